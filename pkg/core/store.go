@@ -66,17 +66,22 @@ type StoreInfo struct {
 	limiter             storelimit.StoreLimit
 	minResolvedTS       uint64
 	lastAwakenTime      time.Time
+	throttleFactor      float64
+	// joinTime is when the store is registered to the cluster, it's zero if
+	// the store joined before the current leader started.
+	joinTime time.Time
 }
 
 // NewStoreInfo creates StoreInfo with meta data.
 func NewStoreInfo(store *metapb.Store, opts ...StoreCreateOption) *StoreInfo {
 	storeInfo := &StoreInfo{
-		meta:          store,
-		storeStats:    newStoreStats(),
-		leaderWeight:  1.0,
-		regionWeight:  1.0,
-		limiter:       storelimit.NewStoreRateLimit(0.0),
-		minResolvedTS: 0,
+		meta:           store,
+		storeStats:     newStoreStats(),
+		leaderWeight:   1.0,
+		regionWeight:   1.0,
+		limiter:        storelimit.NewStoreRateLimit(0.0),
+		minResolvedTS:  0,
+		throttleFactor: 1.0,
 	}
 	for _, opt := range opts {
 		opt(storeInfo)
@@ -301,6 +306,18 @@ func (s *StoreInfo) GetPendingPeerCount() int {
 	return s.pendingPeerCount
 }
 
+// GetThrottleFactor returns the ratio of the store limit that the store can
+// take, which is lowered once the store is struggling.
+func (s *StoreInfo) GetThrottleFactor() float64 {
+	return s.throttleFactor
+}
+
+// GetJoinTime returns when the store is registered to the cluster. It's zero
+// if the store joined before the current leader started.
+func (s *StoreInfo) GetJoinTime() time.Time {
+	return s.joinTime
+}
+
 // GetLeaderWeight returns the leader weight of the store.
 func (s *StoreInfo) GetLeaderWeight() float64 {
 	return s.leaderWeight
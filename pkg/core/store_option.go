@@ -239,6 +239,20 @@ func SetNewStoreStats(stats *pdpb.StoreStats) StoreCreateOption {
 	}
 }
 
+// SetThrottleFactor sets the throttle factor for the store.
+func SetThrottleFactor(factor float64) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.throttleFactor = factor
+	}
+}
+
+// SetStoreJoinTime sets the time when the store is registered to the cluster.
+func SetStoreJoinTime(joinTime time.Time) StoreCreateOption {
+	return func(store *StoreInfo) {
+		store.joinTime = joinTime
+	}
+}
+
 // SetMinResolvedTS sets min resolved ts for the store.
 func SetMinResolvedTS(minResolvedTS uint64) StoreCreateOption {
 	return func(store *StoreInfo) {
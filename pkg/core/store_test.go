@@ -235,3 +235,44 @@ func newStoreInfoWithDisk(id, used, available, capacity, regionSize uint64) *Sto
 	)
 	return store
 }
+
+func TestNextThrottleFactor(t *testing.T) {
+	re := require.New(t)
+	store := NewStoreInfo(&metapb.Store{Id: 1})
+	re.Equal(1.0, store.GetThrottleFactor())
+	thresholds := StoreThrottleThresholds{
+		ApplyLatency:     500 * time.Millisecond,
+		SnapshotDuration: 10 * time.Minute,
+		PendingCount:     8,
+	}
+	healthy := &pdpb.StoreStats{SlowTrend: &pdpb.SlowTrend{CauseValue: 1000}}
+	re.Equal(1.0, store.NextThrottleFactor(healthy, thresholds))
+
+	struggling := []*pdpb.StoreStats{
+		{IsApplyBusy: true},
+		{SlowTrend: &pdpb.SlowTrend{CauseValue: 600000}},
+		{SnapshotStats: []*pdpb.SnapshotStat{{RegionId: 1, TotalDurationSec: 600}}},
+		{ApplyingSnapCount: 4, ReceivingSnapCount: 4},
+	}
+	for _, stats := range struggling {
+		re.Equal(0.5, store.NextThrottleFactor(stats, thresholds))
+	}
+	// The pending peers of the store count as well.
+	re.Equal(1.0, store.NextThrottleFactor(&pdpb.StoreStats{ApplyingSnapCount: 4}, thresholds))
+	store = store.Clone(SetPendingPeerCount(4))
+	re.Equal(0.5, store.NextThrottleFactor(&pdpb.StoreStats{ApplyingSnapCount: 4}, thresholds))
+	// The disabled thresholds are ignored.
+	re.Equal(1.0, store.NextThrottleFactor(struggling[1], StoreThrottleThresholds{}))
+
+	// The factor is halved down to the minimum, and then recovers step by step.
+	for i := 0; i < 10; i++ {
+		store = store.Clone(SetThrottleFactor(store.NextThrottleFactor(struggling[0], thresholds)))
+	}
+	re.Equal(minThrottleFactor, store.GetThrottleFactor())
+	store = store.Clone(SetThrottleFactor(store.NextThrottleFactor(healthy, thresholds)))
+	re.InDelta(0.2, store.GetThrottleFactor(), 1e-9)
+	for i := 0; i < 10; i++ {
+		store = store.Clone(SetThrottleFactor(store.NextThrottleFactor(healthy, thresholds)))
+	}
+	re.Equal(1.0, store.GetThrottleFactor())
+}
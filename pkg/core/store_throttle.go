@@ -0,0 +1,77 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package core
+
+import (
+	"math"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/pdpb"
+)
+
+const (
+	// minThrottleFactor is the lowest ratio of the store limit that a
+	// struggling store can take, so that it is never starved of operators.
+	minThrottleFactor = 0.1
+	// throttleDecreaseRatio is the ratio the throttle factor is multiplied by
+	// once the store is struggling.
+	throttleDecreaseRatio = 0.5
+	// throttleIncreaseStep is the step the throttle factor is increased by
+	// once the store recovers.
+	throttleIncreaseStep = 0.1
+)
+
+// StoreThrottleThresholds are the thresholds over which a store is regarded as
+// struggling. The zero value of a threshold disables it.
+type StoreThrottleThresholds struct {
+	// ApplyLatency is compared with the raftstore latency reported by the slow trend.
+	ApplyLatency time.Duration
+	// SnapshotDuration is compared with the longest duration of the snapshots sent by the store.
+	SnapshotDuration time.Duration
+	// PendingCount is compared with the sum of the pending peers and the snapshots being
+	// received and applied by the store.
+	PendingCount uint64
+}
+
+// NextThrottleFactor returns the throttle factor of the store after it reports
+// the stats. It is a feedback controller which halves the factor once the
+// store is struggling and increases it step by step once the store recovers.
+func (s *StoreInfo) NextThrottleFactor(stats *pdpb.StoreStats, thresholds StoreThrottleThresholds) float64 {
+	factor := s.GetThrottleFactor()
+	if isStoreStruggling(stats, s.GetPendingPeerCount(), thresholds) {
+		return math.Max(factor*throttleDecreaseRatio, minThrottleFactor)
+	}
+	return math.Min(factor+throttleIncreaseStep, 1)
+}
+
+func isStoreStruggling(stats *pdpb.StoreStats, pendingPeerCount int, thresholds StoreThrottleThresholds) bool {
+	if stats.GetIsApplyBusy() {
+		return true
+	}
+	// The cause value of the slow trend is the latency in microseconds.
+	if thresholds.ApplyLatency > 0 &&
+		time.Duration(stats.GetSlowTrend().GetCauseValue()*float64(time.Microsecond)) >= thresholds.ApplyLatency {
+		return true
+	}
+	if thresholds.SnapshotDuration > 0 {
+		for _, snapshot := range stats.GetSnapshotStats() {
+			if time.Duration(snapshot.GetTotalDurationSec())*time.Second >= thresholds.SnapshotDuration {
+				return true
+			}
+		}
+	}
+	pending := uint64(pendingPeerCount) + uint64(stats.GetApplyingSnapCount()) + uint64(stats.GetReceivingSnapCount())
+	return thresholds.PendingCount > 0 && pending >= thresholds.PendingCount
+}
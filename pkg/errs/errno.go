@@ -110,6 +110,7 @@ var (
 	ErrInternalGrowth                   = errors.Normalize("unknown interval growth type error", errors.RFCCodeText("PD:scheduler:ErrInternalGrowth"))
 	ErrSchedulerCreateFuncNotRegistered = errors.Normalize("create func of %v is not registered", errors.RFCCodeText("PD:scheduler:ErrSchedulerCreateFuncNotRegistered"))
 	ErrSchedulerTiKVSplitDisabled       = errors.Normalize("tikv split region disabled", errors.RFCCodeText("PD:scheduler:ErrSchedulerTiKVSplitDisabled"))
+	ErrSchedulerConfigNotReloadable     = errors.Normalize("the config of scheduler %s can not be updated in place", errors.RFCCodeText("PD:scheduler:ErrSchedulerConfigNotReloadable"))
 )
 
 // checker errors
@@ -128,16 +129,30 @@ var (
 
 // placement errors
 var (
-	ErrRuleContent   = errors.Normalize("invalid rule content, %s", errors.RFCCodeText("PD:placement:ErrRuleContent"))
-	ErrLoadRule      = errors.Normalize("load rule failed", errors.RFCCodeText("PD:placement:ErrLoadRule"))
-	ErrLoadRuleGroup = errors.Normalize("load rule group failed", errors.RFCCodeText("PD:placement:ErrLoadRuleGroup"))
-	ErrBuildRuleList = errors.Normalize("build rule list failed, %s", errors.RFCCodeText("PD:placement:ErrBuildRuleList"))
+	ErrRuleContent          = errors.Normalize("invalid rule content, %s", errors.RFCCodeText("PD:placement:ErrRuleContent"))
+	ErrLoadRule             = errors.Normalize("load rule failed", errors.RFCCodeText("PD:placement:ErrLoadRule"))
+	ErrLoadRuleGroup        = errors.Normalize("load rule group failed", errors.RFCCodeText("PD:placement:ErrLoadRuleGroup"))
+	ErrBuildRuleList        = errors.Normalize("build rule list failed, %s", errors.RFCCodeText("PD:placement:ErrBuildRuleList"))
+	ErrRuleBundle           = errors.Normalize("invalid rule bundle, %s", errors.RFCCodeText("PD:placement:ErrRuleBundle"))
+	ErrRuleSnapshotName     = errors.Normalize("invalid rule snapshot name %s", errors.RFCCodeText("PD:placement:ErrRuleSnapshotName"))
+	ErrRuleSnapshotNotFound = errors.Normalize("rule snapshot %s not found", errors.RFCCodeText("PD:placement:ErrRuleSnapshotNotFound"))
+	ErrRuleGroupFrozen      = errors.Normalize("rule group %s is frozen", errors.RFCCodeText("PD:placement:ErrRuleGroupFrozen"))
+	ErrRuleGroupExists      = errors.Normalize("rule group %s already exists", errors.RFCCodeText("PD:placement:ErrRuleGroupExists"))
+	ErrRuleGroupNotFound    = errors.Normalize("rule group %s not found", errors.RFCCodeText("PD:placement:ErrRuleGroupNotFound"))
+	ErrRuleNotFound         = errors.Normalize("rule %s from rule group %s not found", errors.RFCCodeText("PD:placement:ErrRuleNotFound"))
+	ErrDefaultRuleProtected = errors.Normalize("the default rule can't be deleted without force", errors.RFCCodeText("PD:placement:ErrDefaultRuleProtected"))
+	ErrRuleUnschedulable    = errors.Normalize("the rule change makes %d regions unschedulable, such as %v", errors.RFCCodeText("PD:placement:ErrRuleUnschedulable"))
+	ErrRuleTombstone        = errors.Normalize("invalid rule tombstone, %s", errors.RFCCodeText("PD:placement:ErrRuleTombstone"))
+	ErrIdempotencyKeyReused = errors.Normalize("idempotency key %s is reused by a different rule", errors.RFCCodeText("PD:placement:ErrIdempotencyKeyReused"))
+	ErrRuleSetNotFound      = errors.Normalize("rule set %s not found", errors.RFCCodeText("PD:placement:ErrRuleSetNotFound"))
+	ErrRuleRevision         = errors.Normalize("rule revision %d is not in the history", errors.RFCCodeText("PD:placement:ErrRuleRevision"))
 )
 
 // region label errors
 var (
 	ErrRegionRuleContent  = errors.Normalize("invalid region rule content, %s", errors.RFCCodeText("PD:region:ErrRegionRuleContent"))
 	ErrRegionRuleNotFound = errors.Normalize("region label rule not found for id %s", errors.RFCCodeText("PD:region:ErrRegionRuleNotFound"))
+	ErrRegionRuleRevision = errors.Normalize("region label rules have been updated, expected revision %d but current revision is %d", errors.RFCCodeText("PD:region:ErrRegionRuleRevision"))
 )
 
 // cluster errors
@@ -230,6 +245,7 @@ var (
 	ErrEtcdKVPut         = errors.Normalize("etcd KV put failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVPut"))
 	ErrEtcdKVDelete      = errors.Normalize("etcd KV delete failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVDelete"))
 	ErrEtcdKVGet         = errors.Normalize("etcd KV get failed", errors.RFCCodeText("PD:etcd:ErrEtcdKVGet"))
+	ErrEtcdCompact       = errors.Normalize("etcd compact failed", errors.RFCCodeText("PD:etcd:ErrEtcdCompact"))
 	ErrEtcdKVGetResponse = errors.Normalize("etcd invalid get value response %v, must only one", errors.RFCCodeText("PD:etcd:ErrEtcdKVGetResponse"))
 	ErrEtcdGetCluster    = errors.Normalize("etcd get cluster from remote peer failed", errors.RFCCodeText("PD:etcd:ErrEtcdGetCluster"))
 	ErrEtcdMoveLeader    = errors.Normalize("etcd move leader error", errors.RFCCodeText("PD:etcd:ErrEtcdMoveLeader"))
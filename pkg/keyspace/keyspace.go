@@ -28,6 +28,7 @@ import (
 	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/schedule/core"
 	"github.com/tikv/pd/pkg/schedule/labeler"
+	"github.com/tikv/pd/pkg/schedule/placement"
 	"github.com/tikv/pd/pkg/slice"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/storage/kv"
@@ -585,6 +586,12 @@ func (manager *Manager) UpdateKeyspaceState(name string, newState keyspacepb.Key
 		zap.String("keyspace-id", meta.GetName()),
 		zap.String("new-state", newState.String()),
 	)
+	if newState == keyspacepb.KeyspaceState_TOMBSTONE {
+		// updating to the same state again retries the deletion.
+		if err := manager.deleteKeyspaceRules(meta.GetId()); err != nil {
+			return nil, err
+		}
+	}
 	return meta, nil
 }
 
@@ -630,9 +637,33 @@ func (manager *Manager) UpdateKeyspaceStateByID(id uint32, newState keyspacepb.K
 		zap.String("name", meta.GetName()),
 		zap.String("new-state", newState.String()),
 	)
+	if newState == keyspacepb.KeyspaceState_TOMBSTONE {
+		// updating to the same state again retries the deletion.
+		if err := manager.deleteKeyspaceRules(meta.GetId()); err != nil {
+			return nil, err
+		}
+	}
 	return meta, nil
 }
 
+// deleteKeyspaceRules garbage-collects the placement rules scoped to the dropped
+// keyspace. The keyspace is kept as tombstone if it fails, so the caller can
+// update the state again to retry.
+func (manager *Manager) deleteKeyspaceRules(id uint32) error {
+	cl, ok := manager.cluster.(interface{ GetRuleManager() *placement.RuleManager })
+	if !ok || !manager.cluster.GetSharedConfig().IsPlacementRulesEnabled() {
+		return nil
+	}
+	if err := cl.GetRuleManager().DeleteKeyspaceRules(id); err != nil {
+		log.Warn("[keyspace] failed to delete the rules of the dropped keyspace",
+			zap.Uint32("keyspace-id", id),
+			zap.Error(err),
+		)
+		return err
+	}
+	return nil
+}
+
 // updateKeyspaceState updates keyspace meta and record the update time.
 func updateKeyspaceState(meta *keyspacepb.KeyspaceMeta, newState keyspacepb.KeyspaceState, now int64) error {
 	// If already in the target state, do nothing and return.
@@ -0,0 +1,200 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyspace
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
+	"golang.org/x/exp/slices"
+)
+
+// RuleValidationResult is the validation result of the rules scoped to a keyspace.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleValidationResult struct {
+	KeyspaceID uint32   `json:"keyspace_id"`
+	Name       string   `json:"name"`
+	Issues     []string `json:"issues"`
+}
+
+// ValidateKeyspaceRules checks the rules scoped to up to limit keyspaces starting
+// from startID. A keyspace is reported if its region label rule does not match the
+// keyspace boundary, if any placement rule crosses the keyspace boundary, or if any
+// placement rule scoped to the keyspace can not be satisfied by the current stores.
+// The rules covering the whole key space, such as pd/default, and the ones scoped
+// to other keyspaces are not checked. Only the keyspaces with issues are returned.
+func (manager *Manager) ValidateKeyspaceRules(startID uint32, limit int) ([]*RuleValidationResult, error) {
+	cl, ok := manager.cluster.(interface {
+		GetRegionLabeler() *labeler.RegionLabeler
+		GetRuleManager() *placement.RuleManager
+	})
+	if !ok {
+		return nil, errors.New("cluster does not support region label")
+	}
+	keyspaces, err := manager.LoadRangeKeyspace(startID, limit)
+	if err != nil {
+		return nil, err
+	}
+	var stores []*core.StoreInfo
+	for _, s := range manager.cluster.GetStores() {
+		if !s.IsRemoved() {
+			stores = append(stores, s)
+		}
+	}
+	var (
+		rules   []*placement.Rule
+		results []*RuleValidationResult
+	)
+	// The rule manager is not initialized if the placement rules are disabled.
+	if manager.cluster.GetSharedConfig().IsPlacementRulesEnabled() {
+		rules = cl.GetRuleManager().GetAllRules()
+	}
+	for _, meta := range keyspaces {
+		if meta.GetState() == keyspacepb.KeyspaceState_TOMBSTONE {
+			continue
+		}
+		labelRule := cl.GetRegionLabeler().GetLabelRule(getRegionLabelID(meta.GetId()))
+		if result := validateKeyspaceRules(meta, labelRule, rules, stores); len(result.Issues) > 0 {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+func validateKeyspaceRules(meta *keyspacepb.KeyspaceMeta, labelRule *labeler.LabelRule, rules []*placement.Rule, stores []*core.StoreInfo) *RuleValidationResult {
+	result := &RuleValidationResult{
+		KeyspaceID: meta.GetId(),
+		Name:       meta.GetName(),
+	}
+	bound := keyspaceutil.MakeRegionBound(meta.GetId())
+	bounds := []*labeler.KeyRangeRule{
+		{StartKey: bound.RawLeftBound, EndKey: bound.RawRightBound},
+		{StartKey: bound.TxnLeftBound, EndKey: bound.TxnRightBound},
+	}
+
+	if labelRule == nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("label rule %s is missing", getRegionLabelID(meta.GetId())))
+	} else if ranges, ok := labelRule.Data.([]*labeler.KeyRangeRule); !ok || !equalKeyRanges(ranges, bounds) {
+		result.Issues = append(result.Issues, fmt.Sprintf("key ranges of label rule %s are not aligned with the keyspace boundary", labelRule.ID))
+	}
+
+	for _, rule := range rules {
+		// the rules of the whole key space are not scoped to any keyspace.
+		if len(rule.StartKey) == 0 && len(rule.EndKey) == 0 {
+			continue
+		}
+		if id, ok := rule.GetKeyspaceID(); ok && id != meta.GetId() {
+			continue
+		}
+		var overlapped bool
+		for _, b := range bounds {
+			if !overlapRange(rule.StartKey, rule.EndKey, b.StartKey, b.EndKey) {
+				continue
+			}
+			overlapped = true
+			if !containRange(rule.StartKey, rule.EndKey, b.StartKey, b.EndKey) &&
+				!containRange(b.StartKey, b.EndKey, rule.StartKey, rule.EndKey) {
+				result.Issues = append(result.Issues, fmt.Sprintf("range of rule %s/%s crosses the keyspace boundary", rule.GroupID, rule.ID))
+				break
+			}
+		}
+		if !overlapped {
+			continue
+		}
+		if issue := checkRuleSatisfiable(rule, stores); issue != "" {
+			result.Issues = append(result.Issues, issue)
+		}
+	}
+	return result
+}
+
+// checkRuleSatisfiable returns the issue if the stores can not hold the peers
+// of the rule. The stores should match the label constraints and be able to
+// hold the role, and the peers must be isolated by the isolation level.
+func checkRuleSatisfiable(rule *placement.Rule, stores []*core.StoreInfo) string {
+	// the peers are isolated by the location labels up to the isolation level.
+	var levels []string
+	if i := slices.Index(rule.LocationLabels, rule.IsolationLevel); rule.IsolationLevel != "" && i >= 0 {
+		levels = rule.LocationLabels[:i+1]
+	}
+	var matched int
+	isolated := make(map[string]struct{})
+	for _, s := range stores {
+		if !rule.MatchStore(s) {
+			continue
+		}
+		// only the learners can be placed on TiFlash.
+		if rule.Role != placement.Learner && s.IsTiFlash() {
+			continue
+		}
+		if rule.Role == placement.Leader && !s.AllowLeaderTransfer() {
+			continue
+		}
+		matched++
+		if len(levels) > 0 {
+			values := make([]string, 0, len(levels))
+			for _, level := range levels {
+				values = append(values, s.GetLabelValue(level))
+			}
+			isolated[strings.Join(values, "/")] = struct{}{}
+		}
+	}
+	if matched < rule.Count {
+		return fmt.Sprintf("rule %s/%s needs %d %s peers but only %d stores match", rule.GroupID, rule.ID, rule.Count, rule.Role, matched)
+	}
+	if len(levels) > 0 && len(isolated) < rule.Count {
+		return fmt.Sprintf("rule %s/%s needs %d %s peers isolated by %s but only %d %ss match",
+			rule.GroupID, rule.ID, rule.Count, rule.Role, rule.IsolationLevel, len(isolated), rule.IsolationLevel)
+	}
+	return ""
+}
+
+func equalKeyRanges(ranges, expected []*labeler.KeyRangeRule) bool {
+	if len(ranges) != len(expected) {
+		return false
+	}
+	for i := range ranges {
+		if !bytes.Equal(ranges[i].StartKey, expected[i].StartKey) || !bytes.Equal(ranges[i].EndKey, expected[i].EndKey) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlapRange returns whether [start1, end1) and [start2, end2) overlap.
+// An empty end key means +inf.
+func overlapRange(start1, end1, start2, end2 []byte) bool {
+	return (len(end2) == 0 || bytes.Compare(start1, end2) < 0) &&
+		(len(end1) == 0 || bytes.Compare(start2, end1) < 0)
+}
+
+// containRange returns whether [start1, end1) contains [start2, end2).
+// An empty end key means +inf.
+func containRange(start1, end1, start2, end2 []byte) bool {
+	if bytes.Compare(start1, start2) > 0 {
+		return false
+	}
+	if len(end1) == 0 {
+		return true
+	}
+	return len(end2) > 0 && bytes.Compare(end2, end1) <= 0
+}
@@ -0,0 +1,161 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyspace
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/keyspacepb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/mock/mockconfig"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
+)
+
+func TestValidateKeyspaceRules(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	regionLabeler, err := labeler.NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	stores := []*core.StoreInfo{
+		core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1"}),
+		core.NewStoreInfoWithLabel(2, map[string]string{"zone": "z1"}),
+		core.NewStoreInfoWithLabel(3, map[string]string{"zone": "z1"}),
+	}
+	ks1 := &keyspacepb.KeyspaceMeta{Id: 1, Name: "ks1"}
+	ks2 := &keyspacepb.KeyspaceMeta{Id: 2, Name: "ks2"}
+	bound1, bound2 := keyspaceutil.MakeRegionBound(1), keyspaceutil.MakeRegionBound(2)
+	newRule := func(id string, start, end []byte, count int, zone string) *placement.Rule {
+		return &placement.Rule{
+			GroupID:  "keyspace",
+			ID:       id,
+			StartKey: start,
+			EndKey:   end,
+			Role:     placement.Voter,
+			Count:    count,
+			LabelConstraints: []placement.LabelConstraint{
+				{Key: "zone", Op: placement.In, Values: []string{zone}},
+			},
+		}
+	}
+
+	// The rules are consistent with the keyspace boundary.
+	re.NoError(regionLabeler.SetLabelRule(MakeLabelRule(1)))
+	rules := []*placement.Rule{newRule("ks1", bound1.TxnLeftBound, bound1.TxnRightBound, 3, "z1")}
+	result := validateKeyspaceRules(ks1, regionLabeler.GetLabelRule(getRegionLabelID(1)), rules, stores)
+	re.Empty(result.Issues)
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), rules, stores)
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "label rule keyspaces/2 is missing")
+
+	// The range of keyspace 1 shrinks but its scoped rules still cover the old range.
+	staleRule := MakeLabelRule(1)
+	staleRule.Data = labeler.MakeKeyRanges(
+		hex.EncodeToString(bound1.RawLeftBound), hex.EncodeToString(bound2.RawRightBound),
+		hex.EncodeToString(bound1.TxnLeftBound), hex.EncodeToString(bound2.TxnRightBound),
+	)
+	re.NoError(regionLabeler.SetLabelRule(staleRule))
+	rules = []*placement.Rule{newRule("ks1", bound1.TxnLeftBound, bound2.TxnRightBound, 3, "z1")}
+	result = validateKeyspaceRules(ks1, regionLabeler.GetLabelRule(getRegionLabelID(1)), rules, stores)
+	re.Equal(uint32(1), result.KeyspaceID)
+	re.Len(result.Issues, 2)
+	re.Contains(result.Issues[0], "not aligned with the keyspace boundary")
+	re.Contains(result.Issues[1], "range of rule keyspace/ks1 crosses the keyspace boundary")
+	// The stale rule also leaks into keyspace 2.
+	re.NoError(regionLabeler.SetLabelRule(MakeLabelRule(2)))
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), rules, stores)
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "range of rule keyspace/ks1 crosses the keyspace boundary")
+
+	// The scoped rule can not be satisfied by the current stores.
+	rules = []*placement.Rule{
+		newRule("ks2", bound2.TxnLeftBound, bound2.TxnRightBound, 1, "z2"),
+		// the rule does not overlap with keyspace 2.
+		newRule("ks1", bound1.TxnLeftBound, bound1.TxnRightBound, 1, "z2"),
+	}
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), rules, stores)
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "rule keyspace/ks2 needs 1 voter peers but only 0 stores match")
+	// The rule covering the whole key space is not scoped to the keyspace.
+	rules = []*placement.Rule{newRule("default", []byte{}, []byte{}, 4, "z1")}
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), rules, stores)
+	re.Empty(result.Issues)
+	// The rule scoped to another keyspace is skipped.
+	rule := newRule("ks2", bound2.TxnLeftBound, bound2.TxnRightBound, 4, "z1")
+	otherKeyspaceID, keyspaceID := uint32(1), uint32(2)
+	rule.KeyspaceID = &otherKeyspaceID
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, stores)
+	re.Empty(result.Issues)
+	rule.KeyspaceID = &keyspaceID
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, stores)
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "rule keyspace/ks2 needs 4 voter peers but only 3 stores match")
+
+	// The voters can not be placed on TiFlash, while the learners can.
+	tiflash := core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2", core.EngineKey: core.EngineTiFlash})
+	rule = newRule("ks2", bound2.TxnLeftBound, bound2.TxnRightBound, 1, "z2")
+	rule.LabelConstraints = append(rule.LabelConstraints, placement.LabelConstraint{Key: core.EngineKey, Op: placement.In, Values: []string{core.EngineTiFlash}})
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, append(stores, tiflash))
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "rule keyspace/ks2 needs 1 voter peers but only 0 stores match")
+	rule.Role = placement.Learner
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, append(stores, tiflash))
+	re.Empty(result.Issues)
+
+	// The peers should be isolated by the isolation level.
+	rule = newRule("ks2", bound2.TxnLeftBound, bound2.TxnRightBound, 3, "z1")
+	rule.LocationLabels, rule.IsolationLevel = []string{"zone", "host"}, "zone"
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, stores)
+	re.Len(result.Issues, 1)
+	re.Contains(result.Issues[0], "rule keyspace/ks2 needs 3 voter peers isolated by zone but only 1 zones match")
+	// The location labels without the isolation level are the best effort.
+	rule.IsolationLevel = ""
+	result = validateKeyspaceRules(ks2, regionLabeler.GetLabelRule(getRegionLabelID(2)), []*placement.Rule{rule}, stores)
+	re.Empty(result.Issues)
+}
+
+func TestKeyspaceRuleBoundary(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	manager := placement.NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	regionLabeler, err := labeler.NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	// The rules bound to the keyspace by ID are translated into the keyspace boundary.
+	for _, id := range []uint32{1, 100, 0xffff} {
+		bound, keyspaceID := keyspaceutil.MakeRegionBound(id), id
+		re.NoError(manager.SetRule(&placement.Rule{GroupID: "keyspace", ID: "txn", KeyspaceID: &keyspaceID, Role: placement.Learner, Count: 1}))
+		re.NoError(manager.SetRule(&placement.Rule{GroupID: "keyspace", ID: "raw", KeyspaceID: &keyspaceID, KeyspaceMode: placement.KeyspaceModeRaw, Role: placement.Learner, Count: 1}))
+		txn, raw := manager.GetRule("keyspace", "txn"), manager.GetRule("keyspace", "raw")
+		re.Equal(bound.TxnLeftBound, txn.StartKey)
+		re.Equal(bound.TxnRightBound, txn.EndKey)
+		re.Equal(bound.RawLeftBound, raw.StartKey)
+		re.Equal(bound.RawRightBound, raw.EndKey)
+		re.NoError(regionLabeler.SetLabelRule(MakeLabelRule(id)))
+		result := validateKeyspaceRules(&keyspacepb.KeyspaceMeta{Id: id}, regionLabeler.GetLabelRule(getRegionLabelID(id)), []*placement.Rule{txn, raw}, nil)
+		// Only the stores are missing, the rules are aligned with the keyspace boundary.
+		re.Len(result.Issues, 2)
+		for _, issue := range result.Issues {
+			re.Contains(issue, "needs 1 learner peers but only 0 stores match")
+		}
+	}
+}
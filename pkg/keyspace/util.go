@@ -16,17 +16,16 @@ package keyspace
 
 import (
 	"container/heap"
-	"encoding/binary"
 	"encoding/hex"
 	"regexp"
 	"strconv"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/kvproto/pkg/keyspacepb"
-	"github.com/tikv/pd/pkg/codec"
 	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/schedule/labeler"
 	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
 )
 
 const (
@@ -140,42 +139,9 @@ func MaskKeyspaceID(id uint32) uint32 {
 	return id & 0xFF
 }
 
-// RegionBound represents the region boundary of the given keyspace.
-// For a keyspace with id ['a', 'b', 'c'], it has four boundaries:
-//
-//	Lower bound for raw mode: ['r', 'a', 'b', 'c']
-//	Upper bound for raw mode: ['r', 'a', 'b', 'c + 1']
-//	Lower bound for txn mode: ['x', 'a', 'b', 'c']
-//	Upper bound for txn mode: ['x', 'a', 'b', 'c + 1']
-//
-// From which it shares the lower bound with keyspace with id ['a', 'b', 'c-1'].
-// And shares upper bound with keyspace with id ['a', 'b', 'c + 1'].
-// These repeated bound will not cause any problem, as repetitive bound will be ignored during rangeListBuild,
-// but provides guard against hole in keyspace allocations should it occur.
-type RegionBound struct {
-	RawLeftBound  []byte
-	RawRightBound []byte
-	TxnLeftBound  []byte
-	TxnRightBound []byte
-}
-
-// MakeRegionBound constructs the correct region boundaries of the given keyspace.
-func MakeRegionBound(id uint32) *RegionBound {
-	keyspaceIDBytes := make([]byte, 4)
-	nextKeyspaceIDBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(keyspaceIDBytes, id)
-	binary.BigEndian.PutUint32(nextKeyspaceIDBytes, id+1)
-	return &RegionBound{
-		RawLeftBound:  codec.EncodeBytes(append([]byte{'r'}, keyspaceIDBytes[1:]...)),
-		RawRightBound: codec.EncodeBytes(append([]byte{'r'}, nextKeyspaceIDBytes[1:]...)),
-		TxnLeftBound:  codec.EncodeBytes(append([]byte{'x'}, keyspaceIDBytes[1:]...)),
-		TxnRightBound: codec.EncodeBytes(append([]byte{'x'}, nextKeyspaceIDBytes[1:]...)),
-	}
-}
-
 // makeKeyRanges encodes keyspace ID to correct LabelRule data.
 func makeKeyRanges(id uint32) []interface{} {
-	regionBound := MakeRegionBound(id)
+	regionBound := keyspaceutil.MakeRegionBound(id)
 	return []interface{}{
 		map[string]interface{}{
 			"start_key": hex.EncodeToString(regionBound.RawLeftBound),
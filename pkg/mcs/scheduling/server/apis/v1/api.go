@@ -96,6 +96,8 @@ func NewService(srv *scheserver.Service) *Service {
 	s.RegisterOperatorsRouter()
 	s.RegisterSchedulersRouter()
 	s.RegisterCheckersRouter()
+	s.RegisterStatusRouter()
+	s.RegisterRulesRouter()
 	return s
 }
 
@@ -103,6 +105,7 @@ func NewService(srv *scheserver.Service) *Service {
 func (s *Service) RegisterSchedulersRouter() {
 	router := s.root.Group("schedulers")
 	router.GET("", getSchedulers)
+	router.GET("/keyspaces", getKeyspaceSchedulers)
 }
 
 // RegisterCheckersRouter registers the router of the checkers handler.
@@ -111,6 +114,18 @@ func (s *Service) RegisterCheckersRouter() {
 	router.GET("/:name", getCheckerByName)
 }
 
+// RegisterStatusRouter registers the router of the status handler.
+func (s *Service) RegisterStatusRouter() {
+	router := s.root.Group("status")
+	router.GET("/rule-watch", getRuleWatchStatus)
+}
+
+// RegisterRulesRouter registers the router of the rules handler.
+func (s *Service) RegisterRulesRouter() {
+	router := s.root.Group("rules")
+	router.POST("/resync", resyncRules)
+}
+
 // RegisterOperatorsRouter registers the router of the operators handler.
 func (s *Service) RegisterOperatorsRouter() {
 	router := s.root.Group("operators")
@@ -289,3 +304,45 @@ func getSchedulers(c *gin.Context) {
 		c.IndentedJSON(http.StatusOK, schedulers)
 	}
 }
+
+// @Tags     schedulers
+// @Summary  List the schedulers which run independently for each keyspace.
+// @Produce  json
+// @Success  200  {array}  scheserver.KeyspaceSchedulingStatus
+// @Router   /schedulers/keyspaces [get]
+func getKeyspaceSchedulers(c *gin.Context) {
+	svr := c.MustGet(multiservicesapi.ServiceContextKey).(*scheserver.Server)
+	c.IndentedJSON(http.StatusOK, svr.GetCluster().GetKeyspaceSchedulingStatus())
+}
+
+// @Tags     status
+// @Summary  Get how far the rule storage is behind the PD API server.
+// @Produce  json
+// @Success  200  {object}  rule.WatchStatus
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /status/rule-watch [get]
+func getRuleWatchStatus(c *gin.Context) {
+	svr := c.MustGet(multiservicesapi.ServiceContextKey).(*scheserver.Server)
+	status, err := svr.GetRuleWatcher().GetStatus(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, status)
+}
+
+// @Tags     rules
+// @Summary  Reload the rules, rule groups and region label rules from etcd, and report the drift reconciled.
+// @Produce  json
+// @Success  200  {object}  rule.ReloadResult
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /rules/resync [post]
+func resyncRules(c *gin.Context) {
+	svr := c.MustGet(multiservicesapi.ServiceContextKey).(*scheserver.Server)
+	result, err := svr.GetRuleWatcher().ForceReload(c.Request.Context())
+	if err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, result)
+}
@@ -18,6 +18,8 @@ import (
 	"github.com/tikv/pd/pkg/statistics/buckets"
 	"github.com/tikv/pd/pkg/statistics/utils"
 	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/logutil"
+	"github.com/tikv/pd/pkg/utils/syncutil"
 )
 
 // Cluster is used to manage all information for scheduling purpose.
@@ -34,9 +36,17 @@ type Cluster struct {
 	checkMembershipCh chan struct{}
 	apiServerLeader   atomic.Value
 	clusterID         uint64
+
+	keyspaceSchedulingMu syncutil.RWMutex
+	keyspaceScheduling   map[uint32]*keyspaceScheduling
 }
 
-const regionLabelGCInterval = time.Hour
+const (
+	regionLabelGCInterval = time.Hour
+	// ruleActivationCheckInterval is the interval to re-evaluate the
+	// preconditions and the active windows of the placement rules.
+	ruleActivationCheckInterval = 10 * time.Second
+)
 
 // NewCluster creates a new cluster.
 func NewCluster(ctx context.Context, persistConfig *config.PersistConfig, storage storage.Storage, basicCluster *core.BasicCluster, hbStreams *hbstream.HeartbeatStreams, clusterID uint64, checkMembershipCh chan struct{}) (*Cluster, error) {
@@ -44,6 +54,7 @@ func NewCluster(ctx context.Context, persistConfig *config.PersistConfig, storag
 	if err != nil {
 		return nil, err
 	}
+	labelerManager.SetRegionSetInformer(basicCluster)
 	ruleManager := placement.NewRuleManager(storage, basicCluster, persistConfig)
 	c := &Cluster{
 		ctx:               ctx,
@@ -57,6 +68,7 @@ func NewCluster(ctx context.Context, persistConfig *config.PersistConfig, storag
 		clusterID:         clusterID,
 		checkMembershipCh: checkMembershipCh,
 	}
+	labelerManager.SetHotRegionsFunc(c.hotStat.HotRegionIDs)
 	c.coordinator = schedule.NewCoordinator(ctx, c, hbStreams)
 	err = c.ruleManager.Initialize(persistConfig.GetMaxReplicas(), persistConfig.GetLocationLabels())
 	if err != nil {
@@ -65,6 +77,27 @@ func NewCluster(ctx context.Context, persistConfig *config.PersistConfig, storag
 	return c, nil
 }
 
+// runRuleActivationCheck re-evaluates the preconditions and the active windows
+// of the placement rules periodically, so the rules take effect and become
+// inert without being updated. The unsatisfiable rules are checked too, so
+// the fallback works in the scheduling server as well.
+func (c *Cluster) runRuleActivationCheck() {
+	defer logutil.LogPanic()
+	ticker := time.NewTicker(ruleActivationCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if c.persistConfig.IsPlacementRulesEnabled() {
+				c.ruleManager.CheckRulePreconditions()
+				c.ruleManager.CheckUnsatisfiableRules()
+			}
+		}
+	}
+}
+
 // GetCoordinator returns the coordinator
 func (c *Cluster) GetCoordinator() *schedule.Coordinator {
 	return c.coordinator
@@ -122,6 +155,13 @@ func (c *Cluster) RegionWriteStats() map[uint64][]*statistics.HotPeerStat {
 	return c.hotStat.RegionStats(utils.Write, c.persistConfig.GetHotRegionCacheHitsThreshold())
 }
 
+// RegionStats returns the stats of the peers which have been hot for at least
+// minHotDegree times.
+func (c *Cluster) RegionStats(rw utils.RWType, minHotDegree int) map[uint64][]*statistics.HotPeerStat {
+	// RegionStats is a thread-safe method
+	return c.hotStat.RegionStats(rw, minHotDegree)
+}
+
 // BucketsStats returns hot region's buckets stats.
 func (c *Cluster) BucketsStats(degree int, regionIDs ...uint64) map[uint64][]*buckets.BucketStat {
 	return c.hotStat.BucketsStats(degree, regionIDs...)
@@ -78,8 +78,70 @@ type Config struct {
 
 	ClusterVersion semver.Version `toml:"cluster-version" json:"cluster-version"`
 
+	// RuleWatcherMaxInFlightEvents is the max number of the rule watch events
+	// queued to be applied, the default value is used if it's not positive.
+	RuleWatcherMaxInFlightEvents int `toml:"rule-watcher-max-in-flight-events" json:"rule-watcher-max-in-flight-events"`
+	// RuleWatcherMaxStaleness is the max staleness of the watched rules, the
+	// placement decisions are not made on the rules staler than it. 0 means
+	// unbounded.
+	RuleWatcherMaxStaleness typeutil.Duration `toml:"rule-watcher-max-staleness" json:"rule-watcher-max-staleness"`
+
 	Schedule    sc.ScheduleConfig    `toml:"schedule" json:"schedule"`
 	Replication sc.ReplicationConfig `toml:"replication" json:"replication"`
+
+	// KeyspaceScheduling is the schedulers which run independently for each
+	// keyspace, in addition to the ones of the whole cluster.
+	KeyspaceScheduling []KeyspaceSchedulingConfig `toml:"keyspace-scheduling" json:"keyspace-scheduling"`
+}
+
+// maxKeyspaceID is the max keyspace ID, which is encoded in 3 bytes.
+const maxKeyspaceID = 1<<24 - 1
+
+// KeyspaceScheduledTypes are the types of the schedulers which can be scoped
+// to a keyspace, they only pick the regions in the key ranges they work on.
+var KeyspaceScheduledTypes = []string{"balance-leader", "balance-region", "balance-witness", "hot-region"}
+
+// KeyspaceSchedulingConfig is the config of the schedulers of a keyspace. The
+// operators of the keyspace are limited by their own schedule limits and store
+// limits, so the scheduling of a keyspace is not starved by others. The zero
+// limits mean the ones of the whole cluster are used.
+type KeyspaceSchedulingConfig struct {
+	KeyspaceID uint32              `toml:"keyspace-id" json:"keyspace-id"`
+	Schedulers sc.SchedulerConfigs `toml:"schedulers" json:"schedulers"`
+
+	LeaderScheduleLimit    uint64 `toml:"leader-schedule-limit" json:"leader-schedule-limit"`
+	RegionScheduleLimit    uint64 `toml:"region-schedule-limit" json:"region-schedule-limit"`
+	HotRegionScheduleLimit uint64 `toml:"hot-region-schedule-limit" json:"hot-region-schedule-limit"`
+	WitnessScheduleLimit   uint64 `toml:"witness-schedule-limit" json:"witness-schedule-limit"`
+	// StoreLimit is the number of the add-peer and remove-peer operations of
+	// the keyspace per minute on each store.
+	StoreLimit float64 `toml:"store-limit" json:"store-limit"`
+}
+
+func validateKeyspaceScheduling(configs []KeyspaceSchedulingConfig) error {
+	keyspaces := make(map[uint32]struct{}, len(configs))
+	for _, conf := range configs {
+		if conf.KeyspaceID > maxKeyspaceID {
+			return errors.Errorf("illegal keyspace id %d in keyspace-scheduling, larger than %d", conf.KeyspaceID, maxKeyspaceID)
+		}
+		if _, ok := keyspaces[conf.KeyspaceID]; ok {
+			return errors.Errorf("duplicated keyspace id %d in keyspace-scheduling", conf.KeyspaceID)
+		}
+		keyspaces[conf.KeyspaceID] = struct{}{}
+		if conf.StoreLimit < 0 {
+			return errors.Errorf("negative store-limit of keyspace %d in keyspace-scheduling", conf.KeyspaceID)
+		}
+		if len(conf.Schedulers) == 0 {
+			return errors.Errorf("no scheduler of keyspace %d in keyspace-scheduling", conf.KeyspaceID)
+		}
+		for _, s := range conf.Schedulers {
+			if !slice.AnyOf(KeyspaceScheduledTypes, func(i int) bool { return KeyspaceScheduledTypes[i] == s.Type }) {
+				return errors.Errorf("scheduler %s of keyspace %d can not be scoped to a keyspace, it should be one of %v",
+					s.Type, conf.KeyspaceID, KeyspaceScheduledTypes)
+			}
+		}
+	}
+	return nil
 }
 
 // NewConfig creates a new config.
@@ -193,7 +255,7 @@ func (c *Config) validate() error {
 		return errors.New("log directory shouldn't be the subdirectory of data directory")
 	}
 
-	return nil
+	return validateKeyspaceScheduling(c.KeyspaceScheduling)
 }
 
 // PersistConfig wraps all configurations that need to persist to storage and
@@ -483,6 +545,21 @@ func (o *PersistConfig) IsLocationReplacementEnabled() bool {
 	return o.GetScheduleConfig().EnableLocationReplacement
 }
 
+// IsSchedulingDryRunEnabled returns whether the operators are only recorded rather than dispatched.
+func (o *PersistConfig) IsSchedulingDryRunEnabled() bool {
+	return o.GetScheduleConfig().EnableSchedulingDryRun
+}
+
+// GetOperatorPriorityWeights returns the weights of the priority levels of the waiting operators.
+func (o *PersistConfig) GetOperatorPriorityWeights() []float64 {
+	return o.GetScheduleConfig().OperatorPriorityWeights
+}
+
+// GetRecoveryPreemptRatio returns the ratio of the schedule limits to let the recovery operators preempt.
+func (o *PersistConfig) GetRecoveryPreemptRatio() float64 {
+	return o.GetScheduleConfig().RecoveryPreemptRatio
+}
+
 // IsWitnessAllowed returns if the witness is allowed.
 func (o *PersistConfig) IsWitnessAllowed() bool {
 	return o.GetScheduleConfig().EnableWitness
@@ -493,11 +570,56 @@ func (o *PersistConfig) IsPlacementRulesCacheEnabled() bool {
 	return o.GetReplicationConfig().EnablePlacementRulesCache
 }
 
+// IsUnsatisfiableRuleFallbackEnabled returns if the ranges of unsatisfiable rules fall back to the default rule.
+func (o *PersistConfig) IsUnsatisfiableRuleFallbackEnabled() bool {
+	return o.GetReplicationConfig().EnableUnsatisfiableRuleFallback
+}
+
+// IsRuleTopologyValidationEnabled returns if the rules are validated against the store topology when they are set.
+func (o *PersistConfig) IsRuleTopologyValidationEnabled() bool {
+	return o.GetReplicationConfig().EnableRuleTopologyValidation
+}
+
+// GetRuleTombstoneGracePeriod returns how long a deleted placement rule is kept as a tombstone.
+func (o *PersistConfig) GetRuleTombstoneGracePeriod() time.Duration {
+	return o.GetReplicationConfig().RuleTombstoneGracePeriod.Duration
+}
+
 // IsSchedulingHalted returns if PD scheduling is halted.
 func (o *PersistConfig) IsSchedulingHalted() bool {
 	return o.GetScheduleConfig().HaltScheduling
 }
 
+// GetStoreWarmupDuration returns the warm-up window after a store joins.
+func (o *PersistConfig) GetStoreWarmupDuration() time.Duration {
+	return o.GetScheduleConfig().StoreWarmupDuration.Duration
+}
+
+// GetStoreWarmupInitialRatio returns the ratio of the store limit at the beginning of the warm-up window.
+func (o *PersistConfig) GetStoreWarmupInitialRatio() float64 {
+	return o.GetScheduleConfig().StoreWarmupInitialRatio
+}
+
+// GetTieredStorageLabelKey returns the store label key of the storage tiers.
+func (o *PersistConfig) GetTieredStorageLabelKey() string {
+	return o.GetScheduleConfig().TieredStorageLabelKey
+}
+
+// GetHotStorageTier returns the label value of the fast storage tier.
+func (o *PersistConfig) GetHotStorageTier() string {
+	return o.GetScheduleConfig().HotStorageTier
+}
+
+// GetColdStorageTier returns the label value of the slow storage tier.
+func (o *PersistConfig) GetColdStorageTier() string {
+	return o.GetScheduleConfig().ColdStorageTier
+}
+
+// GetColdRegionDuration returns how long a region is not accessed before it is demoted to the cold storage tier.
+func (o *PersistConfig) GetColdRegionDuration() time.Duration {
+	return o.GetScheduleConfig().ColdRegionDuration.Duration
+}
+
 // GetStoreLimitByType returns the limit of a store with a given type.
 func (o *PersistConfig) GetStoreLimitByType(storeID uint64, typ storelimit.Type) (returned float64) {
 	limit := o.GetStoreLimit(storeID)
@@ -49,6 +49,11 @@ type Watcher struct {
 	configWatcher          *etcdutil.LoopWatcher
 	schedulerConfigWatcher *etcdutil.LoopWatcher
 
+	listenerMu sync.RWMutex
+	// schedulerConfigListener is notified when the config of a scheduler is
+	// updated, so the running scheduler can reload its config in place.
+	schedulerConfigListener func(name string, data []byte)
+
 	*PersistConfig
 }
 
@@ -120,10 +125,14 @@ func (cw *Watcher) initializeConfigWatcher() error {
 func (cw *Watcher) initializeSchedulerConfigWatcher() error {
 	prefixToTrim := cw.schedulerConfigPathPrefix + "/"
 	putFn := func(kv *mvccpb.KeyValue) error {
-		cw.SetSchedulerConfig(
-			strings.TrimPrefix(string(kv.Key), prefixToTrim),
-			string(kv.Value),
-		)
+		name := strings.TrimPrefix(string(kv.Key), prefixToTrim)
+		cw.SetSchedulerConfig(name, string(kv.Value))
+		cw.listenerMu.RLock()
+		listener := cw.schedulerConfigListener
+		cw.listenerMu.RUnlock()
+		if listener != nil {
+			listener(name, kv.Value)
+		}
 		return nil
 	}
 	deleteFn := func(kv *mvccpb.KeyValue) error {
@@ -144,6 +153,14 @@ func (cw *Watcher) initializeSchedulerConfigWatcher() error {
 	return cw.schedulerConfigWatcher.WaitLoad()
 }
 
+// SetSchedulerConfigListener sets the listener which is notified when the
+// config of a scheduler is updated.
+func (cw *Watcher) SetSchedulerConfigListener(listener func(name string, data []byte)) {
+	cw.listenerMu.Lock()
+	defer cw.listenerMu.Unlock()
+	cw.schedulerConfigListener = listener
+}
+
 // Close closes the watcher.
 func (cw *Watcher) Close() {
 	cw.cancel()
@@ -20,6 +20,7 @@ import (
 	"github.com/pingcap/log"
 	bs "github.com/tikv/pd/pkg/basicserver"
 	"github.com/tikv/pd/pkg/mcs/registry"
+	"github.com/tikv/pd/pkg/mcs/scheduling/server/rule"
 	"github.com/tikv/pd/pkg/utils/apiutil"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -66,6 +67,16 @@ func NewService[T ConfigProvider](svr bs.Server) registry.RegistrableService {
 
 // RegisterGRPCService registers the service to gRPC server.
 func (s *Service) RegisterGRPCService(g *grpc.Server) {
+	rule.RegisterEventServer(g, rule.NewEventServer(s.getServingRuleWatcher))
+}
+
+// getServingRuleWatcher returns the rule watcher if the server is the primary,
+// the rule watcher is only running on the primary.
+func (s *Service) getServingRuleWatcher() *rule.Watcher {
+	if !s.IsServing() {
+		return nil
+	}
+	return s.GetRuleWatcher()
 }
 
 // RegisterRESTHandler registers the service to REST server.
@@ -0,0 +1,389 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/storelimit"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/mcs/scheduling/server/config"
+	sc "github.com/tikv/pd/pkg/schedule/config"
+	"github.com/tikv/pd/pkg/schedule/hbstream"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/schedulers"
+	"github.com/tikv/pd/pkg/statistics"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
+	"github.com/tikv/pd/pkg/utils/logutil"
+	"go.uber.org/zap"
+)
+
+const keyspacePushOperatorTickInterval = 500 * time.Millisecond
+
+// keyspaceSchedulingConfig overrides the limits of the cluster config with the
+// ones of a keyspace.
+type keyspaceSchedulingConfig struct {
+	*config.PersistConfig
+	conf *config.KeyspaceSchedulingConfig
+}
+
+// GetLeaderScheduleLimit returns the limit for leader schedule of the keyspace.
+func (c *keyspaceSchedulingConfig) GetLeaderScheduleLimit() uint64 {
+	if c.conf.LeaderScheduleLimit > 0 {
+		return c.conf.LeaderScheduleLimit
+	}
+	return c.PersistConfig.GetLeaderScheduleLimit()
+}
+
+// GetRegionScheduleLimit returns the limit for region schedule of the keyspace.
+func (c *keyspaceSchedulingConfig) GetRegionScheduleLimit() uint64 {
+	if c.conf.RegionScheduleLimit > 0 {
+		return c.conf.RegionScheduleLimit
+	}
+	return c.PersistConfig.GetRegionScheduleLimit()
+}
+
+// GetHotRegionScheduleLimit returns the limit for hot region schedule of the keyspace.
+func (c *keyspaceSchedulingConfig) GetHotRegionScheduleLimit() uint64 {
+	if c.conf.HotRegionScheduleLimit > 0 {
+		return c.conf.HotRegionScheduleLimit
+	}
+	return c.PersistConfig.GetHotRegionScheduleLimit()
+}
+
+// GetWitnessScheduleLimit returns the limit for witness schedule of the keyspace.
+func (c *keyspaceSchedulingConfig) GetWitnessScheduleLimit() uint64 {
+	if c.conf.WitnessScheduleLimit > 0 {
+		return c.conf.WitnessScheduleLimit
+	}
+	return c.PersistConfig.GetWitnessScheduleLimit()
+}
+
+// GetStoreLimitByType returns the store limit of the keyspace for a given store ID and type.
+func (c *keyspaceSchedulingConfig) GetStoreLimitByType(storeID uint64, typ storelimit.Type) float64 {
+	if c.conf.StoreLimit > 0 && (typ == storelimit.AddPeer || typ == storelimit.RemovePeer) {
+		return c.conf.StoreLimit
+	}
+	return c.PersistConfig.GetStoreLimitByType(storeID, typ)
+}
+
+// keyspaceCluster is the view of the cluster for the schedulers of a keyspace,
+// which only pick the regions in the keyspace.
+type keyspaceCluster struct {
+	*Cluster
+	config *keyspaceSchedulingConfig
+	// bounds are the raw and txn key ranges of the keyspace.
+	bounds []core.KeyRange
+	// clusterOperators is the operator controller of the whole cluster, the
+	// regions which have operators in it are not picked.
+	clusterOperators *operator.Controller
+}
+
+func newKeyspaceCluster(c *Cluster, conf *config.KeyspaceSchedulingConfig, clusterOperators *operator.Controller) *keyspaceCluster {
+	bound := keyspaceutil.MakeRegionBound(conf.KeyspaceID)
+	return &keyspaceCluster{
+		Cluster: c,
+		config:  &keyspaceSchedulingConfig{PersistConfig: c.persistConfig, conf: conf},
+		bounds: []core.KeyRange{
+			core.NewKeyRange(string(bound.RawLeftBound), string(bound.RawRightBound)),
+			core.NewKeyRange(string(bound.TxnLeftBound), string(bound.TxnRightBound)),
+		},
+		clusterOperators: clusterOperators,
+	}
+}
+
+// GetSharedConfig returns the shared config of the keyspace.
+func (c *keyspaceCluster) GetSharedConfig() sc.SharedConfigProvider {
+	return c.config
+}
+
+// GetSchedulerConfig returns the scheduler config of the keyspace.
+func (c *keyspaceCluster) GetSchedulerConfig() sc.SchedulerConfigProvider {
+	return c.config
+}
+
+// RandLeaderRegions randomly gets a store's leader regions in the keyspace.
+func (c *keyspaceCluster) RandLeaderRegions(storeID uint64, ranges []core.KeyRange) []*core.RegionInfo {
+	return c.filterRegions(c.Cluster.RandLeaderRegions(storeID, c.clipRanges(ranges)))
+}
+
+// RandFollowerRegions randomly gets a store's follower regions in the keyspace.
+func (c *keyspaceCluster) RandFollowerRegions(storeID uint64, ranges []core.KeyRange) []*core.RegionInfo {
+	return c.filterRegions(c.Cluster.RandFollowerRegions(storeID, c.clipRanges(ranges)))
+}
+
+// RandLearnerRegions randomly gets a store's learner regions in the keyspace.
+func (c *keyspaceCluster) RandLearnerRegions(storeID uint64, ranges []core.KeyRange) []*core.RegionInfo {
+	return c.filterRegions(c.Cluster.RandLearnerRegions(storeID, c.clipRanges(ranges)))
+}
+
+// RandWitnessRegions randomly gets a store's witness regions in the keyspace.
+func (c *keyspaceCluster) RandWitnessRegions(storeID uint64, ranges []core.KeyRange) []*core.RegionInfo {
+	return c.filterRegions(c.Cluster.RandWitnessRegions(storeID, c.clipRanges(ranges)))
+}
+
+// RandPendingRegions randomly gets a store's regions with a pending peer in the keyspace.
+func (c *keyspaceCluster) RandPendingRegions(storeID uint64, ranges []core.KeyRange) []*core.RegionInfo {
+	return c.filterRegions(c.Cluster.RandPendingRegions(storeID, c.clipRanges(ranges)))
+}
+
+// RegionReadStats returns the read stats of the hot regions in the keyspace.
+func (c *keyspaceCluster) RegionReadStats() map[uint64][]*statistics.HotPeerStat {
+	return c.filterHotPeers(c.Cluster.RegionReadStats())
+}
+
+// RegionWriteStats returns the write stats of the hot regions in the keyspace.
+func (c *keyspaceCluster) RegionWriteStats() map[uint64][]*statistics.HotPeerStat {
+	return c.filterHotPeers(c.Cluster.RegionWriteStats())
+}
+
+// RegionStats returns the stats of the peers in the keyspace which have been
+// hot for at least minHotDegree times.
+func (c *keyspaceCluster) RegionStats(rw utils.RWType, minHotDegree int) map[uint64][]*statistics.HotPeerStat {
+	return c.filterHotPeers(c.Cluster.RegionStats(rw, minHotDegree))
+}
+
+// clipRanges returns the parts of the ranges which are in the keyspace.
+func (c *keyspaceCluster) clipRanges(ranges []core.KeyRange) []core.KeyRange {
+	if len(ranges) == 0 {
+		return c.bounds
+	}
+	var clipped []core.KeyRange
+	for _, r := range ranges {
+		for _, bound := range c.bounds {
+			start, end := bound.StartKey, bound.EndKey
+			if bytes.Compare(r.StartKey, start) > 0 {
+				start = r.StartKey
+			}
+			if len(r.EndKey) > 0 && bytes.Compare(r.EndKey, end) < 0 {
+				end = r.EndKey
+			}
+			if bytes.Compare(start, end) < 0 {
+				clipped = append(clipped, core.KeyRange{StartKey: start, EndKey: end})
+			}
+		}
+	}
+	sort.Slice(clipped, func(i, j int) bool { return bytes.Compare(clipped[i].StartKey, clipped[j].StartKey) < 0 })
+	return clipped
+}
+
+// contains returns whether the region is in the keyspace.
+func (c *keyspaceCluster) contains(region *core.RegionInfo) bool {
+	for _, bound := range c.bounds {
+		if bytes.Compare(region.GetStartKey(), bound.StartKey) >= 0 &&
+			len(region.GetEndKey()) > 0 && bytes.Compare(region.GetEndKey(), bound.EndKey) <= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRegions removes the regions which cross the bounds of the keyspace
+// and the ones which have operators of the whole cluster.
+func (c *keyspaceCluster) filterRegions(regions []*core.RegionInfo) []*core.RegionInfo {
+	filtered := make([]*core.RegionInfo, 0, len(regions))
+	for _, region := range regions {
+		if c.contains(region) && c.clusterOperators.GetOperator(region.GetID()) == nil {
+			filtered = append(filtered, region)
+		}
+	}
+	return filtered
+}
+
+func (c *keyspaceCluster) filterHotPeers(stats map[uint64][]*statistics.HotPeerStat) map[uint64][]*statistics.HotPeerStat {
+	filtered := make(map[uint64][]*statistics.HotPeerStat, len(stats))
+	for storeID, peers := range stats {
+		var keyspacePeers []*statistics.HotPeerStat
+		for _, peer := range peers {
+			if region := c.GetRegion(peer.RegionID); region != nil && c.contains(region) {
+				keyspacePeers = append(keyspacePeers, peer)
+			}
+		}
+		filtered[storeID] = keyspacePeers
+	}
+	return filtered
+}
+
+// keyspaceScheduling runs the schedulers of a keyspace. The operators are
+// managed by its own operator controller, which limits them with the schedule
+// limits and the store limits of the keyspace, so the scheduling of a keyspace
+// does not starve the others.
+type keyspaceScheduling struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	keyspaceID   uint32
+	cluster      *keyspaceCluster
+	opController *operator.Controller
+	schedulers   *schedulers.Controller
+	// recordOpStepWithTTL records the finished operator steps for the
+	// checkers of the whole cluster.
+	recordOpStepWithTTL func(regionID uint64)
+}
+
+func newKeyspaceScheduling(
+	ctx context.Context,
+	c *Cluster,
+	hbStreams *hbstream.HeartbeatStreams,
+	conf *config.KeyspaceSchedulingConfig,
+) (*keyspaceScheduling, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	co := c.GetCoordinator()
+	cluster := newKeyspaceCluster(c, conf, co.GetOperatorController())
+	opController := operator.NewController(ctx, c.GetBasicCluster(), cluster.config, hbStreams)
+	opController.EnableOwnStoreLimit()
+	// The configs of the schedulers of a keyspace are decided by the config
+	// file, they are not persisted.
+	storage := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	ks := &keyspaceScheduling{
+		ctx:                 ctx,
+		cancel:              cancel,
+		keyspaceID:          conf.KeyspaceID,
+		cluster:             cluster,
+		opController:        opController,
+		schedulers:          schedulers.NewController(ctx, cluster, storage, opController),
+		recordOpStepWithTTL: co.RecordOpStepWithTTL,
+	}
+	for _, schedulerCfg := range conf.Schedulers {
+		if schedulerCfg.Disable {
+			continue
+		}
+		s, err := schedulers.CreateScheduler(schedulerCfg.Type, opController, storage,
+			schedulers.ConfigSliceDecoder(schedulerCfg.Type, schedulerCfg.Args), ks.schedulers.RemoveScheduler)
+		if err == nil {
+			err = ks.schedulers.AddScheduler(s, schedulerCfg.Args...)
+		}
+		if err != nil {
+			ks.stop()
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+func (ks *keyspaceScheduling) start() {
+	ks.wg.Add(1)
+	go ks.drivePushOperator()
+	log.Info("keyspace scheduling is started", zap.Uint32("keyspace-id", ks.keyspaceID),
+		zap.Strings("schedulers", ks.schedulers.GetSchedulerNames()))
+}
+
+func (ks *keyspaceScheduling) stop() {
+	ks.cancel()
+	ks.wg.Wait()
+	ks.schedulers.Wait()
+}
+
+// drivePushOperator pushes the unfinished operators of the keyspace to the stores.
+func (ks *keyspaceScheduling) drivePushOperator() {
+	defer logutil.LogPanic()
+	defer ks.wg.Done()
+	ticker := time.NewTicker(keyspacePushOperatorTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ks.ctx.Done():
+			return
+		case <-ticker.C:
+			ks.opController.PushOperators(ks.recordOpStepWithTTL)
+		}
+	}
+}
+
+// startKeyspaceScheduling starts the schedulers of the keyspaces. The operator
+// controller of the whole cluster does not add the operators to the regions
+// which have operators of the keyspaces, and the schedulers of the keyspaces
+// do not pick the regions which have operators of the whole cluster.
+func (c *Cluster) startKeyspaceScheduling(hbStreams *hbstream.HeartbeatStreams, configs []config.KeyspaceSchedulingConfig) error {
+	c.keyspaceSchedulingMu.Lock()
+	defer c.keyspaceSchedulingMu.Unlock()
+	c.keyspaceScheduling = make(map[uint32]*keyspaceScheduling, len(configs))
+	for i := range configs {
+		ks, err := newKeyspaceScheduling(c.ctx, c, hbStreams, &configs[i])
+		if err != nil {
+			log.Error("failed to create the keyspace scheduling", zap.Uint32("keyspace-id", configs[i].KeyspaceID), errs.ZapError(err))
+			for _, started := range c.keyspaceScheduling {
+				started.stop()
+			}
+			c.keyspaceScheduling = nil
+			return err
+		}
+		c.keyspaceScheduling[ks.keyspaceID] = ks
+	}
+	if len(c.keyspaceScheduling) > 0 {
+		c.GetCoordinator().GetOperatorController().SetConflictOperatorChecker(c.hasKeyspaceOperator)
+	}
+	for _, ks := range c.keyspaceScheduling {
+		ks.start()
+	}
+	return nil
+}
+
+func (c *Cluster) stopKeyspaceScheduling() {
+	c.keyspaceSchedulingMu.Lock()
+	defer c.keyspaceSchedulingMu.Unlock()
+	for _, ks := range c.keyspaceScheduling {
+		ks.stop()
+	}
+	c.keyspaceScheduling = nil
+}
+
+// hasKeyspaceOperator returns whether the region has an operator of a keyspace.
+func (c *Cluster) hasKeyspaceOperator(regionID uint64) bool {
+	c.keyspaceSchedulingMu.RLock()
+	defer c.keyspaceSchedulingMu.RUnlock()
+	for _, ks := range c.keyspaceScheduling {
+		if ks.opController.GetOperator(regionID) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// KeyspaceSchedulingStatus is the status of the schedulers of a keyspace.
+type KeyspaceSchedulingStatus struct {
+	KeyspaceID uint32   `json:"keyspace-id"`
+	Schedulers []string `json:"schedulers"`
+	// Operators is the number of the running operators of the keyspace.
+	Operators int `json:"operators"`
+}
+
+// GetKeyspaceSchedulingStatus returns the status of the schedulers of the keyspaces.
+func (c *Cluster) GetKeyspaceSchedulingStatus() []KeyspaceSchedulingStatus {
+	c.keyspaceSchedulingMu.RLock()
+	defer c.keyspaceSchedulingMu.RUnlock()
+	status := make([]KeyspaceSchedulingStatus, 0, len(c.keyspaceScheduling))
+	for _, ks := range c.keyspaceScheduling {
+		names := ks.schedulers.GetSchedulerNames()
+		sort.Strings(names)
+		status = append(status, KeyspaceSchedulingStatus{
+			KeyspaceID: ks.keyspaceID,
+			Schedulers: names,
+			Operators:  len(ks.opController.GetOperators()),
+		})
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].KeyspaceID < status[j].KeyspaceID })
+	return status
+}
@@ -17,6 +17,8 @@ package meta
 import (
 	"context"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/pingcap/kvproto/pkg/metapb"
@@ -43,6 +45,8 @@ type Watcher struct {
 	etcdClient   *clientv3.Client
 	basicCluster *core.BasicCluster
 	storeWatcher *etcdutil.LoopWatcher
+	// storesLoaded is set once the stores are loaded at the beginning.
+	storesLoaded atomic.Bool
 }
 
 // NewWatcher creates a new watcher to watch the meta change from PD API server.
@@ -78,7 +82,12 @@ func (w *Watcher) initializeStoreWatcher() error {
 		}
 		origin := w.basicCluster.GetStore(store.GetId())
 		if origin == nil {
-			w.basicCluster.PutStore(core.NewStoreInfo(store))
+			// the stores not loaded at the beginning are the newly joined ones.
+			if w.storesLoaded.Load() {
+				w.basicCluster.PutStore(core.NewStoreInfo(store, core.SetStoreJoinTime(time.Now())))
+			} else {
+				w.basicCluster.PutStore(core.NewStoreInfo(store))
+			}
 			return nil
 		}
 		w.basicCluster.PutStore(origin.Clone(core.SetStoreState(store.GetState(), store.GetPhysicallyDestroyed())))
@@ -107,7 +116,11 @@ func (w *Watcher) initializeStoreWatcher() error {
 		clientv3.WithPrefix(),
 	)
 	w.storeWatcher.StartWatchLoop()
-	return w.storeWatcher.WaitLoad()
+	if err := w.storeWatcher.WaitLoad(); err != nil {
+		return err
+	}
+	w.storesLoaded.Store(true)
+	return nil
 }
 
 // Close closes the watcher.
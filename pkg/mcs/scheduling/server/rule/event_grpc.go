@@ -0,0 +1,160 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// EventServiceName is the name of the gRPC service of the rule events.
+	EventServiceName = "scheduling.RuleEvent"
+	// EventCodecName is the content subtype of the gRPC service of the rule
+	// events, the messages are encoded in JSON, so the clients need no
+	// generated code, e.g. grpc.CallContentSubtype(EventCodecName).
+	// The name is unique to not replace the codecs of the other gRPC services.
+	EventCodecName = "pd-rule-event-json"
+
+	defaultEventHeartbeatInterval = 10 * time.Second
+	minEventHeartbeatInterval     = 100 * time.Millisecond
+)
+
+func init() {
+	encoding.RegisterCodec(eventCodec{})
+}
+
+type eventCodec struct{}
+
+func (eventCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (eventCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (eventCodec) Name() string {
+	return EventCodecName
+}
+
+// WatchEventsRequest watches the rule events from the start revision, which is
+// inclusive, see Watcher.WatchEvents. Only the new events are streamed if the
+// start revision is 0. A heartbeat is sent every interval in milliseconds.
+type WatchEventsRequest struct {
+	StartRevision       int64  `json:"start_revision,omitempty"`
+	HeartbeatIntervalMs uint64 `json:"heartbeat_interval_ms,omitempty"`
+}
+
+// EventServer is the gRPC server of the rule events.
+type EventServer interface {
+	// WatchEvents streams the add, modify and delete events of the rules, and
+	// the heartbeats with the applied revision.
+	WatchEvents(*WatchEventsRequest, EventWatchServer) error
+}
+
+// EventWatchServer is the server stream of WatchEvents.
+type EventWatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type eventWatchServer struct {
+	grpc.ServerStream
+}
+
+func (s *eventWatchServer) Send(e *Event) error {
+	return s.ServerStream.SendMsg(e)
+}
+
+// EventServiceDesc is the description of the gRPC service of the rule events.
+var EventServiceDesc = grpc.ServiceDesc{
+	ServiceName: EventServiceName,
+	HandlerType: (*EventServer)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "WatchEvents",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := &WatchEventsRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(EventServer).WatchEvents(req, &eventWatchServer{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+// RegisterEventServer registers the gRPC service of the rule events to the
+// gRPC server.
+func RegisterEventServer(g *grpc.Server, srv EventServer) {
+	g.RegisterService(&EventServiceDesc, srv)
+}
+
+type eventServer struct {
+	getWatcher func() *Watcher
+}
+
+// NewEventServer creates the gRPC server of the rule events. getWatcher returns
+// the running rule watcher, or nil if it's not available, e.g., the server is
+// not the primary.
+func NewEventServer(getWatcher func() *Watcher) EventServer {
+	return &eventServer{getWatcher: getWatcher}
+}
+
+func (s *eventServer) WatchEvents(req *WatchEventsRequest, stream EventWatchServer) error {
+	if req.StartRevision < 0 {
+		return status.Errorf(codes.InvalidArgument, "invalid start revision %d", req.StartRevision)
+	}
+	rw := s.getWatcher()
+	if rw == nil {
+		return status.Error(codes.Unavailable, "the rule watcher is not running")
+	}
+	interval := defaultEventHeartbeatInterval
+	if req.HeartbeatIntervalMs > 0 {
+		interval = time.Duration(req.HeartbeatIntervalMs) * time.Millisecond
+	}
+	if interval < minEventHeartbeatInterval {
+		interval = minEventHeartbeatInterval
+	}
+	// the stream is closed with the watcher, e.g., the server is not the primary anymore.
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	stop := context.AfterFunc(rw.ctx, cancel)
+	defer stop()
+	err := rw.WatchEvents(ctx, req.StartRevision, interval, stream.Send)
+	switch err {
+	case ctx.Err():
+		if rw.ctx.Err() != nil {
+			return status.Error(codes.Unavailable, "the rule watcher is closed")
+		}
+		return nil
+	case ErrEventCompacted:
+		// the client should re-list the rules and watch from the revision of the listing.
+		return status.Error(codes.OutOfRange, err.Error())
+	case ErrEventStreamReset:
+		return status.Error(codes.Aborted, err.Error())
+	case ErrSlowSubscriber:
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return err
+}
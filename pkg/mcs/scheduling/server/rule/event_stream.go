@@ -0,0 +1,269 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+)
+
+const (
+	// maxEventHistory is the number of the latest events kept for the
+	// subscribers resuming from a revision.
+	maxEventHistory = 4096
+	// subscriberBufferSize is the number of events buffered for a subscriber,
+	// the subscriber is closed once its buffer is full.
+	subscriberBufferSize = 1024
+)
+
+var (
+	// ErrEventCompacted is returned if the events from the required revision are
+	// not kept anymore. The subscriber should re-list the rules and subscribe
+	// again from the revision of the listing.
+	ErrEventCompacted = errors.New("the required revision of the rule events has been compacted")
+	// ErrEventStreamReset is returned once the rule storage is resynced from etcd,
+	// since the events between the resync and the last applied one are unknown.
+	ErrEventStreamReset = errors.New("the rule event stream is reset by a resync")
+	// ErrSlowSubscriber is returned if the subscriber can not keep up with the events.
+	ErrSlowSubscriber = errors.New("the rule event subscriber is too slow")
+)
+
+// EventType is the type of a rule event.
+type EventType string
+
+const (
+	// EventAdd means the item is created.
+	EventAdd EventType = "add"
+	// EventModify means the item is updated.
+	EventModify EventType = "modify"
+	// EventDelete means the item is removed.
+	EventDelete EventType = "delete"
+	// EventHeartbeat is sent periodically with the applied revision, so the idle
+	// subscribers can keep the connection alive and detect the staleness.
+	EventHeartbeat EventType = "heartbeat"
+	// EventResync is sent to the in-process subscribers once some events are
+	// dropped for them, they should reload the rules from the rule storage of
+	// the Watcher, which has applied all the events up to the revision.
+	EventResync EventType = "resync"
+)
+
+// Event is a change of a rule, rule group or region label rule applied by the
+// Watcher. The Kind is one of "rule", "rule_group" and "region_label", and the
+// Value is the JSON of the item, which is empty for the deletion.
+// NOTE: the events in the same etcd transaction share the same revision.
+type Event struct {
+	Revision int64     `json:"revision"`
+	Type     EventType `json:"type"`
+	Kind     string    `json:"kind,omitempty"`
+	Key      string    `json:"key,omitempty"`
+	Value    string    `json:"value,omitempty"`
+}
+
+func newEvent(kind string, deleted bool, kv *mvccpb.KeyValue, key string) *Event {
+	e := &Event{Revision: kv.ModRevision, Kind: kind, Key: key}
+	switch {
+	case deleted:
+		e.Type = EventDelete
+	case kv.CreateRevision == kv.ModRevision:
+		e.Type = EventAdd
+		e.Value = string(kv.Value)
+	default:
+		e.Type = EventModify
+		e.Value = string(kv.Value)
+	}
+	return e
+}
+
+type subscriber struct {
+	ch  chan *Event
+	err error
+	// inProcess means the subscriber is created by Subscribe, which is never
+	// closed by the hub but notified to resync from the rule storage.
+	inProcess bool
+}
+
+// eventHub broadcasts the events to the subscribers and keeps the latest ones,
+// so a subscriber can resume from the last received revision after reconnecting.
+type eventHub struct {
+	mu      syncutil.Mutex
+	history []*Event
+	// compactedRevision is the max revision of the events dropped from history.
+	compactedRevision int64
+	subscribers       map[*subscriber]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[*subscriber]struct{})}
+}
+
+func (h *eventHub) publish(e *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.history = append(h.history, e)
+	if len(h.history) > maxEventHistory {
+		if dropped := h.history[0]; dropped.Revision > h.compactedRevision {
+			h.compactedRevision = dropped.Revision
+		}
+		h.history[0] = nil
+		h.history = h.history[1:]
+	}
+	for s := range h.subscribers {
+		if s.inProcess {
+			h.sendOrResync(s, e)
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			h.closeSubscriber(s, ErrSlowSubscriber)
+		}
+	}
+}
+
+// sendOrResync sends the event to the in-process subscriber, or drops it and
+// notifies the subscriber to resync if the buffer is full. The last slot of the
+// buffer is reserved for the resync event, so the notification is never lost.
+// If the slot is taken, a resync event is pending, which covers the dropped
+// event since the event is applied to the rule storage before published.
+// It must be called with mu held.
+func (h *eventHub) sendOrResync(s *subscriber, e *Event) {
+	if len(s.ch) < subscriberBufferSize {
+		s.ch <- e
+		return
+	}
+	subscriberResyncCounter.Inc()
+	select {
+	case s.ch <- &Event{Revision: e.Revision, Type: EventResync}:
+	default:
+	}
+}
+
+// subscribe returns a subscriber receiving the events from the start revision,
+// including the ones kept in history. Only the new events are received if the
+// start revision is 0.
+func (h *eventHub) subscribe(startRevision int64) (*subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []*Event
+	if startRevision > 0 {
+		if startRevision <= h.compactedRevision {
+			return nil, ErrEventCompacted
+		}
+		for _, e := range h.history {
+			if e.Revision >= startRevision {
+				replay = append(replay, e)
+			}
+		}
+	}
+	s := &subscriber{ch: make(chan *Event, len(replay)+subscriberBufferSize)}
+	for _, e := range replay {
+		s.ch <- e
+	}
+	h.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+func (h *eventHub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscribers[s]; ok {
+		delete(h.subscribers, s)
+		close(s.ch)
+	}
+}
+
+// reset drops the history and closes all the subscribers, the subscribers can
+// only resume from the given revision afterwards.
+func (h *eventHub) reset(revision int64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.history = nil
+	h.compactedRevision = revision
+	for s := range h.subscribers {
+		if s.inProcess {
+			h.sendOrResync(s, &Event{Revision: revision, Type: EventResync})
+			continue
+		}
+		h.closeSubscriber(s, ErrEventStreamReset)
+	}
+}
+
+// closeSubscriber must be called with mu held.
+func (h *eventHub) closeSubscriber(s *subscriber, err error) {
+	s.err = err
+	delete(h.subscribers, s)
+	close(s.ch)
+}
+
+// WatchEvents streams the rule events from the start revision to the send
+// function until the context is done or an error occurs. The start revision is
+// inclusive, so a client resuming from the last received revision should skip
+// the events it has received. A heartbeat with the applied revision is sent
+// every heartbeatInterval, which is disabled if it's not positive.
+func (rw *Watcher) WatchEvents(ctx context.Context, startRevision int64, heartbeatInterval time.Duration, send func(*Event) error) error {
+	s, err := rw.events.subscribe(startRevision)
+	if err != nil {
+		return err
+	}
+	defer rw.events.unsubscribe(s)
+	var heartbeat <-chan time.Time
+	if heartbeatInterval > 0 {
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-s.ch:
+			if !ok {
+				// the channel is closed by the hub, the error is set before closing.
+				return s.err
+			}
+			if err := send(e); err != nil {
+				return err
+			}
+		case <-heartbeat:
+			rw.statusMu.RLock()
+			revision := rw.appliedRevision
+			rw.statusMu.RUnlock()
+			if err := send(&Event{Revision: revision, Type: EventHeartbeat}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the rule events applied from now on,
+// and the function to unsubscribe, which closes the channel. It's used by the
+// components in the same process, each of which receives the events
+// independently. A slow subscriber never blocks the watch loop: once its buffer
+// is full, the events are dropped and an EventResync is delivered instead, then
+// the subscriber should reload the rules from GetRuleStorage. An EventResync is
+// delivered after a forced resync of the rule storage too.
+func (rw *Watcher) Subscribe() (<-chan *Event, func()) {
+	s := &subscriber{ch: make(chan *Event, subscriberBufferSize+1), inProcess: true}
+	rw.events.mu.Lock()
+	rw.events.subscribers[s] = struct{}{}
+	rw.events.mu.Unlock()
+	var once sync.Once
+	return s.ch, func() { once.Do(func() { rw.events.unsubscribe(s) }) }
+}
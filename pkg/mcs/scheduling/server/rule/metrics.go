@@ -0,0 +1,153 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import "github.com/prometheus/client_golang/prometheus"
+
+const (
+	namespace = "scheduling"
+	subsystem = "rule_watcher"
+)
+
+var (
+	watchEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "events_total",
+			Help:      "Counter of the watch events applied to the rule storage.",
+		}, []string{"type", "event"})
+
+	resyncCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "resync_total",
+			Help:      "Counter of the forced resyncs of the rule storage.",
+		})
+
+	lastResyncGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "last_resync_timestamp",
+			Help:      "The timestamp (s) of the last successful forced resync.",
+		})
+
+	appliedRevisionGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "applied_revision",
+			Help:      "The max etcd revision applied to the rule storage.",
+		})
+
+	staleEventCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "stale_events_total",
+			Help:      "Counter of the stale watch events dropped by the version vector.",
+		}, []string{"type"})
+
+	revisionGapCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "revision_gaps_total",
+			Help:      "Counter of the gaps found in the rule revisions, each of which requests a resync.",
+		})
+
+	eventQueueDepthGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "event_queue_depth",
+			Help:      "The number of the watch events queued to be applied.",
+		})
+
+	droppedEventCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "dropped_events_total",
+			Help:      "Counter of the watch events dropped since the event queue is saturated, which are recovered by a resync.",
+		})
+
+	lagRevisionsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "lag_revisions",
+			Help:      "The number of revisions the rule storage is behind etcd at the last check.",
+		})
+
+	watchErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "watch_errors_total",
+			Help:      "Counter of the errors met by watching etcd.",
+		}, []string{"watcher", "retryable"})
+
+	compactedWatchCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "compacted_watches_total",
+			Help:      "Counter of the watches whose required revisions are compacted, each of which requests a resync.",
+		}, []string{"watcher"})
+
+	subscriberResyncCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "subscriber_dropped_events_total",
+			Help:      "Counter of the events dropped for the slow in-process subscribers, which are recovered by a resync.",
+		})
+
+	linearizableReadCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "linearizable_reads_total",
+			Help:      "Counter of the linearizable reads bypassing the rule storage.",
+		})
+
+	stalenessGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "staleness_seconds",
+			Help:      "The time (s) since the slowest watch of the rule storage made progress.",
+		})
+)
+
+func init() {
+	prometheus.MustRegister(watchEventCounter)
+	prometheus.MustRegister(resyncCounter)
+	prometheus.MustRegister(lastResyncGauge)
+	prometheus.MustRegister(appliedRevisionGauge)
+	prometheus.MustRegister(lagRevisionsGauge)
+	prometheus.MustRegister(staleEventCounter)
+	prometheus.MustRegister(revisionGapCounter)
+	prometheus.MustRegister(eventQueueDepthGauge)
+	prometheus.MustRegister(droppedEventCounter)
+	prometheus.MustRegister(watchErrorCounter)
+	prometheus.MustRegister(compactedWatchCounter)
+	prometheus.MustRegister(subscriberResyncCounter)
+	prometheus.MustRegister(linearizableReadCounter)
+	prometheus.MustRegister(stalenessGauge)
+}
@@ -0,0 +1,146 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ruletest provides the utilities for testing the rule watcher of the
+// scheduling service. It's only imported by the tests.
+package ruletest
+
+import (
+	"context"
+	"sync"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/mcs/scheduling/server/rule"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"github.com/tikv/pd/pkg/utils/testutil"
+	"go.etcd.io/etcd/clientv3"
+)
+
+// AppliedEvent is an event applied by the watcher without the revision and
+// value, which are not known in advance by the tests.
+type AppliedEvent struct {
+	Type rule.EventType
+	Kind string
+	Key  string
+}
+
+// RuleEvent returns the applied event of a placement rule.
+func RuleEvent(typ rule.EventType, groupID, id string) AppliedEvent {
+	return AppliedEvent{Type: typ, Kind: "rule", Key: (&placement.Rule{GroupID: groupID, ID: id}).StoreKey()}
+}
+
+// RuleGroupEvent returns the applied event of a rule group.
+func RuleGroupEvent(typ rule.EventType, id string) AppliedEvent {
+	return AppliedEvent{Type: typ, Kind: "rule_group", Key: id}
+}
+
+// RegionLabelEvent returns the applied event of a region label rule.
+func RegionLabelEvent(typ rule.EventType, id string) AppliedEvent {
+	return AppliedEvent{Type: typ, Kind: "region_label", Key: id}
+}
+
+// TestWatcher is a rule watcher recording the events it applies, so the tests
+// can check the exact sequence of the changes rather than the final state.
+type TestWatcher struct {
+	*rule.Watcher
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     syncutil.Mutex
+	events []*rule.Event
+	// err is the error stopping the recording, e.g., the stream is reset.
+	err error
+}
+
+// NewTestWatcher creates a rule watcher recording the events applied after
+// it's created. The events of the existing items loaded by the watcher are not
+// recorded.
+func NewTestWatcher(ctx context.Context, etcdClient *clientv3.Client, clusterID uint64) (*TestWatcher, error) {
+	// the revision is fetched before creating the watcher, so the changes made
+	// after NewTestWatcher returns are always newer than it.
+	resp, err := etcdClient.Get(ctx, endpoint.RulesPathPrefix(clusterID), clientv3.WithCountOnly())
+	if err != nil {
+		return nil, err
+	}
+	startRevision := resp.Header.Revision
+	watcher, err := rule.NewWatcher(ctx, etcdClient, clusterID, 0)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	w := &TestWatcher{Watcher: watcher, cancel: cancel}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		// the events in the history are replayed, so the ones applied before
+		// subscribing are not missed.
+		err := watcher.WatchEvents(ctx, startRevision+1, 0, w.record)
+		if err != nil && ctx.Err() == nil {
+			w.mu.Lock()
+			w.err = err
+			w.mu.Unlock()
+		}
+	}()
+	return w, nil
+}
+
+func (w *TestWatcher) record(e *rule.Event) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = append(w.events, e)
+	return nil
+}
+
+// Events returns the events recorded so far in the applied order.
+func (w *TestWatcher) Events() []*rule.Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]*rule.Event(nil), w.events...)
+}
+
+// Reset drops the recorded events, so the following changes can be checked
+// separately.
+func (w *TestWatcher) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.events = nil
+}
+
+// WaitForEvents waits until the expected number of events are recorded and
+// checks they are exactly the expected ones in the same order.
+func (w *TestWatcher) WaitForEvents(re *require.Assertions, expected ...AppliedEvent) {
+	testutil.Eventually(re, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return len(w.events) >= len(expected) || w.err != nil
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	re.NoError(w.err)
+	applied := make([]AppliedEvent, 0, len(w.events))
+	for _, e := range w.events {
+		applied = append(applied, AppliedEvent{Type: e.Type, Kind: e.Kind, Key: e.Key})
+	}
+	re.Equal(expected, applied)
+}
+
+// Close stops recording and closes the watcher.
+func (w *TestWatcher) Close() {
+	w.cancel()
+	w.wg.Wait()
+	w.Watcher.Close()
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"time"
+
+	"github.com/tikv/pd/pkg/utils/logutil"
+)
+
+// stalenessUpdateInterval is the interval to update the staleness metric.
+var stalenessUpdateInterval = time.Second
+
+// SetMaxStaleness sets the max staleness of the rule storage, the storage is
+// not fresh once any watch makes no progress within it. 0 means unbounded.
+func (rw *Watcher) SetMaxStaleness(d time.Duration) {
+	rw.statusMu.Lock()
+	defer rw.statusMu.Unlock()
+	rw.maxStaleness = d
+}
+
+// progressHandler returns the function marking the progress of the watch. The
+// progress is queued, so it's marked after the events before it are applied.
+func (rw *Watcher) progressHandler(watch string) func(int64) {
+	return func(int64) {
+		rw.enqueue(func() { rw.markProgress(watch) })
+	}
+}
+
+// markProgress marks that the watches have caught up with etcd now.
+func (rw *Watcher) markProgress(watches ...string) {
+	now := time.Now()
+	rw.statusMu.Lock()
+	defer rw.statusMu.Unlock()
+	for _, watch := range watches {
+		rw.progress[watch] = now
+	}
+}
+
+// Staleness returns how long the rule storage may be behind etcd, i.e., the
+// time since the last progress of the slowest watch. It returns 0 before all
+// the watches make progress, since they are loaded before the watcher is
+// created.
+func (rw *Watcher) Staleness() time.Duration {
+	rw.statusMu.RLock()
+	defer rw.statusMu.RUnlock()
+	return rw.stalenessLocked()
+}
+
+func (rw *Watcher) stalenessLocked() time.Duration {
+	var oldest time.Time
+	for _, t := range rw.progress {
+		if oldest.IsZero() || t.Before(oldest) {
+			oldest = t
+		}
+	}
+	if oldest.IsZero() {
+		return 0
+	}
+	return time.Since(oldest)
+}
+
+// IsFresh returns whether the rule storage has made progress within the max
+// staleness. The placement decisions should not be made on the stale rules.
+func (rw *Watcher) IsFresh() bool {
+	rw.statusMu.RLock()
+	defer rw.statusMu.RUnlock()
+	return rw.maxStaleness <= 0 || rw.stalenessLocked() <= rw.maxStaleness
+}
+
+func (rw *Watcher) stalenessLoop() {
+	defer logutil.LogPanic()
+	defer rw.wg.Done()
+	ticker := time.NewTicker(stalenessUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rw.ctx.Done():
+			return
+		case <-ticker.C:
+			stalenessGauge.Set(rw.Staleness().Seconds())
+		}
+	}
+}
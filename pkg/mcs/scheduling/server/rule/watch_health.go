@@ -0,0 +1,87 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/utils/etcdutil"
+	"go.uber.org/zap"
+)
+
+// WatchError is an error met by watching the rules in etcd.
+type WatchError struct {
+	// Watch is the name of the watch meeting the error.
+	Watch string
+	Err   error
+	Time  time.Time
+	// Retryable is false if the watch can't recover from the error by retrying,
+	// such as the permission is denied.
+	Retryable bool
+}
+
+func (e *WatchError) Error() string {
+	return fmt.Sprintf("%s met error at %s (retryable: %t): %v", e.Watch, e.Time.Format(time.RFC3339), e.Retryable, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *WatchError) Unwrap() error {
+	return e.Err
+}
+
+func (rw *Watcher) watchStatusHandler(watch string) func(error) {
+	return func(err error) {
+		rw.healthMu.Lock()
+		defer rw.healthMu.Unlock()
+		if err == nil {
+			if _, ok := rw.brokenWatches[watch]; ok {
+				log.Info("the rule watch is recovered", zap.String("watch", watch))
+				delete(rw.brokenWatches, watch)
+			}
+			return
+		}
+		e := &WatchError{Watch: watch, Err: err, Time: time.Now(), Retryable: etcdutil.IsRetryableWatchError(err)}
+		watchErrorCounter.WithLabelValues(watch, strconv.FormatBool(e.Retryable)).Inc()
+		if !e.Retryable {
+			log.Error("the rule watch met a fatal error", zap.String("watch", watch), zap.Error(err))
+		}
+		rw.lastErr = e
+		rw.brokenWatches[watch] = e
+	}
+}
+
+// Err returns the last error met by watching etcd as a *WatchError, even if
+// the watch has recovered from it. It returns nil if there is no error.
+func (rw *Watcher) Err() error {
+	rw.healthMu.RLock()
+	defer rw.healthMu.RUnlock()
+	if rw.lastErr == nil {
+		return nil
+	}
+	return rw.lastErr
+}
+
+// Healthy returns whether all the watches are working, i.e., none of them has
+// met an error without receiving any response from etcd since. The rule
+// storage may be stale if it's not healthy, the caller could resync it by
+// ForceResync or restart the watcher.
+func (rw *Watcher) Healthy() bool {
+	rw.healthMu.RLock()
+	defer rw.healthMu.RUnlock()
+	return len(rw.brokenWatches) == 0
+}
@@ -16,28 +16,75 @@ package rule
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pingcap/errors"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/schedule/placement"
 	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"github.com/tikv/pd/pkg/utils/etcdutil"
+	"github.com/tikv/pd/pkg/utils/logutil"
+	"github.com/tikv/pd/pkg/utils/syncutil"
 	"go.etcd.io/etcd/clientv3"
 	"go.etcd.io/etcd/mvcc/mvccpb"
+	"go.uber.org/zap"
 )
 
+const (
+	ruleType        = "rule"
+	ruleGroupType   = "rule_group"
+	regionLabelType = "region_label"
+
+	putEvent    = "put"
+	deleteEvent = "delete"
+
+	ruleWatchName        = "scheduling-rule-watcher"
+	ruleGroupWatchName   = "scheduling-rule-group-watcher"
+	regionLabelWatchName = "scheduling-region-label-watcher"
+
+	// DefaultMaxInFlightEvents is the default max number of the watch events
+	// queued to be applied.
+	DefaultMaxInFlightEvents = 4096
+)
+
+// queueSaturationTimeout is how long an event waits for a full queue before
+// it's dropped, the dropped events are recovered by a resync. It's a variable
+// for testing.
+var queueSaturationTimeout = 3 * time.Second
+
 // ruleStorage is an in-memory storage for Placement Rules,
 // which will implement the `endpoint.RuleStorage` interface.
 type ruleStorage struct {
+	// mu is held exclusively when replacing all the contents, so the readers
+	// never see a partially replaced storage.
+	mu syncutil.RWMutex
 	// Rule key -> rule value.
 	rules sync.Map
 	// GroupID -> rule group value.
 	groups sync.Map
 	// Region rule key -> rule value.
 	regionRules sync.Map
+	// loadFromEtcd loads the latest key-values under the prefix from etcd with
+	// the prefix trimmed, it's used by the linearizable reads.
+	loadFromEtcd func(prefix string) (map[string]string, error)
+	// rulesPathPrefix and ruleGroupPathPrefix are the prefixes of the rules
+	// and rule groups in etcd, see Watcher.
+	rulesPathPrefix     string
+	ruleGroupPathPrefix string
+	// isFresh reports whether the storage is fresh enough, see Watcher.IsFresh.
+	isFresh func() bool
 }
 
 // LoadRules loads Placement Rules from storage.
 func (rs *ruleStorage) LoadRules(f func(k, v string)) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.rules.Range(func(k, v interface{}) bool {
 		f(k.(string), v.(string))
 		return true
@@ -45,20 +92,81 @@ func (rs *ruleStorage) LoadRules(f func(k, v string)) error {
 	return nil
 }
 
+// LoadRulesWithConsistency loads Placement Rules from the in-memory storage,
+// or from etcd if the read is linearizable.
+func (rs *ruleStorage) LoadRulesWithConsistency(consistency endpoint.ReadConsistency, f func(k, v string)) error {
+	if consistency == endpoint.LinearizableRead {
+		return rs.loadLinearizable(rs.rulesPathPrefix, f)
+	}
+	return rs.LoadRules(f)
+}
+
+// LoadRuleGroupsWithConsistency loads all rule groups from the in-memory
+// storage, or from etcd if the read is linearizable.
+func (rs *ruleStorage) LoadRuleGroupsWithConsistency(consistency endpoint.ReadConsistency, f func(k, v string)) error {
+	if consistency == endpoint.LinearizableRead {
+		return rs.loadLinearizable(rs.ruleGroupPathPrefix, f)
+	}
+	return rs.LoadRuleGroups(f)
+}
+
+// loadLinearizable bypasses the in-memory storage and reads etcd, the etcd
+// reads are linearizable by default.
+func (rs *ruleStorage) loadLinearizable(prefix string, f func(k, v string)) error {
+	if rs.loadFromEtcd == nil {
+		return errors.New("linearizable read is not supported without etcd")
+	}
+	kvs, err := rs.loadFromEtcd(prefix)
+	if err != nil {
+		return err
+	}
+	linearizableReadCounter.Inc()
+	for k, v := range kvs {
+		f(k, v)
+	}
+	return nil
+}
+
+// IsFresh returns whether the in-memory storage has kept up with etcd within
+// the max staleness of the watcher, see Watcher.IsFresh.
+func (rs *ruleStorage) IsFresh() bool {
+	return rs.isFresh == nil || rs.isFresh()
+}
+
+// LoadRulesSorted loads Placement Rules from storage in the apply order.
+func (rs *ruleStorage) LoadRulesSorted(f func(k, v string)) error {
+	return endpoint.SortedRuleLoader(rs.LoadRules, rs.LoadRuleGroups)(f)
+}
+
+// LoadRulesByPrefix loads the Placement Rules with the key prefix from storage.
+func (rs *ruleStorage) LoadRulesByPrefix(keyPrefix string, f func(k, v string)) error {
+	return rs.LoadRules(func(k, v string) {
+		if strings.HasPrefix(k, keyPrefix) {
+			f(k, v)
+		}
+	})
+}
+
 // SaveRule stores a rule cfg to the rulesPathPrefix.
-func (rs *ruleStorage) SaveRule(ruleKey string, rule interface{}) error {
+func (rs *ruleStorage) SaveRule(_ kv.Txn, ruleKey string, rule interface{}) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.rules.Store(ruleKey, rule)
 	return nil
 }
 
 // DeleteRule removes a rule from storage.
-func (rs *ruleStorage) DeleteRule(ruleKey string) error {
+func (rs *ruleStorage) DeleteRule(_ kv.Txn, ruleKey string) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.rules.Delete(ruleKey)
 	return nil
 }
 
 // LoadRuleGroups loads all rule groups from storage.
 func (rs *ruleStorage) LoadRuleGroups(f func(k, v string)) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.groups.Range(func(k, v interface{}) bool {
 		f(k.(string), v.(string))
 		return true
@@ -67,19 +175,114 @@ func (rs *ruleStorage) LoadRuleGroups(f func(k, v string)) error {
 }
 
 // SaveRuleGroup stores a rule group config to storage.
-func (rs *ruleStorage) SaveRuleGroup(groupID string, group interface{}) error {
+func (rs *ruleStorage) SaveRuleGroup(_ kv.Txn, groupID string, group interface{}) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.groups.Store(groupID, group)
 	return nil
 }
 
 // DeleteRuleGroup removes a rule group from storage.
-func (rs *ruleStorage) DeleteRuleGroup(groupID string) error {
+func (rs *ruleStorage) DeleteRuleGroup(_ kv.Txn, groupID string) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.groups.Delete(groupID)
 	return nil
 }
 
+// LoadRuleTemplates loads nothing, since the rule templates are only used by
+// the PD API server to derive the rules, which are synced by the watchers.
+func (*ruleStorage) LoadRuleTemplates(func(k, v string)) error {
+	return nil
+}
+
+// SaveRuleTemplate is not supported, the rule templates are managed by the PD API server.
+func (*ruleStorage) SaveRuleTemplate(kv.Txn, string, interface{}) error {
+	return errors.New("rule template is not supported by the scheduling service")
+}
+
+// DeleteRuleTemplate is not supported, the rule templates are managed by the PD API server.
+func (*ruleStorage) DeleteRuleTemplate(kv.Txn, string) error {
+	return errors.New("rule template is not supported by the scheduling service")
+}
+
+// LoadRuleTombstones loads nothing, since the deleted rules are removed from the
+// rules path, and the scheduling service never sees the tombstoned rules.
+func (*ruleStorage) LoadRuleTombstones(func(k, v string)) error {
+	return nil
+}
+
+// SaveRuleTombstone is not supported, the rule tombstones are managed by the PD API server.
+func (*ruleStorage) SaveRuleTombstone(kv.Txn, string, interface{}) error {
+	return errors.New("rule tombstone is not supported by the scheduling service")
+}
+
+// DeleteRuleTombstone is not supported, the rule tombstones are managed by the PD API server.
+func (*ruleStorage) DeleteRuleTombstone(kv.Txn, string) error {
+	return errors.New("rule tombstone is not supported by the scheduling service")
+}
+
+// LoadRuleSets loads nothing, since the rule sets are activated by the PD API
+// server, and the scheduling service only sees the active rules.
+func (*ruleStorage) LoadRuleSets(func(k, v string)) error {
+	return nil
+}
+
+// SaveRuleSet is not supported, the rule sets are managed by the PD API server.
+func (*ruleStorage) SaveRuleSet(kv.Txn, string, interface{}) error {
+	return errors.New("rule set is not supported by the scheduling service")
+}
+
+// DeleteRuleSet is not supported, the rule sets are managed by the PD API server.
+func (*ruleStorage) DeleteRuleSet(kv.Txn, string) error {
+	return errors.New("rule set is not supported by the scheduling service")
+}
+
+// LoadRuleSetState loads nothing, the rule sets are managed by the PD API server.
+func (*ruleStorage) LoadRuleSetState() (string, error) {
+	return "", nil
+}
+
+// SaveRuleSetState is not supported, the rule sets are managed by the PD API server.
+func (*ruleStorage) SaveRuleSetState(kv.Txn, interface{}) error {
+	return errors.New("rule set is not supported by the scheduling service")
+}
+
+// LoadRuleHistory loads nothing, the rule history is kept by the PD API server.
+func (*ruleStorage) LoadRuleHistory(func(k, v string)) error {
+	return nil
+}
+
+// SaveRuleHistory is not supported, the rule history is kept by the PD API server.
+func (*ruleStorage) SaveRuleHistory(kv.Txn, uint64, interface{}) error {
+	return errors.New("rule history is not supported by the scheduling service")
+}
+
+// DeleteRuleHistory is not supported, the rule history is kept by the PD API server.
+func (*ruleStorage) DeleteRuleHistory(kv.Txn, uint64) error {
+	return errors.New("rule history is not supported by the scheduling service")
+}
+
+// LoadRuleRevision loads nothing, the rule revisions are stamped by the PD API server.
+func (*ruleStorage) LoadRuleRevision() (string, error) {
+	return "", nil
+}
+
+// SaveRuleRevision does nothing, the rule revisions are stamped by the PD API server.
+func (*ruleStorage) SaveRuleRevision(kv.Txn, uint64) error {
+	return nil
+}
+
+// RunInTxn runs the given function directly, since the in-memory storage is
+// updated by the watchers only and the transaction is not needed.
+func (*ruleStorage) RunInTxn(_ context.Context, f func(txn kv.Txn) error) error {
+	return f(nil)
+}
+
 // LoadRegionRules loads region rules from storage.
 func (rs *ruleStorage) LoadRegionRules(f func(k, v string)) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.regionRules.Range(func(k, v interface{}) bool {
 		f(k.(string), v.(string))
 		return true
@@ -87,18 +290,102 @@ func (rs *ruleStorage) LoadRegionRules(f func(k, v string)) error {
 	return nil
 }
 
+// LoadRegionRulesPage loads a page of region rules from storage.
+func (rs *ruleStorage) LoadRegionRulesPage(limit int, startAfter string) ([]string, string, error) {
+	if limit <= 0 {
+		limit = endpoint.MinKVRangeLimit
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	kvs := make(map[string]string)
+	rs.regionRules.Range(func(k, v interface{}) bool {
+		if key := k.(string); key > startAfter {
+			kvs[key] = v.(string)
+		}
+		return true
+	})
+	keys := make([]string, 0, len(kvs))
+	for k := range kvs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var next string
+	if len(keys) >= limit {
+		keys = keys[:limit]
+		next = keys[len(keys)-1]
+	}
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, kvs[k])
+	}
+	return values, next, nil
+}
+
 // SaveRegionRule saves a region rule to the storage.
-func (rs *ruleStorage) SaveRegionRule(ruleKey string, rule interface{}) error {
+func (rs *ruleStorage) SaveRegionRule(_ kv.Txn, ruleKey string, rule interface{}) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.regionRules.Store(ruleKey, rule)
 	return nil
 }
 
 // DeleteRegionRule removes a region rule from storage.
-func (rs *ruleStorage) DeleteRegionRule(ruleKey string) error {
+func (rs *ruleStorage) DeleteRegionRule(_ kv.Txn, ruleKey string) error {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
 	rs.regionRules.Delete(ruleKey)
 	return nil
 }
 
+// SaveSnapshot is not supported, the snapshots are taken by the PD API server.
+func (*ruleStorage) SaveSnapshot(string) error {
+	return errors.New("rule snapshot is not supported by the scheduling service")
+}
+
+// RestoreSnapshot is not supported, the restored rules are synced from the PD
+// API server by the watchers.
+func (*ruleStorage) RestoreSnapshot(string) error {
+	return errors.New("rule snapshot is not supported by the scheduling service")
+}
+
+// ListSnapshots is not supported, the snapshots are taken by the PD API server.
+func (*ruleStorage) ListSnapshots() ([]string, error) {
+	return nil, errors.New("rule snapshot is not supported by the scheduling service")
+}
+
+// Compact is not supported, the etcd is compacted by the PD API server.
+func (*ruleStorage) Compact(int) error {
+	return errors.New("rule storage compaction is not supported by the scheduling service")
+}
+
+// replace replaces all the contents of the storage with the given ones, and
+// returns how the replaced contents differ from them.
+func (rs *ruleStorage) replace(rules, groups, regionRules map[string]string) (ruleDrift, groupDrift, regionRuleDrift Drift) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return resetSyncMap(&rs.rules, rules), resetSyncMap(&rs.groups, groups), resetSyncMap(&rs.regionRules, regionRules)
+}
+
+func resetSyncMap(m *sync.Map, kvs map[string]string) Drift {
+	drift := Drift{Count: len(kvs), Added: len(kvs)}
+	m.Range(func(k, v interface{}) bool {
+		if nv, ok := kvs[k.(string)]; !ok {
+			drift.Deleted++
+		} else {
+			drift.Added--
+			if nv != v.(string) {
+				drift.Updated++
+			}
+		}
+		m.Delete(k)
+		return true
+	})
+	for k, v := range kvs {
+		m.Store(k, v)
+	}
+	return drift
+}
+
 // Watcher is used to watch the PD API server for any Placement Rule changes.
 type Watcher struct {
 	ctx    context.Context
@@ -121,6 +408,58 @@ type Watcher struct {
 	etcdClient *clientv3.Client
 	ruleStore  *ruleStorage
 
+	// eventMu serializes applying the watch events and the forced resync.
+	eventMu syncutil.Mutex
+	// ruleVersions is the version vector of the rules, which records the max
+	// revision of placement.Rule applied for each existing rule key, so the
+	// stale updates delivered out of order are dropped. The entry is removed
+	// once the rule is deleted, the re-added rule starts over.
+	// It's protected by eventMu, and so are the fields below.
+	ruleVersions map[string]uint64
+	// maxRuleRevision is the max rule revision received, and pendingRevisions
+	// are the ones received in the current batch of events. The revisions are
+	// increased one by one by the PD API server, so a gap between them means
+	// some updates are missing.
+	maxRuleRevision  uint64
+	pendingRevisions []uint64
+	// ruleLoaded is set once the rules are loaded, the gaps are not checked
+	// during the initial load, since the overwritten revisions never come.
+	ruleLoaded bool
+	// resyncCh is used to request a forced resync once a gap is found.
+	resyncCh chan struct{}
+	// events broadcasts the applied events to the subscribers of WatchEvents.
+	events *eventHub
+	// resyncRevision is the etcd revision of the last resync, the queued events
+	// not newer than it are skipped since they're covered by the resync.
+	resyncRevision int64
+
+	// queue bounds the watch events in flight between the etcd watchers and
+	// the applier, so a burst of events is flow-controlled rather than being
+	// buffered without limit.
+	queue chan func()
+	// saturated is set once an event is dropped since the queue stays full,
+	// the events are dropped until the queue drains to a half, and the rule
+	// storage is resynced from etcd afterwards.
+	saturated atomic.Bool
+
+	// statusMu protects the fields below, which are used to report the lag.
+	statusMu syncutil.RWMutex
+	// appliedRevision is the max etcd revision applied to the rule storage.
+	appliedRevision int64
+	lastEventTime   time.Time
+	// progress records when the watches made progress last time by the names
+	// of them, and maxStaleness bounds the staleness, see IsFresh.
+	progress     map[string]time.Time
+	maxStaleness time.Duration
+
+	// healthMu protects the fields below, which are used to report the errors
+	// met by watching etcd.
+	healthMu syncutil.RWMutex
+	lastErr  *WatchError
+	// brokenWatches are the watches which have met an error and not received
+	// any response from etcd since, keyed by the names of them.
+	brokenWatches map[string]*WatchError
+
 	ruleWatcher  *etcdutil.LoopWatcher
 	groupWatcher *etcdutil.LoopWatcher
 	labelWatcher *etcdutil.LoopWatcher
@@ -128,11 +467,17 @@ type Watcher struct {
 
 // NewWatcher creates a new watcher to watch the Placement Rule change from PD API server.
 // Please use `GetRuleStorage` to get the underlying storage to access the Placement Rules.
+// At most maxInFlightEvents watch events are queued to be applied, and the default value
+// is used if it's not positive.
 func NewWatcher(
 	ctx context.Context,
 	etcdClient *clientv3.Client,
 	clusterID uint64,
+	maxInFlightEvents int,
 ) (*Watcher, error) {
+	if maxInFlightEvents <= 0 {
+		maxInFlightEvents = DefaultMaxInFlightEvents
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	rw := &Watcher{
 		ctx:                   ctx,
@@ -141,8 +486,26 @@ func NewWatcher(
 		ruleGroupPathPrefix:   endpoint.RuleGroupPathPrefix(clusterID),
 		regionLabelPathPrefix: endpoint.RegionLabelPathPrefix(clusterID),
 		etcdClient:            etcdClient,
-		ruleStore:             &ruleStorage{},
+		ruleStore: &ruleStorage{
+			rulesPathPrefix:     endpoint.RulesPathPrefix(clusterID),
+			ruleGroupPathPrefix: endpoint.RuleGroupPathPrefix(clusterID),
+		},
+		ruleVersions:  make(map[string]uint64),
+		resyncCh:      make(chan struct{}, 1),
+		events:        newEventHub(),
+		brokenWatches: make(map[string]*WatchError),
+		queue:         make(chan func(), maxInFlightEvents),
+		progress:      make(map[string]time.Time),
 	}
+	rw.ruleStore.isFresh = rw.IsFresh
+	rw.ruleStore.loadFromEtcd = func(prefix string) (map[string]string, error) {
+		ctx, cancel := context.WithTimeout(rw.ctx, etcdutil.DefaultRequestTimeout)
+		defer cancel()
+		kvs, _, err := rw.list(ctx, prefix, 0)
+		return kvs, err
+	}
+	rw.wg.Add(1)
+	go rw.applyLoop()
 	err := rw.initializeRuleWatcher()
 	if err != nil {
 		return nil, err
@@ -155,46 +518,161 @@ func NewWatcher(
 	if err != nil {
 		return nil, err
 	}
+	rw.wg.Add(2)
+	go rw.resyncLoop()
+	go rw.stalenessLoop()
 	return rw, nil
 }
 
 func (rw *Watcher) initializeRuleWatcher() error {
-	prefixToTrim := rw.rulesPathPrefix + "/"
-	putFn := func(kv *mvccpb.KeyValue) error {
-		// Since the PD API server will validate the rule before saving it to etcd,
-		// so we could directly save the string rule in JSON to the storage here.
-		return rw.ruleStore.SaveRule(
-			strings.TrimPrefix(string(kv.Key), prefixToTrim),
-			string(kv.Value),
-		)
-	}
-	deleteFn := func(kv *mvccpb.KeyValue) error {
-		return rw.ruleStore.DeleteRule(strings.TrimPrefix(string(kv.Key), prefixToTrim))
-	}
 	postEventFn := func() error {
+		rw.checkRuleRevisionGaps()
 		return nil
 	}
 	rw.ruleWatcher = etcdutil.NewLoopWatcher(
 		rw.ctx, &rw.wg,
 		rw.etcdClient,
-		"scheduling-rule-watcher", rw.rulesPathPrefix,
-		putFn, deleteFn, postEventFn,
+		ruleWatchName, rw.rulesPathPrefix,
+		rw.queued(rw.putRule), rw.queued(rw.deleteRule), rw.queuedPostEvent(postEventFn),
 		clientv3.WithPrefix(),
 	)
+	rw.ruleWatcher.SetWatchStatusHandler(rw.watchStatusHandler(ruleWatchName))
+	rw.ruleWatcher.SetCompactionHandler(rw.compactionHandler(ruleWatchName))
+	rw.ruleWatcher.SetProgressHandler(rw.progressHandler(ruleWatchName))
 	rw.ruleWatcher.StartWatchLoop()
-	return rw.ruleWatcher.WaitLoad()
+	if err := rw.ruleWatcher.WaitLoad(); err != nil {
+		return err
+	}
+	if err := rw.flush(); err != nil {
+		return err
+	}
+	rw.eventMu.Lock()
+	defer rw.eventMu.Unlock()
+	rw.ruleLoaded = true
+	return nil
+}
+
+func (rw *Watcher) putRule(kv *mvccpb.KeyValue) error {
+	key := strings.TrimPrefix(string(kv.Key), rw.rulesPathPrefix+"/")
+	return rw.applyEvent(ruleType, putEvent, kv, key, func() error {
+		if !rw.checkRuleRevision(key, kv.Value) {
+			return errStaleEvent
+		}
+		// Since the PD API server will validate the rule before saving it to etcd,
+		// so we could directly save the string rule in JSON to the storage here.
+		return rw.ruleStore.SaveRule(nil, key, string(kv.Value))
+	})
+}
+
+func (rw *Watcher) deleteRule(kv *mvccpb.KeyValue) error {
+	key := strings.TrimPrefix(string(kv.Key), rw.rulesPathPrefix+"/")
+	return rw.applyEvent(ruleType, deleteEvent, kv, key, func() error {
+		// the revisions are never reused by the PD API server, so the rule
+		// re-added later always has a newer revision than the deleted one.
+		delete(rw.ruleVersions, key)
+		return rw.ruleStore.DeleteRule(nil, key)
+	})
+}
+
+// checkRuleRevision checks the revision of the rule against the version vector,
+// it returns false if the rule is older than the one applied with the same key.
+// It must be called with eventMu held.
+func (rw *Watcher) checkRuleRevision(key string, value []byte) bool {
+	rule, err := placement.NewRuleFromJSON(value)
+	// the rules saved by the old versions of PD have no revision.
+	if err != nil || rule.Revision == 0 {
+		return true
+	}
+	if rule.Revision > rw.maxRuleRevision {
+		rw.pendingRevisions = append(rw.pendingRevisions, rule.Revision)
+	}
+	if applied := rw.ruleVersions[key]; rule.Revision < applied {
+		staleEventCounter.WithLabelValues(ruleType).Inc()
+		log.Warn("drop the stale rule update",
+			zap.String("rule-key", key),
+			zap.Uint64("revision", rule.Revision),
+			zap.Uint64("applied-revision", applied))
+		return false
+	}
+	rw.ruleVersions[key] = rule.Revision
+	return true
+}
+
+// checkRuleRevisionGaps checks whether the rule revisions received are continuous
+// after a batch of events, and requests a resync if some of them are missing.
+func (rw *Watcher) checkRuleRevisionGaps() {
+	rw.eventMu.Lock()
+	defer rw.eventMu.Unlock()
+	revisions := rw.pendingRevisions
+	rw.pendingRevisions = nil
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] < revisions[j] })
+	expected, gap := rw.maxRuleRevision+1, false
+	for _, revision := range revisions {
+		if revision < expected {
+			continue
+		}
+		if revision > expected {
+			gap = true
+			log.Warn("rule revisions are missing",
+				zap.Uint64("from", expected),
+				zap.Uint64("to", revision-1))
+		}
+		expected = revision + 1
+	}
+	rw.maxRuleRevision = expected - 1
+	if gap && rw.ruleLoaded {
+		revisionGapCounter.Inc()
+		rw.requestResync()
+	}
+}
+
+func (rw *Watcher) requestResync() {
+	select {
+	case rw.resyncCh <- struct{}{}:
+	default:
+	}
+}
+
+// compactionHandler returns the function requesting a resync once the required
+// revision of the watch is compacted, since the events before the compact
+// revision are lost, e.g., the deletions of the rules.
+func (rw *Watcher) compactionHandler(watch string) func(int64) {
+	return func(compactRevision int64) {
+		compactedWatchCounter.WithLabelValues(watch).Inc()
+		log.Warn("the required revision of the rule watch is compacted, resync the rule storage",
+			zap.String("watch", watch), zap.Int64("compact-revision", compactRevision))
+		rw.requestResync()
+	}
+}
+
+func (rw *Watcher) resyncLoop() {
+	defer logutil.LogPanic()
+	defer rw.wg.Done()
+	for {
+		select {
+		case <-rw.ctx.Done():
+			return
+		case <-rw.resyncCh:
+			if err := rw.ForceResync(rw.ctx); err != nil {
+				log.Error("failed to resync the rule storage", errs.ZapError(err))
+			}
+		}
+	}
 }
 
 func (rw *Watcher) initializeGroupWatcher() error {
 	prefixToTrim := rw.ruleGroupPathPrefix + "/"
 	putFn := func(kv *mvccpb.KeyValue) error {
-		return rw.ruleStore.SaveRuleGroup(
-			strings.TrimPrefix(string(kv.Key), prefixToTrim),
-			string(kv.Value),
-		)
+		key := strings.TrimPrefix(string(kv.Key), prefixToTrim)
+		return rw.applyEvent(ruleGroupType, putEvent, kv, key, func() error {
+			return rw.ruleStore.SaveRuleGroup(nil, key, string(kv.Value))
+		})
 	}
 	deleteFn := func(kv *mvccpb.KeyValue) error {
-		return rw.ruleStore.DeleteRuleGroup(strings.TrimPrefix(string(kv.Key), prefixToTrim))
+		key := strings.TrimPrefix(string(kv.Key), prefixToTrim)
+		return rw.applyEvent(ruleGroupType, deleteEvent, kv, key, func() error {
+			return rw.ruleStore.DeleteRuleGroup(nil, key)
+		})
 	}
 	postEventFn := func() error {
 		return nil
@@ -202,24 +680,33 @@ func (rw *Watcher) initializeGroupWatcher() error {
 	rw.groupWatcher = etcdutil.NewLoopWatcher(
 		rw.ctx, &rw.wg,
 		rw.etcdClient,
-		"scheduling-rule-group-watcher", rw.ruleGroupPathPrefix,
-		putFn, deleteFn, postEventFn,
+		ruleGroupWatchName, rw.ruleGroupPathPrefix,
+		rw.queued(putFn), rw.queued(deleteFn), postEventFn,
 		clientv3.WithPrefix(),
 	)
+	rw.groupWatcher.SetWatchStatusHandler(rw.watchStatusHandler(ruleGroupWatchName))
+	rw.groupWatcher.SetCompactionHandler(rw.compactionHandler(ruleGroupWatchName))
+	rw.groupWatcher.SetProgressHandler(rw.progressHandler(ruleGroupWatchName))
 	rw.groupWatcher.StartWatchLoop()
-	return rw.groupWatcher.WaitLoad()
+	if err := rw.groupWatcher.WaitLoad(); err != nil {
+		return err
+	}
+	return rw.flush()
 }
 
 func (rw *Watcher) initializeRegionLabelWatcher() error {
 	prefixToTrim := rw.regionLabelPathPrefix + "/"
 	putFn := func(kv *mvccpb.KeyValue) error {
-		return rw.ruleStore.SaveRegionRule(
-			strings.TrimPrefix(string(kv.Key), prefixToTrim),
-			string(kv.Value),
-		)
+		key := strings.TrimPrefix(string(kv.Key), prefixToTrim)
+		return rw.applyEvent(regionLabelType, putEvent, kv, key, func() error {
+			return rw.ruleStore.SaveRegionRule(nil, key, string(kv.Value))
+		})
 	}
 	deleteFn := func(kv *mvccpb.KeyValue) error {
-		return rw.ruleStore.DeleteRegionRule(strings.TrimPrefix(string(kv.Key), prefixToTrim))
+		key := strings.TrimPrefix(string(kv.Key), prefixToTrim)
+		return rw.applyEvent(regionLabelType, deleteEvent, kv, key, func() error {
+			return rw.ruleStore.DeleteRegionRule(nil, key)
+		})
 	}
 	postEventFn := func() error {
 		return nil
@@ -227,12 +714,299 @@ func (rw *Watcher) initializeRegionLabelWatcher() error {
 	rw.labelWatcher = etcdutil.NewLoopWatcher(
 		rw.ctx, &rw.wg,
 		rw.etcdClient,
-		"scheduling-region-label-watcher", rw.regionLabelPathPrefix,
-		putFn, deleteFn, postEventFn,
+		regionLabelWatchName, rw.regionLabelPathPrefix,
+		rw.queued(putFn), rw.queued(deleteFn), postEventFn,
 		clientv3.WithPrefix(),
 	)
+	rw.labelWatcher.SetWatchStatusHandler(rw.watchStatusHandler(regionLabelWatchName))
+	rw.labelWatcher.SetCompactionHandler(rw.compactionHandler(regionLabelWatchName))
+	rw.labelWatcher.SetProgressHandler(rw.progressHandler(regionLabelWatchName))
 	rw.labelWatcher.StartWatchLoop()
-	return rw.labelWatcher.WaitLoad()
+	if err := rw.labelWatcher.WaitLoad(); err != nil {
+		return err
+	}
+	return rw.flush()
+}
+
+// queued returns a watch event handler which queues the event to be applied
+// by fn, so the etcd watchers are throttled by the applier.
+func (rw *Watcher) queued(fn func(*mvccpb.KeyValue) error) func(*mvccpb.KeyValue) error {
+	return func(kv *mvccpb.KeyValue) error {
+		rw.enqueue(func() {
+			if err := fn(kv); err != nil {
+				log.Error("failed to apply the rule watch event", zap.ByteString("key", kv.Key), errs.ZapError(err))
+			}
+		})
+		return nil
+	}
+}
+
+// queuedPostEvent is the same as queued but for the post event function, so
+// it runs after the events of the batch are applied.
+func (rw *Watcher) queuedPostEvent(fn func() error) func() error {
+	return func() error {
+		rw.enqueue(func() {
+			if err := fn(); err != nil {
+				log.Error("failed to run the post event function of the rule watcher", errs.ZapError(err))
+			}
+		})
+		return nil
+	}
+}
+
+// enqueue queues the task, it blocks while the queue is full. If the queue
+// stays full for queueSaturationTimeout, the task is dropped and a resync is
+// requested, and the following tasks are dropped until the queue drains to a
+// half, which are recovered by the resync as well.
+func (rw *Watcher) enqueue(task func()) {
+	defer func() { eventQueueDepthGauge.Set(float64(len(rw.queue))) }()
+	if rw.saturated.Load() {
+		if len(rw.queue) > cap(rw.queue)/2 {
+			rw.dropEvent()
+			return
+		}
+		rw.saturated.Store(false)
+	}
+	select {
+	case rw.queue <- task:
+		return
+	default:
+	}
+	timer := time.NewTimer(queueSaturationTimeout)
+	defer timer.Stop()
+	select {
+	case rw.queue <- task:
+	case <-timer.C:
+		log.Warn("the rule watch event queue is saturated, drop the events and resync the rule storage",
+			zap.Int("max-in-flight-events", cap(rw.queue)))
+		rw.saturated.Store(true)
+		rw.dropEvent()
+	case <-rw.ctx.Done():
+	}
+}
+
+func (rw *Watcher) dropEvent() {
+	droppedEventCounter.Inc()
+	// the resync requested during a resync runs again after it, so the resync
+	// always happens after the last dropped event.
+	rw.requestResync()
+}
+
+// flush waits until the tasks queued so far are applied.
+func (rw *Watcher) flush() error {
+	done := make(chan struct{})
+	select {
+	case rw.queue <- func() { close(done) }:
+	case <-rw.ctx.Done():
+		return rw.ctx.Err()
+	}
+	select {
+	case <-done:
+		return nil
+	case <-rw.ctx.Done():
+		return rw.ctx.Err()
+	}
+}
+
+func (rw *Watcher) applyLoop() {
+	defer logutil.LogPanic()
+	defer rw.wg.Done()
+	for {
+		select {
+		case <-rw.ctx.Done():
+			return
+		case task := <-rw.queue:
+			task()
+			eventQueueDepthGauge.Set(float64(len(rw.queue)))
+		}
+	}
+}
+
+// errStaleEvent is returned by the apply function of applyEvent if the event
+// is dropped, so it's neither counted nor published.
+var errStaleEvent = errors.New("stale event")
+
+func (rw *Watcher) applyEvent(typ, event string, kv *mvccpb.KeyValue, key string, f func() error) error {
+	rw.eventMu.Lock()
+	defer rw.eventMu.Unlock()
+	if kv.ModRevision > 0 && kv.ModRevision <= rw.resyncRevision {
+		// the event is queued before the last resync which has covered it.
+		return nil
+	}
+	if err := f(); err != nil {
+		if err == errStaleEvent {
+			return nil
+		}
+		return err
+	}
+	watchEventCounter.WithLabelValues(typ, event).Inc()
+	rw.updateAppliedRevision(kv.ModRevision)
+	rw.events.publish(newEvent(typ, event == deleteEvent, kv, key))
+	return nil
+}
+
+func (rw *Watcher) updateAppliedRevision(revision int64) {
+	rw.statusMu.Lock()
+	defer rw.statusMu.Unlock()
+	if revision > rw.appliedRevision {
+		rw.appliedRevision = revision
+		appliedRevisionGauge.Set(float64(revision))
+	}
+	rw.lastEventTime = time.Now()
+}
+
+// WatchStatus is the status of the watcher.
+type WatchStatus struct {
+	AppliedRevision int64     `json:"applied_revision"`
+	LatestRevision  int64     `json:"latest_revision"`
+	Lag             int64     `json:"lag"`
+	LastEventTime   time.Time `json:"last_event_time"`
+}
+
+// GetStatus returns how far the rule storage is behind etcd. The latest
+// revision is the max revision of the rules, rule groups and region label
+// rules stored in etcd, so the unrelated updates are not counted in the lag.
+// NOTE: the deletions which are not applied yet are not counted either, since
+// the revisions of the deleted keys are unknown before the events arrive.
+func (rw *Watcher) GetStatus(ctx context.Context) (*WatchStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, etcdutil.DefaultRequestTimeout)
+	defer cancel()
+	var latest int64
+	opts := append([]clientv3.OpOption{clientv3.WithPrefix()}, clientv3.WithLastRev()...)
+	for _, prefix := range []string{rw.rulesPathPrefix, rw.ruleGroupPathPrefix, rw.regionLabelPathPrefix} {
+		resp, err := clientv3.NewKV(rw.etcdClient).Get(ctx, prefix+"/", opts...)
+		if err != nil {
+			return nil, errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
+		}
+		if len(resp.Kvs) > 0 && resp.Kvs[0].ModRevision > latest {
+			latest = resp.Kvs[0].ModRevision
+		}
+	}
+	rw.statusMu.RLock()
+	status := &WatchStatus{
+		AppliedRevision: rw.appliedRevision,
+		LatestRevision:  latest,
+		LastEventTime:   rw.lastEventTime,
+	}
+	rw.statusMu.RUnlock()
+	if latest > status.AppliedRevision {
+		status.Lag = latest - status.AppliedRevision
+	}
+	lagRevisionsGauge.Set(float64(status.Lag))
+	return status, nil
+}
+
+// LagRevisions returns the number of revisions the rule storage is behind
+// etcd, or -1 if the latest revision can not be fetched.
+func (rw *Watcher) LagRevisions() int64 {
+	status, err := rw.GetStatus(rw.ctx)
+	if err != nil {
+		log.Warn("failed to get the status of the rule watcher", errs.ZapError(err))
+		return -1
+	}
+	return status.Lag
+}
+
+// Drift is how the contents of a kind in the rule storage differ from etcd,
+// which are reconciled by a reload.
+type Drift struct {
+	// Count is the number of the contents in etcd.
+	Count int `json:"count"`
+	// Added, Updated and Deleted are the numbers of the contents missing,
+	// outdated and redundant in the rule storage before the reload.
+	Added   int `json:"added"`
+	Updated int `json:"updated"`
+	Deleted int `json:"deleted"`
+}
+
+// ReloadResult is the result of ForceReload.
+type ReloadResult struct {
+	// Revision is the etcd revision the rule storage is reloaded at.
+	Revision    int64 `json:"revision"`
+	Rules       Drift `json:"rules"`
+	RuleGroups  Drift `json:"rule_groups"`
+	RegionRules Drift `json:"region_rules"`
+}
+
+// ForceResync re-lists all the rules, rule groups and region label rules from etcd
+// and replaces the contents of the rule storage with them atomically. It's used to
+// recover the rule storage once it drifts from etcd, e.g., the watch events are lost
+// after the required revision is compacted.
+func (rw *Watcher) ForceResync(ctx context.Context) error {
+	_, err := rw.ForceReload(ctx)
+	return err
+}
+
+// ForceReload is the same as ForceResync, and reports the drift of the rule storage
+// from etcd reconciled by it, so the silent drift can be found out.
+func (rw *Watcher) ForceReload(ctx context.Context) (*ReloadResult, error) {
+	// Hold the event lock during the whole resync, so the watch events arriving in the
+	// meantime are applied after the replacement rather than being overwritten by it.
+	rw.eventMu.Lock()
+	defer rw.eventMu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, etcdutil.DefaultRequestTimeout)
+	defer cancel()
+	rules, revision, err := rw.list(ctx, rw.rulesPathPrefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	// List the others at the same revision to get a consistent snapshot.
+	groups, _, err := rw.list(ctx, rw.ruleGroupPathPrefix, revision)
+	if err != nil {
+		return nil, err
+	}
+	regionRules, _, err := rw.list(ctx, rw.regionLabelPathPrefix, revision)
+	if err != nil {
+		return nil, err
+	}
+	result := &ReloadResult{Revision: revision}
+	result.Rules, result.RuleGroups, result.RegionRules = rw.ruleStore.replace(rules, groups, regionRules)
+	// rebuild the version vector from the reloaded rules, the deleted ones
+	// are dropped from it.
+	rw.ruleVersions = make(map[string]uint64, len(rules))
+	for key, value := range rules {
+		if rule, err := placement.NewRuleFromJSON([]byte(value)); err == nil && rule.Revision > 0 {
+			rw.ruleVersions[key] = rule.Revision
+			if rule.Revision > rw.maxRuleRevision {
+				rw.maxRuleRevision = rule.Revision
+			}
+		}
+	}
+	rw.pendingRevisions = nil
+	rw.resyncRevision = revision
+	rw.updateAppliedRevision(revision)
+	// the storage is as fresh as etcd after the resync.
+	rw.markProgress(ruleWatchName, ruleGroupWatchName, regionLabelWatchName)
+	// the subscribers should re-list the rules, since the changes replaced by
+	// the resync are not published.
+	rw.events.reset(revision)
+	resyncCounter.Inc()
+	lastResyncGauge.Set(float64(time.Now().Unix()))
+	log.Info("rule storage is resynced from etcd",
+		zap.Int64("revision", revision),
+		zap.Any("rules", result.Rules),
+		zap.Any("rule-groups", result.RuleGroups),
+		zap.Any("region-rules", result.RegionRules))
+	return result, nil
+}
+
+// list loads all the key-values under the prefix at the given revision, the latest
+// revision is used if it's 0. The keys returned are trimmed by the prefix.
+func (rw *Watcher) list(ctx context.Context, prefix string, revision int64) (map[string]string, int64, error) {
+	opts := []clientv3.OpOption{clientv3.WithPrefix()}
+	if revision > 0 {
+		opts = append(opts, clientv3.WithRev(revision))
+	}
+	resp, err := clientv3.NewKV(rw.etcdClient).Get(ctx, prefix, opts...)
+	if err != nil {
+		return nil, 0, errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
+	}
+	prefixToTrim := prefix + "/"
+	kvs := make(map[string]string, len(resp.Kvs))
+	for _, item := range resp.Kvs {
+		kvs[strings.TrimPrefix(string(item.Key), prefixToTrim)] = string(item.Value)
+	}
+	return kvs, resp.Header.Revision, nil
 }
 
 // Close closes the watcher.
@@ -0,0 +1,549 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
+	"go.etcd.io/etcd/mvcc/mvccpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestOutOfOrderRuleEvents(t *testing.T) {
+	re := require.New(t)
+	rw := &Watcher{
+		rulesPathPrefix: "/pd/0/rules",
+		ruleStore:       &ruleStorage{},
+		ruleVersions:    make(map[string]uint64),
+		ruleLoaded:      true,
+		resyncCh:        make(chan struct{}, 1),
+		events:          newEventHub(),
+	}
+	var modRevision int64
+	put := func(id string, count int, revision uint64) {
+		rule := &placement.Rule{GroupID: "g", ID: id, Role: placement.Voter, Count: count, Revision: revision}
+		data, err := json.Marshal(rule)
+		re.NoError(err)
+		modRevision++
+		re.NoError(rw.putRule(&mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), Value: data, ModRevision: modRevision}))
+	}
+	del := func(id string) {
+		modRevision++
+		re.NoError(rw.deleteRule(&mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), ModRevision: modRevision}))
+	}
+	counts := func() map[string]int {
+		res := make(map[string]int)
+		re.NoError(rw.ruleStore.LoadRules(func(k, v string) {
+			rule, err := placement.NewRuleFromJSON([]byte(v))
+			re.NoError(err)
+			res[k] = rule.Count
+		}))
+		return res
+	}
+	resyncRequested := func() bool {
+		select {
+		case <-rw.resyncCh:
+			return true
+		default:
+			return false
+		}
+	}
+
+	put("a", 1, 1)
+	put("b", 1, 2)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 1, "b": 1}, counts())
+	re.False(resyncRequested())
+
+	// the stale update of "a" is dropped.
+	put("a", 3, 4)
+	put("a", 2, 3)
+	put("b", 2, 5)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 3, "b": 2}, counts())
+	re.False(resyncRequested())
+
+	// the version of the deleted rule is cleared.
+	del("b")
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 3}, counts())
+	re.NotContains(rw.ruleVersions, "b")
+	re.False(resyncRequested())
+
+	// the rule can be created again with a new revision.
+	put("b", 4, 6)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 3, "b": 4}, counts())
+	re.False(resyncRequested())
+
+	// the revisions 7 and 8 are missing, a resync is requested.
+	put("c", 1, 9)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 3, "b": 4, "c": 1}, counts())
+	re.True(resyncRequested())
+
+	// the late update is still applied if it's not stale.
+	put("a", 5, 8)
+	put("c", 0, 7)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 5, "b": 4, "c": 1}, counts())
+	re.False(resyncRequested())
+
+	// the rules without revision are always applied.
+	put("a", 6, 0)
+	rw.checkRuleRevisionGaps()
+	re.Equal(map[string]int{"a": 6, "b": 4, "c": 1}, counts())
+	re.False(resyncRequested())
+}
+
+func TestEventStream(t *testing.T) {
+	re := require.New(t)
+	rw := &Watcher{
+		rulesPathPrefix: "/pd/0/rules",
+		ruleStore:       &ruleStorage{},
+		ruleVersions:    make(map[string]uint64),
+		resyncCh:        make(chan struct{}, 1),
+		events:          newEventHub(),
+	}
+	put := func(id string, createRevision, modRevision int64) {
+		data, err := json.Marshal(&placement.Rule{GroupID: "g", ID: id, Role: placement.Voter, Count: 1})
+		re.NoError(err)
+		kv := &mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), Value: data, CreateRevision: createRevision, ModRevision: modRevision}
+		re.NoError(rw.putRule(kv))
+	}
+	watch := func(ctx context.Context, startRevision int64) (<-chan *Event, <-chan error) {
+		eventCh, errCh := make(chan *Event, 16), make(chan error, 1)
+		go func() {
+			errCh <- rw.WatchEvents(ctx, startRevision, 10*time.Millisecond, func(e *Event) error {
+				if e.Type != EventHeartbeat {
+					eventCh <- e
+				}
+				return nil
+			})
+		}()
+		return eventCh, errCh
+	}
+	next := func(ch <-chan *Event) *Event {
+		select {
+		case e := <-ch:
+			return e
+		case <-time.After(5 * time.Second):
+			re.FailNow("no event received")
+			return nil
+		}
+	}
+
+	put("a", 1, 1)
+	put("a", 1, 2)
+	re.NoError(rw.deleteRule(&mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/a"), ModRevision: 3}))
+
+	// resume from the revision 2.
+	ctx, cancel := context.WithCancel(context.Background())
+	eventCh, errCh := watch(ctx, 2)
+	e := next(eventCh)
+	re.Equal(int64(2), e.Revision)
+	re.Equal(EventModify, e.Type)
+	re.Equal(ruleType, e.Kind)
+	re.Equal("a", e.Key)
+	re.NotEmpty(e.Value)
+	e = next(eventCh)
+	re.Equal(int64(3), e.Revision)
+	re.Equal(EventDelete, e.Type)
+	re.Empty(e.Value)
+	put("b", 4, 4)
+	e = next(eventCh)
+	re.Equal(int64(4), e.Revision)
+	re.Equal(EventAdd, e.Type)
+	re.Equal("b", e.Key)
+	cancel()
+	re.ErrorIs(<-errCh, context.Canceled)
+
+	// the heartbeat carries the applied revision.
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	heartbeatCh := make(chan *Event, 1)
+	go rw.WatchEvents(ctx, 0, 10*time.Millisecond, func(e *Event) error {
+		select {
+		case heartbeatCh <- e:
+		default:
+		}
+		return nil
+	})
+	e = next(heartbeatCh)
+	re.Equal(EventHeartbeat, e.Type)
+	re.Equal(int64(4), e.Revision)
+
+	// the subscribers are closed by the reset, and the revisions before it can
+	// not be resumed from anymore.
+	_, errCh = watch(context.Background(), 0)
+	re.Eventually(func() bool {
+		rw.events.mu.Lock()
+		defer rw.events.mu.Unlock()
+		return len(rw.events.subscribers) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	rw.events.reset(4)
+	re.ErrorIs(<-errCh, ErrEventStreamReset)
+	_, errCh = watch(context.Background(), 4)
+	re.ErrorIs(<-errCh, ErrEventCompacted)
+	eventCh, _ = watch(ctx, 5)
+	put("c", 5, 5)
+	e = next(eventCh)
+	re.Equal("c", e.Key)
+}
+
+func TestEventService(t *testing.T) {
+	re := require.New(t)
+	watcherCtx, closeWatcher := context.WithCancel(context.Background())
+	defer closeWatcher()
+	rw := &Watcher{
+		ctx:             watcherCtx,
+		cancel:          closeWatcher,
+		rulesPathPrefix: "/pd/0/rules",
+		ruleStore:       &ruleStorage{},
+		ruleVersions:    make(map[string]uint64),
+		resyncCh:        make(chan struct{}, 1),
+		events:          newEventHub(),
+	}
+	put := func(id string, createRevision, modRevision int64) {
+		data, err := json.Marshal(&placement.Rule{GroupID: "g", ID: id, Role: placement.Voter, Count: 1})
+		re.NoError(err)
+		kv := &mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), Value: data, CreateRevision: createRevision, ModRevision: modRevision}
+		re.NoError(rw.putRule(kv))
+	}
+	countSubscribers := func() int {
+		rw.events.mu.Lock()
+		defer rw.events.mu.Unlock()
+		return len(rw.events.subscribers)
+	}
+
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	RegisterEventServer(g, NewEventServer(func() *Watcher { return rw }))
+	go g.Serve(lis)
+	defer g.Stop()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }))
+	re.NoError(err)
+	defer conn.Close()
+	watch := func(ctx context.Context, req *WatchEventsRequest) grpc.ClientStream {
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/"+EventServiceName+"/WatchEvents",
+			grpc.CallContentSubtype(EventCodecName))
+		re.NoError(err)
+		re.NoError(stream.SendMsg(req))
+		re.NoError(stream.CloseSend())
+		return stream
+	}
+	recv := func(stream grpc.ClientStream, heartbeat bool) (*Event, error) {
+		for {
+			e := &Event{}
+			if err := stream.RecvMsg(e); err != nil {
+				return nil, err
+			}
+			if (e.Type == EventHeartbeat) == heartbeat {
+				return e, nil
+			}
+		}
+	}
+	checkCode := func(stream grpc.ClientStream, code codes.Code) {
+		_, err := recv(stream, false)
+		re.Equal(code, status.Code(err))
+	}
+
+	put("a", 1, 1)
+	put("a", 1, 2)
+	re.NoError(rw.deleteRule(&mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/a"), ModRevision: 3}))
+
+	// resume from the revision 2, the typed events are streamed.
+	streamCtx, cancelStream := context.WithCancel(ctx)
+	stream := watch(streamCtx, &WatchEventsRequest{StartRevision: 2, HeartbeatIntervalMs: 100})
+	e, err := recv(stream, false)
+	re.NoError(err)
+	re.Equal(&Event{Revision: 2, Type: EventModify, Kind: ruleType, Key: "a", Value: e.Value}, e)
+	re.NotEmpty(e.Value)
+	e, err = recv(stream, false)
+	re.NoError(err)
+	re.Equal(&Event{Revision: 3, Type: EventDelete, Kind: ruleType, Key: "a"}, e)
+	put("b", 4, 4)
+	e, err = recv(stream, false)
+	re.NoError(err)
+	re.Equal(EventAdd, e.Type)
+	re.Equal("b", e.Key)
+	// the heartbeat carries the applied revision.
+	e, err = recv(stream, true)
+	re.NoError(err)
+	re.Equal(int64(4), e.Revision)
+	// the subscriber is removed once the client goes away.
+	cancelStream()
+	re.Eventually(func() bool { return countSubscribers() == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	// the stream is aborted by a reset, and the revisions before it can not be
+	// resumed from anymore.
+	stream = watch(ctx, &WatchEventsRequest{})
+	re.Eventually(func() bool { return countSubscribers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	rw.events.reset(4)
+	checkCode(stream, codes.Aborted)
+	checkCode(watch(ctx, &WatchEventsRequest{StartRevision: 4}), codes.OutOfRange)
+	checkCode(watch(ctx, &WatchEventsRequest{StartRevision: -1}), codes.InvalidArgument)
+
+	// the stream is closed with the watcher.
+	stream = watch(ctx, &WatchEventsRequest{StartRevision: 5})
+	re.Eventually(func() bool { return countSubscribers() == 1 }, 5*time.Second, 10*time.Millisecond)
+	closeWatcher()
+	checkCode(stream, codes.Unavailable)
+}
+
+func TestSubscribe(t *testing.T) {
+	re := require.New(t)
+	rw := &Watcher{
+		rulesPathPrefix: "/pd/0/rules",
+		ruleStore:       &ruleStorage{},
+		ruleVersions:    make(map[string]uint64),
+		resyncCh:        make(chan struct{}, 1),
+		events:          newEventHub(),
+	}
+	var revision int64
+	put := func(id string) {
+		data, err := json.Marshal(&placement.Rule{GroupID: "g", ID: id, Role: placement.Voter, Count: 1})
+		re.NoError(err)
+		revision++
+		kv := &mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), Value: data, CreateRevision: revision, ModRevision: revision}
+		re.NoError(rw.putRule(kv))
+	}
+
+	// each subscriber receives the events independently.
+	fast, unsubscribeFast := rw.Subscribe()
+	slow, unsubscribeSlow := rw.Subscribe()
+	put("a")
+	re.Equal("a", (<-fast).Key)
+	for i := 0; i < subscriberBufferSize+10; i++ {
+		put(fmt.Sprintf("r%d", i))
+		re.Equal(revision, (<-fast).Revision)
+	}
+
+	// the slow subscriber never blocks the others, the dropped events are
+	// replaced by a resync.
+	re.Len(slow, subscriberBufferSize+1)
+	for i := 0; i < subscriberBufferSize; i++ {
+		e := <-slow
+		re.NotEqual(EventResync, e.Type)
+	}
+	e := <-slow
+	re.Equal(EventResync, e.Type)
+	re.Empty(slow)
+	put("b")
+	re.Equal("b", (<-slow).Key)
+	re.Equal("b", (<-fast).Key)
+
+	// the in-process subscribers are notified to resync by a reset rather than
+	// being closed.
+	rw.events.reset(revision)
+	re.Equal(EventResync, (<-fast).Type)
+	re.Equal(EventResync, (<-slow).Type)
+
+	// the channel is closed by unsubscribing, which can be called repeatedly.
+	unsubscribeSlow()
+	unsubscribeSlow()
+	_, ok := <-slow
+	re.False(ok)
+	put("c")
+	re.Equal("c", (<-fast).Key)
+	unsubscribeFast()
+	rw.events.mu.Lock()
+	re.Empty(rw.events.subscribers)
+	rw.events.mu.Unlock()
+}
+
+func TestEventQueueBackpressure(t *testing.T) {
+	re := require.New(t)
+	saturationTimeout := queueSaturationTimeout
+	queueSaturationTimeout = 50 * time.Millisecond
+	defer func() { queueSaturationTimeout = saturationTimeout }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rw := &Watcher{
+		ctx:      ctx,
+		resyncCh: make(chan struct{}, 1),
+		queue:    make(chan func(), 4),
+	}
+	applied := 0
+	apply := func() { applied++ }
+	for i := 0; i < 4; i++ {
+		rw.enqueue(apply)
+	}
+	re.False(rw.saturated.Load())
+	re.Empty(rw.resyncCh)
+
+	// the queue stays full, so the event is dropped and a resync is requested.
+	rw.enqueue(apply)
+	re.True(rw.saturated.Load())
+	re.Len(rw.queue, 4)
+	re.Len(rw.resyncCh, 1)
+	<-rw.resyncCh
+
+	// the events are dropped until the queue drains to a half.
+	rw.enqueue(apply)
+	re.Len(rw.queue, 4)
+	re.Len(rw.resyncCh, 1)
+	for i := 0; i < 2; i++ {
+		(<-rw.queue)()
+	}
+	rw.enqueue(apply)
+	re.False(rw.saturated.Load())
+	re.Len(rw.queue, 3)
+	for len(rw.queue) > 0 {
+		(<-rw.queue)()
+	}
+	re.Equal(5, applied)
+}
+
+func TestSkipEventsCoveredByResync(t *testing.T) {
+	re := require.New(t)
+	rw := &Watcher{
+		rulesPathPrefix: "/pd/0/rules",
+		ruleStore:       &ruleStorage{},
+		ruleVersions:    make(map[string]uint64),
+		resyncCh:        make(chan struct{}, 1),
+		events:          newEventHub(),
+		resyncRevision:  10,
+	}
+	put := func(id string, modRevision int64) {
+		data, err := json.Marshal(&placement.Rule{GroupID: "g", ID: id, Role: placement.Voter, Count: 1})
+		re.NoError(err)
+		re.NoError(rw.putRule(&mvccpb.KeyValue{Key: []byte(rw.rulesPathPrefix + "/" + id), Value: data, ModRevision: modRevision}))
+	}
+	// the event queued before the resync is skipped.
+	put("a", 10)
+	put("b", 11)
+	var keys []string
+	re.NoError(rw.ruleStore.LoadRules(func(k, _ string) { keys = append(keys, k) }))
+	re.Equal([]string{"b"}, keys)
+}
+
+func TestWatchHealth(t *testing.T) {
+	re := require.New(t)
+	rw := &Watcher{brokenWatches: make(map[string]*WatchError)}
+	ruleStatus, groupStatus := rw.watchStatusHandler("rule"), rw.watchStatusHandler("group")
+	re.True(rw.Healthy())
+	re.NoError(rw.Err())
+
+	// the retryable error.
+	ruleStatus(errors.New("etcdserver: no leader"))
+	re.False(rw.Healthy())
+	var watchErr *WatchError
+	re.ErrorAs(rw.Err(), &watchErr)
+	re.Equal("rule", watchErr.Watch)
+	re.True(watchErr.Retryable)
+	re.False(watchErr.Time.IsZero())
+
+	// the fatal error.
+	groupStatus(rpctypes.ErrPermissionDenied)
+	re.ErrorAs(rw.Err(), &watchErr)
+	re.Equal("group", watchErr.Watch)
+	re.False(watchErr.Retryable)
+	re.ErrorIs(rw.Err(), rpctypes.ErrPermissionDenied)
+
+	// the watcher is healthy once all the watches receive the responses again,
+	// and the last error is kept.
+	ruleStatus(nil)
+	re.False(rw.Healthy())
+	groupStatus(nil)
+	re.True(rw.Healthy())
+	re.ErrorAs(rw.Err(), &watchErr)
+	re.Equal("group", watchErr.Watch)
+}
+
+func TestReadConsistency(t *testing.T) {
+	re := require.New(t)
+	rs := &ruleStorage{rulesPathPrefix: "/pd/0/rules", ruleGroupPathPrefix: "/pd/0/rule_group"}
+	re.NoError(rs.SaveRule(nil, "g-cached", "cached"))
+	re.NoError(rs.SaveRuleGroup(nil, "g", "cached"))
+	load := func(loader func(endpoint.ReadConsistency, func(k, v string)) error, consistency endpoint.ReadConsistency) map[string]string {
+		res := make(map[string]string)
+		re.NoError(loader(consistency, func(k, v string) { res[k] = v }))
+		return res
+	}
+
+	// the linearizable read is rejected without etcd.
+	re.Error(rs.LoadRulesWithConsistency(endpoint.LinearizableRead, func(string, string) {}))
+
+	// the cached read never reaches etcd, while the linearizable read does.
+	etcd := map[string]map[string]string{
+		"/pd/0/rules":      {"g-latest": "latest"},
+		"/pd/0/rule_group": {"g": "latest"},
+	}
+	rs.loadFromEtcd = func(prefix string) (map[string]string, error) {
+		return etcd[prefix], nil
+	}
+	re.Equal(map[string]string{"g-cached": "cached"}, load(rs.LoadRulesWithConsistency, endpoint.CachedRead))
+	re.Equal(map[string]string{"g-latest": "latest"}, load(rs.LoadRulesWithConsistency, endpoint.LinearizableRead))
+	re.Equal(map[string]string{"g": "cached"}, load(rs.LoadRuleGroupsWithConsistency, endpoint.CachedRead))
+	re.Equal(map[string]string{"g": "latest"}, load(rs.LoadRuleGroupsWithConsistency, endpoint.LinearizableRead))
+
+	// the error of etcd is returned as it is.
+	rs.loadFromEtcd = func(string) (map[string]string, error) {
+		return nil, errors.New("etcd is unavailable")
+	}
+	re.Error(rs.LoadRulesWithConsistency(endpoint.LinearizableRead, func(string, string) {}))
+	re.NoError(rs.LoadRulesWithConsistency(endpoint.CachedRead, func(string, string) {}))
+}
+
+func TestBoundedStaleness(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rw := &Watcher{
+		ctx:       ctx,
+		ruleStore: &ruleStorage{},
+		queue:     make(chan func(), 4),
+		progress:  make(map[string]time.Time),
+	}
+	rw.ruleStore.isFresh = rw.IsFresh
+	re.True(rw.ruleStore.IsFresh())
+
+	// the storage is always fresh without the max staleness.
+	rw.markProgress("rule", "group")
+	time.Sleep(20 * time.Millisecond)
+	re.True(rw.IsFresh())
+	re.GreaterOrEqual(rw.Staleness(), 20*time.Millisecond)
+
+	// the storage is stale once any watch makes no progress within the bound.
+	rw.SetMaxStaleness(10 * time.Millisecond)
+	re.False(rw.ruleStore.IsFresh())
+	rw.markProgress("rule")
+	re.False(rw.ruleStore.IsFresh())
+
+	// the progress is marked after the queued events are applied, including
+	// the empty progress notifications.
+	rw.progressHandler("group")(100)
+	re.False(rw.ruleStore.IsFresh())
+	(<-rw.queue)()
+	re.True(rw.ruleStore.IsFresh())
+	re.Less(rw.Staleness(), 10*time.Millisecond)
+}
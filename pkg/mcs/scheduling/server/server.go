@@ -344,6 +344,11 @@ func (s *Server) GetBasicCluster() *core.BasicCluster {
 	return s.basicCluster
 }
 
+// GetRuleWatcher returns the watcher of the Placement Rules.
+func (s *Server) GetRuleWatcher() *rule.Watcher {
+	return s.ruleWatcher
+}
+
 // GetCoordinator returns the coordinator.
 func (s *Server) GetCoordinator() *schedule.Coordinator {
 	return s.GetCluster().GetCoordinator()
@@ -442,11 +447,35 @@ func (s *Server) startCluster(context.Context) error {
 	if err != nil {
 		return err
 	}
+	if err := s.cluster.startKeyspaceScheduling(s.hbStreams, s.cfg.KeyspaceScheduling); err != nil {
+		return err
+	}
+	s.configWatcher.SetSchedulerConfigListener(s.reloadSchedulerConfig)
 	go s.GetCoordinator().RunUntilStop()
+	go s.cluster.runRuleActivationCheck()
 	return nil
 }
 
+// reloadSchedulerConfig updates the config of the running scheduler in place
+// when its config is updated by the PD API server.
+func (s *Server) reloadSchedulerConfig(name string, data []byte) {
+	sc := s.GetCoordinator().GetSchedulersController()
+	scheduler := sc.GetScheduler(name)
+	if scheduler == nil {
+		return
+	}
+	if _, ok := scheduler.Scheduler.(schedulers.ConfigReloader); !ok {
+		return
+	}
+	if err := sc.ReloadSchedulerConfig(name, data); err != nil {
+		log.Warn("failed to reload the scheduler config", zap.String("scheduler-name", name), errs.ZapError(err))
+		return
+	}
+	log.Info("scheduler config is reloaded", zap.String("scheduler-name", name))
+}
+
 func (s *Server) stopCluster() {
+	s.cluster.stopKeyspaceScheduling()
 	s.GetCoordinator().Stop()
 	s.ruleWatcher.Close()
 	s.configWatcher.Close()
@@ -462,8 +491,12 @@ func (s *Server) startWatcher() (err error) {
 	if err != nil {
 		return err
 	}
-	s.ruleWatcher, err = rule.NewWatcher(s.Context(), s.GetClient(), s.clusterID)
-	return err
+	s.ruleWatcher, err = rule.NewWatcher(s.Context(), s.GetClient(), s.clusterID, s.cfg.RuleWatcherMaxInFlightEvents)
+	if err != nil {
+		return err
+	}
+	s.ruleWatcher.SetMaxStaleness(s.cfg.RuleWatcherMaxStaleness.Duration)
+	return nil
 }
 
 // CreateServer creates the Server
@@ -112,6 +112,26 @@ func (mc *Cluster) SetHotRegionCacheHitsThreshold(v int) {
 	mc.updateScheduleConfig(func(s *sc.ScheduleConfig) { s.HotRegionCacheHitsThreshold = uint64(v) })
 }
 
+// SetColdRegionDuration updates the ColdRegionDuration configuration.
+func (mc *Cluster) SetColdRegionDuration(v time.Duration) {
+	mc.updateScheduleConfig(func(s *sc.ScheduleConfig) { s.ColdRegionDuration = typeutil.NewDuration(v) })
+}
+
+// SetEnableSchedulingDryRun updates the EnableSchedulingDryRun configuration.
+func (mc *Cluster) SetEnableSchedulingDryRun(v bool) {
+	mc.updateScheduleConfig(func(s *sc.ScheduleConfig) { s.EnableSchedulingDryRun = v })
+}
+
+// SetOperatorPriorityWeights updates the OperatorPriorityWeights configuration.
+func (mc *Cluster) SetOperatorPriorityWeights(v []float64) {
+	mc.updateScheduleConfig(func(s *sc.ScheduleConfig) { s.OperatorPriorityWeights = v })
+}
+
+// SetRecoveryPreemptRatio updates the RecoveryPreemptRatio configuration.
+func (mc *Cluster) SetRecoveryPreemptRatio(v float64) {
+	mc.updateScheduleConfig(func(s *sc.ScheduleConfig) { s.RecoveryPreemptRatio = v })
+}
+
 // SetEnablePlacementRules updates the EnablePlacementRules configuration.
 func (mc *Cluster) SetEnablePlacementRules(v bool) {
 	mc.updateReplicationConfig(func(r *sc.ReplicationConfig) { r.EnablePlacementRules = v })
@@ -163,7 +183,8 @@ func (mc *Cluster) SetMaxReplicasWithLabel(enablePlacementRules bool, num int, l
 			Count:          num,
 			LocationLabels: labels,
 		}
-		mc.SetRule(rule)
+		// the tests may set more replicas than the stores on purpose.
+		mc.ForceSetRule(rule)
 	} else {
 		mc.SetMaxReplicas(num)
 		mc.SetLocationLabels(labels)
@@ -46,6 +46,7 @@ type Controller struct {
 	splitChecker      *SplitChecker
 	mergeChecker      *MergeChecker
 	jointStateChecker *JointStateChecker
+	tieredChecker     *TieredStorageChecker
 	priorityInspector *PriorityInspector
 	regionWaitingList cache.Cache
 	suspectRegions    *cache.TTLUint64 // suspectRegions are regions that may need fix
@@ -65,6 +66,7 @@ func NewController(ctx context.Context, cluster sche.CheckerCluster, conf config
 		splitChecker:      NewSplitChecker(cluster, ruleManager, labeler),
 		mergeChecker:      NewMergeChecker(ctx, cluster, conf),
 		jointStateChecker: NewJointStateChecker(cluster),
+		tieredChecker:     NewTieredStorageChecker(cluster),
 		priorityInspector: NewPriorityInspector(cluster, conf),
 		regionWaitingList: regionWaitingList,
 		suspectRegions:    cache.NewIDTTL(ctx, time.Minute, 3*time.Minute),
@@ -77,6 +79,7 @@ func (c *Controller) CheckRegion(region *core.RegionInfo) []*operator.Operator {
 	// If PD has restarted, it needs to check learners added before and promote them.
 	// Don't check isRaftLearnerEnabled cause it maybe disable learner feature but there are still some learners to promote.
 	opController := c.opController
+	c.tieredChecker.Observe(region)
 
 	if op := c.jointStateChecker.Check(region); op != nil {
 		return []*operator.Operator{op}
@@ -130,6 +133,13 @@ func (c *Controller) CheckRegion(region *core.RegionInfo) []*operator.Operator {
 		}
 	}
 
+	if op := c.tieredChecker.Check(region); op != nil {
+		if opController.OperatorCount(operator.OpReplica) < c.conf.GetReplicaScheduleLimit() {
+			return []*operator.Operator{op}
+		}
+		operator.OperatorLimitCounter.WithLabelValues(c.tieredChecker.GetType(), operator.OpReplica.String()).Inc()
+	}
+
 	if c.mergeChecker != nil {
 		allowed := opController.OperatorCount(operator.OpMerge) < c.conf.GetMergeScheduleLimit()
 		if !allowed {
@@ -152,6 +162,11 @@ func (c *Controller) GetRuleChecker() *RuleChecker {
 	return c.ruleChecker
 }
 
+// GetTieredStorageChecker returns the tiered storage checker.
+func (c *Controller) GetTieredStorageChecker() *TieredStorageChecker {
+	return c.tieredChecker
+}
+
 // GetWaitingRegions returns the regions in the waiting list.
 func (c *Controller) GetWaitingRegions() []*cache.Item {
 	return c.regionWaitingList.Elems()
@@ -242,6 +257,8 @@ func (c *Controller) GetPauseController(name string) (*PauseController, error) {
 		return &c.mergeChecker.PauseController, nil
 	case "joint-state":
 		return &c.jointStateChecker.PauseController, nil
+	case "tiered-storage":
+		return &c.tieredChecker.PauseController, nil
 	default:
 		return nil, errs.ErrCheckerNotFound.FastGenByArgs()
 	}
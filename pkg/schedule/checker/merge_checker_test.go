@@ -202,7 +202,7 @@ func (suite *mergeCheckerTestSuite) TestBasic() {
 	suite.NotNil(ops)
 	suite.Equal(suite.regions[2].GetID(), ops[0].RegionID())
 	suite.Equal(suite.regions[1].GetID(), ops[1].RegionID())
-	suite.cluster.RuleManager.DeleteRule("pd", "test")
+	suite.cluster.RuleManager.DeleteRule("pd", "test", false)
 
 	//  check 'merge_option' label
 	suite.cluster.GetRegionLabeler().SetLabelRule(&labeler.LabelRule{
@@ -66,6 +66,7 @@ var (
 	ruleCheckerNotAllowLeaderCounter              = checkerCounter.WithLabelValues(ruleChecker, "not-allow-leader")
 	ruleCheckerFixFollowerRoleCounter             = checkerCounter.WithLabelValues(ruleChecker, "fix-follower-role")
 	ruleCheckerNoNewLeaderCounter                 = checkerCounter.WithLabelValues(ruleChecker, "no-new-leader")
+	ruleCheckerFixWitnessLeaderCounter            = checkerCounter.WithLabelValues(ruleChecker, "fix-witness-leader")
 	ruleCheckerDemoteVoterRoleCounter             = checkerCounter.WithLabelValues(ruleChecker, "demote-voter-role")
 	ruleCheckerRecentlyPromoteToNonWitnessCounter = checkerCounter.WithLabelValues(ruleChecker, "recently-promote-to-non-witness")
 	ruleCheckerCancelSwitchToWitnessCounter       = checkerCounter.WithLabelValues(ruleChecker, "cancel-switch-to-witness")
@@ -185,6 +186,11 @@ func (c *RuleChecker) isWitnessEnabled() bool {
 func (c *RuleChecker) fixRulePeer(region *core.RegionInfo, fit *placement.RegionFit, rf *placement.RuleFit) (*operator.Operator, error) {
 	// make up peers.
 	if len(rf.Peers) < rf.Rule.Count {
+		// the leader is never selected as a witness, the witness can only be
+		// placed on its store after the leader is moved away.
+		if rf.Rule.IsWitness && c.isWitnessEnabled() && c.isOrphanLeaderOfRule(region, fit, rf) {
+			return c.transferWitnessLeader(region, fit)
+		}
 		return c.addRulePeer(region, rf)
 	}
 	// fix down/offline peers.
@@ -341,7 +347,7 @@ func (c *RuleChecker) fixLooseMatchPeer(region *core.RegionInfo, fit *placement.
 		return operator.CreateDemoteVoterOperator("fix-demote-voter", c.cluster, region, peer)
 	}
 	if region.GetLeader().GetId() == peer.GetId() && rf.Rule.IsWitness {
-		return nil, errPeerCannotBeWitness
+		return c.transferWitnessLeader(region, fit)
 	}
 	if !core.IsWitness(peer) && rf.Rule.IsWitness && c.isWitnessEnabled() {
 		c.switchWitnessCache.UpdateTTL(c.cluster.GetCheckerConfig().GetSwitchWitnessInterval())
@@ -370,6 +376,36 @@ func (c *RuleChecker) fixLooseMatchPeer(region *core.RegionInfo, fit *placement.
 	return nil, nil
 }
 
+// isOrphanLeaderOfRule returns whether the leader is not selected by any rule
+// while its store matches the rule.
+func (c *RuleChecker) isOrphanLeaderOfRule(region *core.RegionInfo, fit *placement.RegionFit, rf *placement.RuleFit) bool {
+	leader := region.GetLeader()
+	store := c.cluster.GetStore(leader.GetStoreId())
+	if store == nil || !rf.Rule.MatchStore(store) {
+		return false
+	}
+	for _, p := range fit.OrphanPeers {
+		if p.GetId() == leader.GetId() {
+			return true
+		}
+	}
+	return false
+}
+
+// transferWitnessLeader moves the leader away from the peer which should be a
+// witness, since a witness can never be the leader.
+func (c *RuleChecker) transferWitnessLeader(region *core.RegionInfo, fit *placement.RegionFit) (*operator.Operator, error) {
+	ruleCheckerFixWitnessLeaderCounter.Inc()
+	leader := region.GetLeader()
+	for _, p := range region.GetPeers() {
+		if p.GetId() != leader.GetId() && c.allowLeader(fit, p) {
+			return operator.CreateTransferLeaderOperator("fix-witness-leader", c.cluster, region, leader.GetStoreId(), p.GetStoreId(), []uint64{}, 0)
+		}
+	}
+	ruleCheckerNoNewLeaderCounter.Inc()
+	return nil, errPeerCannotBeWitness
+}
+
 func (c *RuleChecker) allowLeader(fit *placement.RegionFit, peer *metapb.Peer) bool {
 	if core.IsLearner(peer) || core.IsWitness(peer) {
 		return false
@@ -384,7 +420,7 @@ func (c *RuleChecker) allowLeader(fit *placement.RegionFit, peer *metapb.Peer) b
 	}
 	for _, rf := range fit.RuleFits {
 		if (rf.Rule.Role == placement.Leader || rf.Rule.Role == placement.Voter) &&
-			placement.MatchLabelConstraints(s, rf.Rule.LabelConstraints) {
+			rf.Rule.MatchStore(s) {
 			return true
 		}
 	}
@@ -407,7 +443,7 @@ func (c *RuleChecker) fixBetterLocation(region *core.RegionInfo, rf *placement.R
 	var coLocationStores []*core.StoreInfo
 	regionStores := c.cluster.GetRegionStores(region)
 	for _, s := range regionStores {
-		if placement.MatchLabelConstraints(s, rf.Rule.LabelConstraints) {
+		if rf.Rule.MatchStore(s) {
 			coLocationStores = append(coLocationStores, s)
 		}
 	}
@@ -585,7 +621,7 @@ func (c *RuleChecker) strategy(region *core.RegionInfo, rule *placement.Rule, fa
 		isolationLevel: rule.IsolationLevel,
 		locationLabels: rule.LocationLabels,
 		region:         region,
-		extraFilters:   []filter.Filter{filter.NewLabelConstraintFilter(c.name, rule.LabelConstraints)},
+		extraFilters:   []filter.Filter{filter.NewLabelConstraintFilter(c.name, rule.LabelConstraints, rule.LabelConstraintAlternatives...)},
 		fastFailover:   fastFailover,
 	}
 }
@@ -366,6 +366,57 @@ func (suite *ruleCheckerTestSuite) TestFixRuleWitness() {
 	suite.True(op.Step(0).(operator.AddLearner).IsWitness)
 }
 
+func (suite *ruleCheckerTestSuite) TestFixWitnessLeader() {
+	suite.cluster.AddLabelsStore(1, 1, map[string]string{"zone": "z1"})
+	suite.cluster.AddLabelsStore(2, 1, map[string]string{"zone": "z2"})
+	suite.cluster.AddLabelsStore(3, 1, map[string]string{"zone": "z3"})
+	suite.cluster.AddLeaderRegion(1, 3, 1, 2)
+	err := suite.ruleManager.SetRules([]*placement.Rule{
+		{
+			GroupID:  "pd",
+			ID:       "default",
+			Override: true,
+			Role:     placement.Voter,
+			Count:    2,
+			LabelConstraints: []placement.LabelConstraint{
+				{Key: "zone", Op: "in", Values: []string{"z1", "z2"}},
+			},
+		},
+		{
+			GroupID: "pd",
+			ID:      "witness",
+			Index:   1,
+			Role:    placement.Witness,
+			Count:   1,
+			LabelConstraints: []placement.LabelConstraint{
+				{Key: "zone", Op: "in", Values: []string{"z3"}},
+			},
+		},
+	})
+	suite.NoError(err)
+
+	// the leader is moved away before its peer becomes a witness.
+	op := suite.rc.Check(suite.cluster.GetRegion(1))
+	suite.NotNil(op)
+	suite.Equal("fix-witness-leader", op.Desc())
+	suite.Equal(uint64(3), op.Step(0).(operator.TransferLeader).FromStore)
+	suite.NotEqual(uint64(3), op.Step(0).(operator.TransferLeader).ToStore)
+
+	suite.cluster.AddLeaderRegion(1, 1, 2, 3)
+	op = suite.rc.Check(suite.cluster.GetRegion(1))
+	suite.NotNil(op)
+	suite.Equal("fix-witness-peer", op.Desc())
+	suite.Equal(uint64(3), op.Step(0).(operator.BecomeWitness).StoreID)
+
+	// the leader is moved away too once the witness is disabled.
+	suite.cluster.SetEnableWitness(false)
+	suite.cluster.AddLeaderRegion(1, 3, 1, 2)
+	op = suite.rc.Check(suite.cluster.GetRegion(1))
+	suite.NotNil(op)
+	suite.Equal("fix-witness-leader", op.Desc())
+	suite.Equal(uint64(3), op.Step(0).(operator.TransferLeader).FromStore)
+}
+
 func (suite *ruleCheckerTestSuite) TestFixRuleWitness2() {
 	suite.cluster.AddLabelsStore(1, 1, map[string]string{"A": "leader"})
 	suite.cluster.AddLabelsStore(2, 1, map[string]string{"B": "voter"})
@@ -786,7 +837,7 @@ func (suite *ruleCheckerTestSuite) TestPriorityFitHealthPeersAndTiFlash() {
 	}
 	suite.ruleManager.SetRule(rule)
 	suite.ruleManager.SetRule(rule2)
-	suite.ruleManager.DeleteRule("pd", "default")
+	suite.ruleManager.DeleteRule("pd", "default", true)
 
 	r1 := suite.cluster.GetRegion(1)
 	// set peer3 to pending and down
@@ -842,7 +893,7 @@ func (suite *ruleCheckerTestSuite) TestIssue3293() {
 		Count:   3,
 	})
 	suite.NoError(err)
-	err = suite.ruleManager.DeleteRule("pd", "default")
+	err = suite.ruleManager.DeleteRule("pd", "default", true)
 	suite.NoError(err)
 	op := suite.rc.Check(suite.cluster.GetRegion(1))
 	suite.NotNil(op)
@@ -1405,7 +1456,7 @@ func (suite *ruleCheckerTestSuite) TestDemoteVoter() {
 	}
 	suite.ruleManager.SetRule(rule)
 	suite.ruleManager.SetRule(rule2)
-	suite.ruleManager.DeleteRule("pd", "default")
+	suite.ruleManager.DeleteRule("pd", "default", true)
 	op := suite.rc.Check(region)
 	suite.NotNil(op)
 	suite.Equal("fix-demote-voter", op.Desc())
@@ -1511,7 +1562,7 @@ func (suite *ruleCheckerTestSuite) TestLocationLabels() {
 	suite.ruleManager.SetRule(rule1)
 	suite.ruleManager.SetRule(rule2)
 	suite.ruleManager.SetRule(rule3)
-	suite.ruleManager.DeleteRule("pd", "default")
+	suite.ruleManager.DeleteRule("pd", "default", true)
 	op := suite.rc.Check(suite.cluster.GetRegion(1))
 	suite.NotNil(op)
 	suite.Equal("move-to-better-location", op.Desc())
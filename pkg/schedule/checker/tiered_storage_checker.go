@@ -0,0 +1,178 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/constant"
+	"github.com/tikv/pd/pkg/errs"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"github.com/tikv/pd/pkg/schedule/filter"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/statistics"
+	"go.uber.org/zap"
+)
+
+const tieredStorageCheckerName = "tiered-storage-checker"
+
+var (
+	// WithLabelValues is a heavy operation, define variable to avoid call it every time.
+	tieredStorageCheckerPausedCounter       = checkerCounter.WithLabelValues(tieredStorageCheckerName, "paused")
+	tieredStorageCheckerPinnedCounter       = checkerCounter.WithLabelValues(tieredStorageCheckerName, "pinned-by-rule")
+	tieredStorageCheckerNoTargetCounter     = checkerCounter.WithLabelValues(tieredStorageCheckerName, "no-target-store")
+	tieredStorageCheckerCreateOpFailCounter = checkerCounter.WithLabelValues(tieredStorageCheckerName, "create-operator-fail")
+	tieredStorageCheckerNewOpCounter        = checkerCounter.WithLabelValues(tieredStorageCheckerName, "new-operator")
+)
+
+// TieredStorageChecker demotes the cold regions to the stores of the cold
+// storage tier, which is told by the value of the tier label of the stores.
+// The coldness of the regions is tracked from the flow of the regions which
+// are checked. The hot regions are promoted by the tiered storage scheduler,
+// which moves the peers with MoveToTier as well. The peers are moved one at
+// a time and the placement of the region never becomes worse.
+type TieredStorageChecker struct {
+	PauseController
+	cluster  sche.SharedCluster
+	coldness *statistics.ColdnessTracker
+}
+
+// NewTieredStorageChecker creates a tiered storage checker.
+func NewTieredStorageChecker(cluster sche.SharedCluster) *TieredStorageChecker {
+	return &TieredStorageChecker{
+		cluster:  cluster,
+		coldness: statistics.NewColdnessTracker(),
+	}
+}
+
+// GetType return TieredStorageChecker's type.
+func (c *TieredStorageChecker) GetType() string {
+	return tieredStorageCheckerName
+}
+
+// Observe records the access of the region.
+func (c *TieredStorageChecker) Observe(region *core.RegionInfo) {
+	c.coldness.Observe(region, time.Now())
+}
+
+// GC removes the coldness of the regions which do not exist anymore.
+func (c *TieredStorageChecker) GC() int {
+	return c.coldness.GC(func(regionID uint64) bool {
+		return c.cluster.GetRegion(regionID) != nil
+	})
+}
+
+// IsCold returns whether the region is cold enough to be demoted.
+func (c *TieredStorageChecker) IsCold(region *core.RegionInfo) bool {
+	coldDuration := c.cluster.GetSharedConfig().GetColdRegionDuration()
+	return coldDuration > 0 && c.coldness.IsCold(region.GetID(), time.Now(), coldDuration)
+}
+
+// Check demotes the region if it is cold and not in the cold storage tier.
+func (c *TieredStorageChecker) Check(region *core.RegionInfo) *operator.Operator {
+	if c.IsPaused() {
+		tieredStorageCheckerPausedCounter.Inc()
+		return nil
+	}
+	if !c.IsCold(region) {
+		return nil
+	}
+	return c.MoveToTier(region, c.cluster.GetSharedConfig().GetColdStorageTier(), operator.OpReplica)
+}
+
+// InTier returns whether all the peers of the region are in the stores of the tier.
+func (c *TieredStorageChecker) InTier(region *core.RegionInfo, tier string) bool {
+	constraint := c.tierConstraint(tier)
+	for _, peer := range region.GetPeers() {
+		store := c.cluster.GetStore(peer.GetStoreId())
+		if store == nil || !constraint.MatchStore(store) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsPinnedByRule returns whether the tier of the region is decided by the
+// placement rules, which have the label constraints of the tier label. The
+// placement rules always take precedence over the tiering.
+func (c *TieredStorageChecker) IsPinnedByRule(region *core.RegionInfo) bool {
+	if !c.cluster.GetSharedConfig().IsPlacementRulesEnabled() {
+		return false
+	}
+	labelKey := c.cluster.GetSharedConfig().GetTieredStorageLabelKey()
+	for _, rule := range c.cluster.GetRuleManager().GetRulesForApplyRegion(region) {
+		for _, constraint := range rule.LabelConstraints {
+			if constraint.Key == labelKey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MoveToTier creates an operator moving a peer of the region, which is not in
+// the stores of the tier, to a store of the tier.
+func (c *TieredStorageChecker) MoveToTier(region *core.RegionInfo, tier string, kind operator.OpKind) *operator.Operator {
+	if c.IsPinnedByRule(region) {
+		tieredStorageCheckerPinnedCounter.Inc()
+		return nil
+	}
+	constraint := c.tierConstraint(tier)
+	conf := c.cluster.GetSharedConfig()
+	misplaced := false
+	for _, peer := range region.GetPeers() {
+		source := c.cluster.GetStore(peer.GetStoreId())
+		if source == nil || constraint.MatchStore(source) || peer.GetIsWitness() {
+			continue
+		}
+		misplaced = true
+		filters := []filter.Filter{
+			filter.NewExcludedFilter(c.GetType(), nil, region.GetStoreIDs()),
+			filter.NewLabelConstraintFilter(c.GetType(), []placement.LabelConstraint{constraint}),
+			&filter.StoreStateFilter{ActionScope: c.GetType(), MoveRegion: true, OperatorLevel: constant.Low},
+			filter.NewSpecialUseFilter(c.GetType()),
+			filter.NewPlacementSafeguard(c.GetType(), conf, c.cluster.GetBasicCluster(), c.cluster.GetRuleManager(), region, source, nil),
+		}
+		target := filter.NewCandidates(c.cluster.GetStores()).
+			FilterTarget(conf, nil, nil, filters...).
+			PickTheTopStore(filter.RegionScoreComparer(conf), true)
+		if target == nil {
+			continue
+		}
+		newPeer := &metapb.Peer{StoreId: target.GetID(), Role: peer.GetRole()}
+		op, err := operator.CreateMovePeerOperator(c.GetType(), c.cluster, region, kind, source.GetID(), newPeer)
+		if err != nil {
+			tieredStorageCheckerCreateOpFailCounter.Inc()
+			log.Debug("fail to create tiered storage operator", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+			return nil
+		}
+		op.Counters = append(op.Counters, tieredStorageCheckerNewOpCounter)
+		op.AdditionalInfos["tier"] = tier
+		return op
+	}
+	if misplaced {
+		tieredStorageCheckerNoTargetCounter.Inc()
+	}
+	return nil
+}
+
+func (c *TieredStorageChecker) tierConstraint(tier string) placement.LabelConstraint {
+	labelKey := c.cluster.GetSharedConfig().GetTieredStorageLabelKey()
+	return placement.LabelConstraint{Key: labelKey, Op: placement.In, Values: []string{tier}}
+}
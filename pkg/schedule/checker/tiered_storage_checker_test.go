@@ -0,0 +1,68 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/mock/mockcluster"
+	"github.com/tikv/pd/pkg/mock/mockconfig"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/placement"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
+)
+
+func TestTieredStorageChecker(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := mockcluster.NewCluster(ctx, mockconfig.NewTestOptions())
+	for id := uint64(1); id <= 3; id++ {
+		cluster.AddLabelsStore(id, 1, map[string]string{"tier": "ssd"})
+	}
+	cluster.AddLabelsStore(4, 0, map[string]string{"tier": "hdd"})
+	region := cluster.AddLeaderRegion(1, 1, 2, 3)
+	tc := NewTieredStorageChecker(cluster)
+
+	// the cold region is not demoted when cold-region-duration is 0.
+	tc.coldness.Observe(region, time.Now().Add(-time.Hour))
+	re.Nil(tc.Check(region))
+
+	cluster.SetColdRegionDuration(time.Minute)
+	re.True(tc.IsCold(region))
+	op := tc.Check(region)
+	re.NotNil(op)
+	re.Equal("hdd", op.AdditionalInfos["tier"])
+	operatorutil.CheckTransferPeerWithLeaderTransfer(re, op, operator.OpReplica, 1, 4)
+
+	// the rules pinning the tier take precedence.
+	re.NoError(cluster.GetRuleManager().SetRule(&placement.Rule{
+		GroupID: "pd", ID: "default", Role: placement.Voter, Count: 3,
+		LabelConstraints: []placement.LabelConstraint{{Key: "tier", Op: placement.In, Values: []string{"ssd"}}},
+	}))
+	re.True(tc.IsPinnedByRule(region))
+	re.Nil(tc.Check(region))
+
+	// the recently accessed region is not demoted.
+	tc.Observe(region.Clone(core.SetReadBytes(1024)))
+	re.False(tc.IsCold(region))
+
+	cluster.RemoveRegion(region)
+	re.Equal(1, tc.GC())
+}
@@ -48,6 +48,12 @@ const (
 	// When a slow store affected more than 30% of total stores, it will trigger evicting.
 	defaultSlowStoreEvictingAffectedStoreRatioThreshold = 0.3
 	defaultMaxMovableHotPeerSize                        = int64(512)
+	defaultStoreWarmupInitialRatio                      = 0.1
+	defaultTieredStorageLabelKey                        = "tier"
+	defaultHotStorageTier                               = "ssd"
+	defaultColdStorageTier                              = "hdd"
+	defaultRecoveryPreemptRatio                         = 0.8
+	defaultStoreThrottlePendingCount                    = 64
 
 	defaultEnableJointConsensus  = true
 	defaultEnableTiKVSplitRegion = true
@@ -67,6 +73,12 @@ const (
 	defaultPatrolRegionInterval    = 10 * time.Millisecond
 	defaultMaxStoreDownTime        = 30 * time.Minute
 	defaultHotRegionsWriteInterval = 10 * time.Minute
+	// defaultStoreThrottleApplyLatency and defaultStoreThrottleSnapshotDuration are the default
+	// thresholds over which a store is regarded as struggling.
+	defaultStoreThrottleApplyLatency     = 500 * time.Millisecond
+	defaultStoreThrottleSnapshotDuration = 10 * time.Minute
+	// defaultBalanceSkewHistoryRetention is the default time to keep the balance skew snapshots.
+	defaultBalanceSkewHistoryRetention = 24 * time.Hour
 	// It means we skip the preparing stage after the 48 hours no matter if the store has finished preparing stage.
 	defaultMaxStorePreparingTime = 48 * time.Hour
 )
@@ -77,6 +89,9 @@ var (
 	DefaultStoreLimit = StoreLimit{AddPeer: 15, RemovePeer: 15}
 	// DefaultTiFlashStoreLimit is the default TiFlash store limit of add peer and remove peer.
 	DefaultTiFlashStoreLimit = StoreLimit{AddPeer: 30, RemovePeer: 30}
+	// DefaultOperatorPriorityWeights is the default weights of the low, normal, high and urgent
+	// priority levels of the waiting operators.
+	DefaultOperatorPriorityWeights = []float64{1.0, 4.0, 9.0, 16.0}
 )
 
 // StoreLimit is the default limit of adding peer and removing peer when putting stores.
@@ -247,6 +262,9 @@ type ScheduleConfig struct {
 	// The day of hot regions data to be reserved. 0 means close.
 	HotRegionsReservedDays uint64 `toml:"hot-regions-reserved-days" json:"hot-regions-reserved-days"`
 
+	// BalanceSkewHistoryRetention is the time to keep the balance skew snapshots of the cluster.
+	BalanceSkewHistoryRetention typeutil.Duration `toml:"balance-skew-history-retention" json:"balance-skew-history-retention"`
+
 	// MaxMovableHotPeerSize is the threshold of region size for balance hot region and split bucket scheduler.
 	// Hot region must be split before moved if it's region size is greater than MaxMovableHotPeerSize.
 	MaxMovableHotPeerSize int64 `toml:"max-movable-hot-peer-size" json:"max-movable-hot-peer-size,omitempty"`
@@ -266,9 +284,51 @@ type ScheduleConfig struct {
 	// v2: which is based on region size by window size.
 	StoreLimitVersion string `toml:"store-limit-version" json:"store-limit-version,omitempty"`
 
+	// StoreWarmupDuration is the warm-up window after a store joins. During the window, the add-peer rate
+	// of the balance operators to the store ramps up gradually from StoreWarmupInitialRatio of its store limit
+	// to the full store limit, while the operators repairing the replicas are not throttled.
+	// 0 means the store limit is applied in full immediately.
+	StoreWarmupDuration typeutil.Duration `toml:"store-warmup-duration" json:"store-warmup-duration"`
+	// StoreWarmupInitialRatio is the ratio of the store limit which a store can take at the beginning of the warm-up window.
+	StoreWarmupInitialRatio float64 `toml:"store-warmup-initial-ratio" json:"store-warmup-initial-ratio"`
+
+	// TieredStorageLabelKey is the store label key which tells the storage tier of the stores.
+	TieredStorageLabelKey string `toml:"tiered-storage-label-key" json:"tiered-storage-label-key"`
+	// HotStorageTier is the label value of the fast storage tier, which the hot regions are promoted to.
+	HotStorageTier string `toml:"hot-storage-tier" json:"hot-storage-tier"`
+	// ColdStorageTier is the label value of the slow storage tier, which the cold regions are demoted to.
+	ColdStorageTier string `toml:"cold-storage-tier" json:"cold-storage-tier"`
+	// ColdRegionDuration is how long a region is not accessed before it is regarded as cold and demoted
+	// to the cold storage tier. 0 means the regions are never demoted.
+	ColdRegionDuration typeutil.Duration `toml:"cold-region-duration" json:"cold-region-duration"`
+
 	// HaltScheduling is the option to halt the scheduling. Once it's on, PD will halt the scheduling,
 	// and any other scheduling configs will be ignored.
 	HaltScheduling bool `toml:"halt-scheduling" json:"halt-scheduling,string,omitempty"`
+
+	// EnableSchedulingDryRun is the option to only record the operators computed by the schedulers and
+	// the checkers instead of dispatching them to the stores. The operators created by the admin are
+	// still dispatched.
+	EnableSchedulingDryRun bool `toml:"enable-scheduling-dry-run" json:"enable-scheduling-dry-run,string"`
+
+	// OperatorPriorityWeights is the weights of the low, normal, high and urgent priority levels, which
+	// decide how likely the waiting operators of each level are promoted.
+	OperatorPriorityWeights []float64 `toml:"operator-priority-weights" json:"operator-priority-weights"`
+	// RecoveryPreemptRatio is the ratio of the schedule limits. Once the count of the operators of any
+	// kind reaches it, the waiting recovery operators created by the checkers are promoted before the
+	// balance operators.
+	RecoveryPreemptRatio float64 `toml:"recovery-preempt-ratio" json:"recovery-preempt-ratio"`
+
+	// EnableStoreLatencyThrottle is the option to lower the store limit of the stores which are struggling,
+	// which is told by the latency and the pending commands reported by the store heartbeats.
+	EnableStoreLatencyThrottle bool `toml:"enable-store-latency-throttle" json:"enable-store-latency-throttle,string"`
+	// StoreThrottleApplyLatency is the raftstore latency over which a store is throttled. 0 means disabled.
+	StoreThrottleApplyLatency typeutil.Duration `toml:"store-throttle-apply-latency" json:"store-throttle-apply-latency"`
+	// StoreThrottleSnapshotDuration is the snapshot duration over which a store is throttled. 0 means disabled.
+	StoreThrottleSnapshotDuration typeutil.Duration `toml:"store-throttle-snapshot-duration" json:"store-throttle-snapshot-duration"`
+	// StoreThrottlePendingCount is the count of the pending peers and snapshots over which a store is
+	// throttled. 0 means disabled.
+	StoreThrottlePendingCount uint64 `toml:"store-throttle-pending-count" json:"store-throttle-pending-count"`
 }
 
 // Clone returns a cloned scheduling configuration.
@@ -285,6 +345,7 @@ func (c *ScheduleConfig) Clone() *ScheduleConfig {
 	cfg.StoreLimit = storeLimit
 	cfg.Schedulers = schedulers
 	cfg.SchedulersPayload = nil
+	cfg.OperatorPriorityWeights = append(c.OperatorPriorityWeights[:0:0], c.OperatorPriorityWeights...)
 	return &cfg
 }
 
@@ -305,6 +366,7 @@ func (c *ScheduleConfig) Adjust(meta *configutil.ConfigMetaData, reloading bool)
 	configutil.AdjustDuration(&c.MaxStoreDownTime, defaultMaxStoreDownTime)
 	configutil.AdjustDuration(&c.HotRegionsWriteInterval, defaultHotRegionsWriteInterval)
 	configutil.AdjustDuration(&c.MaxStorePreparingTime, defaultMaxStorePreparingTime)
+	configutil.AdjustDuration(&c.BalanceSkewHistoryRetention, defaultBalanceSkewHistoryRetention)
 	if !meta.IsDefined("leader-schedule-limit") {
 		configutil.AdjustUint64(&c.LeaderScheduleLimit, defaultLeaderScheduleLimit)
 	}
@@ -397,6 +459,29 @@ func (c *ScheduleConfig) Adjust(meta *configutil.ConfigMetaData, reloading bool)
 	if !meta.IsDefined("slow-store-evicting-affected-store-ratio-threshold") {
 		configutil.AdjustFloat64(&c.SlowStoreEvictingAffectedStoreRatioThreshold, defaultSlowStoreEvictingAffectedStoreRatioThreshold)
 	}
+
+	if !meta.IsDefined("store-warmup-initial-ratio") {
+		configutil.AdjustFloat64(&c.StoreWarmupInitialRatio, defaultStoreWarmupInitialRatio)
+	}
+
+	configutil.AdjustString(&c.TieredStorageLabelKey, defaultTieredStorageLabelKey)
+	configutil.AdjustString(&c.HotStorageTier, defaultHotStorageTier)
+	configutil.AdjustString(&c.ColdStorageTier, defaultColdStorageTier)
+	if len(c.OperatorPriorityWeights) == 0 {
+		c.OperatorPriorityWeights = append([]float64(nil), DefaultOperatorPriorityWeights...)
+	}
+	if !meta.IsDefined("recovery-preempt-ratio") {
+		configutil.AdjustFloat64(&c.RecoveryPreemptRatio, defaultRecoveryPreemptRatio)
+	}
+	if !meta.IsDefined("store-throttle-apply-latency") {
+		configutil.AdjustDuration(&c.StoreThrottleApplyLatency, defaultStoreThrottleApplyLatency)
+	}
+	if !meta.IsDefined("store-throttle-snapshot-duration") {
+		configutil.AdjustDuration(&c.StoreThrottleSnapshotDuration, defaultStoreThrottleSnapshotDuration)
+	}
+	if !meta.IsDefined("store-throttle-pending-count") {
+		configutil.AdjustUint64(&c.StoreThrottlePendingCount, defaultStoreThrottlePendingCount)
+	}
 	return c.Validate()
 }
 
@@ -473,6 +558,23 @@ func (c *ScheduleConfig) Validate() error {
 	if c.SlowStoreEvictingAffectedStoreRatioThreshold == 0 {
 		return errors.Errorf("slow-store-evicting-affected-store-ratio-threshold is not set")
 	}
+	if c.StoreWarmupInitialRatio <= 0 || c.StoreWarmupInitialRatio > 1 {
+		return errors.New("store-warmup-initial-ratio should be larger than 0 and not larger than 1")
+	}
+	if c.HotStorageTier == c.ColdStorageTier {
+		return errors.New("hot-storage-tier and cold-storage-tier should be different")
+	}
+	if len(c.OperatorPriorityWeights) != len(DefaultOperatorPriorityWeights) {
+		return errors.Errorf("operator-priority-weights should have %d weights", len(DefaultOperatorPriorityWeights))
+	}
+	for _, weight := range c.OperatorPriorityWeights {
+		if weight <= 0 {
+			return errors.New("operator-priority-weights should be positive")
+		}
+	}
+	if c.RecoveryPreemptRatio <= 0 || c.RecoveryPreemptRatio > 1 {
+		return errors.New("recovery-preempt-ratio should be larger than 0 and not larger than 1")
+	}
 	return nil
 }
 
@@ -560,6 +662,18 @@ type ReplicationConfig struct {
 	// EnablePlacementRuleCache controls whether use cache during rule checker
 	EnablePlacementRulesCache bool `toml:"enable-placement-rules-cache" json:"enable-placement-rules-cache,string"`
 
+	// EnableUnsatisfiableRuleFallback controls whether the ranges covered by a rule which can not match any store
+	// fall back to the default rule until the topology can satisfy the rule again.
+	EnableUnsatisfiableRuleFallback bool `toml:"enable-unsatisfiable-rule-fallback" json:"enable-unsatisfiable-rule-fallback,string"`
+
+	// EnableRuleTopologyValidation controls whether to reject the rules referring to a location label or a label key
+	// which is not carried by any store.
+	EnableRuleTopologyValidation bool `toml:"enable-rule-topology-validation" json:"enable-rule-topology-validation,string"`
+
+	// RuleTombstoneGracePeriod is how long a deleted placement rule is kept as a tombstone, during which it can be
+	// restored. The rules are removed immediately if it's zero.
+	RuleTombstoneGracePeriod typeutil.Duration `toml:"rule-tombstone-grace-period" json:"rule-tombstone-grace-period"`
+
 	// IsolationLevel is used to isolate replicas explicitly and forcibly if it's not empty.
 	// Its value must be empty or one of LocationLabels.
 	// Example:
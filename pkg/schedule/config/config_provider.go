@@ -112,8 +112,24 @@ type SharedConfigProvider interface {
 	GetRegionScoreFormulaVersion() string
 	GetSchedulerMaxWaitingOperator() uint64
 	GetStoreLimitByType(uint64, storelimit.Type) float64
+	GetStoreWarmupDuration() time.Duration
+	GetStoreWarmupInitialRatio() float64
+	GetTieredStorageLabelKey() string
+	GetHotStorageTier() string
+	GetColdStorageTier() string
+	GetColdRegionDuration() time.Duration
+	IsSchedulingDryRunEnabled() bool
+	GetOperatorPriorityWeights() []float64
+	GetRecoveryPreemptRatio() float64
+	GetLeaderScheduleLimit() uint64
+	GetRegionScheduleLimit() uint64
+	GetHotRegionScheduleLimit() uint64
+	GetReplicaScheduleLimit() uint64
 	IsWitnessAllowed() bool
 	IsPlacementRulesCacheEnabled() bool
+	IsUnsatisfiableRuleFallbackEnabled() bool
+	IsRuleTopologyValidationEnabled() bool
+	GetRuleTombstoneGracePeriod() time.Duration
 	SetHaltScheduling(bool, string)
 
 	// for test purpose
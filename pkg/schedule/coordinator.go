@@ -161,6 +161,8 @@ func (c *Coordinator) PatrolRegions() {
 		if len(key) == 0 {
 			patrolCheckRegionsGauge.Set(time.Since(start).Seconds())
 			start = time.Now()
+			// All the regions have been checked, the coldness of the regions which are gone can be dropped.
+			c.checkers.GetTieredStorageChecker().GC()
 		}
 		failpoint.Inject("break-patrol", func() {
 			failpoint.Break()
@@ -568,13 +568,15 @@ func (f *StoreStateFilter) Target(conf config.SharedConfigProvider, store *core.
 
 // labelConstraintFilter is a filter that selects stores satisfy the constraints.
 type labelConstraintFilter struct {
-	scope       string
-	constraints []placement.LabelConstraint
+	scope        string
+	constraints  []placement.LabelConstraint
+	alternatives [][]placement.LabelConstraint
 }
 
-// NewLabelConstraintFilter creates a filter that selects stores satisfy the constraints.
-func NewLabelConstraintFilter(scope string, constraints []placement.LabelConstraint) Filter {
-	return labelConstraintFilter{scope: scope, constraints: constraints}
+// NewLabelConstraintFilter creates a filter that selects stores satisfy the constraints,
+// and any of the alternative constraint sets if there are.
+func NewLabelConstraintFilter(scope string, constraints []placement.LabelConstraint, alternatives ...[]placement.LabelConstraint) Filter {
+	return labelConstraintFilter{scope: scope, constraints: constraints, alternatives: alternatives}
 }
 
 // Scope returns the scheduler or the checker which the filter acts on.
@@ -589,7 +591,7 @@ func (f labelConstraintFilter) Type() filterType {
 
 // Source filters stores when select them as schedule source.
 func (f labelConstraintFilter) Source(conf config.SharedConfigProvider, store *core.StoreInfo) *plan.Status {
-	if placement.MatchLabelConstraints(store, f.constraints) {
+	if placement.MatchLabelConstraintSets(store, f.constraints, f.alternatives) {
 		return statusOK
 	}
 	return statusStoreNotMatchRule
@@ -597,7 +599,7 @@ func (f labelConstraintFilter) Source(conf config.SharedConfigProvider, store *c
 
 // Target filters stores when select them as schedule target.
 func (f labelConstraintFilter) Target(_ config.SharedConfigProvider, store *core.StoreInfo) *plan.Status {
-	if placement.MatchLabelConstraints(store, f.constraints) {
+	if placement.MatchLabelConstraintSets(store, f.constraints, f.alternatives) {
 		return statusOK
 	}
 	return statusStoreNotMatchRule
@@ -159,7 +159,7 @@ func TestRuleFitFilterWithPlacementRule(t *testing.T) {
 	testCluster := mockcluster.NewCluster(ctx, opt)
 	testCluster.SetEnablePlacementRules(true)
 	ruleManager := testCluster.RuleManager
-	ruleManager.DeleteRule("pd", "default")
+	ruleManager.DeleteRule("pd", "default", true)
 	err := ruleManager.SetRules([]*placement.Rule{
 		{
 			GroupID: "test",
@@ -0,0 +1,123 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// SetRegionSetInformer sets the informer of the regions, which is required by
+// DryMatch.
+func (l *RegionLabeler) SetRegionSetInformer(informer core.RegionSetInformer) {
+	l.Lock()
+	defer l.Unlock()
+	l.regionSetInformer = informer
+}
+
+// DryMatch returns the sorted IDs of the regions the rule would match if it's
+// set, against the current regions. The rule is checked like SetLabelRule but
+// neither stored nor changed. The regions are matched in the same way as
+// getMatchedRules:
+//   - a key-range rule matches the regions inside any of its ranges,
+//   - a key-prefix rule matches the regions whose start key has any prefix,
+//   - a region-id rule matches the existing regions with any of the IDs,
+//   - a hot-region rule matches the existing regions hot for any threshold.
+func (l *RegionLabeler) DryMatch(rule *LabelRule) ([]uint64, error) {
+	// check a copy, since checkAndAdjust adjusts the rule in place.
+	r := *rule
+	r.Labels = append([]RegionLabel(nil), rule.Labels...)
+	if err := r.checkAndAdjust(); err != nil {
+		return nil, err
+	}
+	l.RLock()
+	informer, hotRegionsFunc := l.regionSetInformer, l.hotRegionsFunc
+	l.RUnlock()
+	if informer == nil {
+		return nil, errors.New("the regions are not available to the labeler")
+	}
+
+	matched := make(map[uint64]struct{})
+	switch r.RuleType {
+	case KeyRange:
+		for _, kr := range r.Data.([]*KeyRangeRule) {
+			for _, region := range informer.ScanRegions(kr.StartKey, kr.EndKey, 0) {
+				if isRegionInRange(region, kr.StartKey, kr.EndKey) {
+					matched[region.GetID()] = struct{}{}
+				}
+			}
+		}
+	case KeyPrefix:
+		for _, prefix := range r.prefixes {
+			for _, region := range informer.ScanRegions(prefix, prefixEnd(prefix), 0) {
+				if bytes.HasPrefix(region.GetStartKey(), prefix) {
+					matched[region.GetID()] = struct{}{}
+				}
+			}
+		}
+	case RegionID:
+		for _, id := range r.Data.([]uint64) {
+			if informer.GetRegion(id) != nil {
+				matched[id] = struct{}{}
+			}
+		}
+	case HotRegion:
+		if hotRegionsFunc == nil {
+			break
+		}
+		for _, t := range r.Data.([]*HotRegionThreshold) {
+			for _, id := range hotRegionsFunc(t.rwType, t.MinHotDegree) {
+				if informer.GetRegion(id) != nil {
+					matched[id] = struct{}{}
+				}
+			}
+		}
+	}
+	ids := make([]uint64, 0, len(matched))
+	for id := range matched {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// isRegionInRange checks whether the region is inside [startKey, endKey), an
+// empty end key means the end of the key space.
+func isRegionInRange(region *core.RegionInfo, startKey, endKey []byte) bool {
+	if bytes.Compare(region.GetStartKey(), startKey) < 0 {
+		return false
+	}
+	if len(endKey) == 0 {
+		return true
+	}
+	regionEnd := region.GetEndKey()
+	return len(regionEnd) > 0 && bytes.Compare(regionEnd, endKey) <= 0
+}
+
+// prefixEnd returns the first key without the prefix, it's nil if there is
+// no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
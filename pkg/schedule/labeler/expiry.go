@@ -0,0 +1,138 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxExpiredLabelRecords is the max number of the expired label records
+	// kept in memory, the oldest ones are discarded beyond it.
+	maxExpiredLabelRecords = 1024
+	// expiryWebhookTimeout is the timeout of notifying the webhook.
+	expiryWebhookTimeout = 5 * time.Second
+)
+
+var expiredLabelCounter = LabelerEventCounter.WithLabelValues("labels", "expired")
+
+// ExpiredLabels records the labels of a rule removed since they expired.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type ExpiredLabels struct {
+	RuleID string        `json:"rule_id"`
+	Labels []RegionLabel `json:"labels"`
+	// RuleRemoved is true if the rule is removed since no label is left.
+	RuleRemoved bool      `json:"rule_removed"`
+	RemovedAt   time.Time `json:"removed_at"`
+}
+
+// ValidateExpiryWebhook checks the URL of the expiry webhook, the empty one
+// means no webhook.
+func ValidateExpiryWebhook(webhook string) error {
+	if webhook == "" {
+		return nil
+	}
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid expiry webhook %s", webhook))
+	}
+	return nil
+}
+
+// SetExpiryWebhook sets the URL which the expired labels are posted to in
+// JSON, the empty one disables the notification.
+func (l *RegionLabeler) SetExpiryWebhook(webhook string) error {
+	if err := ValidateExpiryWebhook(webhook); err != nil {
+		return err
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.expiryWebhook = webhook
+	return nil
+}
+
+// GetExpiredLabels returns the recent expired labels in the order of the
+// removal, the expired rules are checked before.
+func (l *RegionLabeler) GetExpiredLabels() []*ExpiredLabels {
+	l.checkAndClearExpiredLabels()
+	l.RLock()
+	defer l.RUnlock()
+	return append([]*ExpiredLabels(nil), l.expiredLabels...)
+}
+
+// removeExpiredLabels removes the expired labels of the rule like
+// checkAndRemoveExpireLabels, and records the removed ones. It must be called
+// with the lock held.
+func (l *RegionLabeler) removeExpiredLabels(rule *LabelRule, now time.Time) bool {
+	before := rule.Labels
+	if !rule.checkAndRemoveExpireLabels(now) {
+		return false
+	}
+	left := make(map[RegionLabel]struct{}, len(rule.Labels))
+	for _, label := range rule.Labels {
+		left[label] = struct{}{}
+	}
+	record := &ExpiredLabels{RuleID: rule.ID, RuleRemoved: len(rule.Labels) == 0, RemovedAt: now}
+	for _, label := range before {
+		if _, ok := left[label]; !ok {
+			record.Labels = append(record.Labels, label)
+		}
+	}
+	expiredLabelCounter.Add(float64(len(record.Labels)))
+	log.Info("region labels expired", zap.String("rule-id", rule.ID), zap.Any("labels", record.Labels), zap.Bool("rule-removed", record.RuleRemoved))
+	l.expiredLabels = append(l.expiredLabels, record)
+	if n := len(l.expiredLabels) - maxExpiredLabelRecords; n > 0 {
+		l.expiredLabels = append(l.expiredLabels[:0:0], l.expiredLabels[n:]...)
+	}
+	if l.expiryWebhook != "" {
+		go l.notifyExpiryWebhook(l.expiryWebhook, record)
+	}
+	return true
+}
+
+func (l *RegionLabeler) notifyExpiryWebhook(webhook string, record *ExpiredLabels) {
+	data, err := json.Marshal([]*ExpiredLabels{record})
+	if err != nil {
+		log.Warn("failed to marshal the expired labels", zap.String("rule-id", record.RuleID), errs.ZapError(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(l.ctx, expiryWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(data))
+	if err != nil {
+		log.Warn("failed to create the request of the expiry webhook", zap.String("webhook", webhook), errs.ZapError(err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		log.Warn("failed to notify the expiry webhook", zap.String("webhook", webhook), zap.String("rule-id", record.RuleID), errs.ZapError(err))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Warn("the expiry webhook responds with an error", zap.String("webhook", webhook), zap.String("rule-id", record.RuleID), zap.Int("status", resp.StatusCode))
+	}
+}
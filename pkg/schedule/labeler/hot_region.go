@@ -0,0 +1,147 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/utils/logutil"
+	"go.uber.org/zap"
+)
+
+// hotRegionRefreshInterval is the interval to refresh the regions matched by
+// the rules of the type `HotRegion`.
+var hotRegionRefreshInterval = 10 * time.Second
+
+// HotRegionThreshold is the hotness threshold of a rule of the type
+// `HotRegion`, a region is hot if its hot degree of the kind reaches
+// MinHotDegree.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type HotRegionThreshold struct {
+	Kind         string `json:"kind"`
+	MinHotDegree int    `json:"min_hot_degree"`
+	rwType       utils.RWType
+}
+
+// HotRegionsFunc returns the IDs of the regions whose hot degree of the kind
+// reaches minHotDegree, according to the hot-region statistics.
+type HotRegionsFunc func(kind utils.RWType, minHotDegree int) []uint64
+
+// SetHotRegionsFunc sets the source of the hot regions, which is required by
+// the rules of the type `HotRegion`, they match no region without it.
+func (l *RegionLabeler) SetHotRegionsFunc(f HotRegionsFunc) {
+	l.Lock()
+	defer l.Unlock()
+	l.hotRegionsFunc = f
+}
+
+func (l *RegionLabeler) doRefreshHotRegions() {
+	defer logutil.LogPanic()
+
+	ticker := time.NewTicker(hotRegionRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.refreshHotRegions()
+		case <-l.ctx.Done():
+			log.Info("region labeler hot region refresh stopped")
+			return
+		}
+	}
+}
+
+// refreshHotRegions updates the regions matched by the rules of the type
+// `HotRegion` with the current hot-region statistics, so the labels are added
+// to the regions becoming hot and removed from the ones cooling down.
+func (l *RegionLabeler) refreshHotRegions() {
+	l.RLock()
+	f := l.hotRegionsFunc
+	var rules []*LabelRule
+	for _, rule := range l.labelRules {
+		if rule.RuleType == HotRegion {
+			rules = append(rules, rule)
+		}
+	}
+	l.RUnlock()
+
+	// collect the statistics without holding the lock, it may take a while.
+	hotRegions := make(map[string]map[uint64]struct{}, len(rules))
+	if f != nil {
+		for _, rule := range rules {
+			ids := make(map[uint64]struct{})
+			for _, t := range rule.Data.([]*HotRegionThreshold) {
+				for _, id := range f(t.rwType, t.MinHotDegree) {
+					ids[id] = struct{}{}
+				}
+			}
+			hotRegions[rule.ID] = ids
+		}
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	for _, rule := range rules {
+		// skip the rule changed during the collection, it's refreshed next time.
+		if l.labelRules[rule.ID] != rule {
+			delete(hotRegions, rule.ID)
+		}
+	}
+	if reflect.DeepEqual(hotRegions, l.hotRegions) {
+		return
+	}
+	l.hotRegions = hotRegions
+	l.buildRangeList()
+	log.Debug("hot regions of label rules refreshed", zap.Int("rule-count", len(hotRegions)))
+}
+
+// initHotRegionThresholdsFromLabelRuleData inits the hotness thresholds from
+// `LabelRule.Data`.
+func initHotRegionThresholdsFromLabelRuleData(data interface{}) ([]*HotRegionThreshold, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %T", data))
+	}
+	if len(items) == 0 {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs("no hot region thresholds")
+	}
+	thresholds := make([]*HotRegionThreshold, 0, len(items))
+	for _, item := range items {
+		m := item.(map[string]interface{})
+		t := &HotRegionThreshold{Kind: m["kind"].(string)}
+		switch t.Kind {
+		case utils.Read.String():
+			t.rwType = utils.Read
+		case utils.Write.String():
+			t.rwType = utils.Write
+		default:
+			return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid hot region kind: %s", t.Kind))
+		}
+		// the numbers are decoded from JSON as float64.
+		degree := m["min_hot_degree"].(float64)
+		if degree < 0 || degree != math.Trunc(degree) {
+			return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid min hot degree: %v", degree))
+		}
+		t.MinHotDegree = int(degree)
+		thresholds = append(thresholds, t)
+	}
+	return thresholds, nil
+}
@@ -16,6 +16,7 @@ package labeler
 
 import (
 	"context"
+	"sort"
 	"strings"
 	"time"
 
@@ -24,6 +25,7 @@ import (
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/schedule/rangelist"
 	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"github.com/tikv/pd/pkg/utils/logutil"
 	"github.com/tikv/pd/pkg/utils/syncutil"
 	"go.uber.org/zap"
@@ -34,24 +36,47 @@ type RegionLabeler struct {
 	storage endpoint.RuleStorage
 	syncutil.RWMutex
 	labelRules map[string]*LabelRule
-	rangeList  rangelist.List // sorted LabelRules of the type `KeyRange`
+	rangeList  rangelist.List          // sorted LabelRules of the type `KeyRange`
+	prefixes   *prefixTrie             // LabelRules of the type `KeyPrefix`
+	regionIDs  map[uint64][]*LabelRule // LabelRules of the type `RegionID` and `HotRegion`
 	ctx        context.Context
 	minExpire  *time.Time
+	// revision is increased by every update of the label rules. It's not
+	// persisted but initialized with the current time, so a revision is never
+	// reused after the labeler is recreated, e.g., the leader is changed.
+	revision int64
+	// overlapPolicy resolves the rules matching the same region.
+	overlapPolicy string
+	// regionSetInformer provides the regions for DryMatch, it's optional.
+	regionSetInformer core.RegionSetInformer
+	// hotRegionsFunc provides the hot regions for the rules of the type
+	// `HotRegion`, it's optional.
+	hotRegionsFunc HotRegionsFunc
+	// hotRegions are the IDs of the regions matched by the rules of the type
+	// `HotRegion` by the rule ID, see refreshHotRegions.
+	hotRegions map[string]map[uint64]struct{}
+	// expiredLabels records the recent expired labels, see removeExpiredLabels.
+	expiredLabels []*ExpiredLabels
+	// expiryWebhook is the URL notified of the expired labels, it's optional.
+	expiryWebhook string
 }
 
 // NewRegionLabeler creates a Labeler instance.
 func NewRegionLabeler(ctx context.Context, storage endpoint.RuleStorage, gcInterval time.Duration) (*RegionLabeler, error) {
 	l := &RegionLabeler{
-		storage:    storage,
-		labelRules: make(map[string]*LabelRule),
-		ctx:        ctx,
-		minExpire:  nil,
+		storage:       storage,
+		labelRules:    make(map[string]*LabelRule),
+		ctx:           ctx,
+		minExpire:     nil,
+		revision:      time.Now().UnixNano(),
+		overlapPolicy: MergeLabels,
 	}
 
 	if err := l.loadRules(); err != nil {
 		return nil, err
 	}
 	go l.doGC(gcInterval)
+	go l.doRefreshHotRegions()
 	return l, nil
 }
 
@@ -84,15 +109,20 @@ func (l *RegionLabeler) checkAndClearExpiredLabels() {
 	deleted := false
 
 	for key, rule := range l.labelRules {
-		if !rule.checkAndRemoveExpireLabels(now) {
+		if !l.removeExpiredLabels(rule, now) {
 			continue
 		}
+		l.revision++
 		if len(rule.Labels) == 0 {
-			err = l.storage.DeleteRegionRule(key)
+			err = l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+				return l.storage.DeleteRegionRule(txn, key)
+			})
 			delete(l.labelRules, key)
 			deleted = true
 		} else {
-			err = l.storage.SaveRegionRule(key, rule)
+			err = l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+				return l.storage.SaveRegionRule(txn, key, rule)
+			})
 		}
 		if err != nil {
 			log.Error("failed to save rule expired label rule", zap.String("rule-key", key), zap.Error(err))
@@ -124,7 +154,9 @@ func (l *RegionLabeler) loadRules() error {
 		return err
 	}
 	for _, d := range toDelete {
-		if err = l.storage.DeleteRegionRule(d); err != nil {
+		if err = l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+			return l.storage.DeleteRegionRule(txn, d)
+		}); err != nil {
 			return err
 		}
 	}
@@ -132,21 +164,69 @@ func (l *RegionLabeler) loadRules() error {
 	return nil
 }
 
+// buildRangeList rebuilds the indexes of the rules, including the range list,
+// the prefix trie and the region IDs.
 func (l *RegionLabeler) buildRangeList() {
 	builder := rangelist.NewBuilder()
+	prefixes := newPrefixTrie()
+	regionIDs := make(map[uint64][]*LabelRule)
 	l.minExpire = nil
 	for _, rule := range l.labelRules {
 		if l.minExpire == nil || rule.expireBefore(*l.minExpire) {
 			l.minExpire = rule.minExpire
 		}
-		if rule.RuleType == KeyRange {
+		switch rule.RuleType {
+		case KeyRange:
 			rs := rule.Data.([]*KeyRangeRule)
 			for _, r := range rs {
 				builder.AddItem(r.StartKey, r.EndKey, rule)
 			}
+		case KeyPrefix:
+			for _, prefix := range rule.prefixes {
+				prefixes.insert(prefix, rule)
+			}
+		case RegionID:
+			for _, id := range rule.Data.([]uint64) {
+				regionIDs[id] = append(regionIDs[id], rule)
+			}
+		case HotRegion:
+			for id := range l.hotRegions[rule.ID] {
+				regionIDs[id] = append(regionIDs[id], rule)
+			}
 		}
 	}
+	for _, rules := range regionIDs {
+		sort.Slice(rules, func(i, j int) bool {
+			if rules[i].Index != rules[j].Index {
+				return rules[i].Index < rules[j].Index
+			}
+			return rules[i].ID < rules[j].ID
+		})
+	}
 	l.rangeList = builder.Build()
+	l.prefixes = prefixes
+	l.regionIDs = regionIDs
+}
+
+// Restore runs restore to overwrite the label rules in storage, and reloads
+// them. The write lock is held during the whole process, so the concurrent
+// updates are serialized with it.
+func (l *RegionLabeler) Restore(restore func() error) error {
+	l.Lock()
+	defer l.Unlock()
+	if err := restore(); err != nil {
+		return err
+	}
+	current := l.labelRules
+	l.labelRules = make(map[string]*LabelRule)
+	if err := l.loadRules(); err != nil {
+		l.labelRules = current
+		l.buildRangeList()
+		return err
+	}
+	l.revision++
+	log.Info("label rules reloaded", zap.Int("rule-count", len(l.labelRules)))
+	return nil
 }
 
 // GetSplitKeys returns all split keys in the range (start, end).
@@ -192,15 +272,20 @@ func (l *RegionLabeler) getAndCheckRule(id string, now time.Time) *LabelRule {
 	if !ok {
 		return nil
 	}
-	if !rule.checkAndRemoveExpireLabels(now) {
+	if !l.removeExpiredLabels(rule, now) {
 		return rule
 	}
+	l.revision++
 	if len(rule.Labels) == 0 {
-		l.storage.DeleteRegionRule(id)
+		l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+			return l.storage.DeleteRegionRule(txn, id)
+		})
 		delete(l.labelRules, id)
 		return nil
 	}
-	l.storage.SaveRegionRule(id, rule)
+	l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+		return l.storage.SaveRegionRule(txn, id, rule)
+	})
 	return rule
 }
 
@@ -211,10 +296,13 @@ func (l *RegionLabeler) SetLabelRule(rule *LabelRule) error {
 	}
 	l.Lock()
 	defer l.Unlock()
-	if err := l.storage.SaveRegionRule(rule.ID, rule); err != nil {
+	if err := l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+		return l.storage.SaveRegionRule(txn, rule.ID, rule)
+	}); err != nil {
 		return err
 	}
 	l.labelRules[rule.ID] = rule
+	l.revision++
 	l.buildRangeList()
 	return nil
 }
@@ -226,46 +314,134 @@ func (l *RegionLabeler) DeleteLabelRule(id string) error {
 	if _, ok := l.labelRules[id]; !ok {
 		return errs.ErrRegionRuleNotFound.FastGenByArgs(id)
 	}
-	if err := l.storage.DeleteRegionRule(id); err != nil {
+	if err := l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+		return l.storage.DeleteRegionRule(txn, id)
+	}); err != nil {
 		return err
 	}
 	delete(l.labelRules, id)
+	l.revision++
 	l.buildRangeList()
 	return nil
 }
 
 // Patch updates multiple region rules in a batch.
 func (l *RegionLabeler) Patch(patch LabelRulePatch) error {
-	for _, rule := range patch.SetRules {
-		if err := rule.checkAndAdjust(); err != nil {
-			return err
-		}
+	if err := patch.checkAndAdjust(); err != nil {
+		return err
 	}
+	l.Lock()
+	defer l.Unlock()
+	return l.applyPatch(patch)
+}
 
-	// save to storage
-	for _, key := range patch.DeleteRules {
-		if err := l.storage.DeleteRegionRule(key); err != nil {
-			return err
-		}
+// PatchCAS updates multiple region rules in a batch only if the revision of
+// the label rules is still expectedRevision, otherwise it returns
+// ErrRegionRuleRevision. It's used to read-modify-write the rules safely
+// with GetRevision.
+func (l *RegionLabeler) PatchCAS(patch LabelRulePatch, expectedRevision int64) error {
+	if err := patch.checkAndAdjust(); err != nil {
+		return err
 	}
-	for _, rule := range patch.SetRules {
-		if err := l.storage.SaveRegionRule(rule.ID, rule); err != nil {
-			return err
-		}
+	l.Lock()
+	defer l.Unlock()
+	if l.revision != expectedRevision {
+		return errs.ErrRegionRuleRevision.FastGenByArgs(expectedRevision, l.revision)
 	}
+	return l.applyPatch(patch)
+}
 
-	// update inmemory states.
+// GetRevision returns the revision of the label rules, which is changed by
+// every update.
+func (l *RegionLabeler) GetRevision() int64 {
+	l.RLock()
+	defer l.RUnlock()
+	return l.revision
+}
+
+// PatchWith updates multiple region rules in a batch like Patch, but the
+// changes are saved by save, which saves them in the same transaction as the
+// other changes of the caller, e.g., the placement rules. The rules in memory
+// are only updated once save succeeds.
+func (l *RegionLabeler) PatchWith(patch LabelRulePatch, save func(ops ...func(txn kv.Txn) error) error) error {
+	if err := patch.checkAndAdjust(); err != nil {
+		return err
+	}
 	l.Lock()
 	defer l.Unlock()
+	if err := save(l.patchOps(patch)...); err != nil {
+		return err
+	}
+	l.commitPatch(patch)
+	return nil
+}
 
+func (l *RegionLabeler) applyPatch(patch LabelRulePatch) error {
+	// save to storage in a transaction, so the patch is applied as a whole.
+	if err := l.storage.RunInTxn(l.ctx, func(txn kv.Txn) error {
+		for _, op := range l.patchOps(patch) {
+			if err := op(txn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	l.commitPatch(patch)
+	return nil
+}
+
+// patchOps returns the operations saving the patch to the storage.
+func (l *RegionLabeler) patchOps(patch LabelRulePatch) []func(txn kv.Txn) error {
+	ops := make([]func(txn kv.Txn) error, 0, len(patch.DeleteRules)+len(patch.SetRules))
+	for _, key := range patch.DeleteRules {
+		key := key
+		ops = append(ops, func(txn kv.Txn) error { return l.storage.DeleteRegionRule(txn, key) })
+	}
+	for _, rule := range patch.SetRules {
+		rule := rule
+		ops = append(ops, func(txn kv.Txn) error { return l.storage.SaveRegionRule(txn, rule.ID, rule) })
+	}
+	return ops
+}
+
+// commitPatch updates the in-memory states with the saved patch.
+func (l *RegionLabeler) commitPatch(patch LabelRulePatch) {
 	for _, key := range patch.DeleteRules {
 		delete(l.labelRules, key)
 	}
 	for _, rule := range patch.SetRules {
 		l.labelRules[rule.ID] = rule
 	}
+	l.revision++
 	l.buildRangeList()
-	return nil
+}
+
+// DeleteRulesByLabel removes all the rules that have the label with the key and
+// value, and returns the IDs of them. The rules are removed in a transaction,
+// so either all of them are removed or none of them.
+func (l *RegionLabeler) DeleteRulesByLabel(key, value string) ([]string, error) {
+	l.Lock()
+	defer l.Unlock()
+	var ids []string
+	for id, rule := range l.labelRules {
+		for _, label := range rule.Labels {
+			if label.Key == key && label.Value == value {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	sort.Strings(ids)
+	if err := l.applyPatch(LabelRulePatch{DeleteRules: ids}); err != nil {
+		return nil, err
+	}
+	log.Info("label rules deleted by label", zap.String("key", key), zap.String("value", value), zap.Strings("ids", ids))
+	return ids, nil
 }
 
 // GetRegionLabel returns the label of the region for a key.
@@ -274,27 +450,44 @@ func (l *RegionLabeler) GetRegionLabel(region *core.RegionInfo, key string) stri
 	l.RLock()
 	defer l.RUnlock()
 	now := time.Now()
-	value, index := "", -1
-	// search ranges
-	if i, data := l.rangeList.GetData(region.GetStartKey(), region.GetEndKey()); i != -1 {
-		for _, rule := range data {
-			r := rule.(*LabelRule)
-			if r.Index <= index && value != "" {
+	value := ""
+	// the rules are in the order of being applied, the latter takes precedence.
+	for _, r := range l.getEffectiveRules(region, now) {
+		for _, l := range r.Labels {
+			if l.expireBefore(now) {
 				continue
 			}
-			for _, l := range r.Labels {
-				if l.expireBefore(now) {
-					continue
-				}
-				if l.Key == key {
-					value, index = l.Value, r.Index
-				}
+			if l.Key == key {
+				value = l.Value
 			}
 		}
 	}
 	return value
 }
 
+// getMatchedRules returns the rules of the type `KeyRange` covering the region,
+// the rules of the type `KeyPrefix` matching the start key of the region in
+// the order of the index, and then the rules of the type `RegionID` listing
+// the ID of the region and the rules of the type `HotRegion` the region is hot
+// for, in the order of the index.
+func (l *RegionLabeler) getMatchedRules(region *core.RegionInfo) []*LabelRule {
+	var rules []*LabelRule
+	// search ranges
+	if i, data := l.rangeList.GetData(region.GetStartKey(), region.GetEndKey()); i != -1 {
+		rules = make([]*LabelRule, 0, len(data))
+		for _, rule := range data {
+			rules = append(rules, rule.(*LabelRule))
+		}
+	}
+	// search prefixes
+	if l.prefixes != nil {
+		rules = append(rules, l.prefixes.match(region.GetStartKey())...)
+	}
+	// search region IDs
+	rules = append(rules, l.regionIDs[region.GetID()]...)
+	return rules
+}
+
 // ScheduleDisabled returns true if the region is lablelld with schedule-disabled.
 func (l *RegionLabeler) ScheduleDisabled(region *core.RegionInfo) bool {
 	v := l.GetRegionLabel(region, scheduleOptionLabel)
@@ -306,36 +499,45 @@ func (l *RegionLabeler) ScheduleDisabled(region *core.RegionInfo) bool {
 func (l *RegionLabeler) GetRegionLabels(region *core.RegionInfo) []*RegionLabel {
 	l.RLock()
 	defer l.RUnlock()
-	type valueIndex struct {
-		value string
-		index int
-	}
-	labels := make(map[string]valueIndex)
+	labels := make(map[string]string)
 	now := time.Now()
-	// search ranges
-	if i, data := l.rangeList.GetData(region.GetStartKey(), region.GetEndKey()); i != -1 {
-		for _, rule := range data {
-			r := rule.(*LabelRule)
-			for _, l := range r.Labels {
-				if l.expireBefore(now) {
-					continue
-				}
-				if old, ok := labels[l.Key]; !ok || old.index < r.Index {
-					labels[l.Key] = valueIndex{l.Value, r.Index}
-				}
+	// the rules are in the order of being applied, the latter takes precedence.
+	for _, r := range l.getEffectiveRules(region, now) {
+		for _, l := range r.Labels {
+			if l.expireBefore(now) {
+				continue
 			}
+			labels[l.Key] = l.Value
 		}
 	}
 	result := make([]*RegionLabel, 0, len(labels))
-	for k, l := range labels {
+	for k, v := range labels {
 		result = append(result, &RegionLabel{
 			Key:   k,
-			Value: l.value,
+			Value: v,
 		})
 	}
 	return result
 }
 
+// MakeKeyPrefixes is a helper function to make key prefixes.
+func MakeKeyPrefixes(prefixes ...string) []interface{} {
+	res := make([]interface{}, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		res = append(res, prefix)
+	}
+	return res
+}
+
+// MakeRegionIDs is a helper function to make region IDs.
+func MakeRegionIDs(ids ...uint64) []interface{} {
+	res := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		res = append(res, float64(id))
+	}
+	return res
+}
+
 // MakeKeyRanges is a helper function to make key ranges.
 func MakeKeyRanges(keys ...string) []interface{} {
 	var res []interface{}
@@ -344,3 +546,12 @@ func MakeKeyRanges(keys ...string) []interface{} {
 	}
 	return res
 }
+
+// MakeHotRegionThresholds is a helper function to make hot region thresholds.
+func MakeHotRegionThresholds(thresholds ...HotRegionThreshold) []interface{} {
+	res := make([]interface{}, 0, len(thresholds))
+	for _, t := range thresholds {
+		res = append(res, map[string]interface{}{"kind": t.Kind, "min_hot_degree": float64(t.MinHotDegree)})
+	}
+	return res
+}
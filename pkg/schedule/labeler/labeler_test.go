@@ -19,6 +19,8 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sort"
 	"testing"
 	"time"
@@ -26,6 +28,8 @@ import (
 	"github.com/pingcap/failpoint"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/statistics/utils"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/storage/kv"
 )
@@ -88,6 +92,42 @@ func TestAdjustRule2(t *testing.T) {
 	}
 }
 
+func TestLabelRuleData(t *testing.T) {
+	re := require.New(t)
+	for ruleType, data := range map[string]string{
+		"key-range":  `["7480"]`,
+		"key-prefix": `[{"start_key":"", "end_key":""}]`,
+		"region-id":  `"1"`,
+	} {
+		_, err := NewLabelRuleFromJSON([]byte(fmt.Sprintf(`{"id":"id", "labels": [{"key": "k1", "value": "v1"}], "rule_type":"%s", "data": %s}`, ruleType, data)))
+		re.True(errs.ErrRegionRuleContent.Equal(err))
+		re.Contains(err.Error(), ruleType+" rule requires an array of")
+	}
+	_, err := NewLabelRuleFromJSON([]byte(`{"id":"id", "labels": [{"key": "k1", "value": "v1"}], "rule_type":"unknown", "data": []}`))
+	re.Error(err)
+	_, err = NewLabelRuleFromJSON([]byte(`{"id":"id", "labels": [{"key": "k1", "value": "v1"}], "rule_type":"key-range", "data": [{"start_key":"", "end_key":""}]}`))
+	re.NoError(err)
+
+	// the data constructed in Go is checked in the same way.
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rule := &LabelRule{
+		ID:       "rule",
+		Labels:   []RegionLabel{{Key: "k1", Value: "v1"}},
+		RuleType: KeyRange,
+		Data:     []*KeyRangeRule{{StartKeyHex: "1234", EndKeyHex: "5678"}},
+	}
+	re.NoError(labeler.SetLabelRule(rule))
+	re.Equal([]*KeyRangeRule{{StartKey: []byte{0x12, 0x34}, StartKeyHex: "1234", EndKey: []byte{0x56, 0x78}, EndKeyHex: "5678"}}, labeler.GetLabelRule("rule").Data)
+	// adjusting the rule again keeps it as is.
+	re.NoError(labeler.SetLabelRule(rule))
+	rule.Data = []uint64{1, 2}
+	err = labeler.SetLabelRule(rule)
+	re.Error(err)
+	re.Contains(err.Error(), "key-range rule requires an array of {start_key,end_key}")
+}
+
 func TestGetSetRule(t *testing.T) {
 	re := require.New(t)
 	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
@@ -134,6 +174,126 @@ func TestGetSetRule(t *testing.T) {
 	}
 }
 
+func TestPatchCAS(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rev := labeler.GetRevision()
+	rule1 := &LabelRule{ID: "rule1", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "key-range", Data: MakeKeyRanges("1234", "5678")}
+	re.NoError(labeler.SetLabelRule(rule1))
+	re.NotEqual(rev, labeler.GetRevision())
+
+	// the stale revision is rejected and nothing is changed.
+	patch := LabelRulePatch{
+		SetRules: []*LabelRule{
+			{ID: "rule2", Labels: []RegionLabel{{Key: "k2", Value: "v2"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+		},
+		DeleteRules: []string{"rule1"},
+	}
+	err = labeler.PatchCAS(patch, rev)
+	re.Error(err)
+	re.True(errs.ErrRegionRuleRevision.Equal(err))
+	re.NotNil(labeler.GetLabelRule("rule1"))
+	re.Nil(labeler.GetLabelRule("rule2"))
+
+	// the current revision is accepted.
+	rev = labeler.GetRevision()
+	re.NoError(labeler.PatchCAS(patch, rev))
+	re.Nil(labeler.GetLabelRule("rule1"))
+	re.NotNil(labeler.GetLabelRule("rule2"))
+	re.NotEqual(rev, labeler.GetRevision())
+
+	// the revision can not be reused.
+	re.True(errs.ErrRegionRuleRevision.Equal(labeler.PatchCAS(patch, rev)))
+}
+
+func TestValidatePatch(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rule1 := &LabelRule{ID: "rule1", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "key-range", Data: MakeKeyRanges("1234", "5678")}
+	re.NoError(labeler.SetLabelRule(rule1))
+
+	patch := LabelRulePatch{
+		SetRules: []*LabelRule{
+			{ID: "rule2", Labels: []RegionLabel{{Key: "k2", Value: "v2"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+			{ID: "rule3", Labels: []RegionLabel{{Key: "k3", Value: "v3"}}, RuleType: "key-range", Data: MakeKeyRanges("xyz", "cd12")},
+			{ID: "rule4", Labels: []RegionLabel{{Key: "k4", Value: "v4"}}, RuleType: "unknown", Data: MakeKeyRanges("ab12", "cd12")},
+			{ID: "rule5", Labels: []RegionLabel{{Key: "k5", Value: "v5", TTL: "1x"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+			{ID: "rule2", Labels: []RegionLabel{{Key: "k2", Value: "v2"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+		},
+		DeleteRules: []string{"rule1", "", "rule1"},
+	}
+	problems := ValidatePatch(patch)
+	re.Len(problems, 6)
+	expected := []LabelRuleError{
+		{Operation: PatchSet, Index: 1, RuleID: "rule3"},
+		{Operation: PatchSet, Index: 2, RuleID: "rule4"},
+		{Operation: PatchSet, Index: 3, RuleID: "rule5"},
+		{Operation: PatchSet, Index: 4, RuleID: "rule2"},
+		{Operation: PatchDelete, Index: 1, RuleID: ""},
+		{Operation: PatchDelete, Index: 2, RuleID: "rule1"},
+	}
+	for i, p := range problems {
+		re.Equal(expected[i].Operation, p.Operation)
+		re.Equal(expected[i].Index, p.Index)
+		re.Equal(expected[i].RuleID, p.RuleID)
+		re.NotEmpty(p.Error)
+	}
+	// the rules are not adjusted by the validation.
+	re.IsType([]interface{}{}, patch.SetRules[0].Data)
+
+	// the patch is rejected as a whole and nothing is changed.
+	err = labeler.Patch(patch)
+	re.Error(err)
+	re.True(errs.ErrRegionRuleContent.Equal(err))
+	re.NotNil(labeler.GetLabelRule("rule1"))
+	re.Nil(labeler.GetLabelRule("rule2"))
+
+	// the valid patch is applied.
+	patch = LabelRulePatch{SetRules: patch.SetRules[:1], DeleteRules: patch.DeleteRules[:1]}
+	re.Empty(ValidatePatch(patch))
+	re.NoError(labeler.Patch(patch))
+	re.Nil(labeler.GetLabelRule("rule1"))
+	re.NotNil(labeler.GetLabelRule("rule2"))
+
+	// too many rules can not be saved in a transaction.
+	patch = LabelRulePatch{}
+	for i := 0; i <= maxEtcdTxnOps; i++ {
+		patch.DeleteRules = append(patch.DeleteRules, fmt.Sprintf("rule%d", i))
+	}
+	re.True(errs.ErrRegionRuleContent.Equal(labeler.Patch(patch)))
+	re.NotNil(labeler.GetLabelRule("rule2"))
+}
+
+func TestDeleteRulesByLabel(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rules := []*LabelRule{
+		{ID: "rule1", Labels: []RegionLabel{{Key: "experiment", Value: "e1"}}, RuleType: "key-range", Data: MakeKeyRanges("1234", "5678")},
+		{ID: "rule2", Labels: []RegionLabel{{Key: "k2", Value: "v2"}, {Key: "experiment", Value: "e1"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+		{ID: "rule3", Labels: []RegionLabel{{Key: "experiment", Value: "e2"}}, RuleType: "key-range", Data: MakeKeyRanges("cd12", "ef12")},
+	}
+	for _, rule := range rules {
+		re.NoError(labeler.SetLabelRule(rule))
+	}
+
+	ids, err := labeler.DeleteRulesByLabel("experiment", "e1")
+	re.NoError(err)
+	re.Equal([]string{"rule1", "rule2"}, ids)
+	checkRuleInMemoryAndStoage(re, labeler, "rule1", false)
+	checkRuleInMemoryAndStoage(re, labeler, "rule2", false)
+	checkRuleInMemoryAndStoage(re, labeler, "rule3", true)
+
+	ids, err = labeler.DeleteRulesByLabel("experiment", "e1")
+	re.NoError(err)
+	re.Empty(ids)
+}
+
 func TestIndex(t *testing.T) {
 	re := require.New(t)
 	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
@@ -176,6 +336,110 @@ func TestIndex(t *testing.T) {
 	}
 }
 
+func TestKeyPrefix(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rules := []*LabelRule{
+		{ID: "rule0", Labels: []RegionLabel{{Key: "k1", Value: "v0"}}, RuleType: "key-range", Data: MakeKeyRanges("", "")},
+		{ID: "rule1", Index: 1, Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "key-prefix", Data: MakeKeyPrefixes("12", "ab")},
+		{ID: "rule2", Index: 2, Labels: []RegionLabel{{Key: "k1", Value: "v2"}, {Key: "k2", Value: "v2"}}, RuleType: "key-prefix", Data: MakeKeyPrefixes("1234")},
+		{ID: "rule3", Index: 3, Labels: []RegionLabel{{Key: "k2", Value: "v3"}}, RuleType: "key-prefix", Data: MakeKeyPrefixes("123456", "12345678")},
+	}
+	for _, r := range rules {
+		re.NoError(labeler.SetLabelRule(r))
+	}
+	re.Equal([]string{"123456", "12345678"}, labeler.GetLabelRule("rule3").Data)
+
+	type testCase struct {
+		start, end string
+		labels     map[string]string
+	}
+	testCases := []testCase{
+		{"", "12", map[string]string{"k1": "v0"}},
+		{"12", "1234", map[string]string{"k1": "v1"}},
+		{"1233ff", "1234", map[string]string{"k1": "v1"}},
+		{"1234", "123456", map[string]string{"k1": "v2", "k2": "v2"}},
+		{"123456", "5678", map[string]string{"k1": "v2", "k2": "v3"}},
+		{"ab12", "", map[string]string{"k1": "v1"}},
+		{"cd", "", map[string]string{"k1": "v0"}},
+	}
+	for _, testCase := range testCases {
+		start, _ := hex.DecodeString(testCase.start)
+		end, _ := hex.DecodeString(testCase.end)
+		region := core.NewTestRegionInfo(1, 1, start, end)
+		labels := labeler.GetRegionLabels(region)
+		re.Len(labels, len(testCase.labels))
+		for _, l := range labels {
+			re.Equal(testCase.labels[l.Key], l.Value)
+		}
+		for _, k := range []string{"k1", "k2"} {
+			re.Equal(testCase.labels[k], labeler.GetRegionLabel(region, k))
+		}
+	}
+
+	// the prefixes are rebuilt after reloaded.
+	labeler2, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	start, _ := hex.DecodeString("123456")
+	re.Equal("v3", labeler2.GetRegionLabel(core.NewTestRegionInfo(1, 1, start, nil), "k2"))
+
+	// invalid prefixes.
+	for _, data := range []interface{}{MakeKeyPrefixes(), MakeKeyPrefixes(""), MakeKeyPrefixes("xyz"), MakeKeyRanges("12", "34")} {
+		rule := &LabelRule{ID: "rule4", Labels: []RegionLabel{{Key: "k1", Value: "v4"}}, RuleType: "key-prefix", Data: data}
+		re.Error(labeler.SetLabelRule(rule))
+	}
+}
+
+func TestRegionID(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rules := []*LabelRule{
+		{ID: "rule0", Labels: []RegionLabel{{Key: "k1", Value: "v0"}}, RuleType: "key-range", Data: MakeKeyRanges("", "")},
+		{ID: "rule1", Index: 1, Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "region-id", Data: MakeRegionIDs(1, 2)},
+		{ID: "rule2", Index: 2, Labels: []RegionLabel{{Key: "k2", Value: "v2"}}, RuleType: "region-id", Data: MakeRegionIDs(2, 100)},
+	}
+	for _, r := range rules {
+		re.NoError(labeler.SetLabelRule(r))
+	}
+	re.Equal([]uint64{2, 100}, labeler.GetLabelRule("rule2").Data)
+
+	// the rules follow the regions regardless of the key ranges.
+	testCases := []struct {
+		regionID uint64
+		start    string
+		labels   map[string]string
+	}{
+		{1, "12", map[string]string{"k1": "v1"}},
+		{1, "34", map[string]string{"k1": "v1"}},
+		{2, "56", map[string]string{"k1": "v1", "k2": "v2"}},
+		{3, "12", map[string]string{"k1": "v0"}},
+	}
+	for _, testCase := range testCases {
+		start, _ := hex.DecodeString(testCase.start)
+		region := core.NewTestRegionInfo(testCase.regionID, 1, start, nil)
+		labels := labeler.GetRegionLabels(region)
+		re.Len(labels, len(testCase.labels))
+		for _, l := range labels {
+			re.Equal(testCase.labels[l.Key], l.Value)
+		}
+	}
+
+	// the region IDs are rebuilt after reloaded.
+	labeler2, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	re.Equal("v2", labeler2.GetRegionLabel(core.NewTestRegionInfo(100, 1, nil, nil), "k2"))
+
+	// invalid region IDs.
+	for _, data := range []interface{}{MakeRegionIDs(), MakeRegionIDs(0), []interface{}{1.5}, MakeKeyPrefixes("12")} {
+		rule := &LabelRule{ID: "rule3", Labels: []RegionLabel{{Key: "k1", Value: "v3"}}, RuleType: "region-id", Data: data}
+		re.Error(labeler.SetLabelRule(rule))
+	}
+}
+
 func TestSaveLoadRule(t *testing.T) {
 	re := require.New(t)
 	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
@@ -380,3 +644,392 @@ func TestGC(t *testing.T) {
 	labeler.RUnlock()
 	re.LessOrEqual(currentRuleLen, 5)
 }
+
+func TestExpiredLabels(t *testing.T) {
+	re := require.New(t)
+	notified := make(chan []*ExpiredLabels, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var records []*ExpiredLabels
+		re.NoError(json.NewDecoder(r.Body).Decode(&records))
+		notified <- records
+	}))
+	defer server.Close()
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	re.Error(labeler.SetExpiryWebhook("127.0.0.1:8080"))
+	re.NoError(labeler.SetExpiryWebhook(server.URL))
+
+	// a label of the rule expires.
+	re.NoError(labeler.SetLabelRule(&LabelRule{
+		ID:       "rule1",
+		Labels:   []RegionLabel{{Key: "k1", Value: "v1"}, {Key: "k2", Value: "v2", TTL: "10ms"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+	}))
+	// the rule expires with all its labels.
+	re.NoError(labeler.SetLabelRule(&LabelRule{
+		ID:       "rule2",
+		Labels:   []RegionLabel{{Key: "k3", Value: "v3"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+		TTL:      "10ms",
+	}))
+	re.Empty(labeler.GetExpiredLabels())
+	time.Sleep(20 * time.Millisecond)
+	expired := labeler.GetExpiredLabels()
+	re.Len(expired, 2)
+	sort.Slice(expired, func(i, j int) bool { return expired[i].RuleID < expired[j].RuleID })
+	re.Equal("rule1", expired[0].RuleID)
+	re.False(expired[0].RuleRemoved)
+	re.Len(expired[0].Labels, 1)
+	re.Equal("k2", expired[0].Labels[0].Key)
+	re.Equal("rule2", expired[1].RuleID)
+	re.True(expired[1].RuleRemoved)
+	re.Len(expired[1].Labels, 1)
+	re.Equal("k3", expired[1].Labels[0].Key)
+	re.Len(labeler.GetLabelRule("rule1").Labels, 1)
+	re.Nil(labeler.GetLabelRule("rule2"))
+
+	// the webhook is notified of each expiration.
+	ruleIDs := make([]string, 0, 2)
+	for i := 0; i < 2; i++ {
+		select {
+		case records := <-notified:
+			re.Len(records, 1)
+			ruleIDs = append(ruleIDs, records[0].RuleID)
+		case <-time.After(5 * time.Second):
+			re.FailNow("the webhook is not notified")
+		}
+	}
+	sort.Strings(ruleIDs)
+	re.Equal([]string{"rule1", "rule2"}, ruleIDs)
+
+	// the records are bounded.
+	labeler.Lock()
+	for i := 0; i < maxExpiredLabelRecords; i++ {
+		labeler.expiredLabels = append(labeler.expiredLabels, &ExpiredLabels{RuleID: fmt.Sprintf("fake-%d", i)})
+	}
+	labeler.expiryWebhook = ""
+	labeler.Unlock()
+	re.NoError(labeler.SetLabelRule(&LabelRule{
+		ID:       "rule3",
+		Labels:   []RegionLabel{{Key: "k4", Value: "v4"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+		TTL:      "10ms",
+	}))
+	time.Sleep(20 * time.Millisecond)
+	expired = labeler.GetExpiredLabels()
+	re.Len(expired, maxExpiredLabelRecords)
+	re.Equal("fake-1", expired[0].RuleID)
+	re.Equal("rule3", expired[len(expired)-1].RuleID)
+}
+
+func TestGetMatchedLabelRules(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	start, _ := hex.DecodeString("1234")
+	end, _ := hex.DecodeString("5678")
+	region := core.NewTestRegionInfo(1, 1, start, end)
+
+	re.Empty(labeler.GetMatchedLabelRules(region))
+	rules := []*LabelRule{
+		{
+			ID:       "deny",
+			Index:    2,
+			Labels:   []RegionLabel{{Key: "schedule", Value: "deny"}},
+			RuleType: KeyRange,
+			Data:     MakeKeyRanges("1000", "6000"),
+		},
+		{
+			ID:       "allow",
+			Index:    1,
+			Labels:   []RegionLabel{{Key: "schedule", Value: "allow"}, {Key: "k1", Value: "v1"}},
+			RuleType: KeyRange,
+			Data:     MakeKeyRanges("1234", "5678"),
+		},
+		{
+			ID:       "other",
+			Labels:   []RegionLabel{{Key: "k2", Value: "v2"}},
+			RuleType: KeyRange,
+			Data:     MakeKeyRanges("7000", "8000"),
+		},
+		{
+			ID:       "region",
+			Labels:   []RegionLabel{{Key: "k3", Value: "v3"}},
+			RuleType: RegionID,
+			Data:     MakeRegionIDs(1),
+		},
+	}
+	for _, rule := range rules {
+		re.NoError(labeler.SetLabelRule(rule))
+	}
+	matched := labeler.GetMatchedLabelRules(region)
+	re.Len(matched, 3)
+	re.Equal("region", matched[0].Rule.ID)
+	re.True(matched[0].Applied)
+	re.Equal([]RegionLabel{{Key: "k3", Value: "v3"}}, matched[0].EffectiveLabels)
+	re.Equal("allow", matched[1].Rule.ID)
+	re.True(matched[1].Applied)
+	re.Equal([]RegionLabel{{Key: "k1", Value: "v1"}}, matched[1].EffectiveLabels)
+	// the rule with the highest index is responsible for the schedule label.
+	re.Equal("deny", matched[2].Rule.ID)
+	re.True(matched[2].Applied)
+	re.Equal([]RegionLabel{{Key: "schedule", Value: "deny"}}, matched[2].EffectiveLabels)
+	re.True(labeler.ScheduleDisabled(region))
+
+	// the rules dropped by the overlap policy are still reported.
+	re.NoError(labeler.SetOverlapPolicy(HighestIndexWins))
+	matched = labeler.GetMatchedLabelRules(region)
+	re.Len(matched, 3)
+	re.False(matched[0].Applied)
+	re.Empty(matched[0].EffectiveLabels)
+	re.False(matched[1].Applied)
+	re.Empty(matched[1].EffectiveLabels)
+	re.True(matched[2].Applied)
+	re.Equal([]RegionLabel{{Key: "schedule", Value: "deny"}}, matched[2].EffectiveLabels)
+}
+
+func TestLabelRuleExpire(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	start, _ := hex.DecodeString("1234")
+	end, _ := hex.DecodeString("5678")
+	region := core.NewTestRegionInfo(1, 1, start, end)
+
+	rule := &LabelRule{
+		ID:       "rule1",
+		Labels:   []RegionLabel{{Key: "k1", Value: "v1"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+		TTL:      "1h",
+	}
+	re.NoError(labeler.SetLabelRule(rule))
+	re.NotEmpty(labeler.GetLabelRule("rule1").ExpireAt)
+	re.Equal("v1", labeler.GetRegionLabel(region, "k1"))
+
+	// the rule expires with all its labels.
+	rule = &LabelRule{
+		ID:       "rule2",
+		Labels:   []RegionLabel{{Key: "k2", Value: "v2"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+		TTL:      "10ms",
+	}
+	re.NoError(labeler.SetLabelRule(rule))
+	re.Equal("v2", labeler.GetRegionLabel(region, "k2"))
+	time.Sleep(20 * time.Millisecond)
+	re.Equal("", labeler.GetRegionLabel(region, "k2"))
+	re.Len(labeler.GetRegionLabels(region), 1)
+	checkRuleInMemoryAndStoage(re, labeler, "rule2", true)
+	labeler.checkAndClearExpiredLabels()
+	checkRuleInMemoryAndStoage(re, labeler, "rule2", false)
+	checkRuleInMemoryAndStoage(re, labeler, "rule1", true)
+
+	// a rule with invalid ttl is rejected.
+	rule = &LabelRule{
+		ID:       "rule3",
+		Labels:   []RegionLabel{{Key: "k3", Value: "v3"}},
+		RuleType: "key-range",
+		Data:     MakeKeyRanges("1234", "5678"),
+		TTL:      "1x",
+	}
+	re.Error(labeler.SetLabelRule(rule))
+	rule.TTL, rule.ExpireAt = "", time.Now().Add(-time.Minute).Format(time.RFC3339)
+	re.Error(labeler.SetLabelRule(rule))
+
+	// the rule expired during the restart is reaped after reloaded.
+	rule.Labels = []RegionLabel{{Key: "k3", Value: "v3"}}
+	rule.ExpireAt = time.Now().Add(time.Second).Format(time.RFC3339Nano)
+	re.NoError(labeler.SetLabelRule(rule))
+	rule.ExpireAt = time.Now().Add(-time.Minute).Format(time.RFC3339)
+	re.NoError(store.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		return store.SaveRegionRule(txn, rule.ID, rule)
+	}))
+	labeler, err = NewRegionLabeler(context.Background(), store, time.Hour)
+	re.NoError(err)
+	checkRuleInMemoryAndStoage(re, labeler, "rule3", false)
+	checkRuleInMemoryAndStoage(re, labeler, "rule1", true)
+}
+
+func TestDryMatch(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rule := &LabelRule{ID: "rule1", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "key-range", Data: MakeKeyRanges("1234", "5678")}
+	_, err = labeler.DryMatch(rule)
+	re.Error(err)
+
+	cluster := core.NewBasicCluster()
+	for i, keys := range [][2]string{{"", "12"}, {"12", "1234"}, {"1234", "3456"}, {"3456", "5678"}, {"5678", "abcd"}, {"abcd", "abcdef"}, {"abcdef", ""}} {
+		start, _ := hex.DecodeString(keys[0])
+		end, _ := hex.DecodeString(keys[1])
+		cluster.PutRegion(core.NewTestRegionInfo(uint64(i+1), 1, start, end))
+	}
+	labeler.SetRegionSetInformer(cluster)
+
+	testCases := []struct {
+		ruleType string
+		data     interface{}
+		expect   []uint64
+	}{
+		{KeyRange, MakeKeyRanges("1234", "5678"), []uint64{3, 4}},
+		// the regions across the boundaries are not matched.
+		{KeyRange, MakeKeyRanges("1233", "5679", "abcd", "abcdee"), []uint64{3, 4}},
+		{KeyRange, MakeKeyRanges("abcd", ""), []uint64{6, 7}},
+		{KeyRange, MakeKeyRanges("", ""), []uint64{1, 2, 3, 4, 5, 6, 7}},
+		{KeyPrefix, MakeKeyPrefixes("12", "abcd"), []uint64{2, 3, 6, 7}},
+		{KeyPrefix, MakeKeyPrefixes("ff"), []uint64{}},
+		{RegionID, MakeRegionIDs(1, 5, 100), []uint64{1, 5}},
+	}
+	for _, testCase := range testCases {
+		rule := &LabelRule{ID: "rule1", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: testCase.ruleType, Data: testCase.data}
+		ids, err := labeler.DryMatch(rule)
+		re.NoError(err)
+		re.Equal(testCase.expect, ids)
+		// the rule is neither stored nor adjusted.
+		re.Nil(labeler.GetLabelRule("rule1"))
+		re.Equal(testCase.data, rule.Data)
+	}
+
+	_, err = labeler.DryMatch(&LabelRule{ID: "rule1", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: KeyPrefix, Data: MakeKeyPrefixes("xyz")})
+	re.Error(err)
+}
+
+func TestOverlapPolicy(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	rules := []*LabelRule{
+		{ID: "rule0", Labels: []RegionLabel{{Key: "k1", Value: "v0"}}, RuleType: "key-range", Data: MakeKeyRanges("", "")},
+		{ID: "rule1", Index: 1, Labels: []RegionLabel{{Key: "k1", Value: "v1"}, {Key: "k2", Value: "v1"}}, RuleType: "key-range", Data: MakeKeyRanges("1234", "5678")},
+		{ID: "rule2", Index: 2, Labels: []RegionLabel{{Key: "k2", Value: "v2"}}, RuleType: "key-range", Data: MakeKeyRanges("ab12", "cd12")},
+		{ID: "rule3", Labels: []RegionLabel{{Key: "k3", Value: "v3"}}, RuleType: "key-range", Data: MakeKeyRanges("5678", "ab12")},
+	}
+	for _, r := range rules {
+		re.NoError(labeler.SetLabelRule(r))
+	}
+	re.Equal(MergeLabels, labeler.GetOverlapPolicy())
+	re.Error(labeler.SetOverlapPolicy("unknown"))
+
+	type testCase struct {
+		policy     string
+		start, end string
+		labels     map[string]string
+	}
+	testCases := []testCase{
+		{MergeLabels, "1234", "5678", map[string]string{"k1": "v1", "k2": "v1"}},
+		{MergeLabels, "5678", "ab12", map[string]string{"k1": "v0", "k3": "v3"}},
+		{MergeLabels, "ab12", "cd12", map[string]string{"k1": "v0", "k2": "v2"}},
+		{HighestIndexWins, "1234", "5678", map[string]string{"k1": "v1", "k2": "v1"}},
+		// the rule with the smaller ID wins if the indexes are the same.
+		{HighestIndexWins, "5678", "ab12", map[string]string{"k1": "v0"}},
+		{HighestIndexWins, "ab12", "cd12", map[string]string{"k2": "v2"}},
+		{HighestIndexWins, "cd12", "", map[string]string{"k1": "v0"}},
+	}
+	for _, testCase := range testCases {
+		re.NoError(labeler.SetOverlapPolicy(testCase.policy))
+		start, _ := hex.DecodeString(testCase.start)
+		end, _ := hex.DecodeString(testCase.end)
+		region := core.NewTestRegionInfo(1, 1, start, end)
+		labels := labeler.GetRegionLabels(region)
+		re.Len(labels, len(testCase.labels))
+		for _, l := range labels {
+			re.Equal(testCase.labels[l.Key], l.Value)
+		}
+		for _, k := range []string{"k1", "k2", "k3"} {
+			re.Equal(testCase.labels[k], labeler.GetRegionLabel(region, k))
+		}
+	}
+
+	re.NoError(labeler.SetOverlapPolicy(MergeLabels))
+	re.Equal([]LabelOverlap{
+		{RuleIDs: [2]string{"rule0", "rule1"}, StartKeyHex: "1234", EndKeyHex: "5678", DroppedLabels: []DroppedLabel{{RuleID: "rule0", Key: "k1", Value: "v0"}}},
+		{RuleIDs: [2]string{"rule0", "rule2"}, StartKeyHex: "ab12", EndKeyHex: "cd12"},
+		{RuleIDs: [2]string{"rule0", "rule3"}, StartKeyHex: "5678", EndKeyHex: "ab12"},
+	}, labeler.DetectOverlaps())
+	re.NoError(labeler.SetOverlapPolicy(HighestIndexWins))
+	re.Equal([]LabelOverlap{
+		{RuleIDs: [2]string{"rule0", "rule1"}, StartKeyHex: "1234", EndKeyHex: "5678", DroppedLabels: []DroppedLabel{{RuleID: "rule0", Key: "k1", Value: "v0"}}},
+		{RuleIDs: [2]string{"rule0", "rule2"}, StartKeyHex: "ab12", EndKeyHex: "cd12", DroppedLabels: []DroppedLabel{{RuleID: "rule0", Key: "k1", Value: "v0"}}},
+		{RuleIDs: [2]string{"rule0", "rule3"}, StartKeyHex: "5678", EndKeyHex: "ab12", DroppedLabels: []DroppedLabel{{RuleID: "rule3", Key: "k3", Value: "v3"}}},
+	}, labeler.DetectOverlaps())
+}
+
+func TestHotRegion(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	labeler, err := NewRegionLabeler(context.Background(), store, time.Millisecond*10)
+	re.NoError(err)
+	hot := map[utils.RWType]map[uint64]int{
+		utils.Read:  {1: 5, 2: 1},
+		utils.Write: {3: 5},
+	}
+	labeler.SetHotRegionsFunc(func(kind utils.RWType, minHotDegree int) []uint64 {
+		var ids []uint64
+		for id, degree := range hot[kind] {
+			if degree >= minHotDegree {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	})
+	rule := &LabelRule{
+		ID:       "hot",
+		Labels:   []RegionLabel{{Key: "pin", Value: "hot"}},
+		RuleType: "hot-region",
+		Data:     MakeHotRegionThresholds(HotRegionThreshold{Kind: "read", MinHotDegree: 3}, HotRegionThreshold{Kind: "write", MinHotDegree: 3}),
+	}
+	re.NoError(labeler.SetLabelRule(rule))
+	ids, err := labeler.DryMatch(rule)
+	re.Error(err) // the regions are not available.
+	re.Nil(ids)
+
+	// the labels are added to the hot regions.
+	labeler.refreshHotRegions()
+	check := func(hotIDs ...uint64) {
+		for id := uint64(1); id <= 4; id++ {
+			expected := ""
+			for _, hotID := range hotIDs {
+				if id == hotID {
+					expected = "hot"
+				}
+			}
+			re.Equal(expected, labeler.GetRegionLabel(core.NewTestRegionInfo(id, 1, nil, nil), "pin"), id)
+		}
+	}
+	check(1, 3)
+
+	// the labels follow the hotness of the regions.
+	hot[utils.Read] = map[uint64]int{1: 1, 2: 3}
+	labeler.refreshHotRegions()
+	check(2, 3)
+	hot[utils.Write] = nil
+	labeler.refreshHotRegions()
+	check(2)
+
+	// the hot regions are kept after other rules are updated.
+	re.NoError(labeler.SetLabelRule(&LabelRule{ID: "other", Labels: []RegionLabel{{Key: "k1", Value: "v1"}}, RuleType: "region-id", Data: MakeRegionIDs(4)}))
+	check(2)
+	re.NoError(labeler.DeleteLabelRule("hot"))
+	labeler.refreshHotRegions()
+	check()
+
+	// invalid thresholds.
+	for _, data := range []interface{}{
+		MakeHotRegionThresholds(),
+		MakeHotRegionThresholds(HotRegionThreshold{Kind: "scan", MinHotDegree: 1}),
+		MakeHotRegionThresholds(HotRegionThreshold{Kind: "read", MinHotDegree: -1}),
+		MakeRegionIDs(1),
+	} {
+		rule := &LabelRule{ID: "hot", Labels: []RegionLabel{{Key: "pin", Value: "hot"}}, RuleType: "hot-region", Data: data}
+		re.Error(labeler.SetLabelRule(rule))
+	}
+}
@@ -0,0 +1,265 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// The policies to resolve the rules matching the same region. The rules are
+// ordered by the index, and then the ID, i.e., the rule with a higher index,
+// or a smaller ID if the indexes are the same, takes precedence.
+const (
+	// MergeLabels applies the labels of all the rules, and the label of the
+	// rule taking precedence is applied if the keys of the labels are the
+	// same. It's the default policy.
+	MergeLabels = "merge-labels"
+	// HighestIndexWins only applies the labels of the rule taking precedence,
+	// the labels of the other rules are dropped.
+	HighestIndexWins = "highest-index-wins"
+)
+
+// ValidateOverlapPolicy checks the overlap policy, the empty one means the
+// default policy.
+func ValidateOverlapPolicy(policy string) error {
+	switch policy {
+	case "", MergeLabels, HighestIndexWins:
+		return nil
+	}
+	return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid overlap policy %s", policy))
+}
+
+// SetOverlapPolicy sets the policy to resolve the rules matching the same
+// region, the empty one means the default policy.
+func (l *RegionLabeler) SetOverlapPolicy(policy string) error {
+	if err := ValidateOverlapPolicy(policy); err != nil {
+		return err
+	}
+	if policy == "" {
+		policy = MergeLabels
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.overlapPolicy = policy
+	return nil
+}
+
+// GetOverlapPolicy returns the policy to resolve the rules matching the same
+// region.
+func (l *RegionLabeler) GetOverlapPolicy() string {
+	l.RLock()
+	defer l.RUnlock()
+	return l.getOverlapPolicy()
+}
+
+func (l *RegionLabeler) getOverlapPolicy() string {
+	if l.overlapPolicy == "" {
+		return MergeLabels
+	}
+	return l.overlapPolicy
+}
+
+// labelRuleLess checks whether the rule a is applied before b, so the label of
+// b takes precedence over a.
+func labelRuleLess(a, b *LabelRule) bool {
+	if a.Index != b.Index {
+		return a.Index < b.Index
+	}
+	// the rule with a smaller ID takes precedence.
+	return a.ID > b.ID
+}
+
+// getEffectiveRules returns the unexpired rules matching the region resolved
+// by the overlap policy, in the order of being applied.
+func (l *RegionLabeler) getEffectiveRules(region *core.RegionInfo, now time.Time) []*LabelRule {
+	var rules []*LabelRule
+	for _, r := range l.getMatchedRules(region) {
+		if !r.expired(now) {
+			rules = append(rules, r)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return labelRuleLess(rules[i], rules[j]) })
+	if len(rules) > 1 && l.getOverlapPolicy() == HighestIndexWins {
+		return rules[len(rules)-1:]
+	}
+	return rules
+}
+
+// MatchedLabelRule is a label rule matching a region, with the labels of it
+// taking effect on the region.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type MatchedLabelRule struct {
+	Rule *LabelRule `json:"rule"`
+	// Applied is false if the rule is dropped by the overlap policy.
+	Applied bool `json:"applied"`
+	// EffectiveLabels are the labels of the rule in the labels of the region,
+	// i.e., the ones neither expired nor overridden by the other rules.
+	EffectiveLabels []RegionLabel `json:"effective_labels"`
+}
+
+// GetMatchedLabelRules returns all the unexpired rules matching the region in
+// the order of being applied, so the rule responsible for a label of the
+// region can be found out among the overlapping ones.
+func (l *RegionLabeler) GetMatchedLabelRules(region *core.RegionInfo) []*MatchedLabelRule {
+	l.RLock()
+	defer l.RUnlock()
+	now := time.Now()
+	applied := make(map[string]struct{})
+	// the label of the rule applied later takes precedence.
+	winners := make(map[string]string)
+	for _, r := range l.getEffectiveRules(region, now) {
+		applied[r.ID] = struct{}{}
+		for _, label := range r.Labels {
+			if !label.expireBefore(now) {
+				winners[label.Key] = r.ID
+			}
+		}
+	}
+	var rules []*LabelRule
+	for _, r := range l.getMatchedRules(region) {
+		if !r.expired(now) {
+			rules = append(rules, r)
+		}
+	}
+	sort.SliceStable(rules, func(i, j int) bool { return labelRuleLess(rules[i], rules[j]) })
+	matched := make([]*MatchedLabelRule, 0, len(rules))
+	for _, r := range rules {
+		m := &MatchedLabelRule{Rule: r, EffectiveLabels: []RegionLabel{}}
+		if _, ok := applied[r.ID]; ok {
+			m.Applied = true
+			for _, label := range r.Labels {
+				if !label.expireBefore(now) && winners[label.Key] == r.ID {
+					m.EffectiveLabels = append(m.EffectiveLabels, label)
+				}
+			}
+		}
+		matched = append(matched, m)
+	}
+	return matched
+}
+
+// LabelOverlap is the overlapping key range of two key-range rules.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type LabelOverlap struct {
+	RuleIDs     [2]string `json:"rule_ids"`
+	StartKeyHex string    `json:"start_key"`
+	EndKeyHex   string    `json:"end_key"`
+	// DroppedLabels are the labels of the rules not applied to the regions in
+	// the range due to the overlap policy.
+	DroppedLabels []DroppedLabel `json:"dropped_labels,omitempty"`
+}
+
+// DroppedLabel is a label of a rule dropped due to the overlap policy.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type DroppedLabel struct {
+	RuleID string `json:"rule_id"`
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+}
+
+// DetectOverlaps returns the overlapping ranges of every two unexpired rules of
+// the type `KeyRange`, and the labels dropped in the ranges by the current
+// overlap policy. Each pair of rules is only checked by themselves, so the
+// labels dropped due to a third rule are not reported.
+func (l *RegionLabeler) DetectOverlaps() []LabelOverlap {
+	l.RLock()
+	defer l.RUnlock()
+	now := time.Now()
+	var rules []*LabelRule
+	for _, r := range l.labelRules {
+		if r.RuleType == KeyRange && !r.expired(now) {
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	policy := l.getOverlapPolicy()
+	var overlaps []LabelOverlap
+	for i, a := range rules {
+		for _, b := range rules[i+1:] {
+			var dropped []DroppedLabel
+			computed := false
+			for _, ra := range a.Data.([]*KeyRangeRule) {
+				for _, rb := range b.Data.([]*KeyRangeRule) {
+					start, end, ok := intersectKeyRange(ra, rb)
+					if !ok {
+						continue
+					}
+					if !computed {
+						dropped, computed = droppedLabels(a, b, policy, now), true
+					}
+					overlaps = append(overlaps, LabelOverlap{
+						RuleIDs:       [2]string{a.ID, b.ID},
+						StartKeyHex:   hex.EncodeToString(start),
+						EndKeyHex:     hex.EncodeToString(end),
+						DroppedLabels: dropped,
+					})
+				}
+			}
+		}
+	}
+	return overlaps
+}
+
+// droppedLabels returns the labels dropped in the range matched by both rules.
+func droppedLabels(a, b *LabelRule, policy string, now time.Time) []DroppedLabel {
+	winner, loser := a, b
+	if labelRuleLess(a, b) {
+		winner, loser = b, a
+	}
+	values := make(map[string]string)
+	for _, l := range winner.Labels {
+		if !l.expireBefore(now) {
+			values[l.Key] = l.Value
+		}
+	}
+	var dropped []DroppedLabel
+	for _, l := range loser.Labels {
+		if l.expireBefore(now) {
+			continue
+		}
+		value, ok := values[l.Key]
+		// the label is dropped if it's overwritten, or the whole rule loses
+		// unless the winner has the same label.
+		if (ok && value != l.Value) || (!ok && policy == HighestIndexWins) {
+			dropped = append(dropped, DroppedLabel{RuleID: loser.ID, Key: l.Key, Value: l.Value})
+		}
+	}
+	return dropped
+}
+
+// intersectKeyRange returns the intersection of two key ranges, the empty end
+// key means the end of the key space.
+func intersectKeyRange(a, b *KeyRangeRule) (start, end []byte, ok bool) {
+	start = a.StartKey
+	if bytes.Compare(b.StartKey, start) > 0 {
+		start = b.StartKey
+	}
+	switch {
+	case len(a.EndKey) == 0:
+		end = b.EndKey
+	case len(b.EndKey) == 0 || bytes.Compare(a.EndKey, b.EndKey) < 0:
+		end = a.EndKey
+	default:
+		end = b.EndKey
+	}
+	return start, end, len(end) == 0 || bytes.Compare(start, end) < 0
+}
@@ -0,0 +1,103 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// maxEtcdTxnOps is the max number of operations in an etcd transaction. The
+// default limit of etcd is 128, we use 120 here to leave some space.
+const maxEtcdTxnOps = 120
+
+// The operations of a patch.
+const (
+	PatchSet    = "set"
+	PatchDelete = "delete"
+)
+
+// LabelRuleError is a problem of a rule in a patch.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type LabelRuleError struct {
+	// Operation is PatchSet or PatchDelete, and Index is the position of the
+	// rule in the sets or deletes of the patch.
+	Operation string `json:"operation"`
+	Index     int    `json:"index"`
+	RuleID    string `json:"rule_id"`
+	Error     string `json:"error"`
+}
+
+// ValidatePatch checks all the rules of the patch, including the key ranges,
+// the rule types and the TTLs, and returns the problems of every rule, so that
+// they can be fixed at once, while Patch only returns them as a whole error.
+// The patch is not modified.
+func ValidatePatch(patch LabelRulePatch) []*LabelRuleError {
+	var res []*LabelRuleError
+	add := func(op string, i int, id string, err error) {
+		res = append(res, &LabelRuleError{Operation: op, Index: i, RuleID: id, Error: err.Error()})
+	}
+	setIDs := make(map[string]int, len(patch.SetRules))
+	for i, rule := range patch.SetRules {
+		if rule == nil {
+			add(PatchSet, i, "", errs.ErrRegionRuleContent.FastGenByArgs("null rule"))
+			continue
+		}
+		if j, ok := setIDs[rule.ID]; ok && rule.ID != "" {
+			add(PatchSet, i, rule.ID, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("duplicated with the rule at %d", j)))
+			continue
+		}
+		setIDs[rule.ID] = i
+		// adjust a copy, so the rule is kept as is.
+		c := *rule
+		c.Labels = append([]RegionLabel(nil), rule.Labels...)
+		if err := c.checkAndAdjust(); err != nil {
+			add(PatchSet, i, rule.ID, err)
+		}
+	}
+	deleteIDs := make(map[string]int, len(patch.DeleteRules))
+	for i, id := range patch.DeleteRules {
+		if id == "" {
+			add(PatchDelete, i, id, errs.ErrRegionRuleContent.FastGenByArgs("empty rule id"))
+			continue
+		}
+		if j, ok := deleteIDs[id]; ok {
+			add(PatchDelete, i, id, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("duplicated with the rule at %d", j)))
+			continue
+		}
+		deleteIDs[id] = i
+	}
+	return res
+}
+
+// patchError returns the error of the problems of the patch.
+func patchError(problems []*LabelRuleError) error {
+	msgs := make([]string, 0, len(problems))
+	for _, p := range problems {
+		msgs = append(msgs, fmt.Sprintf("%s rule %q at %d: %s", p.Operation, p.RuleID, p.Index, p.Error))
+	}
+	return errs.ErrRegionRuleContent.FastGenByArgs(strings.Join(msgs, "; "))
+}
+
+// checkTxnOps checks the number of the operations of the patch, which are
+// saved in one etcd transaction.
+func (p *LabelRulePatch) checkTxnOps() error {
+	if n := len(p.SetRules) + len(p.DeleteRules); n > maxEtcdTxnOps {
+		return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("too many rules %d in a patch, the limit is %d", n, maxEtcdTxnOps))
+	}
+	return nil
+}
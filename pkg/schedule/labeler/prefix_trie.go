@@ -0,0 +1,86 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labeler
+
+import "sort"
+
+// prefixTrie indexes the LabelRules of the type `KeyPrefix` by their prefixes,
+// so the rules matching a key can be found in O(len(key)).
+type prefixTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[byte]*trieNode
+	rules    []*LabelRule
+}
+
+func newPrefixTrie() *prefixTrie {
+	return &prefixTrie{root: &trieNode{}}
+}
+
+func (t *prefixTrie) insert(prefix []byte, rule *LabelRule) {
+	node := t.root
+	for _, b := range prefix {
+		if node.children == nil {
+			node.children = make(map[byte]*trieNode)
+		}
+		child, ok := node.children[b]
+		if !ok {
+			child = &trieNode{}
+			node.children[b] = child
+		}
+		node = child
+	}
+	for _, r := range node.rules {
+		// the rule has an overlapped prefix.
+		if r == rule {
+			return
+		}
+	}
+	node.rules = append(node.rules, rule)
+}
+
+// match returns the rules that have a prefix of the key, they are sorted by
+// the index and then the ID.
+func (t *prefixTrie) match(key []byte) []*LabelRule {
+	var rules []*LabelRule
+	seen := make(map[*LabelRule]struct{})
+	collect := func(node *trieNode) {
+		for _, r := range node.rules {
+			if _, ok := seen[r]; !ok {
+				seen[r] = struct{}{}
+				rules = append(rules, r)
+			}
+		}
+	}
+	node := t.root
+	collect(node)
+	for _, b := range key {
+		child, ok := node.children[b]
+		if !ok {
+			break
+		}
+		node = child
+		collect(node)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Index != rules[j].Index {
+			return rules[i].Index < rules[j].Index
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
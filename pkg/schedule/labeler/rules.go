@@ -19,6 +19,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
 	"time"
 
@@ -41,12 +42,20 @@ type RegionLabel struct {
 // LabelRule is the rule to assign labels to a region.
 // NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
 type LabelRule struct {
-	ID        string        `json:"id"`
-	Index     int           `json:"index"`
-	Labels    []RegionLabel `json:"labels"`
-	RuleType  string        `json:"rule_type"`
-	Data      interface{}   `json:"data"`
+	ID       string        `json:"id"`
+	Index    int           `json:"index"`
+	Labels   []RegionLabel `json:"labels"`
+	RuleType string        `json:"rule_type"`
+	Data     interface{}   `json:"data"`
+	// TTL is the optional lifetime of the rule, the rule is removed with all
+	// its labels after it expires. ExpireAt is set when the rule is created
+	// with a TTL, and it can also be specified directly in RFC3339 format.
+	TTL       string `json:"ttl,omitempty"`
+	ExpireAt  string `json:"expire_at,omitempty"`
+	expire    *time.Time
 	minExpire *time.Time
+	// prefixes is the decoded Data of the rule of the type `KeyPrefix`.
+	prefixes [][]byte
 }
 
 // NewLabelRuleFromJSON creates a label rule from the JSON data.
@@ -56,14 +65,123 @@ func NewLabelRuleFromJSON(data []byte) (*LabelRule, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := validateLabelRuleData(lr.RuleType, lr.Data); err != nil {
+		return nil, err
+	}
 	return lr, nil
 }
 
 const (
 	// KeyRange is the rule type that specifies a list of key ranges.
 	KeyRange = "key-range"
+	// KeyPrefix is the rule type that specifies a list of hex format key
+	// prefixes, it matches the regions whose start key has any of them.
+	KeyPrefix = "key-prefix"
+	// RegionID is the rule type that specifies a list of region IDs, it
+	// matches the regions with any of the IDs. The IDs of the regions which
+	// no longer exist are skipped.
+	RegionID = "region-id"
+	// HotRegion is the rule type that specifies a list of hotness thresholds,
+	// it matches the regions which are hot enough for any of them according
+	// to the hot-region statistics. The labels are removed from the regions
+	// once they cool down.
+	HotRegion = "hot-region"
 )
 
+// labelRuleDataSchema describes the expected shape of `LabelRule.Data` of a
+// rule type, which is an array of the items decoded from JSON.
+type labelRuleDataSchema struct {
+	// desc describes the shape in the error message.
+	desc string
+	// isItem checks whether an item of the array has the expected shape.
+	isItem func(item interface{}) bool
+}
+
+// labelRuleDataSchemas are the schemas of the data of all the rule types, a new
+// rule type must be added here, otherwise it's rejected as unknown.
+var labelRuleDataSchemas = map[string]labelRuleDataSchema{
+	KeyRange: {
+		desc: "an array of {start_key,end_key}",
+		isItem: func(item interface{}) bool {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			_, ok1 := m["start_key"].(string)
+			_, ok2 := m["end_key"].(string)
+			return ok1 && ok2
+		},
+	},
+	KeyPrefix: {
+		desc: "an array of hex format key prefixes",
+		isItem: func(item interface{}) bool {
+			_, ok := item.(string)
+			return ok
+		},
+	},
+	RegionID: {
+		desc: "an array of region IDs",
+		isItem: func(item interface{}) bool {
+			// the numbers are decoded from JSON as float64.
+			_, ok := item.(float64)
+			return ok
+		},
+	},
+	HotRegion: {
+		desc: "an array of {kind,min_hot_degree}",
+		isItem: func(item interface{}) bool {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			_, ok1 := m["kind"].(string)
+			_, ok2 := m["min_hot_degree"].(float64)
+			return ok1 && ok2
+		},
+	},
+}
+
+// validateLabelRuleData checks the shape of the data decoded from JSON against
+// the rule type. The content of the items, such as the hex format of the keys,
+// is checked when adjusting the rule.
+func validateLabelRuleData(ruleType string, data interface{}) error {
+	schema, ok := labelRuleDataSchemas[ruleType]
+	if !ok {
+		return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %s", ruleType))
+	}
+	items, ok := data.([]interface{})
+	if ok {
+		for _, item := range items {
+			if !schema.isItem(item) {
+				ok = false
+				break
+			}
+		}
+	}
+	if !ok {
+		return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("%s rule requires %s", ruleType, schema.desc))
+	}
+	return nil
+}
+
+// normalizeLabelRuleData converts the data to the form decoded from JSON, so
+// the data constructed in Go, such as []*KeyRangeRule, can be checked and
+// adjusted in the same way. It also makes adjusting a rule twice work.
+func normalizeLabelRuleData(data interface{}) (interface{}, error) {
+	if _, ok := data.([]interface{}); ok || data == nil {
+		return data, nil
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule data: %v", err))
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(raw, &normalized); err != nil {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule data: %v", err))
+	}
+	return normalized, nil
+}
+
 const (
 	scheduleOptionLabel     = "schedule"
 	scheduleOptionValueDeny = "deny"
@@ -85,6 +203,24 @@ type LabelRulePatch struct {
 	DeleteRules []string     `json:"deletes"`
 }
 
+// checkAndAdjust validates all the rules before adjusting any of them, so
+// the error reports the problems of every rule and the patch is either
+// applied as a whole or not at all.
+func (p *LabelRulePatch) checkAndAdjust() error {
+	if err := p.checkTxnOps(); err != nil {
+		return err
+	}
+	if problems := ValidatePatch(*p); len(problems) > 0 {
+		return patchError(problems)
+	}
+	for _, rule := range p.SetRules {
+		if err := rule.checkAndAdjust(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (l *RegionLabel) expireBefore(t time.Time) bool {
 	failpoint.Inject("regionLabelExpireSub1Minute", func() {
 		if l.expire != nil {
@@ -121,9 +257,44 @@ func (l *RegionLabel) checkAndAdjustExpire() (err error) {
 	return nil
 }
 
+func (rule *LabelRule) checkAndAdjustExpire() error {
+	if len(rule.TTL) == 0 && len(rule.ExpireAt) == 0 {
+		rule.expire = nil
+		return nil
+	}
+	// ExpireAt is persisted, so the rule expires at the same time after reloaded.
+	if len(rule.ExpireAt) == 0 {
+		ttl, err := time.ParseDuration(rule.TTL)
+		if err != nil {
+			return err
+		}
+		rule.ExpireAt = time.Now().Add(ttl).Format(time.RFC3339Nano)
+	}
+	expire, err := time.Parse(time.RFC3339, rule.ExpireAt)
+	if err != nil {
+		return err
+	}
+	rule.expire = &expire
+	return nil
+}
+
+// expired returns whether the rule itself has expired.
+func (rule *LabelRule) expired(now time.Time) bool {
+	return rule.expire != nil && rule.expire.Before(now)
+}
+
 func (rule *LabelRule) checkAndRemoveExpireLabels(now time.Time) bool {
+	// all labels expire with the rule.
+	if rule.expired(now) {
+		rule.minExpire = nil
+		if len(rule.Labels) == 0 {
+			return false
+		}
+		rule.Labels = nil
+		return true
+	}
 	labels := make([]RegionLabel, 0)
-	rule.minExpire = nil
+	rule.minExpire = rule.expire
 	for _, l := range rule.Labels {
 		if l.expireBefore(now) {
 			continue
@@ -160,19 +331,36 @@ func (rule *LabelRule) checkAndAdjust() error {
 			return errs.ErrRegionRuleContent.FastGenByArgs(err)
 		}
 	}
+	if err := rule.checkAndAdjustExpire(); err != nil {
+		return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("label rule with invalid ttl info %v", err))
+	}
 	rule.checkAndRemoveExpireLabels(time.Now())
 	if len(rule.Labels) == 0 {
 		return errs.ErrRegionRuleContent.FastGenByArgs("region label with expired ttl")
 	}
 
-	// TODO: change it to switch statement once we support more types.
-	if rule.RuleType == KeyRange {
-		var err error
-		rule.Data, err = initKeyRangeRulesFromLabelRuleData(rule.Data)
+	data, err := normalizeLabelRuleData(rule.Data)
+	if err != nil {
 		return err
 	}
-	log.Error("invalid rule type", zap.String("rule-type", rule.RuleType))
-	return errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %s", rule.RuleType))
+	if err := validateLabelRuleData(rule.RuleType, data); err != nil {
+		return err
+	}
+	rule.Data = data
+	switch rule.RuleType {
+	case KeyRange:
+		rule.Data, err = initKeyRangeRulesFromLabelRuleData(rule.Data)
+	case KeyPrefix:
+		rule.Data, rule.prefixes, err = initKeyPrefixesFromLabelRuleData(rule.Data)
+	case RegionID:
+		rule.Data, err = initRegionIDsFromLabelRuleData(rule.Data)
+	case HotRegion:
+		rule.Data, err = initHotRegionThresholdsFromLabelRuleData(rule.Data)
+	default:
+		log.Error("invalid rule type", zap.String("rule-type", rule.RuleType))
+		err = errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %s", rule.RuleType))
+	}
+	return err
 }
 
 func (rule *LabelRule) expireBefore(t time.Time) bool {
@@ -232,3 +420,54 @@ func initAndAdjustKeyRangeRule(rule interface{}) (*KeyRangeRule, error) {
 	}
 	return &r, nil
 }
+
+// initKeyPrefixesFromLabelRuleData inits the hex format prefixes from
+// `LabelRule.Data`, and returns them with the decoded ones.
+func initKeyPrefixesFromLabelRuleData(data interface{}) ([]string, [][]byte, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %T", data))
+	}
+	if len(items) == 0 {
+		return nil, nil, errs.ErrRegionRuleContent.FastGenByArgs("no key prefixes")
+	}
+	hexPrefixes := make([]string, 0, len(items))
+	prefixes := make([][]byte, 0, len(items))
+	for _, item := range items {
+		hexPrefix, ok := item.(string)
+		if !ok {
+			return nil, nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid prefix type: %T", item))
+		}
+		prefix, err := hex.DecodeString(hexPrefix)
+		if err != nil {
+			return nil, nil, errs.ErrHexDecodingString.FastGenByArgs(hexPrefix)
+		}
+		if len(prefix) == 0 {
+			return nil, nil, errs.ErrRegionRuleContent.FastGenByArgs("empty key prefix")
+		}
+		hexPrefixes = append(hexPrefixes, hexPrefix)
+		prefixes = append(prefixes, prefix)
+	}
+	return hexPrefixes, prefixes, nil
+}
+
+// initRegionIDsFromLabelRuleData inits the region IDs from `LabelRule.Data`.
+func initRegionIDsFromLabelRuleData(data interface{}) ([]uint64, error) {
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule type: %T", data))
+	}
+	if len(items) == 0 {
+		return nil, errs.ErrRegionRuleContent.FastGenByArgs("no region IDs")
+	}
+	ids := make([]uint64, 0, len(items))
+	for _, item := range items {
+		// the numbers are decoded from JSON as float64.
+		id, ok := item.(float64)
+		if !ok || id <= 0 || id != math.Trunc(id) {
+			return nil, errs.ErrRegionRuleContent.FastGenByArgs(fmt.Sprintf("invalid region ID: %v", item))
+		}
+		ids = append(ids, uint64(id))
+	}
+	return ids, nil
+}
@@ -357,7 +357,7 @@ func (b *Builder) SetExpectedRoles(roles map[uint64]placement.PeerRoleType) *Bui
 			leaderCount++
 		case placement.Voter:
 			voterCount++
-		case placement.Follower, placement.Learner:
+		case placement.Follower, placement.Learner, placement.Witness:
 			if b.targetLeaderStoreID == id {
 				b.targetLeaderStoreID = 0
 			}
@@ -922,7 +922,7 @@ func (b *Builder) allowLeader(peer *metapb.Peer, ignoreClusterLimit bool) bool {
 	}
 	for _, r := range b.rules {
 		if (r.Role == placement.Leader || r.Role == placement.Voter) &&
-			placement.MatchLabelConstraints(store, r.LabelConstraints) {
+			r.MatchStore(store) {
 			return true
 		}
 	}
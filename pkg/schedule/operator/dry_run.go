@@ -0,0 +1,138 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxDryRunRecords is the max number of the regions whose dry-run
+	// operators are recorded, the oldest one is evicted if exceeded.
+	maxDryRunRecords = 1024
+	// dryRunRecordRemainTime is how long the dry-run operator is recorded
+	// after it is computed last time.
+	dryRunRecordRemainTime = 10 * time.Minute
+)
+
+// DryRunRecord is an operator computed in the dry-run mode, which is recorded
+// instead of being dispatched to the stores.
+type DryRunRecord struct {
+	RegionID uint64 `json:"region-id"`
+	Desc     string `json:"desc"`
+	Kind     string `json:"kind"`
+	Operator string `json:"operator"`
+	// Count is the number of the times the same operator is computed, the
+	// schedulers keep computing it as it is never executed.
+	Count     uint64    `json:"count"`
+	FirstTime time.Time `json:"first-time"`
+	LastTime  time.Time `json:"last-time"`
+}
+
+// dryRunRecords records the latest dry-run operator of each region.
+type dryRunRecords struct {
+	syncutil.Mutex
+	records map[uint64]*DryRunRecord
+}
+
+func newDryRunRecords() *dryRunRecords {
+	return &dryRunRecords{records: make(map[uint64]*DryRunRecord)}
+}
+
+func (r *dryRunRecords) put(op *Operator, now time.Time) {
+	r.Lock()
+	defer r.Unlock()
+	brief := op.String()
+	if record, ok := r.records[op.RegionID()]; ok && record.Operator == brief {
+		record.Count++
+		record.LastTime = now
+		return
+	}
+	if len(r.records) >= maxDryRunRecords {
+		r.evictLocked(now)
+	}
+	r.records[op.RegionID()] = &DryRunRecord{
+		RegionID:  op.RegionID(),
+		Desc:      op.Desc(),
+		Kind:      op.Kind().String(),
+		Operator:  brief,
+		Count:     1,
+		FirstTime: now,
+		LastTime:  now,
+	}
+	operatorDryRunCounter.WithLabelValues(op.Desc()).Inc()
+	log.Info("operator is computed in the dry-run mode",
+		zap.Uint64("region-id", op.RegionID()), zap.Stringer("operator", op))
+}
+
+// evictLocked removes the expired records, or the oldest one if none expires.
+func (r *dryRunRecords) evictLocked(now time.Time) {
+	var oldest *DryRunRecord
+	for id, record := range r.records {
+		if now.Sub(record.LastTime) > dryRunRecordRemainTime {
+			delete(r.records, id)
+			continue
+		}
+		if oldest == nil || record.LastTime.Before(oldest.LastTime) {
+			oldest = record
+		}
+	}
+	if len(r.records) >= maxDryRunRecords && oldest != nil {
+		delete(r.records, oldest.RegionID)
+	}
+}
+
+// get returns the records which are computed since the time, the latest first.
+func (r *dryRunRecords) get(from, now time.Time) []DryRunRecord {
+	r.Lock()
+	defer r.Unlock()
+	records := make([]DryRunRecord, 0, len(r.records))
+	for _, record := range r.records {
+		if now.Sub(record.LastTime) > dryRunRecordRemainTime || record.LastTime.Before(from) {
+			continue
+		}
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].LastTime.Equal(records[j].LastTime) {
+			return records[i].LastTime.After(records[j].LastTime)
+		}
+		return records[i].RegionID < records[j].RegionID
+	})
+	return records
+}
+
+// isDryRun returns whether the operator should be recorded rather than being
+// dispatched. The operators created by the admin are always dispatched.
+func (oc *Controller) isDryRun(op *Operator) bool {
+	return op.Kind()&OpAdmin == 0 && oc.config.IsSchedulingDryRunEnabled()
+}
+
+// recordDryRun records the operator computed in the dry-run mode.
+func (oc *Controller) recordDryRun(op *Operator) {
+	_ = op.Cancel(DryRun)
+	oc.dryRunRecords.put(op, time.Now())
+}
+
+// GetDryRunRecords returns the operators computed in the dry-run mode since
+// the time, the latest first.
+func (oc *Controller) GetDryRunRecords(from time.Time) []DryRunRecord {
+	return oc.dryRunRecords.get(from, time.Now())
+}
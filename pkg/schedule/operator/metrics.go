@@ -78,6 +78,30 @@ var (
 			Help:      "Bucketed histogram of the operator region size.",
 			Buckets:   prometheus.ExponentialBuckets(1, 2, 20), // 1MB~1TB
 		}, []string{"type"})
+
+	storeWarmupGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "store_warmup_ratio",
+			Help:      "The ratio of the add-peer store limit of the stores in the join warm-up window.",
+		}, []string{"store"})
+
+	storeThrottleGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "store_throttle_factor",
+			Help:      "The ratio of the store limit of the stores which are throttled as they are struggling.",
+		}, []string{"store"})
+
+	operatorDryRunCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "schedule",
+			Name:      "dry_run_operators",
+			Help:      "Counter of the operators computed in the dry-run mode, which are not dispatched.",
+		}, []string{"type"})
 )
 
 func init() {
@@ -88,4 +112,7 @@ func init() {
 	prometheus.MustRegister(operatorDuration)
 	prometheus.MustRegister(operatorSizeHist)
 	prometheus.MustRegister(storeLimitCostCounter)
+	prometheus.MustRegister(storeWarmupGauge)
+	prometheus.MustRegister(storeThrottleGauge)
+	prometheus.MustRegister(operatorDryRunCounter)
 }
@@ -64,6 +64,8 @@ var (
 	ExceedWaitLimit CancelReasonType = "exceed wait limit"
 	// RelatedMergeRegion is the cancel reason when the operator is cancelled by related merge region.
 	RelatedMergeRegion CancelReasonType = "related merge region"
+	// DryRun is the cancel reason when the operator is only recorded in the dry-run mode.
+	DryRun CancelReasonType = "dry run"
 	// Unknown is the cancel reason when the operator is cancelled by an unknown reason.
 	Unknown CancelReasonType = "unknown"
 )
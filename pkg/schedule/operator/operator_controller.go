@@ -18,6 +18,7 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
+	"math"
 	"strconv"
 	"time"
 
@@ -48,6 +49,10 @@ var (
 	fastNotifyInterval = 2 * time.Second
 	// StoreBalanceBaseTime represents the base time of balance rate.
 	StoreBalanceBaseTime float64 = 60
+	// storeWarmupSteps is the number of steps to ramp up the add-peer rate in the warm-up window.
+	// The rate is changed by steps rather than continuously because resetting the store limit
+	// refills the tokens.
+	storeWarmupSteps float64 = 10
 	// FastOperatorFinishTime min finish time, if finish duration less than it, op will be pushed to fast operator queue
 	FastOperatorFinishTime = 10 * time.Second
 )
@@ -66,6 +71,16 @@ type Controller struct {
 	wop             WaitingOperator
 	wopStatus       *waitingOperatorStatus
 	opNotifierQueue operatorQueue
+	// ownStoreLimits is not nil if the controller limits its operators with
+	// its own store limits rather than the ones shared by the stores.
+	ownStoreLimits map[uint64]*storelimit.StoreRateLimit
+	// warmupLimits limit the add-peer rate of the balance operators to the
+	// stores in the join warm-up window, on top of the store limits.
+	warmupLimits map[uint64]*storelimit.StoreRateLimit
+	// hasConflictOperator returns whether the region has an operator which is
+	// managed by another controller.
+	hasConflictOperator func(regionID uint64) bool
+	dryRunRecords       *dryRunRecords
 }
 
 // NewController creates a Controller.
@@ -82,9 +97,30 @@ func NewController(ctx context.Context, cluster *core.BasicCluster, config confi
 		wop:             newRandBuckets(),
 		wopStatus:       newWaitingOperatorStatus(),
 		opNotifierQueue: make(operatorQueue, 0),
+		dryRunRecords:   newDryRunRecords(),
+		warmupLimits:    make(map[uint64]*storelimit.StoreRateLimit),
 	}
 }
 
+// EnableOwnStoreLimit makes the controller limit its operators with its own
+// store limits, which are independent of the store limits shared by the other
+// controllers. It should be called before any operator is added.
+func (oc *Controller) EnableOwnStoreLimit() {
+	oc.Lock()
+	defer oc.Unlock()
+	oc.ownStoreLimits = make(map[uint64]*storelimit.StoreRateLimit)
+}
+
+// SetConflictOperatorChecker sets the function telling whether the region has
+// an operator managed by another controller, the operator is not added to the
+// region which has. It should be called before any operator is added, and the
+// function must not call back into this controller.
+func (oc *Controller) SetConflictOperatorChecker(hasConflictOperator func(regionID uint64) bool) {
+	oc.Lock()
+	defer oc.Unlock()
+	oc.hasConflictOperator = hasConflictOperator
+}
+
 // Ctx returns a context which will be canceled once RaftCluster is stopped.
 // For now, it is only used to control the lifetime of TTL cache in schedulers.
 func (oc *Controller) Ctx() context.Context {
@@ -297,6 +333,14 @@ func (oc *Controller) AddWaitingOperator(ops ...*Operator) int {
 			}
 			continue
 		}
+		if oc.isDryRun(op) {
+			oc.recordDryRun(op)
+			if isMerge {
+				i++
+				oc.recordDryRun(ops[i])
+			}
+			continue
+		}
 		oc.wop.PutOperator(op)
 		if isMerge {
 			// count two merge operators as one, so wopStatus.ops[desc] should
@@ -342,6 +386,12 @@ func (oc *Controller) AddOperator(ops ...*Operator) bool {
 		}
 		return false
 	}
+	if len(ops) > 0 && oc.isDryRun(ops[0]) {
+		for _, op := range ops {
+			oc.recordDryRun(op)
+		}
+		return false
+	}
 	for _, op := range ops {
 		if !oc.addOperatorLocked(op) {
 			return false
@@ -354,10 +404,21 @@ func (oc *Controller) AddOperator(ops ...*Operator) bool {
 func (oc *Controller) PromoteWaitingOperator() {
 	oc.Lock()
 	defer oc.Unlock()
+	oc.wop.SetPriorityWeights(oc.config.GetOperatorPriorityWeights())
 	var ops []*Operator
 	for {
-		// GetOperator returns one operator or two merge operators
-		ops = oc.wop.GetOperator()
+		// The recovery operators preempt the others when the operators are near the limits,
+		// so that the replicas are repaired before the balance operators use up the limits.
+		ops = nil
+		if oc.isNearLimitLocked() {
+			if ops = oc.wop.GetRecoveryOperator(); ops != nil {
+				operatorCounter.WithLabelValues(ops[0].Desc(), "preempt").Inc()
+			}
+		}
+		if ops == nil {
+			// GetOperator returns one operator or two merge operators
+			ops = oc.wop.GetOperator()
+		}
 		if ops == nil {
 			return
 		}
@@ -382,6 +443,13 @@ func (oc *Controller) PromoteWaitingOperator() {
 			continue
 		}
 		oc.wopStatus.ops[ops[0].Desc()]--
+		// The waiting operators may be added before the dry-run mode is enabled.
+		if oc.isDryRun(ops[0]) {
+			for _, op := range ops {
+				oc.recordDryRun(op)
+			}
+			continue
+		}
 		break
 	}
 
@@ -392,6 +460,24 @@ func (oc *Controller) PromoteWaitingOperator() {
 	}
 }
 
+// isNearLimitLocked returns whether the count of the operators of any kind
+// reaches the preempt ratio of its schedule limit.
+func (oc *Controller) isNearLimitLocked() bool {
+	ratio := oc.config.GetRecoveryPreemptRatio()
+	limits := map[OpKind]uint64{
+		OpLeader:    oc.config.GetLeaderScheduleLimit(),
+		OpRegion:    oc.config.GetRegionScheduleLimit(),
+		OpHotRegion: oc.config.GetHotRegionScheduleLimit(),
+		OpReplica:   oc.config.GetReplicaScheduleLimit(),
+	}
+	for kind, limit := range limits {
+		if limit > 0 && float64(oc.counts[kind]) >= ratio*float64(limit) {
+			return true
+		}
+	}
+	return false
+}
+
 // checkAddOperator checks if the operator can be added.
 // There are several situations that cannot be added:
 // - There is no such region in the cluster
@@ -424,6 +510,12 @@ func (oc *Controller) checkAddOperator(isPromoting bool, ops ...*Operator) (bool
 			operatorCounter.WithLabelValues(op.Desc(), "already-have").Inc()
 			return false, AlreadyExist
 		}
+		if oc.hasConflictOperator != nil && oc.hasConflictOperator(op.RegionID()) {
+			log.Debug("already have operator in another controller, cancel add operator",
+				zap.Uint64("region-id", op.RegionID()))
+			operatorCounter.WithLabelValues(op.Desc(), "already-have-in-other").Inc()
+			return false, AlreadyExist
+		}
 		if op.Status() != CREATED {
 			log.Error("trying to add operator with unexpected status",
 				zap.Uint64("region-id", op.RegionID()),
@@ -501,7 +593,15 @@ func (oc *Controller) addOperatorLocked(op *Operator) bool {
 			if stepCost == 0 {
 				continue
 			}
+			if oc.ownStoreLimits != nil {
+				limit = oc.getOrCreateStoreLimit(storeID, v)
+			}
 			limit.Take(stepCost, v, op.GetPriorityLevel())
+			if v == storelimit.AddPeer && isWarmupThrottled(op) {
+				if warmupLimit := oc.getStoreWarmupLimit(storeID); warmupLimit != nil {
+					warmupLimit.Take(stepCost, v, op.GetPriorityLevel())
+				}
+			}
 			storeLimitCostCounter.WithLabelValues(strconv.FormatUint(storeID, 10), n).Add(float64(stepCost) / float64(storelimit.RegionInfluence[v]))
 		}
 	}
@@ -907,6 +1007,13 @@ func (oc *Controller) exceedStoreLimitLocked(ops ...*Operator) bool {
 				OperatorExceededStoreLimitCounter.WithLabelValues(desc).Inc()
 				return true
 			}
+			if v != storelimit.AddPeer || !isWarmupThrottled(ops[0]) {
+				continue
+			}
+			if warmupLimit := oc.getStoreWarmupLimit(storeID); warmupLimit != nil && !warmupLimit.Available(stepCost, v, ops[0].GetPriorityLevel()) {
+				OperatorExceededStoreLimitCounter.WithLabelValues(desc).Inc()
+				return true
+			}
 		}
 	}
 	return false
@@ -920,9 +1027,90 @@ func (oc *Controller) getOrCreateStoreLimit(storeID uint64, limitType storelimit
 		log.Error("invalid store ID", zap.Uint64("store-id", storeID))
 		return nil
 	}
+	ratePerSec *= getStoreThrottleFactor(s)
+	if oc.ownStoreLimits != nil {
+		limit, ok := oc.ownStoreLimits[storeID]
+		if !ok {
+			limit = storelimit.NewStoreRateLimit(ratePerSec).(*storelimit.StoreRateLimit)
+			oc.ownStoreLimits[storeID] = limit
+		}
+		if limit.Rate(limitType) != ratePerSec {
+			limit.Reset(ratePerSec, limitType)
+		}
+		return limit
+	}
 	// The other limits do not need to update by config exclude StoreRateLimit.
 	if limit, ok := s.GetStoreLimit().(*storelimit.StoreRateLimit); ok && limit.Rate(limitType) != ratePerSec {
 		oc.cluster.ResetStoreLimit(storeID, limitType, ratePerSec)
 	}
 	return s.GetStoreLimit()
 }
+
+// isWarmupThrottled returns whether the operator is throttled by the warm-up
+// of the stores. Only the balance operators are throttled, the ones repairing
+// the replicas or created by the admin are not delayed.
+func isWarmupThrottled(op *Operator) bool {
+	return op.Kind()&OpRegion != 0 && op.Kind()&(OpReplica|OpAdmin) == 0
+}
+
+// getStoreWarmupLimit returns the add-peer limit of the balance operators to
+// the store in the warm-up window, or nil if the store is not warming up.
+func (oc *Controller) getStoreWarmupLimit(storeID uint64) *storelimit.StoreRateLimit {
+	s := oc.cluster.GetStore(storeID)
+	if s == nil {
+		delete(oc.warmupLimits, storeID)
+		return nil
+	}
+	ratio := oc.getStoreWarmupRatio(s)
+	if ratio >= 1 {
+		delete(oc.warmupLimits, storeID)
+		return nil
+	}
+	ratePerSec := oc.config.GetStoreLimitByType(storeID, storelimit.AddPeer) / StoreBalanceBaseTime * ratio
+	limit, ok := oc.warmupLimits[storeID]
+	if !ok {
+		limit = storelimit.NewStoreRateLimit(ratePerSec).(*storelimit.StoreRateLimit)
+		oc.warmupLimits[storeID] = limit
+	}
+	if limit.Rate(storelimit.AddPeer) != ratePerSec {
+		limit.Reset(ratePerSec, storelimit.AddPeer)
+	}
+	return limit
+}
+
+// getStoreWarmupRatio returns the ratio of the add-peer store limit that the
+// balance operators can take to a store. It ramps up from the initial ratio
+// to 1 in the warm-up window after the store joins. The restart of the store
+// does not start the warm-up again, and the stores joined before the current
+// leader started are regarded as warmed up.
+func (oc *Controller) getStoreWarmupRatio(store *core.StoreInfo) float64 {
+	storeID := strconv.FormatUint(store.GetID(), 10)
+	window, joinTime := oc.config.GetStoreWarmupDuration(), store.GetJoinTime()
+	elapsed := time.Since(joinTime)
+	if window <= 0 || joinTime.IsZero() || elapsed >= window {
+		storeWarmupGauge.DeleteLabelValues(storeID)
+		return 1
+	}
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	initialRatio := oc.config.GetStoreWarmupInitialRatio()
+	progress := math.Floor(float64(elapsed)/float64(window)*storeWarmupSteps) / storeWarmupSteps
+	ratio := initialRatio + (1-initialRatio)*progress
+	storeWarmupGauge.WithLabelValues(storeID).Set(ratio)
+	return ratio
+}
+
+// getStoreThrottleFactor returns the ratio of the store limit that a store
+// can take, which is lowered by the feedback of the store heartbeats once
+// the store is struggling.
+func getStoreThrottleFactor(store *core.StoreInfo) float64 {
+	storeID := strconv.FormatUint(store.GetID(), 10)
+	factor := store.GetThrottleFactor()
+	if factor >= 1 {
+		storeThrottleGauge.DeleteLabelValues(storeID)
+		return 1
+	}
+	storeThrottleGauge.WithLabelValues(storeID).Set(factor)
+	return factor
+}
@@ -28,11 +28,13 @@ import (
 	"github.com/pingcap/kvproto/pkg/pdpb"
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/constant"
 	"github.com/tikv/pd/pkg/core/storelimit"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
 	"github.com/tikv/pd/pkg/mock/mockconfig"
 	"github.com/tikv/pd/pkg/schedule/hbstream"
 	"github.com/tikv/pd/pkg/schedule/labeler"
+	"github.com/tikv/pd/pkg/utils/typeutil"
 )
 
 type operatorControllerTestSuite struct {
@@ -465,6 +467,173 @@ func (suite *operatorControllerTestSuite) TestStoreLimit() {
 	suite.False(oc.RemoveOperator(op))
 }
 
+func (suite *operatorControllerTestSuite) TestStoreWarmup() {
+	opt := mockconfig.NewTestOptions()
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.StoreWarmupDuration = typeutil.NewDuration(time.Hour)
+	cfg.StoreWarmupInitialRatio = 0.5
+	opt.SetScheduleConfig(cfg)
+	tc := mockcluster.NewCluster(suite.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(suite.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	warmupRate := func(storeID uint64) float64 {
+		limit := oc.getStoreWarmupLimit(storeID)
+		if limit == nil {
+			return oc.getOrCreateStoreLimit(storeID, storelimit.AddPeer).(*storelimit.StoreRateLimit).Rate(storelimit.AddPeer)
+		}
+		return limit.Rate(storelimit.AddPeer)
+	}
+	joinStore := func(storeID uint64, joined time.Duration) {
+		tc.PutStore(tc.GetStore(storeID).Clone(core.SetStoreJoinTime(time.Now().Add(-joined))))
+	}
+
+	// the store just joins.
+	joinStore(2, 0)
+	suite.Equal(0.5, warmupRate(2))
+	suite.Equal(1.0, oc.getOrCreateStoreLimit(2, storelimit.AddPeer).(*storelimit.StoreRateLimit).Rate(storelimit.AddPeer))
+	// the store joined before the leader started is not throttled.
+	suite.Equal(1.0, warmupRate(1))
+	// ramps up in the window, and the restart of the store changes nothing.
+	joinStore(2, 35*time.Minute)
+	tc.PutStore(tc.GetStore(2).Clone(core.SetStoreStartTime(time.Now().Unix())))
+	suite.Equal(0.75, warmupRate(2))
+
+	// only the balance operators are throttled.
+	balance := NewTestOperator(1, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: 1})
+	replica := NewTestOperator(1, &metapb.RegionEpoch{}, OpRegion|OpReplica, AddPeer{ToStore: 2, PeerID: 1})
+	suite.True(isWarmupThrottled(balance))
+	suite.False(isWarmupThrottled(replica))
+	suite.False(isWarmupThrottled(NewTestOperator(1, &metapb.RegionEpoch{}, OpRegion|OpAdmin, AddPeer{ToStore: 2, PeerID: 1})))
+
+	// full rate after the window.
+	joinStore(2, 2*time.Hour)
+	suite.Equal(1.0, warmupRate(2))
+	suite.Empty(oc.warmupLimits)
+
+	// disabled by default.
+	cfg = opt.GetScheduleConfig().Clone()
+	cfg.StoreWarmupDuration = typeutil.NewDuration(0)
+	opt.SetScheduleConfig(cfg)
+	joinStore(2, 0)
+	suite.Equal(1.0, warmupRate(2))
+}
+
+func (suite *operatorControllerTestSuite) TestStoreThrottle() {
+	opt := mockconfig.NewTestOptions()
+	tc := mockcluster.NewCluster(suite.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(suite.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	rate := func(storeID uint64, typ storelimit.Type) float64 {
+		return oc.getOrCreateStoreLimit(storeID, typ).(*storelimit.StoreRateLimit).Rate(typ)
+	}
+
+	tc.PutStore(tc.GetStore(2).Clone(core.SetThrottleFactor(0.25)))
+	suite.Equal(0.25, rate(2, storelimit.AddPeer))
+	suite.Equal(0.25, rate(2, storelimit.RemovePeer))
+	suite.Equal(1.0, rate(1, storelimit.AddPeer))
+	// The full rate is restored once the store recovers.
+	tc.PutStore(tc.GetStore(2).Clone(core.SetThrottleFactor(1)))
+	suite.Equal(1.0, rate(2, storelimit.AddPeer))
+}
+
+func (suite *operatorControllerTestSuite) TestOwnStoreLimit() {
+	opt := mockconfig.NewTestOptions()
+	tc := mockcluster.NewCluster(suite.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(suite.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	ownOC := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	ownOC.EnableOwnStoreLimit()
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	for i := uint64(1); i <= 10; i++ {
+		tc.AddLeaderRegion(i, 1)
+		tc.PutRegion(tc.GetRegion(i).Clone(core.SetApproximateSize(10)))
+	}
+
+	tc.SetStoreLimit(2, storelimit.AddPeer, 60)
+	for i := uint64(1); i <= 5; i++ {
+		op := NewTestOperator(i, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: i})
+		suite.True(oc.AddOperator(op))
+		suite.checkRemoveOperatorSuccess(oc, op)
+	}
+	suite.True(oc.ExceedStoreLimit(NewTestOperator(6, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: 6})))
+	// The store limit of the shared one is exhausted, but the own one is not.
+	for i := uint64(6); i <= 10; i++ {
+		op := NewTestOperator(i, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: i})
+		suite.True(ownOC.AddOperator(op))
+		suite.checkRemoveOperatorSuccess(ownOC, op)
+	}
+	op := NewTestOperator(1, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: 1})
+	suite.False(ownOC.AddOperator(op))
+}
+
+func (suite *operatorControllerTestSuite) TestConflictOperatorChecker() {
+	opt := mockconfig.NewTestOptions()
+	tc := mockcluster.NewCluster(suite.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(suite.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	otherOC := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	oc.SetConflictOperatorChecker(func(regionID uint64) bool {
+		return otherOC.GetOperator(regionID) != nil
+	})
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+
+	suite.True(otherOC.AddOperator(NewTestOperator(1, &metapb.RegionEpoch{}, OpLeader, TransferLeader{FromStore: 1, ToStore: 2})))
+	suite.False(oc.AddOperator(NewTestOperator(1, &metapb.RegionEpoch{}, OpLeader, TransferLeader{FromStore: 1, ToStore: 2})))
+	suite.True(oc.AddOperator(NewTestOperator(2, &metapb.RegionEpoch{}, OpLeader, TransferLeader{FromStore: 1, ToStore: 2})))
+}
+
+func (suite *operatorControllerTestSuite) TestDryRun() {
+	opt := mockconfig.NewTestOptions()
+	tc := mockcluster.NewCluster(suite.ctx, opt)
+	stream := hbstream.NewTestHeartbeatStreams(suite.ctx, tc.ID, tc, false /* no need to run */)
+	oc := NewController(suite.ctx, tc.GetBasicCluster(), tc.GetSharedConfig(), stream)
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderRegion(1, 1, 2)
+	tc.AddLeaderRegion(2, 1, 2)
+	tc.SetEnableSchedulingDryRun(true)
+
+	// The operators are recorded rather than added.
+	for i := 0; i < 3; i++ {
+		op := NewTestOperator(1, &metapb.RegionEpoch{}, OpLeader, TransferLeader{FromStore: 1, ToStore: 2})
+		suite.Equal(0, oc.AddWaitingOperator(op))
+		suite.Equal(CANCELED, op.Status())
+	}
+	op := NewTestOperator(2, &metapb.RegionEpoch{}, OpRegion, AddPeer{ToStore: 2, PeerID: 3})
+	suite.False(oc.AddOperator(op))
+	suite.Nil(oc.GetOperator(1))
+	suite.Nil(oc.GetOperator(2))
+	records := oc.GetDryRunRecords(time.Time{})
+	suite.Len(records, 2)
+	suite.ElementsMatch([]uint64{1, 2}, []uint64{records[0].RegionID, records[1].RegionID})
+	for _, record := range records {
+		if record.RegionID == 1 {
+			suite.Equal(uint64(3), record.Count)
+		} else {
+			suite.Equal(uint64(1), record.Count)
+		}
+	}
+	suite.Empty(oc.GetDryRunRecords(time.Now().Add(time.Minute)))
+
+	// The operators of the admin are still added.
+	op = NewTestOperator(1, &metapb.RegionEpoch{}, OpLeader|OpAdmin, TransferLeader{FromStore: 1, ToStore: 2})
+	suite.True(oc.AddOperator(op))
+	suite.NotNil(oc.GetOperator(1))
+
+	tc.SetEnableSchedulingDryRun(false)
+	op = NewTestOperator(2, &metapb.RegionEpoch{}, OpLeader, TransferLeader{FromStore: 1, ToStore: 2})
+	suite.Equal(1, oc.AddWaitingOperator(op))
+	suite.NotNil(oc.GetOperator(2))
+}
+
 // #1652
 func (suite *operatorControllerTestSuite) TestDispatchOutdatedRegion() {
 	cluster := mockcluster.NewCluster(suite.ctx, mockconfig.NewTestOptions())
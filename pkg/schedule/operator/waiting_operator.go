@@ -25,7 +25,9 @@ var priorityWeight = []float64{1.0, 4.0, 9.0, 16.0}
 type WaitingOperator interface {
 	PutOperator(op *Operator)
 	GetOperator() []*Operator
+	GetRecoveryOperator() []*Operator
 	ListOperator() []*Operator
+	SetPriorityWeights(weights []float64)
 }
 
 // bucket is used to maintain the operators created by a specific scheduler.
@@ -61,6 +63,26 @@ func (b *randBuckets) PutOperator(op *Operator) {
 	bucket.ops = append(bucket.ops, op)
 }
 
+// SetPriorityWeights updates the weights of the priority levels, the invalid
+// weights are ignored.
+func (b *randBuckets) SetPriorityWeights(weights []float64) {
+	if len(weights) != len(b.buckets) {
+		return
+	}
+	for _, weight := range weights {
+		if weight <= 0 {
+			return
+		}
+	}
+	b.totalWeight = 0
+	for i, bucket := range b.buckets {
+		bucket.weight = weights[i]
+		if len(bucket.ops) > 0 {
+			b.totalWeight += bucket.weight
+		}
+	}
+}
+
 // ListOperator lists all operator in the random buckets.
 func (b *randBuckets) ListOperator() []*Operator {
 	var ops []*Operator
@@ -85,25 +107,52 @@ func (b *randBuckets) GetOperator() []*Operator {
 		}
 		proportion := bucket.weight / b.totalWeight
 		if r >= sum && r < sum+proportion {
-			var res []*Operator
-			res = append(res, bucket.ops[0])
-			// Merge operation has two operators, and thus it should be handled specifically.
-			if bucket.ops[0].Kind()&OpMerge != 0 {
-				res = append(res, bucket.ops[1])
-				bucket.ops = bucket.ops[2:]
-			} else {
-				bucket.ops = bucket.ops[1:]
+			return b.takeOperator(bucket, 0)
+		}
+		sum += proportion
+	}
+	return nil
+}
+
+// GetRecoveryOperator gets the first recovery operator, which is created by
+// the checkers to repair the replicas, from the highest priority level.
+func (b *randBuckets) GetRecoveryOperator() []*Operator {
+	for i := len(b.buckets) - 1; i >= 0; i-- {
+		bucket := b.buckets[i]
+		for j := 0; j < len(bucket.ops); j++ {
+			op := bucket.ops[j]
+			if op.Kind()&OpMerge != 0 {
+				// Skip the pair of the merge operators.
+				j++
+				continue
 			}
-			if len(bucket.ops) == 0 {
-				b.totalWeight -= bucket.weight
+			if op.Kind()&OpReplica != 0 {
+				return b.takeOperator(bucket, j)
 			}
-			return res
 		}
-		sum += proportion
 	}
 	return nil
 }
 
+// takeOperator removes the operator at the index from the bucket and returns it.
+func (b *randBuckets) takeOperator(bucket *bucket, idx int) []*Operator {
+	n := 1
+	// Merge operation has two operators, and thus it should be handled specifically.
+	if bucket.ops[idx].Kind()&OpMerge != 0 {
+		n = 2
+	}
+	res := append([]*Operator(nil), bucket.ops[idx:idx+n]...)
+	if idx == 0 {
+		bucket.ops = bucket.ops[n:]
+	} else {
+		bucket.ops = append(bucket.ops[:idx], bucket.ops[idx+n:]...)
+	}
+	if len(bucket.ops) == 0 {
+		b.totalWeight -= bucket.weight
+	}
+	return res
+}
+
 // waitingOperatorStatus is used to limit the count of each kind of operators.
 type waitingOperatorStatus struct {
 	ops map[string]uint64
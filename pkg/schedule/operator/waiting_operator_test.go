@@ -116,3 +116,44 @@ func TestRandomBucketsWithMergeRegion(t *testing.T) {
 		re.Nil(rb.GetOperator())
 	}
 }
+
+func TestRecoveryOperator(t *testing.T) {
+	re := require.New(t)
+	rb := newRandBuckets()
+	addOperators(rb)
+	re.Nil(rb.GetRecoveryOperator())
+	for i, level := range []constant.PriorityLevel{constant.Low, constant.High} {
+		op := NewTestOperator(uint64(5+i), &metapb.RegionEpoch{}, OpReplica, []OpStep{
+			AddPeer{ToStore: uint64(5 + i)},
+		}...)
+		op.SetPriorityLevel(level)
+		rb.PutOperator(op)
+	}
+	// The recovery operator of the higher priority level is got first.
+	ops := rb.GetRecoveryOperator()
+	re.Len(ops, 1)
+	re.Equal(uint64(6), ops[0].RegionID())
+	ops = rb.GetRecoveryOperator()
+	re.Len(ops, 1)
+	re.Equal(uint64(5), ops[0].RegionID())
+	re.Nil(rb.GetRecoveryOperator())
+	re.Len(rb.ListOperator(), len(priorityWeight))
+}
+
+func TestSetPriorityWeights(t *testing.T) {
+	re := require.New(t)
+	rb := newRandBuckets()
+	addOperators(rb)
+	re.Equal(30.0, rb.totalWeight)
+	// The invalid weights are ignored.
+	rb.SetPriorityWeights([]float64{1, 2})
+	re.Equal(30.0, rb.totalWeight)
+	rb.SetPriorityWeights([]float64{1, 0, 1, 1})
+	re.Equal(30.0, rb.totalWeight)
+	// Only the urgent operator can be got almost surely.
+	rb.SetPriorityWeights([]float64{1e-9, 1e-9, 1e-9, 1})
+	ops := rb.GetOperator()
+	re.Len(ops, 1)
+	re.Equal(uint64(4), ops[0].RegionID())
+	re.InDelta(3e-9, rb.totalWeight, 1e-12)
+}
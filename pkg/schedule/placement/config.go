@@ -17,19 +17,26 @@ package placement
 import (
 	"bytes"
 	"encoding/json"
+	"sort"
 	"time"
+
+	"github.com/tikv/pd/pkg/storage/kv"
 )
 
-// ruleConfig contains rule and rule group configurations.
+// ruleConfig contains rule, rule group, rule template and rule tombstone configurations.
 type ruleConfig struct {
-	rules  map[[2]string]*Rule   // {group, id} => Rule
-	groups map[string]*RuleGroup // id => RuleGroup
+	rules      map[[2]string]*Rule          // {group, id} => Rule
+	groups     map[string]*RuleGroup        // id => RuleGroup
+	templates  map[string]*RuleTemplate     // id => RuleTemplate
+	tombstones map[[2]string]*RuleTombstone // {group, id} => RuleTombstone
 }
 
 func newRuleConfig() *ruleConfig {
 	return &ruleConfig{
-		rules:  make(map[[2]string]*Rule),
-		groups: make(map[string]*RuleGroup),
+		rules:      make(map[[2]string]*Rule),
+		groups:     make(map[string]*RuleGroup),
+		templates:  make(map[string]*RuleTemplate),
+		tombstones: make(map[[2]string]*RuleTombstone),
 	}
 }
 
@@ -52,6 +59,7 @@ func (c *ruleConfig) adjust() {
 		// setup group for `buildRuleList`
 		r.group = g
 	}
+	inheritLabelConstraints(c.iterateRules, c.getGroup)
 }
 
 func (c *ruleConfig) getRule(key [2]string) *Rule {
@@ -64,6 +72,34 @@ func (c *ruleConfig) iterateRules(f func(*Rule)) {
 	}
 }
 
+// ruleKeys returns the keys of the rules in order, including the ones of the
+// deleted rules in a patch.
+func (c *ruleConfig) ruleKeys() [][2]string {
+	keys := make([][2]string, 0, len(c.rules))
+	for key := range c.rules {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+// sortedRules returns the rules in order of the keys, the deleted rules in a
+// patch are skipped.
+func (c *ruleConfig) sortedRules() []*Rule {
+	rules := make([]*Rule, 0, len(c.rules))
+	for _, key := range c.ruleKeys() {
+		if r := c.rules[key]; r != nil {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
 func (c *ruleConfig) setRule(r *Rule) {
 	c.rules[r.Key()] = r
 }
@@ -90,6 +126,18 @@ func (c *ruleConfig) beginPatch() *ruleConfigPatch {
 type ruleConfigPatch struct {
 	c   *ruleConfig // original configuration to be updated
 	mut *ruleConfig // record all to-commit rules and groups
+	// changeFrozen allows the patch to change the frozen groups, it's only set
+	// by RuleManager.SetRuleGroup.
+	changeFrozen bool
+	// deleteDefaultRule allows the patch to delete the default rule, which is
+	// rejected otherwise, see RuleManager.checkDefaultRulePatch.
+	deleteDefaultRule bool
+	// checkSchedulable rejects the patch if it makes any region unschedulable,
+	// see RuleManager.checkSchedulable.
+	checkSchedulable bool
+	// extraOps are saved in the same transaction as the last changes of the
+	// patch, e.g., the state of the rule sets, see RuleManager.ActivateRuleSet.
+	extraOps []func(txn kv.Txn) error
 }
 
 func (p *ruleConfigPatch) setRule(r *Rule) {
@@ -118,6 +166,22 @@ func (p *ruleConfigPatch) deleteGroup(id string) {
 	p.setGroup(&RuleGroup{ID: id})
 }
 
+func (p *ruleConfigPatch) setTemplate(t *RuleTemplate) {
+	p.mut.templates[t.ID] = t
+}
+
+func (p *ruleConfigPatch) deleteTemplate(id string) {
+	p.mut.templates[id] = nil
+}
+
+func (p *ruleConfigPatch) setTombstone(t *RuleTombstone) {
+	p.mut.tombstones[t.Rule.Key()] = t
+}
+
+func (p *ruleConfigPatch) deleteTombstone(group, id string) {
+	p.mut.tombstones[[2]string{group, id}] = nil
+}
+
 func (p *ruleConfigPatch) iterateRules(f func(*Rule)) {
 	for _, r := range p.mut.rules {
 		if r != nil { // nil means delete.
@@ -134,6 +198,7 @@ func (p *ruleConfigPatch) iterateRules(f func(*Rule)) {
 func (p *ruleConfigPatch) adjust() {
 	// setup rule.group for `buildRuleList` use.
 	p.iterateRules(func(r *Rule) { r.group = p.getGroup(r.GroupID) })
+	inheritLabelConstraints(p.iterateRules, p.getGroup)
 }
 
 // trim unnecessary updates. For example, remove a rule then insert the same rule.
@@ -148,6 +213,16 @@ func (p *ruleConfigPatch) trim() {
 			delete(p.mut.groups, id)
 		}
 	}
+	for id, template := range p.mut.templates {
+		if jsonEquals(template, p.c.templates[id]) {
+			delete(p.mut.templates, id)
+		}
+	}
+	for key, tombstone := range p.mut.tombstones {
+		if jsonEquals(tombstone, p.c.tombstones[key]) {
+			delete(p.mut.tombstones, key)
+		}
+	}
 }
 
 // merge all mutations to ruleConfig.
@@ -173,6 +248,20 @@ func (p *ruleConfigPatch) commit() {
 	for id, group := range p.mut.groups {
 		p.c.groups[id] = group
 	}
+	for id, template := range p.mut.templates {
+		if template == nil {
+			delete(p.c.templates, id)
+		} else {
+			p.c.templates[id] = template
+		}
+	}
+	for key, tombstone := range p.mut.tombstones {
+		if tombstone == nil {
+			delete(p.c.tombstones, key)
+		} else {
+			p.c.tombstones[key] = tombstone
+		}
+	}
 	p.c.adjust()
 }
 
@@ -33,6 +33,8 @@ type RegionFit struct {
 	OrphanPeers  []*metapb.Peer `json:"orphan-peers"`
 	regionStores []*core.StoreInfo
 	rules        []*Rule
+	// ruleSetVersion is the version of the rule set when the fit is calculated.
+	ruleSetVersion uint64
 }
 
 // Replace return true if the replacement store is fit all constraints and isolation score is not less than the origin.
@@ -59,7 +61,7 @@ func (f *RegionFit) Replace(srcStoreID uint64, dstStore *core.StoreInfo) bool {
 	}
 
 	// the target store should be fit all constraints.
-	if !MatchLabelConstraints(dstStore, fit.Rule.LabelConstraints) {
+	if !fit.Rule.MatchStore(dstStore) {
 		return false
 	}
 
@@ -247,7 +249,7 @@ func (w *fitWorker) fitRule(index int) bool {
 		// 3. Don't select leader as witness.
 		// 4. Not selected by other rules.
 		for _, p := range w.peers {
-			if !p.selected && MatchLabelConstraints(p.store, w.rules[index].LabelConstraints) && !(p.isLeader && w.supportWitness && w.rules[index].IsWitness) {
+			if !p.selected && w.rules[index].MatchStore(p.store) && !(p.isLeader && w.supportWitness && w.rules[index].IsWitness) {
 				candidates = append(candidates, p)
 			}
 		}
@@ -383,6 +385,8 @@ func (p *fitPeer) matchRoleStrict(role PeerRoleType) bool {
 		return !core.IsLearner(p.Peer) && !p.isLeader
 	case Learner:
 		return core.IsLearner(p.Peer)
+	case Witness: // Witness matches a follower, since it can't be a leader.
+		return !core.IsLearner(p.Peer) && !p.isLeader
 	}
 	return false
 }
@@ -0,0 +1,204 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// RegionFitExplanation explains how the peers of a region fit the rules.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RegionFitExplanation struct {
+	RegionID    uint64                   `json:"region_id"`
+	Rules       []*RuleFitExplanation    `json:"rules"`
+	OrphanPeers []*OrphanPeerExplanation `json:"orphan_peers"`
+}
+
+// RuleFitExplanation explains how the peers of a region fit a rule.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleFitExplanation struct {
+	GroupID   string       `json:"group_id"`
+	ID        string       `json:"id"`
+	Role      PeerRoleType `json:"role"`
+	Count     int          `json:"count"`
+	Satisfied bool         `json:"satisfied"`
+	// Reason is why the rule is not satisfied, it's empty if satisfied.
+	Reason FitFailureReason `json:"reason,omitempty"`
+	// MatchedPeers are the peers selected by the rule.
+	MatchedPeers []*metapb.Peer `json:"matched_peers"`
+	// PeersWithDifferentRole are the matched peers whose roles need to be
+	// changed by scheduling.
+	PeersWithDifferentRole []*metapb.Peer `json:"peers_with_different_role,omitempty"`
+	// UnmatchedPeers are the peers of the region whose stores don't match the
+	// label constraints of the rule.
+	UnmatchedPeers []*PeerMismatch `json:"unmatched_peers,omitempty"`
+	// MatchingStores is the number of the alive stores matching the rule.
+	MatchingStores int `json:"matching_stores"`
+}
+
+// PeerMismatch explains why the store of a peer doesn't match a rule.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type PeerMismatch struct {
+	PeerID  uint64 `json:"peer_id"`
+	StoreID uint64 `json:"store_id"`
+	// FailedConstraints are the label constraints the store doesn't match. For
+	// the rule with alternatives, the ones of the closest alternative are
+	// reported.
+	FailedConstraints []*ConstraintFailure `json:"failed_constraints"`
+}
+
+// ConstraintFailure is a label constraint not matched by a store.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type ConstraintFailure struct {
+	// Constraint is nil if the store is rejected by its exclusive label not
+	// specified by the rule.
+	Constraint *LabelConstraint `json:"constraint,omitempty"`
+	// Label is the key of the label, and Value is the value of the label of
+	// the store, which is empty if the store doesn't have the label.
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// OrphanPeerExplanation explains why a peer is not selected by any rule.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type OrphanPeerExplanation struct {
+	PeerID  uint64 `json:"peer_id"`
+	StoreID uint64 `json:"store_id"`
+	Reason  string `json:"reason"`
+}
+
+// ExplainRegionFit fits the region to the rules like FitRegion, and explains
+// the result: the peers matched by each rule, the label constraints failed by
+// the other peers, and why the orphan peers are not selected.
+func (m *RuleManager) ExplainRegionFit(storeSet StoreSet, region *core.RegionInfo) *RegionFitExplanation {
+	fit := m.FitRegion(storeSet, region)
+	var stores []*core.StoreInfo
+	for _, s := range storeSet.GetStores() {
+		if !s.IsRemoved() {
+			stores = append(stores, s)
+		}
+	}
+	e := &RegionFitExplanation{
+		RegionID:    region.GetID(),
+		Rules:       make([]*RuleFitExplanation, 0, len(fit.RuleFits)),
+		OrphanPeers: make([]*OrphanPeerExplanation, 0, len(fit.OrphanPeers)),
+	}
+	for _, rf := range fit.RuleFits {
+		rfe := &RuleFitExplanation{
+			GroupID:                rf.Rule.GroupID,
+			ID:                     rf.Rule.ID,
+			Role:                   rf.Rule.Role,
+			Count:                  rf.Rule.Count,
+			Satisfied:              rf.IsSatisfied(),
+			MatchedPeers:           rf.Peers,
+			PeersWithDifferentRole: rf.PeersWithDifferentRole,
+		}
+		if !rfe.Satisfied {
+			rfe.Reason = fitFailureReason(rf, fit, stores)
+		}
+		for _, s := range stores {
+			if rf.Rule.MatchStore(s) {
+				rfe.MatchingStores++
+			}
+		}
+		for _, p := range region.GetPeers() {
+			store := storeSet.GetStore(p.GetStoreId())
+			if store == nil || rf.Rule.MatchStore(store) {
+				continue
+			}
+			rfe.UnmatchedPeers = append(rfe.UnmatchedPeers, &PeerMismatch{
+				PeerID:            p.GetId(),
+				StoreID:           p.GetStoreId(),
+				FailedConstraints: explainConstraintFailures(rf.Rule, store),
+			})
+		}
+		e.Rules = append(e.Rules, rfe)
+	}
+	for _, p := range fit.OrphanPeers {
+		e.OrphanPeers = append(e.OrphanPeers, &OrphanPeerExplanation{
+			PeerID:  p.GetId(),
+			StoreID: p.GetStoreId(),
+			Reason:  explainOrphanPeer(storeSet.GetStore(p.GetStoreId()), fit),
+		})
+	}
+	return e
+}
+
+// explainConstraintFailures returns the label constraints of the rule which
+// the store doesn't match.
+func explainConstraintFailures(r *Rule, store *core.StoreInfo) []*ConstraintFailure {
+	constraints := r.LabelConstraints
+	if engine := r.engineLabelConstraints(); len(engine) > 0 {
+		constraints = append(engine, constraints...)
+	}
+	if len(r.LabelConstraintAlternatives) == 0 {
+		return constraintFailures(constraints, store)
+	}
+	var closest []*ConstraintFailure
+	for i, alt := range r.LabelConstraintAlternatives {
+		merged := make([]LabelConstraint, 0, len(constraints)+len(alt))
+		merged = append(append(merged, constraints...), alt...)
+		if failures := constraintFailures(merged, store); i == 0 || len(failures) < len(closest) {
+			closest = failures
+		}
+	}
+	return closest
+}
+
+func constraintFailures(constraints []LabelConstraint, store *core.StoreInfo) []*ConstraintFailure {
+	var failures []*ConstraintFailure
+	for _, l := range store.GetLabels() {
+		key := l.GetKey()
+		if isExclusiveLabel(key) && !hasConstraintKey(constraints, key) {
+			failures = append(failures, &ConstraintFailure{Label: key, Value: l.GetValue()})
+		}
+	}
+	for i := range constraints {
+		if c := constraints[i]; !c.MatchStore(store) {
+			failures = append(failures, &ConstraintFailure{Constraint: &c, Label: c.Key, Value: store.GetLabelValue(c.Key)})
+		}
+	}
+	return failures
+}
+
+func hasConstraintKey(constraints []LabelConstraint, key string) bool {
+	for _, c := range constraints {
+		if c.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// explainOrphanPeer returns why the peer is not selected by any rule.
+func explainOrphanPeer(store *core.StoreInfo, fit *RegionFit) string {
+	if store == nil {
+		return "the store of the peer is not found"
+	}
+	var full []string
+	for _, rf := range fit.RuleFits {
+		if rf.Rule.MatchStore(store) {
+			full = append(full, rf.Rule.GroupID+"/"+rf.Rule.ID)
+		}
+	}
+	if len(full) == 0 {
+		return "the store matches none of the rules"
+	}
+	return fmt.Sprintf("the matching rules %s already have enough peers or prefer the other peers", strings.Join(full, ", "))
+}
@@ -21,6 +21,9 @@ import (
 
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/mock/mockconfig"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
 )
 
 type mockStoresSet struct {
@@ -404,3 +407,28 @@ func BenchmarkFitRegionWithLocationLabels(b *testing.B) {
 		fitRegion(stores, region, rules, false)
 	}
 }
+
+// BenchmarkRuleManagerFitRegion fits the regions concurrently without the
+// cache, so the fit failures and the rule usage are recorded by every fit.
+func BenchmarkRuleManagerFitRegion(b *testing.B) {
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), nil, mockconfig.NewTestOptions())
+	if err := manager.Initialize(3, []string{}); err != nil {
+		b.Fatal(err)
+	}
+	// the learner is missing, so the rule is not satisfied by the regions.
+	if err := manager.SetRule(&Rule{GroupID: "pd", ID: "learner", Index: 1, Role: Learner, Count: 1}); err != nil {
+		b.Fatal(err)
+	}
+	storesSet := newMockStoresSet(100)
+	region := mockRegion(3, 0)
+	regions := make([]*core.RegionInfo, 1000)
+	for i := range regions {
+		regions[i] = region.Clone(core.WithNewRegionID(uint64(i + 1)))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			manager.FitRegion(storesSet, regions[i%len(regions)])
+		}
+	})
+}
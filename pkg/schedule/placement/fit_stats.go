@@ -0,0 +1,133 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// FitFailureReason is the reason why a rule is not satisfied by a region.
+type FitFailureReason string
+
+const (
+	// FitFailureInsufficientStores means there are not enough stores matching
+	// the label constraints of the rule in the cluster.
+	FitFailureInsufficientStores FitFailureReason = "insufficient_stores"
+	// FitFailureLabelMismatch means some peers of the region are placed in the
+	// stores not matching the label constraints of the rule.
+	FitFailureLabelMismatch FitFailureReason = "label_mismatch"
+	// FitFailureConflictingRule means the region has enough peers matching the
+	// rule, but some of them are taken by the other rules.
+	FitFailureConflictingRule FitFailureReason = "conflicting_rule"
+	// FitFailureOther means the other reasons, e.g., the peers are missing or
+	// have the different roles, which can be fixed by scheduling.
+	FitFailureOther FitFailureReason = "other"
+)
+
+var fitFailureReasons = []FitFailureReason{
+	FitFailureInsufficientStores,
+	FitFailureLabelMismatch,
+	FitFailureConflictingRule,
+	FitFailureOther,
+}
+
+type fitFailureCount struct {
+	count   atomic.Uint64
+	counter prometheus.Counter
+}
+
+// fitFailureStats is updated by every computed fit, so the counts are kept in
+// the atomics, and the map of them is never changed after it's created.
+type fitFailureStats struct {
+	counts map[FitFailureReason]*fitFailureCount
+}
+
+func newFitFailureStats() *fitFailureStats {
+	counts := make(map[FitFailureReason]*fitFailureCount, len(fitFailureReasons))
+	for _, reason := range fitFailureReasons {
+		counts[reason] = &fitFailureCount{counter: fitFailureCounter.WithLabelValues(string(reason))}
+	}
+	return &fitFailureStats{counts: counts}
+}
+
+func (s *fitFailureStats) record(reason FitFailureReason) {
+	c := s.counts[reason]
+	c.count.Add(1)
+	c.counter.Inc()
+}
+
+// FitStats returns the number of times the rules are not satisfied by the
+// regions for each reason. Only the computed fits are counted, the ones got
+// from the cache are not counted again.
+func (m *RuleManager) FitStats() map[FitFailureReason]uint64 {
+	stats := make(map[FitFailureReason]uint64, len(m.fitFailures.counts))
+	for reason, c := range m.fitFailures.counts {
+		if count := c.count.Load(); count > 0 {
+			stats[reason] = count
+		}
+	}
+	return stats
+}
+
+// recordFitFailures records the reasons of the rules not satisfied by the fit.
+func (m *RuleManager) recordFitFailures(storeSet StoreSet, fit *RegionFit) {
+	var stores []*core.StoreInfo
+	for _, rf := range fit.RuleFits {
+		if rf.IsSatisfied() {
+			continue
+		}
+		// the stores are only collected for the unsatisfied rules, which are rare.
+		if stores == nil {
+			for _, s := range storeSet.GetStores() {
+				if !s.IsRemoved() {
+					stores = append(stores, s)
+				}
+			}
+		}
+		m.fitFailures.record(fitFailureReason(rf, fit, stores))
+	}
+}
+
+func fitFailureReason(rf *RuleFit, fit *RegionFit, stores []*core.StoreInfo) FitFailureReason {
+	var matched int
+	for _, s := range stores {
+		if rf.Rule.MatchStore(s) {
+			matched++
+		}
+	}
+	if matched < rf.Rule.Count {
+		return FitFailureInsufficientStores
+	}
+	if len(rf.Peers) < rf.Rule.Count {
+		var regionMatched int
+		for _, s := range fit.regionStores {
+			if rf.Rule.MatchStore(s) {
+				regionMatched++
+			}
+		}
+		if regionMatched >= rf.Rule.Count {
+			return FitFailureConflictingRule
+		}
+	}
+	for _, p := range fit.OrphanPeers {
+		if !rf.Rule.MatchStore(getStoreByID(fit.regionStores, p.GetStoreId())) {
+			return FitFailureLabelMismatch
+		}
+	}
+	return FitFailureOther
+}
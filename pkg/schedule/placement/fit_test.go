@@ -63,7 +63,7 @@ func makeRegion(def string) *core.RegionInfo {
 			idStr, role = splits[0], PeerRoleType(splits[1])
 		}
 		id, _ := strconv.Atoi(idStr)
-		peer := &metapb.Peer{Id: uint64(id), StoreId: uint64(id), Role: role.MetaPeerRole()}
+		peer := &metapb.Peer{Id: uint64(id), StoreId: uint64(id), Role: role.MetaPeerRole(), IsWitness: role == Witness}
 		regionMeta.Peers = append(regionMeta.Peers, peer)
 		if role == Leader {
 			leader = peer
@@ -205,6 +205,85 @@ func TestFitRegion(t *testing.T) {
 	}
 }
 
+func TestFitWitnessRole(t *testing.T) {
+	re := require.New(t)
+	stores := makeStores()
+	witnessRule := makeRule("1/witness//")
+	witnessRule.IsWitness = true
+	rules := []*Rule{makeRule("3/voter//"), witnessRule}
+
+	// the witness peer is not counted by the voter rule.
+	rf := fitRegion(stores.GetStores(), makeRegion("1111_leader,1112,1113,1114_witness"), rules, true)
+	re.True(rf.IsSatisfied())
+	re.True(checkPeerMatch(rf.RuleFits[0].Peers, "1111,1112,1113"))
+	re.True(checkPeerMatch(rf.RuleFits[1].Peers, "1114"))
+	re.Empty(rf.OrphanPeers)
+
+	// the witness rule is not satisfied by a normal voter.
+	rf = fitRegion(stores.GetStores(), makeRegion("1111_leader,1112,1113,1114"), rules, true)
+	re.True(rf.RuleFits[0].IsSatisfied())
+	re.False(rf.RuleFits[1].IsSatisfied())
+}
+
+func TestFitLeaderRule(t *testing.T) {
+	re := require.New(t)
+	stores := makeStores()
+	leaderRule, voterRule := makeRule("1/leader/zone=zone1/"), makeRule("2/voter//")
+
+	// the result doesn't depend on the order of the rules.
+	for _, rules := range [][]*Rule{{leaderRule, voterRule}, {voterRule, leaderRule}} {
+		leaderIndex := 0
+		if rules[0] != leaderRule {
+			leaderIndex = 1
+		}
+		// the leader is in zone1, and the followers are anywhere.
+		rf := fitRegion(stores.GetStores(), makeRegion("1111_leader,2111,3111"), rules, false)
+		re.True(rf.IsSatisfied())
+		re.True(checkPeerMatch(rf.RuleFits[leaderIndex].Peers, "1111"))
+		re.True(checkPeerMatch(rf.RuleFits[1-leaderIndex].Peers, "2111,3111"))
+
+		// the leader is picked among the peers in zone1.
+		rf = fitRegion(stores.GetStores(), makeRegion("1111,1112_leader,2111"), rules, false)
+		re.True(rf.IsSatisfied())
+		re.True(checkPeerMatch(rf.RuleFits[leaderIndex].Peers, "1112"))
+
+		// the peer in zone1 is not the leader, so the leader should be transferred to it.
+		rf = fitRegion(stores.GetStores(), makeRegion("1111,2111_leader,3111"), rules, false)
+		re.False(rf.IsSatisfied())
+		re.True(rf.RuleFits[1-leaderIndex].IsSatisfied())
+		re.True(checkPeerMatch(rf.RuleFits[leaderIndex].Peers, "1111"))
+		re.True(checkPeerMatch(rf.RuleFits[leaderIndex].PeersWithDifferentRole, "1111"))
+	}
+}
+
+func TestFitLabelConstraintAlternatives(t *testing.T) {
+	re := require.New(t)
+	stores := makeStores()
+	// the peers are in zone1 or zone2, and not on tiflash.
+	rule := makeRule("3/voter//")
+	rule.LabelConstraints = []LabelConstraint{{Key: "engine", Op: NotIn, Values: []string{"tiflash"}}}
+	rule.LabelConstraintAlternatives = [][]LabelConstraint{
+		{{Key: "zone", Op: In, Values: []string{"zone1"}}},
+		{{Key: "zone", Op: In, Values: []string{"zone2"}}},
+	}
+	rules := []*Rule{rule}
+
+	rf := fitRegion(stores.GetStores(), makeRegion("1111_leader,2111,2112"), rules, false)
+	re.True(rf.IsSatisfied())
+	re.True(checkPeerMatch(rf.RuleFits[0].Peers, "1111,2111,2112"))
+
+	// the peer in zone3 matches none of the alternatives.
+	rf = fitRegion(stores.GetStores(), makeRegion("1111_leader,2111,3111"), rules, false)
+	re.False(rf.IsSatisfied())
+	re.True(checkPeerMatch(rf.RuleFits[0].Peers, "1111,2111"))
+	re.True(checkPeerMatch(rf.OrphanPeers, "3111"))
+
+	// the peer on tiflash matches an alternative but not the constraints.
+	rf = fitRegion(stores.GetStores(), makeRegion("1111_leader,2111,2115"), rules, false)
+	re.False(rf.IsSatisfied())
+	re.True(checkPeerMatch(rf.OrphanPeers, "2115"))
+}
+
 func TestIsolationScore(t *testing.T) {
 	as := assert.New(t)
 	stores := makeStores()
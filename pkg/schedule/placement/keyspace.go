@@ -0,0 +1,154 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
+	"go.uber.org/zap"
+)
+
+// The rules with a KeyspaceID are scoped to the keyspace. They must be placed
+// in the key ranges of the keyspace, and are only applied to the regions of
+// the keyspace. The rules without KeyspaceID are not scoped to any keyspace
+// and are applied to all regions unless overridden.
+
+// maxKeyspaceID is the max ID of the keyspaces, which is encoded in 3 bytes.
+const maxKeyspaceID = 1<<24 - 1
+
+// The key modes of the keyspace range bound to the rules.
+const (
+	KeyspaceModeRaw = "raw"
+	KeyspaceModeTxn = "txn"
+)
+
+// bindKeyspaceRange binds the rule scoped to a keyspace but without a range
+// to the raw or txn key range of the keyspace, so the users don't need to
+// encode the keyspace boundary by themselves. The rule with a range is left
+// as is, which is checked by checkKeyspaceRange.
+func bindKeyspaceRange(r *Rule) error {
+	switch r.KeyspaceMode {
+	case "", KeyspaceModeRaw, KeyspaceModeTxn:
+	default:
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid keyspace mode %s", r.KeyspaceMode))
+	}
+	keyspaceID, ok := r.GetKeyspaceID()
+	if !ok {
+		if r.KeyspaceMode != "" {
+			return errs.ErrRuleContent.FastGenByArgs("keyspace mode should be set with the keyspace ID")
+		}
+		return nil
+	}
+	if keyspaceID > maxKeyspaceID {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("keyspace ID %d exceeds the max ID %d", keyspaceID, maxKeyspaceID))
+	}
+	if len(r.StartKey) > 0 || len(r.EndKey) > 0 {
+		return nil
+	}
+	bound := keyspaceutil.MakeRegionBound(keyspaceID)
+	if r.KeyspaceMode == KeyspaceModeRaw {
+		r.StartKey, r.EndKey = bound.RawLeftBound, bound.RawRightBound
+	} else {
+		r.StartKey, r.EndKey = bound.TxnLeftBound, bound.TxnRightBound
+	}
+	r.StartKeyHex, r.EndKeyHex = hex.EncodeToString(r.StartKey), hex.EncodeToString(r.EndKey)
+	return nil
+}
+
+// checkKeyspaceRange checks the range of the rule scoped to a keyspace is
+// inside the raw or txn key range of the keyspace.
+func checkKeyspaceRange(r *Rule) error {
+	keyspaceID, ok := r.GetKeyspaceID()
+	if !ok {
+		return nil
+	}
+	bound := keyspaceutil.MakeRegionBound(keyspaceID)
+	for _, rg := range [][2][]byte{
+		{bound.RawLeftBound, bound.RawRightBound},
+		{bound.TxnLeftBound, bound.TxnRightBound},
+	} {
+		if bytes.Compare(r.StartKey, rg[0]) >= 0 && len(r.EndKey) > 0 && bytes.Compare(r.EndKey, rg[1]) <= 0 {
+			return nil
+		}
+	}
+	return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("range of rule should be inside keyspace %d", keyspaceID))
+}
+
+// filterKeyspaceRules drops the rules scoped to the keyspaces other than the
+// one the start key belongs to. If no rule is left, it falls back to the
+// default rule if it's active.
+func (m *RuleManager) filterKeyspaceRules(startKey []byte, rules []*Rule) []*Rule {
+	keyspaceID, inKeyspace := keyspaceutil.KeyspaceOfKey(startKey)
+	var filtered []*Rule
+	for _, r := range rules {
+		if id, ok := r.GetKeyspaceID(); !ok || (inKeyspace && id == keyspaceID) {
+			filtered = append(filtered, r)
+		}
+	}
+	if len(filtered) == len(rules) {
+		return rules
+	}
+	if len(filtered) == 0 {
+		if defaultRule := m.ruleConfig.getRule([2]string{"pd", "default"}); defaultRule != nil && m.isActive(defaultRule) {
+			return []*Rule{defaultRule}
+		}
+	}
+	return filtered
+}
+
+// GetKeyspaceRules returns the sorted rules scoped to the keyspace.
+func (m *RuleManager) GetKeyspaceRules(keyspaceID uint32) []*Rule {
+	m.RLock()
+	defer m.RUnlock()
+	var rules []*Rule
+	for _, r := range m.ruleConfig.rules {
+		if id, ok := r.GetKeyspaceID(); ok && id == keyspaceID {
+			rules = append(rules, r)
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+// DeleteKeyspaceRules deletes all the rules scoped to the keyspace, it's used
+// to garbage-collect the rules of a dropped keyspace.
+func (m *RuleManager) DeleteKeyspaceRules(keyspaceID uint32) error {
+	if keyspaceID == 0 {
+		return errs.ErrRuleContent.FastGenByArgs("rules of the default keyspace can not be deleted")
+	}
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	var deleted int
+	for key, r := range m.ruleConfig.rules {
+		if id, ok := r.GetKeyspaceID(); ok && id == keyspaceID {
+			p.deleteRule(key[0], key[1])
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return nil
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("keyspace rules deleted", zap.Uint32("keyspace-id", keyspaceID), zap.Int("count", deleted))
+	return nil
+}
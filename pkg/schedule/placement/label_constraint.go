@@ -93,3 +93,20 @@ func MatchLabelConstraints(store *core.StoreInfo, constraints []LabelConstraint)
 
 	return slice.AllOf(constraints, func(i int) bool { return constraints[i].MatchStore(store) })
 }
+
+// MatchLabelConstraintSets checks if a store matches the constraints and any
+// of the alternative constraint sets, i.e., the constraints are ANDed with each
+// alternative, and the alternatives are ORed. The store only needs to match the
+// constraints if there is no alternative.
+func MatchLabelConstraintSets(store *core.StoreInfo, constraints []LabelConstraint, alternatives [][]LabelConstraint) bool {
+	if len(alternatives) == 0 {
+		return MatchLabelConstraints(store, constraints)
+	}
+	return slice.AnyOf(alternatives, func(i int) bool {
+		// the alternative is merged with the constraints, so the exclusive
+		// labels specified by either of them are allowed.
+		merged := make([]LabelConstraint, 0, len(constraints)+len(alternatives[i]))
+		merged = append(append(merged, constraints...), alternatives[i]...)
+		return MatchLabelConstraints(store, merged)
+	})
+}
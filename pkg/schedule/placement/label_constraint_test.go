@@ -96,3 +96,45 @@ func TestLabelConstraints(t *testing.T) {
 		re.Equal(expect[i], matched)
 	}
 }
+
+func TestLabelConstraintAlternatives(t *testing.T) {
+	re := require.New(t)
+	// the flat form is still supported.
+	rule, err := NewRuleFromJSON([]byte(`{"group_id":"pd","id":"r","role":"voter","count":3,"label_constraints":[{"key":"zone","op":"in","values":["z1"]}]}`))
+	re.NoError(err)
+	re.Empty(rule.LabelConstraintAlternatives)
+	re.True(rule.MatchStore(core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1"})))
+	re.False(rule.MatchStore(core.NewStoreInfoWithLabel(2, map[string]string{"zone": "z2"})))
+
+	rule, err = NewRuleFromJSON([]byte(`{"group_id":"pd","id":"r","role":"voter","count":3,
+		"label_constraints":[{"key":"disk","op":"in","values":["ssd"]}],
+		"label_constraint_alternatives":[
+			[{"key":"zone","op":"in","values":["z1"]}],
+			[{"key":"zone","op":"in","values":["z2"]},{"key":"rack","op":"notIn","values":["r1"]}],
+			[{"key":"$mode","op":"in","values":["dedicated"]}]
+		]}`))
+	re.NoError(err)
+	re.Len(rule.LabelConstraintAlternatives, 3)
+	stores := []map[string]string{
+		{"zone": "z1", "rack": "r1", "disk": "ssd"},         // 1, matches the first alternative
+		{"zone": "z2", "rack": "r2", "disk": "ssd"},         // 2, matches the second alternative
+		{"zone": "z2", "rack": "r1", "disk": "ssd"},         // 3, matches no alternative
+		{"zone": "z1", "disk": "hdd"},                       // 4, doesn't match the constraints
+		{"zone": "z3", "disk": "ssd"},                       // 5, matches no alternative
+		{"zone": "z1", "disk": "ssd", "$mode": "dedicated"}, // 6, the exclusive label is only allowed by the third one
+		{"zone": "z2", "disk": "ssd", "$mode": "shared"},    // 7, the exclusive label is not allowed
+	}
+	var matched []int
+	for i, labels := range stores {
+		if rule.MatchStore(core.NewStoreInfoWithLabel(uint64(i+1), labels)) {
+			matched = append(matched, i+1)
+		}
+	}
+	re.Equal([]int{1, 2, 6}, matched)
+
+	// an empty alternative is rejected since it matches all stores.
+	_, err = NewRuleFromJSONStrict([]byte(`{"group_id":"pd","id":"r","role":"voter","count":3,"label_constraint_alternatives":[[{"key":"zone","op":"in","values":["z1"]}],[]]}`))
+	re.Error(err)
+	_, err = NewRuleFromJSONStrict([]byte(`{"group_id":"pd","id":"r","role":"voter","count":3,"label_constraint_alternatives":[[{"key":"zone","op":"is","values":["z1"]}]]}`))
+	re.Error(err)
+}
@@ -0,0 +1,63 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	unsatisfiableRulesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "placement",
+			Name:      "unsatisfiable_rules",
+			Help:      "The placement rules whose label constraints can not match any store.",
+		}, []string{"group", "rule"})
+
+	inactiveRulesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "placement",
+			Name:      "inactive_rules",
+			Help:      "The placement rules suppressed since their preconditions are not met.",
+		}, []string{"group", "rule"})
+
+	fitCacheCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "placement",
+			Name:      "fit_cache_total",
+			Help:      "Counter of the lookups of the region fit cache.",
+		}, []string{"result"})
+
+	fitFailureCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "placement",
+			Name:      "fit_failures_total",
+			Help:      "Counter of the rules not satisfied by the regions by reasons.",
+		}, []string{"reason"})
+)
+
+var (
+	fitCacheHitCounter  = fitCacheCounter.WithLabelValues("hit")
+	fitCacheMissCounter = fitCacheCounter.WithLabelValues("miss")
+)
+
+func init() {
+	prometheus.MustRegister(unsatisfiableRulesGauge)
+	prometheus.MustRegister(inactiveRulesGauge)
+	prometheus.MustRegister(fitCacheCounter)
+	prometheus.MustRegister(fitFailureCounter)
+}
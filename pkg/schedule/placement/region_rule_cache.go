@@ -15,6 +15,9 @@
 package placement
 
 import (
+	"bytes"
+	"sync/atomic"
+
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/slice"
@@ -38,10 +41,17 @@ const (
 // 5. stores topology is changed
 // 6. any store label is changed
 // 7. any store state is changed
+// Each cache records the version of the rule set it's built with. Once the
+// rules are changed, the caches of the regions covered by the changed rules are
+// invalidated, and the others can be used without comparing the rules.
 type RegionRuleFitCacheManager struct {
-	mu           syncutil.RWMutex
-	regionCaches map[uint64]*regionRuleFitCache
-	storeCaches  map[uint64]*storeCache
+	mu             syncutil.RWMutex
+	regionCaches   map[uint64]*regionRuleFitCache
+	storeCaches    map[uint64]*storeCache
+	ruleSetVersion uint64
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
 }
 
 // NewRegionRuleFitCacheManager returns RegionRuleFitCacheManager
@@ -77,6 +87,26 @@ func (manager *RegionRuleFitCacheManager) CheckAndGetCache(region *core.RegionIn
 	return false, nil
 }
 
+// checkAndGetCacheByVersion is similar to CheckAndGetCache, but the rules are
+// not compared if the cache is built with the same version of the rule set.
+func (manager *RegionRuleFitCacheManager) checkAndGetCacheByVersion(region *core.RegionInfo,
+	rules []*Rule,
+	ruleSetVersion uint64,
+	stores []*core.StoreInfo) (bool, *RegionFit) {
+	if !ValidateRegion(region) || !ValidateStores(stores) {
+		return false, nil
+	}
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	if cache, ok := manager.regionCaches[region.GetID()]; ok {
+		if cache.isRegionUnchanged(region) && storesEqual(cache.regionStores, stores) &&
+			(cache.ruleSetVersion == ruleSetVersion || rulesEqual(cache.rules, rules)) {
+			return true, cache.bestFit
+		}
+	}
+	return false, nil
+}
+
 // SetCache stores RegionFit cache
 func (manager *RegionRuleFitCacheManager) SetCache(region *core.RegionInfo, fit *RegionFit) {
 	if !ValidateRegion(region) || !ValidateFit(fit) || !ValidateStores(fit.regionStores) {
@@ -84,6 +114,10 @@ func (manager *RegionRuleFitCacheManager) SetCache(region *core.RegionInfo, fit
 	}
 	manager.mu.Lock()
 	defer manager.mu.Unlock()
+	// the fit is calculated with the rules which have been changed.
+	if fit.ruleSetVersion != manager.ruleSetVersion {
+		return
+	}
 	if cache, ok := manager.regionCaches[region.GetID()]; ok {
 		cache.hitCount++
 		if cache.hitCount >= minHitCountToCacheHit {
@@ -94,13 +128,72 @@ func (manager *RegionRuleFitCacheManager) SetCache(region *core.RegionInfo, fit
 	manager.regionCaches[region.GetID()] = manager.toRegionRuleFitCache(region, fit)
 }
 
+// cachedFits returns the cached fits built with the given version of the rule
+// set, the regions without the cached fit or with the outdated ones are not
+// included.
+func (manager *RegionRuleFitCacheManager) cachedFits(ruleSetVersion uint64) []*RegionFit {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+	var fits []*RegionFit
+	for _, cache := range manager.regionCaches {
+		if cache.bestFit != nil && cache.ruleSetVersion == ruleSetVersion {
+			fits = append(fits, cache.bestFit)
+		}
+	}
+	return fits
+}
+
+// invalidRules records the new version of the rule set, and invalidates the
+// caches of the regions overlapped with the changed ranges. All caches are
+// invalidated if ranges is nil.
+func (manager *RegionRuleFitCacheManager) invalidRules(ruleSetVersion uint64, ranges [][2][]byte) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.ruleSetVersion = ruleSetVersion
+	if ranges == nil {
+		manager.regionCaches = make(map[uint64]*regionRuleFitCache)
+		return
+	}
+	for id, cache := range manager.regionCaches {
+		for _, rg := range ranges {
+			if cache.region.overlaps(rg[0], rg[1]) {
+				delete(manager.regionCaches, id)
+				break
+			}
+		}
+	}
+}
+
+// Flush invalidates all caches.
+func (manager *RegionRuleFitCacheManager) Flush() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.regionCaches = make(map[uint64]*regionRuleFitCache)
+}
+
+// Stats returns the number of hits and misses of the cache.
+func (manager *RegionRuleFitCacheManager) Stats() (hits, misses uint64) {
+	return manager.hits.Load(), manager.misses.Load()
+}
+
+func (manager *RegionRuleFitCacheManager) recordLookup(hit bool) {
+	if hit {
+		manager.hits.Add(1)
+		fitCacheHitCounter.Inc()
+	} else {
+		manager.misses.Add(1)
+		fitCacheMissCounter.Inc()
+	}
+}
+
 // regionRuleFitCache stores regions RegionFit result and involving variables
 type regionRuleFitCache struct {
-	region       regionCache
-	regionStores []*storeCache
-	rules        []ruleCache
-	bestFit      *RegionFit
-	hitCount     uint32
+	region         regionCache
+	regionStores   []*storeCache
+	rules          []ruleCache
+	ruleSetVersion uint64
+	bestFit        *RegionFit
+	hitCount       uint32
 }
 
 // IsUnchanged checks whether the region and rules unchanged for the cache
@@ -140,11 +233,12 @@ func storesEqual(a []*storeCache, b []*core.StoreInfo) bool {
 
 func (manager *RegionRuleFitCacheManager) toRegionRuleFitCache(region *core.RegionInfo, fit *RegionFit) *regionRuleFitCache {
 	return &regionRuleFitCache{
-		region:       toRegionCache(region),
-		regionStores: manager.toStoreCacheList(fit.regionStores),
-		rules:        toRuleCacheList(fit.rules),
-		bestFit:      nil,
-		hitCount:     0,
+		region:         toRegionCache(region),
+		regionStores:   manager.toStoreCacheList(fit.regionStores),
+		rules:          toRuleCacheList(fit.rules),
+		ruleSetVersion: fit.ruleSetVersion,
+		bestFit:        nil,
+		hitCount:       0,
 	}
 }
 
@@ -225,6 +319,8 @@ type regionCache struct {
 	leaderStoreID uint64
 	confVer       uint64
 	version       uint64
+	startKey      []byte
+	endKey        []byte
 }
 
 func (r regionCache) epochEqual(region *core.RegionInfo) bool {
@@ -235,12 +331,20 @@ func (r regionCache) epochEqual(region *core.RegionInfo) bool {
 	return r.confVer == v.ConfVer && r.version == v.Version
 }
 
+// overlaps checks whether the region is overlapped with the range [start, end).
+func (r regionCache) overlaps(start, end []byte) bool {
+	return (len(end) == 0 || bytes.Compare(r.startKey, end) < 0) &&
+		(len(r.endKey) == 0 || bytes.Compare(start, r.endKey) < 0)
+}
+
 func toRegionCache(r *core.RegionInfo) regionCache {
 	return regionCache{
 		regionID:      r.GetID(),
 		leaderStoreID: r.GetLeader().StoreId,
 		confVer:       r.GetRegionEpoch().ConfVer,
 		version:       r.GetRegionEpoch().Version,
+		startKey:      r.GetStartKey(),
+		endKey:        r.GetEndKey(),
 	}
 }
 
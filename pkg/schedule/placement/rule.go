@@ -15,11 +15,16 @@
 package placement
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"sort"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
 )
 
 // PeerRoleType is the expected peer type of the placement rule.
@@ -28,16 +33,21 @@ type PeerRoleType string
 const (
 	// Voter can either match a leader peer or follower peer
 	Voter PeerRoleType = "voter"
-	// Leader matches a leader.
+	// Leader matches a leader. A leader rule must have Count 1, its label
+	// constraints pin the leader to the matched stores, while the other voters
+	// are placed by the voter or follower rules.
 	Leader PeerRoleType = "leader"
 	// Follower matches a follower.
 	Follower PeerRoleType = "follower"
 	// Learner matches a learner.
 	Learner PeerRoleType = "learner"
+	// Witness matches a witness voter, which can never be a leader. It's the
+	// same as a Voter rule with IsWitness set.
+	Witness PeerRoleType = "witness"
 )
 
 func validateRole(s PeerRoleType) bool {
-	return s == Voter || s == Leader || s == Follower || s == Learner
+	return s == Voter || s == Leader || s == Follower || s == Learner || s == Witness
 }
 
 // MetaPeerRole converts placement.PeerRoleType to metapb.PeerRole.
@@ -54,34 +64,222 @@ func (s PeerRoleType) MetaPeerRole() metapb.PeerRole {
 //
 // NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
 type Rule struct {
-	GroupID          string            `json:"group_id"`                    // mark the source that add the rule
-	ID               string            `json:"id"`                          // unique ID within a group
-	Index            int               `json:"index,omitempty"`             // rule apply order in a group, rule with less ID is applied first when indexes are equal
-	Override         bool              `json:"override,omitempty"`          // when it is true, all rules with less indexes are disabled
-	StartKey         []byte            `json:"-"`                           // range start key
-	StartKeyHex      string            `json:"start_key"`                   // hex format start key, for marshal/unmarshal
-	EndKey           []byte            `json:"-"`                           // range end key
-	EndKeyHex        string            `json:"end_key"`                     // hex format end key, for marshal/unmarshal
-	Role             PeerRoleType      `json:"role"`                        // expected role of the peers
-	IsWitness        bool              `json:"is_witness"`                  // when it is true, it means the role is also a witness
-	Count            int               `json:"count"`                       // expected count of the peers
-	LabelConstraints []LabelConstraint `json:"label_constraints,omitempty"` // used to select stores to place peers
-	LocationLabels   []string          `json:"location_labels,omitempty"`   // used to make peers isolated physically
-	IsolationLevel   string            `json:"isolation_level,omitempty"`   // used to isolate replicas explicitly and forcibly
-	Version          uint64            `json:"version,omitempty"`           // only set at runtime, add 1 each time rules updated, begin from 0.
-	CreateTimestamp  uint64            `json:"create_timestamp,omitempty"`  // only set at runtime, recorded rule create timestamp
-	group            *RuleGroup        // only set at runtime, no need to {,un}marshal or persist.
-}
-
-// NewRuleFromJSON creates a rule from the JSON data.
+	GroupID                     string              `json:"group_id"`                                // mark the source that add the rule
+	ID                          string              `json:"id"`                                      // unique ID within a group
+	Index                       int                 `json:"index,omitempty"`                         // rule apply order in a group, rule with less ID is applied first when indexes are equal
+	Override                    bool                `json:"override,omitempty"`                      // when it is true, all rules with less indexes are disabled
+	StartKey                    []byte              `json:"-"`                                       // range start key
+	StartKeyHex                 string              `json:"start_key"`                               // hex format start key, for marshal/unmarshal
+	EndKey                      []byte              `json:"-"`                                       // range end key
+	EndKeyHex                   string              `json:"end_key"`                                 // hex format end key, for marshal/unmarshal
+	Role                        PeerRoleType        `json:"role"`                                    // expected role of the peers
+	IsWitness                   bool                `json:"is_witness"`                              // when it is true, it means the role is also a witness
+	Count                       int                 `json:"count"`                                   // expected count of the peers
+	CountExpr                   *RuleCountExpr      `json:"count_expr,omitempty"`                    // resolves the count against the matching stores at fit time, nil means the fixed count
+	Engine                      string              `json:"engine,omitempty"`                        // the engine of the stores to place peers, which is translated into a label constraint, empty means any engine
+	LabelConstraints            []LabelConstraint   `json:"label_constraints,omitempty"`             // used to select stores to place peers
+	LabelConstraintAlternatives [][]LabelConstraint `json:"label_constraint_alternatives,omitempty"` // the stores matching any of the sets besides the label constraints are selected
+	LocationLabels              []string            `json:"location_labels,omitempty"`               // used to make peers isolated physically
+	IsolationLevel              string              `json:"isolation_level,omitempty"`               // used to isolate replicas explicitly and forcibly
+	KeyspaceID                  *uint32             `json:"keyspace_id,omitempty"`                   // the keyspace the rule is scoped to, nil means not scoped to any keyspace
+	KeyspaceMode                string              `json:"keyspace_mode,omitempty"`                 // the key mode of the keyspace range bound to the rule without a range, raw or txn, empty means txn
+	TemplateID                  string              `json:"template_id,omitempty"`                   // the template the rule is derived from, empty means not derived
+	TemplateVars                map[string]string   `json:"template_vars,omitempty"`                 // the store label values substituted for the variables of the template, keyed by the label keys
+	Precondition                *RulePrecondition   `json:"precondition,omitempty"`                  // the cluster state required by the rule to take effect, nil means always
+	ActiveFrom                  *time.Time          `json:"active_from,omitempty"`                   // the rule is inert before it, nil means no lower bound
+	ActiveUntil                 *time.Time          `json:"active_until,omitempty"`                  // the rule is inert since it, nil means no upper bound
+	Enabled                     *bool               `json:"enabled,omitempty"`                       // the disabled rule is kept but skipped by fit, nil means enabled
+	Annotations                 map[string]string   `json:"annotations,omitempty"`                   // free-form metadata such as the owner, which takes no part in the placement
+	Revision                    uint64              `json:"revision,omitempty"`                      // only set by RuleManager, increased by every saved rule to order the updates
+	SchemaVersion               int                 `json:"schema_version,omitempty"`                // only set by RuleManager, the schema version of the saved rule, see RuleSchemaVersion
+	Version                     uint64              `json:"version,omitempty"`                       // only set at runtime, add 1 each time rules updated, begin from 0.
+	CreateTimestamp             uint64              `json:"create_timestamp,omitempty"`              // only set at runtime, recorded rule create timestamp
+	group                       *RuleGroup          // only set at runtime, no need to {,un}marshal or persist.
+	inherited                   []LabelConstraint   // only set at runtime, the label constraints inherited from the groups.
+	inheritedLocationLabels     []string            // only set at runtime, the default location labels of the groups, used if the rule has none.
+}
+
+// NewRuleFromJSON creates a rule from the JSON data, which is migrated to the
+// current schema version first if it's of an older one. The content is not
+// checked, since the rules saved by the old versions may not pass the checks
+// of the current one, e.g., the ones loaded from storage. The rules from users
+// should be parsed by NewRuleFromJSONStrict.
 func NewRuleFromJSON(data []byte) (*Rule, error) {
 	r := &Rule{}
+	data, err := migrateRuleJSON(data)
+	if err != nil {
+		return nil, err
+	}
 	if err := json.Unmarshal(data, r); err != nil {
 		return nil, err
 	}
+	if err := r.NormalizeKeys(); err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
+// NewRuleFromJSONStrict creates a rule from the JSON data like NewRuleFromJSON,
+// but the fields unknown to the rule are rejected, so that a typo of the field
+// name will not be ignored silently, and the role, the alternative constraint
+// sets and the key range are checked. It is used to check the rules from
+// users, while the rules from storage are still parsed leniently for
+// compatibility.
+func NewRuleFromJSONStrict(data []byte) (*Rule, error) {
+	r := &Rule{}
+	data, err := migrateRuleJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(r); err != nil {
+		return nil, err
+	}
+	if err := r.checkRole(); err != nil {
+		return nil, err
+	}
+	if err := r.checkLabelConstraintAlternatives(); err != nil {
+		return nil, err
+	}
+	if err := r.NormalizeKeys(); err != nil {
+		return nil, err
+	}
+	if err := checkKeyRange(r.StartKey, r.EndKey); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkRole checks the role of the rule parsed from JSON. The empty role is
+// left to RuleManager to reject, since the rule may be incomplete here.
+func (r *Rule) checkRole() error {
+	if r.Role != "" && !validateRole(r.Role) {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid role %s", r.Role))
+	}
+	return nil
+}
+
+// checkLabelConstraintAlternatives checks the alternative constraint sets. An
+// empty set is rejected since it would match any store and make the others
+// meaningless.
+func (r *Rule) checkLabelConstraintAlternatives() error {
+	for i, alt := range r.LabelConstraintAlternatives {
+		if len(alt) == 0 {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("label constraint alternative %d should not be empty", i))
+		}
+		for _, c := range alt {
+			if !validateOp(c.Op) {
+				return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s", c.Op))
+			}
+		}
+	}
+	return nil
+}
+
+// checkAnnotations checks the annotations of a rule or rule group, whose keys
+// should not be empty.
+func checkAnnotations(annotations map[string]string) error {
+	for k := range annotations {
+		if k == "" {
+			return errs.ErrRuleContent.FastGenByArgs("annotation key should not be empty")
+		}
+	}
+	return nil
+}
+
+// IsEnabled returns whether the rule is enabled, the rules are enabled unless
+// they are disabled explicitly, e.g., the ones saved by the old versions of PD.
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// GetKeyspaceID returns the keyspace the rule is scoped to, and false if the
+// rule is not scoped to any keyspace.
+func (r *Rule) GetKeyspaceID() (uint32, bool) {
+	if r.KeyspaceID == nil {
+		return 0, false
+	}
+	return *r.KeyspaceID, true
+}
+
+// MatchStore checks if a store matches the label constraints of the rule,
+// including the engine and the alternative constraint sets.
+func (r *Rule) MatchStore(store *core.StoreInfo) bool {
+	constraints := r.LabelConstraints
+	if engine := r.engineLabelConstraints(); len(engine) > 0 {
+		constraints = append(engine, constraints...)
+	}
+	return MatchLabelConstraintSets(store, constraints, r.LabelConstraintAlternatives)
+}
+
+// checkEngine checks the engine of the rule, which should be one of the known
+// engines. The witness can't be placed on TiFlash.
+func (r *Rule) checkEngine(isWitness bool) error {
+	switch r.Engine {
+	case "", core.EngineTiKV:
+	case core.EngineTiFlash:
+		if isWitness {
+			return errs.ErrRuleContent.FastGenByArgs("witness can't combine with tiflash")
+		}
+	default:
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid engine %s", r.Engine))
+	}
+	return nil
+}
+
+// engineLabelConstraints returns the label constraints selecting the stores of
+// the engine. The TiKV stores are the ones without the TiFlash engine label,
+// since they are not labeled by the engine.
+func (r *Rule) engineLabelConstraints() []LabelConstraint {
+	switch r.Engine {
+	case core.EngineTiKV:
+		return []LabelConstraint{{Key: core.EngineKey, Op: NotIn, Values: []string{core.EngineTiFlash}}}
+	case core.EngineTiFlash:
+		return []LabelConstraint{{Key: core.EngineKey, Op: In, Values: []string{core.EngineTiFlash}}}
+	}
+	return nil
+}
+
+// NormalizeKeys makes the raw and hex format of the key range consistent. The
+// missing one is derived from the other, and an error is returned if both are
+// set but disagree. An empty raw key is regarded as missing, since it can not
+// be told from the unset one.
+func (r *Rule) NormalizeKeys() error {
+	if err := normalizeKey(&r.StartKey, &r.StartKeyHex, "start key"); err != nil {
+		return err
+	}
+	return normalizeKey(&r.EndKey, &r.EndKeyHex, "end key")
+}
+
+// checkKeyRange rejects the range unless the start key is strictly less than
+// the end key, where the empty start key means the negative infinity and the
+// empty end key means the positive infinity. An inverted or empty range would
+// match nothing silently.
+func checkKeyRange(startKey, endKey []byte) error {
+	if len(endKey) > 0 && bytes.Compare(endKey, startKey) <= 0 {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("start key %s should be less than end key %s",
+			hex.EncodeToString(startKey), hex.EncodeToString(endKey)))
+	}
+	return nil
+}
+
+func normalizeKey(key *[]byte, keyHex *string, name string) error {
+	if *keyHex == "" {
+		*keyHex = hex.EncodeToString(*key)
+		return nil
+	}
+	decoded, err := hex.DecodeString(*keyHex)
+	if err != nil {
+		return errs.ErrHexDecodingString.FastGenByArgs(*keyHex)
+	}
+	if len(*key) > 0 && !bytes.Equal(*key, decoded) {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("%s %s does not match the hex format %s", name, hex.EncodeToString(*key), *keyHex))
+	}
+	*key = decoded
+	return nil
+}
+
 func (r *Rule) String() string {
 	b, _ := json.Marshal(r)
 	return string(b)
@@ -106,6 +304,26 @@ func (r *Rule) StoreKey() string {
 	return hex.EncodeToString([]byte(r.GroupID)) + "-" + hex.EncodeToString([]byte(r.ID))
 }
 
+// applied returns the rule applied to the regions, whose label constraints
+// include the inherited ones and the ones translated from the engine, and
+// whose location labels are defaulted by the groups. It's the rule itself if
+// nothing is inherited or translated.
+func (r *Rule) applied() *Rule {
+	engine := r.engineLabelConstraints()
+	if len(r.inherited) == 0 && len(engine) == 0 && len(r.inheritedLocationLabels) == 0 {
+		return r
+	}
+	applied := *r
+	if len(r.inheritedLocationLabels) > 0 {
+		applied.LocationLabels = r.inheritedLocationLabels
+	}
+	// the engine is translated, so it's not matched again by MatchStore.
+	applied.Engine = ""
+	applied.LabelConstraints = make([]LabelConstraint, 0, len(r.inherited)+len(engine)+len(r.LabelConstraints))
+	applied.LabelConstraints = append(append(append(applied.LabelConstraints, r.inherited...), engine...), r.LabelConstraints...)
+	return &applied
+}
+
 func (r *Rule) groupIndex() int {
 	if r.group != nil {
 		return r.group.Index
@@ -119,6 +337,29 @@ type RuleGroup struct {
 	ID       string `json:"id,omitempty"`
 	Index    int    `json:"index,omitempty"`
 	Override bool   `json:"override,omitempty"`
+	// Frozen means the rules of the group can not be changed, and the group
+	// itself can only be changed by RuleManager.SetRuleGroup.
+	Frozen bool `json:"frozen,omitempty"`
+	// ParentID is the ID of the parent group. The rules of the group inherit
+	// the label constraints of the group and all its ancestors, which are
+	// combined with their own ones, so a rule can only tighten but never
+	// loosen the inherited constraints.
+	ParentID string `json:"parent_id,omitempty"`
+	// LabelConstraints are inherited by the rules of the group and the
+	// descendant groups.
+	LabelConstraints []LabelConstraint `json:"label_constraints,omitempty"`
+	// DefaultLocationLabels are used by the rules of the group and the
+	// descendant groups which have no location labels, the ones of the
+	// nearest group take effect.
+	DefaultLocationLabels []string `json:"default_location_labels,omitempty"`
+	// DefaultLabelConstraints are used by the rules of the group and the
+	// descendant groups unless the rule has the label constraints of the same
+	// keys, i.e., they can be overridden by the rules, unlike LabelConstraints.
+	// The ones of the nearest group take effect for each key.
+	DefaultLabelConstraints []LabelConstraint `json:"default_label_constraints,omitempty"`
+	// Annotations are the free-form metadata of the group, such as the owner
+	// and the description, which take no part in the placement.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // NewRuleGroupFromJSON creates a rule group from the JSON data.
@@ -131,7 +372,8 @@ func NewRuleGroupFromJSON(data []byte) (*RuleGroup, error) {
 }
 
 func (g *RuleGroup) isDefault() bool {
-	return g.Index == 0 && !g.Override
+	return g.Index == 0 && !g.Override && !g.Frozen && g.ParentID == "" && len(g.LabelConstraints) == 0 &&
+		len(g.DefaultLocationLabels) == 0 && len(g.DefaultLabelConstraints) == 0 && len(g.Annotations) == 0
 }
 
 func (g *RuleGroup) String() string {
@@ -139,7 +381,10 @@ func (g *RuleGroup) String() string {
 	return string(b)
 }
 
-// Rules are ordered by (GroupID, Index, ID).
+// Rules are ordered by (GroupIndex, GroupID, Index, ID). Since (GroupID, ID)
+// is unique, the order is total: the rules sharing the same index in a group
+// are always applied in the order of their IDs, no matter in which order they
+// are loaded or updated, so the fit results are reproducible.
 func compareRule(a, b *Rule) int {
 	switch {
 	case a.groupIndex() < b.groupIndex():
@@ -201,10 +446,32 @@ func prepareRulesForApply(rules []*Rule) []*Rule {
 // GroupBundle represents a rule group and all rules belong to the group.
 // NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
 type GroupBundle struct {
-	ID       string  `json:"group_id"`
-	Index    int     `json:"group_index"`
-	Override bool    `json:"group_override"`
-	Rules    []*Rule `json:"rules"`
+	ID       string `json:"group_id"`
+	Index    int    `json:"group_index"`
+	Override bool   `json:"group_override"`
+	// The other configurations of the group, see RuleGroup. They are omitted
+	// by the old clients, so the empty ones keep the ones of the existing group.
+	Frozen                  bool              `json:"group_frozen,omitempty"`
+	ParentID                string            `json:"group_parent_id,omitempty"`
+	LabelConstraints        []LabelConstraint `json:"group_label_constraints,omitempty"`
+	DefaultLocationLabels   []string          `json:"group_default_location_labels,omitempty"`
+	DefaultLabelConstraints []LabelConstraint `json:"group_default_label_constraints,omitempty"`
+	Annotations             map[string]string `json:"group_annotations,omitempty"`
+	Rules                   []*Rule           `json:"rules"`
+}
+
+func newGroupBundle(g *RuleGroup) GroupBundle {
+	return GroupBundle{
+		ID:                      g.ID,
+		Index:                   g.Index,
+		Override:                g.Override,
+		Frozen:                  g.Frozen,
+		ParentID:                g.ParentID,
+		LabelConstraints:        g.LabelConstraints,
+		DefaultLocationLabels:   g.DefaultLocationLabels,
+		DefaultLabelConstraints: g.DefaultLabelConstraints,
+		Annotations:             g.Annotations,
+	}
 }
 
 func (g GroupBundle) String() string {
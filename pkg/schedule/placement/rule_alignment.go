@@ -0,0 +1,232 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/hex"
+	"sort"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+)
+
+// LabelConstraintMapping declares that the ranges labeled with the region label
+// should be placed by the rules with the label constraint, and the ranges of
+// the rules with the label constraint should be labeled with the region label.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type LabelConstraintMapping struct {
+	LabelKey   string          `json:"label_key"`
+	LabelValue string          `json:"label_value"`
+	Constraint LabelConstraint `json:"constraint"`
+}
+
+func (m *LabelConstraintMapping) matchRule(r *Rule) bool {
+	for _, c := range r.LabelConstraints {
+		if c.Key == m.Constraint.Key && c.Op == m.Constraint.Op && stringSetEqual(c.Values, m.Constraint.Values) {
+			return true
+		}
+	}
+	return false
+}
+
+// MisalignmentType is the type of a Misalignment.
+type MisalignmentType string
+
+const (
+	// MissingPlacementRule means a labeled range is not placed by any rule
+	// with the mapped constraint.
+	MissingPlacementRule MisalignmentType = "missing_placement_rule"
+	// MissingLabelRule means a range of a rule with the mapped constraint is
+	// not labeled with the mapped region label.
+	MissingLabelRule MisalignmentType = "missing_label_rule"
+)
+
+// Misalignment is a key range where the region labels and the placement rules
+// disagree with a mapping. LabelRuleID is set for MissingPlacementRule, and
+// GroupID and RuleID are set for MissingLabelRule.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type Misalignment struct {
+	Type        MisalignmentType       `json:"type"`
+	StartKeyHex string                 `json:"start_key"`
+	EndKeyHex   string                 `json:"end_key"`
+	LabelRuleID string                 `json:"label_rule_id,omitempty"`
+	GroupID     string                 `json:"group_id,omitempty"`
+	RuleID      string                 `json:"rule_id,omitempty"`
+	Mapping     LabelConstraintMapping `json:"mapping"`
+}
+
+// labelRange is a key range of a label rule, the range of a key prefix is
+// from the prefix to the first key without the prefix.
+type labelRange struct {
+	start, end []byte
+	rule       *labeler.LabelRule
+}
+
+func collectLabelRanges(rules []*labeler.LabelRule) []labelRange {
+	var ranges []labelRange
+	for _, rule := range rules {
+		switch data := rule.Data.(type) {
+		case []*labeler.KeyRangeRule:
+			for _, r := range data {
+				ranges = append(ranges, labelRange{start: r.StartKey, end: r.EndKey, rule: rule})
+			}
+		case []string: // the hex format prefixes of the type `KeyPrefix`.
+			for _, h := range data {
+				prefix, err := hex.DecodeString(h)
+				if err != nil {
+					continue
+				}
+				ranges = append(ranges, labelRange{start: prefix, end: prefixEnd(prefix), rule: rule})
+			}
+		}
+	}
+	return ranges
+}
+
+// prefixEnd returns the first key without the prefix, it's nil if there is
+// no such key.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
+
+// CheckRuleLabelAlignment reports the ranges where the region labels and the
+// placement rules disagree with the mappings: a range labeled by a mapping
+// but not placed by any rule with the mapped constraint, or a range of a rule
+// with the mapped constraint but not labeled by the mapping. It's supposed to
+// be run periodically to find the drift between the two subsystems.
+func CheckRuleLabelAlignment(rm *RuleManager, rl *labeler.RegionLabeler, mappings []LabelConstraintMapping) []Misalignment {
+	labelRules := rl.GetAllLabelRules()
+	sort.Slice(labelRules, func(i, j int) bool { return labelRules[i].ID < labelRules[j].ID })
+	labelRanges := collectLabelRanges(labelRules)
+	rules := rm.GetAllRules()
+	var res []Misalignment
+	for _, mapping := range mappings {
+		mapping := mapping
+		// the labeled ranges without a placement rule.
+		for _, lr := range labelRanges {
+			if !hasRegionLabel(lr.rule.Labels, mapping.LabelKey, mapping.LabelValue) {
+				continue
+			}
+			keys := rm.GetSplitKeys(lr.start, lr.end)
+			forEachSubRange(lr.start, lr.end, keys, func(start, end []byte) bool {
+				for _, r := range rm.GetRulesForApplyRange(start, end) {
+					if mapping.matchRule(r) {
+						return true
+					}
+				}
+				return false
+			}, func(start, end []byte) {
+				res = append(res, Misalignment{
+					Type:        MissingPlacementRule,
+					StartKeyHex: hex.EncodeToString(start),
+					EndKeyHex:   hex.EncodeToString(end),
+					LabelRuleID: lr.rule.ID,
+					Mapping:     mapping,
+				})
+			})
+		}
+		// the ranges of the placement rules without a label rule.
+		for _, r := range rules {
+			if !mapping.matchRule(r) {
+				continue
+			}
+			keys := labelSplitKeys(labelRanges, r.StartKey, r.EndKey)
+			forEachSubRange(r.StartKey, r.EndKey, keys, func(start, end []byte) bool {
+				region := core.NewRegionInfo(&metapb.Region{StartKey: start, EndKey: end}, nil)
+				for _, l := range rl.GetRegionLabels(region) {
+					if l.Key == mapping.LabelKey && l.Value == mapping.LabelValue {
+						return true
+					}
+				}
+				return false
+			}, func(start, end []byte) {
+				res = append(res, Misalignment{
+					Type:        MissingLabelRule,
+					StartKeyHex: hex.EncodeToString(start),
+					EndKeyHex:   hex.EncodeToString(end),
+					GroupID:     r.GroupID,
+					RuleID:      r.ID,
+					Mapping:     mapping,
+				})
+			})
+		}
+	}
+	return res
+}
+
+func hasRegionLabel(labels []labeler.RegionLabel, key, value string) bool {
+	for _, l := range labels {
+		if l.Key == key && l.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// labelSplitKeys returns the sorted boundaries of the label ranges within
+// (start, end), so every sub-range is either fully labeled by a label rule or
+// not at all.
+func labelSplitKeys(ranges []labelRange, start, end []byte) [][]byte {
+	var keys [][]byte
+	add := func(key []byte) {
+		if len(key) > 0 && bytes.Compare(key, start) > 0 && (len(end) == 0 || bytes.Compare(key, end) < 0) {
+			keys = append(keys, key)
+		}
+	}
+	for _, r := range ranges {
+		add(r.start)
+		add(r.end)
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	res := keys[:0]
+	for i, key := range keys {
+		if i == 0 || !bytes.Equal(key, keys[i-1]) {
+			res = append(res, key)
+		}
+	}
+	return res
+}
+
+// forEachSubRange splits [start, end) by the sorted keys, and reports the
+// adjacent sub-ranges failing the check as a whole.
+func forEachSubRange(start, end []byte, keys [][]byte, check func(start, end []byte) bool, report func(start, end []byte)) {
+	var failedStart []byte
+	failed := false
+	bounds := append(append([][]byte{start}, keys...), end)
+	for i := 0; i+1 < len(bounds); i++ {
+		if check(bounds[i], bounds[i+1]) {
+			if failed {
+				report(failedStart, bounds[i])
+				failed = false
+			}
+			continue
+		}
+		if !failed {
+			failedStart, failed = bounds[i], true
+		}
+	}
+	if failed {
+		report(failedStart, end)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+)
+
+func TestCheckRuleLabelAlignment(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rl, err := labeler.NewRegionLabeler(ctx, store, time.Hour)
+	re.NoError(err)
+
+	ssd := LabelConstraint{Key: "disk", Op: In, Values: []string{"ssd"}}
+	re.NoError(manager.SetRules([]*Rule{
+		{GroupID: "g", ID: "r1", Role: Voter, Count: 1, StartKeyHex: "10", EndKeyHex: "20", LabelConstraints: []LabelConstraint{ssd}},
+		{GroupID: "g", ID: "r2", Role: Voter, Count: 1, StartKeyHex: "40", EndKeyHex: "50", LabelConstraints: []LabelConstraint{ssd}},
+		{GroupID: "g", ID: "r3", Role: Voter, Count: 1, StartKeyHex: "6000", EndKeyHex: "6001", LabelConstraints: []LabelConstraint{ssd}},
+	}))
+	labels := []labeler.RegionLabel{{Key: "disk", Value: "ssd"}}
+	re.NoError(rl.SetLabelRule(&labeler.LabelRule{ID: "l1", Labels: labels, RuleType: labeler.KeyRange, Data: labeler.MakeKeyRanges("10", "30")}))
+	re.NoError(rl.SetLabelRule(&labeler.LabelRule{ID: "l2", Labels: labels, RuleType: labeler.KeyPrefix, Data: []interface{}{"60"}}))
+	re.NoError(rl.SetLabelRule(&labeler.LabelRule{ID: "l3", Labels: []labeler.RegionLabel{{Key: "disk", Value: "hdd"}}, RuleType: labeler.KeyRange, Data: labeler.MakeKeyRanges("40", "50")}))
+
+	mapping := LabelConstraintMapping{LabelKey: "disk", LabelValue: "ssd", Constraint: ssd}
+	res := CheckRuleLabelAlignment(manager, rl, []LabelConstraintMapping{mapping})
+	re.Equal([]Misalignment{
+		// [20, 30) is labeled but only placed by the default rule.
+		{Type: MissingPlacementRule, StartKeyHex: "20", EndKeyHex: "30", LabelRuleID: "l1", Mapping: mapping},
+		// only [6000, 6001) of the prefix is placed by the rule.
+		{Type: MissingPlacementRule, StartKeyHex: "60", EndKeyHex: "6000", LabelRuleID: "l2", Mapping: mapping},
+		{Type: MissingPlacementRule, StartKeyHex: "6001", EndKeyHex: "61", LabelRuleID: "l2", Mapping: mapping},
+		// [40, 50) is labeled with another value.
+		{Type: MissingLabelRule, StartKeyHex: "40", EndKeyHex: "50", GroupID: "g", RuleID: "r2", Mapping: mapping},
+	}, res)
+
+	// nothing is reported once they are aligned.
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", Role: Voter, Count: 1, StartKeyHex: "10", EndKeyHex: "30", LabelConstraints: []LabelConstraint{ssd}}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r3", Role: Voter, Count: 1, StartKeyHex: "60", EndKeyHex: "61", LabelConstraints: []LabelConstraint{ssd}}))
+	re.NoError(manager.DeleteRule("g", "r2", false))
+	re.Empty(CheckRuleLabelAlignment(manager, rl, []LabelConstraintMapping{mapping}))
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// The operations of the audit entries.
+const (
+	AuditSetRule         = "set-rule"
+	AuditDeleteRule      = "delete-rule"
+	AuditSetRuleGroup    = "set-rule-group"
+	AuditDeleteRuleGroup = "delete-rule-group"
+)
+
+// RuleAuditEntry is the audit record of a mutation of a rule or rule group.
+// The entries are chained by the hashes, so a removed or modified entry can
+// be detected by verifying the chain, see VerifyAuditChain.
+type RuleAuditEntry struct {
+	// Seq is increased by every entry emitted by a RuleManager.
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Operation string    `json:"operation"`
+	GroupID   string    `json:"group_id"`
+	// ID is the ID of the rule, it's empty for the rule groups.
+	ID string `json:"id,omitempty"`
+	// Before and After are the JSON values before and after the mutation,
+	// they are null if the rule or rule group doesn't exist.
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+	// PrevHash is the Hash of the previous entry, and Hash is the hex encoded
+	// SHA-256 of the entry with the PrevHash.
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// computeHash returns the hash of the entry without the Hash itself.
+func (e *RuleAuditEntry) computeHash() string {
+	c := *e
+	c.Hash = ""
+	data, _ := json.Marshal(&c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyAuditChain checks whether the entries emitted in order are intact,
+// it returns the index of the first broken entry, or -1 if they are intact.
+func VerifyAuditChain(entries []*RuleAuditEntry) int {
+	for i, e := range entries {
+		if e.computeHash() != e.Hash {
+			return i
+		}
+		if i > 0 && (e.PrevHash != entries[i-1].Hash || e.Seq != entries[i-1].Seq+1) {
+			return i
+		}
+	}
+	return -1
+}
+
+// RuleAuditSink receives the audit entries. It's called synchronously with the
+// RuleManager locked after the mutations are saved, in the order of the
+// entries, so it should return quickly and must not call the RuleManager.
+type RuleAuditSink interface {
+	Audit(entry *RuleAuditEntry)
+}
+
+// logAuditSink writes the audit entries to the log, it's the default sink.
+type logAuditSink struct{}
+
+// Audit implements RuleAuditSink.
+func (logAuditSink) Audit(e *RuleAuditEntry) {
+	log.Info("placement rule audit",
+		zap.Uint64("seq", e.Seq),
+		zap.Time("time", e.Time),
+		zap.String("operation", e.Operation),
+		zap.String("group-id", e.GroupID),
+		zap.String("id", e.ID),
+		zap.ByteString("before", e.Before),
+		zap.ByteString("after", e.After),
+		zap.String("prev-hash", e.PrevHash),
+		zap.String("hash", e.Hash))
+}
+
+// ruleAuditor chains the audit entries and emits them to the sink.
+type ruleAuditor struct {
+	sink     RuleAuditSink
+	seq      uint64
+	lastHash string
+}
+
+func newRuleAuditor() *ruleAuditor {
+	return &ruleAuditor{sink: logAuditSink{}}
+}
+
+// SetAuditSink replaces the sink of the audit entries, the entries are written
+// to the log by default. A nil sink restores the default one.
+func (m *RuleManager) SetAuditSink(sink RuleAuditSink) {
+	m.Lock()
+	defer m.Unlock()
+	if sink == nil {
+		sink = logAuditSink{}
+	}
+	m.auditor.sink = sink
+}
+
+// newAuditEntries returns the audit entries of the patch, it must be called
+// before the patch is committed, so the values before the mutations are
+// captured in the same critical section. The rules are audited before the
+// rule groups, and each of them in the order of keys like notifyObservers.
+func newAuditEntries(p *ruleConfigPatch) []*RuleAuditEntry {
+	var entries []*RuleAuditEntry
+	for _, key := range p.mut.ruleKeys() {
+		e := &RuleAuditEntry{GroupID: key[0], ID: key[1], Operation: AuditSetRule}
+		after := p.mut.rules[key]
+		if after == nil {
+			e.Operation = AuditDeleteRule
+		}
+		e.Before, e.After = auditValue(p.c.rules[key]), auditValue(after)
+		entries = append(entries, e)
+	}
+	ids := make([]string, 0, len(p.mut.groups))
+	for id := range p.mut.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		e := &RuleAuditEntry{GroupID: id, Operation: AuditSetRuleGroup}
+		var before, after *RuleGroup
+		if g, ok := p.c.groups[id]; ok {
+			before = g
+		}
+		if g := p.mut.groups[id]; g.isDefault() {
+			e.Operation = AuditDeleteRuleGroup
+		} else {
+			after = g
+		}
+		e.Before, e.After = auditValue(before), auditValue(after)
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// auditValue returns the JSON value of the rule or rule group, it's null if
+// the value is nil.
+func auditValue[T any](v *T) json.RawMessage {
+	if v == nil {
+		return json.RawMessage("null")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+// audit stamps the entries and emits them to the sink.
+func (m *RuleManager) audit(entries []*RuleAuditEntry) {
+	a := m.auditor
+	now := time.Now()
+	for _, e := range entries {
+		a.seq++
+		e.Seq, e.Time, e.PrevHash = a.seq, now, a.lastHash
+		e.Hash = e.computeHash()
+		a.lastHash = e.Hash
+		a.sink.Audit(e)
+	}
+}
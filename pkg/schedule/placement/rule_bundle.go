@@ -0,0 +1,303 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"go.uber.org/zap"
+)
+
+// RuleBundleVersion is the schema version of the bundle generated by Export.
+// Please bump it once the format of the bundle is changed incompatibly, and
+// migrate the old versions in Import.
+const RuleBundleVersion = 1
+
+// ImportMode indicates how the existing configuration is handled by Import.
+type ImportMode string
+
+const (
+	// ImportReplace drops the existing rules and groups not present in the bundle.
+	ImportReplace ImportMode = "replace"
+	// ImportMerge keeps the existing rules and groups not present in the bundle.
+	ImportMerge ImportMode = "merge"
+)
+
+// RuleBundle is the versioned bundle of all rules and rule groups, which is
+// used to migrate the placement configuration between clusters.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleBundle struct {
+	Version int          `json:"version"`
+	Groups  []*RuleGroup `json:"groups"`
+	Rules   []*Rule      `json:"rules"`
+}
+
+// PlacementBundle is the versioned bundle of all rules, rule groups and
+// region label rules, which is imported atomically by ImportPlacementBundle.
+// It's compatible with RuleBundle, so it can be imported by Import too, with
+// the label rules ignored.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type PlacementBundle struct {
+	Version    int                  `json:"version"`
+	Groups     []*RuleGroup         `json:"groups"`
+	Rules      []*Rule              `json:"rules"`
+	LabelRules []*labeler.LabelRule `json:"label_rules"`
+}
+
+// Export serializes all rules and rule groups into a bundle.
+func (m *RuleManager) Export() ([]byte, error) {
+	return json.Marshal(m.exportBundle())
+}
+
+func (m *RuleManager) exportBundle() RuleBundle {
+	m.RLock()
+	bundle := RuleBundle{
+		Version: RuleBundleVersion,
+		Groups:  make([]*RuleGroup, 0, len(m.ruleConfig.groups)),
+		Rules:   make([]*Rule, 0, len(m.ruleConfig.rules)),
+	}
+	for _, g := range m.ruleConfig.groups {
+		bundle.Groups = append(bundle.Groups, g)
+	}
+	for _, r := range m.ruleConfig.rules {
+		bundle.Rules = append(bundle.Rules, r)
+	}
+	m.RUnlock()
+	sort.Slice(bundle.Groups, func(i, j int) bool { return bundle.Groups[i].ID < bundle.Groups[j].ID })
+	sortRules(bundle.Rules)
+	return bundle
+}
+
+// ExportGroup serializes the rules and the configuration of a rule group into
+// a bundle, which can be imported by ImportGroup to migrate only the group.
+// The group configuration is always included, so the index and override of
+// the group are kept in the target cluster.
+func (m *RuleManager) ExportGroup(groupID string) ([]byte, error) {
+	m.RLock()
+	_, ok := m.ruleConfig.groups[groupID]
+	bundle := RuleBundle{
+		Version: RuleBundleVersion,
+		Groups:  []*RuleGroup{m.ruleConfig.getGroup(groupID)},
+		Rules:   make([]*Rule, 0),
+	}
+	for _, r := range m.ruleConfig.rules {
+		if r.GroupID == groupID {
+			bundle.Rules = append(bundle.Rules, r)
+		}
+	}
+	m.RUnlock()
+	if !ok && len(bundle.Rules) == 0 {
+		return nil, errs.ErrRuleGroupNotFound.FastGenByArgs(groupID)
+	}
+	sortRules(bundle.Rules)
+	return json.Marshal(bundle)
+}
+
+// Import applies the bundle generated by Export. With ImportReplace, the
+// existing rules and groups not present in the bundle are deleted. With
+// ImportMerge, they are preserved. All the updates are saved in a single
+// patch, so either all of them take effect or none of them does.
+func (m *RuleManager) Import(data []byte, mode ImportMode) error {
+	bundle, err := m.parseBundle(data, mode)
+	if err != nil {
+		return err
+	}
+	return m.importBundle(bundle, mode, false)
+}
+
+// importBundle applies the parsed bundle. If atomic is true, the bundle is
+// rejected once it makes any region unschedulable or it can't be saved in one
+// transaction with the extra operations.
+func (m *RuleManager) importBundle(bundle *RuleBundle, mode ImportMode, atomic bool, extraOps ...func(txn kv.Txn) error) error {
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	if mode == ImportReplace {
+		for k := range m.ruleConfig.rules {
+			p.deleteRule(k[0], k[1])
+		}
+		for id := range m.ruleConfig.groups {
+			p.deleteGroup(id)
+		}
+	}
+	if atomic {
+		if ops := len(p.mut.rules) + len(bundle.Rules) + len(p.mut.groups) + len(bundle.Groups) + len(extraOps) + historyTxnOps; ops > maxEtcdTxnOps {
+			return errs.ErrRuleBundle.FastGenByArgs("too many changes to be saved in one transaction")
+		}
+		p.checkSchedulable = true
+	}
+	p.extraOps = extraOps
+	return m.commitBundle(p, bundle, mode)
+}
+
+// ImportGroup applies the bundle generated by ExportGroup, the other groups
+// are not touched. With ImportReplace, the existing rules of the group not
+// present in the bundle are deleted. With ImportMerge, they are preserved.
+func (m *RuleManager) ImportGroup(groupID string, data []byte, mode ImportMode) error {
+	bundle, err := m.parseBundle(data, mode)
+	if err != nil {
+		return err
+	}
+	for _, g := range bundle.Groups {
+		if g.ID != groupID {
+			return errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("group %s does not match group ID %s", g.ID, groupID))
+		}
+	}
+	for _, r := range bundle.Rules {
+		if r.GroupID != groupID {
+			return errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("rule %s/%s does not match group ID %s", r.GroupID, r.ID, groupID))
+		}
+	}
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	if mode == ImportReplace {
+		for k := range m.ruleConfig.rules {
+			if k[0] == groupID {
+				p.deleteRule(k[0], k[1])
+			}
+		}
+	}
+	return m.commitBundle(p, bundle, mode)
+}
+
+// parseBundle decodes and checks the bundle, the rules in it are adjusted.
+func (m *RuleManager) parseBundle(data []byte, mode ImportMode) (*RuleBundle, error) {
+	if mode != ImportReplace && mode != ImportMerge {
+		return nil, errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("unknown import mode %s", mode))
+	}
+	var bundle RuleBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, errs.ErrRuleBundle.FastGenByArgs(err.Error())
+	}
+	if bundle.Version <= 0 || bundle.Version > RuleBundleVersion {
+		return nil, errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("unsupported version %d", bundle.Version))
+	}
+	groups := make(map[string]struct{}, len(bundle.Groups))
+	for _, g := range bundle.Groups {
+		if g == nil || g.ID == "" {
+			return nil, errs.ErrRuleBundle.FastGenByArgs("group ID should not be empty")
+		}
+		if _, ok := groups[g.ID]; ok {
+			return nil, errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("duplicated group %s", g.ID))
+		}
+		groups[g.ID] = struct{}{}
+	}
+	rules := make(map[[2]string]struct{}, len(bundle.Rules))
+	for _, r := range bundle.Rules {
+		if r == nil {
+			return nil, errs.ErrRuleBundle.FastGenByArgs("rule should not be null")
+		}
+		if err := m.adjustRule(r, ""); err != nil {
+			return nil, err
+		}
+		if _, ok := rules[r.Key()]; ok {
+			return nil, errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("duplicated rule %s/%s", r.GroupID, r.ID))
+		}
+		rules[r.Key()] = struct{}{}
+	}
+	return &bundle, nil
+}
+
+// commitBundle sets the groups and rules of the bundle in the patch, and
+// commits it. It must be called with the lock held.
+func (m *RuleManager) commitBundle(p *ruleConfigPatch, bundle *RuleBundle, mode ImportMode) error {
+	for _, g := range bundle.Groups {
+		p.setGroup(g)
+	}
+	for _, r := range bundle.Rules {
+		p.setRule(r)
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("rule bundle imported",
+		zap.Int("version", bundle.Version),
+		zap.String("mode", string(mode)),
+		zap.Int("group-count", len(bundle.Groups)),
+		zap.Int("rule-count", len(bundle.Rules)))
+	return nil
+}
+
+// ExportPlacementBundle serializes all rules, rule groups and region label
+// rules into a bundle.
+func ExportPlacementBundle(rm *RuleManager, rl *labeler.RegionLabeler) ([]byte, error) {
+	rules := rm.exportBundle()
+	bundle := PlacementBundle{
+		Version:    rules.Version,
+		Groups:     rules.Groups,
+		Rules:      rules.Rules,
+		LabelRules: rl.GetAllLabelRules(),
+	}
+	sort.Slice(bundle.LabelRules, func(i, j int) bool { return bundle.LabelRules[i].ID < bundle.LabelRules[j].ID })
+	return json.Marshal(bundle)
+}
+
+// ImportPlacementBundle applies the bundle generated by ExportPlacementBundle
+// as a whole: the rules, rule groups and region label rules are saved in one
+// transaction, so either all of them take effect or none of them does. Unlike
+// Import, the rules are validated against the store topology, and the bundle
+// is rejected if it makes any region unschedulable. With ImportReplace, the
+// existing label rules not present in the bundle are deleted too.
+func ImportPlacementBundle(rm *RuleManager, rl *labeler.RegionLabeler, data []byte, mode ImportMode) error {
+	bundle, err := rm.parseBundle(data, mode)
+	if err != nil {
+		return err
+	}
+	for _, r := range bundle.Rules {
+		if err := rm.ValidateAgainstTopology(r); err != nil {
+			return err
+		}
+	}
+	var labelBundle PlacementBundle
+	if err := json.Unmarshal(data, &labelBundle); err != nil {
+		return errs.ErrRuleBundle.FastGenByArgs(err.Error())
+	}
+	patch := labeler.LabelRulePatch{SetRules: make([]*labeler.LabelRule, 0, len(labelBundle.LabelRules))}
+	ids := make(map[string]struct{}, len(labelBundle.LabelRules))
+	for _, r := range labelBundle.LabelRules {
+		if r == nil {
+			return errs.ErrRuleBundle.FastGenByArgs("label rule should not be null")
+		}
+		if _, ok := ids[r.ID]; ok {
+			return errs.ErrRuleBundle.FastGenByArgs(fmt.Sprintf("duplicated label rule %s", r.ID))
+		}
+		ids[r.ID] = struct{}{}
+		patch.SetRules = append(patch.SetRules, r)
+	}
+	if mode == ImportReplace {
+		for _, r := range rl.GetAllLabelRules() {
+			if _, ok := ids[r.ID]; !ok {
+				patch.DeleteRules = append(patch.DeleteRules, r.ID)
+			}
+		}
+		sort.Strings(patch.DeleteRules)
+	}
+	if err := rl.PatchWith(patch, func(ops ...func(txn kv.Txn) error) error {
+		return rm.importBundle(bundle, mode, true, ops...)
+	}); err != nil {
+		return err
+	}
+	log.Info("label rules imported with the rule bundle",
+		zap.Int("label-rule-count", len(patch.SetRules)),
+		zap.Int("deleted-label-rule-count", len(patch.DeleteRules)))
+	return nil
+}
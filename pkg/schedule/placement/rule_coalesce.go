@@ -0,0 +1,87 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+
+	"github.com/pingcap/log"
+	"go.uber.org/zap"
+)
+
+// Coalesce merges the rules of the same group whose key ranges are adjacent
+// and whose other fields are identical, except the IDs, into the first one of
+// them spanning the combined range, and removes the others. It returns the
+// number of the removed rules. All the merges are committed as one update.
+// NOTE: the overlapped rules are never merged, since each of them places its
+// own peers in the overlapped range. The rules of the frozen groups are kept
+// as they are.
+func (m *RuleManager) Coalesce() (merged int, err error) {
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	for _, rules := range coalescableRules(m.ruleConfig) {
+		sort.Slice(rules, func(i, j int) bool {
+			return bytes.Compare(rules[i].StartKey, rules[j].StartKey) < 0
+		})
+		var cur *Rule
+		for _, r := range rules {
+			if cur != nil && len(cur.EndKey) > 0 && bytes.Equal(cur.EndKey, r.StartKey) {
+				cur.EndKey, cur.EndKeyHex = r.EndKey, r.EndKeyHex
+				p.setRule(cur)
+				p.deleteRule(r.GroupID, r.ID)
+				merged++
+				log.Info("placement rule is coalesced", zap.String("group", r.GroupID), zap.String("id", r.ID), zap.String("into", cur.ID))
+				continue
+			}
+			cur = r.Clone()
+		}
+	}
+	if merged == 0 {
+		return 0, nil
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return 0, err
+	}
+	log.Info("placement rules coalesced", zap.Int("merged", merged))
+	return merged, nil
+}
+
+// coalescableRules groups the rules of the groups which are not frozen by the
+// group ID and the fields except the ID and the key range.
+func coalescableRules(c *ruleConfig) map[string][]*Rule {
+	res := make(map[string][]*Rule)
+	for _, r := range c.rules {
+		if g, ok := c.groups[r.GroupID]; ok && g.Frozen {
+			continue
+		}
+		sig := coalesceSignature(r)
+		res[sig] = append(res[sig], r)
+	}
+	return res
+}
+
+// coalesceSignature returns the JSON of the rule without the fields which
+// take no part in the placement or are set by RuleManager.
+func coalesceSignature(r *Rule) string {
+	c := r.Clone()
+	c.ID, c.StartKeyHex, c.EndKeyHex = "", "", ""
+	c.StartKey, c.EndKey = nil, nil
+	c.Revision, c.SchemaVersion, c.Version, c.CreateTimestamp = 0, 0, 0, 0
+	data, _ := json.Marshal(c)
+	return string(data)
+}
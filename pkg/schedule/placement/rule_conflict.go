@@ -0,0 +1,223 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/tikv/pd/pkg/core"
+)
+
+// RuleConflict is a pair of rules which are applied to the overlapping range
+// but can not be satisfied at the same time.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleConflict struct {
+	Rule1       [2]string `json:"rule1"`
+	Rule2       [2]string `json:"rule2"`
+	StartKey    []byte    `json:"-"`
+	StartKeyHex string    `json:"start_key"`
+	EndKey      []byte    `json:"-"`
+	EndKeyHex   string    `json:"end_key"`
+	Reason      string    `json:"reason"`
+}
+
+// CheckConflicts returns the pairs of rules which are applied to the
+// overlapping range together but can not be satisfied at the same time, for
+// example, both of them require the leader, or there are not enough stores to
+// place the peers of both of them.
+func (m *RuleManager) CheckConflicts() []RuleConflict {
+	stores := m.getAliveStores()
+	m.RLock()
+	defer m.RUnlock()
+	checked := make(map[[2][2]string]struct{})
+	var conflicts []RuleConflict
+	for _, rr := range m.ruleList.ranges {
+		for i, a := range rr.applyRules {
+			for _, b := range rr.applyRules[i+1:] {
+				pair := [2][2]string{a.Key(), b.Key()}
+				if _, ok := checked[pair]; ok {
+					continue
+				}
+				checked[pair] = struct{}{}
+				reason := checkRuleConflict(a, b, stores)
+				if len(reason) == 0 {
+					continue
+				}
+				start, end := a.StartKey, a.EndKey
+				if bytes.Compare(b.StartKey, start) > 0 {
+					start = b.StartKey
+				}
+				if len(end) == 0 || (len(b.EndKey) > 0 && bytes.Compare(b.EndKey, end) < 0) {
+					end = b.EndKey
+				}
+				conflicts = append(conflicts, RuleConflict{
+					Rule1:       a.Key(),
+					Rule2:       b.Key(),
+					StartKey:    start,
+					StartKeyHex: hex.EncodeToString(start),
+					EndKey:      end,
+					EndKeyHex:   hex.EncodeToString(end),
+					Reason:      reason,
+				})
+			}
+		}
+	}
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Rule1 != conflicts[j].Rule1 {
+			return lessRuleKey(conflicts[i].Rule1, conflicts[j].Rule1)
+		}
+		return lessRuleKey(conflicts[i].Rule2, conflicts[j].Rule2)
+	})
+	return conflicts
+}
+
+// GroupOverload is a range where the rules of a group need more peers than
+// the alive stores.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type GroupOverload struct {
+	GroupID     string `json:"group_id"`
+	StartKey    []byte `json:"-"`
+	StartKeyHex string `json:"start_key"`
+	EndKey      []byte `json:"-"`
+	EndKeyHex   string `json:"end_key"`
+	// Count is the total count of the peers required by the rules of the group
+	// applied to the range.
+	Count  int `json:"count"`
+	Stores int `json:"stores"`
+}
+
+// RuleConflictReport is the result of analyzing the whole rule configuration.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleConflictReport struct {
+	// Conflicts are the pairs of rules which can not be satisfied at the same
+	// time in the overlapping range, see CheckConflicts.
+	Conflicts []RuleConflict `json:"conflicts"`
+	// OverloadedGroups are the ranges where the rules of a group need more
+	// peers than the alive stores.
+	OverloadedGroups []GroupOverload `json:"overloaded_groups"`
+	// UnsatisfiableRules are the keys of the rules which can not match any
+	// alive store by their label constraints.
+	UnsatisfiableRules [][2]string `json:"unsatisfiable_rules"`
+}
+
+// CheckRuleConflicts analyzes the whole rule configuration against the alive
+// stores, and reports the conflicting rule pairs, the overloaded groups and
+// the unsatisfiable rules. Unlike CheckUnsatisfiableRules, it changes nothing
+// so it's safe to be called by the tools at any time.
+func (m *RuleManager) CheckRuleConflicts() *RuleConflictReport {
+	report := &RuleConflictReport{Conflicts: m.CheckConflicts()}
+	stores := m.getAliveStores()
+	m.RLock()
+	defer m.RUnlock()
+	// there is no store yet, such as the cluster is bootstrapping.
+	if len(stores) == 0 {
+		return report
+	}
+	for key, r := range m.ruleConfig.rules {
+		if r.IsEnabled() && !checkRule(r, stores) {
+			report.UnsatisfiableRules = append(report.UnsatisfiableRules, key)
+		}
+	}
+	sort.Slice(report.UnsatisfiableRules, func(i, j int) bool {
+		return lessRuleKey(report.UnsatisfiableRules[i], report.UnsatisfiableRules[j])
+	})
+	report.OverloadedGroups = findGroupOverloads(m.ruleList.ranges, len(stores))
+	return report
+}
+
+// findGroupOverloads returns the ranges where the total count of the rules of
+// a group exceeds the number of the stores. The adjacent ranges with the same
+// group and count are merged.
+func findGroupOverloads(ranges []rangeRules, stores int) []GroupOverload {
+	var overloads []GroupOverload
+	// last records the overloads of the groups ending at the current range.
+	last := make(map[string]int)
+	for i, rr := range ranges {
+		var end []byte
+		if i+1 < len(ranges) {
+			end = ranges[i+1].startKey
+		}
+		counts := make(map[string]int)
+		for _, r := range rr.applyRules {
+			counts[r.GroupID] += r.Count
+		}
+		groups := make([]string, 0, len(counts))
+		for id, count := range counts {
+			if count > stores {
+				groups = append(groups, id)
+			}
+		}
+		sort.Strings(groups)
+		current := make(map[string]int, len(groups))
+		for _, id := range groups {
+			if j, ok := last[id]; ok && overloads[j].Count == counts[id] {
+				overloads[j].EndKey, overloads[j].EndKeyHex = end, hex.EncodeToString(end)
+				current[id] = j
+				continue
+			}
+			overloads = append(overloads, GroupOverload{
+				GroupID:     id,
+				StartKey:    rr.startKey,
+				StartKeyHex: hex.EncodeToString(rr.startKey),
+				EndKey:      end,
+				EndKeyHex:   hex.EncodeToString(end),
+				Count:       counts[id],
+				Stores:      stores,
+			})
+			current[id] = len(overloads) - 1
+		}
+		last = current
+	}
+	return overloads
+}
+
+// checkRuleConflict returns the reason why the two rules can not be satisfied
+// at the same time, it returns an empty string if they are compatible.
+func checkRuleConflict(a, b *Rule, stores []*core.StoreInfo) string {
+	if a.Role == Leader && b.Role == Leader {
+		return "both rules require the leader"
+	}
+	if len(stores) == 0 {
+		return ""
+	}
+	var matchA, matchB, matchAny int
+	for _, s := range stores {
+		inA, inB := a.MatchStore(s), b.MatchStore(s)
+		if inA {
+			matchA++
+		}
+		if inB {
+			matchB++
+		}
+		if inA || inB {
+			matchAny++
+		}
+	}
+	// the rule can not be satisfied by itself, which is not a conflict.
+	if matchA < a.Count || matchB < b.Count {
+		return ""
+	}
+	if matchAny < a.Count+b.Count {
+		return fmt.Sprintf("the rules need %d peers in total but only %d stores match their label constraints", a.Count+b.Count, matchAny)
+	}
+	return ""
+}
+
+func lessRuleKey(a, b [2]string) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
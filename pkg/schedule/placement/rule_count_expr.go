@@ -0,0 +1,115 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// RuleCountExpr makes the count of a rule grow with the cluster. The count is
+// the Percentage of the online stores matching the label constraints of the
+// rule, rounded up and bounded by [Min, Max], where Max is unbounded if it's 0.
+// The count is at least 1 even if there is no matching store.
+//
+// For example, {"percentage": 20, "min": 3} places the replicas on 20% of the
+// matching stores, and at least 3 of them. The count is resolved when fitting
+// the regions, so the regions are scheduled as the stores join and leave.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleCountExpr struct {
+	Percentage float64 `json:"percentage"`
+	Min        int     `json:"min,omitempty"`
+	Max        int     `json:"max,omitempty"`
+}
+
+func (e *RuleCountExpr) validate() error {
+	if e == nil {
+		return nil
+	}
+	if e.Percentage <= 0 || e.Percentage > 100 {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid percentage %v of count expression", e.Percentage))
+	}
+	if e.Min < 0 || e.Max < 0 {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid bounds [%d, %d] of count expression", e.Min, e.Max))
+	}
+	if e.Max > 0 && e.Min > e.Max {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("min %d of count expression should not be greater than max %d", e.Min, e.Max))
+	}
+	return nil
+}
+
+// minCount returns the count of the rule if there is no matching store.
+func (e *RuleCountExpr) minCount() int {
+	if e.Min > 1 {
+		return e.Min
+	}
+	return 1
+}
+
+// resolve returns the count against the stores, the stores which are not up
+// or have been disconnected are not counted.
+func (e *RuleCountExpr) resolve(stores []*core.StoreInfo, rule *Rule) int {
+	matched := 0
+	for _, s := range stores {
+		if s.IsUp() && !s.IsDisconnected() && rule.MatchStore(s) {
+			matched++
+		}
+	}
+	count := int(math.Ceil(float64(matched) * e.Percentage / 100))
+	if e.Max > 0 && count > e.Max {
+		count = e.Max
+	}
+	if lower := e.minCount(); count < lower {
+		count = lower
+	}
+	return count
+}
+
+// resolveRuleCounts returns the rules with the counts resolved against the
+// stores. The rules with a count expression are copied, and the others are
+// returned as is.
+func resolveRuleCounts(rules []*Rule, stores []*core.StoreInfo) []*Rule {
+	var res []*Rule
+	for i, r := range rules {
+		if r.CountExpr == nil {
+			if res != nil {
+				res = append(res, r)
+			}
+			continue
+		}
+		if res == nil {
+			res = append(make([]*Rule, 0, len(rules)), rules[:i]...)
+		}
+		resolved := *r
+		resolved.Count = r.CountExpr.resolve(stores, r)
+		res = append(res, &resolved)
+	}
+	if res == nil {
+		return rules
+	}
+	return res
+}
+
+func hasCountExpr(rules []*Rule) bool {
+	for _, r := range rules {
+		if r.CountExpr != nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// RegionRuleStatus is a rule applied to a region and whether the region
+// satisfies it.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RegionRuleStatus struct {
+	Rule      *Rule `json:"rule"`
+	Satisfied bool  `json:"satisfied"`
+}
+
+// RulesForRegion returns the rules applied to the region in the apply order,
+// which is the order of their priority after the overrides are resolved, and
+// whether the region satisfies each of them. The rules are the effective ones
+// fitting the region, i.e., the label constraints inherited from the groups are
+// included and the count expressions are resolved.
+func (m *RuleManager) RulesForRegion(regionID uint64) ([]*RegionRuleStatus, error) {
+	regionSet, ok := m.storeSetInformer.(interface {
+		GetRegion(regionID uint64) *core.RegionInfo
+	})
+	if !ok {
+		return nil, errors.New("the cluster does not support getting regions")
+	}
+	region := regionSet.GetRegion(regionID)
+	if region == nil {
+		return nil, errors.Errorf("region %d not found", regionID)
+	}
+	fit := m.FitRegion(m.storeSetInformer, region)
+	res := make([]*RegionRuleStatus, 0, len(fit.RuleFits))
+	for _, rf := range fit.RuleFits {
+		res = append(res, &RegionRuleStatus{Rule: rf.Rule.Clone(), Satisfied: rf.IsSatisfied()})
+	}
+	return res, nil
+}
@@ -0,0 +1,330 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/tikv/pd/pkg/schedule/labeler"
+)
+
+// FieldChange is the change of a field between two versions of a rule.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RuleChange is a rule modified in place.
+type RuleChange struct {
+	Old     *Rule         `json:"old"`
+	New     *Rule         `json:"new"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// RuleDiff is the difference between two rule sets. The rules are identified
+// by the group ID and the rule ID, and all the slices are sorted by them.
+type RuleDiff struct {
+	Added    []*Rule       `json:"added"`
+	Removed  []*Rule       `json:"removed"`
+	Modified []*RuleChange `json:"modified"`
+}
+
+// DiffRules compares two rule sets, such as the ones exported by
+// RuleManager.Export from two clusters. The runtime fields Version and
+// CreateTimestamp are ignored, and so is the order of LocationLabels and
+// LabelConstraints.
+func DiffRules(before, after []*Rule) RuleDiff {
+	var diff RuleDiff
+	oldRules := make(map[[2]string]*Rule, len(before))
+	for _, r := range before {
+		oldRules[r.Key()] = r
+	}
+	newRules := make(map[[2]string]*Rule, len(after))
+	for _, r := range after {
+		newRules[r.Key()] = r
+		o, ok := oldRules[r.Key()]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		if changes := diffRule(o, r); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, &RuleChange{Old: o, New: r, Changes: changes})
+		}
+	}
+	for _, r := range before {
+		if _, ok := newRules[r.Key()]; !ok {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+	sortRules(diff.Added)
+	sortRules(diff.Removed)
+	sort.Slice(diff.Modified, func(i, j int) bool {
+		return compareRule(diff.Modified[i].New, diff.Modified[j].New) < 0
+	})
+	return diff
+}
+
+func diffRule(before, after *Rule) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, o, n interface{}) {
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, FieldChange{Field: field, Old: o, New: n})
+		}
+	}
+	add("index", before.Index, after.Index)
+	add("override", before.Override, after.Override)
+	add("start_key", before.StartKeyHex, after.StartKeyHex)
+	add("end_key", before.EndKeyHex, after.EndKeyHex)
+	add("role", before.Role, after.Role)
+	add("is_witness", before.IsWitness, after.IsWitness)
+	add("count", before.Count, after.Count)
+	add("count_expr", before.CountExpr, after.CountExpr)
+	add("engine", before.Engine, after.Engine)
+	if !labelConstraintsEqual(before.LabelConstraints, after.LabelConstraints) {
+		changes = append(changes, FieldChange{Field: "label_constraints", Old: before.LabelConstraints, New: after.LabelConstraints})
+	}
+	if !labelConstraintAlternativesEqual(before.LabelConstraintAlternatives, after.LabelConstraintAlternatives) {
+		changes = append(changes, FieldChange{Field: "label_constraint_alternatives", Old: before.LabelConstraintAlternatives, New: after.LabelConstraintAlternatives})
+	}
+	if !stringSetEqual(before.LocationLabels, after.LocationLabels) {
+		changes = append(changes, FieldChange{Field: "location_labels", Old: before.LocationLabels, New: after.LocationLabels})
+	}
+	add("isolation_level", before.IsolationLevel, after.IsolationLevel)
+	add("keyspace_id", before.KeyspaceID, after.KeyspaceID)
+	add("keyspace_mode", before.KeyspaceMode, after.KeyspaceMode)
+	add("template_id", before.TemplateID, after.TemplateID)
+	if !annotationsEqual(before.TemplateVars, after.TemplateVars) {
+		changes = append(changes, FieldChange{Field: "template_vars", Old: before.TemplateVars, New: after.TemplateVars})
+	}
+	add("precondition", before.Precondition, after.Precondition)
+	add("active_from", before.ActiveFrom, after.ActiveFrom)
+	add("active_until", before.ActiveUntil, after.ActiveUntil)
+	add("enabled", before.IsEnabled(), after.IsEnabled())
+	if !annotationsEqual(before.Annotations, after.Annotations) {
+		changes = append(changes, FieldChange{Field: "annotations", Old: before.Annotations, New: after.Annotations})
+	}
+	return changes
+}
+
+// labelConstraintsEqual checks whether two lists of constraints are the same
+// regardless of the order of the constraints and their values.
+func labelConstraintsEqual(a, b []LabelConstraint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(cs []LabelConstraint) []string {
+		res := make([]string, 0, len(cs))
+		for _, c := range cs {
+			values := append([]string(nil), c.Values...)
+			sort.Strings(values)
+			data, _ := json.Marshal(LabelConstraint{Key: c.Key, Op: c.Op, Values: values})
+			res = append(res, string(data))
+		}
+		sort.Strings(res)
+		return res
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// labelConstraintAlternativesEqual checks whether two lists of alternative
+// constraint sets are the same in order, the order of the sets is kept since
+// it's how they are shown to users.
+func labelConstraintAlternativesEqual(a, b [][]LabelConstraint) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !labelConstraintsEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// annotationsEqual checks whether two annotations are the same, nil and empty
+// ones are equal.
+func annotationsEqual(a, b map[string]string) bool {
+	if len(a) == 0 && len(b) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func stringSetEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
+
+// RuleGroupChange is a rule group modified in place.
+type RuleGroupChange struct {
+	Old     *RuleGroup    `json:"old"`
+	New     *RuleGroup    `json:"new"`
+	Changes []FieldChange `json:"changes"`
+}
+
+// RuleGroupDiff is the difference between two sets of rule groups, all the
+// slices are sorted by the group ID.
+type RuleGroupDiff struct {
+	Added    []*RuleGroup       `json:"added"`
+	Removed  []*RuleGroup       `json:"removed"`
+	Modified []*RuleGroupChange `json:"modified"`
+}
+
+// DiffRuleGroups compares two sets of rule groups.
+func DiffRuleGroups(before, after []*RuleGroup) RuleGroupDiff {
+	var diff RuleGroupDiff
+	oldGroups := make(map[string]*RuleGroup, len(before))
+	for _, g := range before {
+		oldGroups[g.ID] = g
+	}
+	newGroups := make(map[string]*RuleGroup, len(after))
+	for _, g := range after {
+		newGroups[g.ID] = g
+		o, ok := oldGroups[g.ID]
+		if !ok {
+			diff.Added = append(diff.Added, g)
+			continue
+		}
+		var changes []FieldChange
+		if o.Index != g.Index {
+			changes = append(changes, FieldChange{Field: "index", Old: o.Index, New: g.Index})
+		}
+		if o.Override != g.Override {
+			changes = append(changes, FieldChange{Field: "override", Old: o.Override, New: g.Override})
+		}
+		if o.ParentID != g.ParentID {
+			changes = append(changes, FieldChange{Field: "parent_id", Old: o.ParentID, New: g.ParentID})
+		}
+		if !reflect.DeepEqual(o.LabelConstraints, g.LabelConstraints) {
+			changes = append(changes, FieldChange{Field: "label_constraints", Old: o.LabelConstraints, New: g.LabelConstraints})
+		}
+		if !annotationsEqual(o.Annotations, g.Annotations) {
+			changes = append(changes, FieldChange{Field: "annotations", Old: o.Annotations, New: g.Annotations})
+		}
+		if len(changes) > 0 {
+			diff.Modified = append(diff.Modified, &RuleGroupChange{Old: o, New: g, Changes: changes})
+		}
+	}
+	for _, g := range before {
+		if _, ok := newGroups[g.ID]; !ok {
+			diff.Removed = append(diff.Removed, g)
+		}
+	}
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].ID < diff.Added[j].ID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].ID < diff.Removed[j].ID })
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].New.ID < diff.Modified[j].New.ID })
+	return diff
+}
+
+// LabelRuleChange is a label rule modified in place.
+type LabelRuleChange struct {
+	Old     *labeler.LabelRule `json:"old"`
+	New     *labeler.LabelRule `json:"new"`
+	Changes []FieldChange      `json:"changes"`
+}
+
+// LabelRuleDiff is the difference between two sets of label rules, all the
+// slices are sorted by the rule ID.
+type LabelRuleDiff struct {
+	Added    []*labeler.LabelRule `json:"added"`
+	Removed  []*labeler.LabelRule `json:"removed"`
+	Modified []*LabelRuleChange   `json:"modified"`
+}
+
+// DiffLabelRules compares two sets of region label rules. The labels are
+// compared regardless of their order, and the runtime field StartAt of the
+// labels is ignored.
+func DiffLabelRules(before, after []*labeler.LabelRule) LabelRuleDiff {
+	var diff LabelRuleDiff
+	oldRules := make(map[string]*labeler.LabelRule, len(before))
+	for _, r := range before {
+		oldRules[r.ID] = r
+	}
+	newRules := make(map[string]*labeler.LabelRule, len(after))
+	for _, r := range after {
+		newRules[r.ID] = r
+		o, ok := oldRules[r.ID]
+		if !ok {
+			diff.Added = append(diff.Added, r)
+			continue
+		}
+		if changes := diffLabelRule(o, r); len(changes) > 0 {
+			diff.Modified = append(diff.Modified, &LabelRuleChange{Old: o, New: r, Changes: changes})
+		}
+	}
+	for _, r := range before {
+		if _, ok := newRules[r.ID]; !ok {
+			diff.Removed = append(diff.Removed, r)
+		}
+	}
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i].ID < diff.Added[j].ID })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i].ID < diff.Removed[j].ID })
+	sort.Slice(diff.Modified, func(i, j int) bool { return diff.Modified[i].New.ID < diff.Modified[j].New.ID })
+	return diff
+}
+
+func diffLabelRule(before, after *labeler.LabelRule) []FieldChange {
+	var changes []FieldChange
+	if before.Index != after.Index {
+		changes = append(changes, FieldChange{Field: "index", Old: before.Index, New: after.Index})
+	}
+	if !regionLabelsEqual(before.Labels, after.Labels) {
+		changes = append(changes, FieldChange{Field: "labels", Old: before.Labels, New: after.Labels})
+	}
+	if before.RuleType != after.RuleType {
+		changes = append(changes, FieldChange{Field: "rule_type", Old: before.RuleType, New: after.RuleType})
+	}
+	// the data may be either adjusted or raw JSON, compare them in JSON.
+	if !jsonValueEqual(before.Data, after.Data) {
+		changes = append(changes, FieldChange{Field: "data", Old: before.Data, New: after.Data})
+	}
+	if before.TTL != after.TTL {
+		changes = append(changes, FieldChange{Field: "ttl", Old: before.TTL, New: after.TTL})
+	}
+	return changes
+}
+
+func regionLabelsEqual(a, b []labeler.RegionLabel) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	normalize := func(labels []labeler.RegionLabel) []string {
+		res := make([]string, 0, len(labels))
+		for _, l := range labels {
+			res = append(res, l.Key+"="+l.Value+"/"+l.TTL)
+		}
+		sort.Strings(res)
+		return res
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func jsonValueEqual(a, b interface{}) bool {
+	var va, vb interface{}
+	da, _ := json.Marshal(a)
+	db, _ := json.Marshal(b)
+	if json.Unmarshal(da, &va) != nil || json.Unmarshal(db, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
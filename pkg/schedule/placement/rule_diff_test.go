@@ -0,0 +1,109 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/schedule/labeler"
+)
+
+func TestDiffRules(t *testing.T) {
+	re := require.New(t)
+	before := []*Rule{
+		{GroupID: "pd", ID: "default", Role: Voter, Count: 3, LocationLabels: []string{"zone", "host"}},
+		{GroupID: "a", ID: "1", Role: Voter, Count: 1, LabelConstraints: []LabelConstraint{
+			{Key: "zone", Op: In, Values: []string{"z1", "z2"}},
+			{Key: "engine", Op: NotIn, Values: []string{"tiflash"}},
+		}},
+		{GroupID: "a", ID: "2", Role: Learner, Count: 1},
+	}
+	after := []*Rule{
+		// reordered location labels, and the runtime fields are changed.
+		{GroupID: "pd", ID: "default", Role: Voter, Count: 3, LocationLabels: []string{"host", "zone"}, Version: 2, CreateTimestamp: 1},
+		// reordered label constraints, and the count is changed.
+		{GroupID: "a", ID: "1", Role: Voter, Count: 2, LabelConstraints: []LabelConstraint{
+			{Key: "engine", Op: NotIn, Values: []string{"tiflash"}},
+			{Key: "zone", Op: In, Values: []string{"z2", "z1"}},
+		}},
+		{GroupID: "b", ID: "1", Role: Follower, Count: 1},
+	}
+	diff := DiffRules(before, after)
+	re.Len(diff.Added, 1)
+	re.Equal([2]string{"b", "1"}, diff.Added[0].Key())
+	re.Len(diff.Removed, 1)
+	re.Equal([2]string{"a", "2"}, diff.Removed[0].Key())
+	re.Len(diff.Modified, 1)
+	re.Equal([2]string{"a", "1"}, diff.Modified[0].New.Key())
+	re.Equal([]FieldChange{{Field: "count", Old: 1, New: 2}}, diff.Modified[0].Changes)
+
+	// the label constraints with different values are reported.
+	after[1].LabelConstraints[1].Values = []string{"z1"}
+	diff = DiffRules(before, after)
+	re.Len(diff.Modified, 1)
+	re.Len(diff.Modified[0].Changes, 2)
+	re.Equal("label_constraints", diff.Modified[0].Changes[1].Field)
+
+	// no difference with itself.
+	diff = DiffRules(after, after)
+	re.Empty(diff.Added)
+	re.Empty(diff.Removed)
+	re.Empty(diff.Modified)
+}
+
+func TestDiffRuleGroups(t *testing.T) {
+	re := require.New(t)
+	before := []*RuleGroup{{ID: "pd"}, {ID: "a", Index: 1}, {ID: "b"}}
+	after := []*RuleGroup{{ID: "pd"}, {ID: "a", Index: 2, Override: true}, {ID: "c"}}
+	diff := DiffRuleGroups(before, after)
+	re.Equal([]*RuleGroup{{ID: "c"}}, diff.Added)
+	re.Equal([]*RuleGroup{{ID: "b"}}, diff.Removed)
+	re.Len(diff.Modified, 1)
+	re.Equal([]FieldChange{
+		{Field: "index", Old: 1, New: 2},
+		{Field: "override", Old: false, New: true},
+	}, diff.Modified[0].Changes)
+}
+
+func TestDiffLabelRules(t *testing.T) {
+	re := require.New(t)
+	newLabelRule := func(id string, labels []labeler.RegionLabel, data string) *labeler.LabelRule {
+		rule := &labeler.LabelRule{ID: id, Labels: labels, RuleType: labeler.KeyRange}
+		re.NoError(json.Unmarshal([]byte(data), &rule.Data))
+		return rule
+	}
+	before := []*labeler.LabelRule{
+		newLabelRule("r1", []labeler.RegionLabel{{Key: "k1", Value: "v1"}, {Key: "k2", Value: "v2"}}, `[{"start_key":"12","end_key":"34"}]`),
+		newLabelRule("r2", []labeler.RegionLabel{{Key: "k1", Value: "v1"}}, `[{"start_key":"12","end_key":"34"}]`),
+		newLabelRule("r3", []labeler.RegionLabel{{Key: "k1", Value: "v1"}}, `[{"start_key":"12","end_key":"34"}]`),
+	}
+	after := []*labeler.LabelRule{
+		// reordered labels.
+		newLabelRule("r1", []labeler.RegionLabel{{Key: "k2", Value: "v2"}, {Key: "k1", Value: "v1"}}, `[{"end_key":"34","start_key":"12"}]`),
+		newLabelRule("r2", []labeler.RegionLabel{{Key: "k1", Value: "v1"}}, `[{"start_key":"12","end_key":"56"}]`),
+		newLabelRule("r4", []labeler.RegionLabel{{Key: "k1", Value: "v1"}}, `[{"start_key":"12","end_key":"34"}]`),
+	}
+	diff := DiffLabelRules(before, after)
+	re.Len(diff.Added, 1)
+	re.Equal("r4", diff.Added[0].ID)
+	re.Len(diff.Removed, 1)
+	re.Equal("r3", diff.Removed[0].ID)
+	re.Len(diff.Modified, 1)
+	re.Equal("r2", diff.Modified[0].New.ID)
+	re.Len(diff.Modified[0].Changes, 1)
+	re.Equal("data", diff.Modified[0].Changes[0].Field)
+}
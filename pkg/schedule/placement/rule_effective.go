@@ -0,0 +1,112 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/hex"
+)
+
+// The reasons why a rule covering a range is not applied to it.
+const (
+	// SuppressedByOverride means the rule is overridden by a rule with a larger
+	// index in the same group, or a rule of an override group.
+	SuppressedByOverride = "override"
+	// SuppressedByInactive means the precondition of the rule is not met or the
+	// rule is out of its active window.
+	SuppressedByInactive = "inactive"
+	// SuppressedByDisabled means the rule is disabled.
+	SuppressedByDisabled = "disabled"
+	// SuppressedByFallback means the rules are replaced by the default rule
+	// since some of them can not match any store.
+	SuppressedByFallback = "fallback"
+)
+
+// SuppressedRule is a rule covering a range but not applied to it.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type SuppressedRule struct {
+	Rule   *Rule  `json:"rule"`
+	Reason string `json:"reason"`
+	// OverriddenBy is the rule overriding it in the format of "group_id/id",
+	// it's only set if the reason is SuppressedByOverride.
+	OverriddenBy string `json:"overridden_by,omitempty"`
+}
+
+// EffectiveRange is the rules applied to a key range after the overrides are
+// resolved, and the rules suppressed in the range.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type EffectiveRange struct {
+	StartKeyHex string `json:"start_key"`
+	EndKeyHex   string `json:"end_key"`
+	// Rules are applied to the regions in the range in the apply order.
+	Rules      []*Rule           `json:"rules"`
+	Suppressed []*SuppressedRule `json:"suppressed,omitempty"`
+}
+
+// EffectiveRules returns the compiled view of the rules, i.e., the rules applied
+// to each key range as seen by fit, in the order of the ranges. The rules are
+// the effective ones, the label constraints inherited from the groups are
+// included. The rules scoped to the keyspaces are not filtered, since it
+// depends on the regions.
+func (m *RuleManager) EffectiveRules() []*EffectiveRange {
+	m.RLock()
+	defer m.RUnlock()
+	res := make([]*EffectiveRange, 0, len(m.ruleList.ranges))
+	for i, rr := range m.ruleList.ranges {
+		var endKey []byte
+		if i < len(m.ruleList.ranges)-1 {
+			endKey = m.ruleList.ranges[i+1].startKey
+		}
+		applied := rr.activeApplyRules(m.inactiveRules)
+		rules := m.fallbackUnsatisfiableRules(applied)
+		er := &EffectiveRange{
+			StartKeyHex: hex.EncodeToString(rr.startKey),
+			EndKeyHex:   hex.EncodeToString(endKey),
+			Rules:       make([]*Rule, 0, len(rules)),
+		}
+		for _, r := range rules {
+			er.Rules = append(er.Rules, r.Clone())
+		}
+		for _, r := range rr.rules {
+			if containsRule(rules, r) {
+				continue
+			}
+			s := &SuppressedRule{Rule: r.Clone()}
+			switch {
+			case !r.IsEnabled():
+				s.Reason = SuppressedByDisabled
+			case !containsRule(applied, r) && m.isInactive(r):
+				s.Reason = SuppressedByInactive
+			case !containsRule(applied, r):
+				s.Reason = SuppressedByOverride
+				for _, o := range applied {
+					if overridesRule(o, r) {
+						s.OverriddenBy = o.GroupID + "/" + o.ID
+						break
+					}
+				}
+			default:
+				s.Reason = SuppressedByFallback
+			}
+			er.Suppressed = append(er.Suppressed, s)
+		}
+		res = append(res, er)
+	}
+	return res
+}
+
+func (m *RuleManager) isInactive(r *Rule) bool {
+	_, ok := m.inactiveRules[r.Key()]
+	return ok
+}
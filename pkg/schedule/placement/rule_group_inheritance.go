@@ -0,0 +1,159 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// inheritLabelConstraints sets up the label constraints inherited by the rules
+// from their groups and the ancestors of the groups, and the defaults of the
+// groups which are not overridden by the rules.
+func inheritLabelConstraints(iterateRules func(func(*Rule)), getGroup func(string) *RuleGroup) {
+	inherited := make(map[string]*groupInheritance)
+	iterateRules(func(r *Rule) {
+		gi, ok := inherited[r.GroupID]
+		if !ok {
+			gi = newGroupInheritance(getGroup, r.GroupID)
+			inherited[r.GroupID] = gi
+		}
+		gi.apply(r)
+	})
+}
+
+// groupInheritance is what the rules of a group inherit from the group and its
+// ancestors.
+type groupInheritance struct {
+	// constraints are the label constraints of the group and its ancestors,
+	// the ones of the ancestors come first.
+	constraints []LabelConstraint
+	// defaultLocationLabels and defaultConstraints are the defaults of the
+	// nearest groups.
+	defaultLocationLabels []string
+	defaultConstraints    []LabelConstraint
+}
+
+// newGroupInheritance collects the inheritance of the group. The cycles are
+// rejected by checkGroupParents, they are only guarded against here.
+func newGroupInheritance(getGroup func(string) *RuleGroup, id string) *groupInheritance {
+	var chain []*RuleGroup
+	visited := make(map[string]struct{})
+	for id != "" {
+		if _, ok := visited[id]; ok {
+			break
+		}
+		visited[id] = struct{}{}
+		g := getGroup(id)
+		chain = append(chain, g)
+		id = g.ParentID
+	}
+	gi := &groupInheritance{}
+	defaultKeys := make(map[string]struct{})
+	for i := len(chain) - 1; i >= 0; i-- {
+		gi.constraints = append(gi.constraints, chain[i].LabelConstraints...)
+	}
+	for _, g := range chain {
+		if len(gi.defaultLocationLabels) == 0 {
+			gi.defaultLocationLabels = g.DefaultLocationLabels
+		}
+		for _, c := range g.DefaultLabelConstraints {
+			if _, ok := defaultKeys[c.Key]; !ok {
+				defaultKeys[c.Key] = struct{}{}
+				gi.defaultConstraints = append(gi.defaultConstraints, c)
+			}
+		}
+	}
+	return gi
+}
+
+// apply sets up the inheritance of the rule, the defaults are skipped if the
+// rule overrides them.
+func (gi *groupInheritance) apply(r *Rule) {
+	r.inherited = gi.constraints
+	if len(gi.defaultConstraints) > 0 {
+		keys := make(map[string]struct{}, len(r.LabelConstraints))
+		for _, c := range r.LabelConstraints {
+			keys[c.Key] = struct{}{}
+		}
+		r.inherited = make([]LabelConstraint, 0, len(gi.constraints)+len(gi.defaultConstraints))
+		r.inherited = append(r.inherited, gi.constraints...)
+		for _, c := range gi.defaultConstraints {
+			if _, ok := keys[c.Key]; !ok {
+				r.inherited = append(r.inherited, c)
+			}
+		}
+	}
+	r.inheritedLocationLabels = nil
+	if len(r.LocationLabels) == 0 {
+		r.inheritedLocationLabels = gi.defaultLocationLabels
+	}
+}
+
+// checkGroupParents rejects the patch if any changed group has invalid label
+// constraints or a cycle in its parent chain.
+func checkGroupParents(p *ruleConfigPatch) error {
+	for id, g := range p.mut.groups {
+		for _, c := range g.LabelConstraints {
+			if !validateOp(c.Op) {
+				return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s of rule group %s", c.Op, id))
+			}
+		}
+		if err := checkGroupDefaults(g); err != nil {
+			return err
+		}
+		visited := make(map[string]struct{})
+		for cur := id; cur != ""; cur = p.getGroup(cur).ParentID {
+			if _, ok := visited[cur]; ok {
+				return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("cycle in the parent chain of rule group %s", id))
+			}
+			visited[cur] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// checkGroupDefaults rejects the defaults of the group which conflict with the
+// override semantics, i.e., the default label constraints of the same key, and
+// the ones of the keys constrained by the group, which could never be
+// overridden by the rules.
+func checkGroupDefaults(g *RuleGroup) error {
+	labels := make(map[string]struct{}, len(g.DefaultLocationLabels))
+	for _, l := range g.DefaultLocationLabels {
+		if _, ok := labels[l]; ok || l == "" {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid default location label %q of rule group %s", l, g.ID))
+		}
+		labels[l] = struct{}{}
+	}
+	constrained := make(map[string]struct{}, len(g.LabelConstraints))
+	for _, c := range g.LabelConstraints {
+		constrained[c.Key] = struct{}{}
+	}
+	keys := make(map[string]struct{}, len(g.DefaultLabelConstraints))
+	for _, c := range g.DefaultLabelConstraints {
+		if !validateOp(c.Op) {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s of rule group %s", c.Op, g.ID))
+		}
+		if _, ok := keys[c.Key]; ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("duplicated default label constraints of key %s in rule group %s", c.Key, g.ID))
+		}
+		if _, ok := constrained[c.Key]; ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("the default label constraint of key %s conflicts with the label constraints of rule group %s", c.Key, g.ID))
+		}
+		keys[c.Key] = struct{}{}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigHash returns a stable hash of all rules and rule groups, which can be
+// compared to tell whether the placement configuration is changed. The hash
+// only depends on the semantics of the configuration, so it's the same for
+// the same configuration regardless of the order in which the rules and
+// groups are set, or across clusters:
+//   - the rules are sorted by the keys, and the keys are in lower case hex,
+//   - the runtime fields of the rules, such as the version and revision, are
+//     ignored,
+//   - the label constraints and their values are sorted since they are sets,
+//   - the groups with the default configuration are ignored,
+//   - the annotations of the rules and groups are ignored.
+func (m *RuleManager) ConfigHash() string {
+	m.RLock()
+	rules := make([]*Rule, 0, len(m.ruleConfig.rules))
+	for _, r := range m.ruleConfig.rules {
+		rules = append(rules, canonicalRule(r))
+	}
+	groups := make([]*RuleGroup, 0, len(m.ruleConfig.groups))
+	for id, g := range m.ruleConfig.groups {
+		c := &RuleGroup{
+			ID:               id,
+			Index:            g.Index,
+			Override:         g.Override,
+			Frozen:           g.Frozen,
+			ParentID:         g.ParentID,
+			LabelConstraints: canonicalLabelConstraints(g.LabelConstraints),
+			// the default location labels are ordered by the topology.
+			DefaultLocationLabels:   g.DefaultLocationLabels,
+			DefaultLabelConstraints: canonicalLabelConstraints(g.DefaultLabelConstraints),
+		}
+		// the group only with the annotations is the same as the default one.
+		if c.isDefault() {
+			continue
+		}
+		groups = append(groups, c)
+	}
+	m.RUnlock()
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i].Key(), rules[j].Key()
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+	sort.Slice(groups, func(i, j int) bool { return groups[i].ID < groups[j].ID })
+	// the struct fields are marshaled in the order of declaration, so the
+	// JSON encoding is deterministic.
+	data, _ := json.Marshal(struct {
+		Groups []*RuleGroup `json:"groups"`
+		Rules  []*Rule      `json:"rules"`
+	}{groups, rules})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalRule returns a copy of the rule which only keeps the fields taking
+// part in the placement, in the canonical form.
+func canonicalRule(r *Rule) *Rule {
+	c := &Rule{
+		GroupID:          r.GroupID,
+		ID:               r.ID,
+		Index:            r.Index,
+		Override:         r.Override,
+		StartKeyHex:      hex.EncodeToString(r.StartKey),
+		EndKeyHex:        hex.EncodeToString(r.EndKey),
+		Role:             r.Role,
+		IsWitness:        r.IsWitness,
+		Count:            r.Count,
+		CountExpr:        r.CountExpr,
+		Engine:           r.Engine,
+		LabelConstraints: canonicalLabelConstraints(r.LabelConstraints),
+		LocationLabels:   r.LocationLabels,
+		IsolationLevel:   r.IsolationLevel,
+		KeyspaceID:       r.KeyspaceID,
+		TemplateID:       r.TemplateID,
+		TemplateVars:     r.TemplateVars,
+		ActiveFrom:       canonicalTime(r.ActiveFrom),
+		ActiveUntil:      canonicalTime(r.ActiveUntil),
+	}
+	// the rule is enabled if it's not set.
+	if !r.IsEnabled() {
+		c.Enabled = r.Enabled
+	}
+	for _, alt := range r.LabelConstraintAlternatives {
+		c.LabelConstraintAlternatives = append(c.LabelConstraintAlternatives, canonicalLabelConstraints(alt))
+	}
+	if p := r.Precondition; p != nil {
+		c.Precondition = &RulePrecondition{
+			LabelConstraints: canonicalLabelConstraints(p.LabelConstraints),
+			MinStores:        p.MinStores,
+			LocationLabel:    p.LocationLabel,
+			MinLocations:     p.MinLocations,
+		}
+	}
+	return c
+}
+
+func canonicalTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func canonicalLabelConstraints(constraints []LabelConstraint) []LabelConstraint {
+	if len(constraints) == 0 {
+		return nil
+	}
+	res := make([]LabelConstraint, 0, len(constraints))
+	for _, c := range constraints {
+		values := append([]string(nil), c.Values...)
+		sort.Strings(values)
+		res = append(res, LabelConstraint{Key: c.Key, Op: c.Op, Values: values})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Key != res[j].Key {
+			return res[i].Key < res[j].Key
+		}
+		if res[i].Op != res[j].Op {
+			return res[i].Op < res[j].Op
+		}
+		return strings.Join(res[i].Values, ",") < strings.Join(res[j].Values, ",")
+	})
+	return res
+}
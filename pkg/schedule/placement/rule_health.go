@@ -0,0 +1,109 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+)
+
+// RuleHealth is the summary of the health of the placement rules.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleHealth struct {
+	TotalRules int `json:"total_rules"`
+	// ActiveRules are the rules applied by fit, i.e., the ones neither
+	// disabled nor inactive.
+	ActiveRules int `json:"active_rules"`
+	// DisabledRules are the rules disabled explicitly.
+	DisabledRules int `json:"disabled_rules"`
+	// InactiveRules are the enabled rules whose preconditions are not met or
+	// which are out of their active windows.
+	InactiveRules int `json:"inactive_rules"`
+	TotalGroups   int `json:"total_groups"`
+	// Conflicts is the number of the rule pairs which can not be satisfied at
+	// the same time, see CheckConflicts.
+	Conflicts int `json:"conflicts"`
+	// UnusedRules is the number of the rules covering no region for longer
+	// than the grace period, see FindUnusedRules.
+	UnusedRules int `json:"unused_rules"`
+	// UnschedulableRegions is the number of the regions which can not satisfy
+	// the rules since there are not enough matching stores. Only the regions
+	// with the cached fits are counted.
+	UnschedulableRegions int    `json:"unschedulable_regions"`
+	ConfigHash           string `json:"config_hash"`
+}
+
+// HealthSummary returns the summary of the health of the rules in one call.
+// It's cheap enough to be polled frequently: the unused rules are the ones
+// found by the last FindUnusedRules, and the unschedulable regions are counted
+// with the cached fits rather than fitting all the regions again.
+func (m *RuleManager) HealthSummary() RuleHealth {
+	health := RuleHealth{
+		Conflicts:  len(m.CheckConflicts()),
+		ConfigHash: m.ConfigHash(),
+	}
+	stores := m.getAliveStores()
+	m.RLock()
+	defer m.RUnlock()
+	health.TotalRules = len(m.ruleConfig.rules)
+	for key, r := range m.ruleConfig.rules {
+		switch {
+		case !r.IsEnabled():
+			health.DisabledRules++
+		case m.isInactive(r):
+			health.InactiveRules++
+		}
+		if u, ok := m.unusedRules[key]; ok && time.Since(u.since) >= unusedRuleGracePeriod {
+			health.UnusedRules++
+		}
+	}
+	health.ActiveRules = health.TotalRules - health.DisabledRules - health.InactiveRules
+	for _, g := range m.ruleConfig.groups {
+		if !g.isDefault() {
+			health.TotalGroups++
+		}
+	}
+	health.UnschedulableRegions = countUnschedulableFits(m.cache.cachedFits(m.ruleSetVersion), stores)
+	return health
+}
+
+// countUnschedulableFits counts the fits having any rule which has not enough
+// matching stores. The matching stores of each rule are only counted once.
+func countUnschedulableFits(fits []*RegionFit, stores []*core.StoreInfo) int {
+	matched := make(map[*Rule]int)
+	unschedulable := func(r *Rule) bool {
+		n, ok := matched[r]
+		if !ok {
+			for _, s := range stores {
+				if r.MatchStore(s) {
+					n++
+				}
+			}
+			matched[r] = n
+		}
+		return n < r.Count
+	}
+	count := 0
+	for _, fit := range fits {
+		for _, rf := range fit.RuleFits {
+			if !rf.IsSatisfied() && unschedulable(rf.Rule) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
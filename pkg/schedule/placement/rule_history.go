@@ -0,0 +1,206 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"go.uber.org/zap"
+)
+
+// ruleHistoryLimit is the max number of the history entries kept in the
+// storage, the oldest entry is discarded once a new one is saved.
+const ruleHistoryLimit = 1000
+
+// historyTxnOps is the number of the operations saving a history entry, which
+// are saved in the same transaction as the last changes of the mutation.
+const historyTxnOps = 2
+
+// RuleChange is a change of a rule or rule group in a history entry.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleChange struct {
+	Operation string `json:"operation"`
+	GroupID   string `json:"group_id"`
+	// ID is the ID of the rule, it's empty for the rule groups.
+	ID string `json:"id,omitempty"`
+	// Before and After are the JSON values before and after the change, they
+	// are null if the rule or rule group doesn't exist.
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// RuleHistoryEntry is a persisted mutation of the rules and rule groups, whose
+// revision is increased by every mutation.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleHistoryEntry struct {
+	Revision uint64        `json:"revision"`
+	Time     time.Time     `json:"time"`
+	Changes  []*RuleChange `json:"changes"`
+}
+
+// newHistoryEntry returns the history entry of the mutation audited by the
+// entries, it's nil if nothing is changed.
+func (m *RuleManager) newHistoryEntry(entries []*RuleAuditEntry) *RuleHistoryEntry {
+	if len(entries) == 0 {
+		return nil
+	}
+	h := &RuleHistoryEntry{Revision: m.historyRevision + 1, Time: time.Now()}
+	for _, e := range entries {
+		h.Changes = append(h.Changes, &RuleChange{
+			Operation: e.Operation,
+			GroupID:   e.GroupID,
+			ID:        e.ID,
+			Before:    e.Before,
+			After:     e.After,
+		})
+	}
+	return h
+}
+
+// historyOps returns the operations saving the history entry and discarding
+// the oldest one beyond the limit.
+func (m *RuleManager) historyOps(h *RuleHistoryEntry) []func(txn kv.Txn) error {
+	if h == nil {
+		return nil
+	}
+	ops := []func(txn kv.Txn) error{
+		func(txn kv.Txn) error { return m.storage.SaveRuleHistory(txn, h.Revision, h) },
+	}
+	if h.Revision > ruleHistoryLimit {
+		ops = append(ops, func(txn kv.Txn) error { return m.storage.DeleteRuleHistory(txn, h.Revision-ruleHistoryLimit) })
+	}
+	return ops
+}
+
+// loadHistoryRevision loads the revision of the latest history entry.
+func (m *RuleManager) loadHistoryRevision() error {
+	return m.storage.LoadRuleHistory(func(k, _ string) {
+		revision, err := strconv.ParseUint(k, 10, 64)
+		if err != nil {
+			log.Warn("invalid rule history key", zap.String("key", k))
+			return
+		}
+		if revision > m.historyRevision {
+			m.historyRevision = revision
+		}
+	})
+}
+
+// GetRuleHistory returns the history entries in the order of the revisions.
+func (m *RuleManager) GetRuleHistory() ([]*RuleHistoryEntry, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.loadHistory()
+}
+
+func (m *RuleManager) loadHistory() ([]*RuleHistoryEntry, error) {
+	var (
+		entries []*RuleHistoryEntry
+		err     error
+	)
+	if loadErr := m.storage.LoadRuleHistory(func(k, v string) {
+		h := &RuleHistoryEntry{}
+		if e := json.Unmarshal([]byte(v), h); e != nil {
+			err = errs.ErrJSONUnmarshal.Wrap(e).GenWithStackByCause()
+			return
+		}
+		entries = append(entries, h)
+	}); loadErr != nil {
+		return nil, loadErr
+	}
+	return entries, err
+}
+
+// RollbackToRevision reverts the rules and rule groups to the state right
+// after the mutation of the revision, by undoing the later mutations in the
+// history. The rollback is a mutation itself, so it's recorded with a new
+// revision and can be rolled back too. It fails if the revision is not in the
+// history, e.g., it has been discarded.
+func (m *RuleManager) RollbackToRevision(revision uint64) error {
+	m.Lock()
+	defer m.Unlock()
+	entries, err := m.loadHistory()
+	if err != nil {
+		return err
+	}
+	if revision > m.historyRevision || len(entries) == 0 || revision < entries[0].Revision {
+		return errs.ErrRuleRevision.FastGenByArgs(revision)
+	}
+	// the values before the first later change of each rule and group are the
+	// ones at the revision.
+	rules := make(map[[2]string]json.RawMessage)
+	groups := make(map[string]json.RawMessage)
+	for _, h := range entries {
+		if h.Revision <= revision {
+			continue
+		}
+		for _, c := range h.Changes {
+			switch c.Operation {
+			case AuditSetRule, AuditDeleteRule:
+				if _, ok := rules[[2]string{c.GroupID, c.ID}]; !ok {
+					rules[[2]string{c.GroupID, c.ID}] = c.Before
+				}
+			default:
+				if _, ok := groups[c.GroupID]; !ok {
+					groups[c.GroupID] = c.Before
+				}
+			}
+		}
+	}
+	p := m.beginPatch()
+	for key, before := range rules {
+		if isNullValue(before) {
+			p.deleteRule(key[0], key[1])
+			continue
+		}
+		r, err := NewRuleFromJSON(before)
+		if err != nil {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule %s in group %s in the history: %v", key[1], key[0], err))
+		}
+		// the stores may be changed since then, so check it again.
+		if err := m.adjustRule(r, ""); err != nil {
+			return err
+		}
+		p.setRule(r)
+	}
+	for id, before := range groups {
+		if isNullValue(before) {
+			p.deleteGroup(id)
+			continue
+		}
+		g, err := NewRuleGroupFromJSON(before)
+		if err != nil {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule group %s in the history: %v", id, err))
+		}
+		p.setGroup(g)
+	}
+	p.changeFrozen = true
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("placement rules rolled back", zap.Uint64("revision", revision), zap.Uint64("history-revision", m.historyRevision))
+	return nil
+}
+
+func isNullValue(v json.RawMessage) bool {
+	return len(v) == 0 || bytes.Equal(v, []byte("null"))
+}
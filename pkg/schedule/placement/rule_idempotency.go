@@ -0,0 +1,90 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"go.uber.org/zap"
+)
+
+// idempotencyKeyTTL is how long an idempotency key of SetRule is remembered.
+var idempotencyKeyTTL = 10 * time.Minute
+
+// idempotencyKeys records the recently used idempotency keys with the rules
+// set by them.
+type idempotencyKeys struct {
+	// mu serializes the requests with the keys, so the concurrent retries are
+	// applied only once.
+	mu   syncutil.Mutex
+	keys map[string]*idempotentRequest
+}
+
+type idempotentRequest struct {
+	payload  string
+	expireAt time.Time
+}
+
+func newIdempotencyKeys() *idempotencyKeys {
+	return &idempotencyKeys{keys: make(map[string]*idempotentRequest)}
+}
+
+// gcLocked removes the expired keys, the caller should hold the lock.
+func (k *idempotencyKeys) gcLocked(now time.Time) {
+	for key, r := range k.keys {
+		if !r.expireAt.After(now) {
+			delete(k.keys, key)
+		}
+	}
+}
+
+// SetRuleWithIdempotencyKey inserts or updates a Rule like SetRule, or
+// ForceSetRule if force is true, with an idempotency key to make the retries
+// safe. If the key has been used by a successful update recently, the update
+// is skipped if the rule is identical, or rejected otherwise. The failed
+// updates don't use up the key. An empty key means no idempotency.
+func (m *RuleManager) SetRuleWithIdempotencyKey(rule *Rule, key string, force bool) error {
+	if key == "" {
+		return m.setRule(rule, force)
+	}
+	// compare the rules as requested, since they are adjusted when being set.
+	data, err := json.Marshal(rule)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByCause()
+	}
+	payload := string(data)
+
+	k := m.idempotencyKeys
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := time.Now()
+	k.gcLocked(now)
+	if r, ok := k.keys[key]; ok {
+		if r.payload != payload {
+			return errs.ErrIdempotencyKeyReused.FastGenByArgs(key)
+		}
+		log.Info("placement rule update is skipped as a retry", zap.String("idempotency-key", key), zap.String("rule", payload))
+		return nil
+	}
+	if err := m.setRule(rule, force); err != nil {
+		return err
+	}
+	k.keys[key] = &idempotentRequest{payload: payload, expireAt: now.Add(idempotencyKeyTTL)}
+	return nil
+}
@@ -70,7 +70,7 @@ func buildRuleList(rules ruleContainer) (ruleList, error) {
 		return compareRule(a.(*Rule), b.(*Rule))
 	})
 	rules.iterateRules(func(r *Rule) {
-		builder.AddItem(r.StartKey, r.EndKey, r)
+		builder.AddItem(r.StartKey, r.EndKey, r.applied())
 	})
 	rangeList := builder.Build()
 
@@ -131,3 +131,37 @@ func (rl ruleList) getRulesForApplyRange(start, end []byte) []*Rule {
 	}
 	return rl.ranges[i].applyRules
 }
+
+// getActiveRulesForApplyRange is the same as getRulesForApplyRange, except
+// that the inactive rules are excluded before selecting the rules to apply, so
+// an inactive rule doesn't override the others. The rules are selected as if
+// all rules are active if no leader or voter is left.
+func (rl ruleList) getActiveRulesForApplyRange(start, end []byte, inactive map[[2]string]struct{}) []*Rule {
+	i, data := rl.rangeList.GetData(start, end)
+	if i < 0 || len(data) == 0 {
+		return nil
+	}
+	return rl.ranges[i].activeApplyRules(inactive)
+}
+
+// activeApplyRules returns the rules to apply to the range with the inactive
+// rules excluded.
+func (r rangeRules) activeApplyRules(inactive map[[2]string]struct{}) []*Rule {
+	if len(inactive) == 0 {
+		return r.applyRules
+	}
+	active := make([]*Rule, 0, len(r.rules))
+	for _, rule := range r.rules {
+		if _, ok := inactive[rule.Key()]; !ok {
+			active = append(active, rule)
+		}
+	}
+	if len(active) == len(r.rules) {
+		return r.applyRules
+	}
+	applyRules := prepareRulesForApply(active)
+	if checkApplyRules(applyRules) != nil {
+		return r.applyRules
+	}
+	return applyRules
+}
@@ -15,13 +15,14 @@
 package placement
 
 import (
-	"bytes"
-	"encoding/hex"
+	"context"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/pingcap/log"
@@ -32,11 +33,16 @@ import (
 	"github.com/tikv/pd/pkg/schedule/config"
 	"github.com/tikv/pd/pkg/slice"
 	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"github.com/tikv/pd/pkg/utils/syncutil"
 	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
 )
 
+// maxEtcdTxnOps is the max number of operations in an etcd transaction. The
+// default limit of etcd is 128, we use 120 here to leave some space.
+const maxEtcdTxnOps = 120
+
 // RuleManager is responsible for the lifecycle of all placement Rules.
 // It is thread safe.
 type RuleManager struct {
@@ -51,6 +57,39 @@ type RuleManager struct {
 	storeSetInformer core.StoreSetInformer
 	cache            *RegionRuleFitCacheManager
 	conf             config.SharedConfigProvider
+	// ruleSetVersion is increased once the rules applied to the regions may be
+	// changed, it's used to invalidate the region fit cache.
+	ruleSetVersion uint64
+	// revision is the max revision ever stamped on the rules. Every rule to
+	// be saved is stamped with a new revision, so the watchers of the storage
+	// can drop the stale updates and find out the missing ones. It's saved
+	// along with the rules, so it never goes back after the rules with the
+	// max revision are deleted.
+	revision uint64
+	// historyRevision is the revision of the latest rule history entry, it's
+	// increased by every mutation of the rules and rule groups.
+	historyRevision uint64
+
+	// unsatisfiableRules records the rules which can not match any store at
+	// the last check, it is refreshed by CheckUnsatisfiableRules.
+	unsatisfiableRules map[[2]string]struct{}
+	// fitFailures counts the rules not satisfied by the regions by reasons.
+	fitFailures *fitFailureStats
+	// ruleUsage counts the regions matching and satisfying the rules.
+	ruleUsage *ruleUsage
+	// unusedRules records since when the rules cover no region, it is
+	// refreshed by FindUnusedRules.
+	unusedRules map[[2]string]*unusedRule
+	// observers are notified of the committed mutations.
+	observers []RuleObserver
+	// inactiveRules records the rules whose preconditions are not met, which
+	// are out of the active windows or disabled. They are ignored by fit. It's
+	// refreshed by CheckRulePreconditions.
+	inactiveRules map[[2]string]struct{}
+	// idempotencyKeys records the recent idempotency keys of SetRule.
+	idempotencyKeys *idempotencyKeys
+	// auditor emits the audit entries of the committed mutations.
+	auditor *ruleAuditor
 }
 
 // NewRuleManager creates a RuleManager instance.
@@ -61,6 +100,14 @@ func NewRuleManager(storage endpoint.RuleStorage, storeSetInformer core.StoreSet
 		conf:             conf,
 		ruleConfig:       newRuleConfig(),
 		cache:            NewRegionRuleFitCacheManager(),
+
+		unsatisfiableRules: make(map[[2]string]struct{}),
+		fitFailures:        newFitFailureStats(),
+		ruleUsage:          newRuleUsage(),
+		unusedRules:        make(map[[2]string]*unusedRule),
+		inactiveRules:      make(map[[2]string]struct{}),
+		idempotencyKeys:    newIdempotencyKeys(),
+		auditor:            newRuleAuditor(),
 	}
 }
 
@@ -76,9 +123,21 @@ func (m *RuleManager) Initialize(maxReplica int, locationLabels []string) error
 	if err := m.loadRules(); err != nil {
 		return err
 	}
+	if err := m.loadRevision(); err != nil {
+		return err
+	}
 	if err := m.loadGroups(); err != nil {
 		return err
 	}
+	if err := m.loadTemplates(); err != nil {
+		return err
+	}
+	if err := m.loadTombstones(); err != nil {
+		return err
+	}
+	if err := m.loadHistoryRevision(); err != nil {
+		return err
+	}
 	if len(m.ruleConfig.rules) == 0 {
 		// migrate from old config.
 		var defaultRules []*Rule
@@ -105,19 +164,21 @@ func (m *RuleManager) Initialize(maxReplica int, locationLabels []string) error
 				}...,
 			)
 		} else {
-			defaultRules = append(defaultRules, &Rule{
-				GroupID:        "pd",
-				ID:             "default",
-				Role:           Voter,
-				Count:          maxReplica,
-				LocationLabels: locationLabels,
-			})
+			defaultRules = append(defaultRules, newDefaultRule(maxReplica, locationLabels))
 		}
-		for _, defaultRule := range defaultRules {
-			if err := m.storage.SaveRule(defaultRule.StoreKey(), defaultRule); err != nil {
-				// TODO: Need to delete the previously successfully saved Rules?
-				return err
+		revision := m.stampRevisions(defaultRules)
+		if err := m.storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+			for _, defaultRule := range defaultRules {
+				if err := m.storage.SaveRule(txn, defaultRule.StoreKey(), defaultRule); err != nil {
+					return err
+				}
 			}
+			return m.storage.SaveRuleRevision(txn, revision)
+		}); err != nil {
+			return err
+		}
+		m.revision = revision
+		for _, defaultRule := range defaultRules {
 			m.ruleConfig.setRule(defaultRule)
 		}
 	}
@@ -127,6 +188,7 @@ func (m *RuleManager) Initialize(maxReplica int, locationLabels []string) error
 		return err
 	}
 	m.ruleList = ruleList
+	m.updateInactiveRules(m.ruleConfig, m.getAliveStores())
 	m.initialized = true
 	return nil
 }
@@ -134,17 +196,24 @@ func (m *RuleManager) Initialize(maxReplica int, locationLabels []string) error
 func (m *RuleManager) loadRules() error {
 	var toSave []*Rule
 	var toDelete []string
+	// the rules of the old schema versions are migrated once loaded, but they
+	// are only saved in the current version once updated.
+	var legacyRules int
 	err := m.storage.LoadRules(func(k, v string) {
+		if version, err := ruleSchemaVersionOf([]byte(v)); err == nil && version < RuleSchemaVersion {
+			legacyRules++
+		}
+		// the rules which can not be parsed or don't pass the checks of the
+		// current version are skipped but kept in storage, since they may be
+		// saved by the old versions, and can be fixed or deleted by users.
 		r, err := NewRuleFromJSON([]byte(v))
 		if err != nil {
-			log.Error("failed to unmarshal rule value", zap.String("rule-key", k), zap.String("rule-value", v), errs.ZapError(errs.ErrLoadRule))
-			toDelete = append(toDelete, k)
+			log.Error("failed to unmarshal rule value, skip it", zap.String("rule-key", k), zap.String("rule-value", v), errs.ZapError(errs.ErrLoadRule, err))
 			return
 		}
 		err = m.adjustRule(r, "")
 		if err != nil {
-			log.Error("rule is in bad format", zap.String("rule-key", k), zap.String("rule-value", v), errs.ZapError(errs.ErrLoadRule, err))
-			toDelete = append(toDelete, k)
+			log.Error("rule is in bad format, skip it", zap.String("rule-key", k), zap.String("rule-value", v), errs.ZapError(errs.ErrLoadRule, err))
 			return
 		}
 		_, ok := m.ruleConfig.rules[r.Key()]
@@ -158,22 +227,34 @@ func (m *RuleManager) loadRules() error {
 			toDelete = append(toDelete, k)
 			toSave = append(toSave, r)
 		}
+		if r.Revision > m.revision {
+			m.revision = r.Revision
+		}
 		m.ruleConfig.rules[r.Key()] = r
 	})
 	if err != nil {
 		return err
 	}
-	for _, s := range toSave {
-		if err = m.storage.SaveRule(s.StoreKey(), s); err != nil {
-			return err
-		}
+	if legacyRules > 0 {
+		log.Info("rules of the old schema versions are loaded",
+			zap.Int("count", legacyRules), zap.Int("schema-version", RuleSchemaVersion))
 	}
-	for _, d := range toDelete {
-		if err = m.storage.DeleteRule(d); err != nil {
-			return err
-		}
+	if len(toSave) == 0 && len(toDelete) == 0 {
+		return nil
 	}
-	return nil
+	return m.storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for _, s := range toSave {
+			if err := m.storage.SaveRule(txn, s.StoreKey(), s); err != nil {
+				return err
+			}
+		}
+		for _, d := range toDelete {
+			if err := m.storage.DeleteRule(txn, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (m *RuleManager) loadGroups() error {
@@ -189,16 +270,17 @@ func (m *RuleManager) loadGroups() error {
 
 // check and adjust rule from client or storage.
 func (m *RuleManager) adjustRule(r *Rule, groupID string) (err error) {
-	r.StartKey, err = hex.DecodeString(r.StartKeyHex)
-	if err != nil {
-		return errs.ErrHexDecodingString.FastGenByArgs(r.StartKeyHex)
+	if err = r.NormalizeKeys(); err != nil {
+		return err
 	}
-	r.EndKey, err = hex.DecodeString(r.EndKeyHex)
-	if err != nil {
-		return errs.ErrHexDecodingString.FastGenByArgs(r.EndKeyHex)
+	if err = bindKeyspaceRange(r); err != nil {
+		return err
+	}
+	if err = checkKeyRange(r.StartKey, r.EndKey); err != nil {
+		return err
 	}
-	if len(r.EndKey) > 0 && bytes.Compare(r.EndKey, r.StartKey) <= 0 {
-		return errs.ErrRuleContent.FastGenByArgs("endKey should be greater than startKey")
+	if err = checkKeyspaceRange(r); err != nil {
+		return err
 	}
 
 	if m.keyType == constant.Table.String() || m.keyType == constant.Txn.String() {
@@ -230,15 +312,42 @@ func (m *RuleManager) adjustRule(r *Rule, groupID string) (err error) {
 	if !validateRole(r.Role) {
 		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid role %s", r.Role))
 	}
+	if r.Role == Witness {
+		r.IsWitness = true
+	}
+	if r.CountExpr != nil {
+		if err = r.CountExpr.validate(); err != nil {
+			return err
+		}
+		if r.Role == Leader || r.IsWitness {
+			return errs.ErrRuleContent.FastGenByArgs("count expression is not supported by the leader or witness rules")
+		}
+		// the count is used before it's resolved, e.g., checking the rules.
+		if r.Count == 0 {
+			r.Count = r.CountExpr.minCount()
+		}
+	}
 	if r.Count <= 0 {
 		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid count %d", r.Count))
 	}
 	if r.Role == Leader && r.Count > 1 {
 		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("define multiple leaders by count %d", r.Count))
 	}
+	if r.Role == Leader && r.IsWitness {
+		return errs.ErrRuleContent.FastGenByArgs("leader can't be a witness")
+	}
 	if r.IsWitness && r.Count > m.conf.GetMaxReplicas()/2 {
 		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("define too many witness by count %d", r.Count))
 	}
+	if err = r.checkEngine(r.IsWitness); err != nil {
+		return err
+	}
+	if err = r.Precondition.validate(); err != nil {
+		return err
+	}
+	if err = r.validateActiveWindow(); err != nil {
+		return err
+	}
 	for _, c := range r.LabelConstraints {
 		if !validateOp(c.Op) {
 			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s", c.Op))
@@ -247,6 +356,12 @@ func (m *RuleManager) adjustRule(r *Rule, groupID string) (err error) {
 			return errs.ErrRuleContent.FastGenByArgs("witness can't combine with tiflash")
 		}
 	}
+	if err = r.checkLabelConstraintAlternatives(); err != nil {
+		return err
+	}
+	if err = checkAnnotations(r.Annotations); err != nil {
+		return err
+	}
 
 	if m.storeSetInformer != nil {
 		stores := m.storeSetInformer.GetStores()
@@ -268,35 +383,153 @@ func (m *RuleManager) GetRule(group, id string) *Rule {
 	return nil
 }
 
-// SetRule inserts or updates a Rule.
+// newDefaultRule creates the built-in default rule.
+func newDefaultRule(count int, locationLabels []string) *Rule {
+	return &Rule{
+		GroupID:        "pd",
+		ID:             "default",
+		Role:           Voter,
+		Count:          count,
+		LocationLabels: locationLabels,
+	}
+}
+
+func isDefaultRule(group, id string) bool {
+	return group == "pd" && id == "default"
+}
+
+// checkDefaultRule checks that the default rule is still a usable catch-all
+// after being edited, i.e. it covers the whole key space with at least one peer.
+func checkDefaultRule(r *Rule) error {
+	if !isDefaultRule(r.GroupID, r.ID) {
+		return nil
+	}
+	if len(r.StartKey) > 0 || len(r.EndKey) > 0 {
+		return errs.ErrRuleContent.FastGenByArgs("the default rule must cover the whole key space")
+	}
+	if r.Count < 1 {
+		return errs.ErrRuleContent.FastGenByArgs("the count of the default rule must be at least 1")
+	}
+	return nil
+}
+
+// SetRule inserts or updates a Rule. The default rule can be edited, but it
+// must be still a usable catch-all, see checkDefaultRule. The rule is rejected
+// if it makes any region unschedulable, which can be overridden by ForceSetRule.
 func (m *RuleManager) SetRule(rule *Rule) error {
+	return m.setRule(rule, false)
+}
+
+// ForceSetRule inserts or updates a Rule like SetRule, but it's not rejected
+// even if it makes some regions unschedulable.
+func (m *RuleManager) ForceSetRule(rule *Rule) error {
+	return m.setRule(rule, true)
+}
+
+func (m *RuleManager) setRule(rule *Rule, force bool) error {
 	if err := m.adjustRule(rule, ""); err != nil {
 		return err
 	}
+	if err := m.validateTopologyIfEnabled(rule); err != nil {
+		return err
+	}
 	m.Lock()
 	defer m.Unlock()
 	p := m.beginPatch()
+	p.checkSchedulable = !force
 	p.setRule(rule)
 	if err := m.tryCommitPatch(p); err != nil {
 		return err
 	}
-	log.Info("placement rule updated", zap.String("rule", fmt.Sprint(rule)))
+	log.Info("placement rule updated", zap.String("rule", fmt.Sprint(rule)), zap.Bool("force", force))
 	return nil
 }
 
-// DeleteRule removes a Rule.
-func (m *RuleManager) DeleteRule(group, id string) error {
+// DeleteRule removes a Rule. If the rule tombstone grace period is enabled,
+// the rule is kept as a tombstone which can be restored by RestoreRule until
+// it expires. The default rule is only removed if force is true, since the
+// regions can't be placed sanely without it unless other rules cover them.
+// The deletion is rejected if it makes any region unschedulable, which can be
+// overridden by ForceDeleteRule.
+func (m *RuleManager) DeleteRule(group, id string, force bool) error {
+	return m.deleteRule(group, id, force, false)
+}
+
+// ForceDeleteRule removes a Rule like DeleteRule, but it's not rejected even if
+// it makes some regions unschedulable.
+func (m *RuleManager) ForceDeleteRule(group, id string, force bool) error {
+	return m.deleteRule(group, id, force, true)
+}
+
+func (m *RuleManager) deleteRule(group, id string, deleteDefault, skipCheck bool) error {
 	m.Lock()
 	defer m.Unlock()
 	p := m.beginPatch()
+	p.deleteDefaultRule = deleteDefault
+	p.checkSchedulable = !skipCheck
 	p.deleteRule(group, id)
+	t := m.tombstoneRule(p, group, id, time.Now())
 	if err := m.tryCommitPatch(p); err != nil {
 		return err
 	}
+	if t != nil {
+		log.Info("placement rule is tombstoned", zap.String("group", group), zap.String("id", id), zap.Time("expire-at", t.ExpireAt))
+		return nil
+	}
 	log.Info("placement rule is removed", zap.String("group", group), zap.String("id", id))
 	return nil
 }
 
+// RestoreDefaultRule reinstates the built-in default rule with the current
+// max replicas and location labels, which overwrites the edited one if any.
+func (m *RuleManager) RestoreDefaultRule() error {
+	if m.conf == nil {
+		return errors.New("the config is not available to restore the default rule")
+	}
+	rule := newDefaultRule(m.conf.GetMaxReplicas(), m.conf.GetLocationLabels())
+	if err := m.adjustRule(rule, ""); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	p.setRule(rule)
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("default placement rule restored", zap.String("rule", fmt.Sprint(rule)))
+	return nil
+}
+
+// SetRuleEnabled enables or disables a rule. The disabled rule is kept in the
+// storage and listed as usual, but it's skipped by fit until it's enabled
+// again, so it can be neutralized without losing the configuration.
+func (m *RuleManager) SetRuleEnabled(group, id string, enabled bool) error {
+	m.Lock()
+	defer m.Unlock()
+	old := m.ruleConfig.getRule([2]string{group, id})
+	if old == nil {
+		return errs.ErrRuleNotFound.FastGenByArgs(id, group)
+	}
+	if old.IsEnabled() == enabled {
+		return nil
+	}
+	rule := old.Clone()
+	if enabled {
+		// enabled is the default, so it's omitted like the rules never disabled.
+		rule.Enabled = nil
+	} else {
+		rule.Enabled = &enabled
+	}
+	p := m.beginPatch()
+	p.setRule(rule)
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("placement rule enabled state updated", zap.String("group", group), zap.String("id", id), zap.Bool("enabled", enabled))
+	return nil
+}
+
 // GetSplitKeys returns all split keys in the range (start, end).
 func (m *RuleManager) GetSplitKeys(start, end []byte) [][]byte {
 	m.RLock()
@@ -344,39 +577,220 @@ func (m *RuleManager) GetRulesByKey(key []byte) []*Rule {
 
 // GetRulesForApplyRegion returns the rules list that should be applied to a region.
 func (m *RuleManager) GetRulesForApplyRegion(region *core.RegionInfo) []*Rule {
+	rules, _ := m.getRulesForApplyRegion(region)
+	return rules
+}
+
+// getRulesForApplyRegion returns the rules list that should be applied to a
+// region, and the version of the rule set.
+func (m *RuleManager) getRulesForApplyRegion(region *core.RegionInfo) ([]*Rule, uint64) {
 	m.RLock()
 	defer m.RUnlock()
-	return m.ruleList.getRulesForApplyRange(region.GetStartKey(), region.GetEndKey())
+	rules := m.ruleList.getActiveRulesForApplyRange(region.GetStartKey(), region.GetEndKey(), m.inactiveRules)
+	return m.fallbackUnsatisfiableRules(m.filterKeyspaceRules(region.GetStartKey(), rules)), m.ruleSetVersion
 }
 
 // GetRulesForApplyRange returns the rules list that should be applied to a range.
 func (m *RuleManager) GetRulesForApplyRange(start, end []byte) []*Rule {
 	m.RLock()
 	defer m.RUnlock()
-	return m.ruleList.getRulesForApplyRange(start, end)
+	rules := m.ruleList.getActiveRulesForApplyRange(start, end, m.inactiveRules)
+	return m.fallbackUnsatisfiableRules(m.filterKeyspaceRules(start, rules))
+}
+
+// fallbackUnsatisfiableRules drops the rules of the groups which have any rule
+// can not match any store if the fallback is enabled, so only the unsatisfiable
+// groups of the range fall back. The default rule is used if no voter is left,
+// unless it's missing or unsatisfiable too, then the rules are returned as is.
+func (m *RuleManager) fallbackUnsatisfiableRules(rules []*Rule) []*Rule {
+	if len(m.unsatisfiableRules) == 0 || m.conf == nil || !m.conf.IsUnsatisfiableRuleFallbackEnabled() {
+		return rules
+	}
+	unsatisfiableGroups := make(map[string]struct{})
+	for _, r := range rules {
+		if _, ok := m.unsatisfiableRules[r.Key()]; ok {
+			unsatisfiableGroups[r.GroupID] = struct{}{}
+		}
+	}
+	if len(unsatisfiableGroups) == 0 {
+		return rules
+	}
+	defaultRule := m.ruleConfig.getRule([2]string{"pd", "default"})
+	if defaultRule == nil {
+		return rules
+	}
+	if _, ok := m.unsatisfiableRules[defaultRule.Key()]; ok {
+		return rules
+	}
+	res := make([]*Rule, 0, len(rules))
+	hasVoter := false
+	for _, r := range rules {
+		if _, ok := unsatisfiableGroups[r.GroupID]; ok {
+			continue
+		}
+		res = append(res, r)
+		hasVoter = hasVoter || r.Role == Voter || r.Role == Leader
+	}
+	if !hasVoter {
+		res = append(res, defaultRule)
+		sortRules(res)
+	}
+	return res
+}
+
+// CheckUnsatisfiableRules checks all rules against the current stores and
+// returns the sorted rules whose label constraints can not match any store.
+// The result is also used by the fallback and reported by metrics.
+func (m *RuleManager) CheckUnsatisfiableRules() []*Rule {
+	stores := m.getAliveStores()
+	m.Lock()
+	defer m.Unlock()
+	previous := m.unsatisfiableRules
+	m.unsatisfiableRules = make(map[[2]string]struct{})
+	unsatisfiableRulesGauge.Reset()
+	// the fallback changes the rules applied to the regions.
+	defer func() {
+		if !unsatisfiableRulesEqual(previous, m.unsatisfiableRules) {
+			m.invalidFitCache(nil)
+		}
+	}()
+	rules := m.findUnsatisfiableRules(stores)
+	for _, r := range rules {
+		m.unsatisfiableRules[r.Key()] = struct{}{}
+		unsatisfiableRulesGauge.WithLabelValues(r.GroupID, r.ID).Set(1)
+	}
+	return rules
+}
+
+// GetUnsatisfiableRules returns the sorted rules whose label constraints can
+// not match any store currently. Unlike CheckUnsatisfiableRules, it changes
+// neither the fallback nor the metrics.
+func (m *RuleManager) GetUnsatisfiableRules() []*Rule {
+	stores := m.getAliveStores()
+	m.RLock()
+	defer m.RUnlock()
+	return m.findUnsatisfiableRules(stores)
+}
+
+func (m *RuleManager) findUnsatisfiableRules(stores []*core.StoreInfo) []*Rule {
+	// there is no store yet, such as the cluster is bootstrapping.
+	if len(stores) == 0 {
+		return nil
+	}
+	var rules []*Rule
+	for _, r := range m.ruleConfig.rules {
+		if !checkRule(r, stores) {
+			rules = append(rules, r.Clone())
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+func unsatisfiableRulesEqual(a, b map[[2]string]struct{}) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// unusedRuleGracePeriod is how long a rule should cover no region before it's
+// regarded as unused, so the rules covering no region transiently, e.g., the
+// regions are being split or merged, are not reported.
+const unusedRuleGracePeriod = 10 * time.Minute
+
+type unusedRule struct {
+	since time.Time
+	// warned is set once the rule is reported, so the warning is logged once.
+	warned bool
+}
+
+// FindUnusedRules returns the sorted keys of the rules whose key ranges have
+// not intersected any region for a while, in the format of "group_id/id".
+// The rules covering the whole key space are never reported.
+func (m *RuleManager) FindUnusedRules() []string {
+	return m.findUnusedRules(time.Now())
+}
+
+func (m *RuleManager) findUnusedRules(now time.Time) []string {
+	regionSet, ok := m.storeSetInformer.(interface {
+		ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+	})
+	if !ok {
+		return nil
+	}
+	m.Lock()
+	defer m.Unlock()
+	unused := make(map[[2]string]*unusedRule)
+	var keys []string
+	for key, r := range m.ruleConfig.rules {
+		if len(r.StartKey) == 0 && len(r.EndKey) == 0 {
+			continue
+		}
+		if len(regionSet.ScanRegions(r.StartKey, r.EndKey, 1)) > 0 {
+			continue
+		}
+		u, ok := m.unusedRules[key]
+		if !ok {
+			u = &unusedRule{since: now}
+		}
+		unused[key] = u
+		if now.Sub(u.since) < unusedRuleGracePeriod {
+			continue
+		}
+		if !u.warned {
+			log.Warn("rule covers no region", zap.String("group-id", r.GroupID), zap.String("rule-id", r.ID), zap.Time("since", u.since))
+			u.warned = true
+		}
+		keys = append(keys, r.GroupID+"/"+r.ID)
+	}
+	m.unusedRules = unused
+	sort.Strings(keys)
+	return keys
+}
+
+// ResetUnsatisfiableRulesMetrics resets the metrics of unsatisfiable rules.
+func (m *RuleManager) ResetUnsatisfiableRulesMetrics() {
+	unsatisfiableRulesGauge.Reset()
 }
 
 // IsRegionFitCached returns whether the RegionFit can be cached.
 func (m *RuleManager) IsRegionFitCached(storeSet StoreSet, region *core.RegionInfo) bool {
 	regionStores := getStoresByRegion(storeSet, region)
-	rules := m.GetRulesForApplyRegion(region)
-	isCached, _ := m.cache.CheckAndGetCache(region, rules, regionStores)
+	rules, ruleSetVersion := m.getRulesForApplyRegion(region)
+	isCached, _ := m.cache.checkAndGetCacheByVersion(region, rules, ruleSetVersion, regionStores)
 	return isCached
 }
 
 // FitRegion fits a region to the rules it matches.
 func (m *RuleManager) FitRegion(storeSet StoreSet, region *core.RegionInfo) (fit *RegionFit) {
 	regionStores := getStoresByRegion(storeSet, region)
-	rules := m.GetRulesForApplyRegion(region)
+	rules, ruleSetVersion := m.getRulesForApplyRegion(region)
+	// the counts resolved against the stores can be changed without changing
+	// the rules, so the fits with them are not cached.
+	countExpr := hasCountExpr(rules)
+	if countExpr {
+		rules = resolveRuleCounts(rules, storeSet.GetStores())
+	}
 	var isCached bool
-	if m.conf.IsPlacementRulesCacheEnabled() {
-		if isCached, fit = m.cache.CheckAndGetCache(region, rules, regionStores); isCached && fit != nil {
+	if m.conf.IsPlacementRulesCacheEnabled() && !countExpr {
+		isCached, fit = m.cache.checkAndGetCacheByVersion(region, rules, ruleSetVersion, regionStores)
+		m.cache.recordLookup(isCached && fit != nil)
+		if isCached && fit != nil {
 			return fit
 		}
 	}
 	fit = fitRegion(regionStores, region, rules, m.conf.IsWitnessAllowed())
 	fit.regionStores = regionStores
 	fit.rules = rules
+	fit.ruleSetVersion = ruleSetVersion
+	m.recordFitFailures(storeSet, fit)
+	m.ruleUsage.record(region.GetID(), fit)
 	if isCached {
 		m.SetRegionFitCache(region, fit)
 	}
@@ -393,6 +807,42 @@ func (m *RuleManager) InvalidCache(regionID uint64) {
 	m.cache.Invalid(regionID)
 }
 
+// FlushFitCache invalidates all region fit caches.
+// Only used for testing
+func (m *RuleManager) FlushFitCache() {
+	m.cache.Flush()
+}
+
+// GetFitCacheStats returns the number of hits and misses of the region fit cache.
+func (m *RuleManager) GetFitCacheStats() (hits, misses uint64) {
+	return m.cache.Stats()
+}
+
+// invalidFitCache bumps the version of the rule set and invalidates the region
+// fit caches overlapped with the ranges, or all of them if ranges is nil.
+func (m *RuleManager) invalidFitCache(ranges [][2][]byte) {
+	m.ruleSetVersion++
+	m.cache.invalidRules(m.ruleSetVersion, ranges)
+}
+
+// changedRanges returns the key ranges whose rules are changed by the patch.
+// It returns nil if all regions may be affected, e.g., a group is changed.
+func (m *RuleManager) changedRanges(p *ruleConfigPatch) [][2][]byte {
+	if len(p.mut.groups) > 0 {
+		return nil
+	}
+	ranges := make([][2][]byte, 0, len(p.mut.rules)*2)
+	for key, r := range p.mut.rules {
+		if old := m.ruleConfig.getRule(key); old != nil {
+			ranges = append(ranges, [2][]byte{old.StartKey, old.EndKey})
+		}
+		if r != nil {
+			ranges = append(ranges, [2][]byte{r.StartKey, r.EndKey})
+		}
+	}
+	return ranges
+}
+
 // SetPlaceholderRegionFitCache sets a placeholder region fit cache information
 // Only used for testing
 func (m *RuleManager) SetPlaceholderRegionFitCache(region *core.RegionInfo) {
@@ -415,54 +865,234 @@ func (m *RuleManager) beginPatch() *ruleConfigPatch {
 	return m.ruleConfig.beginPatch()
 }
 
-func (m *RuleManager) tryCommitPatch(patch *ruleConfigPatch) error {
+func (m *RuleManager) tryCommitPatch(patch *ruleConfigPatch) (err error) {
+	if err := m.checkFrozenGroups(patch); err != nil {
+		return err
+	}
+	if err := checkGroupParents(patch); err != nil {
+		return err
+	}
+	if err := m.checkDefaultRulePatch(patch); err != nil {
+		return err
+	}
 	patch.adjust()
+	defer func() {
+		// patch.adjust may bind the current rules to the uncommitted groups,
+		// restore them if the patch is rejected.
+		if err != nil {
+			m.ruleConfig.adjust()
+		}
+	}()
 
 	ruleList, err := buildRuleList(patch)
 	if err != nil {
 		return err
 	}
+	if patch.checkSchedulable {
+		if err = m.checkSchedulable(patch, ruleList); err != nil {
+			return err
+		}
+	}
 
 	patch.trim()
 
 	// save updates
-	err = m.savePatch(patch.mut)
+	revision := m.stampRevisions(patch.mut.sortedRules())
+	auditEntries := newAuditEntries(patch)
+	history := m.newHistoryEntry(auditEntries)
+	ops := append(m.revisionOps(revision), patch.extraOps...)
+	err = m.savePatch(patch.mut, append(ops, m.historyOps(history)...)...)
 	if err != nil {
 		return err
 	}
+	m.revision = revision
+	if history != nil {
+		m.historyRevision = history.Revision
+	}
 
 	// update in-memory state
+	var ranges [][2][]byte
+	changed := len(patch.mut.rules) > 0 || len(patch.mut.groups) > 0
+	if changed {
+		ranges = m.changedRanges(patch)
+	}
 	patch.commit()
 	m.ruleList = ruleList
+	// the updated rules have been checked by adjustRule, the deleted ones are gone.
+	for key := range patch.mut.rules {
+		delete(m.unsatisfiableRules, key)
+		delete(m.unusedRules, key)
+	}
+	m.updateInactiveRules(patch.mut, m.getAliveStores())
+	if changed {
+		m.invalidFitCache(ranges)
+	}
+	m.notifyObservers(patch.mut)
+	m.audit(auditEntries)
 	return nil
 }
 
-func (m *RuleManager) savePatch(p *ruleConfig) error {
-	// TODO: it is not completely safe
-	// 1. in case that half of rules applied, error.. we have to cancel persisted rules
-	// but that may fail too, causing memory/disk inconsistency
-	// either rely a transaction API, or clients to request again until success
-	// 2. in case that PD is suddenly down in the loop, inconsistency again
-	// now we can only rely clients to request again
-	var err error
-	for key, r := range p.rules {
-		if r == nil {
-			r = &Rule{GroupID: key[0], ID: key[1]}
-			err = m.storage.DeleteRule(r.StoreKey())
-		} else {
-			err = m.storage.SaveRule(r.StoreKey(), r)
+// checkFrozenGroups rejects the patch if it changes any rule of a frozen group,
+// or changes a frozen group without the privilege.
+func (m *RuleManager) checkFrozenGroups(p *ruleConfigPatch) error {
+	isFrozen := func(id string) bool {
+		g, ok := m.ruleConfig.groups[id]
+		return ok && g.Frozen
+	}
+	for key := range p.mut.rules {
+		if isFrozen(key[0]) {
+			return errs.ErrRuleGroupFrozen.FastGenByArgs(key[0])
 		}
-		if err != nil {
-			return err
+	}
+	if p.changeFrozen {
+		return nil
+	}
+	for id, g := range p.mut.groups {
+		if isFrozen(id) || g.Frozen {
+			return errs.ErrRuleGroupFrozen.FastGenByArgs(id)
 		}
 	}
+	return nil
+}
+
+// checkDefaultRulePatch rejects the patch if it deletes the default rule, or
+// moves it to another group, without the privilege, and checks that the edited
+// default rule is still a usable catch-all, see checkDefaultRule.
+func (m *RuleManager) checkDefaultRulePatch(p *ruleConfigPatch) error {
+	key := [2]string{"pd", "default"}
+	r, ok := p.mut.rules[key]
+	if !ok {
+		return nil
+	}
+	if r != nil {
+		return checkDefaultRule(r)
+	}
+	if !p.deleteDefaultRule && m.ruleConfig.getRule(key) != nil {
+		return errs.ErrDefaultRuleProtected.FastGenByArgs()
+	}
+	return nil
+}
+
+func (m *RuleManager) savePatch(p *ruleConfig, extraOps ...func(txn kv.Txn) error) error {
+	// The updates are saved in one transaction, so that the storage will not
+	// be left half-updated if any of them fails, and the watchers can observe
+	// them as a whole. The patch exceeding the operation limit of etcd
+	// transaction is rejected rather than being split.
+	var ops []func(txn kv.Txn) error
+	for key, t := range p.tombstones {
+		key, t := key, t
+		ops = append(ops, func(txn kv.Txn) error {
+			storeKey := (&Rule{GroupID: key[0], ID: key[1]}).StoreKey()
+			if t == nil {
+				return m.storage.DeleteRuleTombstone(txn, storeKey)
+			}
+			return m.storage.SaveRuleTombstone(txn, storeKey, t)
+		})
+	}
+	// save the rules in order of the keys, which is also the order of their
+	// revisions, so the watchers receive the revisions in order.
+	for _, key := range p.ruleKeys() {
+		key, r := key, p.rules[key]
+		ops = append(ops, func(txn kv.Txn) error {
+			if r == nil {
+				return m.storage.DeleteRule(txn, (&Rule{GroupID: key[0], ID: key[1]}).StoreKey())
+			}
+			return m.storage.SaveRule(txn, r.StoreKey(), r)
+		})
+	}
 	for id, g := range p.groups {
-		if g.isDefault() {
-			err = m.storage.DeleteRuleGroup(id)
-		} else {
-			err = m.storage.SaveRuleGroup(id, g)
+		id, g := id, g
+		ops = append(ops, func(txn kv.Txn) error {
+			if g.isDefault() {
+				return m.storage.DeleteRuleGroup(txn, id)
+			}
+			return m.storage.SaveRuleGroup(txn, id, g)
+		})
+	}
+	for id, t := range p.templates {
+		id, t := id, t
+		ops = append(ops, func(txn kv.Txn) error {
+			if t == nil {
+				return m.storage.DeleteRuleTemplate(txn, id)
+			}
+			return m.storage.SaveRuleTemplate(txn, id, t)
+		})
+	}
+	ops = append(ops, extraOps...)
+	if len(ops) > maxEtcdTxnOps {
+		return errs.ErrRuleContent.FastGenByArgs(
+			fmt.Sprintf("too many changes %d to be saved in one transaction, the limit is %d", len(ops), maxEtcdTxnOps))
+	}
+	return m.storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for _, op := range ops {
+			if err := op(txn); err != nil {
+				return err
+			}
 		}
-		if err != nil {
+		return nil
+	})
+}
+
+// stampRevisions assigns the increasing revisions and the current schema
+// version to the rules, and returns the max revision, which should be recorded
+// after the rules are saved.
+func (m *RuleManager) stampRevisions(rules []*Rule) uint64 {
+	revision := m.revision
+	for _, r := range rules {
+		revision++
+		r.Revision = revision
+		r.SchemaVersion = RuleSchemaVersion
+	}
+	return revision
+}
+
+// revisionOps returns the operation saving the max revision of the rules if
+// any rule is stamped with a new revision.
+func (m *RuleManager) revisionOps(revision uint64) []func(txn kv.Txn) error {
+	if revision == m.revision {
+		return nil
+	}
+	return []func(txn kv.Txn) error{
+		func(txn kv.Txn) error { return m.storage.SaveRuleRevision(txn, revision) },
+	}
+}
+
+// loadRevision loads the saved max revision of the rules, which covers the
+// revisions of the deleted rules, so they are never reused.
+func (m *RuleManager) loadRevision() error {
+	v, err := m.storage.LoadRuleRevision()
+	if err != nil || v == "" {
+		return err
+	}
+	revision, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		log.Warn("invalid rule revision", zap.String("revision", v))
+		return nil
+	}
+	if revision > m.revision {
+		m.revision = revision
+	}
+	return nil
+}
+
+// runInTxns runs the operations in as few transactions as the operation limit
+// of etcd transaction allows. They are not atomic as a whole, so it's only used
+// to re-emit the current rules, which can be retried safely.
+func (m *RuleManager) runInTxns(ops []func(txn kv.Txn) error) error {
+	for len(ops) > 0 {
+		batch := ops
+		if len(batch) > maxEtcdTxnOps {
+			batch = batch[:maxEtcdTxnOps]
+		}
+		ops = ops[len(batch):]
+		if err := m.storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+			for _, op := range batch {
+				if err := op(txn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
 			return err
 		}
 	}
@@ -478,6 +1108,9 @@ func (m *RuleManager) SetRules(rules []*Rule) error {
 		if err := m.adjustRule(r, ""); err != nil {
 			return err
 		}
+		if err := m.validateTopologyIfEnabled(r); err != nil {
+			return err
+		}
 		p.setRule(r)
 	}
 	if err := m.tryCommitPatch(p); err != nil {
@@ -488,6 +1121,56 @@ func (m *RuleManager) SetRules(rules []*Rule) error {
 	return nil
 }
 
+// SetRulesForGroup replaces the rules of the group with the given ones, i.e.,
+// the given rules are set and the other rules of the group are deleted. The
+// unchanged rules are not written again. Unlike SetRules, the changes are
+// saved in one transaction, so they are applied atomically and observed by the
+// watchers as a whole. The group itself is left as is.
+func (m *RuleManager) SetRulesForGroup(groupID string, rules []*Rule) error {
+	if groupID == "" {
+		return errs.ErrRuleContent.FastGenByArgs("group ID should not be empty")
+	}
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	ops := 0
+	keep := make(map[[2]string]struct{}, len(rules))
+	for _, r := range rules {
+		if err := m.adjustRule(r, groupID); err != nil {
+			return err
+		}
+		if err := m.validateTopologyIfEnabled(r); err != nil {
+			return err
+		}
+		if _, ok := keep[r.Key()]; ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("duplicate rule %s in group %s", r.ID, groupID))
+		}
+		keep[r.Key()] = struct{}{}
+		if old := m.ruleConfig.getRule(r.Key()); old != nil && len(diffRule(old, r)) == 0 {
+			continue
+		}
+		p.setRule(r)
+		ops++
+	}
+	for key := range m.ruleConfig.rules {
+		if _, ok := keep[key]; key[0] == groupID && !ok {
+			p.deleteRule(key[0], key[1])
+			ops++
+		}
+	}
+	if ops == 0 {
+		return nil
+	}
+	if ops > maxEtcdTxnOps {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("too many changes of rule group %s to be saved in one transaction", groupID))
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("placement rules of group replaced", zap.String("group", groupID), zap.String("rules", fmt.Sprint(rules)))
+	return nil
+}
+
 // RuleOpType indicates the operation type
 type RuleOpType string
 
@@ -511,7 +1194,10 @@ func (r RuleOp) String() string {
 	return string(b)
 }
 
-// Batch executes a series of actions at once.
+// Batch executes a series of actions at once. The actions are validated as a
+// whole and saved in one transaction, either all or none of them take effect.
+// The batch whose changes exceed the operation limit of etcd transaction is
+// rejected.
 func (m *RuleManager) Batch(todo []RuleOp) error {
 	for _, t := range todo {
 		if t.Action == RuleOpAdd {
@@ -519,6 +1205,9 @@ func (m *RuleManager) Batch(todo []RuleOp) error {
 			if err != nil {
 				return err
 			}
+			if err := m.validateTopologyIfEnabled(t.Rule); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -573,11 +1262,16 @@ func (m *RuleManager) GetRuleGroups() []*RuleGroup {
 	return groups
 }
 
-// SetRuleGroup updates a RuleGroup.
+// SetRuleGroup updates a RuleGroup. It's the only way to freeze or unfreeze a
+// group, which should be called by the privileged users only.
 func (m *RuleManager) SetRuleGroup(group *RuleGroup) error {
+	if err := checkAnnotations(group.Annotations); err != nil {
+		return err
+	}
 	m.Lock()
 	defer m.Unlock()
 	p := m.beginPatch()
+	p.changeFrozen = true
 	p.setGroup(group)
 	if err := m.tryCommitPatch(p); err != nil {
 		return err
@@ -599,6 +1293,73 @@ func (m *RuleManager) DeleteRuleGroup(id string) error {
 	return nil
 }
 
+// RenameRuleGroup moves a rule group and all its rules to the new ID, the
+// groups inheriting from it are updated too. All the updates are saved in one
+// transaction, so the watchers never see the rules without their group. It
+// fails if the new ID is in use, or the group has too many rules to be moved
+// in one transaction.
+func (m *RuleManager) RenameRuleGroup(oldID, newID string) error {
+	m.Lock()
+	defer m.Unlock()
+	if newID == "" {
+		return errs.ErrRuleContent.FastGenByArgs("group ID should not be empty")
+	}
+	inUse := func(id string) bool {
+		if g, ok := m.ruleConfig.groups[id]; ok && !g.isDefault() {
+			return true
+		}
+		for key := range m.ruleConfig.rules {
+			if key[0] == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !inUse(oldID) {
+		return errs.ErrRuleGroupNotFound.FastGenByArgs(oldID)
+	}
+	if inUse(newID) {
+		return errs.ErrRuleGroupExists.FastGenByArgs(newID)
+	}
+
+	p := m.beginPatch()
+	p.changeFrozen = true
+	ops := 0
+	if g, ok := m.ruleConfig.groups[oldID]; ok {
+		renamed := *g
+		renamed.ID = newID
+		p.deleteGroup(oldID)
+		p.setGroup(&renamed)
+		ops += 2
+	}
+	for _, g := range m.ruleConfig.groups {
+		if g.ParentID == oldID && g.ID != oldID {
+			child := *g
+			child.ParentID = newID
+			p.setGroup(&child)
+			ops++
+		}
+	}
+	for key, r := range m.ruleConfig.rules {
+		if key[0] != oldID {
+			continue
+		}
+		renamed := r.Clone()
+		renamed.GroupID = newID
+		p.deleteRule(key[0], key[1])
+		p.setRule(renamed)
+		ops += 2
+	}
+	if ops > maxEtcdTxnOps {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("rule group %s has too many rules to be renamed in one transaction", oldID))
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("rule group renamed", zap.String("old-id", oldID), zap.String("new-id", newID))
+	return nil
+}
+
 // GetAllGroupBundles returns all rules and groups configuration. Rules are
 // grouped by groups.
 func (m *RuleManager) GetAllGroupBundles() []GroupBundle {
@@ -606,11 +1367,7 @@ func (m *RuleManager) GetAllGroupBundles() []GroupBundle {
 	defer m.RUnlock()
 	bundles := make([]GroupBundle, 0, len(m.ruleConfig.groups))
 	for _, g := range m.ruleConfig.groups {
-		bundles = append(bundles, GroupBundle{
-			ID:       g.ID,
-			Index:    g.Index,
-			Override: g.Override,
-		})
+		bundles = append(bundles, newGroupBundle(g))
 	}
 	for _, r := range m.ruleConfig.rules {
 		for i := range bundles {
@@ -635,7 +1392,7 @@ func (m *RuleManager) GetGroupBundle(id string) (b GroupBundle) {
 	defer m.RUnlock()
 	b.ID = id
 	if g := m.ruleConfig.groups[id]; g != nil {
-		b.Index, b.Override = g.Index, g.Override
+		b = newGroupBundle(g)
 		for _, r := range m.ruleConfig.rules {
 			if r.GroupID == id {
 				b.Rules = append(b.Rules, r)
@@ -647,10 +1404,13 @@ func (m *RuleManager) GetGroupBundle(id string) (b GroupBundle) {
 }
 
 // SetAllGroupBundles resets configuration. If override is true, all old configurations are dropped.
-func (m *RuleManager) SetAllGroupBundles(groups []GroupBundle, override bool) error {
+// The default rule is only dropped if force is true, see DeleteRule. The groups
+// kept by the bundles are reset by newBundleGroup.
+func (m *RuleManager) SetAllGroupBundles(groups []GroupBundle, override, force bool) error {
 	m.Lock()
 	defer m.Unlock()
 	p := m.beginPatch()
+	p.deleteDefaultRule = force
 	matchID := func(a string) bool {
 		for _, g := range groups {
 			if g.ID == a {
@@ -670,11 +1430,11 @@ func (m *RuleManager) SetAllGroupBundles(groups []GroupBundle, override bool) er
 		}
 	}
 	for _, g := range groups {
-		p.setGroup(&RuleGroup{
-			ID:       g.ID,
-			Index:    g.Index,
-			Override: g.Override,
-		})
+		group, err := m.newBundleGroup(g)
+		if err != nil {
+			return err
+		}
+		p.setGroup(group)
 		for _, r := range g.Rules {
 			if err := m.adjustRule(r, g.ID); err != nil {
 				return err
@@ -685,12 +1445,12 @@ func (m *RuleManager) SetAllGroupBundles(groups []GroupBundle, override bool) er
 	if err := m.tryCommitPatch(p); err != nil {
 		return err
 	}
-	log.Info("full config reset", zap.String("config", fmt.Sprint(groups)))
+	log.Info("full config reset", zap.String("config", fmt.Sprint(groups)), zap.Bool("force", force))
 	return nil
 }
 
 // SetGroupBundle resets a Group and all rules belong to it. All old rules
-// belong to the Group are dropped.
+// belong to the Group are dropped, the Group is reset by newBundleGroup.
 func (m *RuleManager) SetGroupBundle(group GroupBundle) error {
 	m.Lock()
 	defer m.Unlock()
@@ -702,11 +1462,11 @@ func (m *RuleManager) SetGroupBundle(group GroupBundle) error {
 			}
 		}
 	}
-	p.setGroup(&RuleGroup{
-		ID:       group.ID,
-		Index:    group.Index,
-		Override: group.Override,
-	})
+	g, err := m.newBundleGroup(group)
+	if err != nil {
+		return err
+	}
+	p.setGroup(g)
 	for _, r := range group.Rules {
 		if err := m.adjustRule(r, group.ID); err != nil {
 			return err
@@ -720,9 +1480,39 @@ func (m *RuleManager) SetGroupBundle(group GroupBundle) error {
 	return nil
 }
 
+// newBundleGroup returns the group to be set by the bundle. The configurations
+// of the group omitted by the bundle keep the ones of the existing group, so
+// they can't be cleared by the bundle, but by SetRuleGroup.
+func (m *RuleManager) newBundleGroup(b GroupBundle) (*RuleGroup, error) {
+	if err := checkAnnotations(b.Annotations); err != nil {
+		return nil, err
+	}
+	g := *m.ruleConfig.getGroup(b.ID)
+	g.ID, g.Index, g.Override = b.ID, b.Index, b.Override
+	// the frozen group is rejected by checkFrozenGroups anyway.
+	g.Frozen = g.Frozen || b.Frozen
+	if b.ParentID != "" {
+		g.ParentID = b.ParentID
+	}
+	if len(b.LabelConstraints) > 0 {
+		g.LabelConstraints = b.LabelConstraints
+	}
+	if len(b.DefaultLocationLabels) > 0 {
+		g.DefaultLocationLabels = b.DefaultLocationLabels
+	}
+	if len(b.DefaultLabelConstraints) > 0 {
+		g.DefaultLabelConstraints = b.DefaultLabelConstraints
+	}
+	if len(b.Annotations) > 0 {
+		g.Annotations = b.Annotations
+	}
+	return &g, nil
+}
+
 // DeleteGroupBundle removes a Group and all rules belong to it. If `regex` is
-// true, `id` is a regexp expression.
-func (m *RuleManager) DeleteGroupBundle(id string, regex bool) error {
+// true, `id` is a regexp expression. The default rule is only removed if force
+// is true, see DeleteRule.
+func (m *RuleManager) DeleteGroupBundle(id string, regex, force bool) error {
 	m.Lock()
 	defer m.Unlock()
 	matchID := func(a string) bool { return a == id }
@@ -735,6 +1525,7 @@ func (m *RuleManager) DeleteGroupBundle(id string, regex bool) error {
 	}
 
 	p := m.beginPatch()
+	p.deleteDefaultRule = force
 	for k := range m.ruleConfig.rules {
 		if matchID(k[0]) {
 			p.deleteRule(k[0], k[1])
@@ -748,7 +1539,109 @@ func (m *RuleManager) DeleteGroupBundle(id string, regex bool) error {
 	if err := m.tryCommitPatch(p); err != nil {
 		return err
 	}
-	log.Info("groups are removed", zap.String("id", id), zap.Bool("regexp", regex))
+	log.Info("groups are removed", zap.String("id", id), zap.Bool("regexp", regex), zap.Bool("force", force))
+	return nil
+}
+
+// Restore runs restore to overwrite the rules and rule groups in storage, and
+// reloads them. The write lock is held during the whole process, so the
+// concurrent updates are serialized with it.
+func (m *RuleManager) Restore(restore func() error) error {
+	m.Lock()
+	defer m.Unlock()
+	if err := restore(); err != nil {
+		return err
+	}
+	// the rules will be loaded by Initialize.
+	if !m.initialized {
+		return nil
+	}
+	current := m.ruleConfig
+	m.ruleConfig = newRuleConfig()
+	err := m.loadRules()
+	if err == nil {
+		err = m.loadGroups()
+	}
+	if err == nil {
+		err = m.loadTemplates()
+	}
+	if err == nil {
+		err = m.loadRevision()
+	}
+	if err == nil {
+		err = m.loadHistoryRevision()
+	}
+	loaded := m.ruleConfig
+	m.ruleConfig = current
+	if err != nil {
+		return err
+	}
+	// apply the reloaded configuration as a patch, so that the versions of the
+	// changed rules are bumped.
+	p := m.beginPatch()
+	// the reloaded configuration is trusted, even if it has no default rule.
+	p.deleteDefaultRule = true
+	for key := range current.rules {
+		if _, ok := loaded.rules[key]; !ok {
+			p.deleteRule(key[0], key[1])
+		}
+	}
+	for id := range current.groups {
+		if _, ok := loaded.groups[id]; !ok {
+			p.deleteGroup(id)
+		}
+	}
+	for _, r := range loaded.rules {
+		p.setRule(r)
+	}
+	for _, g := range loaded.groups {
+		p.setGroup(g)
+	}
+	for id := range current.templates {
+		if _, ok := loaded.templates[id]; !ok {
+			p.deleteTemplate(id)
+		}
+	}
+	for _, t := range loaded.templates {
+		p.setTemplate(t)
+	}
+	// the tombstones are not included in the restored configuration, drop the
+	// ones of the restored rules since they can not be restored anymore.
+	for key := range current.tombstones {
+		if _, ok := loaded.rules[key]; ok {
+			p.deleteTombstone(key[0], key[1])
+		}
+	}
+	p.adjust()
+	ruleList, err := buildRuleList(p)
+	if err != nil {
+		m.ruleConfig.adjust()
+		return err
+	}
+	p.trim()
+	// the restored rules are saved again with new revisions, otherwise they
+	// will be dropped by the watchers as stale updates.
+	revision := m.stampRevisions(p.mut.sortedRules())
+	auditEntries := newAuditEntries(p)
+	history := m.newHistoryEntry(auditEntries)
+	ops := append(m.revisionOps(revision), m.historyOps(history)...)
+	if err := m.savePatch(&ruleConfig{rules: p.mut.rules, tombstones: p.mut.tombstones}, ops...); err != nil {
+		m.ruleConfig.adjust()
+		return err
+	}
+	m.revision = revision
+	if history != nil {
+		m.historyRevision = history.Revision
+	}
+	p.commit()
+	m.ruleList = ruleList
+	m.unsatisfiableRules = make(map[[2]string]struct{})
+	m.unusedRules = make(map[[2]string]*unusedRule)
+	m.updateInactiveRules(p.mut, m.getAliveStores())
+	m.invalidFitCache(nil)
+	m.notifyObservers(p.mut)
+	m.audit(auditEntries)
+	log.Info("rules reloaded", zap.Int("rule-count", len(m.ruleConfig.rules)), zap.Int("group-count", len(m.ruleConfig.groups)))
 	return nil
 }
 
@@ -763,7 +1656,7 @@ func (m *RuleManager) IsInitialized() bool {
 // in order to reduce the calculation.
 func checkRule(rule *Rule, stores []*core.StoreInfo) bool {
 	return slice.AnyOf(stores, func(idx int) bool {
-		return MatchLabelConstraints(stores[idx], rule.LabelConstraints)
+		return rule.MatchStore(stores[idx])
 	})
 }
 
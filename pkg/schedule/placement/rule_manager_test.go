@@ -15,17 +15,29 @@
 package placement
 
 import (
+	"context"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/pkg/codec"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/core/constant"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mock/mockconfig"
+	"github.com/tikv/pd/pkg/schedule/labeler"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/storage/kv"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
+	"github.com/tikv/pd/pkg/utils/typeutil"
 )
 
 func newTestManager(t *testing.T, enableWitness bool) (endpoint.RuleStorage, *RuleManager) {
@@ -100,6 +112,13 @@ func TestAdjustRule(t *testing.T) {
 		re.Error(manager.adjustRule(&rules[i], "group"))
 	}
 
+	// the raw keys are accepted, but they must agree with the hex ones.
+	rule := &Rule{GroupID: "group", ID: "id", StartKey: []byte{0x12, 0x3a, 0xbc}, EndKeyHex: "123abf", Role: "voter", Count: 3}
+	re.NoError(manager.adjustRule(rule, "group"))
+	re.Equal("123abc", rule.StartKeyHex)
+	rule = &Rule{GroupID: "group", ID: "id", StartKey: []byte{0x12, 0x3a, 0xbc}, StartKeyHex: "123abd", Role: "voter", Count: 3}
+	re.Error(manager.adjustRule(rule, "group"))
+
 	manager.SetKeyType(constant.Table.String())
 	re.Error(manager.adjustRule(&Rule{GroupID: "group", ID: "id", StartKeyHex: "123abc", EndKeyHex: "123abf", Role: "voter", Count: 3}, "group"))
 
@@ -132,6 +151,7 @@ func TestLeaderCheck(t *testing.T) {
 	_, manager := newTestManager(t, false)
 	re.Regexp(".*needs at least one leader or voter.*", manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: "learner", Count: 3}).Error())
 	re.Regexp(".*define multiple leaders by count 2.*", manager.SetRule(&Rule{GroupID: "g2", ID: "33", Role: "leader", Count: 2}).Error())
+	re.Regexp(".*leader can't be a witness.*", manager.SetRule(&Rule{GroupID: "g2", ID: "33", Role: "leader", Count: 1, IsWitness: true}).Error())
 	re.Regexp(".*multiple leader replicas.*", manager.Batch([]RuleOp{
 		{
 			Rule:   &Rule{GroupID: "g2", ID: "foo1", Role: "leader", Count: 1},
@@ -165,6 +185,36 @@ func TestSaveLoad(t *testing.T) {
 	re.Equal(rules[2].String(), m2.GetRule("foo", "bar").String())
 }
 
+func TestLoadBadRules(t *testing.T) {
+	re := require.New(t)
+	store, _ := newTestManager(t, false)
+	// the rules saved by the old versions may not pass the current checks.
+	badRules := map[string]string{
+		(&Rule{GroupID: "foo", ID: "role"}).StoreKey():      `{"group_id":"foo","id":"role","start_key":"","end_key":"","role":"master","count":1}`,
+		(&Rule{GroupID: "foo", ID: "range"}).StoreKey():     `{"group_id":"foo","id":"range","start_key":"75","end_key":"74","role":"voter","count":1}`,
+		(&Rule{GroupID: "foo", ID: "alt"}).StoreKey():       `{"group_id":"foo","id":"alt","start_key":"","end_key":"","role":"voter","count":1,"label_constraint_alternatives":[[]]}`,
+		(&Rule{GroupID: "foo", ID: "malformed"}).StoreKey(): `{"group_id":"foo","id":`,
+	}
+	re.NoError(store.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for k, v := range badRules {
+			if err := txn.Save("rules/"+k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	m2 := NewRuleManager(store, nil, nil)
+	re.NoError(m2.Initialize(3, []string{}))
+	re.Len(m2.GetAllRules(), 1)
+	// the bad rules are skipped but kept in storage.
+	var keys []string
+	re.NoError(store.LoadRules(func(k, _ string) { keys = append(keys, k) }))
+	for k := range badRules {
+		re.Contains(keys, k)
+	}
+}
+
 func TestSetAfterGet(t *testing.T) {
 	re := require.New(t)
 	store, manager := newTestManager(t, false)
@@ -214,7 +264,7 @@ func TestKeys(t *testing.T) {
 	manager.SetRules(rules)
 	checkRules(t, manager.GetAllRules(), [][2]string{{"1", "1"}, {"2", "2"}, {"2", "3"}, {"3", "4"}, {"3", "5"}, {"pd", "default"}})
 
-	manager.DeleteRule("pd", "default")
+	manager.DeleteRule("pd", "default", true)
 	checkRules(t, manager.GetAllRules(), [][2]string{{"1", "1"}, {"2", "2"}, {"2", "3"}, {"3", "4"}, {"3", "5"}})
 
 	splitKeys := [][]string{
@@ -285,7 +335,7 @@ func TestDeleteByIDPrefix(t *testing.T) {
 		{GroupID: "g2", ID: "foobar", Role: "voter", Count: 1},
 		{GroupID: "g2", ID: "baz2", Role: "voter", Count: 1},
 	})
-	manager.DeleteRule("pd", "default")
+	manager.DeleteRule("pd", "default", true)
 	checkRules(t, manager.GetAllRules(), [][2]string{{"g1", "foo1"}, {"g2", "baz2"}, {"g2", "foo1"}, {"g2", "foobar"}})
 
 	manager.Batch([]RuleOp{{
@@ -299,7 +349,7 @@ func TestDeleteByIDPrefix(t *testing.T) {
 func TestRangeGap(t *testing.T) {
 	re := require.New(t)
 	_, manager := newTestManager(t, false)
-	err := manager.DeleteRule("pd", "default")
+	err := manager.DeleteRule("pd", "default", true)
 	re.Error(err)
 
 	err = manager.SetRule(&Rule{GroupID: "pd", ID: "foo", StartKeyHex: "", EndKeyHex: "abcd", Role: "voter", Count: 1})
@@ -307,12 +357,12 @@ func TestRangeGap(t *testing.T) {
 	// |-- default --|
 	// |-- foo --|
 	// still cannot delete default since it will cause ("abcd", "") has no rules inside.
-	err = manager.DeleteRule("pd", "default")
+	err = manager.DeleteRule("pd", "default", true)
 	re.Error(err)
 	err = manager.SetRule(&Rule{GroupID: "pd", ID: "bar", StartKeyHex: "abcd", EndKeyHex: "", Role: "voter", Count: 1})
 	re.NoError(err)
 	// now default can be deleted.
-	err = manager.DeleteRule("pd", "default")
+	err = manager.DeleteRule("pd", "default", true)
 	re.NoError(err)
 	// cannot change range since it will cause ("abaa", "abcd") has no rules inside.
 	err = manager.SetRule(&Rule{GroupID: "pd", ID: "foo", StartKeyHex: "", EndKeyHex: "abaa", Role: "voter", Count: 1})
@@ -350,7 +400,7 @@ func TestGroupConfig(t *testing.T) {
 	re.Equal([]*RuleGroup{pd1, g2}, manager.GetRuleGroups())
 
 	// delete rule, the group is removed too
-	err = manager.DeleteRule("pd", "default")
+	err = manager.DeleteRule("pd", "default", true)
 	re.NoError(err)
 	re.Equal([]*RuleGroup{g2}, manager.GetRuleGroups())
 }
@@ -373,7 +423,7 @@ func TestRuleVersion(t *testing.T) {
 	newRule = manager.GetRule("g1", "id")
 	re.Equal(uint64(1), newRule.Version)
 	// delete rule
-	err = manager.DeleteRule("g1", "id")
+	err = manager.DeleteRule("g1", "id", false)
 	re.NoError(err)
 	// recreate new rule
 	err = manager.SetRule(newRule)
@@ -489,6 +539,247 @@ func TestCacheManager(t *testing.T) {
 	re.False(manager.IsRegionFitCached(stores, region))
 }
 
+func TestWitnessRole(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, true)
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "witness", Role: Witness, Count: 1}))
+	rule := manager.GetRule("pd", "witness")
+	re.Equal(Witness, rule.Role)
+	re.True(rule.IsWitness)
+	// at most a half of the replicas can be witnesses.
+	re.Error(manager.SetRule(&Rule{GroupID: "pd", ID: "witness", Role: Witness, Count: 2}))
+}
+
+func TestFitStats(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	stores := makeStores()
+	regionMeta := &metapb.Region{
+		Id:          1,
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 0, Version: 0},
+		Peers: []*metapb.Peer{
+			{Id: 11, StoreId: 1111, Role: metapb.PeerRole_Voter},
+			{Id: 12, StoreId: 2111, Role: metapb.PeerRole_Voter},
+			{Id: 13, StoreId: 3111, Role: metapb.PeerRole_Voter},
+		},
+	}
+	region := core.NewRegionInfo(regionMeta, regionMeta.Peers[0])
+	manager.FitRegion(stores, region)
+	re.Empty(manager.FitStats())
+
+	// no store matches the label constraints.
+	rule := &Rule{GroupID: "pd", ID: "zone9", Index: 1, Role: Learner, Count: 1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"zone9"}}}}
+	re.NoError(manager.SetRule(rule))
+	manager.FitRegion(stores, region)
+	re.Equal(map[FitFailureReason]uint64{FitFailureInsufficientStores: 1}, manager.FitStats())
+	re.NoError(manager.DeleteRule("pd", "zone9", false))
+
+	// the peers are taken by the default rule.
+	rule = &Rule{GroupID: "pd", ID: "conflict", Index: 1, Role: Voter, Count: 2}
+	re.NoError(manager.SetRule(rule))
+	manager.FitRegion(stores, region)
+	re.Equal(map[FitFailureReason]uint64{FitFailureInsufficientStores: 1, FitFailureConflictingRule: 1}, manager.FitStats())
+	re.NoError(manager.DeleteRule("pd", "conflict", false))
+
+	// a peer is placed in the tiflash store.
+	rule = manager.GetRule("pd", "default").Clone()
+	rule.LabelConstraints = []LabelConstraint{{Key: "engine", Op: NotIn, Values: []string{"tiflash"}}}
+	re.NoError(manager.SetRule(rule))
+	regionMeta.Peers[2] = &metapb.Peer{Id: 14, StoreId: 1115, Role: metapb.PeerRole_Voter}
+	region = core.NewRegionInfo(regionMeta, regionMeta.Peers[0])
+	manager.FitRegion(stores, region)
+	re.Equal(map[FitFailureReason]uint64{
+		FitFailureInsufficientStores: 1,
+		FitFailureConflictingRule:    1,
+		FitFailureLabelMismatch:      1,
+	}, manager.FitStats())
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	manager.conf.SetPlacementRulesCacheEnabled(true)
+	re.NoError(manager.SetRules(addExtraRules(0)))
+	stores := makeStores()
+
+	newRegion := func(id uint64, start, end string) *core.RegionInfo {
+		meta := &metapb.Region{
+			Id:          id,
+			StartKey:    []byte(start),
+			EndKey:      []byte(end),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 0, Version: 0},
+			Peers: []*metapb.Peer{
+				{Id: id*10 + 1, StoreId: 1111, Role: metapb.PeerRole_Voter},
+				{Id: id*10 + 2, StoreId: 2111, Role: metapb.PeerRole_Voter},
+				{Id: id*10 + 3, StoreId: 3111, Role: metapb.PeerRole_Voter},
+			},
+		}
+		return core.NewRegionInfo(meta, meta.Peers[0])
+	}
+	region1, region2 := newRegion(1, "", "b"), newRegion(2, "b", "")
+	cacheRegions := func() {
+		for _, region := range []*core.RegionInfo{region1, region2} {
+			manager.SetRegionFitCache(region, manager.FitRegion(stores, region))
+		}
+		for i := 0; i < minHitCountToCacheHit; i++ {
+			manager.FitRegion(stores, region1)
+		}
+	}
+	cacheRegions()
+	re.True(manager.CheckIsCachedDirectly(1))
+	re.True(manager.CheckIsCachedDirectly(2))
+	hits, misses := manager.GetFitCacheStats()
+	re.Positive(misses)
+	manager.FitRegion(stores, region1)
+	hits2, misses2 := manager.GetFitCacheStats()
+	re.Equal(hits+1, hits2)
+	re.Equal(misses, misses2)
+
+	// only the cache of the region covered by the changed rule is invalidated.
+	staleFit := manager.FitRegion(stores, region2)
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "r1", StartKeyHex: "63", EndKeyHex: "64", Role: Learner, Count: 1}))
+	re.True(manager.CheckIsCachedDirectly(1))
+	re.False(manager.CheckIsCachedDirectly(2))
+	// the fit calculated with the previous rules is not cached.
+	manager.SetRegionFitCache(region2, staleFit)
+	re.False(manager.CheckIsCachedDirectly(2))
+
+	// all caches are invalidated once a group is changed.
+	re.NoError(manager.DeleteRule("pd", "r1", false))
+	cacheRegions()
+	re.True(manager.CheckIsCachedDirectly(1))
+	re.True(manager.CheckIsCachedDirectly(2))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "pd", Index: 1}))
+	re.False(manager.CheckIsCachedDirectly(1))
+	re.False(manager.CheckIsCachedDirectly(2))
+
+	cacheRegions()
+	manager.FlushFitCache()
+	re.False(manager.CheckIsCachedDirectly(1))
+	re.False(manager.CheckIsCachedDirectly(2))
+}
+
+func TestUnsatisfiableRules(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	z1 := core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1"})
+	z2 := core.NewStoreInfoWithLabel(2, map[string]string{"zone": "z2"})
+	storeSet.PutStore(z1)
+	storeSet.PutStore(z2)
+	opt := mockconfig.NewTestOptions()
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, opt)
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	rule := &Rule{
+		GroupID:          "pd",
+		ID:               "z2",
+		Index:            1,
+		Override:         true,
+		StartKeyHex:      "74",
+		EndKeyHex:        "75",
+		Role:             Voter,
+		Count:            3,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}},
+	}
+	re.NoError(manager.SetRule(rule))
+	re.Empty(manager.CheckUnsatisfiableRules())
+
+	// the learner of another group is kept by the fallback.
+	re.NoError(manager.SetRule(&Rule{
+		GroupID:          "tiflash",
+		ID:               "learner",
+		StartKeyHex:      "74",
+		EndKeyHex:        "75",
+		Role:             Learner,
+		Count:            1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z1"}}},
+	}))
+
+	// the only store matched by the rule is gone.
+	storeSet.DeleteStore(z2)
+	// getting the unsatisfiable rules changes nothing.
+	rules := manager.GetUnsatisfiableRules()
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "z2"}, rules[0].Key())
+	re.Empty(manager.unsatisfiableRules)
+	rules = manager.CheckUnsatisfiableRules()
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "z2"}, rules[0].Key())
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 2)
+	re.Equal("z2", rules[0].ID)
+
+	// only the unsatisfiable group falls back to the default rule once the
+	// fallback is enabled.
+	cfg := opt.GetReplicationConfig().Clone()
+	cfg.EnableUnsatisfiableRuleFallback = true
+	opt.SetReplicationConfig(cfg)
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 2)
+	re.Equal("default", rules[0].ID)
+	re.Equal("learner", rules[1].ID)
+	// the ranges without unsatisfiable rules are not affected.
+	rules = manager.GetRulesForApplyRange(dhex("75"), dhex("76"))
+	re.Len(rules, 1)
+	re.Equal("default", rules[0].ID)
+
+	// the rule takes effect again after the topology can satisfy it.
+	storeSet.PutStore(z2)
+	re.Empty(manager.CheckUnsatisfiableRules())
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 2)
+	re.Equal("z2", rules[0].ID)
+}
+
+func TestValidateAgainstTopology(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	opt := mockconfig.NewTestOptions()
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, opt)
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	rule := &Rule{
+		GroupID:        "a",
+		ID:             "1",
+		Role:           Voter,
+		Count:          3,
+		LocationLabels: []string{"zone", "rack"},
+		LabelConstraints: []LabelConstraint{
+			{Key: "zone", Op: In, Values: []string{"z1"}},
+			{Key: "engine", Op: NotIn, Values: []string{"tiflash"}},
+		},
+	}
+	// there is no store yet.
+	re.NoError(manager.ValidateAgainstTopology(rule))
+
+	storeSet.PutStore(core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1", "host": "h1"}))
+	err := manager.ValidateAgainstTopology(rule)
+	re.Error(err)
+	re.Contains(err.Error(), "location label rack")
+	// the validation is disabled by default.
+	re.NoError(manager.SetRule(rule.Clone()))
+
+	cfg := opt.GetReplicationConfig().Clone()
+	cfg.EnableRuleTopologyValidation = true
+	opt.SetReplicationConfig(cfg)
+	re.Error(manager.SetRule(rule.Clone()))
+	re.Error(manager.SetRules([]*Rule{rule.Clone()}))
+	re.Error(manager.Batch([]RuleOp{{Rule: rule.Clone(), Action: RuleOpAdd}}))
+
+	rule.LocationLabels = []string{"zone", "host"}
+	rule.LabelConstraints = append(rule.LabelConstraints, LabelConstraint{Key: "disk", Op: Exists})
+	err = manager.ValidateAgainstTopology(rule)
+	re.Error(err)
+	re.Contains(err.Error(), "label constraint key disk")
+
+	// the removed stores are ignored.
+	store := core.NewStoreInfoWithLabel(2, map[string]string{"disk": "ssd"})
+	storeSet.PutStore(store.Clone(core.SetStoreState(metapb.StoreState_Tombstone)))
+	re.Error(manager.ValidateAgainstTopology(rule))
+	storeSet.PutStore(store)
+	re.NoError(manager.ValidateAgainstTopology(rule))
+	re.NoError(manager.SetRule(rule))
+}
+
 func dhex(hk string) []byte {
 	k, err := hex.DecodeString(hk)
 	if err != nil {
@@ -496,3 +787,2269 @@ func dhex(hk string) []byte {
 	}
 	return k
 }
+
+func TestPreviewRule(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		cluster.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+	}
+	cluster.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2"}))
+	peers := []*metapb.Peer{
+		{Id: 11, StoreId: 1, Role: metapb.PeerRole_Voter},
+		{Id: 12, StoreId: 2, Role: metapb.PeerRole_Voter},
+		{Id: 13, StoreId: 3, Role: metapb.PeerRole_Voter},
+	}
+	for i, keys := range [][2]string{{"", "74"}, {"74", "75"}, {"75", ""}} {
+		region := &metapb.Region{
+			Id:          uint64(i + 1),
+			StartKey:    dhex(keys[0]),
+			EndKey:      dhex(keys[1]),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+			Peers:       peers,
+		}
+		cluster.PutRegion(core.NewRegionInfo(region, peers[0]))
+	}
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	manager := NewRuleManager(store, cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	newRule := func() *Rule {
+		return &Rule{
+			GroupID:          "pd",
+			ID:               "z2",
+			Index:            1,
+			Override:         true,
+			StartKeyHex:      "74",
+			EndKeyHex:        "75",
+			Role:             Voter,
+			Count:            1,
+			LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}},
+		}
+	}
+	rule := newRule()
+	preview, err := manager.PreviewRule(rule)
+	re.NoError(err)
+	// the rule of the caller is not adjusted.
+	re.Equal(newRule(), rule)
+	re.Equal([]uint64{2}, preview.AffectedRegions)
+	re.Equal(1, preview.AddPeers)
+	re.Equal(3, preview.RemovePeers)
+	re.Empty(preview.RedundantRules)
+	re.Empty(preview.OverriddenBy)
+	// nothing is persisted or applied.
+	re.Nil(manager.GetRule("pd", "z2"))
+	re.Len(manager.GetAllRules(), 1)
+	re.NoError(store.LoadRules(func(k, v string) {
+		re.NotContains(v, "z2")
+	}))
+
+	// the rule is overridden by an override group.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}))
+	preview, err = manager.PreviewRule(newRule())
+	re.NoError(err)
+	re.Empty(preview.AffectedRegions)
+	re.Len(preview.OverriddenBy, 1)
+	re.Equal([2]string{"g", "r"}, preview.OverriddenBy[0].Key())
+
+	// the rule makes all other rules redundant.
+	preview, err = manager.PreviewRule(&Rule{GroupID: "g", ID: "all", Index: 1, Override: true, Role: Voter, Count: 3})
+	re.NoError(err)
+	re.Equal([]uint64{1, 2, 3}, preview.AffectedRegions)
+	re.Zero(preview.AddPeers)
+	re.Zero(preview.RemovePeers)
+	re.Len(preview.RedundantRules, 2)
+	keys := [][2]string{preview.RedundantRules[0].Key(), preview.RedundantRules[1].Key()}
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r"}}, keys)
+	re.Len(manager.GetAllRules(), 2)
+}
+
+func TestPreviewRules(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		cluster.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+	}
+	cluster.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2"}))
+	peers := []*metapb.Peer{
+		{Id: 11, StoreId: 1, Role: metapb.PeerRole_Voter},
+		{Id: 12, StoreId: 2, Role: metapb.PeerRole_Voter},
+		{Id: 13, StoreId: 3, Role: metapb.PeerRole_Voter},
+	}
+	for i, keys := range [][2]string{{"", "74"}, {"74", "75"}, {"75", ""}} {
+		region := &metapb.Region{
+			Id:          uint64(i + 1),
+			StartKey:    dhex(keys[0]),
+			EndKey:      dhex(keys[1]),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+			Peers:       peers,
+		}
+		cluster.PutRegion(core.NewRegionInfo(region, peers[0]))
+	}
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	manager := NewRuleManager(store, cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	newRules := func() []*Rule {
+		return []*Rule{
+			// move a peer of the region 2 to z2.
+			{GroupID: "pd", ID: "z2", Index: 1, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1,
+				LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}}},
+			// the region 3 is split.
+			{GroupID: "pd", ID: "learner", Index: 1, StartKeyHex: "7580", EndKeyHex: "76", Role: Learner, Count: 1},
+		}
+	}
+	rules := newRules()
+	preview, err := manager.PreviewRules(rules)
+	re.NoError(err)
+	// the rules of the caller are not adjusted.
+	re.Equal(newRules(), rules)
+	re.Len(preview.Rules, 2)
+	re.Equal([]*PeerMovement{
+		{RegionID: 2, AddPeers: 1},
+		{RegionID: 3, Split: true},
+	}, preview.Movements)
+	re.Equal(1, preview.AddPeers)
+	re.Zero(preview.RemovePeers)
+	re.Empty(preview.RedundantRules)
+	// nothing is persisted or applied.
+	re.Len(manager.GetAllRules(), 1)
+
+	// the rule replacing the default rule makes nothing redundant.
+	preview, err = manager.PreviewRules([]*Rule{{GroupID: "pd", ID: "default", Role: Voter, Count: 2}})
+	re.NoError(err)
+	re.Empty(preview.RedundantRules)
+	re.Equal(3, preview.RemovePeers)
+	re.Len(preview.Movements, 3)
+
+	_, err = manager.PreviewRules([]*Rule{{GroupID: "pd", ID: "invalid", Role: Voter, Count: 0}})
+	re.Error(err)
+}
+
+type failedRuleStorage struct {
+	endpoint.RuleStorage
+	failedKey string
+}
+
+func (s *failedRuleStorage) SaveRule(txn kv.Txn, ruleKey string, rule interface{}) error {
+	if ruleKey == s.failedKey {
+		return errors.New("failed to save rule")
+	}
+	return s.RuleStorage.SaveRule(txn, ruleKey, rule)
+}
+
+func (s *failedRuleStorage) SaveRuleGroup(txn kv.Txn, groupID string, group interface{}) error {
+	if groupID == s.failedKey {
+		return errors.New("failed to save rule group")
+	}
+	return s.RuleStorage.SaveRuleGroup(txn, groupID, group)
+}
+
+func TestBatchAtomic(t *testing.T) {
+	re := require.New(t)
+	store := &failedRuleStorage{RuleStorage: endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)}
+	manager := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone", "rack", "host"}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	loadRuleKeys := func() []string {
+		var keys []string
+		re.NoError(store.LoadRules(func(k, _ string) { keys = append(keys, k) }))
+		return keys
+	}
+	defaultKey := (&Rule{GroupID: "pd", ID: "default"}).StoreKey()
+	re.Equal([]string{defaultKey}, loadRuleKeys())
+
+	// the batch is rejected by the validation of the whole batch.
+	err := manager.Batch([]RuleOp{
+		{Rule: &Rule{GroupID: "g", ID: "a", Role: Leader, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "g", ID: "b", Role: Leader, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "pd", ID: "default"}, Action: RuleOpDel},
+	})
+	re.Error(err)
+	re.Equal([]string{defaultKey}, loadRuleKeys())
+	re.Len(manager.GetAllRules(), 1)
+
+	// the batch is rejected when any of the updates fails to be saved.
+	store.failedKey = (&Rule{GroupID: "g", ID: "b"}).StoreKey()
+	err = manager.Batch([]RuleOp{
+		{Rule: &Rule{GroupID: "g", ID: "a", Role: Voter, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "g", ID: "b", Role: Voter, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "pd", ID: "default"}, Action: RuleOpDel},
+	})
+	re.Error(err)
+	re.Equal([]string{defaultKey}, loadRuleKeys())
+	rules := manager.GetAllRules()
+	re.Len(rules, 1)
+	re.Equal("default", rules[0].ID)
+	re.Len(manager.GetRulesForApplyRange([]byte{}, []byte{}), 1)
+
+	// the rejected group update does not affect the current rules.
+	store.failedKey = "pd"
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "pd", Index: 2, Override: true}))
+	re.Equal(0, manager.GetRulesForApplyRange([]byte{}, []byte{})[0].groupIndex())
+	re.Equal(0, manager.GetRuleGroup("pd").Index)
+
+	store.failedKey = ""
+	// the default rule can't be deleted by the batch.
+	err = manager.Batch([]RuleOp{
+		{Rule: &Rule{GroupID: "g", ID: "a", Role: Voter, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "g", ID: "b", Role: Voter, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "pd", ID: "default"}, Action: RuleOpDel},
+	})
+	re.True(errs.ErrDefaultRuleProtected.Equal(err))
+	re.Equal([]string{defaultKey}, loadRuleKeys())
+
+	re.NoError(manager.Batch([]RuleOp{
+		{Rule: &Rule{GroupID: "g", ID: "a", Role: Voter, Count: 1}, Action: RuleOpAdd},
+		{Rule: &Rule{GroupID: "g", ID: "b", Role: Voter, Count: 1}, Action: RuleOpAdd},
+	}))
+	re.ElementsMatch([]string{
+		defaultKey,
+		(&Rule{GroupID: "g", ID: "a"}).StoreKey(),
+		(&Rule{GroupID: "g", ID: "b"}).StoreKey(),
+	}, loadRuleKeys())
+
+	// the batch exceeding one transaction is rejected as a whole.
+	todo := make([]RuleOp, 0, maxEtcdTxnOps+1)
+	for i := 0; i <= maxEtcdTxnOps; i++ {
+		todo = append(todo, RuleOp{Rule: &Rule{GroupID: "g", ID: fmt.Sprintf("r%d", i), Role: Voter, Count: 1}, Action: RuleOpAdd})
+	}
+	re.True(errs.ErrRuleContent.Equal(manager.Batch(todo)))
+	re.Len(loadRuleKeys(), 3)
+	re.Len(manager.GetAllRules(), 3)
+}
+
+func TestCheckConflicts(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		storeSet.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+	}
+	storeSet.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2"}))
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	re.Empty(manager.CheckConflicts())
+
+	z2 := []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}}
+	re.NoError(manager.SetRule(&Rule{GroupID: "g1", ID: "a", StartKeyHex: "74", EndKeyHex: "76", Role: Voter, Count: 1, LabelConstraints: z2}))
+	re.Empty(manager.CheckConflicts())
+	// both rules need a peer in the only store of z2.
+	re.NoError(manager.SetRule(&Rule{GroupID: "g2", ID: "b", StartKeyHex: "75", EndKeyHex: "77", Role: Voter, Count: 1, LabelConstraints: z2}))
+	conflicts := manager.CheckConflicts()
+	re.Len(conflicts, 1)
+	re.Equal([2]string{"g1", "a"}, conflicts[0].Rule1)
+	re.Equal([2]string{"g2", "b"}, conflicts[0].Rule2)
+	re.Equal("75", conflicts[0].StartKeyHex)
+	re.Equal("76", conflicts[0].EndKeyHex)
+	re.Contains(conflicts[0].Reason, "need 2 peers in total but only 1 stores match")
+
+	// the conflict is gone after the ranges do not overlap.
+	re.NoError(manager.SetRule(&Rule{GroupID: "g2", ID: "b", StartKeyHex: "76", EndKeyHex: "77", Role: Voter, Count: 1, LabelConstraints: z2}))
+	re.Empty(manager.CheckConflicts())
+	// the overridden rule does not conflict with others.
+	re.NoError(manager.SetRule(&Rule{GroupID: "g2", ID: "b", StartKeyHex: "75", EndKeyHex: "77", Role: Voter, Count: 1, LabelConstraints: z2}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g2", Index: 1, Override: true}))
+	re.Empty(manager.CheckConflicts())
+}
+
+func TestCheckRuleConflicts(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	re.NoError(manager.ForceSetRule(&Rule{GroupID: "g1", ID: "a", StartKeyHex: "74", EndKeyHex: "77", Role: Learner, Count: 3}))
+	re.NoError(manager.ForceSetRule(&Rule{GroupID: "g1", ID: "b", StartKeyHex: "75", EndKeyHex: "77", Role: Learner, Count: 2}))
+	z3 := []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z3"}}}
+	re.NoError(manager.ForceSetRule(&Rule{GroupID: "g2", ID: "c", StartKeyHex: "76", EndKeyHex: "78", Role: Learner, Count: 1, LabelConstraints: z3}))
+	// nothing is reported without stores.
+	report := manager.CheckRuleConflicts()
+	re.Empty(report.OverloadedGroups)
+	re.Empty(report.UnsatisfiableRules)
+
+	for i := uint64(1); i <= 2; i++ {
+		storeSet.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+		storeSet.PutStore(core.NewStoreInfoWithLabel(i+2, map[string]string{"zone": "z2"}))
+	}
+	report = manager.CheckRuleConflicts()
+	re.Equal(manager.CheckConflicts(), report.Conflicts)
+	re.NotEmpty(report.Conflicts)
+	re.Equal([][2]string{{"g2", "c"}}, report.UnsatisfiableRules)
+	// the overloaded ranges split by the rule of g2 are merged.
+	re.Len(report.OverloadedGroups, 1)
+	re.Equal(GroupOverload{
+		GroupID:     "g1",
+		StartKey:    []byte{0x75},
+		StartKeyHex: "75",
+		EndKey:      []byte{0x77},
+		EndKeyHex:   "77",
+		Count:       5,
+		Stores:      4,
+	}, report.OverloadedGroups[0])
+	// the unsatisfiable rules are not recorded by the analysis.
+	re.Empty(manager.unsatisfiableRules)
+}
+
+func TestRuleUsage(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	stores := makeStores()
+	makeVoters := func(id uint64, storeIDs ...uint64) *core.RegionInfo {
+		meta := &metapb.Region{Id: id, RegionEpoch: &metapb.RegionEpoch{}}
+		for i, storeID := range storeIDs {
+			meta.Peers = append(meta.Peers, &metapb.Peer{Id: id*10 + uint64(i), StoreId: storeID, Role: metapb.PeerRole_Voter})
+		}
+		return core.NewRegionInfo(meta, meta.Peers[0])
+	}
+	region1 := makeVoters(1, 1111, 2111, 3111)
+	region2 := makeVoters(2, 1111, 2111)
+	manager.FitRegion(stores, region1)
+	manager.FitRegion(stores, region2)
+	re.Equal(map[string]RuleStats{"pd/default": {Matched: 2, Satisfied: 1}}, manager.RuleUsage())
+
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "learner", Index: 1, Role: Learner, Count: 1}))
+	// the region is counted by the rules at its last fit.
+	for i := 0; i < 2; i++ {
+		manager.FitRegion(stores, region1)
+	}
+	re.Equal(map[string]RuleStats{
+		"pd/default": {Matched: 2, Satisfied: 1},
+		"pd/learner": {Matched: 1, Satisfied: 0},
+	}, manager.RuleUsage())
+
+	manager.ClearDefunctRegion(2)
+	re.NoError(manager.DeleteRule("pd", "learner", false))
+	re.Equal(map[string]RuleStats{"pd/default": {Matched: 1, Satisfied: 1}}, manager.RuleUsage())
+
+	manager.ResetRuleUsage()
+	re.Empty(manager.RuleUsage())
+}
+
+func TestExportImport(t *testing.T) {
+	re := require.New(t)
+	_, source := newTestManager(t, false)
+	re.NoError(source.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	re.NoError(source.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1}))
+	data, err := source.Export()
+	re.NoError(err)
+
+	store := &failedRuleStorage{RuleStorage: endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)}
+	target := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(target.Initialize(3, []string{"zone", "rack", "host"}))
+	re.NoError(target.SetRule(&Rule{GroupID: "x", ID: "y", Role: Voter, Count: 1}))
+	getRuleKeys := func() [][2]string {
+		var keys [][2]string
+		for _, r := range target.GetAllRules() {
+			keys = append(keys, r.Key())
+		}
+		return keys
+	}
+
+	// the incompatible bundle is rejected.
+	re.Error(target.Import([]byte(`{"version":0}`), ImportMerge))
+	re.Error(target.Import([]byte(`{"version":2}`), ImportMerge))
+	re.Error(target.Import(data, ImportMode("unknown")))
+	// the import is rejected as a whole when any of the updates fails to be saved.
+	store.failedKey = "g"
+	re.Error(target.Import(data, ImportReplace))
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"x", "y"}}, getRuleKeys())
+	re.Nil(target.GetRuleGroup("g"))
+	store.failedKey = ""
+
+	re.NoError(target.Import(data, ImportMerge))
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r"}, {"x", "y"}}, getRuleKeys())
+	re.Equal(&RuleGroup{ID: "g", Index: 10, Override: true}, target.GetRuleGroup("g"))
+	re.Equal([]byte("t"), target.GetRule("g", "r").StartKey)
+
+	re.NoError(target.Import(data, ImportReplace))
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r"}}, getRuleKeys())
+	exported, err := target.Export()
+	re.NoError(err)
+	var bundle RuleBundle
+	re.NoError(json.Unmarshal(exported, &bundle))
+	re.Equal(RuleBundleVersion, bundle.Version)
+	re.Equal([]*RuleGroup{{ID: "g", Index: 10, Override: true}}, bundle.Groups)
+	re.Len(bundle.Rules, 2)
+}
+
+func TestExportImportPlacementBundle(t *testing.T) {
+	re := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sourceStore, source := newTestManager(t, false)
+	sourceLabeler, err := labeler.NewRegionLabeler(ctx, sourceStore, time.Hour)
+	re.NoError(err)
+	re.NoError(source.SetRuleGroup(&RuleGroup{ID: "g", Index: 10}))
+	re.NoError(source.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+	re.NoError(sourceLabeler.SetLabelRule(&labeler.LabelRule{ID: "l1", Labels: []labeler.RegionLabel{{Key: "k", Value: "v"}}, RuleType: labeler.KeyRange, Data: labeler.MakeKeyRanges("74", "75")}))
+	data, err := ExportPlacementBundle(source, sourceLabeler)
+	re.NoError(err)
+
+	storeSet := core.NewBasicCluster()
+	storeSet.PutStore(core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1", "rack": "r1", "host": "h1"}))
+	store := &failedRuleStorage{RuleStorage: endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)}
+	target := NewRuleManager(store, storeSet, mockconfig.NewTestOptions())
+	re.NoError(target.Initialize(3, []string{"zone", "rack", "host"}))
+	targetLabeler, err := labeler.NewRegionLabeler(ctx, store, time.Hour)
+	re.NoError(err)
+	re.NoError(targetLabeler.SetLabelRule(&labeler.LabelRule{ID: "old", Labels: []labeler.RegionLabel{{Key: "k", Value: "v"}}, RuleType: labeler.KeyRange, Data: labeler.MakeKeyRanges("76", "77")}))
+	getLabelRuleIDs := func(rl *labeler.RegionLabeler) []string {
+		var ids []string
+		for _, r := range rl.GetAllLabelRules() {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	}
+
+	// the rules are validated against the store topology.
+	err = ImportPlacementBundle(target, targetLabeler, []byte(`{"version":1,"rules":[{"group_id":"g","id":"z","role":"voter","count":1,"location_labels":["dc"]}]}`), ImportMerge)
+	re.True(errs.ErrRuleContent.Equal(err))
+	re.Nil(target.GetRule("g", "z"))
+	// neither the rules nor the label rules are saved if any of them fails.
+	store.failedKey = "g"
+	re.Error(ImportPlacementBundle(target, targetLabeler, data, ImportReplace))
+	re.Nil(target.GetRule("g", "r"))
+	re.Equal([]string{"old"}, getLabelRuleIDs(targetLabeler))
+	reloaded, err := labeler.NewRegionLabeler(ctx, store, time.Hour)
+	re.NoError(err)
+	re.Equal([]string{"old"}, getLabelRuleIDs(reloaded))
+	store.failedKey = ""
+
+	re.NoError(ImportPlacementBundle(target, targetLabeler, data, ImportReplace))
+	re.NotNil(target.GetRule("g", "r"))
+	re.Equal(10, target.GetRuleGroup("g").Index)
+	re.Equal([]string{"l1"}, getLabelRuleIDs(targetLabeler))
+	reloaded, err = labeler.NewRegionLabeler(ctx, store, time.Hour)
+	re.NoError(err)
+	re.Equal([]string{"l1"}, getLabelRuleIDs(reloaded))
+	exported, err := ExportPlacementBundle(target, targetLabeler)
+	re.NoError(err)
+	var bundle PlacementBundle
+	re.NoError(json.Unmarshal(exported, &bundle))
+	re.Len(bundle.Rules, 2)
+	re.Len(bundle.LabelRules, 1)
+}
+
+func TestExportImportGroup(t *testing.T) {
+	re := require.New(t)
+	_, source := newTestManager(t, false)
+	re.NoError(source.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	re.NoError(source.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1}))
+	re.NoError(source.SetRule(&Rule{GroupID: "g", ID: "r2", StartKeyHex: "75", EndKeyHex: "76", Role: Voter, Count: 1}))
+	re.NoError(source.SetRule(&Rule{GroupID: "other", ID: "r", Role: Voter, Count: 1}))
+	_, err := source.ExportGroup("unknown")
+	re.True(errs.ErrRuleGroupNotFound.Equal(err))
+	data, err := source.ExportGroup("g")
+	re.NoError(err)
+	var bundle RuleBundle
+	re.NoError(json.Unmarshal(data, &bundle))
+	re.Equal([]*RuleGroup{{ID: "g", Index: 10, Override: true}}, bundle.Groups)
+	re.Len(bundle.Rules, 2)
+	// the group with the default configuration is exported by its rules.
+	_, err = source.ExportGroup("other")
+	re.NoError(err)
+
+	_, target := newTestManager(t, false)
+	re.NoError(target.SetRule(&Rule{GroupID: "g", ID: "r3", Role: Voter, Count: 1}))
+	re.NoError(target.SetRule(&Rule{GroupID: "x", ID: "y", Role: Voter, Count: 1}))
+	getRuleKeys := func() [][2]string {
+		var keys [][2]string
+		for _, r := range target.GetAllRules() {
+			keys = append(keys, r.Key())
+		}
+		return keys
+	}
+	// the bundle of the other groups is rejected.
+	re.Error(target.ImportGroup("x", data, ImportMerge))
+
+	re.NoError(target.ImportGroup("g", data, ImportMerge))
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r1"}, {"g", "r2"}, {"g", "r3"}, {"x", "y"}}, getRuleKeys())
+	re.Equal(&RuleGroup{ID: "g", Index: 10, Override: true}, target.GetRuleGroup("g"))
+
+	re.NoError(target.ImportGroup("g", data, ImportReplace))
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r1"}, {"g", "r2"}, {"x", "y"}}, getRuleKeys())
+}
+
+func TestRestore(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1}))
+	re.NoError(store.SaveSnapshot("s"))
+
+	re.NoError(manager.DeleteRule("g", "r", false))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g2", Index: 1}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5}))
+	re.NoError(manager.Restore(func() error { return store.RestoreSnapshot("s") }))
+	keys := make([][2]string, 0)
+	for _, r := range manager.GetAllRules() {
+		keys = append(keys, r.Key())
+	}
+	re.ElementsMatch([][2]string{{"pd", "default"}, {"g", "r"}}, keys)
+	re.Nil(manager.GetRuleGroup("g2"))
+	rule := manager.GetRule("pd", "default")
+	re.Equal(3, rule.Count)
+	// the version of the reverted rule is bumped.
+	re.Equal(uint64(2), rule.Version)
+	re.Len(manager.GetRulesForApplyRange([]byte("t"), []byte("u")), 2)
+
+	// the rules are kept if the restore fails.
+	re.Error(manager.Restore(func() error { return store.RestoreSnapshot("unknown") }))
+	re.Len(manager.GetAllRules(), 2)
+}
+
+func TestKeyspaceRules(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	keyspaceID := func(id uint32) *uint32 { return &id }
+	ks0, ks1, ks2 := keyspaceutil.MakeRegionBound(0), keyspaceutil.MakeRegionBound(1), keyspaceutil.MakeRegionBound(2)
+
+	// the rule scoped to keyspace 1 can not cover the range of keyspace 2.
+	err := manager.SetRule(&Rule{
+		GroupID: "pd", ID: "ks1", KeyspaceID: keyspaceID(1), Role: Voter, Count: 1, Override: true, Index: 1,
+		StartKeyHex: hex.EncodeToString(ks1.TxnLeftBound), EndKeyHex: hex.EncodeToString(ks2.TxnRightBound),
+	})
+	re.ErrorContains(err, "inside keyspace 1")
+	re.NoError(manager.SetRule(&Rule{
+		GroupID: "pd", ID: "ks1", KeyspaceID: keyspaceID(1), Role: Voter, Count: 1, Override: true, Index: 1,
+		StartKeyHex: hex.EncodeToString(ks1.TxnLeftBound), EndKeyHex: hex.EncodeToString(ks1.TxnRightBound),
+	}))
+	re.Len(manager.GetKeyspaceRules(1), 1)
+
+	region := core.NewRegionInfo(&metapb.Region{StartKey: ks1.TxnLeftBound, EndKey: ks1.TxnRightBound}, nil)
+	rules := manager.GetRulesForApplyRegion(region)
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "ks1"}, rules[0].Key())
+	rules = manager.GetRulesForApplyRange(ks1.TxnLeftBound, ks1.TxnRightBound)
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "ks1"}, rules[0].Key())
+	region = core.NewRegionInfo(&metapb.Region{StartKey: ks2.TxnLeftBound, EndKey: ks2.TxnRightBound}, nil)
+	rules = manager.GetRulesForApplyRegion(region)
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "default"}, rules[0].Key())
+	// the rules of other keyspaces are dropped and fall back to the default rule.
+	for _, startKey := range [][]byte{ks2.TxnLeftBound, []byte("t")} {
+		rules = manager.filterKeyspaceRules(startKey, []*Rule{manager.GetRule("pd", "ks1")})
+		re.Len(rules, 1)
+		re.Equal([2]string{"pd", "default"}, rules[0].Key())
+	}
+
+	// the inactive default rule is not used as the fallback.
+	defaultRule := manager.ruleConfig.getRule([2]string{"pd", "default"})
+	disabled, future := false, time.Now().Add(time.Hour)
+	defaultRule.Enabled = &disabled
+	re.Empty(manager.filterKeyspaceRules(ks2.TxnLeftBound, []*Rule{manager.GetRule("pd", "ks1")}))
+	defaultRule.Enabled, defaultRule.ActiveFrom = nil, &future
+	re.Empty(manager.filterKeyspaceRules(ks2.TxnLeftBound, []*Rule{manager.GetRule("pd", "ks1")}))
+	defaultRule.ActiveFrom = nil
+	re.Len(manager.filterKeyspaceRules(ks2.TxnLeftBound, []*Rule{manager.GetRule("pd", "ks1")}), 1)
+
+	// the rule scoped to keyspace 0 is only applied to the regions of keyspace 0.
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "ks0", KeyspaceID: keyspaceID(0), Role: Voter, Count: 1, Override: true, Index: 1}))
+	re.Len(manager.GetKeyspaceRules(0), 1)
+	rules = manager.GetRulesForApplyRange(ks0.TxnLeftBound, ks0.TxnRightBound)
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "ks0"}, rules[0].Key())
+	rules = manager.filterKeyspaceRules([]byte("t"), []*Rule{manager.GetRule("pd", "ks0")})
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "default"}, rules[0].Key())
+
+	re.Error(manager.DeleteKeyspaceRules(0))
+	re.NoError(manager.DeleteKeyspaceRules(1))
+	re.Empty(manager.GetKeyspaceRules(1))
+	re.Len(manager.GetAllRules(), 2)
+}
+
+func TestKeyspaceRuleBinding(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	keyspaceID := func(id uint32) *uint32 { return &id }
+	ks1 := keyspaceutil.MakeRegionBound(1)
+	// the rule without a range is bound to the txn range of the keyspace by default.
+	re.NoError(manager.SetRule(&Rule{GroupID: "ks", ID: "txn", KeyspaceID: keyspaceID(1), Role: Learner, Count: 1}))
+	r := manager.GetRule("ks", "txn")
+	re.Equal(ks1.TxnLeftBound, r.StartKey)
+	re.Equal(ks1.TxnRightBound, r.EndKey)
+	re.Equal(hex.EncodeToString(ks1.TxnLeftBound), r.StartKeyHex)
+	re.NoError(manager.SetRule(&Rule{GroupID: "ks", ID: "raw", KeyspaceID: keyspaceID(1), KeyspaceMode: KeyspaceModeRaw, Role: Learner, Count: 1}))
+	r = manager.GetRule("ks", "raw")
+	re.Equal(ks1.RawLeftBound, r.StartKey)
+	re.Equal(ks1.RawRightBound, r.EndKey)
+	re.Len(manager.GetKeyspaceRules(1), 2)
+
+	for _, rule := range []*Rule{
+		{GroupID: "ks", ID: "bad", KeyspaceID: keyspaceID(1), KeyspaceMode: "unknown", Role: Learner, Count: 1},
+		{GroupID: "ks", ID: "bad", KeyspaceMode: KeyspaceModeRaw, Role: Learner, Count: 1},
+		{GroupID: "ks", ID: "bad", KeyspaceID: keyspaceID(maxKeyspaceID + 1), Role: Learner, Count: 1},
+	} {
+		re.True(errs.ErrRuleContent.Equal(manager.SetRule(rule)))
+		re.NotEmpty(ValidateRule(rule))
+	}
+	re.Nil(manager.GetRule("ks", "bad"))
+	re.Empty(ValidateRule(&Rule{GroupID: "ks", ID: "ok", KeyspaceID: keyspaceID(2), Role: Learner, Count: 1}))
+}
+
+func TestRuleOrderStable(t *testing.T) {
+	re := require.New(t)
+	stores := makeStores()
+	region := makeRegion("1111_leader,1211,2111,2211,3111")
+	newRules := func() []*Rule {
+		return []*Rule{
+			{GroupID: "pd", ID: "default", Role: Voter, Count: 1},
+			{GroupID: "g", ID: "c", Index: 1, Role: Voter, Count: 1},
+			{GroupID: "g", ID: "a", Index: 1, Role: Voter, Count: 2, LocationLabels: []string{"zone", "rack"}},
+			{GroupID: "g", ID: "b", Index: 1, Role: Follower, Count: 1, LabelConstraints: []LabelConstraint{
+				{Key: "zone", Op: In, Values: []string{"zone1", "zone2"}},
+			}},
+			{GroupID: "h", ID: "a", Role: Voter, Count: 1},
+		}
+	}
+	fitResult := func(fit *RegionFit) []string {
+		var result []string
+		for _, rf := range fit.RuleFits {
+			var peers []string
+			for _, p := range rf.Peers {
+				peers = append(peers, strconv.FormatUint(p.GetId(), 10))
+			}
+			result = append(result, fmt.Sprintf("%s/%s:%s", rf.Rule.GroupID, rf.Rule.ID, strings.Join(peers, ",")))
+		}
+		for _, p := range fit.OrphanPeers {
+			result = append(result, fmt.Sprintf("orphan:%d", p.GetId()))
+		}
+		return result
+	}
+
+	var expected []string
+	for i := 0; i < 20; i++ {
+		rules := newRules()
+		rand.Shuffle(len(rules), func(i, j int) { rules[i], rules[j] = rules[j], rules[i] })
+		store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+		manager := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+		re.NoError(manager.Initialize(3, []string{"zone", "rack", "host"}))
+		for _, r := range rules {
+			re.NoError(manager.SetRule(r))
+		}
+		// restart to load the rules from storage.
+		manager = NewRuleManager(store, nil, mockconfig.NewTestOptions())
+		re.NoError(manager.Initialize(3, []string{"zone", "rack", "host"}))
+		result := fitResult(manager.FitRegion(stores, region))
+		if expected == nil {
+			expected = result
+			continue
+		}
+		re.Equal(expected, result)
+	}
+	re.Equal([]string{"g/a", "g/b", "g/c", "h/a", "pd/default"}, func() []string {
+		var keys []string
+		for _, s := range expected {
+			if !strings.HasPrefix(s, "orphan") {
+				keys = append(keys, strings.Split(s, ":")[0])
+			}
+		}
+		return keys
+	}())
+}
+
+func TestRuleTemplate(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+
+	template := &RuleTemplate{ID: "tpl", GroupID: "ks", Role: Voter, Count: 3, LocationLabels: []string{"zone"}}
+	re.Error(manager.SetRuleTemplate(&RuleTemplate{GroupID: "ks", Role: Voter, Count: 3}))
+	re.Error(manager.SetRuleTemplate(&RuleTemplate{ID: "bad", GroupID: "ks", Role: Voter}))
+	re.Error(manager.InstantiateTemplate("tpl", []core.KeyRange{{StartKey: []byte("a"), EndKey: []byte("b")}}))
+	re.NoError(manager.SetRuleTemplate(template))
+
+	ranges := []core.KeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("b")},
+		{StartKey: []byte("c"), EndKey: []byte("d")},
+		{StartKey: []byte("e"), EndKey: []byte("f")},
+	}
+	re.NoError(manager.InstantiateTemplate("tpl", ranges))
+	rules := manager.GetTemplateRules("tpl")
+	re.Len(rules, 3)
+	for i, r := range rules {
+		re.Equal(fmt.Sprintf("tpl-%d", i), r.ID)
+		re.Equal("tpl", r.TemplateID)
+		re.Equal(ranges[i].StartKey, r.StartKey)
+		re.Equal(ranges[i].EndKey, r.EndKey)
+		re.Equal(3, r.Count)
+	}
+	re.Len(manager.GetAllRules(), 4)
+
+	// updating the template updates all the derived rules.
+	template = &RuleTemplate{ID: "tpl", GroupID: "ks", Role: Voter, Count: 5, LocationLabels: []string{"zone"}}
+	re.NoError(manager.SetRuleTemplate(template))
+	for i, r := range manager.GetTemplateRules("tpl") {
+		re.Equal(5, r.Count)
+		re.Equal(ranges[i].StartKey, r.StartKey)
+	}
+
+	// re-instantiating replaces the derived rules.
+	re.NoError(manager.InstantiateTemplate("tpl", ranges[:1]))
+	re.Len(manager.GetTemplateRules("tpl"), 1)
+	re.Len(manager.GetAllRules(), 2)
+
+	// the rule not derived from the template can not be overwritten.
+	re.NoError(manager.SetRule(&Rule{GroupID: "ks", ID: "tpl-1", StartKeyHex: "aa", EndKeyHex: "bb", Role: Voter, Count: 3}))
+	re.Error(manager.InstantiateTemplate("tpl", ranges))
+	re.Len(manager.GetTemplateRules("tpl"), 1)
+
+	// the templates and derived rules are persisted.
+	m2 := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(m2.Initialize(3, []string{"zone", "rack", "host"}))
+	re.Equal(template, m2.GetRuleTemplate("tpl"))
+	re.Len(m2.GetTemplateRules("tpl"), 1)
+
+	re.NoError(manager.DeleteTemplateRules("tpl"))
+	re.Empty(manager.GetTemplateRules("tpl"))
+	re.NotNil(manager.GetRuleTemplate("tpl"))
+	re.NoError(manager.InstantiateTemplate("tpl", ranges[2:]))
+	re.NoError(manager.DeleteRuleTemplate("tpl"))
+	re.Nil(manager.GetRuleTemplate("tpl"))
+	re.Empty(manager.GetTemplateRules("tpl"))
+	re.Len(manager.GetAllRules(), 2)
+	re.Empty(manager.GetRuleTemplates())
+}
+
+func TestRuleTemplateVariables(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	for i, zone := range []string{"z1", "z2", "z2"} {
+		storeSet.PutStore(core.NewStoreInfoWithLabel(uint64(i+1), map[string]string{"zone": zone, "disk": "ssd"}))
+	}
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	template := &RuleTemplate{
+		ID:      "tpl",
+		GroupID: "ks",
+		Role:    Follower,
+		Count:   1,
+		LabelConstraints: []LabelConstraint{
+			{Key: "zone", Op: In, Values: []string{"${zone}"}},
+			{Key: "disk", Op: In, Values: []string{"${disk}"}},
+		},
+	}
+	re.Equal([]string{"disk", "zone"}, template.variables())
+	re.NoError(manager.SetRuleTemplate(template))
+	ranges := []core.KeyRange{
+		{StartKey: []byte("a"), EndKey: []byte("b")},
+		{StartKey: []byte("c"), EndKey: []byte("d")},
+	}
+	re.NoError(manager.InstantiateTemplate("tpl", ranges))
+	rules := manager.GetTemplateRules("tpl")
+	re.Len(rules, 4)
+	for i, r := range rules {
+		zone := []string{"z1", "z2"}[i%2]
+		re.Equal(fmt.Sprintf("tpl-%d-ssd-%s", i/2, zone), r.ID)
+		re.Equal(ranges[i/2].StartKey, r.StartKey)
+		re.Equal(map[string]string{"zone": zone, "disk": "ssd"}, r.TemplateVars)
+		re.Equal([]string{zone}, r.LabelConstraints[0].Values)
+		re.Equal([]string{"ssd"}, r.LabelConstraints[1].Values)
+	}
+	// the template itself is not substituted.
+	re.Equal([]string{"${zone}"}, manager.GetRuleTemplate("tpl").LabelConstraints[0].Values)
+
+	// the rules of a new zone are added by refreshing.
+	added, err := manager.refreshTemplateRules()
+	re.NoError(err)
+	re.Zero(added)
+	storeSet.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z3", "disk": "ssd"}))
+	added, err = manager.refreshTemplateRules()
+	re.NoError(err)
+	re.Equal(2, added)
+	rules = manager.GetTemplateRules("tpl")
+	re.Len(rules, 6)
+	re.Equal("tpl-0-ssd-z3", rules[2].ID)
+	re.Equal(ranges[0].EndKey, rules[2].EndKey)
+	re.Equal("tpl-1-ssd-z3", rules[5].ID)
+	re.Equal(ranges[1].EndKey, rules[5].EndKey)
+
+	// the rules of a gone zone are kept by refreshing, but removed by updating
+	// the template.
+	storeSet.DeleteStore(storeSet.GetStore(1))
+	added, err = manager.refreshTemplateRules()
+	re.NoError(err)
+	re.Zero(added)
+	re.Len(manager.GetTemplateRules("tpl"), 6)
+	template.Index = 1
+	re.NoError(manager.SetRuleTemplate(template))
+	rules = manager.GetTemplateRules("tpl")
+	re.Len(rules, 4)
+	for _, r := range rules {
+		re.NotEqual("z1", r.TemplateVars["zone"])
+		re.Equal(1, r.Index)
+	}
+
+	// the template without variables derives one rule for each range again.
+	template.LabelConstraints = nil
+	re.NoError(manager.SetRuleTemplate(template))
+	rules = manager.GetTemplateRules("tpl")
+	re.Len(rules, 2)
+	re.Equal("tpl-0", rules[0].ID)
+	re.Equal("tpl-1", rules[1].ID)
+	re.Empty(rules[1].TemplateVars)
+
+	// the template can't be instantiated if no store has the labels.
+	re.NoError(manager.SetRuleTemplate(&RuleTemplate{
+		ID:               "rack",
+		GroupID:          "ks",
+		Role:             Follower,
+		Count:            1,
+		LabelConstraints: []LabelConstraint{{Key: "rack", Op: In, Values: []string{"${rack}"}}},
+	}))
+	re.Error(manager.InstantiateTemplate("rack", ranges))
+	re.Empty(manager.GetTemplateRules("rack"))
+}
+
+func TestReconcile(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRules([]*Rule{
+		{GroupID: "g", ID: "a", StartKeyHex: "11", EndKeyHex: "22", Role: Voter, Count: 3},
+		{GroupID: "g", ID: "b", StartKeyHex: "22", EndKeyHex: "33", Role: Voter, Count: 3},
+	}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 5}))
+	discrepancies, err := manager.Reconcile(store)
+	re.NoError(err)
+	re.Empty(discrepancies)
+
+	// diverge the storage from the rule manager.
+	re.NoError(store.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		r := manager.GetRule("g", "a")
+		r.Count = 5
+		if err := store.SaveRule(txn, r.StoreKey(), r); err != nil {
+			return err
+		}
+		if err := store.DeleteRule(txn, (&Rule{GroupID: "g", ID: "b"}).StoreKey()); err != nil {
+			return err
+		}
+		extra := &Rule{GroupID: "g", ID: "c", StartKeyHex: "33", EndKeyHex: "44", Role: Voter, Count: 3}
+		if err := store.SaveRule(txn, extra.StoreKey(), extra); err != nil {
+			return err
+		}
+		return store.SaveRuleGroup(txn, "g", &RuleGroup{ID: "g", Index: 6})
+	}))
+	discrepancies, err = manager.Reconcile(store)
+	re.NoError(err)
+	re.Len(discrepancies, 4)
+	re.Equal(DiscrepancyDiffering, discrepancies[0].Type)
+	re.Equal((&Rule{GroupID: "g", ID: "a"}).StoreKey(), discrepancies[0].Key)
+	re.Equal([]FieldChange{{Field: "count", Old: 3, New: 5}}, discrepancies[0].Changes)
+	re.Equal(DiscrepancyMissing, discrepancies[1].Type)
+	re.Equal((&Rule{GroupID: "g", ID: "b"}).StoreKey(), discrepancies[1].Key)
+	re.Equal(DiscrepancyExtra, discrepancies[2].Type)
+	re.Equal((&Rule{GroupID: "g", ID: "c"}).StoreKey(), discrepancies[2].Key)
+	re.Equal(DiscrepancyKindRuleGroup, discrepancies[3].Kind)
+	re.Equal(DiscrepancyDiffering, discrepancies[3].Type)
+	re.Equal([]FieldChange{{Field: "index", Old: 5, New: 6}}, discrepancies[3].Changes)
+
+	re.NoError(manager.Repair(discrepancies))
+	discrepancies, err = manager.Reconcile(store)
+	re.NoError(err)
+	re.Empty(discrepancies)
+}
+
+func TestFrozenGroup(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "a", StartKeyHex: "11", EndKeyHex: "22", Role: Voter, Count: 3}))
+
+	// freeze the default group and a customized one.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "pd", Frozen: true}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1, Frozen: true}))
+	checkFrozen := func(err error) {
+		re.Error(err)
+		re.True(errs.ErrRuleGroupFrozen.Equal(err))
+	}
+	checkFrozen(manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5}))
+	checkFrozen(manager.DeleteRule("pd", "default", true))
+	checkFrozen(manager.SetRule(&Rule{GroupID: "g", ID: "b", StartKeyHex: "22", EndKeyHex: "33", Role: Voter, Count: 3}))
+	checkFrozen(manager.Batch([]RuleOp{{Action: RuleOpDel, Rule: &Rule{GroupID: "g", ID: "a"}}}))
+	checkFrozen(manager.DeleteRuleGroup("g"))
+	checkFrozen(manager.DeleteGroupBundle("g", false, false))
+	checkFrozen(manager.SetGroupBundle(GroupBundle{ID: "g", Index: 1}))
+	re.Equal(3, manager.GetRule("pd", "default").Count)
+	re.NotNil(manager.GetRule("g", "a"))
+	// the other groups are not affected.
+	re.NoError(manager.SetRule(&Rule{GroupID: "h", ID: "a", StartKeyHex: "11", EndKeyHex: "22", Role: Voter, Count: 3}))
+
+	// the frozen groups are persisted.
+	m2 := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(m2.Initialize(3, []string{"zone", "rack", "host"}))
+	re.True(m2.GetRuleGroup("pd").Frozen)
+	checkFrozen(m2.DeleteRule("pd", "default", true))
+
+	// unfreeze the groups.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "pd"}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	re.NoError(manager.DeleteRule("g", "a", false))
+}
+
+func TestSimulate(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		cluster.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+	}
+	cluster.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2"}))
+	peers := []*metapb.Peer{
+		{Id: 11, StoreId: 1, Role: metapb.PeerRole_Voter},
+		{Id: 12, StoreId: 2, Role: metapb.PeerRole_Voter},
+		{Id: 13, StoreId: 3, Role: metapb.PeerRole_Voter},
+	}
+	var regions []*core.RegionInfo
+	for i, keys := range [][2]string{{"", "74"}, {"74", "75"}, {"75", ""}} {
+		region := &metapb.Region{
+			Id:          uint64(i + 1),
+			StartKey:    dhex(keys[0]),
+			EndKey:      dhex(keys[1]),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+			Peers:       peers,
+		}
+		regions = append(regions, core.NewRegionInfo(region, peers[0]))
+	}
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	manager := NewRuleManager(store, cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	newRule := func(id, startKey, endKey string, role PeerRoleType, count int, zone string) *Rule {
+		rule := &Rule{GroupID: "pd", ID: id, StartKeyHex: startKey, EndKeyHex: endKey, Role: role, Count: count}
+		if zone != "" {
+			rule.LabelConstraints = []LabelConstraint{{Key: "zone", Op: In, Values: []string{zone}}}
+		}
+		return rule
+	}
+
+	report, err := manager.Simulate([]*Rule{newRule("default", "", "", Voter, 3, "")}, regions)
+	re.NoError(err)
+	re.Equal(&SimulationReport{RegionCount: 3}, report)
+
+	// a peer of each region is moved to z2.
+	report, err = manager.Simulate([]*Rule{
+		newRule("default", "", "", Voter, 2, "z1"),
+		newRule("z2", "", "", Voter, 1, "z2"),
+	}, regions)
+	re.NoError(err)
+	re.Equal([]uint64{1, 2, 3}, report.RebalanceRegions)
+	re.Equal(3, report.AddPeers)
+	re.Equal(3, report.RemovePeers)
+	re.Equal(3, report.PeerMovements)
+	re.Empty(report.UnschedulableRegions)
+
+	// there is only one store in z2.
+	report, err = manager.Simulate([]*Rule{
+		newRule("default", "", "", Voter, 3, ""),
+		newRule("z2", "", "", Learner, 2, "z2"),
+	}, regions)
+	re.NoError(err)
+	re.Equal([]uint64{1, 2, 3}, report.RebalanceRegions)
+	re.Equal(6, report.AddPeers)
+	re.Zero(report.RemovePeers)
+	re.Equal(6, report.PeerMovements)
+	re.Equal([]UnschedulableRegion{
+		{RegionID: 1, Rule: [2]string{"pd", "z2"}},
+		{RegionID: 2, Rule: [2]string{"pd", "z2"}},
+		{RegionID: 3, Rule: [2]string{"pd", "z2"}},
+	}, report.UnschedulableRegions)
+	// the report can be serialized to be compared.
+	data, err := json.Marshal(report)
+	re.NoError(err)
+	var decoded SimulationReport
+	re.NoError(json.Unmarshal(data, &decoded))
+	re.Equal(report, &decoded)
+
+	// the region crossing the rules is split.
+	report, err = manager.Simulate([]*Rule{
+		newRule("a", "", "7480", Voter, 3, ""),
+		newRule("b", "7480", "", Voter, 3, ""),
+	}, regions)
+	re.NoError(err)
+	re.Equal([]uint64{2}, report.SplitRegions)
+	re.Empty(report.RebalanceRegions)
+
+	// the invalid rules are rejected.
+	_, err = manager.Simulate([]*Rule{newRule("default", "", "", Learner, 3, "")}, regions)
+	re.Error(err)
+
+	// nothing is changed.
+	rules := manager.GetAllRules()
+	re.Len(rules, 1)
+	re.Equal(3, rules[0].Count)
+	re.Empty(rules[0].LabelConstraints)
+}
+
+func TestFindUnusedRules(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	manager := NewRuleManager(store, cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+	now := time.Now()
+	// the default rule is never reported even if there is no region.
+	re.Empty(manager.findUnusedRules(now.Add(unusedRuleGracePeriod)))
+
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "gap", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "used", StartKeyHex: "10", EndKeyHex: "20", Role: Voter, Count: 3}))
+	newRegion := func(id uint64, startKey, endKey string) *core.RegionInfo {
+		return core.NewRegionInfo(&metapb.Region{Id: id, StartKey: dhex(startKey), EndKey: dhex(endKey)}, nil)
+	}
+	cluster.PutRegion(newRegion(1, "", "74"))
+	cluster.PutRegion(newRegion(2, "75", ""))
+	// the rule is not reported before the grace period.
+	re.Empty(manager.findUnusedRules(now))
+	re.Empty(manager.findUnusedRules(now.Add(unusedRuleGracePeriod / 2)))
+	re.Equal([]string{"pd/gap"}, manager.findUnusedRules(now.Add(unusedRuleGracePeriod)))
+
+	// the rule covering a region again is not reported.
+	region := newRegion(3, "74", "75")
+	cluster.PutRegion(region)
+	re.Empty(manager.findUnusedRules(now.Add(unusedRuleGracePeriod * 2)))
+	// and the grace period is restarted once it covers no region again.
+	cluster.RemoveRegion(region)
+	re.Empty(manager.findUnusedRules(now.Add(unusedRuleGracePeriod * 3)))
+	re.Equal([]string{"pd/gap"}, manager.findUnusedRules(now.Add(unusedRuleGracePeriod*4)))
+
+	// the updated rule is checked again.
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "gap", StartKeyHex: "74", EndKeyHex: "7480", Role: Voter, Count: 3}))
+	re.Empty(manager.findUnusedRules(now.Add(unusedRuleGracePeriod * 4)))
+}
+
+type recordingObserver struct {
+	events []string
+	err    error
+}
+
+func (o *recordingObserver) OnRuleSet(rule *Rule) error {
+	o.events = append(o.events, fmt.Sprintf("set rule %s/%s %d", rule.GroupID, rule.ID, rule.Count))
+	return o.err
+}
+
+func (o *recordingObserver) OnRuleDeleted(groupID, id string) error {
+	o.events = append(o.events, fmt.Sprintf("delete rule %s/%s", groupID, id))
+	return o.err
+}
+
+func (o *recordingObserver) OnRuleGroupSet(group *RuleGroup) error {
+	o.events = append(o.events, fmt.Sprintf("set group %s %d", group.ID, group.Index))
+	return o.err
+}
+
+func (o *recordingObserver) OnRuleGroupDeleted(id string) error {
+	o.events = append(o.events, fmt.Sprintf("delete group %s", id))
+	return o.err
+}
+
+func TestRuleObserver(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	obs := &recordingObserver{}
+	manager.RegisterObserver(obs)
+
+	re.NoError(manager.SetRules([]*Rule{
+		{GroupID: "g", ID: "b", Role: Voter, Count: 1},
+		{GroupID: "g", ID: "a", Role: Voter, Count: 1},
+	}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	re.NoError(manager.DeleteRule("g", "a", false))
+	re.NoError(manager.DeleteRuleGroup("g"))
+	re.Equal([]string{
+		"set rule g/a 1",
+		"set rule g/b 1",
+		"set group g 1",
+		"delete rule g/a",
+		"delete group g",
+	}, obs.events)
+
+	// the rejected mutations are not observed.
+	obs.events = nil
+	re.Error(manager.SetRule(&Rule{GroupID: "g", ID: "a", Role: Voter, Count: 0}))
+	re.Empty(obs.events)
+
+	// the error of the observer does not roll back the mutation.
+	obs.err = errors.New("observer error")
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "a", Role: Voter, Count: 2}))
+	re.Equal([]string{"set rule g/a 2"}, obs.events)
+	re.Equal(2, manager.GetRule("g", "a").Count)
+}
+
+func TestRuleTombstone(t *testing.T) {
+	re := require.New(t)
+	store := endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil)
+	opt := mockconfig.NewTestOptions()
+	cfg := opt.GetReplicationConfig().Clone()
+	cfg.RuleTombstoneGracePeriod = typeutil.NewDuration(time.Hour)
+	opt.SetReplicationConfig(cfg)
+	manager := NewRuleManager(store, nil, opt)
+	re.NoError(manager.Initialize(3, []string{"zone", "rack", "host"}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", Role: Voter, Count: 1, StartKeyHex: "11", EndKeyHex: "22"}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r2", Role: Voter, Count: 1, StartKeyHex: "22", EndKeyHex: "33"}))
+
+	// the deleted rule is tombstoned and takes no effect.
+	re.NoError(manager.DeleteRule("g", "r1", false))
+	re.Nil(manager.GetRule("g", "r1"))
+	re.Len(manager.GetRulesForApplyRange(dhex("11"), dhex("22")), 1)
+	tombstones := manager.GetRuleTombstones()
+	re.Len(tombstones, 1)
+	re.Equal([2]string{"g", "r1"}, tombstones[0].Rule.Key())
+	re.Equal(time.Hour, tombstones[0].ExpireAt.Sub(tombstones[0].DeletedAt))
+
+	// the tombstones are persisted.
+	m2 := NewRuleManager(store, nil, opt)
+	re.NoError(m2.Initialize(3, []string{"zone", "rack", "host"}))
+	re.Len(m2.GetRuleTombstones(), 1)
+
+	// restore the tombstoned rule.
+	re.NoError(manager.RestoreRule("g", "r1"))
+	re.NotNil(manager.GetRule("g", "r1"))
+	re.Len(manager.GetRulesForApplyRange(dhex("11"), dhex("22")), 2)
+	re.Empty(manager.GetRuleTombstones())
+	re.Error(manager.RestoreRule("g", "r1"))
+
+	// a tombstone can not be restored once the rule is set again.
+	re.NoError(manager.DeleteRule("g", "r2", false))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r2", Role: Voter, Count: 2, StartKeyHex: "22", EndKeyHex: "33"}))
+	re.Error(manager.RestoreRule("g", "r2"))
+
+	// the expired tombstones are reaped.
+	re.NoError(manager.reapRuleTombstones(time.Now()))
+	re.Len(manager.GetRuleTombstones(), 1)
+	re.NoError(manager.reapRuleTombstones(time.Now().Add(2 * time.Hour)))
+	re.Empty(manager.GetRuleTombstones())
+	m2 = NewRuleManager(store, nil, opt)
+	re.NoError(m2.Initialize(3, []string{"zone", "rack", "host"}))
+	re.Empty(m2.GetRuleTombstones())
+
+	// the rules are removed directly if the grace period is disabled.
+	cfg = opt.GetReplicationConfig().Clone()
+	cfg.RuleTombstoneGracePeriod = typeutil.NewDuration(0)
+	opt.SetReplicationConfig(cfg)
+	re.NoError(manager.DeleteRule("g", "r1", false))
+	re.Empty(manager.GetRuleTombstones())
+	re.Error(manager.RestoreRule("g", "r1"))
+}
+
+func TestRulePrecondition(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	putStore := func(id uint64, zone string) *core.StoreInfo {
+		s := core.NewStoreInfoWithLabel(id, map[string]string{"zone": zone}).Clone(core.SetLastHeartbeatTS(time.Now()))
+		storeSet.PutStore(s)
+		return s
+	}
+	putStore(1, "z1")
+	putStore(2, "z2")
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	rule := &Rule{
+		GroupID:        "pd",
+		ID:             "zones",
+		Index:          1,
+		Override:       true,
+		StartKeyHex:    "74",
+		EndKeyHex:      "75",
+		Role:           Voter,
+		Count:          3,
+		LocationLabels: []string{"zone"},
+		IsolationLevel: "zone",
+		Precondition:   &RulePrecondition{LocationLabel: "zone", MinLocations: 3},
+	}
+	re.NoError(manager.SetRule(rule))
+	// the inactive rule doesn't override the default rule.
+	rules := manager.CheckRulePreconditions()
+	re.Len(rules, 1)
+	re.Equal([2]string{"pd", "zones"}, rules[0].Key())
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 1)
+	re.Equal("default", rules[0].ID)
+
+	// the rule is activated once the third zone is online.
+	z3 := putStore(3, "z3")
+	re.Empty(manager.CheckRulePreconditions())
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 1)
+	re.Equal("zones", rules[0].ID)
+
+	// and deactivated after the zone is gone.
+	storeSet.DeleteStore(z3)
+	re.Len(manager.CheckRulePreconditions(), 1)
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 1)
+	re.Equal("default", rules[0].ID)
+
+	// the rule is kept if no leader or voter is left without it.
+	re.NoError(manager.DeleteRule("pd", "default", true))
+	rules = manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 1)
+	re.Equal("zones", rules[0].ID)
+
+	// invalid preconditions are rejected.
+	for _, p := range []*RulePrecondition{
+		{MinStores: -1},
+		{MinLocations: 2},
+		{LabelConstraints: []LabelConstraint{{Key: "zone", Op: "unknown"}}},
+	} {
+		r := rule.Clone()
+		r.Precondition = p
+		re.Error(manager.SetRule(r))
+	}
+}
+
+func TestRuleConfigHash(t *testing.T) {
+	re := require.New(t)
+	_, m1 := newTestManager(t, false)
+	_, m2 := newTestManager(t, false)
+	re.Equal(m1.ConfigHash(), m2.ConfigHash())
+
+	r1 := &Rule{GroupID: "g1", ID: "r1", StartKeyHex: "7A", EndKeyHex: "7B", Role: Voter, Count: 1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z1", "z2"}}, {Key: "disk", Op: In, Values: []string{"ssd"}}}}
+	r2 := &Rule{GroupID: "g2", ID: "r2", StartKeyHex: "7b", EndKeyHex: "7c", Role: Learner, Count: 1}
+	re.NoError(m1.SetRule(r1))
+	re.NoError(m1.SetRule(r2))
+	re.NoError(m1.SetRuleGroup(&RuleGroup{ID: "g1", Index: 2}))
+	re.NoError(m1.SetRuleGroup(&RuleGroup{ID: "g2"}))
+
+	// the same configuration set in another order and form.
+	re.NoError(m2.SetRuleGroup(&RuleGroup{ID: "g1", Index: 2}))
+	re.NoError(m2.SetRule(&Rule{GroupID: "g2", ID: "r2", StartKeyHex: "7B", EndKeyHex: "7C", Role: Learner, Count: 1}))
+	re.NoError(m2.SetRule(&Rule{GroupID: "g1", ID: "r1", StartKeyHex: "7a", EndKeyHex: "7b", Role: Voter, Count: 1,
+		LabelConstraints: []LabelConstraint{{Key: "disk", Op: In, Values: []string{"ssd"}}, {Key: "zone", Op: In, Values: []string{"z2", "z1"}}}}))
+	re.Equal(m1.ConfigHash(), m2.ConfigHash())
+
+	// any change to the rules or groups changes the hash.
+	hash := m1.ConfigHash()
+	re.NoError(m1.SetRule(&Rule{GroupID: "g2", ID: "r2", StartKeyHex: "7b", EndKeyHex: "7c", Role: Learner, Count: 2}))
+	re.NotEqual(hash, m1.ConfigHash())
+	re.NoError(m1.SetRule(&Rule{GroupID: "g2", ID: "r2", StartKeyHex: "7b", EndKeyHex: "7c", Role: Learner, Count: 1}))
+	re.Equal(hash, m1.ConfigHash())
+	re.NoError(m1.SetRuleGroup(&RuleGroup{ID: "g2", Override: true}))
+	re.NotEqual(hash, m1.ConfigHash())
+}
+
+func TestRuleGroupInheritance(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	zones := LabelConstraint{Key: "zone", Op: In, Values: []string{"zone1", "zone2"}}
+	racks := LabelConstraint{Key: "rack", Op: In, Values: []string{"rack1"}}
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "compliance", LabelConstraints: []LabelConstraint{zones}}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "team", Index: 1, ParentID: "compliance"}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "team", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1,
+		LabelConstraints: []LabelConstraint{racks}}))
+	getAppliedConstraints := func() []LabelConstraint {
+		for _, r := range manager.GetRulesForApplyRange(dhex("74"), dhex("75")) {
+			if r.Key() == [2]string{"team", "r"} {
+				return r.LabelConstraints
+			}
+		}
+		return nil
+	}
+	// the inherited constraints are combined with the ones of the rule.
+	re.Equal([]LabelConstraint{zones, racks}, getAppliedConstraints())
+	re.Equal([]LabelConstraint{racks}, manager.GetRule("team", "r").LabelConstraints)
+
+	// the changes of the ancestors are inherited.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "org", LabelConstraints: []LabelConstraint{{Key: "engine", Op: NotIn, Values: []string{"tiflash"}}}}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "compliance", ParentID: "org", LabelConstraints: []LabelConstraint{zones}}))
+	re.Equal([]LabelConstraint{{Key: "engine", Op: NotIn, Values: []string{"tiflash"}}, zones, racks}, getAppliedConstraints())
+	// the group bundle keeps the inheritance.
+	re.NoError(manager.SetGroupBundle(GroupBundle{ID: "team", Index: 2, Rules: []*Rule{
+		{GroupID: "team", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1, LabelConstraints: []LabelConstraint{racks}},
+	}}))
+	re.Equal("compliance", manager.GetRuleGroup("team").ParentID)
+	re.Len(getAppliedConstraints(), 3)
+
+	// the cycles and invalid constraints are rejected.
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", ParentID: "team"}))
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", ParentID: "org"}))
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", LabelConstraints: []LabelConstraint{{Key: "zone", Op: "unknown"}}}))
+	re.Equal("", manager.GetRuleGroup("org").ParentID)
+
+	// nothing is inherited after the parent is reset.
+	re.NoError(manager.DeleteRuleGroup("compliance"))
+	re.Equal([]LabelConstraint{racks}, getAppliedConstraints())
+}
+
+func TestRuleGroupDefaults(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	zones := LabelConstraint{Key: "zone", Op: In, Values: []string{"zone1", "zone2"}}
+	hosts := LabelConstraint{Key: "host", Op: NotIn, Values: []string{"host1"}}
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "org", DefaultLocationLabels: []string{"zone", "host"},
+		DefaultLabelConstraints: []LabelConstraint{zones, hosts}}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "team", Index: 1, ParentID: "org", DefaultLocationLabels: []string{"rack"}}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "team", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+	getEffectiveRule := func() *Rule {
+		for _, er := range manager.EffectiveRules() {
+			if er.StartKeyHex != "74" {
+				continue
+			}
+			for _, r := range er.Rules {
+				if r.Key() == [2]string{"team", "r"} {
+					return r
+				}
+			}
+		}
+		return nil
+	}
+	// the defaults of the nearest group take effect.
+	r := getEffectiveRule()
+	re.Equal([]string{"rack"}, r.LocationLabels)
+	re.Equal([]LabelConstraint{zones, hosts}, r.LabelConstraints)
+	re.Empty(manager.GetRule("team", "r").LocationLabels)
+
+	// the rule overrides the defaults.
+	zone3 := LabelConstraint{Key: "zone", Op: In, Values: []string{"zone3"}}
+	re.NoError(manager.SetRule(&Rule{GroupID: "team", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1,
+		LocationLabels: []string{"host"}, LabelConstraints: []LabelConstraint{zone3}}))
+	r = getEffectiveRule()
+	re.Equal([]string{"host"}, r.LocationLabels)
+	re.Equal([]LabelConstraint{hosts, zone3}, r.LabelConstraints)
+
+	// the defaults conflicting with the override semantics are rejected.
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", DefaultLabelConstraints: []LabelConstraint{zones, zones}}))
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", LabelConstraints: []LabelConstraint{zones}, DefaultLabelConstraints: []LabelConstraint{zones}}))
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", DefaultLabelConstraints: []LabelConstraint{{Key: "zone", Op: "unknown"}}}))
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "org", DefaultLocationLabels: []string{"zone", "zone"}}))
+	re.Equal([]string{"zone", "host"}, manager.GetRuleGroup("org").DefaultLocationLabels)
+
+	// the defaults are kept by the round-trip of the group bundles.
+	data, err := json.Marshal(manager.GetAllGroupBundles())
+	re.NoError(err)
+	var bundles []GroupBundle
+	re.NoError(json.Unmarshal(data, &bundles))
+	re.NoError(manager.SetAllGroupBundles(bundles, true, false))
+	for _, b := range bundles {
+		re.NoError(manager.SetGroupBundle(b))
+	}
+	re.Equal([]string{"zone", "host"}, manager.GetRuleGroup("org").DefaultLocationLabels)
+	re.Equal([]LabelConstraint{zones, hosts}, manager.GetRuleGroup("org").DefaultLabelConstraints)
+	re.Equal([]string{"rack"}, manager.GetRuleGroup("team").DefaultLocationLabels)
+	r = getEffectiveRule()
+	re.Equal([]string{"host"}, r.LocationLabels)
+	re.Equal([]LabelConstraint{hosts, zone3}, r.LabelConstraints)
+}
+
+func TestRuleCountExpr(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	putStore := func(id uint64, zone string) {
+		storeSet.PutStore(core.NewStoreInfoWithLabel(id, map[string]string{"zone": zone}).Clone(core.SetLastHeartbeatTS(time.Now())))
+	}
+	for id := uint64(1); id <= 5; id++ {
+		putStore(id, "z1")
+	}
+	putStore(6, "z2")
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	rule := &Rule{
+		GroupID:          "pd",
+		ID:               "z1",
+		Index:            1,
+		Override:         true,
+		Role:             Voter,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z1"}}},
+		CountExpr:        &RuleCountExpr{Percentage: 40, Min: 1, Max: 3},
+	}
+	re.NoError(manager.SetRule(rule))
+	// the count is defaulted to the lower bound.
+	re.Equal(1, manager.GetRule("pd", "z1").Count)
+
+	// 40% of the 5 matching stores.
+	region := makeRegion("1,2")
+	fit := manager.FitRegion(storeSet, region)
+	re.Len(fit.RuleFits, 1)
+	re.Equal(2, fit.RuleFits[0].Rule.Count)
+	re.True(fit.IsSatisfied())
+
+	// the count grows with the matching stores, and is bounded by the max.
+	for id := uint64(7); id <= 9; id++ {
+		putStore(id, "z1")
+	}
+	fit = manager.FitRegion(storeSet, region)
+	re.Equal(3, fit.RuleFits[0].Rule.Count)
+	re.False(fit.IsSatisfied())
+	// the rule itself is kept as is.
+	re.Equal(1, manager.GetRule("pd", "z1").Count)
+
+	// invalid count expressions are rejected.
+	for _, e := range []*RuleCountExpr{
+		{Percentage: 0},
+		{Percentage: 101},
+		{Percentage: 50, Min: -1},
+		{Percentage: 50, Min: 3, Max: 2},
+	} {
+		rule.CountExpr = e
+		re.Error(manager.SetRule(rule))
+		re.NotEmpty(ValidateRule(rule))
+	}
+	rule.CountExpr = &RuleCountExpr{Percentage: 50}
+	rule.Role = Leader
+	re.Error(manager.SetRule(rule))
+}
+
+func TestRenameRuleGroup(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "child", ParentID: "g"}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r2", StartKeyHex: "75", EndKeyHex: "76", Role: Voter, Count: 1}))
+
+	re.True(errs.ErrRuleGroupNotFound.Equal(manager.RenameRuleGroup("unknown", "x")))
+	re.True(errs.ErrRuleGroupExists.Equal(manager.RenameRuleGroup("g", "pd")))
+	re.True(errs.ErrRuleGroupExists.Equal(manager.RenameRuleGroup("g", "child")))
+	re.Error(manager.RenameRuleGroup("g", ""))
+
+	re.NoError(manager.RenameRuleGroup("g", "h"))
+	check := func(m *RuleManager) {
+		re.Nil(m.GetRule("g", "r1"))
+		re.Nil(m.GetRule("g", "r2"))
+		re.NotNil(m.GetRule("h", "r1"))
+		re.NotNil(m.GetRule("h", "r2"))
+		re.Equal(&RuleGroup{ID: "h", Index: 10, Override: true}, m.GetRuleGroup("h"))
+		re.Equal("h", m.GetRuleGroup("child").ParentID)
+		rules := m.GetRulesForApplyRange(dhex("74"), dhex("75"))
+		re.Len(rules, 1)
+		re.Equal([2]string{"h", "r1"}, rules[0].Key())
+	}
+	check(manager)
+	// the renaming is persisted.
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	check(reloaded)
+
+	// the frozen group can't be renamed.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "h", Index: 10, Override: true, Frozen: true}))
+	re.True(errs.ErrRuleGroupFrozen.Equal(manager.RenameRuleGroup("h", "i")))
+}
+
+func TestRulesForRegion(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		cluster.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": "z1"}))
+	}
+	cluster.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z2"}))
+	peers := []*metapb.Peer{
+		{Id: 11, StoreId: 1, Role: metapb.PeerRole_Voter},
+		{Id: 12, StoreId: 2, Role: metapb.PeerRole_Voter},
+		{Id: 13, StoreId: 3, Role: metapb.PeerRole_Voter},
+	}
+	cluster.PutRegion(core.NewRegionInfo(&metapb.Region{
+		Id:          1,
+		StartKey:    dhex("74"),
+		EndKey:      dhex("75"),
+		RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+		Peers:       peers,
+	}, peers[0]))
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	_, err := manager.RulesForRegion(2)
+	re.Error(err)
+	getRules := func() (keys [][2]string, satisfied []bool) {
+		rules, err := manager.RulesForRegion(1)
+		re.NoError(err)
+		for _, r := range rules {
+			keys = append(keys, r.Rule.Key())
+			satisfied = append(satisfied, r.Satisfied)
+		}
+		return
+	}
+	keys, satisfied := getRules()
+	re.Equal([][2]string{{"pd", "default"}}, keys)
+	re.Equal([]bool{true}, satisfied)
+
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 10}))
+	re.NoError(manager.SetRule(&Rule{
+		GroupID:          "g",
+		ID:               "z2",
+		StartKeyHex:      "74",
+		EndKeyHex:        "75",
+		Role:             Voter,
+		Count:            1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z2"}}},
+	}))
+	keys, satisfied = getRules()
+	re.Equal([][2]string{{"pd", "default"}, {"g", "z2"}}, keys)
+	re.Equal([]bool{true, false}, satisfied)
+
+	// the rules overridden by the group are not applied.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	keys, satisfied = getRules()
+	re.Equal([][2]string{{"g", "z2"}}, keys)
+	re.Equal([]bool{false}, satisfied)
+}
+
+func TestRuleActiveWindow(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	now := time.Now()
+	timeAt := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	rule := &Rule{
+		GroupID:     "pd",
+		ID:          "window",
+		Index:       1,
+		Override:    true,
+		StartKeyHex: "74",
+		EndKeyHex:   "75",
+		Role:        Voter,
+		Count:       5,
+		ActiveFrom:  timeAt(time.Hour),
+	}
+	getAppliedRuleID := func() string {
+		rules := manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+		re.Len(rules, 1)
+		return rules[0].ID
+	}
+	// the rule is inert before the window opens, but still visible.
+	re.NoError(manager.SetRule(rule))
+	re.NotNil(manager.GetRule("pd", "window"))
+	re.Len(manager.CheckRulePreconditions(), 1)
+	re.Equal("default", getAppliedRuleID())
+
+	rule.ActiveFrom, rule.ActiveUntil = timeAt(-time.Hour), timeAt(200*time.Millisecond)
+	re.NoError(manager.SetRule(rule))
+	re.Empty(manager.CheckRulePreconditions())
+	re.Equal("window", getAppliedRuleID())
+
+	// the rule becomes inert once the window closes.
+	time.Sleep(300 * time.Millisecond)
+	re.Len(manager.CheckRulePreconditions(), 1)
+	re.Equal("default", getAppliedRuleID())
+
+	rule.ActiveFrom, rule.ActiveUntil = timeAt(time.Hour), timeAt(-time.Hour)
+	re.Error(manager.SetRule(rule))
+	re.NotEmpty(ValidateRule(rule))
+}
+
+func TestEffectiveRules(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	activeFrom := time.Now().Add(time.Hour)
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "later", Index: 1, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1, ActiveFrom: &activeFrom}))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 10, Override: true}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}))
+
+	ranges := manager.EffectiveRules()
+	re.Len(ranges, 3)
+	for i, keys := range [][2]string{{"", "74"}, {"74", "75"}, {"75", ""}} {
+		re.Equal(keys[0], ranges[i].StartKeyHex)
+		re.Equal(keys[1], ranges[i].EndKeyHex)
+	}
+	re.Len(ranges[0].Rules, 1)
+	re.Equal([2]string{"pd", "default"}, ranges[0].Rules[0].Key())
+	re.Empty(ranges[0].Suppressed)
+	re.Len(ranges[2].Rules, 1)
+	re.Equal([2]string{"pd", "default"}, ranges[2].Rules[0].Key())
+
+	re.Len(ranges[1].Rules, 1)
+	re.Equal([2]string{"g", "r"}, ranges[1].Rules[0].Key())
+	suppressed := make(map[string]*SuppressedRule)
+	for _, s := range ranges[1].Suppressed {
+		suppressed[s.Rule.ID] = s
+	}
+	re.Len(suppressed, 2)
+	re.Equal(SuppressedByOverride, suppressed["default"].Reason)
+	re.Equal("g/r", suppressed["default"].OverriddenBy)
+	re.Equal(SuppressedByInactive, suppressed["later"].Reason)
+	re.Empty(suppressed["later"].OverriddenBy)
+}
+
+func TestSetRuleEnabled(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "r", Index: 1, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3, Override: true}))
+	getAppliedRuleID := func(m *RuleManager) string {
+		rules := m.GetRulesForApplyRange(dhex("74"), dhex("75"))
+		re.Len(rules, 1)
+		return rules[0].ID
+	}
+	re.True(manager.GetRule("pd", "r").IsEnabled())
+	re.Equal("r", getAppliedRuleID(manager))
+
+	// the disabled rule is still listed, but skipped by fit.
+	re.NoError(manager.SetRuleEnabled("pd", "r", false))
+	rule := manager.GetRule("pd", "r")
+	re.NotNil(rule)
+	re.False(rule.IsEnabled())
+	re.Len(manager.GetAllRules(), 2)
+	re.Equal("default", getAppliedRuleID(manager))
+
+	// the disabled state is persisted.
+	m2 := NewRuleManager(store, nil, nil)
+	re.NoError(m2.Initialize(3, []string{}))
+	re.False(m2.GetRule("pd", "r").IsEnabled())
+	re.Equal("default", getAppliedRuleID(m2))
+
+	re.NoError(manager.SetRuleEnabled("pd", "r", true))
+	re.True(manager.GetRule("pd", "r").IsEnabled())
+	re.Nil(manager.GetRule("pd", "r").Enabled)
+	re.Equal("r", getAppliedRuleID(manager))
+
+	re.True(errs.ErrRuleNotFound.Equal(manager.SetRuleEnabled("pd", "missing", false)))
+}
+
+func TestRevisionNotReused(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1}))
+	revision := manager.GetRule("g", "r1").Revision
+	// the rule with the max revision is deleted.
+	re.NoError(manager.DeleteRule("g", "r1", false))
+
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	re.NoError(reloaded.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 2}))
+	re.Greater(reloaded.GetRule("g", "r1").Revision, revision)
+}
+
+func TestSetRulesForGroup(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRules([]*Rule{
+		{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1},
+		{GroupID: "g", ID: "r2", StartKeyHex: "75", EndKeyHex: "76", Role: Voter, Count: 1},
+		{GroupID: "other", ID: "r1", StartKeyHex: "76", EndKeyHex: "77", Role: Voter, Count: 1},
+	}))
+	revision := manager.GetRule("g", "r1").Revision
+
+	// r1 is unchanged, r2 is deleted and r3 is added.
+	re.NoError(manager.SetRulesForGroup("g", []*Rule{
+		{ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1},
+		{GroupID: "g", ID: "r3", StartKeyHex: "75", EndKeyHex: "76", Role: Learner, Count: 1},
+	}))
+	check := func(m *RuleManager) {
+		rules := m.GetRulesByGroup("g")
+		re.Len(rules, 2)
+		re.Equal("r1", rules[0].ID)
+		re.Equal(revision, rules[0].Revision)
+		re.Equal("r3", rules[1].ID)
+		re.Nil(m.GetRule("g", "r2"))
+		re.NotNil(m.GetRule("other", "r1"))
+	}
+	check(manager)
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	check(reloaded)
+
+	// the invalid input changes nothing.
+	re.Error(manager.SetRulesForGroup("g", []*Rule{{GroupID: "other", ID: "r4", Role: Voter, Count: 1}}))
+	re.Error(manager.SetRulesForGroup("g", []*Rule{
+		{ID: "r4", Role: Voter, Count: 1},
+		{ID: "r4", Role: Voter, Count: 2},
+	}))
+	re.Error(manager.SetRulesForGroup("g", []*Rule{{ID: "r4", Role: Voter, Count: 0}}))
+	check(manager)
+
+	// the changes exceeding one transaction are rejected.
+	rules := make([]*Rule, 0, maxEtcdTxnOps+1)
+	for i := 0; i <= maxEtcdTxnOps; i++ {
+		rules = append(rules, &Rule{ID: fmt.Sprintf("r%d", i+10), Role: Voter, Count: 1})
+	}
+	re.Error(manager.SetRulesForGroup("g", rules))
+	check(manager)
+
+	// an empty rule set removes all the rules of the group.
+	re.NoError(manager.SetRulesForGroup("g", nil))
+	re.Empty(manager.GetRulesByGroup("g"))
+	re.NotNil(manager.GetRule("other", "r1"))
+}
+
+func TestDefaultRuleProtection(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "all", Role: Voter, Count: 3}))
+
+	// the default rule can only be deleted by force.
+	err := manager.DeleteRule("pd", "default", false)
+	re.True(errs.ErrDefaultRuleProtected.Equal(err))
+	re.NotNil(manager.GetRule("pd", "default"))
+
+	// the edited default rule must be still a catch-all.
+	re.Error(manager.SetRule(&Rule{GroupID: "pd", ID: "default", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}))
+	re.Error(manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 0}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5}))
+	re.Equal(5, manager.GetRule("pd", "default").Count)
+
+	// the built-in default rule is reinstated after being edited or deleted.
+	re.NoError(manager.RestoreDefaultRule())
+	rule := manager.GetRule("pd", "default")
+	re.Equal(manager.conf.GetMaxReplicas(), rule.Count)
+	re.Equal(Voter, rule.Role)
+	re.NoError(manager.DeleteRule("pd", "default", true))
+	re.Nil(manager.GetRule("pd", "default"))
+	re.NoError(manager.RestoreDefaultRule())
+	re.NotNil(manager.GetRule("pd", "default"))
+
+	// the default rule is protected on the other paths removing it too.
+	checkProtected := func(err error) {
+		re.True(errs.ErrDefaultRuleProtected.Equal(err))
+		re.NotNil(manager.GetRule("pd", "default"))
+	}
+	checkProtected(manager.Batch([]RuleOp{{Rule: &Rule{GroupID: "pd", ID: "default"}, Action: RuleOpDel}}))
+	checkProtected(manager.SetRulesForGroup("pd", nil))
+	checkProtected(manager.DeleteGroupBundle("pd", false, false))
+	checkProtected(manager.DeleteGroupBundle("p.*", true, false))
+	checkProtected(manager.SetAllGroupBundles([]GroupBundle{{ID: "g", Rules: []*Rule{{GroupID: "g", ID: "all", Role: Voter, Count: 3}}}}, true, false))
+	checkProtected(manager.RenameRuleGroup("pd", "pd2"))
+	// the group bundle of pd can be reset with the default rule.
+	re.NoError(manager.SetGroupBundle(GroupBundle{ID: "pd", Rules: []*Rule{{GroupID: "pd", ID: "default", Role: Voter, Count: 3}}}))
+	re.NoError(manager.SetAllGroupBundles([]GroupBundle{{ID: "g", Rules: []*Rule{{GroupID: "g", ID: "all", Role: Voter, Count: 3}}}}, false, false))
+	re.NotNil(manager.GetRule("pd", "default"))
+	re.NoError(manager.DeleteGroupBundle("pd", false, true))
+	re.Nil(manager.GetRule("pd", "default"))
+}
+
+func TestAnnotations(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	hash := manager.ConfigHash()
+	annotations := map[string]string{"owner": "team-a", "ticket": "OPS-1"}
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "pd", Annotations: annotations}))
+	rule := manager.GetRule("pd", "default")
+	rule.Annotations = annotations
+	re.NoError(manager.SetRule(rule))
+	// the annotations take no part in the placement.
+	re.Equal(hash, manager.ConfigHash())
+
+	check := func(m *RuleManager) {
+		re.Equal(annotations, m.GetRule("pd", "default").Annotations)
+		re.Equal(annotations, m.GetRuleGroup("pd").Annotations)
+	}
+	check(manager)
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	check(reloaded)
+
+	// the annotations are exported, and kept by the group bundle.
+	data, err := manager.Export()
+	re.NoError(err)
+	re.Contains(string(data), `"owner":"team-a"`)
+	bundle := manager.GetGroupBundle("pd")
+	re.NoError(manager.SetGroupBundle(bundle))
+	check(manager)
+
+	// the configurations of the groups are carried by the group bundles.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1, ParentID: "pd",
+		DefaultLocationLabels: []string{"zone"}, Annotations: annotations}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+	data, err = json.Marshal(manager.GetAllGroupBundles())
+	re.NoError(err)
+	var bundles []GroupBundle
+	re.NoError(json.Unmarshal(data, &bundles))
+	_, target := newTestManager(t, false)
+	re.NoError(target.SetAllGroupBundles(bundles, true, false))
+	check(target)
+	re.Equal(manager.GetRuleGroup("g"), target.GetRuleGroup("g"))
+	// the configurations omitted by the bundle are kept.
+	re.NoError(target.SetGroupBundle(GroupBundle{ID: "g", Index: 2}))
+	re.Equal("pd", target.GetRuleGroup("g").ParentID)
+	re.Equal([]string{"zone"}, target.GetRuleGroup("g").DefaultLocationLabels)
+	re.Equal(annotations, target.GetRuleGroup("g").Annotations)
+	re.Error(target.SetGroupBundle(GroupBundle{ID: "g", Annotations: map[string]string{"": "x"}}))
+
+	// the changes of the annotations are found by the diff.
+	changes := diffRule(&Rule{}, &Rule{Annotations: annotations})
+	re.Len(changes, 1)
+	re.Equal("annotations", changes[0].Field)
+	re.Empty(diffRule(&Rule{}, &Rule{Annotations: map[string]string{}}))
+
+	re.Error(manager.SetRuleGroup(&RuleGroup{ID: "pd", Annotations: map[string]string{"": "x"}}))
+	rule.Annotations = map[string]string{"": "x"}
+	re.Error(manager.SetRule(rule))
+}
+
+func TestRuleEngine(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	tikv := core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1"})
+	tiflash := core.NewStoreInfoWithLabel(2, map[string]string{"zone": "z1", core.EngineKey: core.EngineTiFlash})
+	storeSet.PutStore(tikv)
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	rule := &Rule{GroupID: "tiflash", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1, Engine: core.EngineTiFlash}
+	// no store of the engine.
+	re.Error(manager.SetRule(rule))
+	storeSet.PutStore(tiflash)
+	re.NoError(manager.SetRule(rule))
+	re.Equal(core.EngineTiFlash, manager.GetRule("tiflash", "r").Engine)
+
+	// the engine is translated into the label constraint of the applied rule.
+	rules := manager.GetRulesForApplyRange(dhex("74"), dhex("75"))
+	re.Len(rules, 2)
+	for _, r := range rules {
+		if r.ID != "r" {
+			continue
+		}
+		re.Empty(r.Engine)
+		re.Equal([]LabelConstraint{{Key: core.EngineKey, Op: In, Values: []string{core.EngineTiFlash}}}, r.LabelConstraints)
+		re.True(r.MatchStore(tiflash))
+		re.False(r.MatchStore(tikv))
+	}
+	tikvRule := &Rule{Engine: core.EngineTiKV}
+	re.True(tikvRule.MatchStore(tikv))
+	re.False(tikvRule.MatchStore(tiflash))
+
+	// the unknown engine and the witness on TiFlash are rejected.
+	re.Error(manager.SetRule(&Rule{GroupID: "tiflash", ID: "r", Role: Learner, Count: 1, Engine: "tiflsh"}))
+	re.Error(manager.SetRule(&Rule{GroupID: "tiflash", ID: "w", Role: Voter, IsWitness: true, Count: 1, Engine: core.EngineTiFlash}))
+}
+
+func TestRejectUnschedulableRules(t *testing.T) {
+	re := require.New(t)
+	cluster := core.NewBasicCluster()
+	for i := uint64(1); i <= 3; i++ {
+		cluster.PutStore(core.NewStoreInfoWithLabel(i, map[string]string{"zone": fmt.Sprintf("z%d", i)}))
+	}
+	peers := []*metapb.Peer{
+		{Id: 11, StoreId: 1, Role: metapb.PeerRole_Voter},
+		{Id: 12, StoreId: 2, Role: metapb.PeerRole_Voter},
+		{Id: 13, StoreId: 3, Role: metapb.PeerRole_Voter},
+	}
+	for i, keys := range [][2]string{{"", "74"}, {"74", "75"}, {"75", ""}} {
+		region := &metapb.Region{
+			Id:          uint64(i + 1),
+			StartKey:    dhex(keys[0]),
+			EndKey:      dhex(keys[1]),
+			RegionEpoch: &metapb.RegionEpoch{ConfVer: 1, Version: 1},
+			Peers:       peers,
+		}
+		cluster.PutRegion(core.NewRegionInfo(region, peers[0]))
+	}
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), cluster, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	// 5 voters can't be placed on 3 stores.
+	rule := &Rule{GroupID: "pd", ID: "r", Index: 1, Override: true, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 5}
+	err := manager.SetRule(rule)
+	re.True(errs.ErrRuleUnschedulable.Equal(err))
+	re.Contains(err.Error(), "[2]")
+	re.Nil(manager.GetRule("pd", "r"))
+	err = manager.SetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5})
+	re.True(errs.ErrRuleUnschedulable.Equal(err))
+	re.Contains(err.Error(), "3 regions")
+	re.Equal(3, manager.GetRule("pd", "default").Count)
+
+	// the rules matching no store or sharing the stores are rejected too.
+	re.Error(manager.SetRule(&Rule{GroupID: "pd", ID: "r", Index: 1, Override: true, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z4"}}}}))
+	re.Error(manager.SetRule(&Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+
+	// the check can be skipped by force.
+	re.NoError(manager.ForceSetRule(rule))
+	re.Equal(5, manager.GetRule("pd", "r").Count)
+
+	// the deletion making the regions unschedulable is rejected unless forced.
+	re.NoError(manager.ForceSetRule(&Rule{GroupID: "pd", ID: "default", Role: Voter, Count: 5}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "pd", ID: "r", Index: 1, Override: true, StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}))
+	err = manager.DeleteRule("pd", "r", false)
+	re.True(errs.ErrRuleUnschedulable.Equal(err))
+	re.NotNil(manager.GetRule("pd", "r"))
+	// the force to delete the default rule doesn't skip the check.
+	err = manager.DeleteRule("pd", "r", true)
+	re.True(errs.ErrRuleUnschedulable.Equal(err))
+	re.NotNil(manager.GetRule("pd", "r"))
+	re.NoError(manager.ForceDeleteRule("pd", "r", false))
+	re.Nil(manager.GetRule("pd", "r"))
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	newRule := func(count int) *Rule {
+		return &Rule{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: count}
+	}
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(3), "k1", false))
+	revision := manager.GetRule("g", "r").Revision
+
+	// the retry with the same rule is skipped.
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(3), "k1", false))
+	re.Equal(revision, manager.GetRule("g", "r").Revision)
+
+	// the key can't be reused by a different rule.
+	err := manager.SetRuleWithIdempotencyKey(newRule(5), "k1", false)
+	re.True(errs.ErrIdempotencyKeyReused.Equal(err))
+	re.Equal(3, manager.GetRule("g", "r").Count)
+
+	// the failed update doesn't use up the key.
+	re.Error(manager.SetRuleWithIdempotencyKey(newRule(-1), "k2", false))
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(5), "k2", false))
+	re.Equal(5, manager.GetRule("g", "r").Count)
+
+	// the key can be reused after it expires.
+	defer func(ttl time.Duration) { idempotencyKeyTTL = ttl }(idempotencyKeyTTL)
+	idempotencyKeyTTL = 0
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(3), "k3", false))
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(4), "k3", false))
+	re.Equal(4, manager.GetRule("g", "r").Count)
+
+	// no idempotency without a key.
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(3), "", false))
+	re.NoError(manager.SetRuleWithIdempotencyKey(newRule(4), "", false))
+	re.Equal(4, manager.GetRule("g", "r").Count)
+}
+
+type auditRecorder struct {
+	entries []*RuleAuditEntry
+}
+
+func (r *auditRecorder) Audit(e *RuleAuditEntry) {
+	r.entries = append(r.entries, e)
+}
+
+func TestRuleAudit(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	recorder := &auditRecorder{}
+	manager.SetAuditSink(recorder)
+
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", Role: Voter, Count: 3}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", Role: Voter, Count: 5}))
+	re.NoError(manager.DeleteRule("g", "r", false))
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	re.NoError(manager.DeleteRuleGroup("g"))
+	// the rejected mutations are not audited.
+	re.Error(manager.SetRule(&Rule{GroupID: "g", ID: "r", Role: Voter, Count: 0}))
+
+	entries := recorder.entries
+	re.Len(entries, 5)
+	expected := []struct {
+		operation   string
+		id          string
+		beforeCount int
+		afterCount  int
+	}{
+		{AuditSetRule, "r", 0, 3},
+		{AuditSetRule, "r", 3, 5},
+		{AuditDeleteRule, "r", 5, 0},
+		{AuditSetRuleGroup, "", 0, 0},
+		{AuditDeleteRuleGroup, "", 0, 0},
+	}
+	count := func(data json.RawMessage) int {
+		var rule *Rule
+		re.NoError(json.Unmarshal(data, &rule))
+		if rule == nil {
+			return 0
+		}
+		return rule.Count
+	}
+	for i, e := range entries {
+		re.Equal(expected[i].operation, e.Operation)
+		re.Equal("g", e.GroupID)
+		re.Equal(expected[i].id, e.ID)
+		if e.ID != "" {
+			re.Equal(expected[i].beforeCount, count(e.Before))
+			re.Equal(expected[i].afterCount, count(e.After))
+		}
+	}
+	re.Equal("null", string(entries[3].Before))
+	re.JSONEq(`{"id":"g","index":1}`, string(entries[3].After))
+	re.Equal(string(entries[3].After), string(entries[4].Before))
+	re.Equal("null", string(entries[4].After))
+
+	// the chain is broken once an entry is modified or removed.
+	re.Equal(-1, VerifyAuditChain(entries))
+	tampered := *entries[1]
+	tampered.After = json.RawMessage(`null`)
+	re.Equal(1, VerifyAuditChain([]*RuleAuditEntry{entries[0], &tampered, entries[2]}))
+	re.Equal(1, VerifyAuditChain([]*RuleAuditEntry{entries[0], entries[2]}))
+}
+
+func TestCoalesce(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	rules := []*Rule{
+		{GroupID: "g", ID: "a", StartKeyHex: "10", EndKeyHex: "20", Role: Voter, Count: 3},
+		{GroupID: "g", ID: "b", StartKeyHex: "20", EndKeyHex: "30", Role: Voter, Count: 3},
+		{GroupID: "g", ID: "c", StartKeyHex: "30", EndKeyHex: "40", Role: Voter, Count: 3},
+		// not adjacent.
+		{GroupID: "g", ID: "d", StartKeyHex: "50", EndKeyHex: "60", Role: Voter, Count: 3},
+		// overlapped.
+		{GroupID: "g", ID: "e", StartKeyHex: "55", EndKeyHex: "70", Role: Voter, Count: 3},
+		// different count.
+		{GroupID: "g", ID: "f", StartKeyHex: "40", EndKeyHex: "50", Role: Voter, Count: 5},
+		// different group.
+		{GroupID: "h", ID: "a", StartKeyHex: "40", EndKeyHex: "50", Role: Voter, Count: 3},
+		// frozen group.
+		{GroupID: "i", ID: "a", StartKeyHex: "10", EndKeyHex: "20", Role: Learner, Count: 1},
+		{GroupID: "i", ID: "b", StartKeyHex: "20", EndKeyHex: "30", Role: Learner, Count: 1},
+	}
+	for _, r := range rules {
+		re.NoError(manager.SetRule(r))
+	}
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "i", Frozen: true}))
+
+	merged, err := manager.Coalesce()
+	re.NoError(err)
+	re.Equal(2, merged)
+	rule := manager.GetRule("g", "a")
+	re.Equal("10", rule.StartKeyHex)
+	re.Equal("40", rule.EndKeyHex)
+	re.Nil(manager.GetRule("g", "b"))
+	re.Nil(manager.GetRule("g", "c"))
+	for _, key := range [][2]string{{"g", "d"}, {"g", "e"}, {"g", "f"}, {"h", "a"}, {"i", "a"}, {"i", "b"}} {
+		re.NotNil(manager.GetRule(key[0], key[1]))
+	}
+	keys := make([][2]string, 0)
+	for _, r := range manager.GetRulesForApplyRange(dhex("25"), dhex("26")) {
+		keys = append(keys, r.Key())
+	}
+	re.ElementsMatch([][2]string{{"g", "a"}, {"i", "b"}, {"pd", "default"}}, keys)
+
+	// nothing more to coalesce.
+	merged, err = manager.Coalesce()
+	re.NoError(err)
+	re.Zero(merged)
+}
+
+func TestMatchingStores(t *testing.T) {
+	re := require.New(t)
+	storeSet := core.NewBasicCluster()
+	storeSet.PutStore(core.NewStoreInfoWithLabel(1, map[string]string{"zone": "z1"}))
+	storeSet.PutStore(core.NewStoreInfoWithLabel(2, map[string]string{"zone": "z1", core.EngineKey: core.EngineTiFlash}))
+	storeSet.PutStore(core.NewStoreInfoWithLabel(3, map[string]string{"zone": "z2"}))
+	storeSet.PutStore(core.NewStoreInfoWithLabel(4, map[string]string{"zone": "z1"}).Clone(core.SetStoreState(metapb.StoreState_Offline, false)))
+	storeSet.PutStore(core.NewStoreInfoWithLabel(5, map[string]string{"zone": "z1"}).Clone(core.SetStoreState(metapb.StoreState_Tombstone)))
+	manager := NewRuleManager(endpoint.NewStorageEndpoint(kv.NewMemoryKV(), nil), storeSet, mockconfig.NewTestOptions())
+	re.NoError(manager.Initialize(3, []string{"zone"}))
+
+	rule := &Rule{GroupID: "g", ID: "r", Role: Voter, Count: 3, LocationLabels: []string{"zone", "host"}}
+	ids, err := manager.MatchingStores(rule)
+	re.NoError(err)
+	re.Equal([]uint64{1, 2, 3}, ids)
+
+	// the constraints inherited from the group and the ones of the engine.
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", LabelConstraints: []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z1"}}}}))
+	ids, err = manager.MatchingStores(rule)
+	re.NoError(err)
+	re.Equal([]uint64{1, 2}, ids)
+	rule.Engine = core.EngineTiKV
+	ids, err = manager.MatchingStores(rule)
+	re.NoError(err)
+	re.Equal([]uint64{1}, ids)
+	re.Equal(core.EngineTiKV, rule.Engine)
+
+	// the unavailable stores are only included on request.
+	ids, err = manager.MatchingStores(rule, WithUnavailableStores())
+	re.NoError(err)
+	re.Equal([]uint64{1, 4, 5}, ids)
+
+	// no store matches.
+	rule.LabelConstraints = []LabelConstraint{{Key: "zone", Op: In, Values: []string{"z3"}}}
+	ids, err = manager.MatchingStores(rule)
+	re.NoError(err)
+	re.Empty(ids)
+
+	// the invalid rule is rejected.
+	rule.Count = 0
+	_, err = manager.MatchingStores(rule)
+	re.Error(err)
+}
+
+func TestRuleSet(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "blue", Index: 1}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "blue", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+	checkBlue := func(m *RuleManager) {
+		re.NotNil(m.GetRule("pd", "default"))
+		re.NotNil(m.GetRule("blue", "r"))
+		re.Nil(m.GetRule("green", "r"))
+		re.Equal(1, m.GetRuleGroup("blue").Index)
+	}
+	checkGreen := func(m *RuleManager) {
+		re.Nil(m.GetRule("pd", "default"))
+		re.Nil(m.GetRule("blue", "r"))
+		re.Equal(5, m.GetRule("green", "default").Count)
+		re.NotNil(m.GetRule("green", "r"))
+		g := m.GetRuleGroup("blue")
+		re.True(g == nil || g.isDefault())
+		re.True(m.GetRuleGroup("green").Override)
+	}
+
+	// the staged rule set changes nothing until it's activated.
+	re.NoError(manager.StageRuleSet("green", []*Rule{
+		{GroupID: "green", ID: "default", Role: Voter, Count: 5},
+		{GroupID: "green", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 2},
+	}, []*RuleGroup{{ID: "green", Override: true}}))
+	checkBlue(manager)
+	re.NoError(manager.ActivateRuleSet("green"))
+	checkGreen(manager)
+	state, err := manager.GetRuleSetState()
+	re.NoError(err)
+	re.Equal(&RuleSetState{Active: "green"}, state)
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	checkGreen(reloaded)
+
+	// the replaced configuration is restored by the rollback, only once.
+	re.NoError(manager.RollbackRuleSet())
+	checkBlue(manager)
+	state, err = manager.GetRuleSetState()
+	re.NoError(err)
+	re.Equal(&RuleSetState{}, state)
+	re.ErrorContains(manager.RollbackRuleSet(), "not found")
+	checkBlue(manager)
+
+	// the rule set can be activated again.
+	re.NoError(manager.ActivateRuleSet("green"))
+	checkGreen(manager)
+
+	// the invalid rule sets are rejected.
+	re.Error(manager.StageRuleSet("", []*Rule{{GroupID: "g", ID: "default", Role: Voter, Count: 3}}, nil))
+	re.Error(manager.StageRuleSet(rollbackRuleSetName, []*Rule{{GroupID: "g", ID: "default", Role: Voter, Count: 3}}, nil))
+	re.Error(manager.StageRuleSet("empty", nil, nil))
+	re.Error(manager.StageRuleSet("partial", []*Rule{{GroupID: "g", ID: "r", StartKeyHex: "74", EndKeyHex: "75", Role: Voter, Count: 3}}, nil))
+	re.Error(manager.StageRuleSet("dup", []*Rule{
+		{GroupID: "g", ID: "default", Role: Voter, Count: 3},
+		{GroupID: "g", ID: "default", Role: Voter, Count: 3},
+	}, nil))
+	re.ErrorContains(manager.ActivateRuleSet("unknown"), "not found")
+	checkGreen(manager)
+}
+
+func TestHealthSummary(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	re.NoError(manager.SetRules([]*Rule{
+		{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1},
+		{GroupID: "g", ID: "r2", StartKeyHex: "75", EndKeyHex: "76", Role: Learner, Count: 1},
+	}))
+	re.NoError(manager.SetRuleEnabled("g", "r2", false))
+	health := manager.HealthSummary()
+	re.Equal(3, health.TotalRules)
+	re.Equal(2, health.ActiveRules)
+	re.Equal(1, health.DisabledRules)
+	re.Equal(1, health.TotalGroups)
+	re.Zero(health.UnusedRules)
+	re.Zero(health.UnschedulableRegions)
+	re.Equal(manager.ConfigHash(), health.ConfigHash)
+
+	// the unused rules are counted after the grace period.
+	manager.Lock()
+	manager.unusedRules[[2]string{"g", "r1"}] = &unusedRule{since: time.Now().Add(-unusedRuleGracePeriod)}
+	manager.unusedRules[[2]string{"g", "r2"}] = &unusedRule{since: time.Now()}
+	manager.Unlock()
+	re.Equal(1, manager.HealthSummary().UnusedRules)
+
+	// only the cached fits of the current rule set are counted, and there is
+	// no store to satisfy the rules.
+	rule := manager.GetRule("g", "r1")
+	manager.cache.mu.Lock()
+	manager.cache.regionCaches[1] = &regionRuleFitCache{
+		ruleSetVersion: manager.ruleSetVersion,
+		bestFit:        &RegionFit{RuleFits: []*RuleFit{{Rule: rule}}},
+	}
+	manager.cache.regionCaches[2] = &regionRuleFitCache{ruleSetVersion: manager.ruleSetVersion}
+	manager.cache.regionCaches[3] = &regionRuleFitCache{
+		ruleSetVersion: manager.ruleSetVersion - 1,
+		bestFit:        &RegionFit{RuleFits: []*RuleFit{{Rule: rule}}},
+	}
+	manager.cache.mu.Unlock()
+	re.Equal(1, manager.HealthSummary().UnschedulableRegions)
+}
+
+func TestRuleHistory(t *testing.T) {
+	re := require.New(t)
+	store, manager := newTestManager(t, false)
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 1}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r1", StartKeyHex: "74", EndKeyHex: "75", Role: Learner, Count: 2}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r2", StartKeyHex: "75", EndKeyHex: "76", Role: Learner, Count: 1}))
+	re.NoError(manager.DeleteRule("g", "r1", true))
+
+	history, err := manager.GetRuleHistory()
+	re.NoError(err)
+	re.Len(history, 5)
+	for i, h := range history {
+		re.Equal(uint64(i+1), h.Revision)
+		re.NotEmpty(h.Changes)
+	}
+	re.Equal(AuditSetRuleGroup, history[0].Changes[0].Operation)
+	re.Equal(AuditDeleteRule, history[4].Changes[0].Operation)
+	re.Equal("r1", history[4].Changes[0].ID)
+
+	// roll back to the revision where r1 has 2 replicas and r2 doesn't exist.
+	re.NoError(manager.RollbackToRevision(3))
+	re.Equal(2, manager.GetRule("g", "r1").Count)
+	re.Nil(manager.GetRule("g", "r2"))
+	re.NotNil(manager.GetRuleGroup("g"))
+	// the rollback is recorded as a new revision, which can be rolled back too.
+	history, err = manager.GetRuleHistory()
+	re.NoError(err)
+	re.Len(history, 6)
+	re.Equal(uint64(6), history[5].Revision)
+	re.NoError(manager.RollbackToRevision(5))
+	re.Nil(manager.GetRule("g", "r1"))
+	re.Equal(1, manager.GetRule("g", "r2").Count)
+
+	// roll back to the revision where only the group is created.
+	re.NoError(manager.RollbackToRevision(1))
+	re.Nil(manager.GetRule("g", "r1"))
+	re.Nil(manager.GetRule("g", "r2"))
+	re.NotNil(manager.GetRule("pd", "default"))
+
+	// the revisions out of the history are rejected.
+	re.True(errs.ErrRuleRevision.Equal(manager.RollbackToRevision(0)))
+	re.True(errs.ErrRuleRevision.Equal(manager.RollbackToRevision(100)))
+
+	// the revision continues after reloading.
+	reloaded := NewRuleManager(store, nil, mockconfig.NewTestOptions())
+	re.NoError(reloaded.Initialize(3, []string{"zone", "rack", "host"}))
+	re.NoError(reloaded.SetRule(&Rule{GroupID: "g", ID: "r3", StartKeyHex: "76", EndKeyHex: "77", Role: Learner, Count: 1}))
+	history, err = reloaded.GetRuleHistory()
+	re.NoError(err)
+	re.Equal(uint64(9), history[len(history)-1].Revision)
+}
+
+func TestExplainRegionFit(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	stores := makeStores()
+	zone1 := LabelConstraint{Key: "zone", Op: In, Values: []string{"zone1"}}
+	re.NoError(manager.SetRuleGroup(&RuleGroup{ID: "g", Index: 1, Override: true}))
+	re.NoError(manager.SetRule(&Rule{GroupID: "g", ID: "r", Role: Voter, Count: 3, LabelConstraints: []LabelConstraint{zone1}}))
+
+	// 2111 is in another zone, and 1115 is a TiFlash store.
+	region := makeRegion("1111_leader,1112,2111,1115")
+	e := manager.ExplainRegionFit(stores, region)
+	re.Len(e.Rules, 1)
+	rfe := e.Rules[0]
+	re.Equal([2]string{"g", "r"}, [2]string{rfe.GroupID, rfe.ID})
+	re.False(rfe.Satisfied)
+	re.Equal(FitFailureLabelMismatch, rfe.Reason)
+	re.Len(rfe.MatchedPeers, 2)
+	re.Equal(100, rfe.MatchingStores)
+	re.Len(rfe.UnmatchedPeers, 2)
+	re.Equal(uint64(2111), rfe.UnmatchedPeers[0].StoreID)
+	re.Equal([]*ConstraintFailure{{Constraint: &zone1, Label: "zone", Value: "zone2"}}, rfe.UnmatchedPeers[0].FailedConstraints)
+	re.Equal(uint64(1115), rfe.UnmatchedPeers[1].StoreID)
+	re.Equal([]*ConstraintFailure{{Label: "engine", Value: "tiflash"}}, rfe.UnmatchedPeers[1].FailedConstraints)
+	re.Len(e.OrphanPeers, 2)
+	for _, o := range e.OrphanPeers {
+		re.Equal("the store matches none of the rules", o.Reason)
+	}
+
+	// the extra peer matching the rule is orphaned since the rule is full.
+	region = makeRegion("1111_leader,1112,1113,1114")
+	e = manager.ExplainRegionFit(stores, region)
+	re.True(e.Rules[0].Satisfied)
+	re.Empty(e.Rules[0].Reason)
+	re.Empty(e.Rules[0].UnmatchedPeers)
+	re.Len(e.OrphanPeers, 1)
+	re.Contains(e.OrphanPeers[0].Reason, "g/r already have enough peers")
+	// the store of the peer is gone.
+	region = makeRegion("1111_leader,1112,1113,9999")
+	e = manager.ExplainRegionFit(stores, region)
+	re.Len(e.OrphanPeers, 1)
+	re.Equal(uint64(9999), e.OrphanPeers[0].StoreID)
+	re.Equal("the store of the peer is not found", e.OrphanPeers[0].Reason)
+}
@@ -0,0 +1,107 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// RuleSchemaVersion is the current schema version of the rule JSON, which is
+// stamped on the rules saved by RuleManager. The rules saved before the stamp
+// is introduced are of the version 0. Please bump it and append a migration to
+// ruleMigrations once the schema is changed in a way that the old rules need
+// to be filled or converted.
+const RuleSchemaVersion = 1
+
+// ruleMigrations[i] migrates the decoded rule JSON of the version i to i+1 in
+// place, so all the defaults are filled here rather than by each loader.
+var ruleMigrations = []func(rule map[string]interface{}) error{
+	migrateRuleV0ToV1,
+}
+
+// migrateRuleV0ToV1 marks the rules of the witness role as witnesses, and
+// converts the hex keys to lower case, which are done by every loader before.
+func migrateRuleV0ToV1(rule map[string]interface{}) error {
+	if role, ok := rule["role"].(string); ok && PeerRoleType(role) == Witness {
+		rule["is_witness"] = true
+	}
+	for _, field := range []string{"start_key", "end_key"} {
+		if key, ok := rule[field].(string); ok {
+			rule[field] = strings.ToLower(key)
+		}
+	}
+	return nil
+}
+
+// MigrateRule migrates the rule JSON from the schema version fromVersion to
+// toVersion, and stamps the version on it. Only the forward migration is
+// supported.
+func MigrateRule(raw []byte, fromVersion, toVersion int) ([]byte, error) {
+	if fromVersion < 0 || fromVersion > toVersion || toVersion > RuleSchemaVersion {
+		return nil, errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("can not migrate rule from schema version %d to %d", fromVersion, toVersion))
+	}
+	if fromVersion == toVersion {
+		return raw, nil
+	}
+	rule := make(map[string]interface{})
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	// keep the numbers as they are, e.g., the revision may exceed the precision of float64.
+	decoder.UseNumber()
+	if err := decoder.Decode(&rule); err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		// the JSON is null.
+		rule = make(map[string]interface{})
+	}
+	for v := fromVersion; v < toVersion; v++ {
+		if err := ruleMigrations[v](rule); err != nil {
+			return nil, err
+		}
+	}
+	rule["schema_version"] = toVersion
+	return json.Marshal(rule)
+}
+
+// ruleSchemaVersionOf returns the schema version stamped on the rule JSON, it's
+// 0 if the version is missing.
+func ruleSchemaVersionOf(raw []byte) (int, error) {
+	var stamp struct {
+		SchemaVersion int `json:"schema_version"`
+	}
+	if err := json.Unmarshal(raw, &stamp); err != nil {
+		return 0, err
+	}
+	return stamp.SchemaVersion, nil
+}
+
+// migrateRuleJSON migrates the rule JSON to the current schema version. The
+// rules of the newer versions, e.g., saved by the upgraded PD during a rolling
+// upgrade, are kept as they are, since they can't be migrated backward.
+func migrateRuleJSON(raw []byte) ([]byte, error) {
+	version, err := ruleSchemaVersionOf(raw)
+	if err != nil {
+		return nil, err
+	}
+	if version >= RuleSchemaVersion {
+		return raw, nil
+	}
+	return MigrateRule(raw, version, RuleSchemaVersion)
+}
@@ -0,0 +1,90 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sort"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// RuleObserver observes the mutations of the rules and rule groups. The
+// methods are called synchronously with the RuleManager locked after the
+// mutations are saved to the storage, so they should return quickly and must
+// not call the RuleManager. The returned error is logged only, the mutation
+// is not rolled back.
+type RuleObserver interface {
+	// OnRuleSet is called with a copy of the rule inserted or updated.
+	OnRuleSet(rule *Rule) error
+	// OnRuleDeleted is called with the key of the rule removed.
+	OnRuleDeleted(groupID, id string) error
+	// OnRuleGroupSet is called with a copy of the rule group inserted or updated.
+	OnRuleGroupSet(group *RuleGroup) error
+	// OnRuleGroupDeleted is called with the ID of the rule group removed or
+	// reset to the default configuration.
+	OnRuleGroupDeleted(id string) error
+}
+
+// RegisterObserver registers an observer of the mutations of the rules and
+// rule groups. The observers are called in the order of registration.
+func (m *RuleManager) RegisterObserver(obs RuleObserver) {
+	m.Lock()
+	defer m.Unlock()
+	m.observers = append(m.observers, obs)
+}
+
+// notifyObservers notifies the observers of the committed mutations, the rules
+// are notified before the rule groups, and each of them in the order of keys.
+func (m *RuleManager) notifyObservers(mut *ruleConfig) {
+	if len(m.observers) == 0 {
+		return
+	}
+	for _, key := range mut.ruleKeys() {
+		r := mut.rules[key]
+		for _, obs := range m.observers {
+			var err error
+			if r == nil {
+				err = obs.OnRuleDeleted(key[0], key[1])
+			} else {
+				err = obs.OnRuleSet(r.Clone())
+			}
+			if err != nil {
+				log.Warn("rule observer failed", zap.String("group-id", key[0]), zap.String("rule-id", key[1]), errs.ZapError(err))
+			}
+		}
+	}
+	ids := make([]string, 0, len(mut.groups))
+	for id := range mut.groups {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		g := mut.groups[id]
+		for _, obs := range m.observers {
+			var err error
+			if g.isDefault() {
+				err = obs.OnRuleGroupDeleted(id)
+			} else {
+				clone := *g
+				err = obs.OnRuleGroupSet(&clone)
+			}
+			if err != nil {
+				log.Warn("rule group observer failed", zap.String("group-id", id), errs.ZapError(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// RulePrecondition is the cluster state required by a rule to take effect, the
+// rule is inactive and ignored by fit until the precondition is met. It counts
+// the online stores matching the label constraints, all the online stores are
+// counted if there is no constraint. The precondition is met if
+//   - there are at least MinStores of them, and
+//   - they spread over at least MinLocations distinct values of the label
+//     LocationLabel, which is ignored if MinLocations is 0.
+//
+// For example, the precondition of a rule isolating the replicas by zones can
+// be {"location_label": "zone", "min_locations": 3}, so the rule takes effect
+// once three zones are online, and the regions are not made unschedulable
+// during the bootstrap.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RulePrecondition struct {
+	LabelConstraints []LabelConstraint `json:"label_constraints,omitempty"`
+	MinStores        int               `json:"min_stores,omitempty"`
+	LocationLabel    string            `json:"location_label,omitempty"`
+	MinLocations     int               `json:"min_locations,omitempty"`
+}
+
+func (p *RulePrecondition) validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.MinStores < 0 {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid min stores %d of precondition", p.MinStores))
+	}
+	if p.MinLocations < 0 {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid min locations %d of precondition", p.MinLocations))
+	}
+	if p.MinLocations > 0 && p.LocationLabel == "" {
+		return errs.ErrRuleContent.FastGenByArgs("location label of precondition should not be empty")
+	}
+	for _, c := range p.LabelConstraints {
+		if !validateOp(c.Op) {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s of precondition", c.Op))
+		}
+	}
+	return nil
+}
+
+// isMet checks the precondition against the stores, the stores which are not
+// up or have been disconnected are not counted.
+func (p *RulePrecondition) isMet(stores []*core.StoreInfo) bool {
+	count := 0
+	locations := make(map[string]struct{})
+	for _, s := range stores {
+		if !s.IsUp() || s.IsDisconnected() || !MatchLabelConstraints(s, p.LabelConstraints) {
+			continue
+		}
+		count++
+		if p.MinLocations > 0 {
+			if v := s.GetLabelValue(p.LocationLabel); v != "" {
+				locations[v] = struct{}{}
+			}
+		}
+	}
+	return count >= p.MinStores && len(locations) >= p.MinLocations
+}
+
+// validateActiveWindow checks the active window of the rule.
+func (r *Rule) validateActiveWindow() error {
+	if r.ActiveFrom != nil && r.ActiveUntil != nil && !r.ActiveFrom.Before(*r.ActiveUntil) {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("active from %s should be before active until %s",
+			r.ActiveFrom.Format(time.RFC3339), r.ActiveUntil.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// isActiveAt checks whether the time is in the active window of the rule, the
+// window is [ActiveFrom, ActiveUntil).
+func (r *Rule) isActiveAt(now time.Time) bool {
+	if r.ActiveFrom != nil && now.Before(*r.ActiveFrom) {
+		return false
+	}
+	return r.ActiveUntil == nil || now.Before(*r.ActiveUntil)
+}
+
+// isActive checks whether the rule is enabled, in its active window and its
+// precondition is met. The window is checked against the current time since
+// the inactive rules are only updated periodically.
+func (m *RuleManager) isActive(r *Rule) bool {
+	return r.IsEnabled() && r.isActiveAt(time.Now()) && !m.isInactive(r)
+}
+
+// CheckRulePreconditions evaluates the preconditions of the rules against the
+// current stores and the active windows of the rules against the current time,
+// and returns the sorted inactive rules. It should be called periodically, so
+// that the rules are activated and deactivated as the stores join and leave,
+// and as their windows open and close.
+func (m *RuleManager) CheckRulePreconditions() []*Rule {
+	stores := m.getAliveStores()
+	m.Lock()
+	defer m.Unlock()
+	changed := m.updateInactiveRules(m.ruleConfig, stores)
+	// the activation changes the rules applied to the regions.
+	if changed {
+		m.invalidFitCache(nil)
+	}
+	rules := make([]*Rule, 0, len(m.inactiveRules))
+	for key := range m.inactiveRules {
+		rules = append(rules, m.ruleConfig.getRule(key).Clone())
+	}
+	sortRules(rules)
+	return rules
+}
+
+// updateInactiveRules evaluates the preconditions, the active windows and the
+// enabled states of the rules in the config, the nil rules in a patch are regarded as deleted. It
+// returns whether any rule is activated or deactivated, and must be called with
+// the lock held.
+func (m *RuleManager) updateInactiveRules(c *ruleConfig, stores []*core.StoreInfo) bool {
+	changed := false
+	now := time.Now()
+	for key, r := range c.rules {
+		_, inactive := m.inactiveRules[key]
+		if r != nil && (!r.IsEnabled() || !r.isActiveAt(now) || (r.Precondition != nil && !r.Precondition.isMet(stores))) {
+			if !inactive {
+				m.inactiveRules[key] = struct{}{}
+				inactiveRulesGauge.WithLabelValues(key[0], key[1]).Set(1)
+				changed = true
+			}
+			continue
+		}
+		if inactive {
+			delete(m.inactiveRules, key)
+			inactiveRulesGauge.DeleteLabelValues(key[0], key[1])
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,293 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// RulePreview is the estimated impact of setting a rule.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RulePreview struct {
+	Rule *Rule `json:"rule"`
+	// AffectedRegions are the IDs of the regions whose applied rules would change.
+	AffectedRegions []uint64 `json:"affected_regions"`
+	// AddPeers and RemovePeers are the number of peers the rule checker would
+	// additionally add and remove for the affected regions.
+	AddPeers    int `json:"add_peers"`
+	RemovePeers int `json:"remove_peers"`
+	// RedundantRules are the rules which would no longer be applied to any range.
+	RedundantRules []*Rule `json:"redundant_rules"`
+	// OverriddenBy are the rules which would override the previewed rule, the
+	// previewed rule does not take effect in the ranges they cover.
+	OverriddenBy []*Rule `json:"overridden_by"`
+}
+
+// PreviewRule estimates the impact of setting the rule against the current
+// regions without persisting or applying it.
+func (m *RuleManager) PreviewRule(rule *Rule) (*RulePreview, error) {
+	regionSet, ok := m.storeSetInformer.(interface {
+		ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+	})
+	if !ok {
+		return nil, errors.New("the cluster does not support scanning regions")
+	}
+	// the rule of the caller is kept unchanged.
+	rule = rule.Clone()
+	if err := m.adjustRule(rule, ""); err != nil {
+		return nil, err
+	}
+	preview := &RulePreview{Rule: rule.Clone()}
+	oldList, newList, keyRanges, err := m.buildPreviewRuleList([]*Rule{rule}, func(newList ruleList, _ *ruleConfigPatch) {
+		// the rule may be overridden by the rules with a larger index or the
+		// rules belong to an override group.
+		overriddenBy := make(map[[2]string]*Rule)
+		for _, rr := range newList.ranges {
+			if !containsRule(rr.rules, rule) || containsRule(rr.applyRules, rule) {
+				continue
+			}
+			for _, r := range rr.applyRules {
+				if overridesRule(r, rule) {
+					overriddenBy[r.Key()] = r
+				}
+			}
+		}
+		for _, r := range overriddenBy {
+			preview.OverriddenBy = append(preview.OverriddenBy, r.Clone())
+		}
+		sortRules(preview.OverriddenBy)
+
+		oldApplied, newApplied := m.ruleList.appliedRuleKeys(), newList.appliedRuleKeys()
+		for key := range oldApplied {
+			if _, ok := newApplied[key]; !ok && key != rule.Key() {
+				preview.RedundantRules = append(preview.RedundantRules, m.ruleConfig.getRule(key).Clone())
+			}
+		}
+		sortRules(preview.RedundantRules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mv := range m.previewPeerMovements(scanRegions(regionSet, keyRanges), oldList, newList) {
+		preview.AffectedRegions = append(preview.AffectedRegions, mv.RegionID)
+		preview.AddPeers += mv.AddPeers
+		preview.RemovePeers += mv.RemovePeers
+	}
+	return preview, nil
+}
+
+// RulesPreview is the estimated impact of setting a batch of rules.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RulesPreview struct {
+	Rules []*Rule `json:"rules"`
+	// Movements are the peer movements of the regions whose applied rules
+	// would change, in the order of the region IDs.
+	Movements []*PeerMovement `json:"movements"`
+	// AddPeers and RemovePeers are the total number of peers the rule checker
+	// would additionally add and remove.
+	AddPeers    int `json:"add_peers"`
+	RemovePeers int `json:"remove_peers"`
+	// RedundantRules are the rules which would no longer be applied to any range.
+	RedundantRules []*Rule `json:"redundant_rules"`
+}
+
+// PeerMovement is the estimated peer movements of a region caused by the rule
+// changes, i.e., the operators the rule checker would generate for it.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type PeerMovement struct {
+	RegionID uint64 `json:"region_id"`
+	// Split means the region would be split by the rule checker before being
+	// fitted, since it's covered by the different rules partially.
+	Split       bool `json:"split,omitempty"`
+	AddPeers    int  `json:"add_peers"`
+	RemovePeers int  `json:"remove_peers"`
+}
+
+// PreviewRules estimates the impact of setting the rules at once against the
+// current regions without persisting or applying them, like PreviewRule.
+func (m *RuleManager) PreviewRules(rules []*Rule) (*RulesPreview, error) {
+	regionSet, ok := m.storeSetInformer.(interface {
+		ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+	})
+	if !ok {
+		return nil, errors.New("the cluster does not support scanning regions")
+	}
+	// the rules of the caller are kept unchanged.
+	clones := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		r = r.Clone()
+		if err := m.adjustRule(r, ""); err != nil {
+			return nil, err
+		}
+		clones = append(clones, r)
+	}
+	preview := &RulesPreview{Rules: make([]*Rule, 0, len(clones))}
+	for _, r := range clones {
+		preview.Rules = append(preview.Rules, r.Clone())
+	}
+	oldList, newList, keyRanges, err := m.buildPreviewRuleList(clones, func(newList ruleList, p *ruleConfigPatch) {
+		oldApplied, newApplied := m.ruleList.appliedRuleKeys(), newList.appliedRuleKeys()
+		for key := range oldApplied {
+			if _, ok := newApplied[key]; !ok && p.mut.rules[key] == nil {
+				preview.RedundantRules = append(preview.RedundantRules, m.ruleConfig.getRule(key).Clone())
+			}
+		}
+		sortRules(preview.RedundantRules)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preview.Movements = m.previewPeerMovements(scanRegions(regionSet, keyRanges), oldList, newList)
+	for _, mv := range preview.Movements {
+		preview.AddPeers += mv.AddPeers
+		preview.RemovePeers += mv.RemovePeers
+	}
+	return preview, nil
+}
+
+// buildPreviewRuleList builds the rule list with the rules set under the lock,
+// and calls f with it and the patch before releasing the lock. It returns the
+// current and the new rule lists, and the key ranges of the regions affected,
+// i.e., the ranges of the rules and the ones replaced by them, so the regions
+// can be scanned and fitted without holding the lock.
+func (m *RuleManager) buildPreviewRuleList(rules []*Rule, f func(newList ruleList, p *ruleConfigPatch)) (
+	oldList, newList ruleList, keyRanges [][2][]byte, err error) {
+	// buildRuleList needs the rule group of all rules to be setup, which is
+	// done by patch.adjust and requires the write lock.
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	for _, r := range rules {
+		p.setRule(r)
+	}
+	p.adjust()
+	newList, err = buildRuleList(p)
+	if err != nil {
+		return ruleList{}, ruleList{}, nil, err
+	}
+	for _, r := range rules {
+		keyRanges = append(keyRanges, [2][]byte{r.StartKey, r.EndKey})
+		if old := m.ruleConfig.getRule(r.Key()); old != nil {
+			keyRanges = append(keyRanges, [2][]byte{old.StartKey, old.EndKey})
+		}
+	}
+	f(newList, p)
+	return m.ruleList, newList, keyRanges, nil
+}
+
+func scanRegions(regionSet interface {
+	ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+}, keyRanges [][2][]byte) []*core.RegionInfo {
+	var regions []*core.RegionInfo
+	for _, keyRange := range keyRanges {
+		regions = append(regions, regionSet.ScanRegions(keyRange[0], keyRange[1], -1)...)
+	}
+	return regions
+}
+
+// previewPeerMovements returns the peer movements of the regions whose applied
+// rules would change from the old rule list to the new one, in the order of the
+// region IDs. The regions may be duplicated.
+func (m *RuleManager) previewPeerMovements(regions []*core.RegionInfo, oldList, newList ruleList) []*PeerMovement {
+	var movements []*PeerMovement
+	visited := make(map[uint64]struct{}, len(regions))
+	for _, region := range regions {
+		if _, ok := visited[region.GetID()]; ok {
+			continue
+		}
+		visited[region.GetID()] = struct{}{}
+		oldRules := oldList.getRulesForApplyRange(region.GetStartKey(), region.GetEndKey())
+		newRules := newList.getRulesForApplyRange(region.GetStartKey(), region.GetEndKey())
+		if equalRules(oldRules, newRules) {
+			continue
+		}
+		mv := &PeerMovement{RegionID: region.GetID()}
+		movements = append(movements, mv)
+		// the region will be split by the rule checker before fitting it.
+		if len(oldRules) == 0 || len(newRules) == 0 {
+			mv.Split = true
+			continue
+		}
+		stores := getStoresByRegion(m.storeSetInformer, region)
+		oldMissing, oldOrphans := countFitChanges(fitRegion(stores, region, oldRules, m.conf.IsWitnessAllowed()))
+		newMissing, newOrphans := countFitChanges(fitRegion(stores, region, newRules, m.conf.IsWitnessAllowed()))
+		if newMissing > oldMissing {
+			mv.AddPeers = newMissing - oldMissing
+		}
+		if newOrphans > oldOrphans {
+			mv.RemovePeers = newOrphans - oldOrphans
+		}
+	}
+	sort.Slice(movements, func(i, j int) bool { return movements[i].RegionID < movements[j].RegionID })
+	return movements
+}
+
+func (rl ruleList) appliedRuleKeys() map[[2]string]struct{} {
+	keys := make(map[[2]string]struct{})
+	for _, rr := range rl.ranges {
+		for _, r := range rr.applyRules {
+			keys[r.Key()] = struct{}{}
+		}
+	}
+	return keys
+}
+
+func containsRule(rules []*Rule, rule *Rule) bool {
+	for _, r := range rules {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+func equalRules(a, b []*Rule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// overridesRule returns whether r takes precedence over the rule and drops it.
+func overridesRule(r, rule *Rule) bool {
+	if compareRule(r, rule) <= 0 {
+		return false
+	}
+	if r.GroupID == rule.GroupID {
+		return r.Override
+	}
+	return r.group != nil && r.group.Override
+}
+
+// countFitChanges returns the number of peers the region misses and the
+// number of orphan peers to be removed under the fit.
+func countFitChanges(fit *RegionFit) (missing, orphans int) {
+	for _, rf := range fit.RuleFits {
+		if n := rf.Rule.Count - len(rf.Peers); n > 0 {
+			missing += n
+		}
+	}
+	return missing, len(fit.OrphanPeers)
+}
@@ -0,0 +1,196 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"go.uber.org/zap"
+)
+
+// DiscrepancyType is the type of a discrepancy found by Reconcile.
+type DiscrepancyType string
+
+const (
+	// DiscrepancyMissing means the item is in the RuleManager but not in the storage.
+	DiscrepancyMissing DiscrepancyType = "missing"
+	// DiscrepancyExtra means the item is in the storage but not in the RuleManager.
+	DiscrepancyExtra DiscrepancyType = "extra"
+	// DiscrepancyDiffering means the item in the storage differs from the one in the RuleManager.
+	DiscrepancyDiffering DiscrepancyType = "differing"
+)
+
+// DiscrepancyKind is the kind of the item of a discrepancy.
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyKindRule is the discrepancy of a rule.
+	DiscrepancyKindRule DiscrepancyKind = "rule"
+	// DiscrepancyKindRuleGroup is the discrepancy of a rule group.
+	DiscrepancyKindRuleGroup DiscrepancyKind = "rule_group"
+)
+
+// Discrepancy is a difference between a RuleStorage and the RuleManager. The
+// Key is the key of the item in the storage, Expected is the item in the
+// RuleManager and Actual is the one in the storage, which is the raw value if
+// it can not be unmarshalled.
+type Discrepancy struct {
+	Type     DiscrepancyType `json:"type"`
+	Kind     DiscrepancyKind `json:"kind"`
+	Key      string          `json:"key"`
+	Expected interface{}     `json:"expected,omitempty"`
+	Actual   interface{}     `json:"actual,omitempty"`
+	Changes  []FieldChange   `json:"changes,omitempty"`
+}
+
+// Reconcile loads the rules and rule groups from the given storage and
+// compares them with the ones cached in the RuleManager, which are regarded
+// as authoritative. The discrepancies are sorted by the kind and then the key.
+// The rule groups with the default configuration are ignored, since they are
+// not persisted.
+func (m *RuleManager) Reconcile(storage endpoint.RuleStorage) ([]Discrepancy, error) {
+	storedRules := make(map[string]string)
+	if err := storage.LoadRules(func(k, v string) { storedRules[k] = v }); err != nil {
+		return nil, err
+	}
+	storedGroups := make(map[string]string)
+	if err := storage.LoadRuleGroups(func(k, v string) { storedGroups[k] = v }); err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+	var discrepancies []Discrepancy
+	for _, r := range m.ruleConfig.rules {
+		key := r.StoreKey()
+		v, ok := storedRules[key]
+		delete(storedRules, key)
+		if !ok {
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyMissing, Kind: DiscrepancyKindRule, Key: key, Expected: r.Clone()})
+			continue
+		}
+		stored, err := NewRuleFromJSON([]byte(v))
+		if err != nil {
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyDiffering, Kind: DiscrepancyKindRule, Key: key, Expected: r.Clone(), Actual: v})
+			continue
+		}
+		changes := diffRule(r, stored)
+		if stored.Key() != r.Key() {
+			changes = append(changes, FieldChange{Field: "key", Old: r.Key(), New: stored.Key()})
+		}
+		if len(changes) > 0 {
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyDiffering, Kind: DiscrepancyKindRule, Key: key, Expected: r.Clone(), Actual: stored, Changes: changes})
+		}
+	}
+	for key, v := range storedRules {
+		var actual interface{} = v
+		if stored, err := NewRuleFromJSON([]byte(v)); err == nil {
+			actual = stored
+		}
+		discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyExtra, Kind: DiscrepancyKindRule, Key: key, Actual: actual})
+	}
+
+	for id, g := range m.ruleConfig.groups {
+		if g.isDefault() {
+			continue
+		}
+		v, ok := storedGroups[id]
+		delete(storedGroups, id)
+		if !ok {
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyMissing, Kind: DiscrepancyKindRuleGroup, Key: id, Expected: g})
+			continue
+		}
+		stored := &RuleGroup{}
+		if err := json.Unmarshal([]byte(v), stored); err != nil {
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyDiffering, Kind: DiscrepancyKindRuleGroup, Key: id, Expected: g, Actual: v})
+			continue
+		}
+		if diff := DiffRuleGroups([]*RuleGroup{g}, []*RuleGroup{stored}); len(diff.Modified) > 0 || stored.ID != id {
+			var changes []FieldChange
+			if len(diff.Modified) > 0 {
+				changes = diff.Modified[0].Changes
+			}
+			if stored.ID != id {
+				changes = append(changes, FieldChange{Field: "id", Old: id, New: stored.ID})
+			}
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyDiffering, Kind: DiscrepancyKindRuleGroup, Key: id, Expected: g, Actual: stored, Changes: changes})
+		}
+	}
+	for id, v := range storedGroups {
+		stored := &RuleGroup{}
+		if err := json.Unmarshal([]byte(v), stored); err == nil {
+			if stored.isDefault() && stored.ID == id {
+				continue
+			}
+			discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyExtra, Kind: DiscrepancyKindRuleGroup, Key: id, Actual: stored})
+			continue
+		}
+		discrepancies = append(discrepancies, Discrepancy{Type: DiscrepancyExtra, Kind: DiscrepancyKindRuleGroup, Key: id, Actual: v})
+	}
+
+	sort.Slice(discrepancies, func(i, j int) bool {
+		if discrepancies[i].Kind != discrepancies[j].Kind {
+			return discrepancies[i].Kind < discrepancies[j].Kind
+		}
+		return discrepancies[i].Key < discrepancies[j].Key
+	})
+	return discrepancies, nil
+}
+
+// Repair re-emits the authoritative rules and rule groups of the discrepancies
+// to the storage of the RuleManager, so that the watchers of the storage can
+// catch up with them. The missing and differing items are saved again, and
+// the extra ones are removed.
+func (m *RuleManager) Repair(discrepancies []Discrepancy) error {
+	m.RLock()
+	defer m.RUnlock()
+	var ops []func(txn kv.Txn) error
+	for _, d := range discrepancies {
+		key := d.Key
+		switch d.Kind {
+		case DiscrepancyKindRule:
+			var rule *Rule
+			for _, r := range m.ruleConfig.rules {
+				if r.StoreKey() == key {
+					rule = r
+					break
+				}
+			}
+			ops = append(ops, func(txn kv.Txn) error {
+				if rule == nil {
+					return m.storage.DeleteRule(txn, key)
+				}
+				return m.storage.SaveRule(txn, key, rule)
+			})
+		case DiscrepancyKindRuleGroup:
+			g, ok := m.ruleConfig.groups[key]
+			ops = append(ops, func(txn kv.Txn) error {
+				if !ok || g.isDefault() {
+					return m.storage.DeleteRuleGroup(txn, key)
+				}
+				return m.storage.SaveRuleGroup(txn, key, g)
+			})
+		}
+	}
+	if err := m.runInTxns(ops); err != nil {
+		return err
+	}
+	log.Info("rule discrepancies repaired", zap.Int("count", len(ops)))
+	return nil
+}
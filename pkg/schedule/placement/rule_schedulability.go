@@ -0,0 +1,118 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// maxReportedUnschedulableRegions is the max number of the region IDs reported
+// by the error of the schedulability check.
+const maxReportedUnschedulableRegions = 16
+
+// checkSchedulable rejects the patch if it makes any region unschedulable,
+// see findUnschedulableRegions.
+func (m *RuleManager) checkSchedulable(p *ruleConfigPatch, ruleList ruleList) error {
+	ids := m.findUnschedulableRegions(p, ruleList)
+	if len(ids) == 0 {
+		return nil
+	}
+	reported := ids
+	if len(reported) > maxReportedUnschedulableRegions {
+		reported = reported[:maxReportedUnschedulableRegions]
+	}
+	return errs.ErrRuleUnschedulable.FastGenByArgs(len(ids), reported)
+}
+
+// findUnschedulableRegions returns the sorted IDs of the regions in the ranges
+// changed by the patch, whose rules to apply can be satisfied by the stores
+// now but not after the patch is committed, e.g., the rule requires more peers
+// than the stores it matches. Nothing is found if the regions are not available.
+func (m *RuleManager) findUnschedulableRegions(p *ruleConfigPatch, ruleList ruleList) []uint64 {
+	scanner, ok := m.storeSetInformer.(interface {
+		ScanRegions(startKey, endKey []byte, limit int) []*core.RegionInfo
+	})
+	if !ok {
+		return nil
+	}
+	ranges := m.changedRanges(p)
+	if ranges == nil {
+		// the groups are changed, all the ranges may be affected.
+		ranges = [][2][]byte{{nil, nil}}
+	}
+	stores := m.getAliveStores()
+	// the regions applying the same rules share the result.
+	satisfiable := make(map[string]bool)
+	isSatisfiable := func(rules []*Rule) bool {
+		keys := make([]string, 0, len(rules))
+		for _, r := range rules {
+			keys = append(keys, r.StoreKey())
+		}
+		key := strings.Join(keys, ",")
+		res, ok := satisfiable[key]
+		if !ok {
+			res = rulesSatisfiable(rules, stores)
+			satisfiable[key] = res
+		}
+		return res
+	}
+	var ids []uint64
+	visited := make(map[uint64]struct{})
+	for _, r := range ranges {
+		for _, region := range scanner.ScanRegions(r[0], r[1], -1) {
+			if _, ok := visited[region.GetID()]; ok {
+				continue
+			}
+			visited[region.GetID()] = struct{}{}
+			oldRules := m.ruleList.getActiveRulesForApplyRange(region.GetStartKey(), region.GetEndKey(), m.inactiveRules)
+			newRules := ruleList.getActiveRulesForApplyRange(region.GetStartKey(), region.GetEndKey(), m.inactiveRules)
+			if equalRules(oldRules, newRules) || len(newRules) == 0 {
+				continue
+			}
+			if !isSatisfiable(newRules) && isSatisfiable(oldRules) {
+				ids = append(ids, region.GetID())
+			}
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// rulesSatisfiable checks whether the rules applied to a region can be
+// satisfied by the stores together: each rule matches enough stores for its
+// peers, and the stores matched by any rule are enough for all the peers,
+// since a store holds at most one peer of a region.
+func rulesSatisfiable(rules []*Rule, stores []*core.StoreInfo) bool {
+	matched := make(map[uint64]struct{})
+	peers := 0
+	for _, r := range rules {
+		count := 0
+		for _, s := range stores {
+			if r.MatchStore(s) {
+				count++
+				matched[s.GetID()] = struct{}{}
+			}
+		}
+		if count < r.Count {
+			return false
+		}
+		peers += r.Count
+	}
+	return peers <= len(matched)
+}
@@ -0,0 +1,255 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/storage/kv"
+	"go.uber.org/zap"
+)
+
+// rollbackRuleSetName is the reserved name of the rule set which keeps the
+// configuration replaced by the last activation, see RollbackRuleSet.
+const rollbackRuleSetName = ".rollback"
+
+// RuleSet is a complete rule configuration staged to replace the current one
+// at once, see RuleManager.ActivateRuleSet.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleSet struct {
+	Name   string       `json:"name"`
+	Rules  []*Rule      `json:"rules"`
+	Groups []*RuleGroup `json:"groups,omitempty"`
+}
+
+// RuleSetState records the activations of the rule sets.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleSetState struct {
+	// Active is the name of the last activated rule set.
+	Active string `json:"active,omitempty"`
+	// Previous is the name of the rule set active before the last activation,
+	// which is active again after the rollback.
+	Previous string `json:"previous,omitempty"`
+}
+
+func checkRuleSetName(name string) error {
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid rule set name %q", name))
+	}
+	return nil
+}
+
+// StageRuleSet saves the rules and rule groups as the rule set with the given
+// name, which replaces the current configuration once it's activated. The
+// rule set is validated as a whole, but the current configuration is left as
+// is. The existing rule set with the same name is overwritten.
+func (m *RuleManager) StageRuleSet(name string, rules []*Rule, groups []*RuleGroup) error {
+	if err := checkRuleSetName(name); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	// validate the rule set by applying it to an empty configuration.
+	p := newRuleConfig().beginPatch()
+	set := &RuleSet{Name: name, Rules: make([]*Rule, 0, len(rules)), Groups: groups}
+	for _, r := range rules {
+		r = r.Clone()
+		if err := m.adjustRule(r, ""); err != nil {
+			return err
+		}
+		if err := m.validateTopologyIfEnabled(r); err != nil {
+			return err
+		}
+		if _, ok := p.mut.rules[r.Key()]; ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("duplicate rule %s in group %s", r.ID, r.GroupID))
+		}
+		p.setRule(r)
+		set.Rules = append(set.Rules, r)
+	}
+	for _, g := range groups {
+		if err := checkAnnotations(g.Annotations); err != nil {
+			return err
+		}
+		p.setGroup(g)
+	}
+	if err := checkGroupParents(p); err != nil {
+		return err
+	}
+	p.adjust()
+	if _, err := buildRuleList(p); err != nil {
+		return err
+	}
+	if err := m.storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		return m.storage.SaveRuleSet(txn, name, set)
+	}); err != nil {
+		return err
+	}
+	log.Info("rule set staged", zap.String("name", name), zap.Int("rules", len(set.Rules)), zap.Int("groups", len(groups)))
+	return nil
+}
+
+// ActivateRuleSet replaces the current rules and rule groups with the staged
+// rule set. The replaced configuration is kept for RollbackRuleSet. All the
+// changes are saved in one transaction with the state of the rule sets, so
+// the watchers observe the swap as a whole, and it fails if there are too
+// many changes to be saved in one transaction.
+func (m *RuleManager) ActivateRuleSet(name string) error {
+	if err := checkRuleSetName(name); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	set, err := m.loadRuleSet(name)
+	if err != nil {
+		return err
+	}
+	state, err := m.loadRuleSetState()
+	if err != nil {
+		return err
+	}
+	rollback := &RuleSet{Name: rollbackRuleSetName}
+	for _, r := range m.ruleConfig.rules {
+		rollback.Rules = append(rollback.Rules, r.Clone())
+	}
+	for _, g := range m.ruleConfig.groups {
+		if !g.isDefault() {
+			rollback.Groups = append(rollback.Groups, g)
+		}
+	}
+	next := &RuleSetState{Active: name, Previous: state.Active}
+	if err := m.swapRuleSet(set,
+		func(txn kv.Txn) error { return m.storage.SaveRuleSet(txn, rollbackRuleSetName, rollback) },
+		func(txn kv.Txn) error { return m.storage.SaveRuleSetState(txn, next) },
+	); err != nil {
+		return err
+	}
+	log.Info("rule set activated", zap.String("name", name), zap.String("previous", state.Active))
+	return nil
+}
+
+// RollbackRuleSet restores the configuration replaced by the last activation,
+// it can only roll back once after each activation.
+func (m *RuleManager) RollbackRuleSet() error {
+	m.Lock()
+	defer m.Unlock()
+	set, err := m.loadRuleSet(rollbackRuleSetName)
+	if err != nil {
+		return err
+	}
+	state, err := m.loadRuleSetState()
+	if err != nil {
+		return err
+	}
+	next := &RuleSetState{Active: state.Previous}
+	if err := m.swapRuleSet(set,
+		func(txn kv.Txn) error { return m.storage.DeleteRuleSet(txn, rollbackRuleSetName) },
+		func(txn kv.Txn) error { return m.storage.SaveRuleSetState(txn, next) },
+	); err != nil {
+		return err
+	}
+	log.Info("rule set rolled back", zap.String("active", state.Previous), zap.String("rolled-back", state.Active))
+	return nil
+}
+
+// GetRuleSetState returns the state of the rule sets.
+func (m *RuleManager) GetRuleSetState() (*RuleSetState, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.loadRuleSetState()
+}
+
+// swapRuleSet replaces the current configuration with the rule set, and saves
+// the extra operations in the same transaction.
+func (m *RuleManager) swapRuleSet(set *RuleSet, extraOps ...func(txn kv.Txn) error) error {
+	p := m.beginPatch()
+	// the rule set is a whole configuration, it may place the regions without
+	// the default rule.
+	p.deleteDefaultRule = true
+	keepRules := make(map[[2]string]struct{}, len(set.Rules))
+	for _, r := range set.Rules {
+		// the stores may be changed since the rule set is staged, so check it again.
+		if err := m.adjustRule(r, ""); err != nil {
+			return err
+		}
+		keepRules[r.Key()] = struct{}{}
+		if old := m.ruleConfig.getRule(r.Key()); old != nil && len(diffRule(old, r)) == 0 {
+			continue
+		}
+		p.setRule(r)
+	}
+	for key := range m.ruleConfig.rules {
+		if _, ok := keepRules[key]; !ok {
+			p.deleteRule(key[0], key[1])
+		}
+	}
+	keepGroups := make(map[string]struct{}, len(set.Groups))
+	for _, g := range set.Groups {
+		keepGroups[g.ID] = struct{}{}
+		if !jsonEquals(g, m.ruleConfig.groups[g.ID]) {
+			p.setGroup(g)
+		}
+	}
+	for id, g := range m.ruleConfig.groups {
+		if _, ok := keepGroups[id]; !ok && !g.isDefault() {
+			p.deleteGroup(id)
+		}
+	}
+	if ops := len(p.mut.rules) + len(p.mut.groups) + len(extraOps) + historyTxnOps; ops > maxEtcdTxnOps {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("too many changes of rule set %s to be saved in one transaction", set.Name))
+	}
+	p.changeFrozen = true
+	p.extraOps = extraOps
+	return m.tryCommitPatch(p)
+}
+
+func (m *RuleManager) loadRuleSet(name string) (*RuleSet, error) {
+	var (
+		set *RuleSet
+		err error
+	)
+	if loadErr := m.storage.LoadRuleSets(func(k, v string) {
+		if k != name {
+			return
+		}
+		set = &RuleSet{}
+		err = json.Unmarshal([]byte(v), set)
+	}); loadErr != nil {
+		return nil, loadErr
+	}
+	if err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByCause()
+	}
+	if set == nil {
+		return nil, errs.ErrRuleSetNotFound.FastGenByArgs(name)
+	}
+	return set, nil
+}
+
+func (m *RuleManager) loadRuleSetState() (*RuleSetState, error) {
+	state := &RuleSetState{}
+	v, err := m.storage.LoadRuleSetState()
+	if err != nil || v == "" {
+		return state, err
+	}
+	if err := json.Unmarshal([]byte(v), state); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByCause()
+	}
+	return state, nil
+}
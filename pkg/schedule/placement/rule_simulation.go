@@ -0,0 +1,132 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/core"
+)
+
+// SimulationReport is the estimated effect of a rule set on a snapshot of regions.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type SimulationReport struct {
+	RegionCount int `json:"region_count"`
+	// RebalanceRegions are the IDs of the regions not satisfied by the rules,
+	// which need to be scheduled by the rule checker.
+	RebalanceRegions []uint64 `json:"rebalance_regions"`
+	// SplitRegions are the IDs of the regions crossing the boundaries of the
+	// rules, which will be split before being scheduled.
+	SplitRegions []uint64 `json:"split_regions"`
+	// AddPeers and RemovePeers are the number of peers to be added and removed,
+	// and PeerMovements is the estimated number of the peers to be moved, since
+	// a move is composed of an addition and a removal.
+	AddPeers      int `json:"add_peers"`
+	RemovePeers   int `json:"remove_peers"`
+	PeerMovements int `json:"peer_movements"`
+	// UnschedulableRegions are the regions which can never be satisfied by the
+	// rules, since there are not enough stores matching the rules.
+	UnschedulableRegions []UnschedulableRegion `json:"unschedulable_regions"`
+}
+
+// UnschedulableRegion is a region which can never be satisfied by a rule.
+type UnschedulableRegion struct {
+	RegionID uint64    `json:"region_id"`
+	Rule     [2]string `json:"rule"`
+}
+
+// Simulate computes the effect of replacing all the rules with the given ones
+// on the given regions, the rule groups are kept unchanged. Neither the rules
+// nor the regions are changed, so it can be used to compare the candidate rule
+// sets against the historical regions offline.
+func (m *RuleManager) Simulate(rules []*Rule, regions []*core.RegionInfo) (*SimulationReport, error) {
+	if m.storeSetInformer == nil {
+		return nil, errors.New("the stores are unknown to simulate the rules")
+	}
+	clones := make([]*Rule, 0, len(rules))
+	for _, r := range rules {
+		r = r.Clone()
+		if err := m.adjustRule(r, ""); err != nil {
+			return nil, err
+		}
+		clones = append(clones, r)
+	}
+	// all the rules are replaced, so the rule list is built from the clones
+	// and a snapshot of the rule groups, without holding the lock.
+	c := m.snapshotRuleGroups()
+	for _, r := range clones {
+		c.setRule(r)
+	}
+	c.adjust()
+	ruleList, err := buildRuleList(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var stores []*core.StoreInfo
+	for _, s := range m.storeSetInformer.GetStores() {
+		if !s.IsRemoved() {
+			stores = append(stores, s)
+		}
+	}
+	report := &SimulationReport{RegionCount: len(regions)}
+	for _, region := range regions {
+		applyRules := ruleList.getRulesForApplyRange(region.GetStartKey(), region.GetEndKey())
+		if len(applyRules) == 0 {
+			report.SplitRegions = append(report.SplitRegions, region.GetID())
+			continue
+		}
+		regionStores := getStoresByRegion(m.storeSetInformer, region)
+		fit := fitRegion(regionStores, region, applyRules, m.conf.IsWitnessAllowed())
+		fit.regionStores = regionStores
+		if fit.IsSatisfied() {
+			continue
+		}
+		report.RebalanceRegions = append(report.RebalanceRegions, region.GetID())
+		missing, orphans := countFitChanges(fit)
+		report.AddPeers += missing
+		report.RemovePeers += orphans
+		if missing > orphans {
+			report.PeerMovements += missing
+		} else {
+			report.PeerMovements += orphans
+		}
+		for _, rf := range fit.RuleFits {
+			if !rf.IsSatisfied() && fitFailureReason(rf, fit, stores) == FitFailureInsufficientStores {
+				report.UnschedulableRegions = append(report.UnschedulableRegions, UnschedulableRegion{RegionID: region.GetID(), Rule: rf.Rule.Key()})
+			}
+		}
+	}
+	sort.Slice(report.RebalanceRegions, func(i, j int) bool { return report.RebalanceRegions[i] < report.RebalanceRegions[j] })
+	sort.Slice(report.SplitRegions, func(i, j int) bool { return report.SplitRegions[i] < report.SplitRegions[j] })
+	sort.SliceStable(report.UnschedulableRegions, func(i, j int) bool {
+		return report.UnschedulableRegions[i].RegionID < report.UnschedulableRegions[j].RegionID
+	})
+	return report, nil
+}
+
+// snapshotRuleGroups returns a rule config containing the copies of the current
+// rule groups only.
+func (m *RuleManager) snapshotRuleGroups() *ruleConfig {
+	m.RLock()
+	defer m.RUnlock()
+	c := newRuleConfig()
+	for id, g := range m.ruleConfig.groups {
+		clone := *g
+		c.groups[id] = &clone
+	}
+	return c
+}
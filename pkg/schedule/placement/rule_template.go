@@ -0,0 +1,445 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// RuleTemplate defines everything of a rule except the key range. The rules
+// derived from it by InstantiateTemplate refer to it by TemplateID, and they
+// are updated together once the template is changed.
+//
+// The values of the label constraints can reference the store labels by the
+// variables like ${zone}, then one rule is derived for each combination of
+// the label values of the stores in every key range, e.g., the rules of the
+// template with the constraint `zone in ${zone}` place the peers in each zone.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleTemplate struct {
+	ID               string            `json:"id"`
+	GroupID          string            `json:"group_id"`
+	Index            int               `json:"index,omitempty"`
+	Override         bool              `json:"override,omitempty"`
+	Role             PeerRoleType      `json:"role"`
+	IsWitness        bool              `json:"is_witness"`
+	Count            int               `json:"count"`
+	LabelConstraints []LabelConstraint `json:"label_constraints,omitempty"`
+	LocationLabels   []string          `json:"location_labels,omitempty"`
+	IsolationLevel   string            `json:"isolation_level,omitempty"`
+}
+
+// NewRuleTemplateFromJSON creates a rule template from the JSON data.
+func NewRuleTemplateFromJSON(data []byte) (*RuleTemplate, error) {
+	t := &RuleTemplate{}
+	if err := json.Unmarshal(data, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// derive returns the rule with the given ID derived from the template.
+func (t *RuleTemplate) derive(id string, startKey, endKey []byte) *Rule {
+	return &Rule{
+		GroupID:          t.GroupID,
+		ID:               id,
+		Index:            t.Index,
+		Override:         t.Override,
+		StartKeyHex:      hex.EncodeToString(startKey),
+		EndKeyHex:        hex.EncodeToString(endKey),
+		Role:             t.Role,
+		IsWitness:        t.IsWitness,
+		Count:            t.Count,
+		LabelConstraints: append(t.LabelConstraints[:0:0], t.LabelConstraints...),
+		LocationLabels:   append(t.LocationLabels[:0:0], t.LocationLabels...),
+		IsolationLevel:   t.IsolationLevel,
+		TemplateID:       t.ID,
+	}
+}
+
+func derivedRuleID(templateID string, i int) string {
+	return fmt.Sprintf("%s-%d", templateID, i)
+}
+
+// templateVarPattern matches the variables in the values of the label
+// constraints, whose names are the label keys.
+var templateVarPattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// variables returns the sorted label keys referenced by the template.
+func (t *RuleTemplate) variables() []string {
+	set := make(map[string]struct{})
+	for _, c := range t.LabelConstraints {
+		for _, v := range c.Values {
+			for _, m := range templateVarPattern.FindAllStringSubmatch(v, -1) {
+				set[m[1]] = struct{}{}
+			}
+		}
+	}
+	vars := make([]string, 0, len(set))
+	for v := range set {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	return vars
+}
+
+// deriveAll returns the rules derived from the template in the key range. If
+// the template has no variable, it's the rule with the base ID. Otherwise, it's
+// one rule for each combination of the label values of the stores, whose ID is
+// the base ID suffixed with the values. There is no rule if no store has the
+// labels.
+func (t *RuleTemplate) deriveAll(baseID string, startKey, endKey []byte, stores []*core.StoreInfo) []*Rule {
+	vars := t.variables()
+	if len(vars) == 0 {
+		return []*Rule{t.derive(baseID, startKey, endKey)}
+	}
+	combinations := []map[string]string{{}}
+	for _, key := range vars {
+		values := labelValues(stores, key)
+		next := make([]map[string]string, 0, len(combinations)*len(values))
+		for _, c := range combinations {
+			for _, v := range values {
+				n := make(map[string]string, len(c)+1)
+				for k, cv := range c {
+					n[k] = cv
+				}
+				n[key] = v
+				next = append(next, n)
+			}
+		}
+		combinations = next
+	}
+	rules := make([]*Rule, 0, len(combinations))
+	for _, c := range combinations {
+		rule := t.derive(baseID+templateVarsSuffix(c), startKey, endKey)
+		for i := range rule.LabelConstraints {
+			lc := &rule.LabelConstraints[i]
+			values := make([]string, 0, len(lc.Values))
+			for _, v := range lc.Values {
+				values = append(values, templateVarPattern.ReplaceAllStringFunc(v, func(m string) string {
+					return c[m[2:len(m)-1]]
+				}))
+			}
+			lc.Values = values
+		}
+		rule.TemplateVars = c
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// templateVarsSuffix returns the suffix of the ID of the rule derived with the
+// variables, which is the values joined in the order of the label keys.
+func templateVarsSuffix(vars map[string]string) string {
+	if len(vars) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString("-")
+		b.WriteString(vars[k])
+	}
+	return b.String()
+}
+
+// labelValues returns the sorted distinct values of the label of the stores.
+func labelValues(stores []*core.StoreInfo, key string) []string {
+	set := make(map[string]struct{})
+	for _, s := range stores {
+		if v := s.GetLabelValue(key); v != "" {
+			set[v] = struct{}{}
+		}
+	}
+	values := make([]string, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func (m *RuleManager) loadTemplates() error {
+	return m.storage.LoadRuleTemplates(func(k, v string) {
+		t, err := NewRuleTemplateFromJSON([]byte(v))
+		if err != nil {
+			log.Error("failed to unmarshal rule template", zap.String("template-id", k), errs.ZapError(errs.ErrLoadRule, err))
+			return
+		}
+		m.ruleConfig.templates[t.ID] = t
+	})
+}
+
+func (m *RuleManager) checkTemplate(t *RuleTemplate, stores []*core.StoreInfo) error {
+	if t.ID == "" {
+		return errs.ErrRuleContent.FastGenByArgs("template ID should not be empty")
+	}
+	// check the content by the rules covering the whole key space.
+	rules := t.deriveAll(derivedRuleID(t.ID, 0), nil, nil, stores)
+	if len(rules) == 0 {
+		// no store has the labels of the variables yet, so only the content
+		// without the topology can be checked.
+		return m.adjustRule(t.derive(derivedRuleID(t.ID, 0), nil, nil), "")
+	}
+	for _, rule := range rules {
+		if err := m.adjustRule(rule, ""); err != nil {
+			return err
+		}
+		if err := m.validateTopologyIfEnabled(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateRuleRanges returns the rules derived from the template by their base
+// IDs, which are the IDs without the suffixes of the variables. The rules with
+// the same base ID share the key range.
+func (m *RuleManager) templateRuleRanges(templateID string) map[string]*Rule {
+	ranges := make(map[string]*Rule)
+	for _, r := range m.ruleConfig.rules {
+		if r.TemplateID == templateID {
+			ranges[strings.TrimSuffix(r.ID, templateVarsSuffix(r.TemplateVars))] = r
+		}
+	}
+	return ranges
+}
+
+// rederiveTemplate derives the rules from the template again in the key ranges
+// of the derived rules. If onlyMissing is true, only the rules of the new label
+// values are added, otherwise all the derived rules are replaced. It returns
+// the number of the changed rules, and must be called with the lock held.
+func (m *RuleManager) rederiveTemplate(p *ruleConfigPatch, t *RuleTemplate, stores []*core.StoreInfo, onlyMissing bool) (int, error) {
+	var changed int
+	derived := make(map[[2]string]struct{})
+	for baseID, r := range m.templateRuleRanges(t.ID) {
+		rules := t.deriveAll(baseID, r.StartKey, r.EndKey, stores)
+		if len(rules) == 0 {
+			return 0, errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("no store has the labels %v of template %s", t.variables(), t.ID))
+		}
+		for _, rule := range rules {
+			derived[rule.Key()] = struct{}{}
+			existing := m.ruleConfig.getRule(rule.Key())
+			if onlyMissing && existing != nil {
+				continue
+			}
+			if existing != nil && existing.TemplateID != t.ID {
+				return 0, errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("rule %s/%s is not derived from template %s", rule.GroupID, rule.ID, t.ID))
+			}
+			if err := m.adjustRule(rule, ""); err != nil {
+				return 0, err
+			}
+			p.setRule(rule)
+			changed++
+		}
+	}
+	if onlyMissing {
+		return changed, nil
+	}
+	// the group of the template may be changed, and the label values may be
+	// gone.
+	for key, r := range m.ruleConfig.rules {
+		if _, ok := derived[key]; !ok && r.TemplateID == t.ID {
+			p.deleteRule(key[0], key[1])
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// GetRuleTemplate returns the rule template with the same ID.
+func (m *RuleManager) GetRuleTemplate(id string) *RuleTemplate {
+	m.RLock()
+	defer m.RUnlock()
+	return m.ruleConfig.templates[id]
+}
+
+// GetRuleTemplates returns all rule templates sorted by the ID.
+func (m *RuleManager) GetRuleTemplates() []*RuleTemplate {
+	m.RLock()
+	defer m.RUnlock()
+	templates := make([]*RuleTemplate, 0, len(m.ruleConfig.templates))
+	for _, t := range m.ruleConfig.templates {
+		templates = append(templates, t)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].ID < templates[j].ID })
+	return templates
+}
+
+// SetRuleTemplate inserts or updates a rule template. The rules derived from
+// it are updated with their key ranges unchanged in the same patch.
+func (m *RuleManager) SetRuleTemplate(t *RuleTemplate) error {
+	stores := m.getAliveStores()
+	if err := m.checkTemplate(t, stores); err != nil {
+		return err
+	}
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	p.setTemplate(t)
+	changed, err := m.rederiveTemplate(p, t, stores, false)
+	if err != nil {
+		return err
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("rule template updated", zap.String("template-id", t.ID), zap.Int("changed-rule-count", changed))
+	return nil
+}
+
+// DeleteRuleTemplate removes a rule template and all the rules derived from it.
+func (m *RuleManager) DeleteRuleTemplate(id string) error {
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	p.deleteTemplate(id)
+	deleted := m.deleteTemplateRules(p, id)
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("rule template removed", zap.String("template-id", id), zap.Int("derived-rule-count", deleted))
+	return nil
+}
+
+// InstantiateTemplate materializes the rule template to one rule for each key
+// range, or the rules of the label values if it has variables. The rules
+// derived from the template before are replaced, so it can be called again to
+// change the ranges.
+func (m *RuleManager) InstantiateTemplate(templateID string, ranges []core.KeyRange) error {
+	stores := m.getAliveStores()
+	m.Lock()
+	defer m.Unlock()
+	t, ok := m.ruleConfig.templates[templateID]
+	if !ok {
+		return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("template %s not found", templateID))
+	}
+	p := m.beginPatch()
+	m.deleteTemplateRules(p, templateID)
+	for i, rg := range ranges {
+		rules := t.deriveAll(derivedRuleID(templateID, i), rg.StartKey, rg.EndKey, stores)
+		// the key ranges are kept by the derived rules, so they can't be empty.
+		if len(rules) == 0 {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("no store has the labels %v of template %s", t.variables(), templateID))
+		}
+		for _, rule := range rules {
+			if existing := m.ruleConfig.getRule(rule.Key()); existing != nil && existing.TemplateID != templateID {
+				return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("rule %s/%s is not derived from template %s", rule.GroupID, rule.ID, templateID))
+			}
+			if err := m.adjustRule(rule, ""); err != nil {
+				return err
+			}
+			p.setRule(rule)
+		}
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("rule template instantiated", zap.String("template-id", templateID), zap.Int("range-count", len(ranges)))
+	return nil
+}
+
+// RefreshTemplateRules derives the rules of the new label values of the stores
+// from the templates with variables, e.g., the rules of a new zone, in the key
+// ranges the templates are instantiated with. The rules of the gone label
+// values are kept until the templates are updated or instantiated again, in
+// case the stores are removed by mistake.
+func (m *RuleManager) RefreshTemplateRules() {
+	if _, err := m.refreshTemplateRules(); err != nil {
+		log.Warn("failed to refresh the rules derived from the templates", errs.ZapError(err))
+	}
+}
+
+// refreshTemplateRules returns the number of the added rules.
+func (m *RuleManager) refreshTemplateRules() (int, error) {
+	stores := m.getAliveStores()
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	var added int
+	for _, t := range m.ruleConfig.templates {
+		if len(t.variables()) == 0 {
+			continue
+		}
+		n, err := m.rederiveTemplate(p, t, stores, true)
+		if err != nil {
+			return 0, err
+		}
+		added += n
+	}
+	if added == 0 {
+		return 0, nil
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return 0, err
+	}
+	log.Info("derived rules of the new label values added", zap.Int("count", added))
+	return added, nil
+}
+
+// GetTemplateRules returns the sorted rules derived from the template.
+func (m *RuleManager) GetTemplateRules(templateID string) []*Rule {
+	m.RLock()
+	defer m.RUnlock()
+	var rules []*Rule
+	for _, r := range m.ruleConfig.rules {
+		if r.TemplateID == templateID {
+			rules = append(rules, r)
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+// DeleteTemplateRules removes all the rules derived from the template, and
+// keeps the template itself.
+func (m *RuleManager) DeleteTemplateRules(templateID string) error {
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	deleted := m.deleteTemplateRules(p, templateID)
+	if deleted == 0 {
+		return nil
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("derived rules removed", zap.String("template-id", templateID), zap.Int("count", deleted))
+	return nil
+}
+
+func (m *RuleManager) deleteTemplateRules(p *ruleConfigPatch, templateID string) int {
+	var deleted int
+	for key, r := range m.ruleConfig.rules {
+		if r.TemplateID == templateID {
+			p.deleteRule(key[0], key[1])
+			deleted++
+		}
+	}
+	return deleted
+}
@@ -16,9 +16,12 @@ package placement
 
 import (
 	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"math/rand"
 	"testing"
 
+	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -186,3 +189,183 @@ func TestBuildRuleList(t *testing.T) {
 		re.Equal(testCase.expect.ranges, result.ranges)
 	}
 }
+
+func TestNewRuleFromJSONStrict(t *testing.T) {
+	re := require.New(t)
+	data := []byte(`{"group_id":"g","id":"1","start_key":"","end_key":"","role":"voter","count":3}`)
+	rule, err := NewRuleFromJSONStrict(data)
+	re.NoError(err)
+	re.Equal(Voter, rule.Role)
+	re.Equal(3, rule.Count)
+
+	data = []byte(`{"group_id":"g","id":"1","start_key":"","end_key":"","Roles":"voter","count":3}`)
+	rule, err = NewRuleFromJSON(data)
+	re.NoError(err)
+	re.Empty(rule.Role)
+	_, err = NewRuleFromJSONStrict(data)
+	re.Error(err)
+	re.Contains(err.Error(), `unknown field "Roles"`)
+}
+
+func TestWitnessRoleJSON(t *testing.T) {
+	re := require.New(t)
+	data := []byte(`{"group_id":"g","id":"1","start_key":"","end_key":"","role":"witness","count":1}`)
+	rule, err := NewRuleFromJSON(data)
+	re.NoError(err)
+	re.Equal(Witness, rule.Role)
+	re.Equal(metapb.PeerRole_Voter, rule.Role.MetaPeerRole())
+	data, err = json.Marshal(rule)
+	re.NoError(err)
+	re.Contains(string(data), `"role":"witness"`)
+	rule2, err := NewRuleFromJSONStrict(data)
+	re.NoError(err)
+	re.Equal(rule, rule2)
+
+	// the invalid role is only rejected by the strict parsing.
+	data = []byte(`{"group_id":"g","id":"1","start_key":"","end_key":"","role":"master","count":1}`)
+	rule, err = NewRuleFromJSON(data)
+	re.NoError(err)
+	re.Equal(PeerRoleType("master"), rule.Role)
+	_, err = NewRuleFromJSONStrict(data)
+	re.Error(err)
+	re.Contains(err.Error(), "invalid role master")
+}
+
+func TestNormalizeKeys(t *testing.T) {
+	re := require.New(t)
+	rule := &Rule{StartKey: []byte{0x12}, EndKeyHex: "34"}
+	re.NoError(rule.NormalizeKeys())
+	re.Equal("12", rule.StartKeyHex)
+	re.Equal([]byte{0x34}, rule.EndKey)
+	// the consistent keys are kept.
+	re.NoError(rule.NormalizeKeys())
+	re.Equal("12", rule.StartKeyHex)
+	re.Equal([]byte{0x34}, rule.EndKey)
+
+	rule = &Rule{}
+	re.NoError(rule.NormalizeKeys())
+	re.Empty(rule.StartKeyHex)
+	re.Empty(rule.EndKey)
+
+	rule = &Rule{StartKey: []byte{0x12}, StartKeyHex: "13"}
+	err := rule.NormalizeKeys()
+	re.Error(err)
+	re.Contains(err.Error(), "start key 12 does not match the hex format 13")
+	rule = &Rule{EndKeyHex: "123"}
+	re.Error(rule.NormalizeKeys())
+
+	rule, err = NewRuleFromJSON([]byte(`{"group_id":"g","id":"1","start_key":"12","end_key":"34","role":"voter","count":3}`))
+	re.NoError(err)
+	re.Equal([]byte{0x12}, rule.StartKey)
+	re.Equal([]byte{0x34}, rule.EndKey)
+	_, err = NewRuleFromJSONStrict([]byte(`{"group_id":"g","id":"1","start_key":"xx","end_key":"","role":"voter","count":3}`))
+	re.Error(err)
+}
+
+func TestRuleKeyRange(t *testing.T) {
+	re := require.New(t)
+	_, manager := newTestManager(t, false)
+	testCases := []struct {
+		startKeyHex string
+		endKeyHex   string
+		valid       bool
+	}{
+		{"", "", true},
+		{"", "74", true},
+		{"74", "", true},
+		{"74", "75", true},
+		{"75", "74", false},
+		{"74", "74", false},
+	}
+	for _, tc := range testCases {
+		data := fmt.Sprintf(`{"group_id":"g","id":"1","start_key":"%s","end_key":"%s","role":"voter","count":3}`, tc.startKeyHex, tc.endKeyHex)
+		rule, err := NewRuleFromJSON([]byte(data))
+		_, strictErr := NewRuleFromJSONStrict([]byte(data))
+		setErr := manager.SetRule(&Rule{GroupID: "g", ID: "1", StartKeyHex: tc.startKeyHex, EndKeyHex: tc.endKeyHex, Role: Voter, Count: 3})
+		if tc.valid {
+			re.NoError(err)
+			re.NoError(strictErr)
+			re.NoError(setErr)
+			re.Equal(tc.startKeyHex, rule.StartKeyHex)
+			re.Equal(tc.endKeyHex, rule.EndKeyHex)
+			continue
+		}
+		// the inverted range is only rejected by the strict parsing.
+		re.NoError(err)
+		for _, err := range []error{strictErr, setErr} {
+			re.ErrorContains(err, fmt.Sprintf("start key %s should be less than end key %s", tc.startKeyHex, tc.endKeyHex))
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	re := require.New(t)
+	rule := &Rule{GroupID: "g", ID: "1", StartKeyHex: "12", EndKeyHex: "34", Role: Voter, Count: 3}
+	re.Empty(ValidateRule(rule))
+
+	// all the problems are reported.
+	rule = &Rule{
+		StartKeyHex:      "xx",
+		EndKeyHex:        "34",
+		Role:             "unknown",
+		Count:            -1,
+		LabelConstraints: []LabelConstraint{{Key: "zone", Op: "in"}, {Key: "disk", Op: "unknown"}},
+	}
+	var messages []string
+	for _, err := range ValidateRule(rule) {
+		messages = append(messages, err.Error())
+	}
+	re.Len(messages, 6)
+	for i, expected := range []string{
+		"group ID should not be empty",
+		"ID should not be empty",
+		"xx",
+		"invalid role unknown",
+		"invalid count -1",
+		"invalid op unknown",
+	} {
+		re.Contains(messages[i], expected)
+	}
+	// the rule is not modified.
+	re.Empty(rule.StartKey)
+	re.Empty(rule.EndKey)
+
+	rule = &Rule{GroupID: "g", ID: "1", StartKeyHex: "34", EndKeyHex: "12", Role: Leader, IsWitness: true, Count: 2}
+	re.Len(ValidateRule(rule), 3)
+}
+
+func TestMigrateRule(t *testing.T) {
+	re := require.New(t)
+	legacy := []byte(`{"group_id":"g","id":"1","start_key":"7A","end_key":"7B","role":"witness","count":1,"revision":18446744073709551615}`)
+	data, err := MigrateRule(legacy, 0, RuleSchemaVersion)
+	re.NoError(err)
+	var migrated map[string]interface{}
+	re.NoError(json.Unmarshal(data, &migrated))
+	re.Equal(true, migrated["is_witness"])
+	re.Equal("7a", migrated["start_key"])
+	re.Equal("7b", migrated["end_key"])
+	re.Contains(string(data), `"revision":18446744073709551615`)
+	re.Contains(string(data), `"schema_version":1`)
+
+	// the legacy rule is migrated by the parser automatically.
+	rule, err := NewRuleFromJSON(legacy)
+	re.NoError(err)
+	re.True(rule.IsWitness)
+	re.Equal("7a", rule.StartKeyHex)
+	re.Equal(RuleSchemaVersion, rule.SchemaVersion)
+	re.Equal(uint64(18446744073709551615), rule.Revision)
+
+	// the rule of the current or a newer version is parsed as it is.
+	data = []byte(`{"group_id":"g","id":"1","start_key":"","end_key":"","role":"witness","count":1,"schema_version":99}`)
+	rule, err = NewRuleFromJSON(data)
+	re.NoError(err)
+	re.False(rule.IsWitness)
+	re.Equal(99, rule.SchemaVersion)
+
+	_, err = MigrateRule(legacy, 1, 0)
+	re.Error(err)
+	_, err = MigrateRule(legacy, 0, RuleSchemaVersion+1)
+	re.Error(err)
+	_, err = MigrateRule([]byte(`{`), 0, RuleSchemaVersion)
+	re.Error(err)
+}
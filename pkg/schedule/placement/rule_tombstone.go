@@ -0,0 +1,151 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/errs"
+	"go.uber.org/zap"
+)
+
+// RuleTombstone is a deleted rule kept during the grace period, it can be
+// restored by RuleManager.RestoreRule before it expires. The tombstoned rule
+// is removed from the rules, so it's ignored by fit and the scheduling service.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleTombstone struct {
+	Rule      *Rule     `json:"rule"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpireAt  time.Time `json:"expire_at"`
+}
+
+func (t *RuleTombstone) isExpired(now time.Time) bool {
+	return !now.Before(t.ExpireAt)
+}
+
+func (m *RuleManager) loadTombstones() error {
+	return m.storage.LoadRuleTombstones(func(k, v string) {
+		var t RuleTombstone
+		if err := json.Unmarshal([]byte(v), &t); err != nil {
+			log.Error("failed to unmarshal rule tombstone", zap.String("rule-key", k), errs.ZapError(errs.ErrLoadRule, err))
+			return
+		}
+		if t.Rule == nil {
+			log.Error("rule tombstone without rule", zap.String("rule-key", k))
+			return
+		}
+		m.ruleConfig.tombstones[t.Rule.Key()] = &t
+	})
+}
+
+// tombstoneRule keeps the rule deleted by the patch as a tombstone if the
+// grace period is enabled, it returns nil if the rule is removed directly.
+func (m *RuleManager) tombstoneRule(p *ruleConfigPatch, group, id string, now time.Time) *RuleTombstone {
+	if m.conf == nil {
+		return nil
+	}
+	gracePeriod := m.conf.GetRuleTombstoneGracePeriod()
+	if gracePeriod <= 0 {
+		return nil
+	}
+	r := m.ruleConfig.getRule([2]string{group, id})
+	if r == nil {
+		return nil
+	}
+	t := &RuleTombstone{Rule: r.Clone(), DeletedAt: now, ExpireAt: now.Add(gracePeriod)}
+	p.setTombstone(t)
+	return t
+}
+
+// GetRuleTombstones returns the unexpired rule tombstones sorted by the keys
+// of the rules.
+func (m *RuleManager) GetRuleTombstones() []*RuleTombstone {
+	m.RLock()
+	defer m.RUnlock()
+	now := time.Now()
+	var tombstones []*RuleTombstone
+	for _, t := range m.ruleConfig.tombstones {
+		if !t.isExpired(now) {
+			tombstones = append(tombstones, t)
+		}
+	}
+	sort.Slice(tombstones, func(i, j int) bool {
+		a, b := tombstones[i].Rule.Key(), tombstones[j].Rule.Key()
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		return a[1] < b[1]
+	})
+	return tombstones
+}
+
+// RestoreRule restores a tombstoned rule before it expires. It fails if a rule
+// with the same key has been set after the deletion.
+func (m *RuleManager) RestoreRule(group, id string) error {
+	m.Lock()
+	defer m.Unlock()
+	key := [2]string{group, id}
+	t, ok := m.ruleConfig.tombstones[key]
+	if !ok || t.isExpired(time.Now()) {
+		return errs.ErrRuleTombstone.FastGenByArgs(fmt.Sprintf("rule '%s' from rule group '%s' is not found or expired", id, group))
+	}
+	if m.ruleConfig.getRule(key) != nil {
+		return errs.ErrRuleTombstone.FastGenByArgs(fmt.Sprintf("rule '%s' from rule group '%s' has been set again", id, group))
+	}
+	// the stores may be changed during the grace period, so check it again.
+	rule := t.Rule.Clone()
+	if err := m.adjustRule(rule, ""); err != nil {
+		return err
+	}
+	p := m.beginPatch()
+	p.setRule(rule)
+	p.deleteTombstone(group, id)
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("placement rule is restored", zap.String("rule", fmt.Sprint(rule)))
+	return nil
+}
+
+// ReapRuleTombstones removes the expired rule tombstones permanently, it
+// should be called periodically.
+func (m *RuleManager) ReapRuleTombstones() {
+	if err := m.reapRuleTombstones(time.Now()); err != nil {
+		log.Warn("failed to reap the expired rule tombstones", errs.ZapError(err))
+	}
+}
+
+func (m *RuleManager) reapRuleTombstones(now time.Time) error {
+	m.Lock()
+	defer m.Unlock()
+	p := m.beginPatch()
+	for key, t := range m.ruleConfig.tombstones {
+		if t.isExpired(now) {
+			p.deleteTombstone(key[0], key[1])
+		}
+	}
+	if len(p.mut.tombstones) == 0 {
+		return nil
+	}
+	if err := m.tryCommitPatch(p); err != nil {
+		return err
+	}
+	log.Info("expired rule tombstones are removed", zap.Int("count", len(p.mut.tombstones)))
+	return nil
+}
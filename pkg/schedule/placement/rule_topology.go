@@ -0,0 +1,126 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+)
+
+// ValidateAgainstTopology checks every location label and every label key
+// required by the label constraints of the rule is carried by at least one
+// store, so the rule doesn't make the regions unschedulable silently. The keys
+// only used by `notIn` or `notExists` are not checked since the stores without
+// them can match the constraints. It passes if there is no store yet.
+func (m *RuleManager) ValidateAgainstTopology(rule *Rule) error {
+	stores := m.getAliveStores()
+	if len(stores) == 0 {
+		return nil
+	}
+	keys := make(map[string]struct{})
+	for _, s := range stores {
+		for _, l := range s.GetLabels() {
+			keys[l.GetKey()] = struct{}{}
+		}
+	}
+	for _, label := range rule.LocationLabels {
+		if _, ok := keys[label]; !ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("location label %s is not carried by any store", label))
+		}
+	}
+	for _, c := range rule.LabelConstraints {
+		if c.Op != In && c.Op != Exists {
+			continue
+		}
+		if _, ok := keys[c.Key]; !ok {
+			return errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("label constraint key %s is not carried by any store", c.Key))
+		}
+	}
+	return nil
+}
+
+func (m *RuleManager) validateTopologyIfEnabled(rule *Rule) error {
+	if m.conf == nil || !m.conf.IsRuleTopologyValidationEnabled() {
+		return nil
+	}
+	return m.ValidateAgainstTopology(rule)
+}
+
+// getAliveStores returns the stores which are not removed.
+func (m *RuleManager) getAliveStores() []*core.StoreInfo {
+	if m.storeSetInformer == nil {
+		return nil
+	}
+	var stores []*core.StoreInfo
+	for _, s := range m.storeSetInformer.GetStores() {
+		if !s.IsRemoved() {
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+// MatchingStoresOption is the option of MatchingStores.
+type MatchingStoresOption func(*matchingStoresOptions)
+
+type matchingStoresOptions struct {
+	includeUnavailable bool
+}
+
+// WithUnavailableStores makes MatchingStores include the offline and the
+// tombstone stores.
+func WithUnavailableStores() MatchingStoresOption {
+	return func(o *matchingStoresOptions) { o.includeUnavailable = true }
+}
+
+// MatchingStores returns the sorted IDs of the stores which can host a peer of
+// the rule right now, i.e. they match the label constraints of the rule, which
+// include the ones inherited from the rule groups and the ones of the engine.
+// The location labels only decide how the peers are isolated, so the stores
+// without them are still returned. The offline and the tombstone stores are
+// excluded unless WithUnavailableStores is specified. The rule is not changed.
+func (m *RuleManager) MatchingStores(rule *Rule, opts ...MatchingStoresOption) ([]uint64, error) {
+	var o matchingStoresOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if m.storeSetInformer == nil {
+		return nil, errors.New("the stores are not available")
+	}
+	r := rule.Clone()
+	if err := m.adjustRule(r, ""); err != nil {
+		return nil, err
+	}
+	m.RLock()
+	newGroupInheritance(m.ruleConfig.getGroup, r.GroupID).apply(r)
+	m.RUnlock()
+	applied := r.applied()
+
+	ids := make([]uint64, 0)
+	for _, s := range m.storeSetInformer.GetStores() {
+		if !o.includeUnavailable && (s.IsRemoved() || s.IsRemoving()) {
+			continue
+		}
+		if applied.MatchStore(s) {
+			ids = append(ids, s.GetID())
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
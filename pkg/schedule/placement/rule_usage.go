@@ -0,0 +1,155 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"sync"
+
+	"github.com/tikv/pd/pkg/utils/syncutil"
+)
+
+// RuleStats is the usage of a rule by the regions at their last fits.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type RuleStats struct {
+	// Matched is the number of the regions the rule is applied to.
+	Matched uint64 `json:"matched"`
+	// Satisfied is the number of the matched regions satisfying the rule.
+	Satisfied uint64 `json:"satisfied"`
+}
+
+type regionRuleState struct {
+	key       [2]string
+	satisfied bool
+}
+
+// ruleUsage is updated by every computed fit. Most of the fits don't change
+// the rules applied to the region and whether they are satisfied, so they are
+// skipped by comparing with the last states without the lock.
+type ruleUsage struct {
+	syncutil.Mutex
+	// regions records the rules applied to each region at the last fit, i.e.,
+	// map[uint64][]regionRuleState. It's only changed with the lock.
+	regions sync.Map
+	stats   map[[2]string]*RuleStats
+}
+
+func newRuleUsage() *ruleUsage {
+	return &ruleUsage{
+		stats: make(map[[2]string]*RuleStats),
+	}
+}
+
+func (u *ruleUsage) record(regionID uint64, fit *RegionFit) {
+	if states, ok := u.regions.Load(regionID); ok && equalRuleStates(states.([]regionRuleState), fit) {
+		return
+	}
+	states := make([]regionRuleState, 0, len(fit.RuleFits))
+	for _, rf := range fit.RuleFits {
+		states = append(states, regionRuleState{key: rf.Rule.Key(), satisfied: rf.IsSatisfied()})
+	}
+	u.Lock()
+	defer u.Unlock()
+	u.removeLocked(regionID)
+	for _, state := range states {
+		s, ok := u.stats[state.key]
+		if !ok {
+			s = &RuleStats{}
+			u.stats[state.key] = s
+		}
+		s.Matched++
+		if state.satisfied {
+			s.Satisfied++
+		}
+	}
+	u.regions.Store(regionID, states)
+}
+
+func equalRuleStates(states []regionRuleState, fit *RegionFit) bool {
+	if len(states) != len(fit.RuleFits) {
+		return false
+	}
+	for i, rf := range fit.RuleFits {
+		if states[i].key != rf.Rule.Key() || states[i].satisfied != rf.IsSatisfied() {
+			return false
+		}
+	}
+	return true
+}
+
+func (u *ruleUsage) remove(regionID uint64) {
+	u.Lock()
+	defer u.Unlock()
+	u.removeLocked(regionID)
+}
+
+func (u *ruleUsage) removeLocked(regionID uint64) {
+	states, ok := u.regions.LoadAndDelete(regionID)
+	if !ok {
+		return
+	}
+	for _, state := range states.([]regionRuleState) {
+		s := u.stats[state.key]
+		s.Matched--
+		if state.satisfied {
+			s.Satisfied--
+		}
+		if s.Matched == 0 {
+			delete(u.stats, state.key)
+		}
+	}
+}
+
+func (u *ruleUsage) reset() {
+	u.Lock()
+	defer u.Unlock()
+	u.regions.Range(func(regionID, _ interface{}) bool {
+		u.regions.Delete(regionID)
+		return true
+	})
+	u.stats = make(map[[2]string]*RuleStats)
+}
+
+// RuleUsage returns the number of the regions matching each rule and the ones
+// satisfying it, keyed by "group/id". The rules matching no region are not
+// included. Only the computed fits are recorded, so a region is counted by
+// the rules at its last fit, and a rule satisfied by all the regions it
+// matches is likely a redundant one.
+func (m *RuleManager) RuleUsage() map[string]RuleStats {
+	m.RLock()
+	defer m.RUnlock()
+	m.ruleUsage.Lock()
+	defer m.ruleUsage.Unlock()
+	usage := make(map[string]RuleStats, len(m.ruleUsage.stats))
+	for key, s := range m.ruleUsage.stats {
+		// the regions may not be fitted again after the rule is deleted.
+		if m.ruleConfig.getRule(key) == nil {
+			continue
+		}
+		usage[key[0]+"/"+key[1]] = *s
+	}
+	return usage
+}
+
+// ResetRuleUsage clears the usage of the rules, it's recorded again as the
+// regions are fitted.
+func (m *RuleManager) ResetRuleUsage() {
+	m.ruleUsage.reset()
+}
+
+// ClearDefunctRegion removes the usage of the rules by a region which no
+// longer exists, e.g., it's merged or overlapped by the other regions.
+func (m *RuleManager) ClearDefunctRegion(regionID uint64) {
+	m.ruleUsage.remove(regionID)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package placement
+
+import (
+	"fmt"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+	"golang.org/x/exp/slices"
+)
+
+// ValidateRule checks the content of the rule and returns all the problems
+// found, so that they can be fixed at once, while RuleManager.SetRule only
+// returns the first one. The rule is not modified. The checks depending on
+// the cluster, such as the key type and the stores, are left to RuleManager,
+// so a rule passing the validation may still be rejected by it.
+func ValidateRule(r *Rule) []error {
+	var res []error
+	add := func(err error) {
+		if err != nil {
+			res = append(res, err)
+		}
+	}
+	if r.GroupID == "" {
+		add(errs.ErrRuleContent.FastGenByArgs("group ID should not be empty"))
+	}
+	if r.ID == "" {
+		add(errs.ErrRuleContent.FastGenByArgs("ID should not be empty"))
+	}
+	// normalize the copies of the keys, so the rule is kept as is.
+	startKey, startKeyHex := r.StartKey, r.StartKeyHex
+	endKey, endKeyHex := r.EndKey, r.EndKeyHex
+	startErr := normalizeKey(&startKey, &startKeyHex, "start key")
+	endErr := normalizeKey(&endKey, &endKeyHex, "end key")
+	add(startErr)
+	add(endErr)
+	if startErr == nil && endErr == nil {
+		kr := &Rule{KeyspaceID: r.KeyspaceID, KeyspaceMode: r.KeyspaceMode, StartKey: startKey, EndKey: endKey}
+		if err := bindKeyspaceRange(kr); err != nil {
+			add(err)
+		} else if err := checkKeyRange(kr.StartKey, kr.EndKey); err != nil {
+			add(err)
+		} else {
+			add(checkKeyspaceRange(kr))
+		}
+	}
+	if !validateRole(r.Role) {
+		add(errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid role %s", r.Role)))
+	}
+	isWitness := r.IsWitness || r.Role == Witness
+	add(r.CountExpr.validate())
+	if r.CountExpr != nil && (r.Role == Leader || isWitness) {
+		add(errs.ErrRuleContent.FastGenByArgs("count expression is not supported by the leader or witness rules"))
+	}
+	// the count is derived from the count expression if it's not set.
+	if r.Count < 0 || (r.Count == 0 && r.CountExpr == nil) {
+		add(errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid count %d", r.Count)))
+	}
+	if r.Role == Leader && r.Count > 1 {
+		add(errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("define multiple leaders by count %d", r.Count)))
+	}
+	if r.Role == Leader && isWitness {
+		add(errs.ErrRuleContent.FastGenByArgs("leader can't be a witness"))
+	}
+	add(r.checkEngine(isWitness))
+	add(r.Precondition.validate())
+	add(r.validateActiveWindow())
+	for _, c := range r.LabelConstraints {
+		if !validateOp(c.Op) {
+			add(errs.ErrRuleContent.FastGenByArgs(fmt.Sprintf("invalid op %s", c.Op)))
+		}
+		if isWitness && c.Key == core.EngineKey && slices.Contains(c.Values, core.EngineTiFlash) {
+			add(errs.ErrRuleContent.FastGenByArgs("witness can't combine with tiflash"))
+		}
+	}
+	add(r.checkLabelConstraintAlternatives())
+	add(checkAnnotations(r.Annotations))
+	return res
+}
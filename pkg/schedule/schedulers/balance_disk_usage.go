@@ -0,0 +1,324 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/docker/go-units"
+	"github.com/gorilla/mux"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/constant"
+	"github.com/tikv/pd/pkg/errs"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"github.com/tikv/pd/pkg/schedule/filter"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/plan"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/apiutil"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"github.com/unrolled/render"
+)
+
+const (
+	// BalanceDiskUsageName is balance disk usage scheduler name.
+	BalanceDiskUsageName = "balance-disk-usage-scheduler"
+	// BalanceDiskUsageType is balance disk usage scheduler type.
+	BalanceDiskUsageType = "balance-disk-usage"
+
+	defaultDiskUsageHighWatermark = 0.8
+	defaultDiskUsageLowWatermark  = 0.6
+)
+
+var (
+	// WithLabelValues is a heavy operation, define variable to avoid call it every time.
+	balanceDiskUsageCounter              = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "schedule")
+	balanceDiskUsageNoSourceCounter      = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "no-source-store")
+	balanceDiskUsageNoRegionCounter      = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "no-region")
+	balanceDiskUsageNoTargetCounter      = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "no-target-store")
+	balanceDiskUsageCreateOpFailCounter  = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "create-operator-fail")
+	balanceDiskUsageNewOperatorCounter   = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "new-operator")
+	balanceDiskUsageHotRegionSkipCounter = schedulerCounter.WithLabelValues(BalanceDiskUsageName, "region-hot")
+)
+
+type balanceDiskUsageSchedulerConfig struct {
+	syncutil.RWMutex
+	storage endpoint.ConfigStorage
+
+	Ranges []core.KeyRange `json:"ranges"`
+	// The regions are moved out of the stores whose disk usage is above
+	// HighWatermark, to the stores whose disk usage is below LowWatermark.
+	HighWatermark float64 `json:"high-watermark"`
+	LowWatermark  float64 `json:"low-watermark"`
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) EncodeConfig() ([]byte, error) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return EncodeConfig(conf)
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) getWatermarks() (high, low float64) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.HighWatermark, conf.LowWatermark
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) setWatermarks(high, low float64) error {
+	if err := validateDiskUsageWatermarks(high, low); err != nil {
+		return err
+	}
+	conf.Lock()
+	defer conf.Unlock()
+	conf.HighWatermark, conf.LowWatermark = high, low
+	return nil
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) getRanges() []core.KeyRange {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.Ranges
+}
+
+func validateDiskUsageWatermarks(high, low float64) error {
+	if low <= 0 || high >= 1 || low >= high {
+		return errs.ErrSchedulerConfig.FastGenByArgs("watermarks, which should be 0 < low-watermark < high-watermark < 1")
+	}
+	return nil
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := mux.NewRouter()
+	router.HandleFunc("/list", conf.handleGetConfig).Methods(http.MethodGet)
+	router.HandleFunc("/config", conf.handleSetConfig).Methods(http.MethodPost)
+	router.ServeHTTP(w, r)
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	conf.RLock()
+	defer conf.RUnlock()
+	rd.JSON(w, http.StatusOK, conf)
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	var input map[string]interface{}
+	if err := apiutil.ReadJSONRespondError(rd, w, r.Body, &input); err != nil {
+		return
+	}
+
+	conf.Lock()
+	defer conf.Unlock()
+	high, low := conf.HighWatermark, conf.LowWatermark
+	if v, ok := input["high-watermark"].(float64); ok {
+		high = v
+	}
+	if v, ok := input["low-watermark"].(float64); ok {
+		low = v
+	}
+	if err := validateDiskUsageWatermarks(high, low); err != nil {
+		rd.Text(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	oldHigh, oldLow := conf.HighWatermark, conf.LowWatermark
+	conf.HighWatermark, conf.LowWatermark = high, low
+	if err := conf.persist(); err != nil {
+		conf.HighWatermark, conf.LowWatermark = oldHigh, oldLow // revert
+		rd.Text(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rd.Text(w, http.StatusOK, "Config is updated.")
+}
+
+func (conf *balanceDiskUsageSchedulerConfig) persist() error {
+	data, err := EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(BalanceDiskUsageName, data)
+}
+
+// balanceDiskUsageScheduler moves the regions out of the stores whose disk is
+// almost full. Unlike the balance region scheduler, it uses the actual disk
+// usage reported by the store heartbeats instead of the region size, so the
+// stores with different disk sizes are filled proportionally.
+type balanceDiskUsageScheduler struct {
+	*BaseScheduler
+	conf    *balanceDiskUsageSchedulerConfig
+	filters []filter.Filter
+}
+
+// newBalanceDiskUsageScheduler creates a scheduler that balances the disk
+// usage of the stores.
+func newBalanceDiskUsageScheduler(opController *operator.Controller, conf *balanceDiskUsageSchedulerConfig) Scheduler {
+	filters := []filter.Filter{
+		&filter.StoreStateFilter{ActionScope: BalanceDiskUsageName, MoveRegion: true, OperatorLevel: constant.Medium},
+		filter.NewSpecialUseFilter(BalanceDiskUsageName),
+	}
+	base := NewBaseScheduler(opController)
+	return &balanceDiskUsageScheduler{
+		BaseScheduler: base,
+		conf:          conf,
+		filters:       filters,
+	}
+}
+
+func (s *balanceDiskUsageScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.conf.ServeHTTP(w, r)
+}
+
+func (s *balanceDiskUsageScheduler) GetName() string {
+	return BalanceDiskUsageName
+}
+
+func (s *balanceDiskUsageScheduler) GetType() string {
+	return BalanceDiskUsageType
+}
+
+func (s *balanceDiskUsageScheduler) EncodeConfig() ([]byte, error) {
+	return s.conf.EncodeConfig()
+}
+
+func (s *balanceDiskUsageScheduler) ReloadConfig(data []byte) error {
+	newConf := &balanceDiskUsageSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	if err := validateDiskUsageWatermarks(newConf.HighWatermark, newConf.LowWatermark); err != nil {
+		return err
+	}
+	s.conf.Lock()
+	defer s.conf.Unlock()
+	s.conf.Ranges, s.conf.HighWatermark, s.conf.LowWatermark = newConf.Ranges, newConf.HighWatermark, newConf.LowWatermark
+	return nil
+}
+
+func (s *balanceDiskUsageScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
+	allowed := s.OpController.OperatorCount(operator.OpRegion) < cluster.GetSchedulerConfig().GetRegionScheduleLimit()
+	if !allowed {
+		operator.OperatorLimitCounter.WithLabelValues(s.GetType(), operator.OpRegion.String()).Inc()
+	}
+	return allowed
+}
+
+func (s *balanceDiskUsageScheduler) Schedule(cluster sche.SchedulerCluster, dryRun bool) ([]*operator.Operator, []plan.Plan) {
+	balanceDiskUsageCounter.Inc()
+	high, low := s.conf.getWatermarks()
+	ranges := s.conf.getRanges()
+	conf := cluster.GetSchedulerConfig()
+	opInfluence := s.OpController.GetOpInfluence(cluster.GetBasicCluster())
+	usages := make(map[uint64]float64)
+	for _, store := range cluster.GetStores() {
+		if usage, ok := diskUsage(store, opInfluence); ok {
+			usages[store.GetID()] = usage
+		}
+	}
+
+	var sources, targets []*core.StoreInfo
+	for _, store := range filter.NewCandidates(cluster.GetStores()).FilterSource(conf, nil, nil, s.filters...).Stores {
+		if usage, ok := usages[store.GetID()]; ok && usage > high {
+			sources = append(sources, store)
+		}
+	}
+	if len(sources) == 0 {
+		balanceDiskUsageNoSourceCounter.Inc()
+		return nil, nil
+	}
+	// the fullest store is scheduled first.
+	sort.Slice(sources, func(i, j int) bool { return usages[sources[i].GetID()] > usages[sources[j].GetID()] })
+	for _, store := range filter.NewCandidates(cluster.GetStores()).FilterTarget(conf, nil, nil, s.filters...).Stores {
+		if usage, ok := usages[store.GetID()]; ok && usage < low {
+			targets = append(targets, store)
+		}
+	}
+	// the emptiest store is preferred.
+	sort.Slice(targets, func(i, j int) bool { return usages[targets[i].GetID()] < usages[targets[j].GetID()] })
+
+	pendingFilter := filter.NewRegionPendingFilter()
+	downFilter := filter.NewRegionDownFilter()
+	replicaFilter := filter.NewRegionReplicatedFilter(cluster)
+	for _, source := range sources {
+		filters := []filter.RegionFilter{pendingFilter, downFilter, replicaFilter, filter.NewRegionWitnessFilter(source.GetID())}
+		for _, regions := range [][]*core.RegionInfo{
+			cluster.RandFollowerRegions(source.GetID(), ranges),
+			cluster.RandLeaderRegions(source.GetID(), ranges),
+			cluster.RandLearnerRegions(source.GetID(), ranges),
+		} {
+			region := filter.SelectOneRegion(regions, nil, filters...)
+			if region == nil {
+				continue
+			}
+			if cluster.IsRegionHot(region) {
+				balanceDiskUsageHotRegionSkipCounter.Inc()
+				continue
+			}
+			if op := s.transferPeer(cluster, region, source, targets, usages, high); op != nil {
+				return []*operator.Operator{op}, nil
+			}
+		}
+		balanceDiskUsageNoRegionCounter.Inc()
+	}
+	return nil, nil
+}
+
+// transferPeer moves the peer of the region in the source store to the
+// emptiest target store, which is still below the high watermark after the
+// region is moved in.
+func (s *balanceDiskUsageScheduler) transferPeer(cluster sche.SchedulerCluster, region *core.RegionInfo, source *core.StoreInfo,
+	targets []*core.StoreInfo, usages map[uint64]float64, high float64) *operator.Operator {
+	conf := cluster.GetSchedulerConfig()
+	filters := []filter.Filter{
+		filter.NewExcludedFilter(BalanceDiskUsageName, nil, region.GetStoreIDs()),
+		filter.NewPlacementSafeguard(BalanceDiskUsageName, conf, cluster.GetBasicCluster(), cluster.GetRuleManager(), region, source, nil),
+	}
+	regionSize := float64(region.GetApproximateSize()) * units.MiB
+	for _, target := range filter.NewCandidates(targets).FilterTarget(conf, nil, nil, filters...).Stores {
+		if usages[target.GetID()]+regionSize/float64(target.GetCapacity()) >= high {
+			continue
+		}
+		oldPeer := region.GetStorePeer(source.GetID())
+		newPeer := &metapb.Peer{StoreId: target.GetID(), Role: oldPeer.Role}
+		op, err := operator.CreateMovePeerOperator(BalanceDiskUsageType, cluster, region, operator.OpRegion, source.GetID(), newPeer)
+		if err != nil {
+			balanceDiskUsageCreateOpFailCounter.Inc()
+			continue
+		}
+		op.Counters = append(op.Counters, balanceDiskUsageNewOperatorCounter)
+		op.FinishedCounters = append(op.FinishedCounters,
+			balanceDirectionCounter.WithLabelValues(s.GetName(), strconv.FormatUint(source.GetID(), 10), strconv.FormatUint(target.GetID(), 10)),
+		)
+		op.AdditionalInfos["sourceUsage"] = strconv.FormatFloat(usages[source.GetID()], 'f', 2, 64)
+		op.AdditionalInfos["targetUsage"] = strconv.FormatFloat(usages[target.GetID()], 'f', 2, 64)
+		return op
+	}
+	balanceDiskUsageNoTargetCounter.Inc()
+	return nil
+}
+
+// diskUsage returns the used ratio of the disk of the store reported by the
+// heartbeats, with the influence of the unfinished operators, and false if
+// the capacity is unknown.
+func diskUsage(store *core.StoreInfo, opInfluence operator.OpInfluence) (float64, bool) {
+	capacity := store.GetCapacity()
+	if capacity == 0 {
+		return 0, false
+	}
+	influence := opInfluence.GetStoreInfluence(store.GetID())
+	return 1 - store.AvailableRatio() + float64(influence.RegionSize)*units.MiB/float64(capacity), true
+}
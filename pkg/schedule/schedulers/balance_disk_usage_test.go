@@ -0,0 +1,76 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
+)
+
+func TestBalanceDiskUsage(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	sb, err := CreateScheduler(BalanceDiskUsageType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(BalanceDiskUsageType, []string{"", ""}))
+	re.NoError(err)
+
+	// the region counts are the same, but the disk usages are different.
+	for id := uint64(1); id <= 4; id++ {
+		tc.AddRegionStore(id, 10)
+	}
+	tc.UpdateStorageRatio(1, 0.9, 0.1)
+	tc.UpdateStorageRatio(2, 0.7, 0.3)
+	tc.UpdateStorageRatio(3, 0.7, 0.3)
+	tc.UpdateStorageRatio(4, 0.65, 0.35)
+	tc.AddLeaderRegion(1, 1, 2, 3)
+
+	// no store is below the low watermark.
+	re.True(sb.IsScheduleAllowed(tc))
+	ops, _ := sb.Schedule(tc, false)
+	re.Empty(ops)
+
+	// the peer is moved from the fullest store to the emptiest one.
+	tc.UpdateStorageRatio(4, 0.3, 0.7)
+	ops, _ = sb.Schedule(tc, false)
+	re.Len(ops, 1)
+	operatorutil.CheckTransferPeer(re, ops[0], operator.OpRegion, 1, 4)
+
+	// no store is above the high watermark.
+	tc.UpdateStorageRatio(1, 0.75, 0.25)
+	ops, _ = sb.Schedule(tc, false)
+	re.Empty(ops)
+
+	conf := sb.(*balanceDiskUsageScheduler).conf
+	re.NoError(conf.setWatermarks(0.7, defaultDiskUsageLowWatermark))
+	high, low := conf.getWatermarks()
+	re.Equal(0.7, high)
+	re.Equal(defaultDiskUsageLowWatermark, low)
+	ops, _ = sb.Schedule(tc, false)
+	re.Len(ops, 1)
+	operatorutil.CheckTransferPeer(re, ops[0], operator.OpRegion, 1, 4)
+
+	re.Error(validateDiskUsageWatermarks(0.6, 0.6))
+	re.Error(validateDiskUsageWatermarks(1, 0.6))
+	re.Error(validateDiskUsageWatermarks(0.8, 0))
+	re.NoError(validateDiskUsageWatermarks(0.8, 0.6))
+	re.Error(conf.setWatermarks(0.5, 0.6))
+	high, _ = conf.getWatermarks()
+	re.Equal(0.7, high)
+}
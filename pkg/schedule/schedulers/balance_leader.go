@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"math"
 	"net/http"
 	"sort"
 	"strconv"
@@ -31,6 +32,8 @@ import (
 	"github.com/tikv/pd/pkg/schedule/filter"
 	"github.com/tikv/pd/pkg/schedule/operator"
 	"github.com/tikv/pd/pkg/schedule/plan"
+	"github.com/tikv/pd/pkg/statistics"
+	"github.com/tikv/pd/pkg/statistics/utils"
 	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/utils/reflectutil"
 	"github.com/tikv/pd/pkg/utils/syncutil"
@@ -53,6 +56,14 @@ const (
 
 	transferIn  = "transfer-in"
 	transferOut = "transfer-out"
+
+	// The dimensions which the leaders are balanced by, the empty one is the
+	// same as leaderDimensionCount.
+	leaderDimensionCount = "count"
+	leaderDimensionQPS   = "qps"
+	// minLeaderWeight is the min leader weight when scoring the stores, which
+	// is the same as the one of the LeaderScore of the stores.
+	minLeaderWeight = 1e-6
 )
 
 var (
@@ -72,6 +83,10 @@ type balanceLeaderSchedulerConfig struct {
 	Ranges  []core.KeyRange `json:"ranges"`
 	// Batch is used to generate multiple operators by one scheduling
 	Batch int `json:"batch"`
+	// Dimension is leaderDimensionCount to balance the leaders by the
+	// leader-schedule-policy, or leaderDimensionQPS to balance the read and
+	// write QPS served by the leaders.
+	Dimension string `json:"dimension,omitempty"`
 }
 
 func (conf *balanceLeaderSchedulerConfig) Update(data []byte) (int, interface{}) {
@@ -85,9 +100,9 @@ func (conf *balanceLeaderSchedulerConfig) Update(data []byte) (int, interface{})
 	}
 	newc, _ := json.Marshal(conf)
 	if !bytes.Equal(oldc, newc) {
-		if !conf.validate() {
+		if msg := conf.validate(); msg != "" {
 			json.Unmarshal(oldc, conf)
-			return http.StatusBadRequest, "invalid batch size which should be an integer between 1 and 10"
+			return http.StatusBadRequest, msg
 		}
 		conf.persistLocked()
 		log.Info("balance-leader-scheduler config is updated", zap.ByteString("old", oldc), zap.ByteString("new", newc))
@@ -104,8 +119,17 @@ func (conf *balanceLeaderSchedulerConfig) Update(data []byte) (int, interface{})
 	return http.StatusBadRequest, "Config item is not found."
 }
 
-func (conf *balanceLeaderSchedulerConfig) validate() bool {
-	return conf.Batch >= 1 && conf.Batch <= 10
+// validate returns the message of the invalid config, or the empty one.
+func (conf *balanceLeaderSchedulerConfig) validate() string {
+	if conf.Batch < 1 || conf.Batch > 10 {
+		return "invalid batch size which should be an integer between 1 and 10"
+	}
+	switch conf.Dimension {
+	case "", leaderDimensionCount, leaderDimensionQPS:
+		return ""
+	default:
+		return "invalid dimension which should be count or qps"
+	}
 }
 
 func (conf *balanceLeaderSchedulerConfig) Clone() *balanceLeaderSchedulerConfig {
@@ -114,11 +138,32 @@ func (conf *balanceLeaderSchedulerConfig) Clone() *balanceLeaderSchedulerConfig
 	ranges := make([]core.KeyRange, len(conf.Ranges))
 	copy(ranges, conf.Ranges)
 	return &balanceLeaderSchedulerConfig{
-		Ranges: ranges,
-		Batch:  conf.Batch,
+		Ranges:    ranges,
+		Batch:     conf.Batch,
+		Dimension: conf.Dimension,
 	}
 }
 
+func (conf *balanceLeaderSchedulerConfig) getBatch() int {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return conf.Batch
+}
+
+func (conf *balanceLeaderSchedulerConfig) getRanges() []core.KeyRange {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	ranges := make([]core.KeyRange, len(conf.Ranges))
+	copy(ranges, conf.Ranges)
+	return ranges
+}
+
+func (conf *balanceLeaderSchedulerConfig) getDimension() string {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return conf.Dimension
+}
+
 func (conf *balanceLeaderSchedulerConfig) persistLocked() error {
 	data, err := EncodeConfig(conf)
 	if err != nil {
@@ -215,6 +260,20 @@ func (l *balanceLeaderScheduler) EncodeConfig() ([]byte, error) {
 	return EncodeConfig(l.conf)
 }
 
+func (l *balanceLeaderScheduler) ReloadConfig(data []byte) error {
+	newConf := &balanceLeaderSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	if msg := newConf.validate(); msg != "" {
+		return errs.ErrSchedulerConfig.FastGenByArgs(msg)
+	}
+	l.conf.mu.Lock()
+	defer l.conf.mu.Unlock()
+	l.conf.Ranges, l.conf.Batch, l.conf.Dimension = newConf.Ranges, newConf.Batch, newConf.Dimension
+	return nil
+}
+
 func (l *balanceLeaderScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
 	allowed := l.OpController.OperatorCount(operator.OpLeader) < cluster.GetSchedulerConfig().GetLeaderScheduleLimit()
 	if !allowed {
@@ -316,24 +375,19 @@ func (cs *candidateStores) resortStoreWithPos(pos int) {
 }
 
 func (l *balanceLeaderScheduler) Schedule(cluster sche.SchedulerCluster, dryRun bool) ([]*operator.Operator, []plan.Plan) {
-	l.conf.mu.RLock()
-	defer l.conf.mu.RUnlock()
 	basePlan := plan.NewBalanceSchedulerPlan()
 	var collector *plan.Collector
 	if dryRun {
 		collector = plan.NewCollector(basePlan)
 	}
-	batch := l.conf.Batch
+	batch := l.conf.getBatch()
 	balanceLeaderScheduleCounter.Inc()
 
-	leaderSchedulePolicy := cluster.GetSchedulerConfig().GetLeaderSchedulePolicy()
-	opInfluence := l.OpController.GetOpInfluence(cluster.GetBasicCluster())
-	kind := constant.NewScheduleKind(constant.LeaderKind, leaderSchedulePolicy)
-	solver := newSolver(basePlan, kind, cluster, opInfluence)
+	solver := l.newSolver(basePlan, cluster, l.conf.getDimension())
 
 	stores := cluster.GetStores()
 	scoreFunc := func(store *core.StoreInfo) float64 {
-		return store.LeaderScore(solver.kind.Policy, solver.GetOpInfluence(store.GetID()))
+		return solver.getLeaderScore(store, solver.GetOpInfluence(store.GetID()))
 	}
 	sourceCandidate := newCandidateStores(filter.SelectSourceStores(stores, l.filters, cluster.GetSchedulerConfig(), collector, l.filterCounter), false, scoreFunc)
 	targetCandidate := newCandidateStores(filter.SelectTargetStores(stores, l.filters, cluster.GetSchedulerConfig(), nil, l.filterCounter), true, scoreFunc)
@@ -369,6 +423,22 @@ func (l *balanceLeaderScheduler) Schedule(cluster sche.SchedulerCluster, dryRun
 	return result, collector.GetPlans()
 }
 
+// newSolver creates the solver scoring the stores by the dimension.
+func (l *balanceLeaderScheduler) newSolver(basePlan *plan.BalanceSchedulerPlan, cluster sche.SchedulerCluster, dimension string) *solver {
+	leaderSchedulePolicy := cluster.GetSchedulerConfig().GetLeaderSchedulePolicy()
+	if dimension == leaderDimensionQPS {
+		// the influence of the operators is counted by the leaders.
+		leaderSchedulePolicy = constant.ByCount
+	}
+	opInfluence := l.OpController.GetOpInfluence(cluster.GetBasicCluster())
+	kind := constant.NewScheduleKind(constant.LeaderKind, leaderSchedulePolicy)
+	solver := newSolver(basePlan, kind, cluster, opInfluence)
+	if dimension == leaderDimensionQPS {
+		solver.leaderScore = newLeaderQPSScore(cluster)
+	}
+	return solver
+}
+
 func createTransferLeaderOperator(cs *candidateStores, dir string, l *balanceLeaderScheduler,
 	ssolver *solver, usedRegions map[uint64]struct{}, collector *plan.Collector) *operator.Operator {
 	store := cs.getStore()
@@ -422,7 +492,7 @@ func makeInfluence(op *operator.Operator, plan *solver, usedRegions map[uint64]s
 // It randomly selects a health region from the source store, then picks
 // the best follower peer and transfers the leader.
 func (l *balanceLeaderScheduler) transferLeaderOut(solver *solver, collector *plan.Collector) *operator.Operator {
-	solver.Region = filter.SelectOneRegion(solver.RandLeaderRegions(solver.SourceStoreID(), l.conf.Ranges),
+	solver.Region = filter.SelectOneRegion(solver.RandLeaderRegions(solver.SourceStoreID(), l.conf.getRanges()),
 		collector, filter.NewRegionPendingFilter(), filter.NewRegionDownFilter())
 	if solver.Region == nil {
 		log.Debug("store has no leader", zap.String("scheduler", l.GetName()), zap.Uint64("store-id", solver.SourceStoreID()))
@@ -446,11 +516,10 @@ func (l *balanceLeaderScheduler) transferLeaderOut(solver *solver, collector *pl
 		finalFilters = append(l.filters, leaderFilter)
 	}
 	targets = filter.SelectTargetStores(targets, finalFilters, conf, collector, l.filterCounter)
-	leaderSchedulePolicy := conf.GetLeaderSchedulePolicy()
 	sort.Slice(targets, func(i, j int) bool {
 		iOp := solver.GetOpInfluence(targets[i].GetID())
 		jOp := solver.GetOpInfluence(targets[j].GetID())
-		return targets[i].LeaderScore(leaderSchedulePolicy, iOp) < targets[j].LeaderScore(leaderSchedulePolicy, jOp)
+		return solver.getLeaderScore(targets[i], iOp) < solver.getLeaderScore(targets[j], jOp)
 	})
 	for _, solver.Target = range targets {
 		if op := l.createOperator(solver, collector); op != nil {
@@ -466,7 +535,7 @@ func (l *balanceLeaderScheduler) transferLeaderOut(solver *solver, collector *pl
 // It randomly selects a health region from the target store, then picks
 // the worst follower peer and transfers the leader.
 func (l *balanceLeaderScheduler) transferLeaderIn(solver *solver, collector *plan.Collector) *operator.Operator {
-	solver.Region = filter.SelectOneRegion(solver.RandFollowerRegions(solver.TargetStoreID(), l.conf.Ranges),
+	solver.Region = filter.SelectOneRegion(solver.RandFollowerRegions(solver.TargetStoreID(), l.conf.getRanges()),
 		nil, filter.NewRegionPendingFilter(), filter.NewRegionDownFilter())
 	if solver.Region == nil {
 		log.Debug("store has no follower", zap.String("scheduler", l.GetName()), zap.Uint64("store-id", solver.TargetStoreID()))
@@ -540,3 +609,38 @@ func (l *balanceLeaderScheduler) createOperator(solver *solver, collector *plan.
 	op.AdditionalInfos["targetScore"] = strconv.FormatFloat(solver.targetScore, 'f', 2, 64)
 	return op
 }
+
+// newLeaderQPSScore returns the function scoring the stores by the read and
+// write QPS served by their leaders, which are counted from the hot leader
+// peers. delta is the number of the leaders to be transferred in or out,
+// which is estimated with the average QPS of the leaders of the store, or
+// that of the cluster if the store has no leader.
+func newLeaderQPSScore(cluster sche.SchedulerCluster) func(*core.StoreInfo, int64) float64 {
+	qps := make(map[uint64]float64)
+	for _, stats := range []map[uint64][]*statistics.HotPeerStat{cluster.RegionReadStats(), cluster.RegionWriteStats()} {
+		for storeID, peers := range stats {
+			for _, peer := range peers {
+				if peer.IsLeader() {
+					qps[storeID] += peer.GetLoad(utils.QueryDim)
+				}
+			}
+		}
+	}
+	var totalQPS float64
+	var totalLeaders int
+	for _, store := range cluster.GetStores() {
+		totalQPS += qps[store.GetID()]
+		totalLeaders += store.GetLeaderCount()
+	}
+	var avgLeaderQPS float64
+	if totalLeaders > 0 {
+		avgLeaderQPS = totalQPS / float64(totalLeaders)
+	}
+	return func(store *core.StoreInfo, delta int64) float64 {
+		leaderQPS := avgLeaderQPS
+		if count := store.GetLeaderCount(); count > 0 {
+			leaderQPS = qps[store.GetID()] / float64(count)
+		}
+		return (qps[store.GetID()] + float64(delta)*leaderQPS) / math.Max(store.GetLeaderWeight(), minLeaderWeight)
+	}
+}
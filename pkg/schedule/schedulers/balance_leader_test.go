@@ -15,12 +15,19 @@
 package schedulers
 
 import (
+	"bytes"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
 )
 
 func TestBalanceLeaderSchedulerConfigClone(t *testing.T) {
@@ -40,6 +47,45 @@ func TestBalanceLeaderSchedulerConfigClone(t *testing.T) {
 	re.NotEqual(conf.Ranges, conf2.Ranges)
 }
 
+func TestBalanceLeaderByQPS(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	lb, err := CreateScheduler(BalanceLeaderType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(BalanceLeaderType, []string{"", ""}))
+	re.NoError(err)
+	conf := lb.(*balanceLeaderScheduler).conf
+	updateConfig := func(body string) int {
+		w := httptest.NewRecorder()
+		lb.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body)))
+		return w.Code
+	}
+
+	// the leader counts are the same, but the leaders of store 1 serve much
+	// more queries.
+	tc.SetHotRegionCacheHitsThreshold(0)
+	tc.AddLeaderStore(1, 10)
+	tc.AddLeaderStore(2, 10)
+	tc.AddLeaderStore(3, 10)
+	tc.AddRegionWithReadInfo(1, 1, 0, 0, 3000*utils.StoreHeartBeatReportInterval, utils.StoreHeartBeatReportInterval, []uint64{2, 3})
+	// the queries served by the followers are not counted.
+	tc.UpdateStorageReadQuery(2, 10000*utils.StoreHeartBeatReportInterval)
+
+	ops, _ := lb.Schedule(tc, false)
+	re.Empty(ops)
+
+	re.Equal(http.StatusOK, updateConfig(`{"dimension":"qps"}`))
+	re.Equal(leaderDimensionQPS, conf.getDimension())
+	ops, _ = lb.Schedule(tc, false)
+	re.NotEmpty(ops)
+	operatorutil.CheckTransferLeaderFrom(re, ops[0], operator.OpLeader, 1)
+
+	re.Equal(http.StatusBadRequest, updateConfig(`{"dimension":"unknown"}`))
+	re.Equal(leaderDimensionQPS, conf.getDimension())
+	re.Equal(http.StatusOK, updateConfig(`{"dimension":"count"}`))
+	re.Equal(leaderDimensionCount, conf.getDimension())
+}
+
 func BenchmarkCandidateStores(b *testing.B) {
 	cancel, _, tc, _ := prepareSchedulersTest()
 	defer cancel()
@@ -210,7 +210,26 @@ func (b *balanceWitnessScheduler) EncodeConfig() ([]byte, error) {
 	return EncodeConfig(b.conf)
 }
 
+func (b *balanceWitnessScheduler) ReloadConfig(data []byte) error {
+	newConf := &balanceWitnessSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	if !newConf.validate() {
+		return errs.ErrSchedulerConfig.FastGenByArgs("batch, which should be an integer between 1 and 10")
+	}
+	b.conf.mu.Lock()
+	defer b.conf.mu.Unlock()
+	b.conf.Ranges, b.conf.Batch = newConf.Ranges, newConf.Batch
+	return nil
+}
+
 func (b *balanceWitnessScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
+	// the witnesses are only created by the rule checker when witness is
+	// enabled, so there is nothing to balance otherwise.
+	if !cluster.GetSchedulerConfig().IsWitnessAllowed() {
+		return false
+	}
 	allowed := b.OpController.OperatorCount(operator.OpWitness) < cluster.GetSchedulerConfig().GetWitnessScheduleLimit()
 	if !allowed {
 		operator.OperatorLimitCounter.WithLabelValues(b.GetType(), operator.OpWitness.String()).Inc()
@@ -63,6 +63,13 @@ func (suite *balanceWitnessSchedulerTestSuite) schedule() []*operator.Operator {
 	return ops
 }
 
+func (suite *balanceWitnessSchedulerTestSuite) TestScheduleAllowed() {
+	suite.tc.SetEnableWitness(false)
+	suite.False(suite.lb.IsScheduleAllowed(suite.tc))
+	suite.tc.SetEnableWitness(true)
+	suite.True(suite.lb.IsScheduleAllowed(suite.tc))
+}
+
 func (suite *balanceWitnessSchedulerTestSuite) TestScheduleWithOpInfluence() {
 	suite.tc.SetTolerantSizeRatio(2.5)
 	// Stores:     1    2    3    4
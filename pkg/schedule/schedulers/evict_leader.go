@@ -154,6 +154,36 @@ func (conf *evictLeaderSchedulerConfig) resetStore(id uint64, keyRange []core.Ke
 	conf.StoreIDWithRanges[id] = keyRange
 }
 
+// reload replaces the stores and their ranges, the leader transfer of the
+// stores which are added is paused and that of the removed ones is resumed.
+func (conf *evictLeaderSchedulerConfig) reload(storeIDWithRanges map[uint64][]core.KeyRange) error {
+	if len(storeIDWithRanges) == 0 {
+		return errs.ErrSchedulerConfig.FastGenByArgs("store-id-ranges, which should not be empty")
+	}
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+	paused := make([]uint64, 0, len(storeIDWithRanges))
+	for id := range storeIDWithRanges {
+		if _, ok := conf.StoreIDWithRanges[id]; ok {
+			continue
+		}
+		if err := conf.cluster.PauseLeaderTransfer(id); err != nil {
+			for _, pausedID := range paused {
+				conf.cluster.ResumeLeaderTransfer(pausedID)
+			}
+			return err
+		}
+		paused = append(paused, id)
+	}
+	for id := range conf.StoreIDWithRanges {
+		if _, ok := storeIDWithRanges[id]; !ok {
+			conf.cluster.ResumeLeaderTransfer(id)
+		}
+	}
+	conf.StoreIDWithRanges = storeIDWithRanges
+	return nil
+}
+
 func (conf *evictLeaderSchedulerConfig) getKeyRangesByID(id uint64) []core.KeyRange {
 	conf.mu.RLock()
 	defer conf.mu.RUnlock()
@@ -204,6 +234,14 @@ func (s *evictLeaderScheduler) EncodeConfig() ([]byte, error) {
 	return EncodeConfig(s.conf)
 }
 
+func (s *evictLeaderScheduler) ReloadConfig(data []byte) error {
+	newConf := &evictLeaderSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	return s.conf.reload(newConf.StoreIDWithRanges)
+}
+
 func (s *evictLeaderScheduler) Prepare(cluster sche.SchedulerCluster) error {
 	s.conf.mu.RLock()
 	defer s.conf.mu.RUnlock()
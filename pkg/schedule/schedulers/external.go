@@ -0,0 +1,632 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/constant"
+	"github.com/tikv/pd/pkg/errs"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"github.com/tikv/pd/pkg/schedule/filter"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/plan"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/apiutil"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"github.com/unrolled/render"
+	"go.uber.org/zap"
+)
+
+const (
+	// ExternalType is external scheduler type.
+	ExternalType = "external"
+	// ExternalName is external scheduler name, the name of an external
+	// scheduler is the name with the plugin name as the suffix.
+	ExternalName = "external"
+
+	defaultExternalOperatorBudget = 16
+	defaultExternalStoreBudget    = 4
+	// maxExternalPendingProposals is the max number of the proposals waiting
+	// to be scheduled, the new ones are rejected beyond it.
+	maxExternalPendingProposals = 1024
+	// maxExternalResults is the max number of the results of the proposals
+	// kept in memory, the oldest ones are discarded beyond it.
+	maxExternalResults = 1024
+	// defaultExternalSnapshotLimit is the default number of the regions in a
+	// page of the snapshot.
+	defaultExternalSnapshotLimit = 1024
+	// maxExternalPluginNameLength is the max length of the plugin name.
+	maxExternalPluginNameLength = 64
+)
+
+// externalPluginNamePattern restricts the plugin names to the letters, digits
+// and underscores, so the name of an external scheduler never contains the
+// type of another scheduler, which is used to find the type by the name.
+var externalPluginNamePattern = regexp.MustCompile("^[a-zA-Z0-9_]+$")
+
+// The kinds of the operators which can be proposed by an external scheduler.
+const (
+	ExternalTransferLeader = "transfer-leader"
+	ExternalMovePeer       = "move-peer"
+)
+
+// The states of a proposal of an external scheduler.
+const (
+	ExternalProposalPending  = "pending"
+	ExternalProposalRejected = "rejected"
+	ExternalProposalRunning  = "running"
+)
+
+var (
+	// WithLabelValues is a heavy operation, define variable to avoid call it every time.
+	externalCounter                = schedulerCounter.WithLabelValues(ExternalName, "schedule")
+	externalNewOperatorCounter     = schedulerCounter.WithLabelValues(ExternalName, "new-operator")
+	externalRejectedCounter        = schedulerCounter.WithLabelValues(ExternalName, "rejected")
+	externalStoreBudgetCounter     = schedulerCounter.WithLabelValues(ExternalName, "store-budget-exhausted")
+	externalOperatorBudgetExceeded = schedulerCounter.WithLabelValues(ExternalName, "operator-budget-exhausted")
+)
+
+type externalSchedulerConfig struct {
+	mu         syncutil.RWMutex
+	storage    endpoint.ConfigStorage
+	PluginName string `json:"plugin-name"`
+	// OperatorBudget is the max number of the unfinished operators of the
+	// plugin, and StoreBudget is the max number of them involving a store.
+	// They are independent of the schedule limits of the built-in schedulers.
+	OperatorBudget uint64 `json:"operator-budget"`
+	StoreBudget    uint64 `json:"store-budget"`
+}
+
+func (conf *externalSchedulerConfig) BuildWithArgs(args []string) error {
+	if len(args) < 1 || len(args) > 3 {
+		return errs.ErrSchedulerConfig.FastGenByArgs("plugin name and budgets")
+	}
+	if err := validateExternalPluginName(args[0]); err != nil {
+		return err
+	}
+	budgets := []uint64{defaultExternalOperatorBudget, defaultExternalStoreBudget}
+	for i, arg := range args[1:] {
+		budget, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return errs.ErrStrconvParseUint.Wrap(err).FastGenWithCause()
+		}
+		if budget == 0 {
+			return errs.ErrSchedulerConfig.FastGenByArgs("budget")
+		}
+		budgets[i] = budget
+	}
+	conf.mu.Lock()
+	defer conf.mu.Unlock()
+	conf.PluginName = args[0]
+	conf.OperatorBudget, conf.StoreBudget = budgets[0], budgets[1]
+	return nil
+}
+
+func (conf *externalSchedulerConfig) Clone() *externalSchedulerConfig {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return &externalSchedulerConfig{
+		PluginName:     conf.PluginName,
+		OperatorBudget: conf.OperatorBudget,
+		StoreBudget:    conf.StoreBudget,
+	}
+}
+
+func (conf *externalSchedulerConfig) Persist() error {
+	name := conf.getSchedulerName()
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	data, err := EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(name, data)
+}
+
+func (conf *externalSchedulerConfig) getBudgets() (operatorBudget, storeBudget uint64) {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return conf.OperatorBudget, conf.StoreBudget
+}
+
+func (conf *externalSchedulerConfig) getSchedulerName() string {
+	conf.mu.RLock()
+	defer conf.mu.RUnlock()
+	return externalSchedulerName(conf.PluginName)
+}
+
+func externalSchedulerName(pluginName string) string {
+	return fmt.Sprintf("%s-%s", ExternalName, pluginName)
+}
+
+// validateExternalPluginName checks the plugin name, the scheduler named by it
+// must be found as an external scheduler once it's reloaded.
+func validateExternalPluginName(pluginName string) error {
+	if len(pluginName) == 0 || len(pluginName) > maxExternalPluginNameLength ||
+		!externalPluginNamePattern.MatchString(pluginName) ||
+		FindSchedulerTypeByName(externalSchedulerName(pluginName)) != ExternalType {
+		return errs.ErrSchedulerConfig.FastGenByArgs(fmt.Sprintf("plugin name %q, which should be at most %d letters, digits or underscores",
+			pluginName, maxExternalPluginNameLength))
+	}
+	return nil
+}
+
+// ExternalProposal is an operator proposed by an external scheduler.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type ExternalProposal struct {
+	RegionID uint64 `json:"region_id"`
+	// Kind is ExternalTransferLeader or ExternalMovePeer.
+	Kind        string `json:"kind"`
+	SourceStore uint64 `json:"source_store"`
+	TargetStore uint64 `json:"target_store"`
+	// RegionEpoch is the epoch of the region which the proposal is based on,
+	// the proposal is rejected if the region has changed since then.
+	RegionEpoch *metapb.RegionEpoch `json:"region_epoch,omitempty"`
+}
+
+// ExternalProposalResult is the result of a proposal of an external scheduler.
+// NOTE: This type is exported by HTTP API. Please pay more attention when modifying it.
+type ExternalProposalResult struct {
+	ID       uint64            `json:"id"`
+	Proposal *ExternalProposal `json:"proposal"`
+	// Status is ExternalProposalPending, ExternalProposalRejected, or
+	// ExternalProposalRunning and the status of the operator after it starts.
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+	op     *operator.Operator
+}
+
+// ExternalStore is a store in the snapshot of an external scheduler.
+type ExternalStore struct {
+	Meta        *metapb.Store `json:"meta"`
+	LeaderCount int           `json:"leader_count"`
+	RegionCount int           `json:"region_count"`
+}
+
+// ExternalRegion is a region in the snapshot of an external scheduler.
+type ExternalRegion struct {
+	Meta            *metapb.Region `json:"meta"`
+	Leader          *metapb.Peer   `json:"leader"`
+	ApproximateSize int64          `json:"approximate_size"`
+}
+
+// ExternalSnapshot is a page of the cluster snapshot of an external scheduler.
+// The stores are only in the first page, and the regions are paged by the key,
+// NextKey is the hex encoded start key of the next page, which is empty at
+// the end.
+type ExternalSnapshot struct {
+	Stores  []*ExternalStore  `json:"stores,omitempty"`
+	Regions []*ExternalRegion `json:"regions"`
+	NextKey string            `json:"next_key,omitempty"`
+}
+
+// externalScheduler schedules the operators proposed by an out-of-process
+// scheduler. The plugin reads the snapshot of the cluster and submits the
+// proposals, which are checked against the cluster and the budgets of the
+// plugin before becoming operators.
+type externalScheduler struct {
+	*BaseScheduler
+	conf    *externalSchedulerConfig
+	handler http.Handler
+	filters []filter.Filter
+
+	mu      syncutil.Mutex
+	cluster sche.SchedulerCluster
+	nextID  uint64
+	pending []*ExternalProposalResult
+	results []*ExternalProposalResult
+	running []*ExternalProposalResult
+}
+
+// newExternalScheduler creates a scheduler that schedules the operators
+// proposed by an external scheduler.
+func newExternalScheduler(opController *operator.Controller, conf *externalSchedulerConfig) Scheduler {
+	s := &externalScheduler{
+		BaseScheduler: NewBaseScheduler(opController),
+		conf:          conf,
+		nextID:        1,
+	}
+	name := conf.getSchedulerName()
+	s.filters = []filter.Filter{
+		&filter.StoreStateFilter{ActionScope: name, MoveRegion: true, OperatorLevel: constant.Medium},
+		filter.NewSpecialUseFilter(name),
+	}
+	s.handler = newExternalHandler(s)
+	return s
+}
+
+func (s *externalScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.handler.ServeHTTP(w, r)
+}
+
+func (s *externalScheduler) GetName() string {
+	return s.conf.getSchedulerName()
+}
+
+func (s *externalScheduler) GetType() string {
+	return ExternalType
+}
+
+func (s *externalScheduler) EncodeConfig() ([]byte, error) {
+	s.conf.mu.RLock()
+	defer s.conf.mu.RUnlock()
+	return EncodeConfig(s.conf)
+}
+
+func (s *externalScheduler) Prepare(cluster sche.SchedulerCluster) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cluster = cluster
+	return nil
+}
+
+func (s *externalScheduler) Cleanup(cluster sche.SchedulerCluster) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cluster = nil
+	for _, r := range s.pending {
+		r.Status, r.Reason = ExternalProposalRejected, "scheduler removed"
+	}
+	s.pending = nil
+}
+
+func (s *externalScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
+	operatorBudget, _ := s.conf.getBudgets()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gcRunningLocked()
+	if len(s.pending) == 0 {
+		return false
+	}
+	allowed := uint64(len(s.running)) < operatorBudget
+	if !allowed {
+		externalOperatorBudgetExceeded.Inc()
+	}
+	return allowed
+}
+
+func (s *externalScheduler) Schedule(cluster sche.SchedulerCluster, dryRun bool) ([]*operator.Operator, []plan.Plan) {
+	externalCounter.Inc()
+	if dryRun {
+		return nil, nil
+	}
+	operatorBudget, storeBudget := s.conf.getBudgets()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.gcRunningLocked()
+	storeOps := make(map[uint64]uint64)
+	for _, r := range s.running {
+		for _, id := range r.Proposal.stores() {
+			storeOps[id]++
+		}
+	}
+	var ops []*operator.Operator
+	for len(s.pending) > 0 && uint64(len(s.running)) < operatorBudget {
+		r := s.pending[0]
+		s.pending = s.pending[1:]
+		exhausted := false
+		for _, id := range r.Proposal.stores() {
+			if storeOps[id] >= storeBudget {
+				exhausted = true
+			}
+		}
+		if exhausted {
+			externalStoreBudgetCounter.Inc()
+			s.rejectLocked(r, "store budget exhausted")
+			continue
+		}
+		op, err := s.createOperator(cluster, r.Proposal)
+		if err != nil {
+			log.Debug("fail to create external operator", zap.String("scheduler", s.GetName()), errs.ZapError(err))
+			s.rejectLocked(r, err.Error())
+			continue
+		}
+		op.Counters = append(op.Counters, externalNewOperatorCounter)
+		r.Status, r.op = ExternalProposalRunning, op
+		s.running = append(s.running, r)
+		for _, id := range r.Proposal.stores() {
+			storeOps[id]++
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// createOperator checks the proposal against the cluster and creates the
+// operator of it.
+func (s *externalScheduler) createOperator(cluster sche.SchedulerCluster, p *ExternalProposal) (*operator.Operator, error) {
+	region := cluster.GetRegion(p.RegionID)
+	if region == nil {
+		return nil, errors.Errorf("region %d not found", p.RegionID)
+	}
+	if p.RegionEpoch != nil {
+		epoch := region.GetRegionEpoch()
+		if epoch.GetVersion() != p.RegionEpoch.GetVersion() || epoch.GetConfVer() != p.RegionEpoch.GetConfVer() {
+			return nil, errors.New("stale region epoch")
+		}
+	}
+	if status := filter.NewRegionPendingFilter().Select(region); !status.IsOK() {
+		return nil, errors.New("region has pending peers")
+	}
+	if status := filter.NewRegionDownFilter().Select(region); !status.IsOK() {
+		return nil, errors.New("region has down peers")
+	}
+	if region.GetStorePeer(p.SourceStore) == nil {
+		return nil, errors.Errorf("no peer of region %d in source store %d", p.RegionID, p.SourceStore)
+	}
+	conf := cluster.GetSchedulerConfig()
+	source, target := cluster.GetStore(p.SourceStore), cluster.GetStore(p.TargetStore)
+	if source == nil {
+		return nil, errs.ErrStoreNotFound.FastGenByArgs(p.SourceStore)
+	}
+	if target == nil {
+		return nil, errs.ErrStoreNotFound.FastGenByArgs(p.TargetStore)
+	}
+	name := s.GetName()
+	switch p.Kind {
+	case ExternalTransferLeader:
+		if region.GetLeader().GetStoreId() != p.SourceStore {
+			return nil, errors.Errorf("the leader of region %d is not in source store %d", p.RegionID, p.SourceStore)
+		}
+		filters := []filter.Filter{
+			&filter.StoreStateFilter{ActionScope: name, TransferLeader: true, OperatorLevel: constant.Medium},
+			filter.NewSpecialUseFilter(name),
+		}
+		if f := filter.NewPlacementLeaderSafeguard(name, conf, cluster.GetBasicCluster(), cluster.GetRuleManager(), region, source, false); f != nil {
+			filters = append(filters, f)
+		}
+		if len(filter.SelectTargetStores([]*core.StoreInfo{target}, filters, conf, nil, nil)) == 0 {
+			return nil, errors.Errorf("can not transfer the leader to store %d", p.TargetStore)
+		}
+		return operator.CreateTransferLeaderOperator(name, cluster, region, p.SourceStore, p.TargetStore, []uint64{}, operator.OpLeader)
+	case ExternalMovePeer:
+		if region.GetStorePeer(p.TargetStore) != nil {
+			return nil, errors.Errorf("region %d already has a peer in target store %d", p.RegionID, p.TargetStore)
+		}
+		filters := append([]filter.Filter{
+			filter.NewPlacementSafeguard(name, conf, cluster.GetBasicCluster(), cluster.GetRuleManager(), region, source, nil),
+		}, s.filters...)
+		if len(filter.SelectTargetStores([]*core.StoreInfo{target}, filters, conf, nil, nil)) == 0 {
+			return nil, errors.Errorf("can not move the peer to store %d", p.TargetStore)
+		}
+		oldPeer := region.GetStorePeer(p.SourceStore)
+		return operator.CreateMovePeerOperator(name, cluster, region, operator.OpRegion, p.SourceStore, &metapb.Peer{StoreId: p.TargetStore, Role: oldPeer.Role})
+	default:
+		return nil, errors.Errorf("unknown kind %s", p.Kind)
+	}
+}
+
+// Submit queues the proposals, which are scheduled in order, and returns the
+// results of them.
+func (s *externalScheduler) Submit(proposals []*ExternalProposal) []*ExternalProposalResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*ExternalProposalResult, 0, len(proposals))
+	for _, p := range proposals {
+		r := &ExternalProposalResult{ID: s.nextID, Proposal: p, Status: ExternalProposalPending}
+		s.nextID++
+		s.results = append(s.results, r)
+		if n := len(s.results) - maxExternalResults; n > 0 {
+			s.results = append(s.results[:0:0], s.results[n:]...)
+		}
+		switch {
+		case p == nil || p.RegionID == 0:
+			s.rejectLocked(r, "no region")
+		case p.Kind != ExternalTransferLeader && p.Kind != ExternalMovePeer:
+			s.rejectLocked(r, fmt.Sprintf("unknown kind %s", p.Kind))
+		case p.SourceStore == p.TargetStore:
+			s.rejectLocked(r, "the same source and target store")
+		case s.cluster == nil:
+			s.rejectLocked(r, "scheduler not prepared")
+		case len(s.pending) >= maxExternalPendingProposals:
+			s.rejectLocked(r, "too many pending proposals")
+		default:
+			s.pending = append(s.pending, r)
+		}
+		res = append(res, r.clone())
+	}
+	return res
+}
+
+// GetResults returns the results of the recent proposals in order.
+func (s *externalScheduler) GetResults() []*ExternalProposalResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*ExternalProposalResult, 0, len(s.results))
+	for _, r := range s.results {
+		res = append(res, r.clone())
+	}
+	return res
+}
+
+// GetSnapshot returns a page of the snapshot of the cluster from the start
+// key with at most limit regions. The plugin follows the changes of the
+// regions by reading the pages repeatedly.
+func (s *externalScheduler) GetSnapshot(startKey []byte, limit int) (*ExternalSnapshot, error) {
+	s.mu.Lock()
+	cluster := s.cluster
+	s.mu.Unlock()
+	if cluster == nil {
+		return nil, errs.ErrNotBootstrapped.FastGenByArgs()
+	}
+	if limit <= 0 {
+		limit = defaultExternalSnapshotLimit
+	}
+	snapshot := &ExternalSnapshot{}
+	if len(startKey) == 0 {
+		for _, store := range cluster.GetStores() {
+			if store.IsRemoved() {
+				continue
+			}
+			snapshot.Stores = append(snapshot.Stores, &ExternalStore{
+				Meta:        store.GetMeta(),
+				LeaderCount: store.GetLeaderCount(),
+				RegionCount: store.GetRegionCount(),
+			})
+		}
+	}
+	regions := cluster.ScanRegions(startKey, nil, limit)
+	snapshot.Regions = make([]*ExternalRegion, 0, len(regions))
+	for _, region := range regions {
+		snapshot.Regions = append(snapshot.Regions, &ExternalRegion{
+			Meta:            region.GetMeta(),
+			Leader:          region.GetLeader(),
+			ApproximateSize: region.GetApproximateSize(),
+		})
+	}
+	if len(regions) == limit {
+		snapshot.NextKey = hex.EncodeToString(regions[len(regions)-1].GetEndKey())
+	}
+	return snapshot, nil
+}
+
+// gcRunningLocked removes the finished operators from the running ones.
+func (s *externalScheduler) gcRunningLocked() {
+	running := s.running[:0]
+	for _, r := range s.running {
+		if !r.op.IsEnd() {
+			running = append(running, r)
+		}
+	}
+	s.running = running
+}
+
+func (s *externalScheduler) rejectLocked(r *ExternalProposalResult, reason string) {
+	externalRejectedCounter.Inc()
+	r.Status, r.Reason = ExternalProposalRejected, reason
+}
+
+func (r *ExternalProposalResult) clone() *ExternalProposalResult {
+	res := *r
+	res.op = nil
+	if r.op != nil {
+		if status := r.op.Status(); status != operator.CREATED {
+			res.Status = operator.OpStatusToString(status)
+		}
+	}
+	return &res
+}
+
+// stores returns the stores involved in the proposal.
+func (p *ExternalProposal) stores() []uint64 {
+	if p.Kind == ExternalTransferLeader {
+		return []uint64{p.TargetStore}
+	}
+	return []uint64{p.SourceStore, p.TargetStore}
+}
+
+type externalHandler struct {
+	rd        *render.Render
+	scheduler *externalScheduler
+}
+
+func (handler *externalHandler) ListConfig(w http.ResponseWriter, r *http.Request) {
+	conf := handler.scheduler.conf.Clone()
+	handler.rd.JSON(w, http.StatusOK, conf)
+}
+
+func (handler *externalHandler) UpdateConfig(w http.ResponseWriter, r *http.Request) {
+	var input map[string]interface{}
+	if err := apiutil.ReadJSONRespondError(handler.rd, w, r.Body, &input); err != nil {
+		return
+	}
+	conf := handler.scheduler.conf
+	old := conf.Clone()
+	args := []string{old.PluginName, strconv.FormatUint(old.OperatorBudget, 10), strconv.FormatUint(old.StoreBudget, 10)}
+	for i, key := range []string{"operator-budget", "store-budget"} {
+		v, ok := input[key]
+		if !ok {
+			continue
+		}
+		budget, ok := v.(float64)
+		if !ok || budget <= 0 || budget != math.Trunc(budget) || budget > math.MaxUint32 {
+			handler.rd.JSON(w, http.StatusBadRequest, errs.ErrSchedulerConfig.FastGenByArgs(key).Error())
+			return
+		}
+		args[i+1] = strconv.FormatUint(uint64(budget), 10)
+	}
+	if err := conf.BuildWithArgs(args); err != nil {
+		handler.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := conf.Persist(); err != nil {
+		conf.mu.Lock()
+		conf.OperatorBudget, conf.StoreBudget = old.OperatorBudget, old.StoreBudget
+		conf.mu.Unlock()
+		handler.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	handler.rd.JSON(w, http.StatusOK, "Config is updated.")
+}
+
+func (handler *externalHandler) Submit(w http.ResponseWriter, r *http.Request) {
+	var proposals []*ExternalProposal
+	if err := apiutil.ReadJSONRespondError(handler.rd, w, r.Body, &proposals); err != nil {
+		return
+	}
+	handler.rd.JSON(w, http.StatusOK, handler.scheduler.Submit(proposals))
+}
+
+func (handler *externalHandler) GetResults(w http.ResponseWriter, r *http.Request) {
+	handler.rd.JSON(w, http.StatusOK, handler.scheduler.GetResults())
+}
+
+func (handler *externalHandler) GetSnapshot(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit := 0
+	if limitStr := query.Get("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil {
+			handler.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	startKey, err := hex.DecodeString(query.Get("start_key"))
+	if err != nil {
+		handler.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	snapshot, err := handler.scheduler.GetSnapshot(startKey, limit)
+	if err != nil {
+		handler.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	handler.rd.JSON(w, http.StatusOK, snapshot)
+}
+
+func newExternalHandler(scheduler *externalScheduler) http.Handler {
+	h := &externalHandler{
+		scheduler: scheduler,
+		rd:        render.New(render.Options{IndentJSON: true}),
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/list", h.ListConfig).Methods(http.MethodGet)
+	router.HandleFunc("/config", h.UpdateConfig).Methods(http.MethodPost)
+	router.HandleFunc("/proposals", h.Submit).Methods(http.MethodPost)
+	router.HandleFunc("/proposals", h.GetResults).Methods(http.MethodGet)
+	router.HandleFunc("/snapshot", h.GetSnapshot).Methods(http.MethodGet)
+	return router
+}
@@ -0,0 +1,374 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ExternalSchedulerServiceName is the name of the gRPC service of the
+	// external schedulers.
+	ExternalSchedulerServiceName = "schedulers.ExternalScheduler"
+	// ExternalCodecName is the content subtype of the gRPC service of the
+	// external schedulers, the messages are encoded in JSON, so the plugins
+	// need no generated code, e.g. grpc.CallContentSubtype(ExternalCodecName).
+	// The name is unique to not replace the codecs of the other gRPC services.
+	ExternalCodecName = "pd-external-json"
+
+	defaultExternalWatchInterval = 10 * time.Second
+	minExternalWatchInterval     = time.Second
+)
+
+func init() {
+	encoding.RegisterCodec(externalCodec{})
+}
+
+type externalCodec struct{}
+
+func (externalCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (externalCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (externalCodec) Name() string {
+	return ExternalCodecName
+}
+
+// ExternalRegisterRequest registers a plugin, which creates the external
+// scheduler of it if it does not exist. The budgets are optional.
+type ExternalRegisterRequest struct {
+	PluginName     string `json:"plugin_name"`
+	OperatorBudget uint64 `json:"operator_budget,omitempty"`
+	StoreBudget    uint64 `json:"store_budget,omitempty"`
+}
+
+// ExternalRegisterResponse is the response of ExternalRegisterRequest.
+type ExternalRegisterResponse struct {
+	SchedulerName string `json:"scheduler_name"`
+}
+
+// ExternalSnapshotRequest reads a page of the snapshot from the start key.
+type ExternalSnapshotRequest struct {
+	PluginName string `json:"plugin_name"`
+	StartKey   []byte `json:"start_key,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+}
+
+// ExternalSubmitRequest submits the proposals of a plugin.
+type ExternalSubmitRequest struct {
+	PluginName string              `json:"plugin_name"`
+	Proposals  []*ExternalProposal `json:"proposals"`
+}
+
+// ExternalResultsRequest reads the results of the recent proposals of a plugin.
+type ExternalResultsRequest struct {
+	PluginName string `json:"plugin_name"`
+}
+
+// ExternalResultsResponse is the results of the proposals of a plugin.
+type ExternalResultsResponse struct {
+	Results []*ExternalProposalResult `json:"results"`
+}
+
+// ExternalWatchRequest watches the changes of the regions, which are checked
+// every interval in seconds.
+type ExternalWatchRequest struct {
+	PluginName  string `json:"plugin_name"`
+	IntervalSec uint64 `json:"interval_sec,omitempty"`
+}
+
+// ExternalRegionEvent is a change of a region streamed to a plugin, either
+// the region is added or updated, or it's removed, e.g. merged.
+type ExternalRegionEvent struct {
+	Region    *ExternalRegion `json:"region,omitempty"`
+	RemovedID uint64          `json:"removed_id,omitempty"`
+}
+
+// ExternalSchedulerServer is the gRPC server of the external schedulers.
+type ExternalSchedulerServer interface {
+	Register(context.Context, *ExternalRegisterRequest) (*ExternalRegisterResponse, error)
+	GetSnapshot(context.Context, *ExternalSnapshotRequest) (*ExternalSnapshot, error)
+	SubmitProposals(context.Context, *ExternalSubmitRequest) (*ExternalResultsResponse, error)
+	GetResults(context.Context, *ExternalResultsRequest) (*ExternalResultsResponse, error)
+	// WatchRegions streams all the regions first, and then their changes.
+	WatchRegions(*ExternalWatchRequest, ExternalWatchRegionsServer) error
+}
+
+// ExternalWatchRegionsServer is the server stream of WatchRegions.
+type ExternalWatchRegionsServer interface {
+	Send(*ExternalRegionEvent) error
+	grpc.ServerStream
+}
+
+type externalWatchRegionsServer struct {
+	grpc.ServerStream
+}
+
+func (s *externalWatchRegionsServer) Send(e *ExternalRegionEvent) error {
+	return s.ServerStream.SendMsg(e)
+}
+
+// ExternalSchedulerServiceDesc is the description of the gRPC service of the
+// external schedulers.
+var ExternalSchedulerServiceDesc = grpc.ServiceDesc{
+	ServiceName: ExternalSchedulerServiceName,
+	HandlerType: (*ExternalSchedulerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		newExternalMethodDesc("Register", ExternalSchedulerServer.Register),
+		newExternalMethodDesc("GetSnapshot", ExternalSchedulerServer.GetSnapshot),
+		newExternalMethodDesc("SubmitProposals", ExternalSchedulerServer.SubmitProposals),
+		newExternalMethodDesc("GetResults", ExternalSchedulerServer.GetResults),
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "WatchRegions",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				req := &ExternalWatchRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(ExternalSchedulerServer).WatchRegions(req, &externalWatchRegionsServer{stream})
+			},
+			ServerStreams: true,
+		},
+	},
+}
+
+func newExternalMethodDesc[Req, Resp any](name string, call func(ExternalSchedulerServer, context.Context, *Req) (*Resp, error)) grpc.MethodDesc {
+	return grpc.MethodDesc{
+		MethodName: name,
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(Req)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(srv.(ExternalSchedulerServer), ctx, req.(*Req))
+			}
+			if interceptor == nil {
+				return handler(ctx, req)
+			}
+			info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + ExternalSchedulerServiceName + "/" + name}
+			return interceptor(ctx, req, info, handler)
+		},
+	}
+}
+
+// RegisterExternalSchedulerServer registers the gRPC service of the external
+// schedulers to the gRPC server.
+func RegisterExternalSchedulerServer(g *grpc.Server, srv ExternalSchedulerServer) {
+	g.RegisterService(&ExternalSchedulerServiceDesc, srv)
+}
+
+type externalSchedulerServer struct {
+	getScheduler func(name string) (Scheduler, error)
+	addScheduler func(args ...string) error
+}
+
+// NewExternalSchedulerServer creates the gRPC server of the external schedulers.
+// getScheduler returns the running scheduler by name, and addScheduler adds an
+// external scheduler with the args of it.
+func NewExternalSchedulerServer(getScheduler func(name string) (Scheduler, error), addScheduler func(args ...string) error) ExternalSchedulerServer {
+	return &externalSchedulerServer{getScheduler: getScheduler, addScheduler: addScheduler}
+}
+
+func (s *externalSchedulerServer) Register(_ context.Context, req *ExternalRegisterRequest) (*ExternalRegisterResponse, error) {
+	if err := validateExternalPluginName(req.PluginName); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	name := externalSchedulerName(req.PluginName)
+	if _, err := s.getScheduler(name); err == nil {
+		return &ExternalRegisterResponse{SchedulerName: name}, nil
+	}
+	args := []string{req.PluginName}
+	if req.OperatorBudget > 0 || req.StoreBudget > 0 {
+		operatorBudget, storeBudget := req.OperatorBudget, req.StoreBudget
+		if operatorBudget == 0 {
+			operatorBudget = defaultExternalOperatorBudget
+		}
+		if storeBudget == 0 {
+			storeBudget = defaultExternalStoreBudget
+		}
+		args = append(args, strconv.FormatUint(operatorBudget, 10), strconv.FormatUint(storeBudget, 10))
+	}
+	if err := s.addScheduler(args...); err != nil && !errs.ErrSchedulerExisted.Equal(err) {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &ExternalRegisterResponse{SchedulerName: name}, nil
+}
+
+func (s *externalSchedulerServer) GetSnapshot(_ context.Context, req *ExternalSnapshotRequest) (*ExternalSnapshot, error) {
+	es, err := s.getExternalScheduler(req.PluginName)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := es.GetSnapshot(req.StartKey, req.Limit)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return snapshot, nil
+}
+
+func (s *externalSchedulerServer) SubmitProposals(_ context.Context, req *ExternalSubmitRequest) (*ExternalResultsResponse, error) {
+	es, err := s.getExternalScheduler(req.PluginName)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalResultsResponse{Results: es.Submit(req.Proposals)}, nil
+}
+
+func (s *externalSchedulerServer) GetResults(_ context.Context, req *ExternalResultsRequest) (*ExternalResultsResponse, error) {
+	es, err := s.getExternalScheduler(req.PluginName)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalResultsResponse{Results: es.GetResults()}, nil
+}
+
+func (s *externalSchedulerServer) WatchRegions(req *ExternalWatchRequest, stream ExternalWatchRegionsServer) error {
+	es, err := s.getExternalScheduler(req.PluginName)
+	if err != nil {
+		return err
+	}
+	interval := defaultExternalWatchInterval
+	if req.IntervalSec > 0 {
+		interval = time.Duration(req.IntervalSec) * time.Second
+	}
+	if interval < minExternalWatchInterval {
+		interval = minExternalWatchInterval
+	}
+	err = es.WatchRegions(stream.Context(), interval, stream.Send)
+	if err == stream.Context().Err() {
+		return nil
+	}
+	return err
+}
+
+func (s *externalSchedulerServer) getExternalScheduler(pluginName string) (*externalScheduler, error) {
+	if err := validateExternalPluginName(pluginName); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	sl, err := s.getScheduler(externalSchedulerName(pluginName))
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	es, ok := sl.(*externalScheduler)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%s is not an external scheduler", sl.GetName())
+	}
+	return es, nil
+}
+
+// WatchRegions sends all the regions first, and then the regions changed since
+// they were sent last time every interval, until the context is done or it
+// fails to send. The regions are changed if their epochs or leaders change.
+func (s *externalScheduler) WatchRegions(ctx context.Context, interval time.Duration, send func(*ExternalRegionEvent) error) error {
+	watcher := newExternalRegionWatcher()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		cluster := s.cluster
+		s.mu.Unlock()
+		if cluster == nil {
+			return errs.ErrNotBootstrapped.FastGenByArgs()
+		}
+		for _, e := range watcher.diff(scanAllRegions(cluster)) {
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func scanAllRegions(cluster sche.SchedulerCluster) []*core.RegionInfo {
+	var regions []*core.RegionInfo
+	var startKey []byte
+	for {
+		page := cluster.ScanRegions(startKey, nil, defaultExternalSnapshotLimit)
+		regions = append(regions, page...)
+		if len(page) < defaultExternalSnapshotLimit {
+			return regions
+		}
+		startKey = page[len(page)-1].GetEndKey()
+		if len(startKey) == 0 {
+			return regions
+		}
+	}
+}
+
+type externalRegionState struct {
+	version, confVer, leaderStoreID uint64
+}
+
+// externalRegionWatcher records the states of the regions sent to a plugin.
+type externalRegionWatcher struct {
+	sent map[uint64]externalRegionState
+}
+
+func newExternalRegionWatcher() *externalRegionWatcher {
+	return &externalRegionWatcher{sent: make(map[uint64]externalRegionState)}
+}
+
+// diff returns the events of the regions changed since the last time, and the
+// regions not in the cluster anymore.
+func (w *externalRegionWatcher) diff(regions []*core.RegionInfo) []*ExternalRegionEvent {
+	var events []*ExternalRegionEvent
+	current := make(map[uint64]struct{}, len(regions))
+	for _, region := range regions {
+		current[region.GetID()] = struct{}{}
+		state := externalRegionState{
+			version:       region.GetRegionEpoch().GetVersion(),
+			confVer:       region.GetRegionEpoch().GetConfVer(),
+			leaderStoreID: region.GetLeader().GetStoreId(),
+		}
+		if sent, ok := w.sent[region.GetID()]; ok && sent == state {
+			continue
+		}
+		w.sent[region.GetID()] = state
+		events = append(events, &ExternalRegionEvent{Region: &ExternalRegion{
+			Meta:            region.GetMeta(),
+			Leader:          region.GetLeader(),
+			ApproximateSize: region.GetApproximateSize(),
+		}})
+	}
+	for id := range w.sent {
+		if _, ok := current[id]; !ok {
+			delete(w.sent, id)
+			events = append(events, &ExternalRegionEvent{RemovedID: id})
+		}
+	}
+	return events
+}
@@ -0,0 +1,254 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestExternalScheduler(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	for id := uint64(1); id <= 4; id++ {
+		tc.AddLeaderStore(id, 0)
+	}
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	tc.AddLeaderRegion(2, 1, 2, 3)
+	tc.AddLeaderRegion(3, 1, 2, 3)
+
+	_, err := CreateScheduler(ExternalType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(ExternalType, []string{""}))
+	re.Error(err)
+	sl, err := CreateScheduler(ExternalType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(ExternalType, []string{"foo", "2", "1"}))
+	re.NoError(err)
+	re.Equal("external-foo", sl.GetName())
+	s := sl.(*externalScheduler)
+
+	// the proposals are rejected before the scheduler is prepared.
+	results := s.Submit([]*ExternalProposal{{RegionID: 1, Kind: ExternalTransferLeader, SourceStore: 1, TargetStore: 2}})
+	re.Equal(ExternalProposalRejected, results[0].Status)
+	re.NoError(sl.Prepare(tc))
+	re.False(sl.IsScheduleAllowed(tc))
+
+	snapshot, err := s.GetSnapshot(nil, 2)
+	re.NoError(err)
+	re.Len(snapshot.Stores, 4)
+	re.Len(snapshot.Regions, 2)
+	re.NotEmpty(snapshot.NextKey)
+
+	results = s.Submit([]*ExternalProposal{
+		{RegionID: 1, Kind: ExternalTransferLeader, SourceStore: 1, TargetStore: 2},
+		// the store budget of store 2 is used by the first one.
+		{RegionID: 2, Kind: ExternalTransferLeader, SourceStore: 1, TargetStore: 2},
+		{RegionID: 2, Kind: ExternalMovePeer, SourceStore: 3, TargetStore: 4},
+		// the operator budget is used by the first and the third ones.
+		{RegionID: 3, Kind: ExternalTransferLeader, SourceStore: 1, TargetStore: 2},
+		{RegionID: 3, Kind: "unknown", SourceStore: 1, TargetStore: 2},
+	})
+	re.Len(results, 5)
+	for i, status := range []string{ExternalProposalPending, ExternalProposalPending, ExternalProposalPending, ExternalProposalPending, ExternalProposalRejected} {
+		re.Equal(status, results[i].Status)
+	}
+
+	re.True(sl.IsScheduleAllowed(tc))
+	ops, _ := sl.Schedule(tc, false)
+	re.Len(ops, 2)
+	operatorutil.CheckTransferLeader(re, ops[0], operator.OpLeader, 1, 2)
+	operatorutil.CheckTransferPeer(re, ops[1], operator.OpRegion, 3, 4)
+	re.False(sl.IsScheduleAllowed(tc))
+
+	results = s.GetResults()
+	re.Len(results, 6)
+	for i, status := range []string{ExternalProposalRejected, ExternalProposalRunning, ExternalProposalRejected, ExternalProposalRunning, ExternalProposalPending, ExternalProposalRejected} {
+		re.Equal(status, results[i].Status)
+	}
+
+	// the budgets are released once the operator is finished.
+	re.True(ops[0].Cancel())
+	re.True(sl.IsScheduleAllowed(tc))
+	ops, _ = sl.Schedule(tc, false)
+	re.Len(ops, 1)
+	operatorutil.CheckTransferLeader(re, ops[0], operator.OpLeader, 1, 2)
+	re.Equal(operator.OpStatusToString(operator.CANCELED), s.GetResults()[1].Status)
+}
+
+func TestExternalPluginName(t *testing.T) {
+	re := require.New(t)
+	cancel, _, _, oc := prepareSchedulersTest()
+	defer cancel()
+
+	for _, name := range []string{"", "balance-leader-x", "foo bar", "../foo", strings.Repeat("a", maxExternalPluginNameLength+1)} {
+		_, err := CreateScheduler(ExternalType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(ExternalType, []string{name}))
+		re.Error(err, name)
+	}
+	for _, name := range []string{"foo", "balance_leader_x", strings.Repeat("a", maxExternalPluginNameLength)} {
+		re.NoError(validateExternalPluginName(name))
+		re.Equal(ExternalType, FindSchedulerTypeByName(externalSchedulerName(name)))
+	}
+}
+
+func TestExternalUpdateConfig(t *testing.T) {
+	re := require.New(t)
+	cancel, _, _, oc := prepareSchedulersTest()
+	defer cancel()
+
+	sl, err := CreateScheduler(ExternalType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(ExternalType, []string{"foo", "2", "1"}))
+	re.NoError(err)
+	s := sl.(*externalScheduler)
+	post := func(body string) int {
+		w := httptest.NewRecorder()
+		sl.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/config", bytes.NewBufferString(body)))
+		return w.Code
+	}
+	for _, body := range []string{`{"operator-budget":-1}`, `{"store-budget":0}`, `{"operator-budget":1.5}`, `{"store-budget":"1"}`, `{"operator-budget":1e20}`} {
+		re.Equal(http.StatusBadRequest, post(body), body)
+	}
+	re.Equal(uint64(2), operatorBudget(s))
+	re.Equal(http.StatusOK, post(`{"operator-budget":4,"store-budget":2}`))
+	re.Equal(uint64(4), operatorBudget(s))
+}
+
+func TestExternalRegionWatcher(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, _ := prepareSchedulersTest()
+	defer cancel()
+
+	for id := uint64(1); id <= 3; id++ {
+		tc.AddLeaderStore(id, 0)
+	}
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	tc.AddLeaderRegion(2, 1, 2, 3)
+	w := newExternalRegionWatcher()
+	events := w.diff([]*core.RegionInfo{tc.GetRegion(1), tc.GetRegion(2)})
+	re.Len(events, 2)
+	re.Empty(w.diff([]*core.RegionInfo{tc.GetRegion(1), tc.GetRegion(2)}))
+
+	// the leader of region 1 is transferred, and region 2 is removed.
+	tc.AddLeaderRegion(1, 2, 1, 3)
+	events = w.diff([]*core.RegionInfo{tc.GetRegion(1)})
+	re.Len(events, 2)
+	re.Equal(uint64(1), events[0].Region.Meta.GetId())
+	re.Equal(uint64(2), events[0].Region.Leader.GetStoreId())
+	re.Nil(events[1].Region)
+	re.Equal(uint64(2), events[1].RemovedID)
+}
+
+func TestExternalSchedulerServer(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	for id := uint64(1); id <= 3; id++ {
+		tc.AddLeaderStore(id, 0)
+	}
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	running := make(map[string]Scheduler)
+	getScheduler := func(name string) (Scheduler, error) {
+		if s, ok := running[name]; ok {
+			return s, nil
+		}
+		return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	addScheduler := func(args ...string) error {
+		s, err := CreateScheduler(ExternalType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(ExternalType, args))
+		if err != nil {
+			return err
+		}
+		if _, ok := running[s.GetName()]; ok {
+			return errs.ErrSchedulerExisted.FastGenByArgs()
+		}
+		running[s.GetName()] = s
+		return s.Prepare(tc)
+	}
+	srv := NewExternalSchedulerServer(getScheduler, addScheduler)
+
+	ctx := context.Background()
+	_, err := srv.GetSnapshot(ctx, &ExternalSnapshotRequest{PluginName: "foo"})
+	re.Error(err)
+	_, err = srv.Register(ctx, &ExternalRegisterRequest{PluginName: "balance-leader-x"})
+	re.Error(err)
+	for i := 0; i < 2; i++ {
+		resp, err := srv.Register(ctx, &ExternalRegisterRequest{PluginName: "foo"})
+		re.NoError(err)
+		re.Equal("external-foo", resp.SchedulerName)
+	}
+	snapshot, err := srv.GetSnapshot(ctx, &ExternalSnapshotRequest{PluginName: "foo"})
+	re.NoError(err)
+	re.Len(snapshot.Regions, 1)
+	results, err := srv.SubmitProposals(ctx, &ExternalSubmitRequest{PluginName: "foo", Proposals: []*ExternalProposal{
+		{RegionID: 1, Kind: ExternalTransferLeader, SourceStore: 1, TargetStore: 2},
+	}})
+	re.NoError(err)
+	re.Equal(ExternalProposalPending, results.Results[0].Status)
+	results, err = srv.GetResults(ctx, &ExternalResultsRequest{PluginName: "foo"})
+	re.NoError(err)
+	re.Len(results.Results, 1)
+}
+
+func TestExternalCodec(t *testing.T) {
+	re := require.New(t)
+	// the codecs of the other gRPC services are not replaced.
+	re.Nil(encoding.GetCodec("json"))
+	re.NotNil(encoding.GetCodec(ExternalCodecName))
+
+	var added []string
+	srv := NewExternalSchedulerServer(
+		func(string) (Scheduler, error) { return nil, errs.ErrSchedulerNotFound.FastGenByArgs() },
+		func(args ...string) error {
+			added = append(added, args...)
+			return nil
+		},
+	)
+	lis := bufconn.Listen(1024 * 1024)
+	g := grpc.NewServer()
+	RegisterExternalSchedulerServer(g, srv)
+	go g.Serve(lis)
+	defer g.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(),
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }))
+	re.NoError(err)
+	defer conn.Close()
+	resp := &ExternalRegisterResponse{}
+	err = conn.Invoke(ctx, "/"+ExternalSchedulerServiceName+"/Register", &ExternalRegisterRequest{PluginName: "foo"}, resp,
+		grpc.CallContentSubtype(ExternalCodecName))
+	re.NoError(err)
+	re.Equal("external-foo", resp.SchedulerName)
+	re.Equal([]string{"foo"}, added)
+}
+
+func operatorBudget(s *externalScheduler) uint64 {
+	budget, _ := s.conf.getBudgets()
+	return budget
+}
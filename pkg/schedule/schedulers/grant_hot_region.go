@@ -120,6 +120,17 @@ func (conf *grantHotRegionSchedulerConfig) has(storeID uint64) bool {
 	})
 }
 
+func (s *grantHotRegionScheduler) ReloadConfig(data []byte) error {
+	newConf := &grantHotRegionSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	if !s.conf.setStore(newConf.StoreLeaderID, newConf.StoreIDs) {
+		return errs.ErrSchedulerConfig.FastGenByArgs("store-leader-id, which should be one of store-id")
+	}
+	return nil
+}
+
 // grantLeaderScheduler transfers all hot peers to peers  and transfer leader to the fixed store
 type grantHotRegionScheduler struct {
 	*baseHotScheduler
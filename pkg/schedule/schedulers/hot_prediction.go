@@ -0,0 +1,354 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pingcap/kvproto/pkg/pdpb"
+	"github.com/pingcap/log"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/core/constant"
+	"github.com/tikv/pd/pkg/errs"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"github.com/tikv/pd/pkg/schedule/filter"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/plan"
+	"github.com/tikv/pd/pkg/statistics"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/utils/apiutil"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"github.com/tikv/pd/pkg/utils/typeutil"
+	"github.com/unrolled/render"
+	"go.uber.org/zap"
+)
+
+const (
+	// HotPredictionName is hot prediction scheduler name.
+	HotPredictionName = "hot-prediction-scheduler"
+	// HotPredictionType is hot prediction scheduler type.
+	HotPredictionType = "hot-prediction"
+
+	defaultPredictHorizon       = time.Minute
+	defaultPredictMinGrowthRate = 0.01
+	// defaultPredictSplitMinSize is the min approximate size in MiB of the
+	// predicted hot regions to be split.
+	defaultPredictSplitMinSize = 16
+)
+
+var (
+	// WithLabelValues is a heavy operation, define variable to avoid call it every time.
+	hotPredictionCounter              = schedulerCounter.WithLabelValues(HotPredictionName, "schedule")
+	hotPredictionNoRegionCounter      = schedulerCounter.WithLabelValues(HotPredictionName, "no-region")
+	hotPredictionOperatorExistCounter = schedulerCounter.WithLabelValues(HotPredictionName, "operator-exist")
+	hotPredictionNoTargetCounter      = schedulerCounter.WithLabelValues(HotPredictionName, "no-target-store")
+	hotPredictionCreateOpFailCounter  = schedulerCounter.WithLabelValues(HotPredictionName, "create-operator-fail")
+	hotPredictionSplitCounter         = schedulerCounter.WithLabelValues(HotPredictionName, "new-split-operator")
+	hotPredictionScatterCounter       = schedulerCounter.WithLabelValues(HotPredictionName, "new-scatter-operator")
+)
+
+type hotPredictionSchedulerConfig struct {
+	syncutil.RWMutex
+	storage endpoint.ConfigStorage
+
+	// Horizon is how long ahead the flow of the regions is predicted.
+	Horizon typeutil.Duration `json:"horizon"`
+	// MinGrowthRate is the min exponential growth rate per second of the flow
+	// of the regions to be predicted as hot.
+	MinGrowthRate float64 `json:"min-growth-rate"`
+	// SplitMinSize is the min approximate size in MiB of the predicted hot
+	// regions to be split, the smaller ones are scattered by their leaders.
+	SplitMinSize int64 `json:"split-min-size"`
+}
+
+func initHotPredictionConfig() *hotPredictionSchedulerConfig {
+	return &hotPredictionSchedulerConfig{
+		Horizon:       typeutil.NewDuration(defaultPredictHorizon),
+		MinGrowthRate: defaultPredictMinGrowthRate,
+		SplitMinSize:  defaultPredictSplitMinSize,
+	}
+}
+
+func (conf *hotPredictionSchedulerConfig) EncodeConfig() ([]byte, error) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return EncodeConfig(conf)
+}
+
+func (conf *hotPredictionSchedulerConfig) get() (horizon time.Duration, minGrowthRate float64, splitMinSize int64) {
+	conf.RLock()
+	defer conf.RUnlock()
+	return conf.Horizon.Duration, conf.MinGrowthRate, conf.SplitMinSize
+}
+
+func validateHotPredictionConfig(horizon time.Duration, minGrowthRate float64, splitMinSize int64) error {
+	if horizon <= 0 || minGrowthRate <= 0 || splitMinSize <= 0 {
+		return errs.ErrSchedulerConfig.FastGenByArgs("horizon, min-growth-rate and split-min-size, which should be positive")
+	}
+	return nil
+}
+
+func (conf *hotPredictionSchedulerConfig) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	conf.RLock()
+	defer conf.RUnlock()
+	rd.JSON(w, http.StatusOK, conf)
+}
+
+func (conf *hotPredictionSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	var input map[string]interface{}
+	if err := apiutil.ReadJSONRespondError(rd, w, r.Body, &input); err != nil {
+		return
+	}
+
+	conf.Lock()
+	defer conf.Unlock()
+	horizon, minGrowthRate, splitMinSize := conf.Horizon.Duration, conf.MinGrowthRate, conf.SplitMinSize
+	if v, ok := input["horizon"].(string); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			rd.Text(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		horizon = d
+	}
+	if v, ok := input["min-growth-rate"].(float64); ok {
+		minGrowthRate = v
+	}
+	if v, ok := input["split-min-size"].(float64); ok {
+		splitMinSize = int64(v)
+	}
+	if err := validateHotPredictionConfig(horizon, minGrowthRate, splitMinSize); err != nil {
+		rd.Text(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	oldHorizon, oldMinGrowthRate, oldSplitMinSize := conf.Horizon, conf.MinGrowthRate, conf.SplitMinSize
+	conf.Horizon, conf.MinGrowthRate, conf.SplitMinSize = typeutil.NewDuration(horizon), minGrowthRate, splitMinSize
+	if err := conf.persist(); err != nil {
+		conf.Horizon, conf.MinGrowthRate, conf.SplitMinSize = oldHorizon, oldMinGrowthRate, oldSplitMinSize // revert
+		rd.Text(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	rd.Text(w, http.StatusOK, "Config is updated.")
+}
+
+func (conf *hotPredictionSchedulerConfig) persist() error {
+	data, err := EncodeConfig(conf)
+	if err != nil {
+		return err
+	}
+	return conf.storage.SaveScheduleConfig(HotPredictionName, data)
+}
+
+// hotPredictionScheduler fits the short-term trends of the flow of the regions
+// in the hot peer cache, and prepares the regions which are predicted to be hot
+// before they are flagged as hot. The large ones are split, and the leaders of
+// the small ones are moved to the stores with the least flow.
+type hotPredictionScheduler struct {
+	*BaseScheduler
+	conf       *hotPredictionSchedulerConfig
+	predictors map[utils.RWType]*statistics.HotPredictor
+}
+
+// newHotPredictionScheduler creates a scheduler that prepares the regions
+// which are predicted to be hot.
+func newHotPredictionScheduler(opController *operator.Controller, conf *hotPredictionSchedulerConfig) Scheduler {
+	return &hotPredictionScheduler{
+		BaseScheduler: NewBaseScheduler(opController),
+		conf:          conf,
+		predictors: map[utils.RWType]*statistics.HotPredictor{
+			utils.Write: statistics.NewHotPredictor(utils.Write),
+			utils.Read:  statistics.NewHotPredictor(utils.Read),
+		},
+	}
+}
+
+func (s *hotPredictionScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	router := mux.NewRouter()
+	router.HandleFunc("/list", s.conf.handleGetConfig).Methods(http.MethodGet)
+	router.HandleFunc("/config", s.conf.handleSetConfig).Methods(http.MethodPost)
+	router.HandleFunc("/predicted-hot-regions", s.handleGetPredicted).Methods(http.MethodGet)
+	router.ServeHTTP(w, r)
+}
+
+func (s *hotPredictionScheduler) handleGetPredicted(w http.ResponseWriter, r *http.Request) {
+	rd := render.New(render.Options{IndentJSON: true})
+	rd.JSON(w, http.StatusOK, s.GetPredictedHotRegions())
+}
+
+// GetPredictedHotRegions returns the regions which are predicted to be hot
+// last time, the write ones first.
+func (s *hotPredictionScheduler) GetPredictedHotRegions() []*statistics.PredictedHotRegion {
+	predicted := make([]*statistics.PredictedHotRegion, 0)
+	for _, rw := range []utils.RWType{utils.Write, utils.Read} {
+		predicted = append(predicted, s.predictors[rw].GetPredicted()...)
+	}
+	return predicted
+}
+
+func (s *hotPredictionScheduler) GetName() string {
+	return HotPredictionName
+}
+
+func (s *hotPredictionScheduler) GetType() string {
+	return HotPredictionType
+}
+
+func (s *hotPredictionScheduler) EncodeConfig() ([]byte, error) {
+	return s.conf.EncodeConfig()
+}
+
+func (s *hotPredictionScheduler) ReloadConfig(data []byte) error {
+	newConf := &hotPredictionSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	if err := validateHotPredictionConfig(newConf.Horizon.Duration, newConf.MinGrowthRate, newConf.SplitMinSize); err != nil {
+		return err
+	}
+	s.conf.Lock()
+	defer s.conf.Unlock()
+	s.conf.Horizon, s.conf.MinGrowthRate, s.conf.SplitMinSize = newConf.Horizon, newConf.MinGrowthRate, newConf.SplitMinSize
+	return nil
+}
+
+func (s *hotPredictionScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
+	if s.OpController.OperatorCount(operator.OpSplit) >= defaultSplitLimit {
+		operator.OperatorLimitCounter.WithLabelValues(s.GetType(), operator.OpSplit.String()).Inc()
+		return false
+	}
+	if s.OpController.OperatorCount(operator.OpLeader) >= cluster.GetSchedulerConfig().GetLeaderScheduleLimit() {
+		operator.OperatorLimitCounter.WithLabelValues(s.GetType(), operator.OpLeader.String()).Inc()
+		return false
+	}
+	return true
+}
+
+func (s *hotPredictionScheduler) Schedule(cluster sche.SchedulerCluster, dryRun bool) ([]*operator.Operator, []plan.Plan) {
+	hotPredictionCounter.Inc()
+	horizon, minGrowthRate, splitMinSize := s.conf.get()
+	now := time.Now()
+	var op *operator.Operator
+	for _, rw := range []utils.RWType{utils.Write, utils.Read} {
+		// The regions are predicted even if an operator is created, so that
+		// the samples of the flow are taken continuously.
+		stats := cluster.RegionStats(rw, 0)
+		predictor := s.predictors[rw]
+		predictor.Observe(stats, now)
+		threshold := hotByteThreshold(rw, hotPeerStats(cluster, rw))
+		storeLoads := storeByteLoads(stats)
+		for _, predicted := range predictor.Predict(threshold, horizon, minGrowthRate, now) {
+			if op != nil {
+				break
+			}
+			op = s.prepare(cluster, predicted, storeLoads, splitMinSize)
+		}
+	}
+	if op == nil {
+		hotPredictionNoRegionCounter.Inc()
+		return nil, nil
+	}
+	return []*operator.Operator{op}, nil
+}
+
+// prepare splits the predicted hot region if it is large enough, otherwise
+// moves its leader to the follower store with the least flow.
+func (s *hotPredictionScheduler) prepare(cluster sche.SchedulerCluster, predicted *statistics.PredictedHotRegion,
+	storeLoads map[uint64]float64, splitMinSize int64) *operator.Operator {
+	region := cluster.GetRegion(predicted.RegionID)
+	if region == nil || region.GetLeader() == nil {
+		return nil
+	}
+	if s.OpController.GetOperator(region.GetID()) != nil {
+		hotPredictionOperatorExistCounter.Inc()
+		return nil
+	}
+	if region.GetApproximateSize() >= splitMinSize {
+		op, err := operator.CreateSplitRegionOperator(HotPredictionType, region, operator.OpSplit, pdpb.CheckPolicy_APPROXIMATE, nil)
+		if err != nil {
+			hotPredictionCreateOpFailCounter.Inc()
+			log.Debug("fail to create split operator", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+			return nil
+		}
+		op.Counters = append(op.Counters, hotPredictionSplitCounter)
+		op.AdditionalInfos["predicted-load"] = strconv.FormatFloat(predicted.PredictedLoad, 'f', 2, 64)
+		return op
+	}
+
+	source := region.GetLeader().GetStoreId()
+	targets := filter.NewCandidates(cluster.GetFollowerStores(region)).
+		FilterTarget(cluster.GetSchedulerConfig(), nil, nil,
+			&filter.StoreStateFilter{ActionScope: s.GetName(), TransferLeader: true, OperatorLevel: constant.Medium}).Stores
+	var target *core.StoreInfo
+	minLoad := math.Inf(1)
+	for _, store := range targets {
+		if load := storeLoads[store.GetID()]; load < minLoad && load < storeLoads[source] {
+			target, minLoad = store, load
+		}
+	}
+	if target == nil {
+		hotPredictionNoTargetCounter.Inc()
+		return nil
+	}
+	op, err := operator.CreateTransferLeaderOperator(HotPredictionType, cluster, region, source, target.GetID(), []uint64{}, operator.OpLeader)
+	if err != nil {
+		hotPredictionCreateOpFailCounter.Inc()
+		log.Debug("fail to create transfer leader operator", zap.Uint64("region-id", region.GetID()), errs.ZapError(err))
+		return nil
+	}
+	op.Counters = append(op.Counters, hotPredictionScatterCounter)
+	op.AdditionalInfos["predicted-load"] = strconv.FormatFloat(predicted.PredictedLoad, 'f', 2, 64)
+	return op
+}
+
+func hotPeerStats(cluster sche.SchedulerCluster, rw utils.RWType) map[uint64][]*statistics.HotPeerStat {
+	if rw == utils.Read {
+		return cluster.RegionReadStats()
+	}
+	return cluster.RegionWriteStats()
+}
+
+// hotByteThreshold returns the byte rate over which a region is regarded as
+// hot, which is the least byte rate of the hot peers, or the min hot threshold
+// if there is no hot peer.
+func hotByteThreshold(rw utils.RWType, hotPeers map[uint64][]*statistics.HotPeerStat) float64 {
+	minThreshold := utils.MinHotThresholds[rw.RegionStats()[utils.ByteDim]]
+	threshold := math.Inf(1)
+	for _, peers := range hotPeers {
+		for _, peer := range peers {
+			threshold = math.Min(threshold, peer.GetLoad(utils.ByteDim))
+		}
+	}
+	if math.IsInf(threshold, 1) {
+		return minThreshold
+	}
+	return math.Max(threshold, minThreshold)
+}
+
+func storeByteLoads(stats map[uint64][]*statistics.HotPeerStat) map[uint64]float64 {
+	loads := make(map[uint64]float64, len(stats))
+	for storeID, peers := range stats {
+		for _, peer := range peers {
+			loads[storeID] += peer.GetLoad(utils.ByteDim)
+		}
+	}
+	return loads
+}
@@ -0,0 +1,98 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/statistics"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
+)
+
+func TestHotPrediction(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	sb, err := CreateScheduler(HotPredictionType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(HotPredictionType, nil))
+	re.NoError(err)
+	re.Equal(HotPredictionName, sb.GetName())
+	s := sb.(*hotPredictionScheduler)
+	for id := uint64(1); id <= 3; id++ {
+		tc.AddLeaderStore(id, 0)
+	}
+	tc.AddLeaderRegion(1, 1, 2, 3)
+	re.True(sb.IsScheduleAllowed(tc))
+	ops, _ := sb.Schedule(tc, false)
+	re.Empty(ops)
+	re.Empty(s.GetPredictedHotRegions())
+
+	// the large region is split.
+	predicted := &statistics.PredictedHotRegion{RegionID: 1, StoreID: 1, PredictedLoad: 1024}
+	storeLoads := map[uint64]float64{1: 100, 2: 10, 3: 50}
+	op := s.prepare(tc, predicted, storeLoads, defaultPredictSplitMinSize)
+	re.NotNil(op)
+	re.Equal(HotPredictionType, op.Desc())
+	re.Equal(operator.OpSplit, op.Kind()&operator.OpSplit)
+
+	// the leader of the small region is moved to the store with the least flow.
+	tc.PutRegion(tc.GetRegion(1).Clone(core.SetApproximateSize(1)))
+	op = s.prepare(tc, predicted, storeLoads, defaultPredictSplitMinSize)
+	re.NotNil(op)
+	operatorutil.CheckTransferLeader(re, op, operator.OpLeader, 1, 2)
+	re.Equal("1024.00", op.AdditionalInfos["predicted-load"])
+	// no store has less flow than the leader store.
+	re.Nil(s.prepare(tc, predicted, map[uint64]float64{1: 10, 2: 100, 3: 50}, defaultPredictSplitMinSize))
+	// the region which has an operator is skipped.
+	re.True(oc.AddOperator(op))
+	re.Nil(s.prepare(tc, predicted, storeLoads, defaultPredictSplitMinSize))
+}
+
+func TestHotByteThreshold(t *testing.T) {
+	re := require.New(t)
+	minThreshold := utils.MinHotThresholds[utils.RegionWriteBytes]
+	re.Equal(minThreshold, hotByteThreshold(utils.Write, nil))
+	hotPeers := map[uint64][]*statistics.HotPeerStat{
+		1: {{RegionID: 1, Loads: []float64{4096, 0, 0}}, {RegionID: 2, Loads: []float64{8192, 0, 0}}},
+		2: {{RegionID: 1, Loads: []float64{2048, 0, 0}}},
+	}
+	re.Equal(2048.0, hotByteThreshold(utils.Write, hotPeers))
+	hotPeers[2][0].Loads[utils.ByteDim] = 1
+	re.Equal(minThreshold, hotByteThreshold(utils.Write, hotPeers))
+}
+
+func TestHotPredictionReloadConfig(t *testing.T) {
+	re := require.New(t)
+	cancel, _, _, oc := prepareSchedulersTest()
+	defer cancel()
+
+	sb, err := CreateScheduler(HotPredictionType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(HotPredictionType, nil))
+	re.NoError(err)
+	s := sb.(*hotPredictionScheduler)
+	re.NoError(s.ReloadConfig([]byte(`{"horizon":"2m","min-growth-rate":0.02,"split-min-size":32}`)))
+	horizon, minGrowthRate, splitMinSize := s.conf.get()
+	re.Equal(2*time.Minute, horizon)
+	re.Equal(0.02, minGrowthRate)
+	re.Equal(int64(32), splitMinSize)
+	re.Error(s.ReloadConfig([]byte(`{"horizon":"2m","min-growth-rate":-1,"split-min-size":32}`)))
+	_, minGrowthRate, _ = s.conf.get()
+	re.Equal(0.02, minGrowthRate)
+}
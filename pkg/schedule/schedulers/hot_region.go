@@ -257,6 +257,10 @@ func (h *hotScheduler) EncodeConfig() ([]byte, error) {
 	return h.conf.EncodeConfig()
 }
 
+func (h *hotScheduler) ReloadConfig(data []byte) error {
+	return h.conf.reload(data)
+}
+
 func (h *hotScheduler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.conf.ServeHTTP(w, r)
 }
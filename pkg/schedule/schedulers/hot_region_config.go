@@ -440,6 +440,25 @@ func (conf *hotRegionSchedulerConfig) handleSetConfig(w http.ResponseWriter, r *
 	rd.Text(w, http.StatusBadRequest, "Config item is not found.")
 }
 
+func (conf *hotRegionSchedulerConfig) reload(data []byte) error {
+	conf.Lock()
+	defer conf.Unlock()
+	oldc, err := json.Marshal(conf)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).FastGenWithCause()
+	}
+	if err := json.Unmarshal(data, conf); err != nil {
+		// the config may be partially decoded.
+		json.Unmarshal(oldc, conf)
+		return errs.ErrJSONUnmarshal.Wrap(err).FastGenWithCause()
+	}
+	if err := conf.valid(); err != nil {
+		json.Unmarshal(oldc, conf)
+		return err
+	}
+	return nil
+}
+
 func (conf *hotRegionSchedulerConfig) persistLocked() error {
 	data, err := EncodeConfig(conf)
 	if err != nil {
@@ -88,6 +88,37 @@ func schedulersRegister() {
 		return newBalanceRegionScheduler(opController, conf), nil
 	})
 
+	// balance disk usage
+	RegisterSliceDecoderBuilder(BalanceDiskUsageType, func(args []string) ConfigDecoder {
+		return func(v interface{}) error {
+			conf, ok := v.(*balanceDiskUsageSchedulerConfig)
+			if !ok {
+				return errs.ErrScheduleConfigNotExist.FastGenByArgs()
+			}
+			ranges, err := getKeyRanges(args)
+			if err != nil {
+				return err
+			}
+			conf.Ranges = ranges
+			return nil
+		}
+	})
+
+	RegisterScheduler(BalanceDiskUsageType, func(opController *operator.Controller, storage endpoint.ConfigStorage, decoder ConfigDecoder, removeSchedulerCb ...func(string) error) (Scheduler, error) {
+		conf := &balanceDiskUsageSchedulerConfig{
+			storage:       storage,
+			HighWatermark: defaultDiskUsageHighWatermark,
+			LowWatermark:  defaultDiskUsageLowWatermark,
+		}
+		if err := decoder(conf); err != nil {
+			return nil, err
+		}
+		if err := validateDiskUsageWatermarks(conf.HighWatermark, conf.LowWatermark); err != nil {
+			return nil, err
+		}
+		return newBalanceDiskUsageScheduler(opController, conf), nil
+	})
+
 	// balance witness
 	RegisterSliceDecoderBuilder(BalanceWitnessType, func(args []string) ConfigDecoder {
 		return func(v interface{}) error {
@@ -293,6 +324,42 @@ func schedulersRegister() {
 		return newLabelScheduler(opController, conf), nil
 	})
 
+	// hot prediction
+	RegisterSliceDecoderBuilder(HotPredictionType, func(args []string) ConfigDecoder {
+		return func(v interface{}) error {
+			return nil
+		}
+	})
+
+	RegisterScheduler(HotPredictionType, func(opController *operator.Controller, storage endpoint.ConfigStorage, decoder ConfigDecoder, removeSchedulerCb ...func(string) error) (Scheduler, error) {
+		conf := initHotPredictionConfig()
+		if err := decoder(conf); err != nil {
+			return nil, err
+		}
+		conf.storage = storage
+		return newHotPredictionScheduler(opController, conf), nil
+	})
+
+	// tiered storage
+	RegisterSliceDecoderBuilder(TieredStorageType, func(args []string) ConfigDecoder {
+		return func(v interface{}) error {
+			conf, ok := v.(*tieredStorageSchedulerConfig)
+			if !ok {
+				return errs.ErrScheduleConfigNotExist.FastGenByArgs()
+			}
+			conf.Name = TieredStorageName
+			return nil
+		}
+	})
+
+	RegisterScheduler(TieredStorageType, func(opController *operator.Controller, storage endpoint.ConfigStorage, decoder ConfigDecoder, removeSchedulerCb ...func(string) error) (Scheduler, error) {
+		conf := &tieredStorageSchedulerConfig{}
+		if err := decoder(conf); err != nil {
+			return nil, err
+		}
+		return newTieredStorageScheduler(opController, conf), nil
+	})
+
 	// random merge
 	RegisterSliceDecoderBuilder(RandomMergeType, func(args []string) ConfigDecoder {
 		return func(v interface{}) error {
@@ -472,4 +539,31 @@ func schedulersRegister() {
 		}
 		return newEvictSlowTrendScheduler(opController, conf), nil
 	})
+
+	// external
+	// args: [plugin-name, operator-budget, store-budget], the budgets are optional.
+	RegisterSliceDecoderBuilder(ExternalType, func(args []string) ConfigDecoder {
+		return func(v interface{}) error {
+			conf, ok := v.(*externalSchedulerConfig)
+			if !ok {
+				return errs.ErrScheduleConfigNotExist.FastGenByArgs()
+			}
+			return conf.BuildWithArgs(args)
+		}
+	})
+
+	RegisterScheduler(ExternalType, func(opController *operator.Controller, storage endpoint.ConfigStorage, decoder ConfigDecoder, removeSchedulerCb ...func(string) error) (Scheduler, error) {
+		conf := &externalSchedulerConfig{
+			storage:        storage,
+			OperatorBudget: defaultExternalOperatorBudget,
+			StoreBudget:    defaultExternalStoreBudget,
+		}
+		if err := decoder(conf); err != nil {
+			return nil, err
+		}
+		if len(conf.PluginName) == 0 {
+			return nil, errs.ErrSchedulerConfig.FastGenByArgs("plugin name")
+		}
+		return newExternalScheduler(opController, conf), nil
+	})
 }
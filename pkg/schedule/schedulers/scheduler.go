@@ -64,6 +64,15 @@ func DecodeConfig(data []byte, v interface{}) error {
 	return nil
 }
 
+// ConfigReloader is implemented by the schedulers whose config can be
+// updated in place, without removing the scheduler and losing its state.
+type ConfigReloader interface {
+	// ReloadConfig replaces the config of the scheduler with the whole config
+	// encoded by EncodeConfig. The config is not changed if the new one is
+	// invalid. The caller is responsible for persisting the config.
+	ReloadConfig(data []byte) error
+}
+
 // ConfigDecoder used to decode the config.
 type ConfigDecoder func(v interface{}) error
 
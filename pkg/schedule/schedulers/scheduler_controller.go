@@ -130,6 +130,7 @@ func (c *Controller) AddScheduler(scheduler Scheduler, args ...string) error {
 	if err := s.Scheduler.Prepare(c.cluster); err != nil {
 		return err
 	}
+	c.restorePause(s)
 
 	c.wg.Add(1)
 	go c.runScheduler(s)
@@ -161,6 +162,10 @@ func (c *Controller) RemoveScheduler(name string) error {
 		log.Error("can not remove the scheduler config", errs.ZapError(err))
 		return err
 	}
+	if err := c.storage.RemoveSchedulerPause(name); err != nil {
+		log.Error("can not remove the scheduler pause", errs.ZapError(err))
+		return err
+	}
 
 	s.Stop()
 	schedulerStatusGauge.DeleteLabelValues(name, "allow")
@@ -188,16 +193,109 @@ func (c *Controller) PauseOrResumeScheduler(name string, t int64) error {
 			s = append(s, sc)
 		}
 	}
-	var err error
+	var delayAt, delayUntil int64
+	if t > 0 {
+		delayAt = time.Now().Unix()
+		delayUntil = delayAt + t
+	}
+	// The pauses are persisted in a transaction before they take effect, so
+	// they survive the leader transfer and the schedulers resume when they
+	// expire, and no scheduler is paused if any of them fails.
+	pauses := make(map[string]*endpoint.SchedulerPause, len(s))
 	for _, sc := range s {
-		var delayAt, delayUntil int64
+		var pause *endpoint.SchedulerPause
 		if t > 0 {
-			delayAt = time.Now().Unix()
-			delayUntil = delayAt + t
+			pause = &endpoint.SchedulerPause{DelayAt: delayAt, DelayUntil: delayUntil}
 		}
+		pauses[sc.Scheduler.GetName()] = pause
+	}
+	if err := c.storage.SaveSchedulerPauses(pauses); err != nil {
+		log.Error("can not persist the scheduler pause", zap.String("scheduler-name", name), errs.ZapError(err))
+		return err
+	}
+	for _, sc := range s {
 		sc.SetDelay(delayAt, delayUntil)
 	}
-	return err
+	return nil
+}
+
+// restorePause restores the persisted pause of the scheduler, the expired
+// pause is removed.
+func (c *Controller) restorePause(s *ScheduleController) {
+	name := s.Scheduler.GetName()
+	pause, err := c.storage.LoadSchedulerPause(name)
+	if err != nil {
+		log.Warn("can not load the scheduler pause", zap.String("scheduler-name", name), errs.ZapError(err))
+		return
+	}
+	if pause == nil {
+		return
+	}
+	if pause.DelayUntil <= time.Now().Unix() {
+		if err := c.storage.RemoveSchedulerPause(name); err != nil {
+			log.Warn("can not remove the expired scheduler pause", zap.String("scheduler-name", name), errs.ZapError(err))
+		}
+		return
+	}
+	s.SetDelay(pause.DelayAt, pause.DelayUntil)
+	log.Info("scheduler pause is restored", zap.String("scheduler-name", name),
+		zap.Time("resume-at", time.Unix(pause.DelayUntil, 0)))
+}
+
+// UpdateSchedulerConfig replaces the config of a running scheduler with the
+// whole config and persists it. The scheduler keeps running with its state.
+func (c *Controller) UpdateSchedulerConfig(name string, data []byte) error {
+	c.RLock()
+	defer c.RUnlock()
+	s, err := c.getConfigReloaderLocked(name)
+	if err != nil {
+		return err
+	}
+	old, err := s.Scheduler.EncodeConfig()
+	if err != nil {
+		return err
+	}
+	if err := s.Scheduler.(ConfigReloader).ReloadConfig(data); err != nil {
+		return err
+	}
+	// The config is encoded again to persist the normalized one.
+	newData, err := s.Scheduler.EncodeConfig()
+	if err == nil {
+		err = c.storage.SaveScheduleConfig(name, newData)
+	}
+	if err != nil {
+		log.Error("can not persist the scheduler config", zap.String("scheduler-name", name), errs.ZapError(err))
+		if rerr := s.Scheduler.(ConfigReloader).ReloadConfig(old); rerr != nil {
+			log.Error("can not revert the scheduler config", zap.String("scheduler-name", name), errs.ZapError(rerr))
+		}
+		return err
+	}
+	log.Info("scheduler config is updated", zap.String("scheduler-name", name), zap.ByteString("config", newData))
+	return nil
+}
+
+// ReloadSchedulerConfig replaces the config of a running scheduler with the
+// whole config without persisting it, which is used when the config has been
+// persisted by others, e.g. the API server.
+func (c *Controller) ReloadSchedulerConfig(name string, data []byte) error {
+	c.RLock()
+	defer c.RUnlock()
+	s, err := c.getConfigReloaderLocked(name)
+	if err != nil {
+		return err
+	}
+	return s.Scheduler.(ConfigReloader).ReloadConfig(data)
+}
+
+func (c *Controller) getConfigReloaderLocked(name string) (*ScheduleController, error) {
+	s, ok := c.schedulers[name]
+	if !ok {
+		return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	if _, ok := s.Scheduler.(ConfigReloader); !ok {
+		return nil, errs.ErrSchedulerConfigNotReloadable.FastGenByArgs(name)
+	}
+	return s, nil
 }
 
 // IsSchedulerAllowed returns whether a scheduler is allowed to schedule, a scheduler is not allowed to schedule if it is paused or blocked by unsafe recovery.
@@ -22,6 +22,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/mock/mockcluster"
 	"github.com/tikv/pd/pkg/mock/mockconfig"
 	"github.com/tikv/pd/pkg/schedule/config"
@@ -493,3 +494,54 @@ func TestBalanceLeaderWithConflictRule(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateSchedulerConfig(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+	tc.AddLeaderStore(1, 0)
+	tc.AddLeaderStore(2, 0)
+	tc.AddLeaderStore(3, 0)
+
+	kvStorage := storage.NewStorageWithMemoryBackend()
+	controller := NewController(context.Background(), tc, kvStorage, oc)
+	sl, err := CreateScheduler(EvictLeaderType, oc, kvStorage, ConfigSliceDecoder(EvictLeaderType, []string{"1"}), func(string) error { return nil })
+	re.NoError(err)
+	re.NoError(controller.AddScheduler(sl))
+	defer controller.RemoveScheduler(sl.GetName())
+	re.False(tc.GetStore(1).AllowLeaderTransfer())
+
+	// The stores are replaced in place.
+	re.NoError(controller.UpdateSchedulerConfig(sl.GetName(), []byte(`{"store-id-ranges":{"2":[{"start-key":"","end-key":""}]}}`)))
+	re.Same(sl, controller.GetScheduler(sl.GetName()).Scheduler)
+	re.True(tc.GetStore(1).AllowLeaderTransfer())
+	re.False(tc.GetStore(2).AllowLeaderTransfer())
+	names, configs, err := kvStorage.LoadAllScheduleConfig()
+	re.NoError(err)
+	re.Equal([]string{sl.GetName()}, names)
+	re.Contains(configs[0], `"2"`)
+	re.NotContains(configs[0], `"1"`)
+
+	// The invalid config is rejected and the config is unchanged.
+	re.Error(controller.UpdateSchedulerConfig(sl.GetName(), []byte(`{"store-id-ranges":{}}`)))
+	re.Error(controller.UpdateSchedulerConfig(sl.GetName(), []byte(`{"store-id-ranges":`)))
+	re.False(tc.GetStore(2).AllowLeaderTransfer())
+	data, err := sl.EncodeConfig()
+	re.NoError(err)
+	re.Contains(string(data), `"2"`)
+
+	// The config is reloaded without being persisted.
+	re.NoError(controller.ReloadSchedulerConfig(sl.GetName(), []byte(`{"store-id-ranges":{"3":[{"start-key":"","end-key":""}]}}`)))
+	re.False(tc.GetStore(3).AllowLeaderTransfer())
+	_, configs, err = kvStorage.LoadAllScheduleConfig()
+	re.NoError(err)
+	re.Contains(configs[0], `"2"`)
+
+	// The scheduler must exist and support it.
+	re.True(errs.ErrSchedulerNotFound.Equal(controller.UpdateSchedulerConfig("not-exist", nil)))
+	shuffle, err := CreateScheduler(ShuffleLeaderType, oc, kvStorage, ConfigSliceDecoder(ShuffleLeaderType, []string{"", ""}))
+	re.NoError(err)
+	re.NoError(controller.AddScheduler(shuffle))
+	defer controller.RemoveScheduler(shuffle.GetName())
+	re.True(errs.ErrSchedulerConfigNotReloadable.Equal(controller.UpdateSchedulerConfig(shuffle.GetName(), []byte(`{}`))))
+}
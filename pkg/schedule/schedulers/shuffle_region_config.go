@@ -19,6 +19,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/schedule/placement"
 	"github.com/tikv/pd/pkg/slice"
 	"github.com/tikv/pd/pkg/storage/endpoint"
@@ -104,6 +105,22 @@ func (conf *shuffleRegionSchedulerConfig) handleSetRoles(w http.ResponseWriter,
 	rd.Text(w, http.StatusOK, "Config is updated.")
 }
 
+func (conf *shuffleRegionSchedulerConfig) reload(data []byte) error {
+	newConf := &shuffleRegionSchedulerConfig{}
+	if err := DecodeConfig(data, newConf); err != nil {
+		return err
+	}
+	for _, r := range newConf.Roles {
+		if slice.NoneOf(allRoles, func(i int) bool { return allRoles[i] == r }) {
+			return errs.ErrSchedulerConfig.FastGenByArgs("role " + r)
+		}
+	}
+	conf.Lock()
+	defer conf.Unlock()
+	conf.Ranges, conf.Roles = newConf.Ranges, newConf.Roles
+	return nil
+}
+
 func (conf *shuffleRegionSchedulerConfig) persist() error {
 	data, err := EncodeConfig(conf)
 	if err != nil {
@@ -0,0 +1,125 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"sort"
+
+	"github.com/tikv/pd/pkg/schedule/checker"
+	sche "github.com/tikv/pd/pkg/schedule/core"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/schedule/plan"
+	"github.com/tikv/pd/pkg/statistics"
+)
+
+const (
+	// TieredStorageName is tiered storage scheduler name.
+	TieredStorageName = "tiered-storage-scheduler"
+	// TieredStorageType is tiered storage scheduler type.
+	TieredStorageType = "tiered-storage"
+)
+
+var (
+	// WithLabelValues is a heavy operation, define variable to avoid call it every time.
+	tieredStorageCounter            = schedulerCounter.WithLabelValues(TieredStorageName, "schedule")
+	tieredStorageNoRegionCounter    = schedulerCounter.WithLabelValues(TieredStorageName, "no-region")
+	tieredStorageNewOperatorCounter = schedulerCounter.WithLabelValues(TieredStorageName, "new-operator")
+)
+
+type tieredStorageSchedulerConfig struct {
+	Name string `json:"name"`
+}
+
+// tieredStorageScheduler promotes the hot regions to the stores of the hot
+// storage tier. The cold regions are demoted by the tiered storage checker,
+// which tracks the coldness of the regions while patrolling them.
+type tieredStorageScheduler struct {
+	*BaseScheduler
+	conf *tieredStorageSchedulerConfig
+}
+
+// newTieredStorageScheduler creates a scheduler that promotes the hot regions
+// to the hot storage tier.
+func newTieredStorageScheduler(opController *operator.Controller, conf *tieredStorageSchedulerConfig) Scheduler {
+	return &tieredStorageScheduler{
+		BaseScheduler: NewBaseScheduler(opController),
+		conf:          conf,
+	}
+}
+
+func (s *tieredStorageScheduler) GetName() string {
+	return s.conf.Name
+}
+
+func (s *tieredStorageScheduler) GetType() string {
+	return TieredStorageType
+}
+
+func (s *tieredStorageScheduler) EncodeConfig() ([]byte, error) {
+	return EncodeConfig(s.conf)
+}
+
+func (s *tieredStorageScheduler) IsScheduleAllowed(cluster sche.SchedulerCluster) bool {
+	allowed := s.OpController.OperatorCount(operator.OpRegion) < cluster.GetSchedulerConfig().GetRegionScheduleLimit()
+	if !allowed {
+		operator.OperatorLimitCounter.WithLabelValues(s.GetType(), operator.OpRegion.String()).Inc()
+	}
+	return allowed
+}
+
+func (s *tieredStorageScheduler) Schedule(cluster sche.SchedulerCluster, dryRun bool) ([]*operator.Operator, []plan.Plan) {
+	tieredStorageCounter.Inc()
+	tier := cluster.GetSchedulerConfig().GetHotStorageTier()
+	tieredChecker := checker.NewTieredStorageChecker(cluster)
+	for _, regionID := range hotRegionsByDegree(cluster, cluster.GetSchedulerConfig().GetHotRegionCacheHitsThreshold()) {
+		region := cluster.GetRegion(regionID)
+		if region == nil || tieredChecker.InTier(region, tier) {
+			continue
+		}
+		if op := tieredChecker.MoveToTier(region, tier, operator.OpRegion); op != nil {
+			op.SetDesc(TieredStorageType)
+			op.Counters = append(op.Counters, tieredStorageNewOperatorCounter)
+			return []*operator.Operator{op}, nil
+		}
+	}
+	tieredStorageNoRegionCounter.Inc()
+	return nil, nil
+}
+
+// hotRegionsByDegree returns the IDs of the read and write hot regions whose
+// hot degree is at least minHotDegree, the hottest first.
+func hotRegionsByDegree(cluster sche.SchedulerCluster, minHotDegree int) []uint64 {
+	degrees := make(map[uint64]int)
+	for _, stats := range []map[uint64][]*statistics.HotPeerStat{cluster.RegionReadStats(), cluster.RegionWriteStats()} {
+		for _, peers := range stats {
+			for _, peer := range peers {
+				if peer.HotDegree >= minHotDegree && peer.HotDegree > degrees[peer.RegionID] {
+					degrees[peer.RegionID] = peer.HotDegree
+				}
+			}
+		}
+	}
+	ids := make([]uint64, 0, len(degrees))
+	for id := range degrees {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if degrees[ids[i]] != degrees[ids[j]] {
+			return degrees[ids[i]] > degrees[ids[j]]
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
@@ -0,0 +1,55 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package schedulers
+
+import (
+	"testing"
+
+	"github.com/docker/go-units"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/schedule/operator"
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/utils/operatorutil"
+)
+
+func TestTieredStorage(t *testing.T) {
+	re := require.New(t)
+	cancel, _, tc, oc := prepareSchedulersTest()
+	defer cancel()
+
+	sb, err := CreateScheduler(TieredStorageType, oc, storage.NewStorageWithMemoryBackend(), ConfigSliceDecoder(TieredStorageType, nil))
+	re.NoError(err)
+	re.Equal(TieredStorageName, sb.GetName())
+	tc.SetHotRegionCacheHitsThreshold(0)
+
+	tc.AddLabelsStore(1, 1, map[string]string{"tier": "ssd"})
+	for id := uint64(2); id <= 4; id++ {
+		tc.AddLabelsStore(id, 1, map[string]string{"tier": "hdd"})
+	}
+	// the cold region stays in the hdd stores.
+	tc.AddLeaderRegion(1, 2, 3, 4)
+	re.True(sb.IsScheduleAllowed(tc))
+	ops, _ := sb.Schedule(tc, false)
+	re.Empty(ops)
+
+	// the hot region is promoted to the ssd store.
+	tc.AddRegionWithReadInfo(1, 2, 512*units.KiB*utils.StoreHeartBeatReportInterval, 0, 0, utils.StoreHeartBeatReportInterval, []uint64{3, 4})
+	ops, _ = sb.Schedule(tc, false)
+	re.Len(ops, 1)
+	re.Equal(TieredStorageType, ops[0].Desc())
+	re.Equal("ssd", ops[0].AdditionalInfos["tier"])
+	operatorutil.CheckTransferPeerWithLeaderTransfer(re, ops[0], operator.OpRegion, 2, 1)
+}
@@ -53,6 +53,9 @@ type solver struct {
 
 	sourceScore float64
 	targetScore float64
+	// leaderScore scores the stores of the leader kind instead of the
+	// LeaderScore of the stores if it's set.
+	leaderScore func(store *core.StoreInfo, delta int64) float64
 }
 
 func newSolver(basePlan *plan.BalanceSchedulerPlan, kind constant.ScheduleKind, cluster sche.SchedulerCluster, opInfluence operator.OpInfluence) *solver {
@@ -69,6 +72,13 @@ func (p *solver) GetOpInfluence(storeID uint64) int64 {
 	return p.opInfluence.GetStoreInfluence(storeID).ResourceProperty(p.kind)
 }
 
+func (p *solver) getLeaderScore(store *core.StoreInfo, delta int64) float64 {
+	if p.leaderScore != nil {
+		return p.leaderScore(store, delta)
+	}
+	return store.LeaderScore(p.kind.Policy, delta)
+}
+
 func (p *solver) SourceStoreID() uint64 {
 	return p.Source.GetID()
 }
@@ -103,7 +113,7 @@ func (p *solver) sourceStoreScore(scheduleName string) float64 {
 	switch p.kind.Resource {
 	case constant.LeaderKind:
 		sourceDelta := influence - tolerantResource
-		score = p.Source.LeaderScore(p.kind.Policy, sourceDelta)
+		score = p.getLeaderScore(p.Source, sourceDelta)
 	case constant.RegionKind:
 		sourceDelta := influence*influenceAmp - tolerantResource
 		score = p.Source.RegionScore(p.GetSchedulerConfig().GetRegionScoreFormulaVersion(), p.GetSchedulerConfig().GetHighSpaceRatio(), p.GetSchedulerConfig().GetLowSpaceRatio(), sourceDelta)
@@ -133,7 +143,7 @@ func (p *solver) targetStoreScore(scheduleName string) float64 {
 	switch p.kind.Resource {
 	case constant.LeaderKind:
 		targetDelta := influence + tolerantResource
-		score = p.Target.LeaderScore(p.kind.Policy, targetDelta)
+		score = p.getLeaderScore(p.Target, targetDelta)
 	case constant.RegionKind:
 		targetDelta := influence*influenceAmp + tolerantResource
 		score = p.Target.RegionScore(p.GetSchedulerConfig().GetRegionScoreFormulaVersion(), p.GetSchedulerConfig().GetHighSpaceRatio(), p.GetSchedulerConfig().GetLowSpaceRatio(), targetDelta)
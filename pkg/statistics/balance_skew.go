@@ -0,0 +1,146 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"math"
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+	"github.com/tikv/pd/server/config"
+)
+
+// BalanceSkew is a snapshot of how balanced the cluster is. Every dimension
+// is the standard deviation of the value across all up stores.
+type BalanceSkew struct {
+	// Timestamp is the unix timestamp in seconds when the snapshot is taken.
+	Timestamp   int64   `json:"timestamp"`
+	StoreCount  int     `json:"store_count"`
+	RegionCount float64 `json:"region_count"`
+	RegionSize  float64 `json:"region_size"`
+	LeaderCount float64 `json:"leader_count"`
+	LeaderSize  float64 `json:"leader_size"`
+	RegionScore float64 `json:"region_score"`
+	LeaderScore float64 `json:"leader_score"`
+}
+
+// BalanceSkewHistory records the balance skew snapshots of the cluster in a
+// bounded time series. The snapshots older than the configured retention are
+// dropped. It is thread safe.
+type BalanceSkewHistory struct {
+	syncutil.RWMutex
+	opt     *config.PersistOptions
+	records []*BalanceSkew
+}
+
+// NewBalanceSkewHistory creates a new BalanceSkewHistory.
+func NewBalanceSkewHistory(opt *config.PersistOptions) *BalanceSkewHistory {
+	return &BalanceSkewHistory{opt: opt}
+}
+
+// Observe takes a snapshot of the balance skew of the given stores.
+func (h *BalanceSkewHistory) Observe(stores []*core.StoreInfo) *BalanceSkew {
+	return h.observe(stores, time.Now())
+}
+
+func (h *BalanceSkewHistory) observe(stores []*core.StoreInfo, now time.Time) *BalanceSkew {
+	var regionCount, regionSize, leaderCount, leaderSize, regionScore, leaderScore []float64
+	for _, store := range stores {
+		if !store.IsUp() {
+			continue
+		}
+		regionCount = append(regionCount, float64(store.GetRegionCount()))
+		regionSize = append(regionSize, float64(store.GetRegionSize()))
+		leaderCount = append(leaderCount, float64(store.GetLeaderCount()))
+		leaderSize = append(leaderSize, float64(store.GetLeaderSize()))
+		regionScore = append(regionScore, store.RegionScore(h.opt.GetRegionScoreFormulaVersion(), h.opt.GetHighSpaceRatio(), h.opt.GetLowSpaceRatio(), 0))
+		leaderScore = append(leaderScore, store.LeaderScore(h.opt.GetLeaderSchedulePolicy(), 0))
+	}
+	skew := &BalanceSkew{
+		Timestamp:   now.Unix(),
+		StoreCount:  len(regionCount),
+		RegionCount: stdDev(regionCount),
+		RegionSize:  stdDev(regionSize),
+		LeaderCount: stdDev(leaderCount),
+		LeaderSize:  stdDev(leaderSize),
+		RegionScore: stdDev(regionScore),
+		LeaderScore: stdDev(leaderScore),
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	h.records = append(h.records, skew)
+	expired := now.Add(-h.opt.GetBalanceSkewHistoryRetention()).Unix()
+	i := 0
+	for i < len(h.records) && h.records[i].Timestamp < expired {
+		i++
+	}
+	h.records = h.records[i:]
+	return skew
+}
+
+// GetHistory returns the snapshots taken in [start, end], which are sorted by
+// time. A zero end means no upper bound.
+func (h *BalanceSkewHistory) GetHistory(start, end int64) []*BalanceSkew {
+	h.RLock()
+	defer h.RUnlock()
+	res := make([]*BalanceSkew, 0, len(h.records))
+	for _, r := range h.records {
+		if r.Timestamp < start || (end > 0 && r.Timestamp > end) {
+			continue
+		}
+		skew := *r
+		res = append(res, &skew)
+	}
+	return res
+}
+
+// Collect sets the metrics with the latest snapshot.
+func (h *BalanceSkewHistory) Collect() {
+	h.RLock()
+	defer h.RUnlock()
+	if len(h.records) == 0 {
+		return
+	}
+	latest := h.records[len(h.records)-1]
+	balanceSkewGauge.WithLabelValues("region_count").Set(latest.RegionCount)
+	balanceSkewGauge.WithLabelValues("region_size").Set(latest.RegionSize)
+	balanceSkewGauge.WithLabelValues("leader_count").Set(latest.LeaderCount)
+	balanceSkewGauge.WithLabelValues("leader_size").Set(latest.LeaderSize)
+	balanceSkewGauge.WithLabelValues("region_score").Set(latest.RegionScore)
+	balanceSkewGauge.WithLabelValues("leader_score").Set(latest.LeaderScore)
+}
+
+// Reset resets the metrics.
+func (h *BalanceSkewHistory) Reset() {
+	balanceSkewGauge.Reset()
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
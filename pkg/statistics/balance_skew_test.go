@@ -0,0 +1,79 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/mock/mockconfig"
+	"github.com/tikv/pd/pkg/utils/typeutil"
+)
+
+func TestBalanceSkewHistory(t *testing.T) {
+	re := require.New(t)
+	opt := mockconfig.NewTestOptions()
+	cfg := opt.GetScheduleConfig().Clone()
+	cfg.BalanceSkewHistoryRetention = typeutil.NewDuration(time.Hour)
+	opt.SetScheduleConfig(cfg)
+	history := NewBalanceSkewHistory(opt)
+
+	newStores := func(regionCounts ...int) []*core.StoreInfo {
+		stores := make([]*core.StoreInfo, 0, len(regionCounts))
+		for i, count := range regionCounts {
+			stores = append(stores, core.NewStoreInfo(&metapb.Store{Id: uint64(i + 1)},
+				core.SetRegionCount(count),
+				core.SetLeaderCount(count/3),
+				core.SetRegionSize(int64(count)*10),
+			))
+		}
+		return stores
+	}
+
+	now := time.Now()
+	// balanced
+	skew := history.observe(newStores(300, 300, 300), now)
+	re.Equal(3, skew.StoreCount)
+	re.Zero(skew.RegionCount)
+	re.Zero(skew.RegionSize)
+	re.Zero(skew.LeaderCount)
+	// a new empty store joins
+	skew = history.observe(newStores(300, 300, 300, 0), now.Add(time.Minute))
+	re.Equal(4, skew.StoreCount)
+	re.InDelta(129.9, skew.RegionCount, 0.1)
+	re.InDelta(1299.0, skew.RegionSize, 1)
+	re.InDelta(43.3, skew.LeaderCount, 0.1)
+	// rebalanced
+	skew = history.observe(newStores(225, 225, 225, 225), now.Add(2*time.Minute))
+	re.Zero(skew.RegionCount)
+
+	records := history.GetHistory(0, 0)
+	re.Len(records, 3)
+	re.Zero(records[0].RegionCount)
+	re.Greater(records[1].RegionCount, records[0].RegionCount)
+	re.Less(records[2].RegionCount, records[1].RegionCount)
+	records = history.GetHistory(now.Add(time.Minute).Unix(), now.Add(time.Minute).Unix())
+	re.Len(records, 1)
+	re.Equal(4, records[0].StoreCount)
+
+	// the snapshots out of retention are dropped.
+	history.observe(newStores(225, 225, 225, 225), now.Add(time.Hour+time.Minute))
+	records = history.GetHistory(0, 0)
+	re.Len(records, 3)
+	re.Equal(now.Add(time.Minute).Unix(), records[0].Timestamp)
+}
@@ -103,6 +103,22 @@ func (w *HotCache) IsRegionHot(region *core.RegionInfo, minHotDegree int) bool {
 	return false
 }
 
+// HotRegionIDs returns the IDs of the hot regions according to kind, a region
+// is hot if any of its peers is hot.
+func (w *HotCache) HotRegionIDs(kind utils.RWType, minHotDegree int) []uint64 {
+	var ids []uint64
+	visited := make(map[uint64]struct{})
+	for _, stats := range w.RegionStats(kind, minHotDegree) {
+		for _, stat := range stats {
+			if _, ok := visited[stat.RegionID]; !ok {
+				visited[stat.RegionID] = struct{}{}
+				ids = append(ids, stat.RegionID)
+			}
+		}
+	}
+	return ids
+}
+
 // GetHotPeerStat returns hot peer stat with specified regionID and storeID.
 func (w *HotCache) GetHotPeerStat(kind utils.RWType, regionID, storeID uint64) *HotPeerStat {
 	task := newGetHotPeerStatTask(regionID, storeID)
@@ -0,0 +1,194 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/tikv/pd/pkg/statistics/utils"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+)
+
+const (
+	// minPredictSampleInterval is the min interval between two samples of a
+	// region, the flow of the regions is reported every heartbeat interval.
+	minPredictSampleInterval = 10 * time.Second
+	// maxPredictSamples is the max number of the samples of a region which
+	// the trend is fitted over.
+	maxPredictSamples = 12
+	// minPredictSamples is the min number of the samples of a region to fit
+	// the trend.
+	minPredictSamples = 3
+)
+
+// PredictedHotRegion is a region whose flow is predicted to make it hot.
+type PredictedHotRegion struct {
+	RegionID uint64 `json:"region_id"`
+	// StoreID is the store of the peer with the most flow.
+	StoreID uint64 `json:"store_id"`
+	RWType  string `json:"rw_type"`
+	// CurrentLoad and PredictedLoad are the byte rate of the region.
+	CurrentLoad   float64 `json:"current_load"`
+	PredictedLoad float64 `json:"predicted_load"`
+	// Threshold is the byte rate over which the region is regarded as hot.
+	Threshold float64 `json:"threshold"`
+	// GrowthRate is the exponential growth rate of the flow per second.
+	GrowthRate  float64   `json:"growth_rate"`
+	PredictTime time.Time `json:"predict_time"`
+}
+
+type flowSample struct {
+	time time.Time
+	load float64
+}
+
+type regionFlowSamples struct {
+	storeID uint64
+	samples []flowSample
+}
+
+// HotPredictor fits the short-term trends of the byte rate of the regions in
+// the hot peer cache, and predicts the regions which are going to be hot
+// before they are flagged as hot by the hot peer cache. The trend is fitted
+// as the exponential growth, which is the linear regression over the
+// logarithm of the byte rate.
+type HotPredictor struct {
+	syncutil.RWMutex
+	rwType    utils.RWType
+	samples   map[uint64]*regionFlowSamples
+	predicted []*PredictedHotRegion
+}
+
+// NewHotPredictor creates a HotPredictor of the read or write flow.
+func NewHotPredictor(rwType utils.RWType) *HotPredictor {
+	return &HotPredictor{
+		rwType:  rwType,
+		samples: make(map[uint64]*regionFlowSamples),
+	}
+}
+
+// Observe samples the byte rate of the regions in the hot peer cache, the
+// regions which are not in the cache anymore are removed.
+func (p *HotPredictor) Observe(stats map[uint64][]*HotPeerStat, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	loads := make(map[uint64]*regionFlowSamples)
+	for _, peers := range stats {
+		for _, peer := range peers {
+			load := peer.GetLoad(utils.ByteDim)
+			if cur, ok := loads[peer.RegionID]; !ok || load > cur.samples[0].load {
+				loads[peer.RegionID] = &regionFlowSamples{storeID: peer.StoreID, samples: []flowSample{{now, load}}}
+			}
+		}
+	}
+	for regionID := range p.samples {
+		if _, ok := loads[regionID]; !ok {
+			delete(p.samples, regionID)
+		}
+	}
+	for regionID, cur := range loads {
+		region, ok := p.samples[regionID]
+		if !ok {
+			p.samples[regionID] = cur
+			continue
+		}
+		region.storeID = cur.storeID
+		if now.Sub(region.samples[len(region.samples)-1].time) < minPredictSampleInterval {
+			continue
+		}
+		region.samples = append(region.samples, cur.samples[0])
+		if len(region.samples) > maxPredictSamples {
+			region.samples = region.samples[len(region.samples)-maxPredictSamples:]
+		}
+	}
+}
+
+// Predict predicts the regions whose byte rate is going to reach the threshold
+// in the horizon while it is below the threshold now, and the growth rate is
+// at least minGrowthRate. The regions which grow fastest come first.
+func (p *HotPredictor) Predict(threshold float64, horizon time.Duration, minGrowthRate float64, now time.Time) []*PredictedHotRegion {
+	p.Lock()
+	defer p.Unlock()
+	var predicted []*PredictedHotRegion
+	for regionID, region := range p.samples {
+		if len(region.samples) < minPredictSamples {
+			continue
+		}
+		current := region.samples[len(region.samples)-1].load
+		if current <= 0 || current >= threshold {
+			continue
+		}
+		rate, ok := fitGrowthRate(region.samples)
+		if !ok || rate < minGrowthRate {
+			continue
+		}
+		load := current * math.Exp(rate*horizon.Seconds())
+		if load < threshold {
+			continue
+		}
+		predicted = append(predicted, &PredictedHotRegion{
+			RegionID:      regionID,
+			StoreID:       region.storeID,
+			RWType:        p.rwType.String(),
+			CurrentLoad:   current,
+			PredictedLoad: load,
+			Threshold:     threshold,
+			GrowthRate:    rate,
+			PredictTime:   now,
+		})
+	}
+	sort.Slice(predicted, func(i, j int) bool {
+		if predicted[i].GrowthRate != predicted[j].GrowthRate {
+			return predicted[i].GrowthRate > predicted[j].GrowthRate
+		}
+		return predicted[i].RegionID < predicted[j].RegionID
+	})
+	p.predicted = predicted
+	return predicted
+}
+
+// GetPredicted returns the regions predicted last time.
+func (p *HotPredictor) GetPredicted() []*PredictedHotRegion {
+	p.RLock()
+	defer p.RUnlock()
+	return append([]*PredictedHotRegion(nil), p.predicted...)
+}
+
+// fitGrowthRate fits the exponential growth rate per second of the samples
+// by the least squares over the logarithm of the loads. It returns false if
+// any load is not positive or all the samples are at the same time.
+func fitGrowthRate(samples []flowSample) (float64, bool) {
+	n := float64(len(samples))
+	start := samples[0].time
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range samples {
+		if sample.load <= 0 {
+			return 0, false
+		}
+		x := sample.time.Sub(start).Seconds()
+		y := math.Log(sample.load)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
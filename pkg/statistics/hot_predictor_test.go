@@ -0,0 +1,69 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/statistics/utils"
+)
+
+func TestHotPredictor(t *testing.T) {
+	re := require.New(t)
+	predictor := NewHotPredictor(utils.Write)
+	peer := func(regionID, storeID uint64, load float64) *HotPeerStat {
+		return &HotPeerStat{RegionID: regionID, StoreID: storeID, Loads: []float64{load, 0, 0}}
+	}
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		elapsed := time.Duration(i) * 10 * time.Second
+		predictor.Observe(map[uint64][]*HotPeerStat{
+			1: {
+				// region 1 grows exponentially.
+				peer(1, 1, 100*math.Exp(0.05*elapsed.Seconds())),
+				// region 2 is steady.
+				peer(2, 1, 500),
+				// region 3 is hot already.
+				peer(3, 1, 2000*math.Exp(0.05*elapsed.Seconds())),
+			},
+			// the peer with the most flow is picked.
+			2: {peer(1, 2, 10)},
+		}, start.Add(elapsed))
+		// the samples too close to the last one are skipped.
+		predictor.Observe(map[uint64][]*HotPeerStat{1: {peer(1, 1, 1), peer(2, 1, 500), peer(3, 1, 2000)}, 2: {peer(1, 2, 10)}},
+			start.Add(elapsed+time.Second))
+	}
+
+	now := start.Add(30 * time.Second)
+	predicted := predictor.Predict(1000, time.Minute, 0.01, now)
+	re.Len(predicted, 1)
+	re.Equal(uint64(1), predicted[0].RegionID)
+	re.Equal(uint64(1), predicted[0].StoreID)
+	re.Equal("write", predicted[0].RWType)
+	re.InDelta(0.05, predicted[0].GrowthRate, 1e-3)
+	re.Greater(predicted[0].PredictedLoad, 1000.0)
+	re.Equal(predicted, predictor.GetPredicted())
+	// not hot in the horizon.
+	re.Empty(predictor.Predict(1e6, time.Minute, 0.01, now))
+	// not growing fast enough.
+	re.Empty(predictor.Predict(1000, time.Minute, 0.1, now))
+
+	// the regions which are not in the cache anymore are removed.
+	predictor.Observe(map[uint64][]*HotPeerStat{1: {peer(2, 1, 500)}}, now.Add(10*time.Second))
+	re.Empty(predictor.Predict(1000, time.Minute, 0.01, now))
+}
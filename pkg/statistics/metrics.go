@@ -170,6 +170,14 @@ var (
 			Name:      "hot_peers_summary",
 			Help:      "Hot peers summary for each store",
 		}, []string{"type", "store"})
+
+	balanceSkewGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "cluster",
+			Name:      "balance_skew",
+			Help:      "The standard deviation of each balance dimension across stores.",
+		}, []string{"dimension"})
 )
 
 var (
@@ -196,4 +204,5 @@ func init() {
 	prometheus.MustRegister(regionAbnormalPeerDuration)
 	prometheus.MustRegister(hotCacheFlowQueueStatusGauge)
 	prometheus.MustRegister(hotPeerSummary)
+	prometheus.MustRegister(balanceSkewGauge)
 }
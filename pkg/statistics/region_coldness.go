@@ -0,0 +1,101 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"time"
+
+	"github.com/tikv/pd/pkg/core"
+	"github.com/tikv/pd/pkg/utils/syncutil"
+)
+
+// ColdnessTracker records the last time each region is accessed, which is
+// observed from the read and write flow reported by the region heartbeats.
+// A region that has not been observed to be accessed is regarded as accessed
+// at the time it is observed first, so the regions are never cold before they
+// are tracked for a while.
+type ColdnessTracker struct {
+	syncutil.RWMutex
+	lastAccess map[uint64]time.Time
+}
+
+// NewColdnessTracker creates a new ColdnessTracker.
+func NewColdnessTracker() *ColdnessTracker {
+	return &ColdnessTracker{
+		lastAccess: make(map[uint64]time.Time),
+	}
+}
+
+// Observe updates the last access time of the region with its flow.
+func (t *ColdnessTracker) Observe(region *core.RegionInfo, now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	_, ok := t.lastAccess[region.GetID()]
+	if !ok || isRegionAccessed(region) {
+		t.lastAccess[region.GetID()] = now
+	}
+}
+
+// GetIdleDuration returns how long the region has not been accessed, and
+// false if the region is not tracked.
+func (t *ColdnessTracker) GetIdleDuration(regionID uint64, now time.Time) (time.Duration, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	last, ok := t.lastAccess[regionID]
+	if !ok {
+		return 0, false
+	}
+	return now.Sub(last), true
+}
+
+// IsCold returns whether the region has not been accessed for the duration.
+func (t *ColdnessTracker) IsCold(regionID uint64, now time.Time, coldDuration time.Duration) bool {
+	idle, ok := t.GetIdleDuration(regionID, now)
+	return ok && idle >= coldDuration
+}
+
+// Remove removes the region from the tracker.
+func (t *ColdnessTracker) Remove(regionID uint64) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.lastAccess, regionID)
+}
+
+// GC removes the regions which do not exist anymore, and returns the number
+// of the removed regions.
+func (t *ColdnessTracker) GC(exist func(regionID uint64) bool) int {
+	t.Lock()
+	defer t.Unlock()
+	removed := 0
+	for id := range t.lastAccess {
+		if !exist(id) {
+			delete(t.lastAccess, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// Len returns the number of the tracked regions.
+func (t *ColdnessTracker) Len() int {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.lastAccess)
+}
+
+func isRegionAccessed(region *core.RegionInfo) bool {
+	return region.GetBytesRead() > 0 || region.GetKeysRead() > 0 || region.GetReadQueryNum() > 0 ||
+		region.GetBytesWritten() > 0 || region.GetKeysWritten() > 0 || region.GetWriteQueryNum() > 0
+}
@@ -0,0 +1,57 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package statistics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pingcap/kvproto/pkg/metapb"
+	"github.com/stretchr/testify/require"
+	"github.com/tikv/pd/pkg/core"
+)
+
+func TestColdnessTracker(t *testing.T) {
+	re := require.New(t)
+	tracker := NewColdnessTracker()
+	idle := core.NewRegionInfo(&metapb.Region{Id: 1}, nil)
+	accessed := idle.Clone(core.SetReadBytes(1024))
+	start := time.Now()
+
+	// the region is not cold before it is tracked.
+	re.False(tracker.IsCold(1, start, time.Minute))
+	tracker.Observe(idle, start)
+	_, ok := tracker.GetIdleDuration(1, start)
+	re.True(ok)
+	re.False(tracker.IsCold(1, start.Add(30*time.Second), time.Minute))
+
+	// observing an idle region doesn't refresh the access time.
+	tracker.Observe(idle, start.Add(30*time.Second))
+	re.True(tracker.IsCold(1, start.Add(time.Minute), time.Minute))
+
+	// the access makes the region hot again.
+	tracker.Observe(accessed, start.Add(time.Minute))
+	re.False(tracker.IsCold(1, start.Add(90*time.Second), time.Minute))
+	idleDuration, ok := tracker.GetIdleDuration(1, start.Add(90*time.Second))
+	re.True(ok)
+	re.Equal(30*time.Second, idleDuration)
+
+	tracker.Observe(core.NewRegionInfo(&metapb.Region{Id: 2}, nil), start)
+	re.Equal(2, tracker.Len())
+	re.Equal(1, tracker.GC(func(regionID uint64) bool { return regionID == 1 }))
+	re.Equal(1, tracker.Len())
+	tracker.Remove(1)
+	re.Equal(0, tracker.Len())
+}
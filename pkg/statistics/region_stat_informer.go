@@ -29,4 +29,7 @@ type RegionStatInformer interface {
 	// RegionReadStats return the storeID -> read stat of peers on this store.
 	// The result only includes peers that are hot enough.
 	RegionReadStats() map[uint64][]*HotPeerStat
+	// RegionStats return the storeID -> stat of peers on this store, which have
+	// been hot for at least minHotDegree times.
+	RegionStats(rw utils.RWType, minHotDegree int) map[uint64][]*HotPeerStat
 }
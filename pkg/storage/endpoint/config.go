@@ -15,10 +15,12 @@
 package endpoint
 
 import (
+	"context"
 	"encoding/json"
 	"strings"
 
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"go.etcd.io/etcd/clientv3"
 )
 
@@ -29,6 +31,16 @@ type ConfigStorage interface {
 	LoadAllScheduleConfig() ([]string, []string, error)
 	SaveScheduleConfig(scheduleName string, data []byte) error
 	RemoveScheduleConfig(scheduleName string) error
+	LoadSchedulerPause(scheduleName string) (*SchedulerPause, error)
+	SaveSchedulerPause(scheduleName string, pause *SchedulerPause) error
+	RemoveSchedulerPause(scheduleName string) error
+	SaveSchedulerPauses(pauses map[string]*SchedulerPause) error
+}
+
+// SchedulerPause is the pause of a scheduler, the timestamps are in seconds.
+type SchedulerPause struct {
+	DelayAt    int64 `json:"delay_at"`
+	DelayUntil int64 `json:"delay_until"`
 }
 
 var _ ConfigStorage = (*StorageEndpoint)(nil)
@@ -74,3 +86,46 @@ func (se *StorageEndpoint) SaveScheduleConfig(scheduleName string, data []byte)
 func (se *StorageEndpoint) RemoveScheduleConfig(scheduleName string) error {
 	return se.Remove(scheduleConfigPath(scheduleName))
 }
+
+// LoadSchedulerPause loads the pause of scheduler, it returns nil if the
+// scheduler is not paused.
+func (se *StorageEndpoint) LoadSchedulerPause(scheduleName string) (*SchedulerPause, error) {
+	value, err := se.Load(schedulerPausePath(scheduleName))
+	if err != nil || value == "" {
+		return nil, err
+	}
+	pause := &SchedulerPause{}
+	if err := json.Unmarshal([]byte(value), pause); err != nil {
+		return nil, errs.ErrJSONUnmarshal.Wrap(err).GenWithStackByCause()
+	}
+	return pause, nil
+}
+
+// SaveSchedulerPause saves the pause of scheduler.
+func (se *StorageEndpoint) SaveSchedulerPause(scheduleName string, pause *SchedulerPause) error {
+	return se.saveJSON(schedulerPausePath(scheduleName), pause)
+}
+
+// RemoveSchedulerPause removes the pause of scheduler.
+func (se *StorageEndpoint) RemoveSchedulerPause(scheduleName string) error {
+	return se.Remove(schedulerPausePath(scheduleName))
+}
+
+// SaveSchedulerPauses saves the pauses of the schedulers in a transaction, the
+// pause of a scheduler is removed if it's nil.
+func (se *StorageEndpoint) SaveSchedulerPauses(pauses map[string]*SchedulerPause) error {
+	return se.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for name, pause := range pauses {
+			var err error
+			if pause == nil {
+				err = txn.Remove(schedulerPausePath(name))
+			} else {
+				err = saveJSONInTxn(txn, schedulerPausePath(name), pause)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
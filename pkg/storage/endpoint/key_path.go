@@ -33,9 +33,18 @@ const (
 	gcPath                   = "gc"
 	rulesPath                = "rules"
 	ruleGroupPath            = "rule_group"
+	ruleTemplatePath         = "rule_template"
+	ruleTombstonePath        = "rule_tombstone"
 	regionLabelPath          = "region_label"
+	ruleSnapshotPath         = "rule_snapshot"
+	ruleSnapshotMetaPath     = "rule_snapshot_meta"
+	ruleSetPath              = "rule_set"
+	ruleSetStatePath         = "rule_set_state"
+	ruleHistoryPath          = "rule_history"
+	ruleRevisionPath         = "rule_revision"
 	replicationPath          = "replication_mode"
 	customScheduleConfigPath = "scheduler_config"
+	schedulerPauseRootPath   = "scheduler_pause"
 	// GCWorkerServiceSafePointID is the service id of GC worker.
 	GCWorkerServiceSafePointID = "gc_worker"
 	minResolvedTS              = "min_resolved_ts"
@@ -116,6 +125,10 @@ func scheduleConfigPath(scheduleName string) string {
 	return path.Join(customScheduleConfigPath, scheduleName)
 }
 
+func schedulerPausePath(scheduleName string) string {
+	return path.Join(schedulerPauseRootPath, scheduleName)
+}
+
 // StorePath returns the store meta info key path with the given store ID.
 func StorePath(storeID uint64) string {
 	return path.Join(clusterPath, "s", fmt.Sprintf("%020d", storeID))
@@ -181,10 +194,36 @@ func ruleGroupIDPath(groupID string) string {
 	return path.Join(ruleGroupPath, groupID)
 }
 
+func ruleTemplateIDPath(templateID string) string {
+	return path.Join(ruleTemplatePath, templateID)
+}
+
+func ruleTombstoneKeyPath(ruleKey string) string {
+	return path.Join(ruleTombstonePath, ruleKey)
+}
+
 func regionLabelKeyPath(ruleKey string) string {
 	return path.Join(regionLabelPath, ruleKey)
 }
 
+func ruleSnapshotKeyPath(name string) string {
+	return path.Join(ruleSnapshotPath, name)
+}
+
+func ruleSnapshotMetaKeyPath(name string) string {
+	return path.Join(ruleSnapshotMetaPath, name)
+}
+
+func ruleSetKeyPath(name string) string {
+	return path.Join(ruleSetPath, name)
+}
+
+// ruleHistoryKeyPath returns the path of the rule history entry, the revision
+// is padded so the entries are listed in the order of the revisions.
+func ruleHistoryKeyPath(revision uint64) string {
+	return path.Join(ruleHistoryPath, fmt.Sprintf("%020d", revision))
+}
+
 func replicationModePath(mode string) string {
 	return path.Join(replicationPath, mode)
 }
@@ -15,34 +15,95 @@
 package endpoint
 
 import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/pingcap/errors"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"go.etcd.io/etcd/clientv3"
 )
 
 // RuleStorage defines the storage operations on the rule.
 type RuleStorage interface {
 	LoadRules(f func(k, v string)) error
-	SaveRule(ruleKey string, rule interface{}) error
-	DeleteRule(ruleKey string) error
+	// LoadRulesSorted loads the rules like LoadRules, but in the order the
+	// rules are applied, see SortedRuleLoader.
+	LoadRulesSorted(f func(k, v string)) error
+	// LoadRulesByPrefix loads the rules whose keys have the prefix, e.g. the
+	// hex encoded group ID followed by "-" selects the rules of the group.
+	LoadRulesByPrefix(keyPrefix string, f func(k, v string)) error
 	LoadRuleGroups(f func(k, v string)) error
-	SaveRuleGroup(groupID string, group interface{}) error
-	DeleteRuleGroup(groupID string) error
+	// LoadRulesWithConsistency and LoadRuleGroupsWithConsistency load the
+	// rules and rule groups like LoadRules and LoadRuleGroups, at the given
+	// consistency level, see ReadConsistency.
+	LoadRulesWithConsistency(consistency ReadConsistency, f func(k, v string)) error
+	LoadRuleGroupsWithConsistency(consistency ReadConsistency, f func(k, v string)) error
+	// IsFresh returns whether the storage is fresh enough to make placement
+	// decisions on, the watched storage may fall behind etcd.
+	IsFresh() bool
+	// The rules and rule groups are saved in a transaction, so that a batch
+	// of updates is either fully applied or not at all, and it is observed
+	// by the watchers as a whole.
+	SaveRule(txn kv.Txn, ruleKey string, rule interface{}) error
+	DeleteRule(txn kv.Txn, ruleKey string) error
+	SaveRuleGroup(txn kv.Txn, groupID string, group interface{}) error
+	DeleteRuleGroup(txn kv.Txn, groupID string) error
+	LoadRuleTemplates(f func(k, v string)) error
+	SaveRuleTemplate(txn kv.Txn, templateID string, template interface{}) error
+	DeleteRuleTemplate(txn kv.Txn, templateID string) error
+	// The tombstones keep the deleted rules during the grace period, so that
+	// they can be restored.
+	LoadRuleTombstones(f func(k, v string)) error
+	SaveRuleTombstone(txn kv.Txn, ruleKey string, tombstone interface{}) error
+	DeleteRuleTombstone(txn kv.Txn, ruleKey string) error
+	// The rule sets are the complete rule configurations staged to be
+	// activated at once, and the state records the active one.
+	LoadRuleSets(f func(k, v string)) error
+	SaveRuleSet(txn kv.Txn, name string, ruleSet interface{}) error
+	DeleteRuleSet(txn kv.Txn, name string) error
+	LoadRuleSetState() (string, error)
+	SaveRuleSetState(txn kv.Txn, state interface{}) error
+	// The history records the mutations of the rules and rule groups, which
+	// are loaded in the order of the revisions.
+	LoadRuleHistory(f func(k, v string)) error
+	SaveRuleHistory(txn kv.Txn, revision uint64, entry interface{}) error
+	DeleteRuleHistory(txn kv.Txn, revision uint64) error
+	// The rule revision is the max revision ever stamped on the rules, it's
+	// saved along with the rules, so the revisions are never reused even if
+	// the rules with the max revision are deleted.
+	LoadRuleRevision() (string, error)
+	SaveRuleRevision(txn kv.Txn, revision uint64) error
+	RunInTxn(ctx context.Context, f func(txn kv.Txn) error) error
 	LoadRegionRules(f func(k, v string)) error
-	SaveRegionRule(ruleKey string, rule interface{}) error
-	DeleteRegionRule(ruleKey string) error
+	// LoadRegionRulesPage loads at most limit region rules after the key
+	// startAfter in the order of the keys. The returned token is used as the
+	// startAfter of the next page, and it's empty once all rules are loaded.
+	LoadRegionRulesPage(limit int, startAfter string) ([]string, string, error)
+	SaveRegionRule(txn kv.Txn, ruleKey string, rule interface{}) error
+	DeleteRegionRule(txn kv.Txn, ruleKey string) error
+	// The snapshots contain all the rules, rule groups, rule templates and
+	// region label rules, which are used to revert them to a point in time.
+	SaveSnapshot(name string) error
+	RestoreSnapshot(name string) error
+	ListSnapshots() ([]string, error)
+	// Compact discards the history of the storage before the latest
+	// keepRevisions revisions, it's a no-op if the storage keeps no history.
+	Compact(keepRevisions int) error
 }
 
 var _ RuleStorage = (*StorageEndpoint)(nil)
 
 // SaveRule stores a rule cfg to the rulesPath.
-func (se *StorageEndpoint) SaveRule(ruleKey string, rule interface{}) error {
-	return se.saveJSON(ruleKeyPath(ruleKey), rule)
+func (se *StorageEndpoint) SaveRule(txn kv.Txn, ruleKey string, rule interface{}) error {
+	return saveJSONInTxn(txn, ruleKeyPath(ruleKey), rule)
 }
 
 // DeleteRule removes a rule from storage.
-func (se *StorageEndpoint) DeleteRule(ruleKey string) error {
-	return se.Remove(ruleKeyPath(ruleKey))
+func (se *StorageEndpoint) DeleteRule(txn kv.Txn, ruleKey string) error {
+	return txn.Remove(ruleKeyPath(ruleKey))
 }
 
 // LoadRuleGroups loads all rule groups from storage.
@@ -51,13 +112,43 @@ func (se *StorageEndpoint) LoadRuleGroups(f func(k, v string)) error {
 }
 
 // SaveRuleGroup stores a rule group config to storage.
-func (se *StorageEndpoint) SaveRuleGroup(groupID string, group interface{}) error {
-	return se.saveJSON(ruleGroupIDPath(groupID), group)
+func (se *StorageEndpoint) SaveRuleGroup(txn kv.Txn, groupID string, group interface{}) error {
+	return saveJSONInTxn(txn, ruleGroupIDPath(groupID), group)
 }
 
 // DeleteRuleGroup removes a rule group from storage.
-func (se *StorageEndpoint) DeleteRuleGroup(groupID string) error {
-	return se.Remove(ruleGroupIDPath(groupID))
+func (se *StorageEndpoint) DeleteRuleGroup(txn kv.Txn, groupID string) error {
+	return txn.Remove(ruleGroupIDPath(groupID))
+}
+
+// LoadRuleTemplates loads all rule templates from storage.
+func (se *StorageEndpoint) LoadRuleTemplates(f func(k, v string)) error {
+	return se.loadRangeByPrefix(ruleTemplatePath+"/", f)
+}
+
+// SaveRuleTemplate stores a rule template to storage.
+func (se *StorageEndpoint) SaveRuleTemplate(txn kv.Txn, templateID string, template interface{}) error {
+	return saveJSONInTxn(txn, ruleTemplateIDPath(templateID), template)
+}
+
+// DeleteRuleTemplate removes a rule template from storage.
+func (se *StorageEndpoint) DeleteRuleTemplate(txn kv.Txn, templateID string) error {
+	return txn.Remove(ruleTemplateIDPath(templateID))
+}
+
+// LoadRuleTombstones loads all rule tombstones from storage.
+func (se *StorageEndpoint) LoadRuleTombstones(f func(k, v string)) error {
+	return se.loadRangeByPrefix(ruleTombstonePath+"/", f)
+}
+
+// SaveRuleTombstone stores a rule tombstone to storage.
+func (se *StorageEndpoint) SaveRuleTombstone(txn kv.Txn, ruleKey string, tombstone interface{}) error {
+	return saveJSONInTxn(txn, ruleTombstoneKeyPath(ruleKey), tombstone)
+}
+
+// DeleteRuleTombstone removes a rule tombstone from storage.
+func (se *StorageEndpoint) DeleteRuleTombstone(txn kv.Txn, ruleKey string) error {
+	return txn.Remove(ruleTombstoneKeyPath(ruleKey))
 }
 
 // LoadRegionRules loads region rules from storage.
@@ -65,14 +156,19 @@ func (se *StorageEndpoint) LoadRegionRules(f func(k, v string)) error {
 	return se.loadRangeByPrefix(regionLabelPath+"/", f)
 }
 
+// LoadRegionRulesPage loads a page of region rules from storage.
+func (se *StorageEndpoint) LoadRegionRulesPage(limit int, startAfter string) ([]string, string, error) {
+	return se.loadPageByPrefix(regionLabelPath+"/", limit, startAfter)
+}
+
 // SaveRegionRule saves a region rule to the storage.
-func (se *StorageEndpoint) SaveRegionRule(ruleKey string, rule interface{}) error {
-	return se.saveJSON(regionLabelKeyPath(ruleKey), rule)
+func (se *StorageEndpoint) SaveRegionRule(txn kv.Txn, ruleKey string, rule interface{}) error {
+	return saveJSONInTxn(txn, regionLabelKeyPath(ruleKey), rule)
 }
 
 // DeleteRegionRule removes a region rule from storage.
-func (se *StorageEndpoint) DeleteRegionRule(ruleKey string) error {
-	return se.Remove(regionLabelKeyPath(ruleKey))
+func (se *StorageEndpoint) DeleteRegionRule(txn kv.Txn, ruleKey string) error {
+	return txn.Remove(regionLabelKeyPath(ruleKey))
 }
 
 // LoadRules loads placement rules from storage.
@@ -80,6 +176,172 @@ func (se *StorageEndpoint) LoadRules(f func(k, v string)) error {
 	return se.loadRangeByPrefix(rulesPath+"/", f)
 }
 
+// ReadConsistency is the consistency level of reading the rules.
+type ReadConsistency int
+
+const (
+	// CachedRead reads the rules from the view kept by the storage, e.g., the
+	// one maintained by watching etcd. It's fast and never leaves the process,
+	// but it may lag behind the committed updates for a while.
+	CachedRead ReadConsistency = iota
+	// LinearizableRead reads the rules through etcd with a quorum read, so all
+	// the updates committed before the read are observed. It costs a round trip
+	// to the etcd leader, so it should not be used in the hot path, e.g., by
+	// the schedulers.
+	LinearizableRead
+)
+
+// LoadRulesWithConsistency loads placement rules from storage. The storage
+// reads etcd directly, so both the consistency levels are linearizable.
+func (se *StorageEndpoint) LoadRulesWithConsistency(_ ReadConsistency, f func(k, v string)) error {
+	return se.LoadRules(f)
+}
+
+// LoadRuleGroupsWithConsistency loads all rule groups from storage, like
+// LoadRulesWithConsistency.
+func (se *StorageEndpoint) LoadRuleGroupsWithConsistency(_ ReadConsistency, f func(k, v string)) error {
+	return se.LoadRuleGroups(f)
+}
+
+// IsFresh always returns true since the storage reads etcd directly.
+func (*StorageEndpoint) IsFresh() bool {
+	return true
+}
+
+// LoadRuleSets loads all rule sets from storage.
+func (se *StorageEndpoint) LoadRuleSets(f func(k, v string)) error {
+	return se.loadRangeByPrefix(ruleSetPath+"/", f)
+}
+
+// SaveRuleSet stores a rule set to storage.
+func (se *StorageEndpoint) SaveRuleSet(txn kv.Txn, name string, ruleSet interface{}) error {
+	return saveJSONInTxn(txn, ruleSetKeyPath(name), ruleSet)
+}
+
+// DeleteRuleSet removes a rule set from storage.
+func (se *StorageEndpoint) DeleteRuleSet(txn kv.Txn, name string) error {
+	return txn.Remove(ruleSetKeyPath(name))
+}
+
+// LoadRuleSetState loads the state of the rule sets from storage.
+func (se *StorageEndpoint) LoadRuleSetState() (string, error) {
+	return se.Load(ruleSetStatePath)
+}
+
+// SaveRuleSetState stores the state of the rule sets to storage.
+func (se *StorageEndpoint) SaveRuleSetState(txn kv.Txn, state interface{}) error {
+	return saveJSONInTxn(txn, ruleSetStatePath, state)
+}
+
+// LoadRuleHistory loads all rule history entries from storage.
+func (se *StorageEndpoint) LoadRuleHistory(f func(k, v string)) error {
+	return se.loadRangeByPrefix(ruleHistoryPath+"/", f)
+}
+
+// SaveRuleHistory stores a rule history entry to storage.
+func (se *StorageEndpoint) SaveRuleHistory(txn kv.Txn, revision uint64, entry interface{}) error {
+	return saveJSONInTxn(txn, ruleHistoryKeyPath(revision), entry)
+}
+
+// DeleteRuleHistory removes a rule history entry from storage.
+func (se *StorageEndpoint) DeleteRuleHistory(txn kv.Txn, revision uint64) error {
+	return txn.Remove(ruleHistoryKeyPath(revision))
+}
+
+// LoadRuleRevision loads the max revision of the rules from storage.
+func (se *StorageEndpoint) LoadRuleRevision() (string, error) {
+	return se.Load(ruleRevisionPath)
+}
+
+// SaveRuleRevision stores the max revision of the rules to storage.
+func (se *StorageEndpoint) SaveRuleRevision(txn kv.Txn, revision uint64) error {
+	return txn.Save(ruleRevisionPath, strconv.FormatUint(revision, 10))
+}
+
+// LoadRulesSorted loads placement rules from storage in the apply order.
+func (se *StorageEndpoint) LoadRulesSorted(f func(k, v string)) error {
+	return SortedRuleLoader(se.LoadRules, se.LoadRuleGroups)(f)
+}
+
+// SortedRuleLoader returns a function loading the rules by loadRules in the
+// order the rules are applied, that is [GroupIndex, GroupID, Index, ID], and
+// the group indexes are loaded by loadRuleGroups. The rules failed to be
+// decoded are loaded at last in the order of the keys, so that the callers
+// can handle them as with loadRules.
+func SortedRuleLoader(loadRules, loadRuleGroups func(f func(k, v string)) error) func(f func(k, v string)) error {
+	return func(f func(k, v string)) error {
+		groupIndexes := make(map[string]int)
+		if err := loadRuleGroups(func(k, v string) {
+			var g struct {
+				Index int `json:"index"`
+			}
+			if json.Unmarshal([]byte(v), &g) == nil {
+				groupIndexes[k] = g.Index
+			}
+		}); err != nil {
+			return err
+		}
+		type sortedRule struct {
+			key, value string
+			valid      bool
+			groupIndex int
+			GroupID    string `json:"group_id"`
+			ID         string `json:"id"`
+			Index      int    `json:"index"`
+		}
+		var rules []*sortedRule
+		if err := loadRules(func(k, v string) {
+			r := &sortedRule{key: k, value: v}
+			r.valid = json.Unmarshal([]byte(v), r) == nil
+			r.groupIndex = groupIndexes[r.GroupID]
+			rules = append(rules, r)
+		}); err != nil {
+			return err
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			a, b := rules[i], rules[j]
+			switch {
+			case a.valid != b.valid:
+				return a.valid
+			case !a.valid:
+				return a.key < b.key
+			case a.groupIndex != b.groupIndex:
+				return a.groupIndex < b.groupIndex
+			case a.GroupID != b.GroupID:
+				return a.GroupID < b.GroupID
+			case a.Index != b.Index:
+				return a.Index < b.Index
+			default:
+				return a.ID < b.ID
+			}
+		})
+		for _, r := range rules {
+			f(r.key, r.value)
+		}
+		return nil
+	}
+}
+
+// LoadRulesByPrefix loads the placement rules with the key prefix from storage.
+func (se *StorageEndpoint) LoadRulesByPrefix(keyPrefix string, f func(k, v string)) error {
+	return se.loadRangeByPrefix(rulesPath+"/"+keyPrefix, func(k, v string) { f(keyPrefix+k, v) })
+}
+
+// Compact compacts the history of the storage before the latest keepRevisions
+// revisions. NOTE: for etcd, the whole keyspace rather than only the rules is
+// compacted, and the watchers whose revisions are compacted have to resync.
+func (se *StorageEndpoint) Compact(keepRevisions int) error {
+	if keepRevisions < 0 {
+		return errors.Errorf("invalid keep revisions %d", keepRevisions)
+	}
+	compactor, ok := se.Base.(kv.Compactor)
+	if !ok {
+		return nil
+	}
+	_, err := compactor.Compact(context.Background(), int64(keepRevisions))
+	return err
+}
+
 // loadRangeByPrefix iterates all key-value pairs in the storage that has the prefix.
 func (se *StorageEndpoint) loadRangeByPrefix(prefix string, f func(k, v string)) error {
 	nextKey := prefix
@@ -98,3 +360,25 @@ func (se *StorageEndpoint) loadRangeByPrefix(prefix string, f func(k, v string))
 		nextKey = keys[len(keys)-1] + "\x00"
 	}
 }
+
+// loadPageByPrefix loads at most limit values after the key startAfter under
+// the prefix, the MinKVRangeLimit is used if the limit is not positive. The
+// trimmed key of the last value is returned if there may be more values.
+func (se *StorageEndpoint) loadPageByPrefix(prefix string, limit int, startAfter string) ([]string, string, error) {
+	if limit <= 0 {
+		limit = MinKVRangeLimit
+	}
+	startKey := prefix
+	if startAfter != "" {
+		startKey = prefix + startAfter + "\x00"
+	}
+	keys, values, err := se.LoadRange(startKey, clientv3.GetPrefixRangeEnd(prefix), limit)
+	if err != nil {
+		return nil, "", err
+	}
+	var next string
+	if len(keys) == limit {
+		next = strings.TrimPrefix(keys[len(keys)-1], prefix)
+	}
+	return values, next, nil
+}
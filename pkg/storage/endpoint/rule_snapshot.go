@@ -0,0 +1,155 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package endpoint
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/storage/kv"
+)
+
+// maxTxnOps is the max number of operations in a transaction, which is
+// limited by etcd.
+const maxTxnOps = 120
+
+// ruleSnapshotPrefixes are the paths of the keys included in a rule snapshot.
+var ruleSnapshotPrefixes = []string{rulesPath, ruleGroupPath, ruleTemplatePath, regionLabelPath}
+
+// SaveSnapshot copies all the rules, rule groups, rule templates and region
+// label rules to the snapshot with the given name. The existing snapshot with
+// the same name is overwritten.
+func (se *StorageEndpoint) SaveSnapshot(name string) error {
+	if err := checkRuleSnapshotName(name); err != nil {
+		return err
+	}
+	// Remove the meta first, so that a partially written snapshot is never listed.
+	if err := se.Remove(ruleSnapshotMetaKeyPath(name)); err != nil {
+		return err
+	}
+	snapshotPath := ruleSnapshotKeyPath(name)
+	kvs := make(map[string]string)
+	for _, prefix := range ruleSnapshotPrefixes {
+		prefix := prefix
+		if err := se.loadRangeByPrefix(prefix+"/", func(k, v string) {
+			kvs[path.Join(snapshotPath, prefix, k)] = v
+		}); err != nil {
+			return err
+		}
+	}
+	var ops []func(txn kv.Txn) error
+	// Remove the keys of the old snapshot which are not overwritten.
+	if err := se.loadRangeByPrefix(snapshotPath+"/", func(k, _ string) {
+		key := path.Join(snapshotPath, k)
+		if _, ok := kvs[key]; !ok {
+			ops = append(ops, func(txn kv.Txn) error { return txn.Remove(key) })
+		}
+	}); err != nil {
+		return err
+	}
+	for k, v := range kvs {
+		k, v := k, v
+		ops = append(ops, func(txn kv.Txn) error { return txn.Save(k, v) })
+	}
+	if err := se.runInTxns(ops); err != nil {
+		return err
+	}
+	return se.Save(ruleSnapshotMetaKeyPath(name), time.Now().Format(time.RFC3339))
+}
+
+// RestoreSnapshot overwrites the rules, rule groups, rule templates and region
+// label rules with the snapshot with the given name. The keys not in the
+// snapshot are deleted, and the unchanged keys are not touched, so the watchers
+// only observe the keys which are reverted.
+func (se *StorageEndpoint) RestoreSnapshot(name string) error {
+	if err := checkRuleSnapshotName(name); err != nil {
+		return err
+	}
+	meta, err := se.Load(ruleSnapshotMetaKeyPath(name))
+	if err != nil {
+		return err
+	}
+	if meta == "" {
+		return errs.ErrRuleSnapshotNotFound.FastGenByArgs(name)
+	}
+	snapshotPath := ruleSnapshotKeyPath(name)
+	kvs := make(map[string]string)
+	if err := se.loadRangeByPrefix(snapshotPath+"/", func(k, v string) {
+		kvs[k] = v
+	}); err != nil {
+		return err
+	}
+	var ops []func(txn kv.Txn) error
+	for _, prefix := range ruleSnapshotPrefixes {
+		prefix := prefix
+		if err := se.loadRangeByPrefix(prefix+"/", func(k, v string) {
+			key := path.Join(prefix, k)
+			if value, ok := kvs[key]; !ok {
+				ops = append(ops, func(txn kv.Txn) error { return txn.Remove(key) })
+			} else if value == v {
+				delete(kvs, key)
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	for k, v := range kvs {
+		k, v := k, v
+		ops = append(ops, func(txn kv.Txn) error { return txn.Save(k, v) })
+	}
+	return se.runInTxns(ops)
+}
+
+// ListSnapshots returns the names of all the rule snapshots.
+func (se *StorageEndpoint) ListSnapshots() ([]string, error) {
+	var names []string
+	err := se.loadRangeByPrefix(ruleSnapshotMetaPath+"/", func(k, _ string) {
+		names = append(names, k)
+	})
+	return names, err
+}
+
+func checkRuleSnapshotName(name string) error {
+	if name == "" || strings.Contains(name, "/") {
+		return errs.ErrRuleSnapshotName.FastGenByArgs(name)
+	}
+	return nil
+}
+
+// runInTxns runs the operations in transactions, each of which has at most
+// maxTxnOps operations.
+func (se *StorageEndpoint) runInTxns(ops []func(txn kv.Txn) error) error {
+	for len(ops) > 0 {
+		batch := ops
+		if len(batch) > maxTxnOps {
+			batch = batch[:maxTxnOps]
+		}
+		ops = ops[len(batch):]
+		if err := se.RunInTxn(context.Background(), func(txn kv.Txn) error {
+			for _, op := range batch {
+				if err := op(txn); err != nil {
+					return err
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
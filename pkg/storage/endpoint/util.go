@@ -19,6 +19,7 @@ import (
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/tikv/pd/pkg/errs"
+	"github.com/tikv/pd/pkg/storage/kv"
 )
 
 func (se *StorageEndpoint) loadProto(key string, msg proto.Message) (bool, error) {
@@ -48,3 +49,11 @@ func (se *StorageEndpoint) saveJSON(key string, data interface{}) error {
 	}
 	return se.Save(key, string(value))
 }
+
+func saveJSONInTxn(txn kv.Txn, key string, data interface{}) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return errs.ErrJSONMarshal.Wrap(err).GenWithStackByArgs()
+	}
+	return txn.Save(key, string(value))
+}
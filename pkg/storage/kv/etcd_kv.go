@@ -18,6 +18,7 @@ import (
 	"context"
 	"path"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/pingcap/errors"
@@ -26,6 +27,7 @@ import (
 	"github.com/tikv/pd/pkg/errs"
 	"github.com/tikv/pd/pkg/utils/etcdutil"
 	"go.etcd.io/etcd/clientv3"
+	"go.etcd.io/etcd/etcdserver/api/v3rpc/rpctypes"
 	"go.uber.org/zap"
 )
 
@@ -45,6 +47,8 @@ var (
 type etcdKVBase struct {
 	client   *clientv3.Client
 	rootPath string
+	// compactRevision is the last revision compacted to by the kv.
+	compactRevision int64
 }
 
 // NewEtcdKVBase creates a new etcd kv.
@@ -133,6 +137,33 @@ func (kv *etcdKVBase) Remove(key string) error {
 	return nil
 }
 
+// Compact compacts the etcd history before the latest keepRevisions revisions.
+// NOTE: the compaction applies to the whole etcd keyspace rather than the keys
+// under the root path, since etcd doesn't support compacting a key range.
+func (kv *etcdKVBase) Compact(ctx context.Context, keepRevisions int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	resp, err := kv.client.Get(ctx, kv.rootPath, clientv3.WithCountOnly())
+	if err != nil {
+		return 0, errs.ErrEtcdKVGet.Wrap(err).GenWithStackByCause()
+	}
+	revision := resp.Header.Revision - keepRevisions
+	if revision <= atomic.LoadInt64(&kv.compactRevision) {
+		return 0, nil
+	}
+	if _, err := kv.client.Compact(ctx, revision); err != nil {
+		// the revision may be compacted by others, e.g., the auto compaction of etcd.
+		if err == rpctypes.ErrCompacted {
+			return 0, nil
+		}
+		return 0, errs.ErrEtcdCompact.Wrap(err).GenWithStackByCause()
+	}
+	compactedRevisionsCounter.Add(float64(revision - atomic.SwapInt64(&kv.compactRevision, revision)))
+	lastCompactionGauge.Set(float64(time.Now().Unix()))
+	log.Info("etcd is compacted", zap.Int64("revision", revision), zap.Int64("keep-revisions", keepRevisions))
+	return revision, nil
+}
+
 // SlowLogTxn wraps etcd transaction and log slow one.
 type SlowLogTxn struct {
 	clientv3.Txn
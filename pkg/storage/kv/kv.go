@@ -43,3 +43,11 @@ type Base interface {
 	// values loaded during transaction has not been modified before commit.
 	RunInTxn(ctx context.Context, f func(txn Txn) error) error
 }
+
+// Compactor is implemented by the kv which keeps the history of the revisions,
+// e.g. etcd, so the history can be compacted to reclaim the space.
+type Compactor interface {
+	// Compact discards the history before the latest keepRevisions revisions,
+	// and returns the revision compacted to, which is 0 if nothing is done.
+	Compact(ctx context.Context, keepRevisions int64) (int64, error)
+}
@@ -37,6 +37,7 @@ func TestEtcd(t *testing.T) {
 	testRange(re, kv)
 	testSaveMultiple(re, kv, 20)
 	testLoadConflict(re, kv)
+	testCompact(re, kv, client)
 }
 
 func TestLevelDB(t *testing.T) {
@@ -158,3 +159,28 @@ func testLoadConflict(re *require.Assertions, kv Base) {
 	// When other writer exists, loader must error.
 	re.Error(kv.RunInTxn(context.Background(), conflictLoader))
 }
+
+func testCompact(re *require.Assertions, kv *etcdKVBase, client *clientv3.Client) {
+	for i := 0; i < 10; i++ {
+		re.NoError(kv.Save("compactKey", strconv.Itoa(i)))
+	}
+	resp, err := client.Get(context.Background(), path.Join(kv.rootPath, "compactKey"))
+	re.NoError(err)
+	revision := resp.Header.Revision
+	compacted, err := kv.Compact(context.Background(), 5)
+	re.NoError(err)
+	re.Equal(revision-5, compacted)
+	// the history before the compact revision is discarded.
+	_, err = client.Get(context.Background(), path.Join(kv.rootPath, "compactKey"), clientv3.WithRev(compacted-1))
+	re.Error(err)
+	resp, err = client.Get(context.Background(), path.Join(kv.rootPath, "compactKey"), clientv3.WithRev(compacted))
+	re.NoError(err)
+	re.Len(resp.Kvs, 1)
+	// nothing is done if the revision is compacted already.
+	compacted, err = kv.Compact(context.Background(), 5)
+	re.NoError(err)
+	re.Zero(compacted)
+	value, err := kv.Load("compactKey")
+	re.NoError(err)
+	re.Equal("9", value)
+}
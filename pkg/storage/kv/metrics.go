@@ -33,9 +33,27 @@ var (
 			Help:      "Bucketed histogram of processing time (s) of handled txns.",
 			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 13),
 		}, []string{"result"})
+
+	compactedRevisionsCounter = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: "pd",
+			Subsystem: "storage",
+			Name:      "compacted_revisions_total",
+			Help:      "Counter of the etcd revisions compacted by PD.",
+		})
+
+	lastCompactionGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "pd",
+			Subsystem: "storage",
+			Name:      "last_compaction_time",
+			Help:      "The unix timestamp of the last etcd compaction done by PD.",
+		})
 )
 
 func init() {
 	prometheus.MustRegister(txnCounter)
 	prometheus.MustRegister(txnDuration)
+	prometheus.MustRegister(compactedRevisionsCounter)
+	prometheus.MustRegister(lastCompactionGauge)
 }
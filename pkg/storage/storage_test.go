@@ -30,6 +30,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/storage/endpoint"
+	"github.com/tikv/pd/pkg/storage/kv"
 	"go.etcd.io/etcd/clientv3"
 )
 
@@ -314,6 +315,142 @@ func TestTrySwitchRegionStorage(t *testing.T) {
 	}
 }
 
+func TestRuleSnapshot(t *testing.T) {
+	re := require.New(t)
+	storage := NewStorageWithMemoryBackend()
+	saveRules := func(rules map[string]string) {
+		re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+			for k, v := range rules {
+				if v == "" {
+					if err := storage.DeleteRule(txn, k); err != nil {
+						return err
+					}
+				} else if err := storage.SaveRule(txn, k, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}))
+	}
+	loadAll := func() map[string]string {
+		kvs := make(map[string]string)
+		re.NoError(storage.LoadRules(func(k, v string) { kvs["rule/"+k] = v }))
+		re.NoError(storage.LoadRuleGroups(func(k, v string) { kvs["group/"+k] = v }))
+		re.NoError(storage.LoadRegionRules(func(k, v string) { kvs["label/"+k] = v }))
+		return kvs
+	}
+
+	saveRules(map[string]string{"r1": "v1", "r2": "v2"})
+	re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		return storage.SaveRuleGroup(txn, "g1", "v1")
+	}))
+	re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		return storage.SaveRegionRule(txn, "l1", "v1")
+	}))
+	expected := loadAll()
+	re.Len(expected, 4)
+	re.NoError(storage.SaveSnapshot("s1"))
+	names, err := storage.ListSnapshots()
+	re.NoError(err)
+	re.Equal([]string{"s1"}, names)
+	// the snapshot is not visible to the loaders.
+	re.Equal(expected, loadAll())
+
+	saveRules(map[string]string{"r1": "", "r2": "v3", "r3": "v1"})
+	re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		return storage.DeleteRegionRule(txn, "l1")
+	}))
+	re.NoError(storage.RestoreSnapshot("s1"))
+	re.Equal(expected, loadAll())
+
+	// overwrite the snapshot.
+	saveRules(map[string]string{"r1": ""})
+	expected = loadAll()
+	re.NoError(storage.SaveSnapshot("s1"))
+	saveRules(map[string]string{"r1": "v1"})
+	re.NoError(storage.RestoreSnapshot("s1"))
+	re.Equal(expected, loadAll())
+	names, err = storage.ListSnapshots()
+	re.NoError(err)
+	re.Equal([]string{"s1"}, names)
+
+	re.ErrorContains(storage.RestoreSnapshot("s2"), "not found")
+	re.ErrorContains(storage.SaveSnapshot("s/2"), "invalid rule snapshot name")
+	re.ErrorContains(storage.SaveSnapshot(""), "invalid rule snapshot name")
+}
+
+func TestLoadRulesPaginated(t *testing.T) {
+	re := require.New(t)
+	storage := NewStorageWithMemoryBackend()
+	re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for i := 0; i < 5; i++ {
+			if err := storage.SaveRegionRule(txn, fmt.Sprintf("l%d", i), fmt.Sprintf("v%d", i)); err != nil {
+				return err
+			}
+		}
+		for _, k := range []string{"61-31", "61-32", "62-31"} {
+			if err := storage.SaveRule(txn, k, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	var pages [][]string
+	token := ""
+	for {
+		values, next, err := storage.LoadRegionRulesPage(2, token)
+		re.NoError(err)
+		pages = append(pages, values)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	// the values are saved in JSON.
+	re.Equal([][]string{{`"v0"`, `"v1"`}, {`"v2"`, `"v3"`}, {`"v4"`}}, pages)
+	values, next, err := storage.LoadRegionRulesPage(0, "l2")
+	re.NoError(err)
+	re.Equal([]string{`"v3"`, `"v4"`}, values)
+	re.Empty(next)
+
+	rules := make(map[string]string)
+	re.NoError(storage.LoadRulesByPrefix("61-", func(k, v string) { rules[k] = v }))
+	re.Equal(map[string]string{"61-31": `"61-31"`, "61-32": `"61-32"`}, rules)
+}
+
+func TestLoadRulesSorted(t *testing.T) {
+	re := require.New(t)
+	storage := NewStorageWithMemoryBackend()
+	rules := map[string]string{
+		"g1-r1": `{"group_id":"g1","id":"r1","index":2}`,
+		"g1-r2": `{"group_id":"g1","id":"r2","index":1}`,
+		"g2-r1": `{"group_id":"g2","id":"r1"}`,
+		"g3-r1": `{"group_id":"g3","id":"r1"}`,
+		"g3-r2": `{"group_id":"g3","id":"r2"}`,
+		"bad":   `"invalid"`,
+	}
+	re.NoError(storage.RunInTxn(context.Background(), func(txn kv.Txn) error {
+		for k, v := range rules {
+			if err := storage.SaveRule(txn, k, json.RawMessage(v)); err != nil {
+				return err
+			}
+		}
+		if err := storage.SaveRuleGroup(txn, "g1", map[string]interface{}{"id": "g1", "index": 1}); err != nil {
+			return err
+		}
+		return storage.SaveRuleGroup(txn, "g2", map[string]interface{}{"id": "g2", "index": 2})
+	}))
+
+	var keys []string
+	re.NoError(storage.LoadRulesSorted(func(k, v string) {
+		re.Equal(rules[k], v)
+		keys = append(keys, k)
+	}))
+	// sorted by the group index, group ID, rule index and ID.
+	re.Equal([]string{"g3-r1", "g3-r2", "g1-r2", "g1-r1", "g2-r1", "bad"}, keys)
+}
+
 const (
 	keyChars = "abcdefghijklmnopqrstuvwxyz"
 	keyLen   = 20
@@ -57,6 +57,9 @@ const (
 	XForwardedPortHeader = "X-Forwarded-Port"
 	// XRealIPHeader is used to mark the real client IP.
 	XRealIPHeader = "X-Real-Ip"
+	// IdempotencyKeyHeader is used to mark the retries of a request, so the
+	// request is only applied once.
+	IdempotencyKeyHeader = "Idempotency-Key"
 
 	// ErrRedirectFailed is the error message for redirect failed.
 	ErrRedirectFailed = "redirect failed"
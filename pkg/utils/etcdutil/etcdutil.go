@@ -38,6 +38,8 @@ import (
 	"go.etcd.io/etcd/mvcc/mvccpb"
 	"go.etcd.io/etcd/pkg/types"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -581,6 +583,15 @@ type LoopWatcher struct {
 	// updateClientCh is used to update the etcd client.
 	// It's only used for testing.
 	updateClientCh chan *clientv3.Client
+	// watchStatusFn is called with the error once the watch meets one, and
+	// with nil once it receives a response from etcd again.
+	watchStatusFn func(err error)
+	// compactionFn is called with the compact revision once the required
+	// revision is compacted, since the events before it are lost.
+	compactionFn func(compactRevision int64)
+	// progressFn is called with the revision once all the events up to it
+	// have been handled, including the empty progress notifications.
+	progressFn func(revision int64)
 }
 
 // NewLoopWatcher creates a new LoopWatcher.
@@ -633,6 +644,7 @@ func (lw *LoopWatcher) StartWatchLoop() {
 			}
 			nextRevision, err := lw.watch(ctx, watchStartRevision)
 			if err != nil {
+				lw.notifyWatchStatus(err)
 				log.Error("watcher canceled unexpectedly and a new watcher will start after a while for watch loop",
 					zap.String("name", lw.name),
 					zap.String("key", lw.key),
@@ -684,6 +696,7 @@ func (lw *LoopWatcher) initFromEtcd(ctx context.Context) int64 {
 	}
 	if err != nil {
 		log.Warn("meet error when loading in watch loop", zap.String("name", lw.name), zap.String("key", lw.key), zap.Error(err))
+		lw.notifyWatchStatus(err)
 	} else {
 		log.Info("load finished in watch loop", zap.String("name", lw.name), zap.String("key", lw.key))
 	}
@@ -728,6 +741,7 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 		if err := watcherCtx.Err(); err != nil {
 			log.Warn("error occurred while creating watch channel and retry it", zap.Error(err),
 				zap.Int64("revision", revision), zap.String("name", lw.name), zap.String("key", lw.key))
+			lw.notifyWatchStatus(err)
 			select {
 			case <-ctx.Done():
 				return revision, nil
@@ -746,6 +760,7 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 			if err != nil {
 				log.Warn("force load key failed in watch loop",
 					zap.String("name", lw.name), zap.String("key", lw.key), zap.Error(err))
+				lw.notifyWatchStatus(err)
 			}
 			continue
 		case <-ticker.C:
@@ -763,6 +778,7 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 				log.Warn("watch channel is blocked for a long time, recreating a new one in watch loop",
 					zap.Duration("timeout", time.Since(lastReceivedResponseTime)),
 					zap.Int64("revision", revision), zap.String("name", lw.name), zap.String("key", lw.key))
+				lw.notifyWatchStatus(errors.Errorf("watch channel is blocked for %s", time.Since(lastReceivedResponseTime)))
 				continue
 			}
 		case wresp := <-watchChan:
@@ -772,11 +788,17 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 				failpoint.Goto("watchChanLoop")
 			})
 			lastReceivedResponseTime = time.Now()
+			if wresp.Err() == nil {
+				lw.notifyWatchStatus(nil)
+			}
 			if wresp.CompactRevision != 0 {
 				log.Warn("required revision has been compacted, use the compact revision in watch loop",
 					zap.Int64("required-revision", revision), zap.Int64("compact-revision", wresp.CompactRevision),
 					zap.String("name", lw.name), zap.String("key", lw.key))
 				revision = wresp.CompactRevision
+				if lw.compactionFn != nil {
+					lw.compactionFn(revision)
+				}
 				continue
 			} else if err := wresp.Err(); err != nil { // wresp.Err() contains CompactRevision not equal to 0
 				log.Error("watcher is canceled in watch loop", errs.ZapError(errs.ErrEtcdWatcherCancel, err),
@@ -785,6 +807,7 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 			} else if wresp.IsProgressNotify() {
 				log.Debug("watcher receives progress notify in watch loop",
 					zap.Int64("revision", revision), zap.String("name", lw.name), zap.String("key", lw.key))
+				lw.notifyProgress(wresp.Header.Revision)
 				goto watchChanLoop
 			}
 			for _, event := range wresp.Events {
@@ -812,6 +835,7 @@ func (lw *LoopWatcher) watch(ctx context.Context, revision int64) (nextRevision
 				log.Error("run post event failed in watch loop", zap.Error(err),
 					zap.Int64("revision", revision), zap.String("name", lw.name), zap.String("key", lw.key))
 			}
+			lw.notifyProgress(wresp.Header.Revision)
 			revision = wresp.Header.Revision + 1
 		}
 		goto watchChanLoop // Use goto to avoid creating a new watchChan
@@ -856,6 +880,7 @@ func (lw *LoopWatcher) load(ctx context.Context) (nextRevision int64, err error)
 				log.Error("run post event failed in watch loop", zap.String("name", lw.name),
 					zap.String("key", lw.key), zap.Error(err))
 			}
+			lw.notifyProgress(resp.Header.Revision)
 			return resp.Header.Revision + 1, err
 		}
 	}
@@ -907,3 +932,54 @@ func (lw *LoopWatcher) SetLoadTimeout(timeout time.Duration) {
 func (lw *LoopWatcher) SetLoadBatchSize(size int64) {
 	lw.loadBatchSize = size
 }
+
+// SetWatchStatusHandler sets the function called with the error once the watch
+// meets one, and with nil once it receives a response from etcd again. It
+// should be set before starting the watch loop.
+func (lw *LoopWatcher) SetWatchStatusHandler(fn func(err error)) {
+	lw.watchStatusFn = fn
+}
+
+// SetCompactionHandler sets the function called with the compact revision once
+// the required revision of the watch is compacted. The watch continues from the
+// compact revision, so the events in between are lost and the watcher may need
+// to reload. It should be set before starting the watch loop.
+func (lw *LoopWatcher) SetCompactionHandler(fn func(compactRevision int64)) {
+	lw.compactionFn = fn
+}
+
+// SetProgressHandler sets the function called with the revision once all the
+// events up to it have been handled, i.e., after loading, after handling the
+// events of a watch response, or on an empty progress notification. It should
+// be set before starting the watch loop.
+func (lw *LoopWatcher) SetProgressHandler(fn func(revision int64)) {
+	lw.progressFn = fn
+}
+
+func (lw *LoopWatcher) notifyProgress(revision int64) {
+	if lw.progressFn != nil {
+		lw.progressFn(revision)
+	}
+}
+
+func (lw *LoopWatcher) notifyWatchStatus(err error) {
+	if lw.watchStatusFn != nil {
+		lw.watchStatusFn(err)
+	}
+}
+
+// IsRetryableWatchError returns whether the watch may recover from the error by
+// retrying. The errors of the authentication and permission are fatal, which
+// can't be fixed without the intervention.
+func IsRetryableWatchError(err error) bool {
+	switch errors.Cause(err) {
+	case rpctypes.ErrPermissionDenied, rpctypes.ErrAuthFailed, rpctypes.ErrAuthNotEnabled,
+		rpctypes.ErrInvalidAuthToken, rpctypes.ErrInvalidAuthMgmt, rpctypes.ErrUserNotFound:
+		return false
+	}
+	switch status.Code(errors.Cause(err)) {
+	case codes.PermissionDenied, codes.Unauthenticated:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyspaceutil
+
+import (
+	"encoding/binary"
+
+	"github.com/tikv/pd/pkg/codec"
+)
+
+const (
+	rawKeyPrefix = 'r'
+	txnKeyPrefix = 'x'
+)
+
+// RegionBound represents the region boundary of the given keyspace.
+// For a keyspace with id ['a', 'b', 'c'], it has four boundaries:
+//
+//	Lower bound for raw mode: ['r', 'a', 'b', 'c']
+//	Upper bound for raw mode: ['r', 'a', 'b', 'c + 1']
+//	Lower bound for txn mode: ['x', 'a', 'b', 'c']
+//	Upper bound for txn mode: ['x', 'a', 'b', 'c + 1']
+//
+// From which it shares the lower bound with keyspace with id ['a', 'b', 'c-1'].
+// And shares upper bound with keyspace with id ['a', 'b', 'c + 1'].
+// These repeated bound will not cause any problem, as repetitive bound will be ignored during rangeListBuild,
+// but provides guard against hole in keyspace allocations should it occur.
+type RegionBound struct {
+	RawLeftBound  []byte
+	RawRightBound []byte
+	TxnLeftBound  []byte
+	TxnRightBound []byte
+}
+
+// MakeRegionBound constructs the correct region boundaries of the given keyspace.
+func MakeRegionBound(id uint32) *RegionBound {
+	keyspaceIDBytes := make([]byte, 4)
+	nextKeyspaceIDBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(keyspaceIDBytes, id)
+	binary.BigEndian.PutUint32(nextKeyspaceIDBytes, id+1)
+	return &RegionBound{
+		RawLeftBound:  codec.EncodeBytes(append([]byte{rawKeyPrefix}, keyspaceIDBytes[1:]...)),
+		RawRightBound: codec.EncodeBytes(append([]byte{rawKeyPrefix}, nextKeyspaceIDBytes[1:]...)),
+		TxnLeftBound:  codec.EncodeBytes(append([]byte{txnKeyPrefix}, keyspaceIDBytes[1:]...)),
+		TxnRightBound: codec.EncodeBytes(append([]byte{txnKeyPrefix}, nextKeyspaceIDBytes[1:]...)),
+	}
+}
+
+// KeyspaceOfKey returns the keyspace the encoded key belongs to. It returns
+// false if the key is not in any keyspace.
+func KeyspaceOfKey(key []byte) (uint32, bool) {
+	_, raw, err := codec.DecodeBytes(key)
+	if err != nil || len(raw) < 4 || (raw[0] != rawKeyPrefix && raw[0] != txnKeyPrefix) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(append([]byte{0}, raw[1:4]...)), true
+}
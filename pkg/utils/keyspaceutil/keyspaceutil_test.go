@@ -0,0 +1,40 @@
+// Copyright 2023 TiKV Project Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyspaceutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyspaceOfKey(t *testing.T) {
+	re := require.New(t)
+	ks1, ks2 := MakeRegionBound(1), MakeRegionBound(2)
+	for _, key := range [][]byte{ks1.RawLeftBound, ks1.TxnLeftBound} {
+		id, ok := KeyspaceOfKey(key)
+		re.True(ok)
+		re.Equal(uint32(1), id)
+	}
+	// the right bound of a keyspace is the left bound of the next one.
+	id, ok := KeyspaceOfKey(ks1.TxnRightBound)
+	re.True(ok)
+	re.Equal(uint32(2), id)
+	re.Equal(ks2.TxnLeftBound, ks1.TxnRightBound)
+	_, ok = KeyspaceOfKey([]byte("t"))
+	re.False(ok)
+	_, ok = KeyspaceOfKey(nil)
+	re.False(ok)
+}
@@ -18,9 +18,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"time"
 
+	"github.com/pingcap/errors"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/statistics/buckets"
 	"github.com/tikv/pd/pkg/statistics/utils"
@@ -223,33 +228,194 @@ func (h *hotStatusHandler) GetHotBuckets(w http.ResponseWriter, r *http.Request)
 
 // @Tags     hotspot
 // @Summary  List the history hot regions.
+// @Description  The request is read from the query if any, otherwise from the body.
 // @Accept   json
+// @Param    start      query  integer  false  "The start time in unix milliseconds"
+// @Param    end        query  integer  false  "The end time in unix milliseconds, now by default"
+// @Param    store      query  integer  false  "The store ID, which can be repeated"
+// @Param    region     query  integer  false  "The region ID, which can be repeated"
+// @Param    type       query  string   false  "The hot region type, read or write"
+// @Param    aggregate  query  string   false  "Aggregate the history hot regions by region or store"
 // @Produce  json
 // @Success  200  {object}  storage.HistoryHotRegions
+// @Success  200  {object}  HistoryHotRegionsAggregation
 // @Failure  400  {string}  string  "The input is invalid."
 // @Failure  500  {string}  string  "PD server failed to proceed the request."
 // @Router   /hotspot/regions/history [get]
 func (h *hotStatusHandler) GetHistoryHotRegions(w http.ResponseWriter, r *http.Request) {
-	data, err := io.ReadAll(r.Body)
-	r.Body.Close()
-	if err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-	historyHotRegionsRequest := &HistoryHotRegionsRequest{}
-	err = json.Unmarshal(data, historyHotRegionsRequest)
-	if err != nil {
-		h.rd.JSON(w, http.StatusBadRequest, err.Error())
-		return
+	var (
+		historyHotRegionsRequest *HistoryHotRegionsRequest
+		aggregate                string
+		err                      error
+	)
+	if query := r.URL.Query(); len(query) > 0 {
+		historyHotRegionsRequest, aggregate, err = parseHistoryHotRegionsQuery(query, time.Now())
+		if err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		data, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		historyHotRegionsRequest = &HistoryHotRegionsRequest{}
+		err = json.Unmarshal(data, historyHotRegionsRequest)
+		if err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 	results, err := getAllRequestHistoryHotRegion(h.Handler, historyHotRegionsRequest)
 	if err != nil {
 		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	if aggregate != "" {
+		h.rd.JSON(w, http.StatusOK, aggregateHistoryHotRegions(results.HistoryHotRegion, aggregate))
+		return
+	}
 	h.rd.JSON(w, http.StatusOK, results)
 }
 
+// parseHistoryHotRegionsQuery parses the request of the history hot regions
+// and the way to aggregate them from the query.
+func parseHistoryHotRegionsQuery(query url.Values, now time.Time) (*HistoryHotRegionsRequest, string, error) {
+	request := &HistoryHotRegionsRequest{
+		EndTime:    now.UnixMilli(),
+		IsLearners: []bool{false, true},
+		IsLeaders:  []bool{false, true},
+	}
+	var err error
+	if start := query.Get("start"); start != "" {
+		if request.StartTime, err = strconv.ParseInt(start, 10, 64); err != nil {
+			return nil, "", errors.Errorf("invalid start time: %s", start)
+		}
+	}
+	if end := query.Get("end"); end != "" {
+		if request.EndTime, err = strconv.ParseInt(end, 10, 64); err != nil {
+			return nil, "", errors.Errorf("invalid end time: %s", end)
+		}
+	}
+	if request.StartTime > request.EndTime {
+		return nil, "", errors.New("start time should not be later than end time")
+	}
+	if request.StoreIDs, err = parseUint64Query(query, "store"); err != nil {
+		return nil, "", err
+	}
+	if request.RegionIDs, err = parseUint64Query(query, "region"); err != nil {
+		return nil, "", err
+	}
+	for _, typ := range query["type"] {
+		if typ != storage.ReadType.String() && typ != storage.WriteType.String() {
+			return nil, "", errors.Errorf("invalid hot region type: %s", typ)
+		}
+		request.HotRegionTypes = append(request.HotRegionTypes, typ)
+	}
+	aggregate := query.Get("aggregate")
+	if aggregate != "" && aggregate != aggregateByRegion && aggregate != aggregateByStore {
+		return nil, "", errors.Errorf("invalid aggregate: %s", aggregate)
+	}
+	return request, aggregate, nil
+}
+
+func parseUint64Query(query url.Values, key string) ([]uint64, error) {
+	var ids []uint64
+	for _, value := range query[key] {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid %s id: %s", key, value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+const (
+	aggregateByRegion = "region"
+	aggregateByStore  = "store"
+)
+
+// HistoryHotRegionsAggregation is the aggregation of the history hot regions.
+type HistoryHotRegionsAggregation struct {
+	Aggregate string                  `json:"aggregate"`
+	Stats     []*HistoryHotRegionStat `json:"stats"`
+}
+
+// HistoryHotRegionStat is the aggregated stat of the history hot regions of a
+// region or a store, the read and write ones are aggregated separately.
+type HistoryHotRegionStat struct {
+	RegionID      uint64  `json:"region_id,omitempty"`
+	StoreID       uint64  `json:"store_id,omitempty"`
+	HotRegionType string  `json:"hot_region_type"`
+	Count         int     `json:"count"`
+	FirstTime     int64   `json:"first_time"`
+	LastTime      int64   `json:"last_time"`
+	MaxHotDegree  int64   `json:"max_hot_degree"`
+	MaxFlowBytes  float64 `json:"max_flow_bytes"`
+	AvgFlowBytes  float64 `json:"avg_flow_bytes"`
+	MaxKeyRate    float64 `json:"max_key_rate"`
+	AvgKeyRate    float64 `json:"avg_key_rate"`
+	MaxQueryRate  float64 `json:"max_query_rate"`
+	AvgQueryRate  float64 `json:"avg_query_rate"`
+}
+
+// aggregateHistoryHotRegions aggregates the history hot regions by region or
+// store. The stats are sorted by the max flow bytes, the hottest first.
+func aggregateHistoryHotRegions(regions []*storage.HistoryHotRegion, aggregate string) *HistoryHotRegionsAggregation {
+	type statKey struct {
+		id  uint64
+		typ string
+	}
+	statMap := make(map[statKey]*HistoryHotRegionStat)
+	stats := make([]*HistoryHotRegionStat, 0)
+	for _, region := range regions {
+		key := statKey{id: region.RegionID, typ: region.HotRegionType}
+		if aggregate == aggregateByStore {
+			key.id = region.StoreID
+		}
+		stat, ok := statMap[key]
+		if !ok {
+			stat = &HistoryHotRegionStat{HotRegionType: region.HotRegionType, FirstTime: region.UpdateTime}
+			if aggregate == aggregateByStore {
+				stat.StoreID = region.StoreID
+			} else {
+				stat.RegionID = region.RegionID
+			}
+			statMap[key] = stat
+			stats = append(stats, stat)
+		}
+		stat.Count++
+		if region.UpdateTime < stat.FirstTime {
+			stat.FirstTime = region.UpdateTime
+		}
+		if region.UpdateTime > stat.LastTime {
+			stat.LastTime = region.UpdateTime
+		}
+		if region.HotDegree > stat.MaxHotDegree {
+			stat.MaxHotDegree = region.HotDegree
+		}
+		stat.MaxFlowBytes = math.Max(stat.MaxFlowBytes, region.FlowBytes)
+		stat.MaxKeyRate = math.Max(stat.MaxKeyRate, region.KeyRate)
+		stat.MaxQueryRate = math.Max(stat.MaxQueryRate, region.QueryRate)
+		// The sums are divided by the count below.
+		stat.AvgFlowBytes += region.FlowBytes
+		stat.AvgKeyRate += region.KeyRate
+		stat.AvgQueryRate += region.QueryRate
+	}
+	for _, stat := range stats {
+		stat.AvgFlowBytes /= float64(stat.Count)
+		stat.AvgKeyRate /= float64(stat.Count)
+		stat.AvgQueryRate /= float64(stat.Count)
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].MaxFlowBytes > stats[j].MaxFlowBytes
+	})
+	return &HistoryHotRegionsAggregation{Aggregate: aggregate, Stats: stats}
+}
+
 func getAllRequestHistoryHotRegion(handler *server.Handler, request *HistoryHotRegionsRequest) (*storage.HistoryHotRegions, error) {
 	var hotRegionTypes = storage.HotRegionTypes
 	if len(request.HotRegionTypes) != 0 {
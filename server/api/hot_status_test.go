@@ -192,6 +192,50 @@ func (suite *hotStatusTestSuite) TestGetHistoryHotRegionsIDAndTypes() {
 	suite.NoError(err)
 }
 
+func (suite *hotStatusTestSuite) TestGetHistoryHotRegionsByQuery() {
+	re := suite.Require()
+	hotRegionStorage := suite.svr.GetHistoryHotRegionStorage()
+	start := time.Now().Add(time.Hour).UnixMilli()
+	hotRegions := []*storage.HistoryHotRegion{
+		{RegionID: 1, StoreID: 1, HotRegionType: "write", FlowBytes: 100, UpdateTime: start},
+		{RegionID: 1, StoreID: 1, HotRegionType: "write", FlowBytes: 300, UpdateTime: start + 1000},
+		{RegionID: 2, StoreID: 1, HotRegionType: "write", FlowBytes: 500, UpdateTime: start + 2000},
+		{RegionID: 3, StoreID: 2, HotRegionType: "read", FlowBytes: 700, UpdateTime: start + 3000},
+	}
+	suite.NoError(writeToDB(hotRegionStorage.LevelDBKV, hotRegions))
+	prefix := fmt.Sprintf("%s/regions/history?start=%d&end=%d", suite.urlPrefix, start, start+3000)
+
+	historyHotRegions := &storage.HistoryHotRegions{}
+	suite.NoError(tu.ReadGetJSON(re, testDialClient, prefix+"&store=1", historyHotRegions))
+	suite.Len(historyHotRegions.HistoryHotRegion, 3)
+	historyHotRegions = &storage.HistoryHotRegions{}
+	suite.NoError(tu.ReadGetJSON(re, testDialClient, prefix+"&type=read", historyHotRegions))
+	suite.Len(historyHotRegions.HistoryHotRegion, 1)
+	suite.Equal(uint64(3), historyHotRegions.HistoryHotRegion[0].RegionID)
+
+	aggregation := &HistoryHotRegionsAggregation{}
+	suite.NoError(tu.ReadGetJSON(re, testDialClient, prefix+"&store=1&aggregate=region", aggregation))
+	suite.Len(aggregation.Stats, 2)
+	suite.Equal(uint64(2), aggregation.Stats[0].RegionID)
+	stat := aggregation.Stats[1]
+	suite.Equal(uint64(1), stat.RegionID)
+	suite.Equal(2, stat.Count)
+	suite.Equal(start, stat.FirstTime)
+	suite.Equal(start+1000, stat.LastTime)
+	suite.Equal(300.0, stat.MaxFlowBytes)
+	suite.Equal(200.0, stat.AvgFlowBytes)
+	aggregation = &HistoryHotRegionsAggregation{}
+	suite.NoError(tu.ReadGetJSON(re, testDialClient, prefix+"&aggregate=store", aggregation))
+	suite.Len(aggregation.Stats, 2)
+	suite.Equal(uint64(2), aggregation.Stats[0].StoreID)
+	suite.Equal(uint64(1), aggregation.Stats[1].StoreID)
+	suite.Equal(3, aggregation.Stats[1].Count)
+
+	for _, query := range []string{"start=err", "store=err", "type=err", "aggregate=err", fmt.Sprintf("start=%d&end=%d", start, start-1)} {
+		suite.NoError(tu.CheckGetJSON(testDialClient, suite.urlPrefix+"/regions/history?"+query, nil, tu.StatusNotOK(re)))
+	}
+}
+
 func writeToDB(kv *kv.LevelDBKV, hotRegions []*storage.HistoryHotRegion) error {
 	batch := new(leveldb.Batch)
 	for _, region := range hotRegions {
@@ -390,7 +390,7 @@ func (suite *transferRegionOperatorTestSuite) TestTransferRegionWithPlacementRul
 			// add customized rule first and then remove default rule
 			err := suite.svr.GetRaftCluster().GetRuleManager().SetRules(testCase.rules)
 			suite.NoError(err)
-			err = suite.svr.GetRaftCluster().GetRuleManager().DeleteRule("pd", "default")
+			err = suite.svr.GetRaftCluster().GetRuleManager().DeleteRule("pd", "default", true)
 			suite.NoError(err)
 		}
 		var err error
@@ -33,10 +33,10 @@ import (
 	"github.com/pingcap/kvproto/pkg/replication_modepb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/core"
-	"github.com/tikv/pd/pkg/keyspace"
 	"github.com/tikv/pd/pkg/schedule/filter"
 	"github.com/tikv/pd/pkg/statistics"
 	"github.com/tikv/pd/pkg/utils/apiutil"
+	"github.com/tikv/pd/pkg/utils/keyspaceutil"
 	"github.com/tikv/pd/pkg/utils/typeutil"
 	"github.com/tikv/pd/server"
 	"github.com/unrolled/render"
@@ -508,7 +508,7 @@ func (h *regionsHandler) GetKeyspaceRegions(w http.ResponseWriter, r *http.Reque
 	if limit > maxRegionLimit {
 		limit = maxRegionLimit
 	}
-	regionBound := keyspace.MakeRegionBound(keyspaceID)
+	regionBound := keyspaceutil.MakeRegionBound(keyspaceID)
 	regions := rc.ScanRegions(regionBound.RawLeftBound, regionBound.RawRightBound, limit)
 	if limit <= 0 || limit > len(regions) {
 		txnRegion := rc.ScanRegions(regionBound.TxnLeftBound, regionBound.TxnRightBound, limit-len(regions))
@@ -76,6 +76,26 @@ func (h *regionLabelHandler) PatchRegionLabelRules(w http.ResponseWriter, r *htt
 	h.rd.JSON(w, http.StatusOK, "Update region label rules successfully.")
 }
 
+// @Tags     region_label
+// @Summary  Validate a patch of region label rules without applying it.
+// @Accept   json
+// @Param    patch  body  labeler.LabelRulePatch  true  "Patch to validate"
+// @Produce  json
+// @Success  200  {array}   labeler.LabelRuleError
+// @Failure  400  {string}  string  "The input is invalid."
+// @Router   /config/region-label/rules/validate [post]
+func (h *regionLabelHandler) ValidateRegionLabelRules(w http.ResponseWriter, r *http.Request) {
+	var patch labeler.LabelRulePatch
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &patch); err != nil {
+		return
+	}
+	problems := labeler.ValidatePatch(patch)
+	if problems == nil {
+		problems = []*labeler.LabelRuleError{}
+	}
+	h.rd.JSON(w, http.StatusOK, problems)
+}
+
 // @Tags     region_label
 // @Summary  Get label rules of cluster by ids.
 // @Param    body  body  []string  true  "IDs of query rules"
@@ -173,6 +193,72 @@ func (h *regionLabelHandler) SetRegionLabelRule(w http.ResponseWriter, r *http.R
 	h.rd.JSON(w, http.StatusOK, "Update region label rule successfully.")
 }
 
+// @Tags     region_label
+// @Summary  List the overlapping ranges of the key-range label rules, and the labels dropped by the overlap policy.
+// @Produce  json
+// @Success  200  {array}  labeler.LabelOverlap
+// @Router   /config/region-label/overlaps [get]
+func (h *regionLabelHandler) GetRegionLabelRuleOverlaps(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	overlaps := cluster.GetRegionLabeler().DetectOverlaps()
+	if overlaps == nil {
+		overlaps = []labeler.LabelOverlap{}
+	}
+	h.rd.JSON(w, http.StatusOK, overlaps)
+}
+
+// @Tags     region_label
+// @Summary  List the recent region labels removed since they expired.
+// @Produce  json
+// @Success  200  {array}  labeler.ExpiredLabels
+// @Router   /config/region-label/rules/expired [get]
+func (h *regionLabelHandler) GetExpiredRegionLabels(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	expired := cluster.GetRegionLabeler().GetExpiredLabels()
+	if expired == nil {
+		expired = []*labeler.ExpiredLabels{}
+	}
+	h.rd.JSON(w, http.StatusOK, expired)
+}
+
+// LabelRuleMatch is the regions a label rule would match.
+type LabelRuleMatch struct {
+	RegionIDs        []uint64 `json:"region_ids"`
+	MatchedCount     int      `json:"matched_count"`
+	TotalRegionCount int      `json:"total_region_count"`
+}
+
+// @Tags     region_label
+// @Summary  Get the regions a label rule would match without setting it.
+// @Accept   json
+// @Param    rule  body  labeler.LabelRule  true  "Parameters of label rule"
+// @Produce  json
+// @Success  200  {object}  LabelRuleMatch
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/region-label/rule/dry-match [post]
+func (h *regionLabelHandler) DryMatchRegionLabelRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	var rule labeler.LabelRule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+		return
+	}
+	ids, err := cluster.GetRegionLabeler().DryMatch(&rule)
+	if err != nil {
+		if errs.ErrRegionRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, &LabelRuleMatch{
+		RegionIDs:        ids,
+		MatchedCount:     len(ids),
+		TotalRegionCount: cluster.GetTotalRegionCount(),
+	})
+}
+
 // @Tags     region_label
 // @Summary  Get label of a region.
 // @Param    id   path  integer  true  "Region Id"
@@ -222,3 +308,26 @@ func (h *regionLabelHandler) GetRegionLabels(w http.ResponseWriter, r *http.Requ
 	labels := cluster.GetRegionLabeler().GetRegionLabels(region)
 	h.rd.JSON(w, http.StatusOK, labels)
 }
+
+// @Tags     region_label
+// @Summary  Get all the label rules matching a region, with the labels of each rule taking effect on the region.
+// @Param    id  path  integer  true  "Region Id"
+// @Produce  json
+// @Success  200  {array}   labeler.MatchedLabelRule
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  404  {string}  string  "The region does not exist."
+// @Router   /region/{id}/labels/rules [get]
+func (h *regionLabelHandler) GetRegionLabelRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	regionID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	region := cluster.GetRegion(regionID)
+	if region == nil {
+		h.rd.JSON(w, http.StatusNotFound, nil)
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRegionLabeler().GetMatchedLabelRules(region))
+}
@@ -714,7 +714,7 @@ func (suite *regionsReplicatedTestSuite) TestCheckRegionsReplicated() {
 	// test one rule
 	data, err := json.Marshal(bundle)
 	suite.NoError(err)
-	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule", data, tu.StatusOK(re))
+	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule?force", data, tu.StatusOK(re))
 	suite.NoError(err)
 
 	err = tu.ReadGetJSON(re, testDialClient, url, &status)
@@ -736,7 +736,7 @@ func (suite *regionsReplicatedTestSuite) TestCheckRegionsReplicated() {
 	})
 	data, err = json.Marshal(bundle)
 	suite.NoError(err)
-	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule", data, tu.StatusOK(re))
+	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule?force", data, tu.StatusOK(re))
 	suite.NoError(err)
 
 	err = tu.ReadGetJSON(re, testDialClient, url, &status)
@@ -755,7 +755,7 @@ func (suite *regionsReplicatedTestSuite) TestCheckRegionsReplicated() {
 	})
 	data, err = json.Marshal(bundle)
 	suite.NoError(err)
-	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule", data, tu.StatusOK(re))
+	err = tu.CheckPostJSON(testDialClient, suite.urlPrefix+"/config/placement-rule?force", data, tu.StatusOK(re))
 	suite.NoError(err)
 
 	err = tu.ReadGetJSON(re, testDialClient, url, &status)
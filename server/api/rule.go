@@ -17,11 +17,13 @@ package api
 import (
 	"encoding/hex"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/pingcap/errcode"
 	"github.com/pingcap/errors"
 	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/errs"
@@ -62,12 +64,105 @@ func (h *ruleHandler) GetAllRules(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, rules)
 }
 
+// @Tags     rule
+// @Summary  List all rules whose label constraints can not match any store.
+// @Produce  json
+// @Success  200  {array}   placement.Rule
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/unsatisfiable [get]
+func (h *ruleHandler) GetUnsatisfiableRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	rules := cluster.GetRuleManager().GetUnsatisfiableRules()
+	h.rd.JSON(w, http.StatusOK, rules)
+}
+
+// @Tags     rule
+// @Summary  List the number of regions matching each rule and the ones satisfying it.
+// @Produce  json
+// @Success  200  {object}  map[string]placement.RuleStats
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/usage [get]
+func (h *ruleHandler) GetRuleUsage(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().RuleUsage())
+}
+
+// @Tags     rule
+// @Summary  List the rules applied to each key range after the overrides are resolved, and the suppressed ones.
+// @Produce  json
+// @Success  200  {array}   placement.EffectiveRange
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/effective [get]
+func (h *ruleHandler) GetEffectiveRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().EffectiveRules())
+}
+
+// @Tags     rule
+// @Summary  List the pairs of rules which are applied to the overlapping range but can not be satisfied at the same time.
+// @Produce  json
+// @Success  200  {array}   placement.RuleConflict
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/conflicts [get]
+func (h *ruleHandler) GetRuleConflicts(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	conflicts := cluster.GetRuleManager().CheckConflicts()
+	h.rd.JSON(w, http.StatusOK, conflicts)
+}
+
+// @Tags     rule
+// @Summary  Analyze the whole rule configuration, and report the conflicting rule pairs, the groups needing more peers than the alive stores and the rules which can not match any store.
+// @Produce  json
+// @Success  200  {object}  placement.RuleConflictReport
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/conflicts/report [get]
+func (h *ruleHandler) GetRuleConflictReport(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().CheckRuleConflicts())
+}
+
+// @Tags     rule
+// @Summary  Get the summary of the health of the rules, which is cheap enough to be polled frequently.
+// @Produce  json
+// @Success  200  {object}  placement.RuleHealth
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/health [get]
+func (h *ruleHandler) GetRuleHealth(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().HealthSummary())
+}
+
 // @Tags     rule
 // @Summary  Set all rules for the cluster. If there is an error, modifications are promised to be rollback in memory, but may fail to rollback disk. You probably want to request again to make rules in memory/disk consistent.
 // @Produce  json
 // @Param    rules  body      []placement.Rule  true  "Parameters of rules"
 // @Success  200    {string}  string            "Update rules successfully."
 // @Failure  400    {string}  string            "The input is invalid."
+// @Failure  403    {string}  string            "The rule group is frozen."
 // @Failure  412    {string}  string            "Placement rules feature is disabled."
 // @Failure  500    {string}  string            "PD server failed to proceed the request."
 // @Router   /config/rules [post]
@@ -82,6 +177,10 @@ func (h *ruleHandler) SetAllRules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	for _, v := range rules {
+		if g := cluster.GetRuleManager().GetRuleGroup(v.GroupID); g != nil && g.Frozen {
+			h.rd.JSON(w, http.StatusForbidden, errs.ErrRuleGroupFrozen.FastGenByArgs(v.GroupID).Error())
+			return
+		}
 		if err := h.syncReplicateConfigWithDefaultRule(v); err != nil {
 			h.rd.JSON(w, http.StatusBadRequest, err.Error())
 			return
@@ -91,6 +190,8 @@ func (h *ruleHandler) SetAllRules(w http.ResponseWriter, r *http.Request) {
 		SetRules(rules); err != nil {
 		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
 			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
 		} else {
 			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		}
@@ -153,6 +254,46 @@ func (h *ruleHandler) CheckRegionPlacementRule(w http.ResponseWriter, r *http.Re
 	h.rd.JSON(w, http.StatusOK, regionFit)
 }
 
+// @Tags     rule
+// @Summary  Explain how the peers of the given region fit the rules: the peers matched by each rule, the label constraints failed by the other peers and why the orphan peers exist.
+// @Param    id  path  integer  true  "Region Id"
+// @Produce  json
+// @Success  200  {object}  placement.RegionFitExplanation
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  404  {string}  string  "The region does not exist."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /region/{id}/rule-fit/explain [get]
+func (h *ruleHandler) ExplainRegionRuleFit(w http.ResponseWriter, r *http.Request) {
+	cluster, region := h.preCheckForRegionAndRule(w, r)
+	if cluster == nil || region == nil {
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().ExplainRegionFit(cluster, region))
+}
+
+// @Tags     rule
+// @Summary  List rules applied to the given region in the priority order and whether they are satisfied.
+// @Param    id  path  integer  true  "Region Id"
+// @Produce  json
+// @Success  200  {array}   placement.RegionRuleStatus
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  404  {string}  string  "The region does not exist."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/region/{region}/coverage [get]
+func (h *ruleHandler) GetRegionRuleCoverage(w http.ResponseWriter, r *http.Request) {
+	cluster, region := h.preCheckForRegionAndRule(w, r)
+	if cluster == nil || region == nil {
+		return
+	}
+	rules, err := cluster.GetRuleManager().RulesForRegion(region.GetID())
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, rules)
+}
+
 func (h *ruleHandler) preCheckForRegionAndRule(w http.ResponseWriter, r *http.Request) (*cluster.RaftCluster, *core.RegionInfo) {
 	cluster := getCluster(r)
 	if !cluster.GetOpts().IsPlacementRulesEnabled() {
@@ -224,10 +365,14 @@ func (h *ruleHandler) GetRuleByGroupAndID(w http.ResponseWriter, r *http.Request
 // @Tags     rule
 // @Summary  Update rule of cluster.
 // @Accept   json
-// @Param    rule  body  placement.Rule  true  "Parameters of rule"
+// @Param    rule             body    placement.Rule  true   "Parameters of rule"
+// @Param    force            query   boolean         false  "Whether to update the rule even if it makes some regions unschedulable"
+// @Param    Idempotency-Key  header  string          false  "The key to make the retries of the request safe"
 // @Produce  json
 // @Success  200  {string}  string  "Update rule successfully."
 // @Failure  400  {string}  string  "The input is invalid."
+// @Failure  403  {string}  string  "The rule group is frozen."
+// @Failure  409  {string}  string  "The rule makes some regions unschedulable, or the idempotency key is reused by a different rule."
 // @Failure  412  {string}  string  "Placement rules feature is disabled."
 // @Failure  500  {string}  string  "PD server failed to proceed the request."
 // @Router   /config/rule [post]
@@ -237,19 +382,37 @@ func (h *ruleHandler) SetRule(w http.ResponseWriter, r *http.Request) {
 		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
 		return
 	}
-	var rule placement.Rule
-	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// reject the unknown fields, which are likely to be typos.
+	rule, err := placement.NewRuleFromJSONStrict(data)
+	if err != nil {
+		apiutil.ErrorResp(h.rd, w, errcode.NewInvalidInputErr(err))
+		return
+	}
+	// check the frozen group before syncing the replication config.
+	if g := cluster.GetRuleManager().GetRuleGroup(rule.GroupID); g != nil && g.Frozen {
+		h.rd.JSON(w, http.StatusForbidden, errs.ErrRuleGroupFrozen.FastGenByArgs(rule.GroupID).Error())
 		return
 	}
 	oldRule := cluster.GetRuleManager().GetRule(rule.GroupID, rule.ID)
-	if err := h.syncReplicateConfigWithDefaultRule(&rule); err != nil {
+	if err := h.syncReplicateConfigWithDefaultRule(rule); err != nil {
 		h.rd.JSON(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	_, force := r.URL.Query()["force"]
 	if err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).
-		SetRule(&rule); err != nil {
+		SetRuleWithIdempotencyKey(rule, r.Header.Get(apiutil.IdempotencyKeyHeader), force); err != nil {
 		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
 			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else if errs.ErrRuleUnschedulable.Equal(err) || errs.ErrIdempotencyKeyReused.Equal(err) {
+			h.rd.JSON(w, http.StatusConflict, err.Error())
 		} else {
 			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		}
@@ -262,6 +425,133 @@ func (h *ruleHandler) SetRule(w http.ResponseWriter, r *http.Request) {
 	h.rd.JSON(w, http.StatusOK, "Update rule successfully.")
 }
 
+// @Tags     rule
+// @Summary  Preview the impact of updating a rule without applying it.
+// @Accept   json
+// @Param    rule  body  placement.Rule  true  "Parameters of rule"
+// @Produce  json
+// @Success  200  {object}  placement.RulePreview
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rule/preview [post]
+func (h *ruleHandler) PreviewRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var rule placement.Rule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+		return
+	}
+	preview, err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).PreviewRule(&rule)
+	if err != nil {
+		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, preview)
+}
+
+// @Tags     rule
+// @Summary  Preview the peer movements of updating a batch of rules across the current regions without applying them.
+// @Accept   json
+// @Param    rules  body  []placement.Rule  true  "Parameters of rules"
+// @Produce  json
+// @Success  200  {object}  placement.RulesPreview
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/preview [post]
+func (h *ruleHandler) PreviewRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var rules []*placement.Rule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rules); err != nil {
+		return
+	}
+	preview, err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).PreviewRules(rules)
+	if err != nil {
+		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, preview)
+}
+
+// @Tags     rule
+// @Summary  List the stores which can host a peer of the rule right now.
+// @Accept   json
+// @Param    rule                 body   placement.Rule  true   "Parameters of rule"
+// @Param    include_unavailable  query  boolean         false  "Whether to include the offline and the tombstone stores"
+// @Produce  json
+// @Success  200  {array}   integer  "The IDs of the stores."
+// @Failure  400  {string}  string   "The input is invalid."
+// @Failure  412  {string}  string   "Placement rules feature is disabled."
+// @Failure  500  {string}  string   "PD server failed to proceed the request."
+// @Router   /config/rule/matching-stores [post]
+func (h *ruleHandler) GetMatchingStores(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var rule placement.Rule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+		return
+	}
+	var opts []placement.MatchingStoresOption
+	if _, ok := r.URL.Query()["include_unavailable"]; ok {
+		opts = append(opts, placement.WithUnavailableStores())
+	}
+	ids, err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).MatchingStores(&rule, opts...)
+	if err != nil {
+		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, ids)
+}
+
+// @Tags     rule
+// @Summary  Validate a rule without applying it, and list all the problems of it.
+// @Accept   json
+// @Param    rule  body  placement.Rule  true  "Parameters of rule"
+// @Produce  json
+// @Success  200  {array}   string  "The problems of the rule, empty if it's valid."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rule/validate [post]
+func (h *ruleHandler) ValidateRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	var rule placement.Rule
+	if err := apiutil.ReadJSONRespondError(h.rd, w, r.Body, &rule); err != nil {
+		return
+	}
+	problems := make([]string, 0)
+	for _, err := range placement.ValidateRule(&rule) {
+		problems = append(problems, err.Error())
+	}
+	h.rd.JSON(w, http.StatusOK, problems)
+}
+
 // sync replicate config with default-rule
 func (h *ruleHandler) syncReplicateConfigWithDefaultRule(rule *placement.Rule) error {
 	// sync default rule with replicate config
@@ -278,9 +568,13 @@ func (h *ruleHandler) syncReplicateConfigWithDefaultRule(rule *placement.Rule) e
 // @Tags     rule
 // @Summary  Delete rule of cluster.
 // @Param    group  path  string  true  "The name of group"
-// @Param    id     path  string  true  "Rule Id"
+// @Param    id     path   string   true   "Rule Id"
+// @Param    force                query  boolean  false  "Whether to delete the default rule"
+// @Param    allow_unschedulable  query  boolean  false  "Whether to delete the rule even if it makes some regions unschedulable"
 // @Produce  json
 // @Success  200  {string}  string  "Delete rule successfully."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is deleted without force."
+// @Failure  409  {string}  string  "The deletion makes some regions unschedulable without allow_unschedulable."
 // @Failure  412  {string}  string  "Placement rules feature is disabled."
 // @Failure  500  {string}  string  "PD server failed to proceed the request."
 // @Router   /config/rule/{group}/{id} [delete]
@@ -292,8 +586,19 @@ func (h *ruleHandler) DeleteRuleByGroup(w http.ResponseWriter, r *http.Request)
 	}
 	group, id := mux.Vars(r)["group"], mux.Vars(r)["id"]
 	rule := cluster.GetRuleManager().GetRule(group, id)
-	if err := cluster.GetRuleManager().DeleteRule(group, id); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	_, force := r.URL.Query()["force"]
+	deleteRule := cluster.GetRuleManager().DeleteRule
+	if _, ok := r.URL.Query()["allow_unschedulable"]; ok {
+		deleteRule = cluster.GetRuleManager().ForceDeleteRule
+	}
+	if err := deleteRule(group, id, force); err != nil {
+		if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else if errs.ErrRuleUnschedulable.Equal(err) {
+			h.rd.JSON(w, http.StatusConflict, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 	if rule != nil {
@@ -303,12 +608,97 @@ func (h *ruleHandler) DeleteRuleByGroup(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, "Delete rule successfully.")
 }
 
+// @Tags     rule
+// @Summary  Reinstate the built-in default rule with the current max replicas and location labels.
+// @Produce  json
+// @Success  200  {string}  string  "Restore the default rule successfully."
+// @Failure  403  {string}  string  "The rule group is frozen."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/default/restore [post]
+func (h *ruleHandler) RestoreDefaultRule(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	if err := cluster.GetRuleManager().RestoreDefaultRule(); err != nil {
+		if errs.ErrRuleGroupFrozen.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	cluster.AddSuspectKeyRange(nil, nil)
+	h.rd.JSON(w, http.StatusOK, "Restore the default rule successfully.")
+}
+
+// @Tags     rule
+// @Summary  Enable a disabled rule, so it's enforced again.
+// @Param    group  path  string  true  "The name of group"
+// @Param    id     path  string  true  "Rule Id"
+// @Produce  json
+// @Success  200  {string}  string  "Enable rule successfully."
+// @Failure  403  {string}  string  "The rule group is frozen."
+// @Failure  404  {string}  string  "The rule does not exist."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rule/{group}/{id}/enable [post]
+func (h *ruleHandler) EnableRule(w http.ResponseWriter, r *http.Request) {
+	h.setRuleEnabled(w, r, true)
+}
+
+// @Tags     rule
+// @Summary  Disable a rule without deleting it, the rule is kept but not enforced.
+// @Param    group  path  string  true  "The name of group"
+// @Param    id     path  string  true  "Rule Id"
+// @Produce  json
+// @Success  200  {string}  string  "Disable rule successfully."
+// @Failure  403  {string}  string  "The rule group is frozen."
+// @Failure  404  {string}  string  "The rule does not exist."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rule/{group}/{id}/disable [post]
+func (h *ruleHandler) DisableRule(w http.ResponseWriter, r *http.Request) {
+	h.setRuleEnabled(w, r, false)
+}
+
+func (h *ruleHandler) setRuleEnabled(w http.ResponseWriter, r *http.Request, enabled bool) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	group, id := mux.Vars(r)["group"], mux.Vars(r)["id"]
+	if err := cluster.GetRuleManager().SetRuleEnabled(group, id, enabled); err != nil {
+		switch {
+		case errs.ErrRuleNotFound.Equal(err):
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		case errs.ErrRuleGroupFrozen.Equal(err):
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		default:
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	if rule := cluster.GetRuleManager().GetRule(group, id); rule != nil {
+		cluster.AddSuspectKeyRange(rule.StartKey, rule.EndKey)
+	}
+	if enabled {
+		h.rd.JSON(w, http.StatusOK, "Enable rule successfully.")
+	} else {
+		h.rd.JSON(w, http.StatusOK, "Disable rule successfully.")
+	}
+}
+
 // @Tags     rule
 // @Summary  Batch operations for the cluster. Operations should be independent(different ID). If there is an error, modifications are promised to be rollback in memory, but may fail to rollback disk. You probably want to request again to make rules in memory/disk consistent.
 // @Produce  json
 // @Param    operations  body      []placement.RuleOp  true  "Parameters of rule operations"
 // @Success  200         {string}  string              "Batch operations successfully."
 // @Failure  400         {string}  string              "The input is invalid."
+// @Failure  403         {string}  string              "The rule group is frozen, or the default rule is deleted."
 // @Failure  412         {string}  string              "Placement rules feature is disabled."
 // @Failure  500         {string}  string              "PD server failed to proceed the request."
 // @Router   /config/rules/batch [post]
@@ -326,6 +716,8 @@ func (h *ruleHandler) BatchRules(w http.ResponseWriter, r *http.Request) {
 		Batch(opts); err != nil {
 		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
 			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
 		} else {
 			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		}
@@ -378,7 +770,11 @@ func (h *ruleHandler) SetGroupConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := cluster.GetRuleManager().SetRuleGroup(&ruleGroup); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		if errs.ErrRuleContent.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 	for _, r := range cluster.GetRuleManager().GetRulesByGroup(ruleGroup.ID) {
@@ -448,9 +844,11 @@ func (h *ruleHandler) GetPlacementRules(w http.ResponseWriter, r *http.Request)
 // @Tags     rule
 // @Summary  Update all rules and groups configuration.
 // @Param    partial  query  bool  false  "if partially update rules"  default(false)
+// @Param    force    query  bool  false  "Whether to drop the default rule"  default(false)
 // @Produce  json
 // @Success  200  {string}  string  "Update rules and groups successfully."
 // @Failure  400  {string}  string  "The input is invalid."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is dropped without force."
 // @Failure  412  {string}  string  "Placement rules feature is disabled."
 // @Failure  500  {string}  string  "PD server failed to proceed the request."
 // @Router   /config/placement-rule [post]
@@ -465,10 +863,13 @@ func (h *ruleHandler) SetPlacementRules(w http.ResponseWriter, r *http.Request)
 		return
 	}
 	_, partial := r.URL.Query()["partial"]
+	_, force := r.URL.Query()["force"]
 	if err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).
-		SetAllGroupBundles(groups, !partial); err != nil {
+		SetAllGroupBundles(groups, !partial, force); err != nil {
 		if errs.ErrRuleContent.Equal(err) || errs.ErrHexDecodingString.Equal(err) {
 			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
 		} else {
 			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
 		}
@@ -477,6 +878,356 @@ func (h *ruleHandler) SetPlacementRules(w http.ResponseWriter, r *http.Request)
 	h.rd.JSON(w, http.StatusOK, "Update rules and groups successfully.")
 }
 
+// @Tags     rule
+// @Summary  Export all rules and groups configuration as a versioned bundle.
+// @Produce  json
+// @Success  200  {object}  placement.RuleBundle
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/export [get]
+func (h *ruleHandler) ExportRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	data, err := cluster.GetRuleManager().Export()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.Data(w, http.StatusOK, data)
+}
+
+// @Tags     rule
+// @Summary  Get the hash of all rules and groups configuration, which is the same for semantically identical configurations.
+// @Produce  json
+// @Success  200  {string}  string  "The hash of the configuration."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Router   /config/rules/hash [get]
+func (h *ruleHandler) GetRuleConfigHash(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, cluster.GetRuleManager().ConfigHash())
+}
+
+// @Tags     rule
+// @Summary  Import the rules and groups configuration from a bundle.
+// @Accept   json
+// @Param    bundle  body   placement.RuleBundle  true   "The bundle exported by the cluster"
+// @Param    mode    query  string                false  "How to handle the existing rules and groups not in the bundle"  Enums(replace, merge)  default(replace)
+// @Produce  json
+// @Success  200  {string}  string  "Import rules and groups successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/import [post]
+func (h *ruleHandler) ImportRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	mode := placement.ImportReplace
+	if m := r.URL.Query().Get("mode"); m != "" {
+		mode = placement.ImportMode(m)
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).
+		Import(data, mode); err != nil {
+		if errs.ErrRuleBundle.Equal(err) || errs.ErrRuleContent.Equal(err) ||
+			errs.ErrHexDecodingString.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Import rules and groups successfully.")
+}
+
+// @Tags     rule
+// @Summary  Export all rules, groups and region label rules as a versioned bundle.
+// @Produce  json
+// @Success  200  {object}  placement.PlacementBundle
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/placement-bundle [get]
+func (h *ruleHandler) ExportPlacementBundle(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	data, err := placement.ExportPlacementBundle(cluster.GetRuleManager(), cluster.GetRegionLabeler())
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.Data(w, http.StatusOK, data)
+}
+
+// @Tags     rule
+// @Summary  Import the rules, groups and region label rules from a bundle atomically. The rules are validated against the store topology, and either all of the bundle takes effect or none of it does.
+// @Accept   json
+// @Param    bundle  body   placement.PlacementBundle  true   "The bundle exported by the cluster"
+// @Param    mode    query  string                     false  "How to handle the existing rules, groups and label rules not in the bundle"  Enums(replace, merge)  default(replace)
+// @Produce  json
+// @Success  200  {string}  string  "Import the placement bundle successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is deleted."
+// @Failure  409  {string}  string  "The bundle makes some regions unschedulable."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/placement-bundle [post]
+func (h *ruleHandler) ImportPlacementBundle(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	mode := placement.ImportReplace
+	if m := r.URL.Query().Get("mode"); m != "" {
+		mode = placement.ImportMode(m)
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := placement.ImportPlacementBundle(cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType),
+		cluster.GetRegionLabeler(), data, mode); err != nil {
+		if errs.ErrRuleBundle.Equal(err) || errs.ErrRuleContent.Equal(err) || errs.ErrRegionRuleContent.Equal(err) ||
+			errs.ErrHexDecodingString.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else if errs.ErrRuleUnschedulable.Equal(err) {
+			h.rd.JSON(w, http.StatusConflict, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Import the placement bundle successfully.")
+}
+
+// @Tags     rule
+// @Summary  Export the rules and the configuration of a rule group as a versioned bundle.
+// @Param    group  path  string  true  "The name of group"
+// @Produce  json
+// @Success  200  {object}  placement.RuleBundle
+// @Failure  404  {string}  string  "The rule group does not exist."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/group/{group}/export [get]
+func (h *ruleHandler) ExportRuleGroup(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	group := mux.Vars(r)["group"]
+	data, err := cluster.GetRuleManager().ExportGroup(group)
+	if err != nil {
+		if errs.ErrRuleGroupNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.Data(w, http.StatusOK, data)
+}
+
+// @Tags     rule
+// @Summary  Import the rules and the configuration of a rule group from a bundle, the other groups are not touched.
+// @Accept   json
+// @Param    group   path   string                true   "The name of group"
+// @Param    bundle  body   placement.RuleBundle  true   "The bundle exported by the cluster"
+// @Param    mode    query  string                false  "How to handle the existing rules of the group not in the bundle"  Enums(replace, merge)  default(replace)
+// @Produce  json
+// @Success  200  {string}  string  "Import the rule group successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is deleted."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/group/{group}/import [post]
+func (h *ruleHandler) ImportRuleGroup(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	group := mux.Vars(r)["group"]
+	mode := placement.ImportReplace
+	if m := r.URL.Query().Get("mode"); m != "" {
+		mode = placement.ImportMode(m)
+	}
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := cluster.GetRuleManager().SetKeyType(h.svr.GetConfig().PDServerCfg.KeyType).
+		ImportGroup(group, data, mode); err != nil {
+		if errs.ErrRuleBundle.Equal(err) || errs.ErrRuleContent.Equal(err) ||
+			errs.ErrHexDecodingString.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Import the rule group successfully.")
+}
+
+// @Tags     rule
+// @Summary  List the names of all rule snapshots.
+// @Produce  json
+// @Success  200  {array}   string
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/snapshots [get]
+func (h *ruleHandler) GetRuleSnapshots(w http.ResponseWriter, r *http.Request) {
+	names, err := getCluster(r).GetStorage().ListSnapshots()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, names)
+}
+
+// @Tags     rule
+// @Summary  Save all rules, rule groups and region label rules to a snapshot.
+// @Param    name  path  string  true  "The name of the snapshot"
+// @Produce  json
+// @Success  200  {string}  string  "Save rule snapshot successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/snapshot/{name} [post]
+func (h *ruleHandler) SaveRuleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if err := getCluster(r).SaveRuleSnapshot(mux.Vars(r)["name"]); err != nil {
+		if errs.ErrRuleSnapshotName.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Save rule snapshot successfully.")
+}
+
+// @Tags     rule
+// @Summary  Revert all rules, rule groups and region label rules to a snapshot.
+// @Param    name  path  string  true  "The name of the snapshot"
+// @Produce  json
+// @Success  200  {string}  string  "Restore rule snapshot successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  404  {string}  string  "The snapshot does not exist."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/snapshot/{name}/restore [post]
+func (h *ruleHandler) RestoreRuleSnapshot(w http.ResponseWriter, r *http.Request) {
+	if err := getCluster(r).RestoreRuleSnapshot(mux.Vars(r)["name"]); err != nil {
+		if errs.ErrRuleSnapshotName.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleSnapshotNotFound.Equal(err) {
+			h.rd.JSON(w, http.StatusNotFound, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Restore rule snapshot successfully.")
+}
+
+// @Tags     rule
+// @Summary  Compact the history of the rule storage to reclaim the space. NOTE: the whole etcd keyspace is compacted.
+// @Param    keep_revisions  query  integer  true  "The number of the latest revisions to keep"
+// @Produce  json
+// @Success  200  {string}  string  "Compact the rule storage successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/compact [post]
+func (h *ruleHandler) CompactRuleStorage(w http.ResponseWriter, r *http.Request) {
+	keepRevisions, err := strconv.Atoi(r.URL.Query().Get("keep_revisions"))
+	if err != nil || keepRevisions < 0 {
+		h.rd.JSON(w, http.StatusBadRequest, "invalid keep_revisions")
+		return
+	}
+	if err := getCluster(r).GetStorage().Compact(keepRevisions); err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Compact the rule storage successfully.")
+}
+
+// @Tags     rule
+// @Summary  Get the history of the mutations of the rules and rule groups.
+// @Produce  json
+// @Success  200  {array}   placement.RuleHistoryEntry
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/history [get]
+func (h *ruleHandler) GetRuleHistory(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	history, err := cluster.GetRuleManager().GetRuleHistory()
+	if err != nil {
+		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, history)
+}
+
+// @Tags     rule
+// @Summary  Roll back the rules and rule groups to the given revision in the history. The rollback is recorded as a new revision.
+// @Param    revision  query  integer  true  "The revision to roll back to"
+// @Produce  json
+// @Success  200  {string}  string  "Roll back the rules successfully."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is deleted."
+// @Failure  412  {string}  string  "Placement rules feature is disabled."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /config/rules/rollback [post]
+func (h *ruleHandler) RollbackRules(w http.ResponseWriter, r *http.Request) {
+	cluster := getCluster(r)
+	if !cluster.GetOpts().IsPlacementRulesEnabled() {
+		h.rd.JSON(w, http.StatusPreconditionFailed, errPlacementDisabled.Error())
+		return
+	}
+	revision, err := strconv.ParseUint(r.URL.Query().Get("revision"), 10, 64)
+	if err != nil {
+		h.rd.JSON(w, http.StatusBadRequest, "invalid revision")
+		return
+	}
+	if err := cluster.GetRuleManager().RollbackToRevision(revision); err != nil {
+		if errs.ErrRuleRevision.Equal(err) || errs.ErrRuleContent.Equal(err) || errs.ErrBuildRuleList.Equal(err) {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+		} else if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.rd.JSON(w, http.StatusOK, "Roll back the rules successfully.")
+}
+
 // @Tags     rule
 // @Summary  Get group config and all rules belong to the group.
 // @Param    group  path  string  true  "The name of group"
@@ -498,9 +1249,11 @@ func (h *ruleHandler) GetPlacementRuleByGroup(w http.ResponseWriter, r *http.Req
 // @Summary  Get group config and all rules belong to the group.
 // @Param    group   path   string  true   "The name or name pattern of group"
 // @Param    regexp  query  bool    false  "Use regular expression"  default(false)
+// @Param    force   query  bool    false  "Whether to delete the default rule"  default(false)
 // @Produce  plain
 // @Success  200  {string}  string  "Delete group and rules successfully."
 // @Failure  400  {string}  string  "Bad request."
+// @Failure  403  {string}  string  "The rule group is frozen, or the default rule is deleted without force."
 // @Failure  412  {string}  string  "Placement rules feature is disabled."
 // @Router   /config/placement-rule [delete]
 func (h *ruleHandler) DeletePlacementRuleByGroup(w http.ResponseWriter, r *http.Request) {
@@ -516,8 +1269,13 @@ func (h *ruleHandler) DeletePlacementRuleByGroup(w http.ResponseWriter, r *http.
 		return
 	}
 	_, regex := r.URL.Query()["regexp"]
-	if err := cluster.GetRuleManager().DeleteGroupBundle(group, regex); err != nil {
-		h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+	_, force := r.URL.Query()["force"]
+	if err := cluster.GetRuleManager().DeleteGroupBundle(group, regex, force); err != nil {
+		if errs.ErrRuleGroupFrozen.Equal(err) || errs.ErrDefaultRuleProtected.Equal(err) {
+			h.rd.JSON(w, http.StatusForbidden, err.Error())
+		} else {
+			h.rd.JSON(w, http.StatusInternalServerError, err.Error())
+		}
 		return
 	}
 	h.rd.JSON(w, http.StatusOK, "Delete group and rules successfully.")
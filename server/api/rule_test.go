@@ -139,6 +139,17 @@ func (suite *ruleTestSuite) TestSet() {
     "Offset": 2
   }
 }
+`,
+		},
+		{
+			name:    "Unknown field",
+			rawData: []byte(`{"group_id":"a","id":"10","start_key":"1111","end_key":"3333","Roles":"voter","count":1}`),
+			success: false,
+			response: `{
+  "code": "input",
+  "msg": "json: unknown field \"Roles\"",
+  "data": {}
+}
 `,
 		},
 		{
@@ -725,8 +736,16 @@ func (suite *ruleTestSuite) TestBundle() {
 	suite.compareBundle(bundles[0], b1)
 	suite.compareBundle(bundles[1], b2)
 
+	// Delete the default rule without force
+	statusCode, err := apiutil.DoDelete(testDialClient, suite.urlPrefix+"/placement-rule/pd")
+	suite.NoError(err)
+	suite.Equal(http.StatusForbidden, statusCode)
+	err = tu.ReadGetJSON(re, testDialClient, suite.urlPrefix+"/placement-rule", &bundles)
+	suite.NoError(err)
+	suite.Len(bundles, 2)
+
 	// Delete
-	_, err = apiutil.DoDelete(testDialClient, suite.urlPrefix+"/placement-rule/pd")
+	_, err = apiutil.DoDelete(testDialClient, suite.urlPrefix+"/placement-rule/pd?force")
 	suite.NoError(err)
 
 	// GetAll again
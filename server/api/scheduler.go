@@ -15,8 +15,11 @@
 package api
 
 import (
+	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -47,6 +50,8 @@ type schedulerPausedPeriod struct {
 	Name     string    `json:"name"`
 	PausedAt time.Time `json:"paused_at"`
 	ResumeAt time.Time `json:"resume_at"`
+	// Remaining is the time left before the scheduler resumes.
+	Remaining string `json:"remaining"`
 }
 
 // @Tags     scheduler
@@ -94,6 +99,7 @@ func (h *schedulerHandler) GetSchedulers(w http.ResponseWriter, r *http.Request)
 						return
 					}
 					s.ResumeAt = time.Unix(resumeAt, 0)
+					s.Remaining = time.Until(s.ResumeAt).Round(time.Second).String()
 					pausedPeriods = append(pausedPeriods, s)
 				} else {
 					pausedSchedulers = append(pausedSchedulers, scheduler)
@@ -178,6 +184,21 @@ func (h *schedulerHandler) CreateScheduler(w http.ResponseWriter, r *http.Reques
 			h.r.JSON(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+	case schedulers.BalanceDiskUsageName:
+		if err := h.AddBalanceDiskUsageScheduler(); err != nil {
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case schedulers.TieredStorageName:
+		if err := h.AddTieredStorageScheduler(); err != nil {
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	case schedulers.HotPredictionName:
+		if err := h.AddHotPredictionScheduler(); err != nil {
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	case schedulers.LabelName:
 		if err := h.AddLabelScheduler(); err != nil {
 			h.r.JSON(w, http.StatusInternalServerError, err.Error())
@@ -208,6 +229,29 @@ func (h *schedulerHandler) CreateScheduler(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
+	case schedulers.ExternalName:
+		pluginName, ok := input["plugin_name"].(string)
+		if !ok || len(pluginName) == 0 {
+			h.r.JSON(w, http.StatusBadRequest, "missing plugin name")
+			return
+		}
+		args := []string{pluginName}
+		// the store budget is only taken with the operator budget.
+		for _, key := range []string{"operator_budget", "store_budget"} {
+			budget, ok := input[key].(float64)
+			if !ok {
+				break
+			}
+			if budget <= 0 || budget != math.Trunc(budget) || budget > math.MaxUint32 {
+				h.r.JSON(w, http.StatusBadRequest, "invalid "+key)
+				return
+			}
+			args = append(args, strconv.FormatUint(uint64(budget), 10))
+		}
+		if err := h.AddExternalScheduler(args...); err != nil {
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 	case schedulers.GrantLeaderName:
 		h.addEvictOrGrant(w, input, schedulers.GrantLeaderName)
 	case schedulers.EvictLeaderName:
@@ -362,6 +406,39 @@ func (h *schedulerHandler) PauseOrResumeScheduler(w http.ResponseWriter, r *http
 	h.r.JSON(w, http.StatusOK, "Pause or resume the scheduler successfully.")
 }
 
+// @Tags     scheduler
+// @Summary  Update the config of a scheduler in place, the scheduler keeps running with its state.
+// @Accept   json
+// @Param    name  path  string  true  "The name of the scheduler."
+// @Param    body  body  object  true  "The whole config of the scheduler, which is the same as the one returned by the scheduler config API."
+// @Produce  json
+// @Success  200  {string}  string  "The scheduler config is updated."
+// @Failure  400  {string}  string  "The input is invalid."
+// @Failure  404  {string}  string  "The scheduler is not found."
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /schedulers/config/{name} [post]
+func (h *schedulerHandler) UpdateSchedulerConfig(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	name := mux.Vars(r)["name"]
+	if err := h.Handler.UpdateSchedulerConfig(name, data); err != nil {
+		switch {
+		case errs.ErrSchedulerNotFound.Equal(err):
+			h.r.JSON(w, http.StatusNotFound, err.Error())
+		case errs.ErrSchedulerConfig.Equal(err) || errs.ErrSchedulerConfigNotReloadable.Equal(err) || errs.ErrJSONUnmarshal.Equal(err):
+			h.r.JSON(w, http.StatusBadRequest, err.Error())
+		default:
+			h.r.JSON(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+	h.r.JSON(w, http.StatusOK, "The scheduler config is updated.")
+}
+
 type schedulerConfigHandler struct {
 	svr *server.Server
 	rd  *render.Render
@@ -16,6 +16,7 @@ package api
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/tikv/pd/pkg/statistics"
 	"github.com/tikv/pd/server"
@@ -52,3 +53,30 @@ func (h *statsHandler) GetRegionStatus(w http.ResponseWriter, r *http.Request) {
 	}
 	h.rd.JSON(w, http.StatusOK, stats)
 }
+
+// @Tags     stats
+// @Summary  Get the balance skew snapshots of the cluster in a time range.
+// @Param    start_time  query  integer  false  "Start unix timestamp in seconds"
+// @Param    end_time    query  integer  false  "End unix timestamp in seconds"
+// @Produce  json
+// @Success  200  {array}   statistics.BalanceSkew
+// @Failure  400  {string}  string  "The input is invalid."
+// @Router   /stats/balance-skew [get]
+func (h *statsHandler) GetBalanceSkewHistory(w http.ResponseWriter, r *http.Request) {
+	rc := getCluster(r)
+	var start, end int64
+	var err error
+	if startStr := r.URL.Query().Get("start_time"); startStr != "" {
+		if start, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if endStr := r.URL.Query().Get("end_time"); endStr != "" {
+		if end, err = strconv.ParseInt(endStr, 10, 64); err != nil {
+			h.rd.JSON(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	h.rd.JSON(w, http.StatusOK, rc.GetBalanceSkewHistory().GetHistory(start, end))
+}
@@ -42,6 +42,7 @@ func RegisterKeyspace(r *gin.RouterGroup) {
 	router.PATCH("/:name/config", UpdateKeyspaceConfig)
 	router.PUT("/:name/state", UpdateKeyspaceState)
 	router.GET("/id/:id", LoadKeyspaceByID)
+	router.GET("/rules/validation", ValidateKeyspaceRules)
 }
 
 // CreateKeyspaceParams represents parameters needed when creating a new keyspace.
@@ -156,6 +157,29 @@ func LoadKeyspaceByID(c *gin.Context) {
 	c.IndentedJSON(http.StatusOK, &KeyspaceMeta{meta})
 }
 
+// ValidateKeyspaceRules validates the rules scoped to every keyspace.
+//
+// @Tags     keyspaces
+// @Summary  Validate the region label rules and placement rules of all keyspaces.
+// @Produce  json
+// @Success  200  {array}   keyspace.RuleValidationResult
+// @Failure  500  {string}  string  "PD server failed to proceed the request."
+// @Router   /keyspaces/rules/validation [get]
+func ValidateKeyspaceRules(c *gin.Context) {
+	svr := c.MustGet(middlewares.ServerContextKey).(*server.Server)
+	manager := svr.GetKeyspaceManager()
+	if manager == nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, managerUninitializedErr)
+		return
+	}
+	results, err := manager.ValidateKeyspaceRules(0, 0)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.IndentedJSON(http.StatusOK, results)
+}
+
 // parseLoadAllQuery parses LoadAllKeyspaces'/GetKeyspaceGroups' query parameters.
 // page_token:
 // The keyspace/keyspace group id of the scan start. If not set, scan from keyspace/keyspace group with id 1.
@@ -164,6 +164,7 @@ type RaftCluster struct {
 	regionStats              *statistics.RegionStatistics
 	hotStat                  *statistics.HotStat
 	slowStat                 *statistics.SlowStat
+	balanceSkew              *statistics.BalanceSkewHistory
 	ruleManager              *placement.RuleManager
 	regionLabeler            *labeler.RegionLabeler
 	replicationMode          *replication.ModeManager
@@ -271,6 +272,7 @@ func (c *RaftCluster) InitCluster(
 	c.labelLevelStats = statistics.NewLabelStatistics()
 	c.hotStat = statistics.NewHotStat(c.ctx)
 	c.slowStat = statistics.NewSlowStat(c.ctx)
+	c.balanceSkew = statistics.NewBalanceSkewHistory(c.opt)
 	c.progressManager = progress.NewManager()
 	c.changedRegions = make(chan *core.RegionInfo, defaultChangedRegionsLimit)
 	c.prevStoreLimit = make(map[uint64]map[storelimit.Type]float64)
@@ -309,6 +311,14 @@ func (c *RaftCluster) Start(s Server) error {
 	if err != nil {
 		return err
 	}
+	c.regionLabeler.SetRegionSetInformer(c.core)
+	c.regionLabeler.SetHotRegionsFunc(c.hotStat.HotRegionIDs)
+	if err := c.regionLabeler.SetOverlapPolicy(c.opt.GetPDServerConfig().RegionLabelOverlapPolicy); err != nil {
+		log.Warn("failed to set the region label overlap policy, use the default one", errs.ZapError(err))
+	}
+	if err := c.regionLabeler.SetExpiryWebhook(c.opt.GetPDServerConfig().RegionLabelExpiryWebhook); err != nil {
+		log.Warn("failed to set the region label expiry webhook, the expired labels are not notified", errs.ZapError(err))
+	}
 
 	c.replicationMode, err = replication.NewReplicationModeManager(s.GetConfig().ReplicationMode, c.storage, cluster, s)
 	if err != nil {
@@ -810,6 +820,11 @@ func (c *RaftCluster) PauseOrResumeScheduler(name string, t int64) error {
 	return c.coordinator.GetSchedulersController().PauseOrResumeScheduler(name, t)
 }
 
+// UpdateSchedulerConfig updates the config of a running scheduler in place.
+func (c *RaftCluster) UpdateSchedulerConfig(name string, data []byte) error {
+	return c.coordinator.GetSchedulersController().UpdateSchedulerConfig(name, data)
+}
+
 // PauseOrResumeChecker pauses or resumes checker.
 func (c *RaftCluster) PauseOrResumeChecker(name string, t int64) error {
 	return c.coordinator.PauseOrResumeChecker(name, t)
@@ -840,6 +855,28 @@ func (c *RaftCluster) GetRegionLabeler() *labeler.RegionLabeler {
 	return c.regionLabeler
 }
 
+// SaveRuleSnapshot saves the placement rules, rule groups and region label
+// rules to the snapshot with the given name.
+func (c *RaftCluster) SaveRuleSnapshot(name string) error {
+	// block the updates to take a consistent snapshot.
+	c.ruleManager.RLock()
+	defer c.ruleManager.RUnlock()
+	c.regionLabeler.RLock()
+	defer c.regionLabeler.RUnlock()
+	return c.storage.SaveSnapshot(name)
+}
+
+// RestoreRuleSnapshot reverts the placement rules, rule groups and region
+// label rules to the snapshot with the given name. The reverted keys are
+// synced to the scheduling service by its watchers.
+func (c *RaftCluster) RestoreRuleSnapshot(name string) error {
+	return c.regionLabeler.Restore(func() error {
+		return c.ruleManager.Restore(func() error {
+			return c.storage.RestoreSnapshot(name)
+		})
+	})
+}
+
 // GetStorage returns the storage.
 func (c *RaftCluster) GetStorage() storage.Storage {
 	return c.storage
@@ -895,6 +932,11 @@ func (c *RaftCluster) GetHotStat() *statistics.HotStat {
 	return c.hotStat
 }
 
+// GetBalanceSkewHistory returns the balance skew history of the cluster.
+func (c *RaftCluster) GetBalanceSkewHistory() *statistics.BalanceSkewHistory {
+	return c.balanceSkew
+}
+
 // RemoveSuspectRegion removes region from suspect list.
 func (c *RaftCluster) RemoveSuspectRegion(id uint64) {
 	c.coordinator.GetCheckerController().RemoveSuspectRegion(id)
@@ -947,21 +989,34 @@ func (c *RaftCluster) HandleStoreHeartbeat(heartbeat *pdpb.StoreHeartbeatRequest
 		opt = core.SetStoreLimit(limit)
 	}
 
+	// The struggling stores are throttled by lowering their store limits, and
+	// the throttle is lifted step by step once they recover.
+	throttleFactor := 1.0
+	if c.opt.IsStoreLatencyThrottleEnabled() {
+		throttleFactor = store.NextThrottleFactor(stats, c.opt.GetStoreThrottleThresholds())
+		if throttleFactor < store.GetThrottleFactor() {
+			log.Warn("store is throttled as it is struggling",
+				zap.Uint64("store-id", storeID),
+				zap.Float64("throttle-factor", throttleFactor))
+		}
+	}
+	throttleOpt := core.SetThrottleFactor(throttleFactor)
+
 	nowTime := time.Now()
 	var newStore *core.StoreInfo
 	// If this cluster has slow stores, we should awaken hibernated regions in other stores.
 	if !c.isAPIServiceMode {
 		if needAwaken, slowStoreIDs := c.NeedAwakenAllRegionsInStore(storeID); needAwaken {
 			log.Info("forcely awaken hibernated regions", zap.Uint64("store-id", storeID), zap.Uint64s("slow-stores", slowStoreIDs))
-			newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), core.SetLastAwakenTime(nowTime), opt)
+			newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), core.SetLastAwakenTime(nowTime), throttleOpt, opt)
 			resp.AwakenRegions = &pdpb.AwakenRegions{
 				AbnormalStores: slowStoreIDs,
 			}
 		} else {
-			newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), opt)
+			newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), throttleOpt, opt)
 		}
 	} else {
-		newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), opt)
+		newStore = store.Clone(core.SetStoreStats(stats), core.SetLastHeartbeatTS(nowTime), throttleOpt, opt)
 	}
 
 	if newStore.IsLowSpace(c.opt.GetLowSpaceRatio()) {
@@ -1145,6 +1200,7 @@ func (c *RaftCluster) processRegionHeartbeat(region *core.RegionInfo) error {
 				c.labelLevelStats.ClearDefunctRegion(item.GetID())
 			}
 			c.ruleManager.InvalidCache(item.GetID())
+			c.ruleManager.ClearDefunctRegion(item.GetID())
 		}
 		regionUpdateCacheEventCounter.Inc()
 	}
@@ -1419,7 +1475,7 @@ func (c *RaftCluster) putStoreImpl(store *metapb.Store, force bool) error {
 	s := c.GetStore(store.GetId())
 	if s == nil {
 		// Add a new store.
-		s = core.NewStoreInfo(store)
+		s = core.NewStoreInfo(store, core.SetStoreJoinTime(time.Now()))
 	} else {
 		// Use the given labels to update the store.
 		labels := store.GetLabels()
@@ -1928,7 +1984,7 @@ func (c *RaftCluster) calculateRange(stores []*core.StoreInfo, store *core.Store
 	var storeSize float64
 	rules := c.ruleManager.GetRulesForApplyRange(startKey, endKey)
 	for _, rule := range rules {
-		if !placement.MatchLabelConstraints(store, rule.LabelConstraints) {
+		if !rule.MatchStore(store) {
 			continue
 		}
 
@@ -1937,7 +1993,7 @@ func (c *RaftCluster) calculateRange(stores []*core.StoreInfo, store *core.Store
 			if s.IsRemoving() || s.IsRemoved() {
 				continue
 			}
-			if placement.MatchLabelConstraints(s, rule.LabelConstraints) {
+			if rule.MatchStore(s) {
 				matchStores = append(matchStores, s)
 			}
 		}
@@ -2163,24 +2219,35 @@ func (c *RaftCluster) collectMetrics() {
 		}
 	}
 	statsMap.Collect()
+	c.balanceSkew.Observe(stores)
+	c.balanceSkew.Collect()
 
 	if !c.isAPIServiceMode {
 		c.coordinator.GetSchedulersController().CollectSchedulerMetrics()
 		c.coordinator.CollectHotSpotMetrics()
 		c.collectClusterMetrics()
 	}
+	if c.opt.IsPlacementRulesEnabled() {
+		c.ruleManager.CheckUnsatisfiableRules()
+		c.ruleManager.CheckRulePreconditions()
+		c.ruleManager.RefreshTemplateRules()
+		c.ruleManager.FindUnusedRules()
+		c.ruleManager.ReapRuleTombstones()
+	}
 	c.collectHealthStatus()
 }
 
 func (c *RaftCluster) resetMetrics() {
 	statsMap := statistics.NewStoreStatisticsMap(c.opt)
 	statsMap.Reset()
+	c.balanceSkew.Reset()
 
 	if !c.isAPIServiceMode {
 		c.coordinator.GetSchedulersController().ResetSchedulerMetrics()
 		c.coordinator.ResetHotSpotMetrics()
 		c.resetClusterMetrics()
 	}
+	c.ruleManager.ResetUnsatisfiableRulesMetrics()
 	c.resetHealthStatus()
 	c.resetProgressIndicator()
 }
@@ -2379,6 +2446,13 @@ func (c *RaftCluster) RegionWriteStats() map[uint64][]*statistics.HotPeerStat {
 	return c.hotStat.RegionStats(utils.Write, c.GetOpts().GetHotRegionCacheHitsThreshold())
 }
 
+// RegionStats returns the stats of the peers which have been hot for at least
+// minHotDegree times.
+func (c *RaftCluster) RegionStats(rw utils.RWType, minHotDegree int) map[uint64][]*statistics.HotPeerStat {
+	// RegionStats is a thread-safe method
+	return c.hotStat.RegionStats(rw, minHotDegree)
+}
+
 // BucketsStats returns hot region's buckets stats.
 func (c *RaftCluster) BucketsStats(degree int, regionIDs ...uint64) map[uint64][]*buckets.BucketStat {
 	return c.hotStat.BucketsStats(degree, regionIDs...)
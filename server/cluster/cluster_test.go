@@ -53,6 +53,7 @@ import (
 	"github.com/tikv/pd/pkg/statistics"
 	"github.com/tikv/pd/pkg/statistics/utils"
 	"github.com/tikv/pd/pkg/storage"
+	"github.com/tikv/pd/pkg/storage/endpoint"
 	"github.com/tikv/pd/pkg/utils/operatorutil"
 	"github.com/tikv/pd/pkg/utils/testutil"
 	"github.com/tikv/pd/pkg/utils/typeutil"
@@ -1683,7 +1684,7 @@ func TestCalculateStoreSize1(t *testing.T) {
 			},
 			LocationLabels: []string{"rack", "host"}},
 	)
-	cluster.ruleManager.DeleteRule("pd", "default")
+	cluster.ruleManager.DeleteRule("pd", "default", true)
 
 	regions := newTestRegions(100, 10, 5)
 	for _, region := range regions {
@@ -1767,7 +1768,7 @@ func TestCalculateStoreSize2(t *testing.T) {
 			},
 			LocationLabels: []string{"dc", "logic", "rack", "host"}},
 	)
-	cluster.ruleManager.DeleteRule("pd", "default")
+	cluster.ruleManager.DeleteRule("pd", "default", true)
 
 	regions := newTestRegions(100, 10, 5)
 	for _, region := range regions {
@@ -3278,7 +3279,9 @@ func TestRestart(t *testing.T) {
 func TestPauseScheduler(t *testing.T) {
 	re := require.New(t)
 
-	_, co, cleanup := prepare(nil, nil, func(co *schedule.Coordinator) { co.Run() }, re)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tc, co, cleanup := prepare(nil, nil, func(co *schedule.Coordinator) { co.Run() }, re)
 	defer cleanup()
 	controller := co.GetSchedulersController()
 	_, err := controller.IsSchedulerAllowed("test")
@@ -3293,6 +3296,52 @@ func TestPauseScheduler(t *testing.T) {
 	re.Equal(int64(60), resumeAt-pausedAt)
 	allowed, _ := controller.IsSchedulerAllowed(schedulers.BalanceLeaderName)
 	re.False(allowed)
+
+	// the pause is persisted and restored by the new leader.
+	pause, err := tc.GetStorage().LoadSchedulerPause(schedulers.BalanceLeaderName)
+	re.NoError(err)
+	re.Equal(resumeAt, pause.DelayUntil)
+	newController := schedulers.NewController(ctx, tc.RaftCluster, tc.GetStorage(), co.GetOperatorController())
+	re.NoError(newController.AddScheduler(controller.GetScheduler(schedulers.BalanceLeaderName).Scheduler))
+	paused, _ = newController.IsSchedulerPaused(schedulers.BalanceLeaderName)
+	re.True(paused)
+	restoredResumeAt, err := newController.GetPausedSchedulerDelayUntil(schedulers.BalanceLeaderName)
+	re.NoError(err)
+	re.Equal(resumeAt, restoredResumeAt)
+
+	// resuming removes the pause.
+	re.NoError(controller.PauseOrResumeScheduler(schedulers.BalanceLeaderName, 0))
+	pause, err = tc.GetStorage().LoadSchedulerPause(schedulers.BalanceLeaderName)
+	re.NoError(err)
+	re.Nil(pause)
+
+	// the expired pause is not restored.
+	re.NoError(newController.RemoveScheduler(schedulers.BalanceLeaderName))
+	re.NoError(tc.GetStorage().SaveSchedulerPause(schedulers.BalanceLeaderName, &endpoint.SchedulerPause{DelayAt: pausedAt - 120, DelayUntil: pausedAt - 60}))
+	re.NoError(newController.AddScheduler(controller.GetScheduler(schedulers.BalanceLeaderName).Scheduler))
+	paused, _ = newController.IsSchedulerPaused(schedulers.BalanceLeaderName)
+	re.False(paused)
+	pause, err = tc.GetStorage().LoadSchedulerPause(schedulers.BalanceLeaderName)
+	re.NoError(err)
+	re.Nil(pause)
+
+	// all the schedulers are paused and resumed together.
+	re.NoError(controller.PauseOrResumeScheduler("all", 60))
+	for _, name := range controller.GetSchedulerNames() {
+		paused, _ = controller.IsSchedulerPaused(name)
+		re.True(paused)
+		pause, err = tc.GetStorage().LoadSchedulerPause(name)
+		re.NoError(err)
+		re.NotNil(pause)
+	}
+	re.NoError(controller.PauseOrResumeScheduler("all", 0))
+	for _, name := range controller.GetSchedulerNames() {
+		paused, _ = controller.IsSchedulerPaused(name)
+		re.False(paused)
+		pause, err = tc.GetStorage().LoadSchedulerPause(name)
+		re.NoError(err)
+		re.Nil(pause)
+	}
 }
 
 func BenchmarkPatrolRegion(b *testing.B) {
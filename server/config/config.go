@@ -34,6 +34,7 @@ import (
 	"github.com/tikv/pd/pkg/errs"
 	rm "github.com/tikv/pd/pkg/mcs/resourcemanager/server"
 	sc "github.com/tikv/pd/pkg/schedule/config"
+	"github.com/tikv/pd/pkg/schedule/labeler"
 	"github.com/tikv/pd/pkg/utils/configutil"
 	"github.com/tikv/pd/pkg/utils/grpcutil"
 	"github.com/tikv/pd/pkg/utils/metricutil"
@@ -526,6 +527,14 @@ type PDServerConfig struct {
 	GCTunerThreshold float64 `toml:"gc-tuner-threshold" json:"gc-tuner-threshold"`
 	// BlockSafePointV1 is used to control gc safe point v1 and service safe point v1 can not be updated.
 	BlockSafePointV1 bool `toml:"block-safe-point-v1" json:"block-safe-point-v1,string"`
+	// RegionLabelOverlapPolicy is the policy to resolve the region label rules
+	// matching the same region. There are some policies supported:
+	// ["merge-labels", "highest-index-wins"], default: "merge-labels"
+	RegionLabelOverlapPolicy string `toml:"region-label-overlap-policy" json:"region-label-overlap-policy"`
+	// RegionLabelExpiryWebhook is the URL which the expired region labels are
+	// posted to, so the downstream tools know the protection by the labels
+	// lapsed. The empty one means no notification.
+	RegionLabelExpiryWebhook string `toml:"region-label-expiry-webhook" json:"region-label-expiry-webhook"`
 }
 
 func (c *PDServerConfig) adjust(meta *configutil.ConfigMetaData) error {
@@ -536,6 +545,9 @@ func (c *PDServerConfig) adjust(meta *configutil.ConfigMetaData) error {
 	if !meta.IsDefined("key-type") {
 		c.KeyType = defaultKeyType
 	}
+	if !meta.IsDefined("region-label-overlap-policy") {
+		c.RegionLabelOverlapPolicy = labeler.MergeLabels
+	}
 	if !meta.IsDefined("runtime-services") {
 		c.RuntimeServices = defaultRuntimeServices
 	}
@@ -631,6 +643,13 @@ func (c *PDServerConfig) Validate() error {
 	if c.FlowRoundByDigit < 0 {
 		return errs.ErrConfigItem.GenWithStack("flow round by digit cannot be negative number")
 	}
+	// the empty policy is allowed for the config persisted by the old versions.
+	if err := labeler.ValidateOverlapPolicy(c.RegionLabelOverlapPolicy); err != nil {
+		return err
+	}
+	if err := labeler.ValidateExpiryWebhook(c.RegionLabelExpiryWebhook); err != nil {
+		return err
+	}
 	if c.ServerMemoryLimit < minServerMemoryLimit || c.ServerMemoryLimit > maxServerMemoryLimit {
 		return errors.New(fmt.Sprintf("server-memory-limit should between %v and %v", minServerMemoryLimit, maxServerMemoryLimit))
 	}
@@ -30,6 +30,7 @@ import (
 	"github.com/pingcap/kvproto/pkg/metapb"
 	"github.com/pingcap/log"
 	"github.com/tikv/pd/pkg/cache"
+	"github.com/tikv/pd/pkg/core"
 	"github.com/tikv/pd/pkg/core/constant"
 	"github.com/tikv/pd/pkg/core/storelimit"
 	sc "github.com/tikv/pd/pkg/schedule/config"
@@ -199,6 +200,21 @@ func (o *PersistOptions) SetPlacementRulesCacheEnabled(enabled bool) {
 	o.SetReplicationConfig(v)
 }
 
+// IsUnsatisfiableRuleFallbackEnabled returns if the ranges of unsatisfiable rules fall back to the default rule.
+func (o *PersistOptions) IsUnsatisfiableRuleFallbackEnabled() bool {
+	return o.GetReplicationConfig().EnableUnsatisfiableRuleFallback
+}
+
+// IsRuleTopologyValidationEnabled returns if the rules are validated against the store topology when they are set.
+func (o *PersistOptions) IsRuleTopologyValidationEnabled() bool {
+	return o.GetReplicationConfig().EnableRuleTopologyValidation
+}
+
+// GetRuleTombstoneGracePeriod returns how long a deleted placement rule is kept as a tombstone.
+func (o *PersistOptions) GetRuleTombstoneGracePeriod() time.Duration {
+	return o.GetReplicationConfig().RuleTombstoneGracePeriod.Duration
+}
+
 // GetStrictlyMatchLabel returns whether check label strict.
 func (o *PersistOptions) GetStrictlyMatchLabel() bool {
 	return o.GetReplicationConfig().StrictlyMatchLabel
@@ -318,6 +334,36 @@ func (o *PersistOptions) SetEnableDiagnostic(enable bool) {
 	o.SetScheduleConfig(v)
 }
 
+// IsSchedulingDryRunEnabled returns whether the operators are only recorded rather than dispatched.
+func (o *PersistOptions) IsSchedulingDryRunEnabled() bool {
+	return o.GetScheduleConfig().EnableSchedulingDryRun
+}
+
+// GetOperatorPriorityWeights returns the weights of the priority levels of the waiting operators.
+func (o *PersistOptions) GetOperatorPriorityWeights() []float64 {
+	return o.GetScheduleConfig().OperatorPriorityWeights
+}
+
+// GetRecoveryPreemptRatio returns the ratio of the schedule limits to let the recovery operators preempt.
+func (o *PersistOptions) GetRecoveryPreemptRatio() float64 {
+	return o.GetScheduleConfig().RecoveryPreemptRatio
+}
+
+// IsStoreLatencyThrottleEnabled returns whether the struggling stores are throttled.
+func (o *PersistOptions) IsStoreLatencyThrottleEnabled() bool {
+	return o.GetScheduleConfig().EnableStoreLatencyThrottle
+}
+
+// GetStoreThrottleThresholds returns the thresholds over which a store is throttled.
+func (o *PersistOptions) GetStoreThrottleThresholds() core.StoreThrottleThresholds {
+	cfg := o.GetScheduleConfig()
+	return core.StoreThrottleThresholds{
+		ApplyLatency:     cfg.StoreThrottleApplyLatency.Duration,
+		SnapshotDuration: cfg.StoreThrottleSnapshotDuration.Duration,
+		PendingCount:     cfg.StoreThrottlePendingCount,
+	}
+}
+
 // IsWitnessAllowed returns whether is enable to use witness.
 func (o *PersistOptions) IsWitnessAllowed() bool {
 	return o.GetScheduleConfig().EnableWitness
@@ -410,6 +456,36 @@ func (o *PersistOptions) GetMaxStoreDownTime() time.Duration {
 	return o.GetScheduleConfig().MaxStoreDownTime.Duration
 }
 
+// GetStoreWarmupDuration returns the warm-up window after a store joins.
+func (o *PersistOptions) GetStoreWarmupDuration() time.Duration {
+	return o.GetScheduleConfig().StoreWarmupDuration.Duration
+}
+
+// GetStoreWarmupInitialRatio returns the ratio of the store limit at the beginning of the warm-up window.
+func (o *PersistOptions) GetStoreWarmupInitialRatio() float64 {
+	return o.GetScheduleConfig().StoreWarmupInitialRatio
+}
+
+// GetTieredStorageLabelKey returns the store label key of the storage tiers.
+func (o *PersistOptions) GetTieredStorageLabelKey() string {
+	return o.GetScheduleConfig().TieredStorageLabelKey
+}
+
+// GetHotStorageTier returns the label value of the fast storage tier.
+func (o *PersistOptions) GetHotStorageTier() string {
+	return o.GetScheduleConfig().HotStorageTier
+}
+
+// GetColdStorageTier returns the label value of the slow storage tier.
+func (o *PersistOptions) GetColdStorageTier() string {
+	return o.GetScheduleConfig().ColdStorageTier
+}
+
+// GetColdRegionDuration returns how long a region is not accessed before it is demoted to the cold storage tier.
+func (o *PersistOptions) GetColdRegionDuration() time.Duration {
+	return o.GetScheduleConfig().ColdRegionDuration.Duration
+}
+
 // GetMaxStorePreparingTime returns the max preparing time of a store.
 func (o *PersistOptions) GetMaxStorePreparingTime() time.Duration {
 	return o.GetScheduleConfig().MaxStorePreparingTime.Duration
@@ -682,6 +758,11 @@ func (o *PersistOptions) GetHotRegionsWriteInterval() time.Duration {
 	return o.GetScheduleConfig().HotRegionsWriteInterval.Duration
 }
 
+// GetBalanceSkewHistoryRetention gets the time to keep the balance skew snapshots.
+func (o *PersistOptions) GetBalanceSkewHistoryRetention() time.Duration {
+	return o.GetScheduleConfig().BalanceSkewHistoryRetention.Duration
+}
+
 // GetHotRegionsReservedDays gets days hot region information is kept.
 func (o *PersistOptions) GetHotRegionsReservedDays() uint64 {
 	return o.GetScheduleConfig().HotRegionsReservedDays
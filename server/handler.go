@@ -149,6 +149,19 @@ func (h *Handler) GetSchedulers() ([]string, error) {
 	return c.GetSchedulers(), nil
 }
 
+// GetScheduler returns the running scheduler with the given name.
+func (h *Handler) GetScheduler(name string) (schedulers.Scheduler, error) {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return nil, err
+	}
+	sc := c.GetCoordinator().GetSchedulersController().GetScheduler(name)
+	if sc == nil {
+		return nil, errs.ErrSchedulerNotFound.FastGenByArgs()
+	}
+	return sc.Scheduler, nil
+}
+
 // IsCheckerPaused returns if checker is paused
 func (h *Handler) IsCheckerPaused(name string) (bool, error) {
 	rc, err := h.GetRaftCluster()
@@ -280,6 +293,19 @@ func (h *Handler) RemoveScheduler(name string) error {
 	return err
 }
 
+// UpdateSchedulerConfig replaces the config of a running scheduler with the
+// whole config, without removing the scheduler.
+func (h *Handler) UpdateSchedulerConfig(name string, data []byte) error {
+	c, err := h.GetRaftCluster()
+	if err != nil {
+		return err
+	}
+	if err = c.UpdateSchedulerConfig(name, data); err != nil {
+		log.Error("can not update scheduler config", zap.String("scheduler-name", name), errs.ZapError(err))
+	}
+	return err
+}
+
 // PauseOrResumeScheduler pauses a scheduler for delay seconds or resume a paused scheduler.
 // t == 0 : resume scheduler.
 // t > 0 : scheduler delays t seconds.
@@ -362,6 +388,27 @@ func (h *Handler) AddScatterRangeScheduler(args ...string) error {
 	return h.AddScheduler(schedulers.ScatterRangeType, args...)
 }
 
+// AddBalanceDiskUsageScheduler adds a balance-disk-usage-scheduler.
+func (h *Handler) AddBalanceDiskUsageScheduler() error {
+	return h.AddScheduler(schedulers.BalanceDiskUsageType)
+}
+
+// AddTieredStorageScheduler adds a tiered-storage-scheduler.
+func (h *Handler) AddTieredStorageScheduler() error {
+	return h.AddScheduler(schedulers.TieredStorageType)
+}
+
+// AddHotPredictionScheduler adds a hot-prediction-scheduler.
+func (h *Handler) AddHotPredictionScheduler() error {
+	return h.AddScheduler(schedulers.HotPredictionType)
+}
+
+// AddExternalScheduler adds an external scheduler for the plugin, args are
+// the plugin name and the optional operator and store budgets.
+func (h *Handler) AddExternalScheduler(args ...string) error {
+	return h.AddScheduler(schedulers.ExternalType, args...)
+}
+
 // AddGrantLeaderScheduler adds a grant-leader-scheduler.
 func (h *Handler) AddGrantLeaderScheduler(storeID uint64) error {
 	return h.AddScheduler(schedulers.GrantLeaderType, strconv.FormatUint(storeID, 10))
@@ -520,6 +567,15 @@ func (h *Handler) GetRecords(from time.Time) ([]*operator.OpRecord, error) {
 	return records, nil
 }
 
+// GetDryRunRecords returns the operators computed in the dry-run mode since the time.
+func (h *Handler) GetDryRunRecords(from time.Time) ([]operator.DryRunRecord, error) {
+	c, err := h.GetOperatorController()
+	if err != nil {
+		return nil, err
+	}
+	return c.GetDryRunRecords(from), nil
+}
+
 // SetAllStoresLimit is used to set limit of all stores.
 func (h *Handler) SetAllStoresLimit(ratePerMin float64, limitType storelimit.Type) error {
 	c, err := h.GetRaftCluster()
@@ -304,6 +304,7 @@ func CreateServer(ctx context.Context, cfg *config.Config, services []string, le
 		pdpb.RegisterPDServer(gs, grpcServer)
 		keyspacepb.RegisterKeyspaceServer(gs, &KeyspaceServer{GrpcServer: grpcServer})
 		diagnosticspb.RegisterDiagnosticsServer(gs, s)
+		schedulers.RegisterExternalSchedulerServer(gs, schedulers.NewExternalSchedulerServer(s.handler.GetScheduler, s.handler.AddExternalScheduler))
 		// Register the micro services GRPC service.
 		s.registry.InstallAllGRPCServices(s, gs)
 		s.grpcServer = gs
@@ -1079,7 +1080,7 @@ func (s *Server) SetReplicationConfig(cfg sc.ReplicationConfig) error {
 		if rc == nil {
 			return errs.ErrNotBootstrapped.GenWithStackByArgs()
 		}
-		if err := rc.GetRuleManager().SetRule(rule); err != nil {
+		if err := rc.GetRuleManager().ForceSetRule(rule); err != nil {
 			log.Error("failed to update rule count",
 				errs.ZapError(err))
 			return err
@@ -1095,7 +1096,7 @@ func (s *Server) SetReplicationConfig(cfg sc.ReplicationConfig) error {
 			if rc == nil {
 				return errs.ErrNotBootstrapped.GenWithStackByArgs()
 			}
-			if e := rc.GetRuleManager().SetRule(rule); e != nil {
+			if e := rc.GetRuleManager().ForceSetRule(rule); e != nil {
 				log.Error("failed to roll back count of rule when update replication config", errs.ZapError(e))
 			}
 		}
@@ -1260,6 +1261,11 @@ func (s *Server) SetPDServerConfig(cfg config.PDServerConfig) error {
 			errs.ZapError(err))
 		return err
 	}
+	if rc := s.GetRaftCluster(); rc != nil {
+		// the policy and the webhook have been validated, so they never fail.
+		_ = rc.GetRegionLabeler().SetOverlapPolicy(cfg.RegionLabelOverlapPolicy)
+		_ = rc.GetRegionLabeler().SetExpiryWebhook(cfg.RegionLabelExpiryWebhook)
+	}
 	log.Info("PD server config is updated", zap.Reflect("new", cfg), zap.Reflect("old", old))
 	return nil
 }
@@ -2,6 +2,7 @@ package scheduling_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"testing"
@@ -9,6 +10,8 @@ import (
 
 	"github.com/stretchr/testify/suite"
 	_ "github.com/tikv/pd/pkg/mcs/scheduling/server/apis/v1"
+	"github.com/tikv/pd/pkg/mcs/scheduling/server/rule"
+	"github.com/tikv/pd/pkg/schedule/placement"
 	"github.com/tikv/pd/pkg/utils/tempurl"
 	"github.com/tikv/pd/pkg/utils/testutil"
 	"github.com/tikv/pd/tests"
@@ -106,3 +109,38 @@ func (suite *apiTestSuite) TestGetCheckerByName() {
 		suite.False(resp["paused"].(bool))
 	}
 }
+
+func (suite *apiTestSuite) TestResyncRules() {
+	re := suite.Require()
+	s, cleanup := tests.StartSingleSchedulingTestServer(suite.ctx, re, suite.backendEndpoints, tempurl.Alloc())
+	defer cleanup()
+	testutil.Eventually(re, func() bool {
+		return s.IsServing()
+	}, testutil.WithWaitFor(5*time.Second), testutil.WithTickInterval(50*time.Millisecond))
+	url := fmt.Sprintf("%s/scheduling/api/v1/rules/resync", s.GetAddr())
+
+	var result rule.ReloadResult
+	re.NoError(testutil.CheckPostJSON(testDialClient, url, nil, testutil.StatusOK(re), testutil.ExtractJSON(re, &result)))
+	re.Positive(result.Revision)
+	re.Equal(rule.Drift{Count: 1}, result.Rules)
+
+	// Make the storage drift from etcd.
+	ruleStorage := s.GetRuleWatcher().GetRuleStorage()
+	staleRule := &placement.Rule{GroupID: "stale", ID: "1", Role: placement.Voter, Count: 1}
+	data, err := json.Marshal(staleRule)
+	re.NoError(err)
+	re.NoError(ruleStorage.SaveRule(nil, staleRule.StoreKey(), string(data)))
+	var defaultKey string
+	re.NoError(ruleStorage.LoadRules(func(k, _ string) {
+		if k != staleRule.StoreKey() {
+			defaultKey = k
+		}
+	}))
+	re.NoError(ruleStorage.SaveRule(nil, defaultKey, "{}"))
+	re.NoError(ruleStorage.SaveRuleGroup(nil, "stale", "{}"))
+
+	re.NoError(testutil.CheckPostJSON(testDialClient, url, nil, testutil.StatusOK(re), testutil.ExtractJSON(re, &result)))
+	re.Equal(rule.Drift{Count: 1, Updated: 1, Deleted: 1}, result.Rules)
+	re.Equal(rule.Drift{Deleted: 1}, result.RuleGroups)
+	re.Zero(result.RegionRules.Added + result.RegionRules.Updated + result.RegionRules.Deleted)
+}
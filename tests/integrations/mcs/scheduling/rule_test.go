@@ -16,6 +16,7 @@ package scheduling
 
 import (
 	"context"
+	"encoding/json"
 	"sort"
 	"testing"
 
@@ -23,6 +24,7 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/tikv/pd/pkg/keyspace"
 	"github.com/tikv/pd/pkg/mcs/scheduling/server/rule"
+	"github.com/tikv/pd/pkg/mcs/scheduling/server/rule/ruletest"
 	"github.com/tikv/pd/pkg/mcs/utils"
 	"github.com/tikv/pd/pkg/schedule/labeler"
 	"github.com/tikv/pd/pkg/schedule/placement"
@@ -104,6 +106,7 @@ func (suite *ruleTestSuite) TestRuleWatch() {
 		suite.ctx,
 		suite.pdLeaderServer.GetEtcdClient(),
 		suite.cluster.GetCluster().GetId(),
+		0,
 	)
 	re.NoError(err)
 	ruleStorage := watcher.GetRuleStorage()
@@ -148,7 +151,7 @@ func (suite *ruleTestSuite) TestRuleWatch() {
 	re.Equal(rule.StartKeyHex, rules[1].StartKeyHex)
 	re.Equal(rule.EndKeyHex, rules[1].EndKeyHex)
 	// Delete the rule.
-	err = ruleManager.DeleteRule(rule.GroupID, rule.ID)
+	err = ruleManager.DeleteRule(rule.GroupID, rule.ID, false)
 	re.NoError(err)
 	testutil.Eventually(re, func() bool {
 		rules = loadRules(re, ruleStorage)
@@ -233,3 +236,112 @@ func (suite *ruleTestSuite) TestRuleWatch() {
 	re.Equal(labelRule.Labels, labelRules[1].Labels)
 	re.Equal(labelRule.RuleType, labelRules[1].RuleType)
 }
+
+func (suite *ruleTestSuite) TestRuleForceResync() {
+	re := suite.Require()
+
+	watcher, err := rule.NewWatcher(
+		suite.ctx,
+		suite.pdLeaderServer.GetEtcdClient(),
+		suite.cluster.GetCluster().GetId(),
+		0,
+	)
+	re.NoError(err)
+	defer watcher.Close()
+	ruleStorage := watcher.GetRuleStorage()
+	rules := loadRules(re, ruleStorage)
+	re.Len(rules, 1)
+	re.Len(loadRegionRules(re, ruleStorage), 1)
+
+	// Make the storage drift from etcd.
+	staleRule := &placement.Rule{GroupID: "stale", ID: "1", Role: placement.Voter, Count: 1}
+	data, err := json.Marshal(staleRule)
+	re.NoError(err)
+	re.NoError(ruleStorage.SaveRule(nil, staleRule.StoreKey(), string(data)))
+	re.NoError(ruleStorage.DeleteRule(nil, rules[0].StoreKey()))
+	re.NoError(ruleStorage.DeleteRegionRule(nil, keyspace.MakeLabelRule(utils.DefaultKeyspaceID).ID))
+
+	re.NoError(watcher.ForceResync(suite.ctx))
+	rules = loadRules(re, ruleStorage)
+	re.Len(rules, 1)
+	re.Equal("pd", rules[0].GroupID)
+	re.Equal("default", rules[0].ID)
+	re.Empty(loadRuleGroups(re, ruleStorage))
+	re.Len(loadRegionRules(re, ruleStorage), 1)
+
+	// The watch events are still applied after the resync.
+	ruleManager := suite.pdLeaderServer.GetRaftCluster().GetRuleManager()
+	newRule := &placement.Rule{GroupID: "resync", ID: "1", Role: placement.Voter, Count: 1}
+	re.NoError(ruleManager.SetRule(newRule))
+	testutil.Eventually(re, func() bool {
+		return len(loadRules(re, ruleStorage)) == 2
+	})
+	re.NoError(ruleManager.DeleteRule(newRule.GroupID, newRule.ID, false))
+	testutil.Eventually(re, func() bool {
+		return len(loadRules(re, ruleStorage)) == 1
+	})
+}
+
+func (suite *ruleTestSuite) TestRuleWatchStatus() {
+	re := suite.Require()
+
+	watcher, err := rule.NewWatcher(
+		suite.ctx,
+		suite.pdLeaderServer.GetEtcdClient(),
+		suite.cluster.GetCluster().GetId(),
+		0,
+	)
+	re.NoError(err)
+	defer watcher.Close()
+	status, err := watcher.GetStatus(suite.ctx)
+	re.NoError(err)
+	re.Positive(status.AppliedRevision)
+	re.Equal(status.AppliedRevision, status.LatestRevision)
+	re.Zero(status.Lag)
+	re.Zero(watcher.LagRevisions())
+
+	ruleManager := suite.pdLeaderServer.GetRaftCluster().GetRuleManager()
+	newRule := &placement.Rule{GroupID: "lag", ID: "1", Role: placement.Voter, Count: 1}
+	re.NoError(ruleManager.SetRule(newRule))
+	defer func() {
+		re.NoError(ruleManager.DeleteRule(newRule.GroupID, newRule.ID, false))
+	}()
+	testutil.Eventually(re, func() bool {
+		current, err := watcher.GetStatus(suite.ctx)
+		re.NoError(err)
+		return current.LatestRevision > status.LatestRevision && current.Lag == 0 &&
+			current.AppliedRevision == current.LatestRevision
+	})
+}
+
+func (suite *ruleTestSuite) TestRuleWatchSequence() {
+	re := suite.Require()
+
+	watcher, err := ruletest.NewTestWatcher(
+		suite.ctx,
+		suite.pdLeaderServer.GetEtcdClient(),
+		suite.cluster.GetCluster().GetId(),
+	)
+	re.NoError(err)
+	defer watcher.Close()
+
+	ruleManager := suite.pdLeaderServer.GetRaftCluster().GetRuleManager()
+	newRule := &placement.Rule{GroupID: "sequence", ID: "1", Role: placement.Voter, Count: 1}
+	re.NoError(ruleManager.SetRule(newRule))
+	newRule.Count = 2
+	re.NoError(ruleManager.SetRule(newRule))
+	re.NoError(ruleManager.DeleteRule(newRule.GroupID, newRule.ID, false))
+	watcher.WaitForEvents(re,
+		ruletest.RuleEvent(rule.EventAdd, "sequence", "1"),
+		ruletest.RuleEvent(rule.EventModify, "sequence", "1"),
+		ruletest.RuleEvent(rule.EventDelete, "sequence", "1"),
+	)
+
+	watcher.Reset()
+	re.NoError(ruleManager.SetRuleGroup(&placement.RuleGroup{ID: "sequence", Index: 100}))
+	re.NoError(ruleManager.DeleteRuleGroup("sequence"))
+	watcher.WaitForEvents(re,
+		ruletest.RuleGroupEvent(rule.EventAdd, "sequence"),
+		ruletest.RuleGroupEvent(rule.EventDelete, "sequence"),
+	)
+}
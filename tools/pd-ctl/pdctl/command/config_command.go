@@ -44,6 +44,7 @@ var (
 	ruleGroupsPrefix      = "pd/api/v1/config/rule_groups"
 	replicationModePrefix = "pd/api/v1/config/replication-mode"
 	ruleBundlePrefix      = "pd/api/v1/config/placement-rule"
+	placementBundlePrefix = "pd/api/v1/config/placement-bundle"
 	pdServerPrefix        = "pd/api/v1/config/pd-server"
 )
 
@@ -452,6 +453,24 @@ func NewPlacementRulesCommand() *cobra.Command {
 		Run:   putPlacementRulesFunc,
 	}
 	save.Flags().String("in", "rules.json", "the filename contains rules")
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "export all placement rules, rule groups and region label rules to a file",
+		Run:   exportPlacementBundleFunc,
+	}
+	export.Flags().String("file", "placement.json", "the output file")
+	importBundle := &cobra.Command{
+		Use:   "import",
+		Short: "import all placement rules, rule groups and region label rules from a file atomically",
+		Run:   importPlacementBundleFunc,
+	}
+	importBundle.Flags().String("file", "placement.json", "the file contains the exported bundle")
+	importBundle.Flags().String("mode", "replace", "how to handle the existing configurations not in the file, replace or merge")
+	conflicts := &cobra.Command{
+		Use:   "conflicts",
+		Short: "analyze all placement rules and show the conflicts",
+		Run:   getPlacementRuleConflictsFunc,
+	}
 	ruleGroup := &cobra.Command{
 		Use:   "rule-group",
 		Short: "rule group configurations",
@@ -472,6 +491,7 @@ func NewPlacementRulesCommand() *cobra.Command {
 		Run:   delRuleBundle,
 	}
 	ruleGroupDelete.Flags().Bool("regexp", false, "match group id by regular expression")
+	ruleGroupDelete.Flags().Bool("force", false, "delete the default rule if it's matched")
 	ruleGroup.AddCommand(ruleGroupShow, ruleGroupSet, ruleGroupDelete)
 	ruleBundle := &cobra.Command{
 		Use:   "rule-bundle",
@@ -495,6 +515,7 @@ func NewPlacementRulesCommand() *cobra.Command {
 		Run:   delRuleBundle,
 	}
 	ruleBundleDelete.Flags().Bool("regexp", false, "match group id by regular expression")
+	ruleBundleDelete.Flags().Bool("force", false, "delete the default rule if it's matched")
 	ruleBundleLoad := &cobra.Command{
 		Use:   "load",
 		Short: "load all group configs and rules to file",
@@ -508,8 +529,9 @@ func NewPlacementRulesCommand() *cobra.Command {
 	}
 	ruleBundleSave.Flags().String("in", "rules.json", "the file contains all group configs and all rules")
 	ruleBundleSave.Flags().Bool("partial", false, "do not drop all old configurations, partial update")
+	ruleBundleSave.Flags().Bool("force", false, "drop the default rule if it's not in the file")
 	ruleBundle.AddCommand(ruleBundleGet, ruleBundleSet, ruleBundleDelete, ruleBundleLoad, ruleBundleSave)
-	c.AddCommand(enable, disable, show, load, save, ruleGroup, ruleBundle)
+	c.AddCommand(enable, disable, show, load, save, export, importBundle, conflicts, ruleGroup, ruleBundle)
 	return c
 }
 
@@ -619,6 +641,46 @@ func putPlacementRulesFunc(cmd *cobra.Command, args []string) {
 	cmd.Println("Success!")
 }
 
+func exportPlacementBundleFunc(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("file")
+	res, err := doRequest(cmd, placementBundlePrefix, http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	if err := os.WriteFile(file, []byte(res), 0644); err != nil { // #nosec
+		cmd.Println(err)
+		return
+	}
+	cmd.Printf("placement bundle saved to file %s\n", file)
+}
+
+func importPlacementBundleFunc(cmd *cobra.Command, args []string) {
+	file, _ := cmd.Flags().GetString("file")
+	mode, _ := cmd.Flags().GetString("mode")
+	content, err := os.ReadFile(file)
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	reqPath := placementBundlePrefix + "?" + url.Values{"mode": {mode}}.Encode()
+	_, err = doRequest(cmd, reqPath, http.MethodPost, http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewReader(content)))
+	if err != nil {
+		cmd.Printf("failed to import placement bundle: %s\n", err)
+		return
+	}
+	cmd.Println("Success!")
+}
+
+func getPlacementRuleConflictsFunc(cmd *cobra.Command, args []string) {
+	res, err := doRequest(cmd, path.Join(rulesPrefix, "conflicts", "report"), http.MethodGet, http.Header{})
+	if err != nil {
+		cmd.Println(err)
+		return
+	}
+	cmd.Println(res)
+}
+
 func showRuleGroupFunc(cmd *cobra.Command, args []string) {
 	if len(args) > 1 {
 		cmd.Println(cmd.UsageString())
@@ -733,8 +795,15 @@ func delRuleBundle(cmd *cobra.Command, args []string) {
 
 	reqPath := path.Join(ruleBundlePrefix, url.PathEscape(args[0]))
 
+	query := url.Values{}
 	if ok, _ := cmd.Flags().GetBool("regexp"); ok {
-		reqPath += "?regexp"
+		query.Set("regexp", "")
+	}
+	if ok, _ := cmd.Flags().GetBool("force"); ok {
+		query.Set("force", "")
+	}
+	if len(query) > 0 {
+		reqPath += "?" + query.Encode()
 	}
 
 	res, err := doRequest(cmd, reqPath, http.MethodDelete, http.Header{})
@@ -782,8 +851,15 @@ func saveRuleBundle(cmd *cobra.Command, args []string) {
 	}
 
 	path := ruleBundlePrefix
+	query := url.Values{}
 	if ok, _ := cmd.Flags().GetBool("partial"); ok {
-		path += "?partial=true"
+		query.Set("partial", "true")
+	}
+	if ok, _ := cmd.Flags().GetBool("force"); ok {
+		query.Set("force", "true")
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
 	}
 
 	res, err := doRequest(cmd, path, http.MethodPost, http.Header{"Content-Type": {"application/json"}}, WithBody(bytes.NewReader(content)))
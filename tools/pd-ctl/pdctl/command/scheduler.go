@@ -23,6 +23,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pingcap/errors"
 	"github.com/spf13/cobra"
@@ -55,8 +56,8 @@ func NewSchedulerCommand() *cobra.Command {
 // NewPauseSchedulerCommand returns a command to pause a scheduler.
 func NewPauseSchedulerCommand() *cobra.Command {
 	c := &cobra.Command{
-		Use:   "pause <scheduler> <delay_seconds>",
-		Short: "pause a scheduler",
+		Use:   "pause <scheduler> <delay_seconds|duration>",
+		Short: "pause a scheduler, it resumes automatically after the delay, e.g. 600 or 2h",
 		Run:   pauseSchedulerCommandFunc,
 	}
 	return c
@@ -68,6 +69,11 @@ func pauseSchedulerCommandFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 	delay, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		var d time.Duration
+		d, err = time.ParseDuration(args[1])
+		delay = int64(d / time.Second)
+	}
 	if err != nil || delay <= 0 {
 		cmd.Usage()
 		return